@@ -26,13 +26,28 @@ const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 
 // Client represents an OAuth2 client.
 type Client struct {
-	Id                   string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Secret               string   `protobuf:"bytes,2,opt,name=secret,proto3" json:"secret,omitempty"`
-	RedirectUris         []string `protobuf:"bytes,3,rep,name=redirect_uris,json=redirectUris,proto3" json:"redirect_uris,omitempty"`
-	TrustedPeers         []string `protobuf:"bytes,4,rep,name=trusted_peers,json=trustedPeers,proto3" json:"trusted_peers,omitempty"`
-	Public               bool     `protobuf:"varint,5,opt,name=public,proto3" json:"public,omitempty"`
-	Name                 string   `protobuf:"bytes,6,opt,name=name,proto3" json:"name,omitempty"`
-	LogoUrl              string   `protobuf:"bytes,7,opt,name=logo_url,json=logoUrl,proto3" json:"logo_url,omitempty"`
+	Id           string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Secret       string   `protobuf:"bytes,2,opt,name=secret,proto3" json:"secret,omitempty"`
+	RedirectUris []string `protobuf:"bytes,3,rep,name=redirect_uris,json=redirectUris,proto3" json:"redirect_uris,omitempty"`
+	TrustedPeers []string `protobuf:"bytes,4,rep,name=trusted_peers,json=trustedPeers,proto3" json:"trusted_peers,omitempty"`
+	Public       bool     `protobuf:"varint,5,opt,name=public,proto3" json:"public,omitempty"`
+	Name         string   `protobuf:"bytes,6,opt,name=name,proto3" json:"name,omitempty"`
+	LogoUrl      string   `protobuf:"bytes,7,opt,name=logo_url,json=logoUrl,proto3" json:"logo_url,omitempty"`
+	AllowedCidrs []string `protobuf:"bytes,8,rep,name=allowed_cidrs,json=allowedCidrs,proto3" json:"allowed_cidrs,omitempty"`
+	// bcrypt or argon2id hash of the secret, stored instead of the secret.
+	// Only one of secret and secret_hash may be set.
+	SecretHash string `protobuf:"bytes,9,opt,name=secret_hash,json=secretHash,proto3" json:"secret_hash,omitempty"`
+	// Metadata of OAuth 2.0 Dynamic Client Registration shown to end users.
+	TosUri    string   `protobuf:"bytes,10,opt,name=tos_uri,json=tosUri,proto3" json:"tos_uri,omitempty"`
+	PolicyUri string   `protobuf:"bytes,11,opt,name=policy_uri,json=policyUri,proto3" json:"policy_uri,omitempty"`
+	Contacts  []string `protobuf:"bytes,12,rep,name=contacts,proto3" json:"contacts,omitempty"`
+	// Either "web" or "native".
+	ApplicationType string `protobuf:"bytes,13,opt,name=application_type,json=applicationType,proto3" json:"application_type,omitempty"`
+	// Unix time the client expires at. Expired clients can't authenticate
+	// and are deleted along with their tokens. 0 for clients that don't expire.
+	Expiry int64 `protobuf:"varint,14,opt,name=expiry,proto3" json:"expiry,omitempty"`
+	// Receives OpenID Connect Back-Channel Logout tokens.
+	BackchannelLogoutUri string   `protobuf:"bytes,15,opt,name=backchannel_logout_uri,json=backchannelLogoutUri,proto3" json:"backchannel_logout_uri,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -112,6 +127,62 @@ func (m *Client) GetLogoUrl() string {
 	return ""
 }
 
+func (m *Client) GetAllowedCidrs() []string {
+	if m != nil {
+		return m.AllowedCidrs
+	}
+	return nil
+}
+
+func (m *Client) GetSecretHash() string {
+	if m != nil {
+		return m.SecretHash
+	}
+	return ""
+}
+
+func (m *Client) GetTosUri() string {
+	if m != nil {
+		return m.TosUri
+	}
+	return ""
+}
+
+func (m *Client) GetPolicyUri() string {
+	if m != nil {
+		return m.PolicyUri
+	}
+	return ""
+}
+
+func (m *Client) GetContacts() []string {
+	if m != nil {
+		return m.Contacts
+	}
+	return nil
+}
+
+func (m *Client) GetApplicationType() string {
+	if m != nil {
+		return m.ApplicationType
+	}
+	return ""
+}
+
+func (m *Client) GetExpiry() int64 {
+	if m != nil {
+		return m.Expiry
+	}
+	return 0
+}
+
+func (m *Client) GetBackchannelLogoutUri() string {
+	if m != nil {
+		return m.BackchannelLogoutUri
+	}
+	return ""
+}
+
 // CreateClientReq is a request to make a client.
 type CreateClientReq struct {
 	Client               *Client  `protobuf:"bytes,1,opt,name=client,proto3" json:"client,omitempty"`
@@ -283,11 +354,21 @@ func (m *DeleteClientResp) GetNotFound() bool {
 
 // UpdateClientReq is a request to update an exisitng client.
 type UpdateClientReq struct {
-	Id                   string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	RedirectUris         []string `protobuf:"bytes,2,rep,name=redirect_uris,json=redirectUris,proto3" json:"redirect_uris,omitempty"`
-	TrustedPeers         []string `protobuf:"bytes,3,rep,name=trusted_peers,json=trustedPeers,proto3" json:"trusted_peers,omitempty"`
-	Name                 string   `protobuf:"bytes,4,opt,name=name,proto3" json:"name,omitempty"`
-	LogoUrl              string   `protobuf:"bytes,5,opt,name=logo_url,json=logoUrl,proto3" json:"logo_url,omitempty"`
+	Id           string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	RedirectUris []string `protobuf:"bytes,2,rep,name=redirect_uris,json=redirectUris,proto3" json:"redirect_uris,omitempty"`
+	TrustedPeers []string `protobuf:"bytes,3,rep,name=trusted_peers,json=trustedPeers,proto3" json:"trusted_peers,omitempty"`
+	Name         string   `protobuf:"bytes,4,opt,name=name,proto3" json:"name,omitempty"`
+	LogoUrl      string   `protobuf:"bytes,5,opt,name=logo_url,json=logoUrl,proto3" json:"logo_url,omitempty"`
+	AllowedCidrs []string `protobuf:"bytes,6,rep,name=allowed_cidrs,json=allowedCidrs,proto3" json:"allowed_cidrs,omitempty"`
+	// If set, replaces the client's secret by this bcrypt or argon2id hash.
+	SecretHash      string   `protobuf:"bytes,7,opt,name=secret_hash,json=secretHash,proto3" json:"secret_hash,omitempty"`
+	TosUri          string   `protobuf:"bytes,8,opt,name=tos_uri,json=tosUri,proto3" json:"tos_uri,omitempty"`
+	PolicyUri       string   `protobuf:"bytes,9,opt,name=policy_uri,json=policyUri,proto3" json:"policy_uri,omitempty"`
+	Contacts        []string `protobuf:"bytes,10,rep,name=contacts,proto3" json:"contacts,omitempty"`
+	ApplicationType string   `protobuf:"bytes,11,opt,name=application_type,json=applicationType,proto3" json:"application_type,omitempty"`
+	// If set, replaces the client's expiry by this Unix time.
+	Expiry               int64    `protobuf:"varint,12,opt,name=expiry,proto3" json:"expiry,omitempty"`
+	BackchannelLogoutUri string   `protobuf:"bytes,13,opt,name=backchannel_logout_uri,json=backchannelLogoutUri,proto3" json:"backchannel_logout_uri,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -353,6 +434,62 @@ func (m *UpdateClientReq) GetLogoUrl() string {
 	return ""
 }
 
+func (m *UpdateClientReq) GetAllowedCidrs() []string {
+	if m != nil {
+		return m.AllowedCidrs
+	}
+	return nil
+}
+
+func (m *UpdateClientReq) GetSecretHash() string {
+	if m != nil {
+		return m.SecretHash
+	}
+	return ""
+}
+
+func (m *UpdateClientReq) GetTosUri() string {
+	if m != nil {
+		return m.TosUri
+	}
+	return ""
+}
+
+func (m *UpdateClientReq) GetPolicyUri() string {
+	if m != nil {
+		return m.PolicyUri
+	}
+	return ""
+}
+
+func (m *UpdateClientReq) GetContacts() []string {
+	if m != nil {
+		return m.Contacts
+	}
+	return nil
+}
+
+func (m *UpdateClientReq) GetApplicationType() string {
+	if m != nil {
+		return m.ApplicationType
+	}
+	return ""
+}
+
+func (m *UpdateClientReq) GetExpiry() int64 {
+	if m != nil {
+		return m.Expiry
+	}
+	return 0
+}
+
+func (m *UpdateClientReq) GetBackchannelLogoutUri() string {
+	if m != nil {
+		return m.BackchannelLogoutUri
+	}
+	return ""
+}
+
 // UpdateClientResp returns the reponse form updating a client.
 type UpdateClientResp struct {
 	NotFound             bool     `protobuf:"varint,1,opt,name=not_found,json=notFound,proto3" json:"not_found,omitempty"`
@@ -1200,244 +1337,2571 @@ func (m *VerifyPasswordResp) GetNotFound() bool {
 	return false
 }
 
-func init() {
-	proto.RegisterType((*Client)(nil), "api.Client")
-	proto.RegisterType((*CreateClientReq)(nil), "api.CreateClientReq")
-	proto.RegisterType((*CreateClientResp)(nil), "api.CreateClientResp")
-	proto.RegisterType((*DeleteClientReq)(nil), "api.DeleteClientReq")
-	proto.RegisterType((*DeleteClientResp)(nil), "api.DeleteClientResp")
-	proto.RegisterType((*UpdateClientReq)(nil), "api.UpdateClientReq")
-	proto.RegisterType((*UpdateClientResp)(nil), "api.UpdateClientResp")
-	proto.RegisterType((*Password)(nil), "api.Password")
-	proto.RegisterType((*CreatePasswordReq)(nil), "api.CreatePasswordReq")
-	proto.RegisterType((*CreatePasswordResp)(nil), "api.CreatePasswordResp")
-	proto.RegisterType((*UpdatePasswordReq)(nil), "api.UpdatePasswordReq")
-	proto.RegisterType((*UpdatePasswordResp)(nil), "api.UpdatePasswordResp")
-	proto.RegisterType((*DeletePasswordReq)(nil), "api.DeletePasswordReq")
-	proto.RegisterType((*DeletePasswordResp)(nil), "api.DeletePasswordResp")
-	proto.RegisterType((*ListPasswordReq)(nil), "api.ListPasswordReq")
-	proto.RegisterType((*ListPasswordResp)(nil), "api.ListPasswordResp")
-	proto.RegisterType((*VersionReq)(nil), "api.VersionReq")
-	proto.RegisterType((*VersionResp)(nil), "api.VersionResp")
-	proto.RegisterType((*RefreshTokenRef)(nil), "api.RefreshTokenRef")
-	proto.RegisterType((*ListRefreshReq)(nil), "api.ListRefreshReq")
-	proto.RegisterType((*ListRefreshResp)(nil), "api.ListRefreshResp")
-	proto.RegisterType((*RevokeRefreshReq)(nil), "api.RevokeRefreshReq")
-	proto.RegisterType((*RevokeRefreshResp)(nil), "api.RevokeRefreshResp")
-	proto.RegisterType((*VerifyPasswordReq)(nil), "api.VerifyPasswordReq")
-	proto.RegisterType((*VerifyPasswordResp)(nil), "api.VerifyPasswordResp")
+// Feature is an experimental capability of the server.
+type Feature struct {
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// Whether this version of the server implements the feature.
+	Supported bool `protobuf:"varint,2,opt,name=supported,proto3" json:"supported,omitempty"`
+	// Whether the feature is enabled in the server's config.
+	Enabled              bool     `protobuf:"varint,3,opt,name=enabled,proto3" json:"enabled,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func init() { proto.RegisterFile("api/v2/api.proto", fileDescriptor_14cbb315f08d2e3f) }
-
-var fileDescriptor_14cbb315f08d2e3f = []byte{
-	// 908 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x56, 0xeb, 0x6e, 0xdb, 0x36,
-	0x14, 0xb6, 0xad, 0xd8, 0x91, 0x8f, 0x6f, 0x32, 0xe7, 0xa6, 0xae, 0x8b, 0x01, 0x29, 0x8b, 0x01,
-	0x29, 0x06, 0xd8, 0x6b, 0x06, 0x6c, 0xc0, 0x8a, 0x75, 0x97, 0xb4, 0x5b, 0x0b, 0x6c, 0x43, 0x21,
-	0xcc, 0xfd, 0x39, 0x41, 0xb5, 0x8e, 0x1b, 0xa2, 0x8a, 0xa4, 0x91, 0xb4, 0x9d, 0xee, 0x51, 0xf6,
-	0x36, 0xfb, 0xb5, 0xd7, 0x2a, 0x48, 0x51, 0x8a, 0x2e, 0x6e, 0x9d, 0x7f, 0x3e, 0x9f, 0x78, 0x6e,
-	0xdf, 0xe1, 0xf9, 0x68, 0x70, 0xfc, 0x84, 0x2d, 0xb6, 0xe7, 0x0b, 0x3f, 0x61, 0xf3, 0x84, 0xc7,
-	0x32, 0x26, 0x96, 0x9f, 0x30, 0xfa, 0x5f, 0x13, 0x3a, 0x17, 0x21, 0xc3, 0x48, 0x92, 0x21, 0xb4,
-	0x58, 0x30, 0x6d, 0x9e, 0x36, 0xcf, 0xba, 0x6e, 0x8b, 0x05, 0xe4, 0x04, 0x3a, 0x02, 0x57, 0x1c,
-	0xe5, 0xb4, 0xa5, 0x31, 0x63, 0x91, 0x87, 0x30, 0xe0, 0x18, 0x30, 0x8e, 0x2b, 0xe9, 0x6d, 0x38,
-	0x13, 0x53, 0xeb, 0xd4, 0x3a, 0xeb, 0xba, 0xfd, 0x0c, 0x5c, 0x72, 0x26, 0xd4, 0x21, 0xc9, 0x37,
-	0x42, 0x62, 0xe0, 0x25, 0x88, 0x5c, 0x4c, 0x8f, 0xd2, 0x43, 0x06, 0x7c, 0xa5, 0x30, 0x95, 0x21,
-	0xd9, 0xbc, 0x09, 0xd9, 0x6a, 0xda, 0x3e, 0x6d, 0x9e, 0xd9, 0xae, 0xb1, 0x08, 0x81, 0xa3, 0xc8,
-	0xbf, 0xc2, 0x69, 0x47, 0xe7, 0xd5, 0xbf, 0xc9, 0x3d, 0xb0, 0xc3, 0xf8, 0x6d, 0xec, 0x6d, 0x78,
-	0x38, 0x3d, 0xd6, 0xf8, 0xb1, 0xb2, 0x97, 0x3c, 0xa4, 0xdf, 0xc0, 0xe8, 0x82, 0xa3, 0x2f, 0x31,
-	0x6d, 0xc4, 0xc5, 0xbf, 0xc9, 0x43, 0xe8, 0xac, 0xb4, 0xa1, 0xfb, 0xe9, 0x9d, 0xf7, 0xe6, 0xaa,
-	0x6f, 0xf3, 0xdd, 0x7c, 0xa2, 0x7f, 0x81, 0x53, 0xf6, 0x13, 0x09, 0xf9, 0x02, 0x86, 0x7e, 0xc8,
-	0xd1, 0x0f, 0xde, 0x7b, 0x78, 0xcd, 0x84, 0x14, 0x3a, 0x80, 0xed, 0x0e, 0x0c, 0xfa, 0x5c, 0x83,
-	0x85, 0xf8, 0xad, 0x8f, 0xc7, 0x7f, 0x00, 0xa3, 0x67, 0x18, 0x62, 0xb1, 0xae, 0x0a, 0xc7, 0x74,
-	0x01, 0x4e, 0xf9, 0x88, 0x48, 0xc8, 0x7d, 0xe8, 0x46, 0xb1, 0xf4, 0xd6, 0xf1, 0x26, 0x0a, 0x4c,
-	0x76, 0x3b, 0x8a, 0xe5, 0x2f, 0xca, 0xa6, 0xff, 0x36, 0x61, 0xb4, 0x4c, 0x02, 0xff, 0x13, 0x41,
-	0xeb, 0x03, 0x6a, 0xdd, 0x66, 0x40, 0xd6, 0x9e, 0x01, 0x65, 0x83, 0x38, 0xfa, 0xc8, 0x20, 0xda,
-	0xe5, 0x41, 0x2c, 0xc0, 0x29, 0xd7, 0x76, 0xa8, 0x1b, 0x06, 0xf6, 0x2b, 0x5f, 0x88, 0x5d, 0xcc,
-	0x03, 0x32, 0x81, 0x36, 0x5e, 0xf9, 0x2c, 0x34, 0x8d, 0xa4, 0x86, 0xaa, 0xe0, 0xd2, 0x17, 0x97,
-	0x9a, 0xe6, 0xbe, 0xab, 0x7f, 0x93, 0x19, 0xd8, 0x1b, 0x81, 0x5c, 0x57, 0x66, 0xe9, 0xc3, 0xb9,
-	0x4d, 0xee, 0xc2, 0xb1, 0xfa, 0xed, 0xb1, 0xc0, 0x14, 0xdd, 0x51, 0xe6, 0xcb, 0x80, 0x3e, 0x85,
-	0x71, 0x3a, 0xec, 0x2c, 0xa1, 0x62, 0xee, 0x11, 0xd8, 0x89, 0x31, 0xcd, 0x45, 0x19, 0xe8, 0x41,
-	0xe6, 0x67, 0xf2, 0xcf, 0xf4, 0x09, 0x90, 0xaa, 0xff, 0xad, 0xaf, 0x0b, 0x7d, 0x0b, 0xe3, 0x94,
-	0x98, 0x62, 0xf2, 0xfd, 0x0d, 0xdf, 0x03, 0x3b, 0xc2, 0x9d, 0x57, 0x68, 0xfa, 0x38, 0xc2, 0xdd,
-	0x0b, 0xd5, 0xf7, 0x03, 0xe8, 0xab, 0x4f, 0x95, 0xde, 0x7b, 0x11, 0xee, 0x96, 0x06, 0xa2, 0x8f,
-	0x81, 0x54, 0x13, 0x1d, 0x9a, 0xc1, 0x23, 0x18, 0xa7, 0x57, 0xf0, 0x60, 0x6d, 0x2a, 0x7a, 0xf5,
-	0xe8, 0xa1, 0xe8, 0x63, 0x18, 0xfd, 0xc6, 0x84, 0x2c, 0xc4, 0xa6, 0x3f, 0x80, 0x53, 0x86, 0x44,
-	0x42, 0xbe, 0x84, 0x6e, 0xc6, 0xb4, 0xa2, 0xd0, 0xaa, 0x4f, 0xe2, 0xe6, 0x3b, 0xed, 0x03, 0xbc,
-	0x46, 0x2e, 0x58, 0x1c, 0xa9, 0x70, 0xdf, 0x42, 0x2f, 0xb7, 0x44, 0x92, 0xaa, 0x16, 0xdf, 0x22,
-	0x37, 0xa5, 0x1b, 0x8b, 0x38, 0xa0, 0xf4, 0x4e, 0x53, 0xda, 0x76, 0xb5, 0xf4, 0xfd, 0x03, 0x23,
-	0x17, 0xd7, 0x1c, 0xc5, 0xe5, 0x9f, 0xf1, 0x3b, 0x8c, 0x5c, 0x5c, 0xd7, 0x36, 0xe9, 0x3e, 0x74,
-	0xd3, 0x5d, 0x56, 0xf7, 0x29, 0x55, 0x41, 0x3b, 0x05, 0x5e, 0x06, 0xe4, 0x73, 0x80, 0x95, 0xbe,
-	0x11, 0x81, 0xe7, 0x4b, 0xbd, 0x0a, 0x96, 0xdb, 0x35, 0xc8, 0x4f, 0x52, 0xf9, 0x86, 0xbe, 0x90,
-	0x6a, 0x5c, 0x81, 0x56, 0x32, 0xcb, 0xb5, 0x15, 0xb0, 0x14, 0xa8, 0x48, 0x1f, 0x2a, 0x0e, 0x4c,
-	0x7e, 0xc5, 0x78, 0xe1, 0xe2, 0x36, 0x4b, 0x17, 0xf7, 0x8f, 0x94, 0xc1, 0xfc, 0xa8, 0x48, 0xc8,
-	0x13, 0x18, 0xf2, 0xd4, 0xf4, 0xa4, 0x2a, 0x3d, 0xa3, 0x6c, 0xa2, 0x29, 0xab, 0x34, 0xe5, 0x0e,
-	0x78, 0x01, 0x10, 0xf4, 0x05, 0x38, 0x2e, 0x6e, 0xe3, 0x77, 0x78, 0x8b, 0xe4, 0x9f, 0x24, 0x80,
-	0x7e, 0x05, 0xe3, 0x4a, 0xa4, 0x43, 0xb7, 0xe1, 0x39, 0x8c, 0x5f, 0x23, 0x67, 0xeb, 0xf7, 0x87,
-	0xf7, 0x60, 0x56, 0x58, 0x4d, 0x93, 0x38, 0xdf, 0xc5, 0xdf, 0x81, 0x54, 0xc3, 0x88, 0x44, 0x79,
-	0x6c, 0x15, 0xca, 0x30, 0x4f, 0x9c, 0xd9, 0xe5, 0xaa, 0x5a, 0xe5, 0xaa, 0xce, 0xff, 0x6f, 0x83,
-	0xf5, 0x0c, 0xaf, 0xc9, 0xf7, 0xd0, 0x2f, 0xbe, 0x07, 0x24, 0xa5, 0xb3, 0xf2, 0xb4, 0xcc, 0xee,
-	0xec, 0x41, 0x45, 0x42, 0x1b, 0xca, 0xbd, 0xa8, 0x7e, 0xc6, 0xbd, 0x22, 0xd6, 0xc6, 0xbd, 0x2a,
-	0x93, 0xa9, 0x7b, 0xf1, 0x29, 0x30, 0xee, 0x95, 0x07, 0xc4, 0xb8, 0x57, 0xdf, 0x0c, 0xda, 0x20,
-	0x17, 0x30, 0x2c, 0xeb, 0x13, 0x39, 0x29, 0x14, 0x5a, 0xe0, 0x7b, 0x76, 0x77, 0x2f, 0x9e, 0x05,
-	0x29, 0xcb, 0x87, 0x09, 0x52, 0x13, 0x2f, 0x13, 0xa4, 0xae, 0x35, 0x69, 0x90, 0xb2, 0x4a, 0x98,
-	0x20, 0x35, 0x95, 0x31, 0x41, 0xea, 0x92, 0x42, 0x1b, 0xe4, 0x29, 0x0c, 0x8a, 0x22, 0x21, 0x0c,
-	0x1d, 0x15, 0x2d, 0x31, 0x74, 0x54, 0xe5, 0x84, 0x36, 0xc8, 0x63, 0x80, 0x5f, 0x51, 0x1a, 0x61,
-	0x20, 0x23, 0x7d, 0xec, 0x46, 0x34, 0x66, 0x4e, 0x19, 0xd0, 0x2e, 0xdf, 0x41, 0xaf, 0xb0, 0x68,
-	0xe4, 0xb3, 0x3c, 0xf4, 0xcd, 0xa2, 0xcc, 0x26, 0x75, 0x50, 0xfb, 0xfe, 0x08, 0x83, 0xd2, 0x2a,
-	0x90, 0x3b, 0x66, 0x15, 0xcb, 0x8b, 0x36, 0x3b, 0xd9, 0x07, 0x67, 0xac, 0x95, 0xef, 0xb4, 0x61,
-	0xad, 0xb6, 0x2f, 0x86, 0xb5, 0xfa, 0x02, 0xd0, 0xc6, 0xcf, 0x13, 0x20, 0xab, 0xf8, 0x6a, 0xbe,
-	0x8a, 0x39, 0xc6, 0x62, 0x1e, 0xe0, 0xb5, 0x3a, 0xfa, 0xa6, 0xa3, 0xff, 0xef, 0x7d, 0xfd, 0x21,
-	0x00, 0x00, 0xff, 0xff, 0x3d, 0xbe, 0xae, 0xc8, 0x03, 0x0a, 0x00, 0x00,
+func (m *Feature) Reset()         { *m = Feature{} }
+func (m *Feature) String() string { return proto.CompactTextString(m) }
+func (*Feature) ProtoMessage()    {}
+func (*Feature) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14cbb315f08d2e3f, []int{25}
 }
 
-// Reference imports to suppress errors if they are not otherwise used.
-var _ context.Context
-var _ grpc.ClientConn
-
-// This is a compile-time assertion to ensure that this generated file
-// is compatible with the grpc package it is being compiled against.
-const _ = grpc.SupportPackageIsVersion4
-
-// DexClient is the client API for Dex service.
-//
-// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
-type DexClient interface {
-	// CreateClient creates a client.
-	CreateClient(ctx context.Context, in *CreateClientReq, opts ...grpc.CallOption) (*CreateClientResp, error)
-	// UpdateClient updates an existing client
-	UpdateClient(ctx context.Context, in *UpdateClientReq, opts ...grpc.CallOption) (*UpdateClientResp, error)
-	// DeleteClient deletes the provided client.
-	DeleteClient(ctx context.Context, in *DeleteClientReq, opts ...grpc.CallOption) (*DeleteClientResp, error)
-	// CreatePassword creates a password.
-	CreatePassword(ctx context.Context, in *CreatePasswordReq, opts ...grpc.CallOption) (*CreatePasswordResp, error)
-	// UpdatePassword modifies existing password.
-	UpdatePassword(ctx context.Context, in *UpdatePasswordReq, opts ...grpc.CallOption) (*UpdatePasswordResp, error)
-	// DeletePassword deletes the password.
-	DeletePassword(ctx context.Context, in *DeletePasswordReq, opts ...grpc.CallOption) (*DeletePasswordResp, error)
-	// ListPassword lists all password entries.
-	ListPasswords(ctx context.Context, in *ListPasswordReq, opts ...grpc.CallOption) (*ListPasswordResp, error)
-	// GetVersion returns version information of the server.
-	GetVersion(ctx context.Context, in *VersionReq, opts ...grpc.CallOption) (*VersionResp, error)
-	// ListRefresh lists all the refresh token entries for a particular user.
-	ListRefresh(ctx context.Context, in *ListRefreshReq, opts ...grpc.CallOption) (*ListRefreshResp, error)
-	// RevokeRefresh revokes the refresh token for the provided user-client pair.
-	//
-	// Note that each user-client pair can have only one refresh token at a time.
-	RevokeRefresh(ctx context.Context, in *RevokeRefreshReq, opts ...grpc.CallOption) (*RevokeRefreshResp, error)
-	// VerifyPassword returns whether a password matches a hash for a specific email or not.
-	VerifyPassword(ctx context.Context, in *VerifyPasswordReq, opts ...grpc.CallOption) (*VerifyPasswordResp, error)
+func (m *Feature) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Feature.Unmarshal(m, b)
 }
-
-type dexClient struct {
-	cc *grpc.ClientConn
+func (m *Feature) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Feature.Marshal(b, m, deterministic)
 }
-
-func NewDexClient(cc *grpc.ClientConn) DexClient {
-	return &dexClient{cc}
+func (m *Feature) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Feature.Merge(m, src)
 }
-
-func (c *dexClient) CreateClient(ctx context.Context, in *CreateClientReq, opts ...grpc.CallOption) (*CreateClientResp, error) {
-	out := new(CreateClientResp)
-	err := c.cc.Invoke(ctx, "/api.Dex/CreateClient", in, out, opts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
+func (m *Feature) XXX_Size() int {
+	return xxx_messageInfo_Feature.Size(m)
+}
+func (m *Feature) XXX_DiscardUnknown() {
+	xxx_messageInfo_Feature.DiscardUnknown(m)
 }
 
-func (c *dexClient) UpdateClient(ctx context.Context, in *UpdateClientReq, opts ...grpc.CallOption) (*UpdateClientResp, error) {
-	out := new(UpdateClientResp)
-	err := c.cc.Invoke(ctx, "/api.Dex/UpdateClient", in, out, opts...)
-	if err != nil {
-		return nil, err
+var xxx_messageInfo_Feature proto.InternalMessageInfo
+
+func (m *Feature) GetName() string {
+	if m != nil {
+		return m.Name
 	}
-	return out, nil
+	return ""
 }
 
-func (c *dexClient) DeleteClient(ctx context.Context, in *DeleteClientReq, opts ...grpc.CallOption) (*DeleteClientResp, error) {
-	out := new(DeleteClientResp)
-	err := c.cc.Invoke(ctx, "/api.Dex/DeleteClient", in, out, opts...)
-	if err != nil {
-		return nil, err
+func (m *Feature) GetSupported() bool {
+	if m != nil {
+		return m.Supported
 	}
-	return out, nil
+	return false
 }
 
-func (c *dexClient) CreatePassword(ctx context.Context, in *CreatePasswordReq, opts ...grpc.CallOption) (*CreatePasswordResp, error) {
-	out := new(CreatePasswordResp)
-	err := c.cc.Invoke(ctx, "/api.Dex/CreatePassword", in, out, opts...)
-	if err != nil {
-		return nil, err
+func (m *Feature) GetEnabled() bool {
+	if m != nil {
+		return m.Enabled
 	}
-	return out, nil
+	return false
 }
 
-func (c *dexClient) UpdatePassword(ctx context.Context, in *UpdatePasswordReq, opts ...grpc.CallOption) (*UpdatePasswordResp, error) {
-	out := new(UpdatePasswordResp)
-	err := c.cc.Invoke(ctx, "/api.Dex/UpdatePassword", in, out, opts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
+// ListFeaturesReq is a request to enumerate the features of the server.
+type ListFeaturesReq struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func (c *dexClient) DeletePassword(ctx context.Context, in *DeletePasswordReq, opts ...grpc.CallOption) (*DeletePasswordResp, error) {
-	out := new(DeletePasswordResp)
-	err := c.cc.Invoke(ctx, "/api.Dex/DeletePassword", in, out, opts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
+func (m *ListFeaturesReq) Reset()         { *m = ListFeaturesReq{} }
+func (m *ListFeaturesReq) String() string { return proto.CompactTextString(m) }
+func (*ListFeaturesReq) ProtoMessage()    {}
+func (*ListFeaturesReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14cbb315f08d2e3f, []int{26}
 }
 
-func (c *dexClient) ListPasswords(ctx context.Context, in *ListPasswordReq, opts ...grpc.CallOption) (*ListPasswordResp, error) {
-	out := new(ListPasswordResp)
-	err := c.cc.Invoke(ctx, "/api.Dex/ListPasswords", in, out, opts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
+func (m *ListFeaturesReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListFeaturesReq.Unmarshal(m, b)
+}
+func (m *ListFeaturesReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListFeaturesReq.Marshal(b, m, deterministic)
+}
+func (m *ListFeaturesReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListFeaturesReq.Merge(m, src)
+}
+func (m *ListFeaturesReq) XXX_Size() int {
+	return xxx_messageInfo_ListFeaturesReq.Size(m)
+}
+func (m *ListFeaturesReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListFeaturesReq.DiscardUnknown(m)
 }
 
-func (c *dexClient) GetVersion(ctx context.Context, in *VersionReq, opts ...grpc.CallOption) (*VersionResp, error) {
-	out := new(VersionResp)
-	err := c.cc.Invoke(ctx, "/api.Dex/GetVersion", in, out, opts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
+var xxx_messageInfo_ListFeaturesReq proto.InternalMessageInfo
+
+// ListFeaturesResp returns all features known to the server.
+type ListFeaturesResp struct {
+	Features             []*Feature `protobuf:"bytes,1,rep,name=features,proto3" json:"features,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}   `json:"-"`
+	XXX_unrecognized     []byte     `json:"-"`
+	XXX_sizecache        int32      `json:"-"`
 }
 
-func (c *dexClient) ListRefresh(ctx context.Context, in *ListRefreshReq, opts ...grpc.CallOption) (*ListRefreshResp, error) {
-	out := new(ListRefreshResp)
-	err := c.cc.Invoke(ctx, "/api.Dex/ListRefresh", in, out, opts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
+func (m *ListFeaturesResp) Reset()         { *m = ListFeaturesResp{} }
+func (m *ListFeaturesResp) String() string { return proto.CompactTextString(m) }
+func (*ListFeaturesResp) ProtoMessage()    {}
+func (*ListFeaturesResp) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14cbb315f08d2e3f, []int{27}
 }
 
-func (c *dexClient) RevokeRefresh(ctx context.Context, in *RevokeRefreshReq, opts ...grpc.CallOption) (*RevokeRefreshResp, error) {
-	out := new(RevokeRefreshResp)
-	err := c.cc.Invoke(ctx, "/api.Dex/RevokeRefresh", in, out, opts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
+func (m *ListFeaturesResp) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListFeaturesResp.Unmarshal(m, b)
+}
+func (m *ListFeaturesResp) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListFeaturesResp.Marshal(b, m, deterministic)
+}
+func (m *ListFeaturesResp) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListFeaturesResp.Merge(m, src)
+}
+func (m *ListFeaturesResp) XXX_Size() int {
+	return xxx_messageInfo_ListFeaturesResp.Size(m)
+}
+func (m *ListFeaturesResp) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListFeaturesResp.DiscardUnknown(m)
 }
 
-func (c *dexClient) VerifyPassword(ctx context.Context, in *VerifyPasswordReq, opts ...grpc.CallOption) (*VerifyPasswordResp, error) {
-	out := new(VerifyPasswordResp)
-	err := c.cc.Invoke(ctx, "/api.Dex/VerifyPassword", in, out, opts...)
-	if err != nil {
-		return nil, err
+var xxx_messageInfo_ListFeaturesResp proto.InternalMessageInfo
+
+func (m *ListFeaturesResp) GetFeatures() []*Feature {
+	if m != nil {
+		return m.Features
 	}
-	return out, nil
+	return nil
 }
 
-// DexServer is the server API for Dex service.
-type DexServer interface {
+// AuditEvent is a security relevant event recorded by the server.
+type AuditEvent struct {
+	Id       string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Type     string `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Severity string `protobuf:"bytes,3,opt,name=severity,proto3" json:"severity,omitempty"`
+	// Unix time of the event.
+	Time     int64  `protobuf:"varint,4,opt,name=time,proto3" json:"time,omitempty"`
+	ClientId string `protobuf:"bytes,5,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	// The "sub" claim of the user involved in the event.
+	Subject     string   `protobuf:"bytes,6,opt,name=subject,proto3" json:"subject,omitempty"`
+	ConnectorId string   `protobuf:"bytes,7,opt,name=connector_id,json=connectorId,proto3" json:"connector_id,omitempty"`
+	SourceIps   []string `protobuf:"bytes,8,rep,name=source_ips,json=sourceIps,proto3" json:"source_ips,omitempty"`
+	Message     string   `protobuf:"bytes,9,opt,name=message,proto3" json:"message,omitempty"`
+	// Whether the affected session was revoked in response to the event.
+	Revoked              bool     `protobuf:"varint,10,opt,name=revoked,proto3" json:"revoked,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *AuditEvent) Reset()         { *m = AuditEvent{} }
+func (m *AuditEvent) String() string { return proto.CompactTextString(m) }
+func (*AuditEvent) ProtoMessage()    {}
+func (*AuditEvent) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14cbb315f08d2e3f, []int{28}
+}
+
+func (m *AuditEvent) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AuditEvent.Unmarshal(m, b)
+}
+func (m *AuditEvent) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AuditEvent.Marshal(b, m, deterministic)
+}
+func (m *AuditEvent) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AuditEvent.Merge(m, src)
+}
+func (m *AuditEvent) XXX_Size() int {
+	return xxx_messageInfo_AuditEvent.Size(m)
+}
+func (m *AuditEvent) XXX_DiscardUnknown() {
+	xxx_messageInfo_AuditEvent.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AuditEvent proto.InternalMessageInfo
+
+func (m *AuditEvent) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *AuditEvent) GetType() string {
+	if m != nil {
+		return m.Type
+	}
+	return ""
+}
+
+func (m *AuditEvent) GetSeverity() string {
+	if m != nil {
+		return m.Severity
+	}
+	return ""
+}
+
+func (m *AuditEvent) GetTime() int64 {
+	if m != nil {
+		return m.Time
+	}
+	return 0
+}
+
+func (m *AuditEvent) GetClientId() string {
+	if m != nil {
+		return m.ClientId
+	}
+	return ""
+}
+
+func (m *AuditEvent) GetSubject() string {
+	if m != nil {
+		return m.Subject
+	}
+	return ""
+}
+
+func (m *AuditEvent) GetConnectorId() string {
+	if m != nil {
+		return m.ConnectorId
+	}
+	return ""
+}
+
+func (m *AuditEvent) GetSourceIps() []string {
+	if m != nil {
+		return m.SourceIps
+	}
+	return nil
+}
+
+func (m *AuditEvent) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
+func (m *AuditEvent) GetRevoked() bool {
+	if m != nil {
+		return m.Revoked
+	}
+	return false
+}
+
+// ListAuditEventsReq is a request to query the stored audit events. Fields
+// left empty match any event.
+type ListAuditEventsReq struct {
+	// The "sub" claim of the user involved in the event.
+	Subject  string `protobuf:"bytes,1,opt,name=subject,proto3" json:"subject,omitempty"`
+	ClientId string `protobuf:"bytes,2,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	Type     string `protobuf:"bytes,3,opt,name=type,proto3" json:"type,omitempty"`
+	// Unix times bounding the events, since inclusive and until exclusive.
+	Since int64 `protobuf:"varint,4,opt,name=since,proto3" json:"since,omitempty"`
+	Until int64 `protobuf:"varint,5,opt,name=until,proto3" json:"until,omitempty"`
+	// Maximum number of events to return. Defaults to 100, at most 1000.
+	Limit                int32    `protobuf:"varint,6,opt,name=limit,proto3" json:"limit,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListAuditEventsReq) Reset()         { *m = ListAuditEventsReq{} }
+func (m *ListAuditEventsReq) String() string { return proto.CompactTextString(m) }
+func (*ListAuditEventsReq) ProtoMessage()    {}
+func (*ListAuditEventsReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14cbb315f08d2e3f, []int{29}
+}
+
+func (m *ListAuditEventsReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListAuditEventsReq.Unmarshal(m, b)
+}
+func (m *ListAuditEventsReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListAuditEventsReq.Marshal(b, m, deterministic)
+}
+func (m *ListAuditEventsReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListAuditEventsReq.Merge(m, src)
+}
+func (m *ListAuditEventsReq) XXX_Size() int {
+	return xxx_messageInfo_ListAuditEventsReq.Size(m)
+}
+func (m *ListAuditEventsReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListAuditEventsReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListAuditEventsReq proto.InternalMessageInfo
+
+func (m *ListAuditEventsReq) GetSubject() string {
+	if m != nil {
+		return m.Subject
+	}
+	return ""
+}
+
+func (m *ListAuditEventsReq) GetClientId() string {
+	if m != nil {
+		return m.ClientId
+	}
+	return ""
+}
+
+func (m *ListAuditEventsReq) GetType() string {
+	if m != nil {
+		return m.Type
+	}
+	return ""
+}
+
+func (m *ListAuditEventsReq) GetSince() int64 {
+	if m != nil {
+		return m.Since
+	}
+	return 0
+}
+
+func (m *ListAuditEventsReq) GetUntil() int64 {
+	if m != nil {
+		return m.Until
+	}
+	return 0
+}
+
+func (m *ListAuditEventsReq) GetLimit() int32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+// ListAuditEventsResp returns the matching audit events, newest first.
+type ListAuditEventsResp struct {
+	Events               []*AuditEvent `protobuf:"bytes,1,rep,name=events,proto3" json:"events,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}      `json:"-"`
+	XXX_unrecognized     []byte        `json:"-"`
+	XXX_sizecache        int32         `json:"-"`
+}
+
+func (m *ListAuditEventsResp) Reset()         { *m = ListAuditEventsResp{} }
+func (m *ListAuditEventsResp) String() string { return proto.CompactTextString(m) }
+func (*ListAuditEventsResp) ProtoMessage()    {}
+func (*ListAuditEventsResp) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14cbb315f08d2e3f, []int{30}
+}
+
+func (m *ListAuditEventsResp) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListAuditEventsResp.Unmarshal(m, b)
+}
+func (m *ListAuditEventsResp) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListAuditEventsResp.Marshal(b, m, deterministic)
+}
+func (m *ListAuditEventsResp) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListAuditEventsResp.Merge(m, src)
+}
+func (m *ListAuditEventsResp) XXX_Size() int {
+	return xxx_messageInfo_ListAuditEventsResp.Size(m)
+}
+func (m *ListAuditEventsResp) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListAuditEventsResp.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListAuditEventsResp proto.InternalMessageInfo
+
+func (m *ListAuditEventsResp) GetEvents() []*AuditEvent {
+	if m != nil {
+		return m.Events
+	}
+	return nil
+}
+
+// ExportUserDataReq is a request to export all data stored about a user.
+type ExportUserDataReq struct {
+	// The "sub" claim returned in the ID Token.
+	Subject              string   `protobuf:"bytes,1,opt,name=subject,proto3" json:"subject,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ExportUserDataReq) Reset()         { *m = ExportUserDataReq{} }
+func (m *ExportUserDataReq) String() string { return proto.CompactTextString(m) }
+func (*ExportUserDataReq) ProtoMessage()    {}
+func (*ExportUserDataReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14cbb315f08d2e3f, []int{31}
+}
+
+func (m *ExportUserDataReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ExportUserDataReq.Unmarshal(m, b)
+}
+func (m *ExportUserDataReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ExportUserDataReq.Marshal(b, m, deterministic)
+}
+func (m *ExportUserDataReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ExportUserDataReq.Merge(m, src)
+}
+func (m *ExportUserDataReq) XXX_Size() int {
+	return xxx_messageInfo_ExportUserDataReq.Size(m)
+}
+func (m *ExportUserDataReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_ExportUserDataReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ExportUserDataReq proto.InternalMessageInfo
+
+func (m *ExportUserDataReq) GetSubject() string {
+	if m != nil {
+		return m.Subject
+	}
+	return ""
+}
+
+// ExportUserDataResp returns the data stored about a user.
+type ExportUserDataResp struct {
+	// JSON document of the user's password entry, offline session, refresh
+	// tokens, terms of service acceptance and audit events. Credentials, such
+	// as password hashes and tokens, are left out.
+	Data                 []byte   `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	NotFound             bool     `protobuf:"varint,2,opt,name=not_found,json=notFound,proto3" json:"not_found,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ExportUserDataResp) Reset()         { *m = ExportUserDataResp{} }
+func (m *ExportUserDataResp) String() string { return proto.CompactTextString(m) }
+func (*ExportUserDataResp) ProtoMessage()    {}
+func (*ExportUserDataResp) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14cbb315f08d2e3f, []int{32}
+}
+
+func (m *ExportUserDataResp) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ExportUserDataResp.Unmarshal(m, b)
+}
+func (m *ExportUserDataResp) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ExportUserDataResp.Marshal(b, m, deterministic)
+}
+func (m *ExportUserDataResp) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ExportUserDataResp.Merge(m, src)
+}
+func (m *ExportUserDataResp) XXX_Size() int {
+	return xxx_messageInfo_ExportUserDataResp.Size(m)
+}
+func (m *ExportUserDataResp) XXX_DiscardUnknown() {
+	xxx_messageInfo_ExportUserDataResp.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ExportUserDataResp proto.InternalMessageInfo
+
+func (m *ExportUserDataResp) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func (m *ExportUserDataResp) GetNotFound() bool {
+	if m != nil {
+		return m.NotFound
+	}
+	return false
+}
+
+// EraseUserDataReq is a request to erase all data stored about a user.
+type EraseUserDataReq struct {
+	// The "sub" claim returned in the ID Token.
+	Subject              string   `protobuf:"bytes,1,opt,name=subject,proto3" json:"subject,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *EraseUserDataReq) Reset()         { *m = EraseUserDataReq{} }
+func (m *EraseUserDataReq) String() string { return proto.CompactTextString(m) }
+func (*EraseUserDataReq) ProtoMessage()    {}
+func (*EraseUserDataReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14cbb315f08d2e3f, []int{33}
+}
+
+func (m *EraseUserDataReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_EraseUserDataReq.Unmarshal(m, b)
+}
+func (m *EraseUserDataReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_EraseUserDataReq.Marshal(b, m, deterministic)
+}
+func (m *EraseUserDataReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_EraseUserDataReq.Merge(m, src)
+}
+func (m *EraseUserDataReq) XXX_Size() int {
+	return xxx_messageInfo_EraseUserDataReq.Size(m)
+}
+func (m *EraseUserDataReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_EraseUserDataReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_EraseUserDataReq proto.InternalMessageInfo
+
+func (m *EraseUserDataReq) GetSubject() string {
+	if m != nil {
+		return m.Subject
+	}
+	return ""
+}
+
+// EraseUserDataResp returns the result of an erasure.
+type EraseUserDataResp struct {
+	NotFound bool `protobuf:"varint,1,opt,name=not_found,json=notFound,proto3" json:"not_found,omitempty"`
+	// Replaces the subject in the anonymized audit events of the user and
+	// identifies the audit event recording the erasure.
+	ErasureId            string   `protobuf:"bytes,2,opt,name=erasure_id,json=erasureId,proto3" json:"erasure_id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *EraseUserDataResp) Reset()         { *m = EraseUserDataResp{} }
+func (m *EraseUserDataResp) String() string { return proto.CompactTextString(m) }
+func (*EraseUserDataResp) ProtoMessage()    {}
+func (*EraseUserDataResp) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14cbb315f08d2e3f, []int{34}
+}
+
+func (m *EraseUserDataResp) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_EraseUserDataResp.Unmarshal(m, b)
+}
+func (m *EraseUserDataResp) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_EraseUserDataResp.Marshal(b, m, deterministic)
+}
+func (m *EraseUserDataResp) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_EraseUserDataResp.Merge(m, src)
+}
+func (m *EraseUserDataResp) XXX_Size() int {
+	return xxx_messageInfo_EraseUserDataResp.Size(m)
+}
+func (m *EraseUserDataResp) XXX_DiscardUnknown() {
+	xxx_messageInfo_EraseUserDataResp.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_EraseUserDataResp proto.InternalMessageInfo
+
+func (m *EraseUserDataResp) GetNotFound() bool {
+	if m != nil {
+		return m.NotFound
+	}
+	return false
+}
+
+func (m *EraseUserDataResp) GetErasureId() string {
+	if m != nil {
+		return m.ErasureId
+	}
+	return ""
+}
+
+// ValidateTemplatesReq is a request to validate the web templates in a directory
+// on the server's host.
+type ValidateTemplatesReq struct {
+	// The web directory, containing the static, templates and themes directories.
+	// Defaults to "./web".
+	WebDir string `protobuf:"bytes,1,opt,name=web_dir,json=webDir,proto3" json:"web_dir,omitempty"`
+	// The templates directory. Defaults to "( web_dir )/templates".
+	TemplatesDir string `protobuf:"bytes,2,opt,name=templates_dir,json=templatesDir,proto3" json:"templates_dir,omitempty"`
+	// The theme. Defaults to "coreos".
+	Theme                string   `protobuf:"bytes,3,opt,name=theme,proto3" json:"theme,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ValidateTemplatesReq) Reset()         { *m = ValidateTemplatesReq{} }
+func (m *ValidateTemplatesReq) String() string { return proto.CompactTextString(m) }
+func (*ValidateTemplatesReq) ProtoMessage()    {}
+func (*ValidateTemplatesReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14cbb315f08d2e3f, []int{35}
+}
+
+func (m *ValidateTemplatesReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ValidateTemplatesReq.Unmarshal(m, b)
+}
+func (m *ValidateTemplatesReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ValidateTemplatesReq.Marshal(b, m, deterministic)
+}
+func (m *ValidateTemplatesReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ValidateTemplatesReq.Merge(m, src)
+}
+func (m *ValidateTemplatesReq) XXX_Size() int {
+	return xxx_messageInfo_ValidateTemplatesReq.Size(m)
+}
+func (m *ValidateTemplatesReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_ValidateTemplatesReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ValidateTemplatesReq proto.InternalMessageInfo
+
+func (m *ValidateTemplatesReq) GetWebDir() string {
+	if m != nil {
+		return m.WebDir
+	}
+	return ""
+}
+
+func (m *ValidateTemplatesReq) GetTemplatesDir() string {
+	if m != nil {
+		return m.TemplatesDir
+	}
+	return ""
+}
+
+func (m *ValidateTemplatesReq) GetTheme() string {
+	if m != nil {
+		return m.Theme
+	}
+	return ""
+}
+
+// ValidateTemplatesResp returns the problems found in the templates.
+type ValidateTemplatesResp struct {
+	// Errors of loading or rendering the templates. Empty if all templates are
+	// valid.
+	Errors               []string `protobuf:"bytes,1,rep,name=errors,proto3" json:"errors,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ValidateTemplatesResp) Reset()         { *m = ValidateTemplatesResp{} }
+func (m *ValidateTemplatesResp) String() string { return proto.CompactTextString(m) }
+func (*ValidateTemplatesResp) ProtoMessage()    {}
+func (*ValidateTemplatesResp) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14cbb315f08d2e3f, []int{36}
+}
+
+func (m *ValidateTemplatesResp) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ValidateTemplatesResp.Unmarshal(m, b)
+}
+func (m *ValidateTemplatesResp) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ValidateTemplatesResp.Marshal(b, m, deterministic)
+}
+func (m *ValidateTemplatesResp) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ValidateTemplatesResp.Merge(m, src)
+}
+func (m *ValidateTemplatesResp) XXX_Size() int {
+	return xxx_messageInfo_ValidateTemplatesResp.Size(m)
+}
+func (m *ValidateTemplatesResp) XXX_DiscardUnknown() {
+	xxx_messageInfo_ValidateTemplatesResp.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ValidateTemplatesResp proto.InternalMessageInfo
+
+func (m *ValidateTemplatesResp) GetErrors() []string {
+	if m != nil {
+		return m.Errors
+	}
+	return nil
+}
+
+// APIKey is a long-lived credential exchanged for tokens of a client. The
+// secret part of the key is never returned after the key is created.
+type APIKey struct {
+	Id       string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	ClientId string `protobuf:"bytes,2,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	Name     string `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	// Scopes tokens may be requested with.
+	Scopes []string `protobuf:"bytes,4,rep,name=scopes,proto3" json:"scopes,omitempty"`
+	// Claims of the identity tokens are issued for.
+	UserId      string   `protobuf:"bytes,5,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Username    string   `protobuf:"bytes,6,opt,name=username,proto3" json:"username,omitempty"`
+	Email       string   `protobuf:"bytes,7,opt,name=email,proto3" json:"email,omitempty"`
+	Groups      []string `protobuf:"bytes,8,rep,name=groups,proto3" json:"groups,omitempty"`
+	ConnectorId string   `protobuf:"bytes,9,opt,name=connector_id,json=connectorId,proto3" json:"connector_id,omitempty"`
+	// Unix times. An expiry of 0 means the key doesn't expire.
+	CreatedAt            int64    `protobuf:"varint,10,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	Expiry               int64    `protobuf:"varint,11,opt,name=expiry,proto3" json:"expiry,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *APIKey) Reset()         { *m = APIKey{} }
+func (m *APIKey) String() string { return proto.CompactTextString(m) }
+func (*APIKey) ProtoMessage()    {}
+func (*APIKey) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14cbb315f08d2e3f, []int{37}
+}
+
+func (m *APIKey) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_APIKey.Unmarshal(m, b)
+}
+func (m *APIKey) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_APIKey.Marshal(b, m, deterministic)
+}
+func (m *APIKey) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_APIKey.Merge(m, src)
+}
+func (m *APIKey) XXX_Size() int {
+	return xxx_messageInfo_APIKey.Size(m)
+}
+func (m *APIKey) XXX_DiscardUnknown() {
+	xxx_messageInfo_APIKey.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_APIKey proto.InternalMessageInfo
+
+func (m *APIKey) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *APIKey) GetClientId() string {
+	if m != nil {
+		return m.ClientId
+	}
+	return ""
+}
+
+func (m *APIKey) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *APIKey) GetScopes() []string {
+	if m != nil {
+		return m.Scopes
+	}
+	return nil
+}
+
+func (m *APIKey) GetUserId() string {
+	if m != nil {
+		return m.UserId
+	}
+	return ""
+}
+
+func (m *APIKey) GetUsername() string {
+	if m != nil {
+		return m.Username
+	}
+	return ""
+}
+
+func (m *APIKey) GetEmail() string {
+	if m != nil {
+		return m.Email
+	}
+	return ""
+}
+
+func (m *APIKey) GetGroups() []string {
+	if m != nil {
+		return m.Groups
+	}
+	return nil
+}
+
+func (m *APIKey) GetConnectorId() string {
+	if m != nil {
+		return m.ConnectorId
+	}
+	return ""
+}
+
+func (m *APIKey) GetCreatedAt() int64 {
+	if m != nil {
+		return m.CreatedAt
+	}
+	return 0
+}
+
+func (m *APIKey) GetExpiry() int64 {
+	if m != nil {
+		return m.Expiry
+	}
+	return 0
+}
+
+// CreateAPIKeyReq is a request to create an API key.
+type CreateAPIKeyReq struct {
+	ClientId string   `protobuf:"bytes,1,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	Name     string   `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Scopes   []string `protobuf:"bytes,3,rep,name=scopes,proto3" json:"scopes,omitempty"`
+	UserId   string   `protobuf:"bytes,4,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Username string   `protobuf:"bytes,5,opt,name=username,proto3" json:"username,omitempty"`
+	Email    string   `protobuf:"bytes,6,opt,name=email,proto3" json:"email,omitempty"`
+	Groups   []string `protobuf:"bytes,7,rep,name=groups,proto3" json:"groups,omitempty"`
+	// Connector ID of issued tokens. Defaults to "api_key".
+	ConnectorId string `protobuf:"bytes,8,opt,name=connector_id,json=connectorId,proto3" json:"connector_id,omitempty"`
+	// Unix time the key expires at. 0 creates a key that doesn't expire.
+	Expiry               int64    `protobuf:"varint,9,opt,name=expiry,proto3" json:"expiry,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CreateAPIKeyReq) Reset()         { *m = CreateAPIKeyReq{} }
+func (m *CreateAPIKeyReq) String() string { return proto.CompactTextString(m) }
+func (*CreateAPIKeyReq) ProtoMessage()    {}
+func (*CreateAPIKeyReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14cbb315f08d2e3f, []int{38}
+}
+
+func (m *CreateAPIKeyReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CreateAPIKeyReq.Unmarshal(m, b)
+}
+func (m *CreateAPIKeyReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CreateAPIKeyReq.Marshal(b, m, deterministic)
+}
+func (m *CreateAPIKeyReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CreateAPIKeyReq.Merge(m, src)
+}
+func (m *CreateAPIKeyReq) XXX_Size() int {
+	return xxx_messageInfo_CreateAPIKeyReq.Size(m)
+}
+func (m *CreateAPIKeyReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_CreateAPIKeyReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CreateAPIKeyReq proto.InternalMessageInfo
+
+func (m *CreateAPIKeyReq) GetClientId() string {
+	if m != nil {
+		return m.ClientId
+	}
+	return ""
+}
+
+func (m *CreateAPIKeyReq) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *CreateAPIKeyReq) GetScopes() []string {
+	if m != nil {
+		return m.Scopes
+	}
+	return nil
+}
+
+func (m *CreateAPIKeyReq) GetUserId() string {
+	if m != nil {
+		return m.UserId
+	}
+	return ""
+}
+
+func (m *CreateAPIKeyReq) GetUsername() string {
+	if m != nil {
+		return m.Username
+	}
+	return ""
+}
+
+func (m *CreateAPIKeyReq) GetEmail() string {
+	if m != nil {
+		return m.Email
+	}
+	return ""
+}
+
+func (m *CreateAPIKeyReq) GetGroups() []string {
+	if m != nil {
+		return m.Groups
+	}
+	return nil
+}
+
+func (m *CreateAPIKeyReq) GetConnectorId() string {
+	if m != nil {
+		return m.ConnectorId
+	}
+	return ""
+}
+
+func (m *CreateAPIKeyReq) GetExpiry() int64 {
+	if m != nil {
+		return m.Expiry
+	}
+	return 0
+}
+
+// CreateAPIKeyResp returns the created API key.
+type CreateAPIKeyResp struct {
+	ApiKey *APIKey `protobuf:"bytes,1,opt,name=api_key,json=apiKey,proto3" json:"api_key,omitempty"`
+	// The key to pass to the token endpoint. It is only returned once.
+	Key                  string   `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	ClientNotFound       bool     `protobuf:"varint,3,opt,name=client_not_found,json=clientNotFound,proto3" json:"client_not_found,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CreateAPIKeyResp) Reset()         { *m = CreateAPIKeyResp{} }
+func (m *CreateAPIKeyResp) String() string { return proto.CompactTextString(m) }
+func (*CreateAPIKeyResp) ProtoMessage()    {}
+func (*CreateAPIKeyResp) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14cbb315f08d2e3f, []int{39}
+}
+
+func (m *CreateAPIKeyResp) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CreateAPIKeyResp.Unmarshal(m, b)
+}
+func (m *CreateAPIKeyResp) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CreateAPIKeyResp.Marshal(b, m, deterministic)
+}
+func (m *CreateAPIKeyResp) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CreateAPIKeyResp.Merge(m, src)
+}
+func (m *CreateAPIKeyResp) XXX_Size() int {
+	return xxx_messageInfo_CreateAPIKeyResp.Size(m)
+}
+func (m *CreateAPIKeyResp) XXX_DiscardUnknown() {
+	xxx_messageInfo_CreateAPIKeyResp.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CreateAPIKeyResp proto.InternalMessageInfo
+
+func (m *CreateAPIKeyResp) GetApiKey() *APIKey {
+	if m != nil {
+		return m.ApiKey
+	}
+	return nil
+}
+
+func (m *CreateAPIKeyResp) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+func (m *CreateAPIKeyResp) GetClientNotFound() bool {
+	if m != nil {
+		return m.ClientNotFound
+	}
+	return false
+}
+
+// ListAPIKeysReq is a request to list the API keys of a client. An empty
+// client ID lists all API keys.
+type ListAPIKeysReq struct {
+	ClientId             string   `protobuf:"bytes,1,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListAPIKeysReq) Reset()         { *m = ListAPIKeysReq{} }
+func (m *ListAPIKeysReq) String() string { return proto.CompactTextString(m) }
+func (*ListAPIKeysReq) ProtoMessage()    {}
+func (*ListAPIKeysReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14cbb315f08d2e3f, []int{40}
+}
+
+func (m *ListAPIKeysReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListAPIKeysReq.Unmarshal(m, b)
+}
+func (m *ListAPIKeysReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListAPIKeysReq.Marshal(b, m, deterministic)
+}
+func (m *ListAPIKeysReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListAPIKeysReq.Merge(m, src)
+}
+func (m *ListAPIKeysReq) XXX_Size() int {
+	return xxx_messageInfo_ListAPIKeysReq.Size(m)
+}
+func (m *ListAPIKeysReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListAPIKeysReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListAPIKeysReq proto.InternalMessageInfo
+
+func (m *ListAPIKeysReq) GetClientId() string {
+	if m != nil {
+		return m.ClientId
+	}
+	return ""
+}
+
+// ListAPIKeysResp returns the API keys.
+type ListAPIKeysResp struct {
+	ApiKeys              []*APIKey `protobuf:"bytes,1,rep,name=api_keys,json=apiKeys,proto3" json:"api_keys,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
+	XXX_unrecognized     []byte    `json:"-"`
+	XXX_sizecache        int32     `json:"-"`
+}
+
+func (m *ListAPIKeysResp) Reset()         { *m = ListAPIKeysResp{} }
+func (m *ListAPIKeysResp) String() string { return proto.CompactTextString(m) }
+func (*ListAPIKeysResp) ProtoMessage()    {}
+func (*ListAPIKeysResp) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14cbb315f08d2e3f, []int{41}
+}
+
+func (m *ListAPIKeysResp) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListAPIKeysResp.Unmarshal(m, b)
+}
+func (m *ListAPIKeysResp) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListAPIKeysResp.Marshal(b, m, deterministic)
+}
+func (m *ListAPIKeysResp) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListAPIKeysResp.Merge(m, src)
+}
+func (m *ListAPIKeysResp) XXX_Size() int {
+	return xxx_messageInfo_ListAPIKeysResp.Size(m)
+}
+func (m *ListAPIKeysResp) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListAPIKeysResp.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListAPIKeysResp proto.InternalMessageInfo
+
+func (m *ListAPIKeysResp) GetApiKeys() []*APIKey {
+	if m != nil {
+		return m.ApiKeys
+	}
+	return nil
+}
+
+// RevokeAPIKeyReq is a request to revoke an API key.
+type RevokeAPIKeyReq struct {
+	Id                   string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RevokeAPIKeyReq) Reset()         { *m = RevokeAPIKeyReq{} }
+func (m *RevokeAPIKeyReq) String() string { return proto.CompactTextString(m) }
+func (*RevokeAPIKeyReq) ProtoMessage()    {}
+func (*RevokeAPIKeyReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14cbb315f08d2e3f, []int{42}
+}
+
+func (m *RevokeAPIKeyReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RevokeAPIKeyReq.Unmarshal(m, b)
+}
+func (m *RevokeAPIKeyReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RevokeAPIKeyReq.Marshal(b, m, deterministic)
+}
+func (m *RevokeAPIKeyReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RevokeAPIKeyReq.Merge(m, src)
+}
+func (m *RevokeAPIKeyReq) XXX_Size() int {
+	return xxx_messageInfo_RevokeAPIKeyReq.Size(m)
+}
+func (m *RevokeAPIKeyReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_RevokeAPIKeyReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RevokeAPIKeyReq proto.InternalMessageInfo
+
+func (m *RevokeAPIKeyReq) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+// RevokeAPIKeyResp returns the result of a revocation.
+type RevokeAPIKeyResp struct {
+	NotFound             bool     `protobuf:"varint,1,opt,name=not_found,json=notFound,proto3" json:"not_found,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RevokeAPIKeyResp) Reset()         { *m = RevokeAPIKeyResp{} }
+func (m *RevokeAPIKeyResp) String() string { return proto.CompactTextString(m) }
+func (*RevokeAPIKeyResp) ProtoMessage()    {}
+func (*RevokeAPIKeyResp) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14cbb315f08d2e3f, []int{43}
+}
+
+func (m *RevokeAPIKeyResp) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RevokeAPIKeyResp.Unmarshal(m, b)
+}
+func (m *RevokeAPIKeyResp) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RevokeAPIKeyResp.Marshal(b, m, deterministic)
+}
+func (m *RevokeAPIKeyResp) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RevokeAPIKeyResp.Merge(m, src)
+}
+func (m *RevokeAPIKeyResp) XXX_Size() int {
+	return xxx_messageInfo_RevokeAPIKeyResp.Size(m)
+}
+func (m *RevokeAPIKeyResp) XXX_DiscardUnknown() {
+	xxx_messageInfo_RevokeAPIKeyResp.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RevokeAPIKeyResp proto.InternalMessageInfo
+
+func (m *RevokeAPIKeyResp) GetNotFound() bool {
+	if m != nil {
+		return m.NotFound
+	}
+	return false
+}
+
+// ServiceAccount is the identity of a workload. Service accounts authenticate
+// with JWT assertions signed by one of their keys.
+type ServiceAccount struct {
+	// ID of the service account, also the subject of its tokens.
+	Id     string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name   string   `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Groups []string `protobuf:"bytes,3,rep,name=groups,proto3" json:"groups,omitempty"`
+	// IDs of the clients tokens may be issued for.
+	Clients []string `protobuf:"bytes,4,rep,name=clients,proto3" json:"clients,omitempty"`
+	// JSON web keys verifying the assertions of the service account.
+	PublicKeys []string `protobuf:"bytes,5,rep,name=public_keys,json=publicKeys,proto3" json:"public_keys,omitempty"`
+	// Unix time the service account was created at.
+	CreatedAt            int64    `protobuf:"varint,6,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ServiceAccount) Reset()         { *m = ServiceAccount{} }
+func (m *ServiceAccount) String() string { return proto.CompactTextString(m) }
+func (*ServiceAccount) ProtoMessage()    {}
+func (*ServiceAccount) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14cbb315f08d2e3f, []int{44}
+}
+
+func (m *ServiceAccount) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ServiceAccount.Unmarshal(m, b)
+}
+func (m *ServiceAccount) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ServiceAccount.Marshal(b, m, deterministic)
+}
+func (m *ServiceAccount) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ServiceAccount.Merge(m, src)
+}
+func (m *ServiceAccount) XXX_Size() int {
+	return xxx_messageInfo_ServiceAccount.Size(m)
+}
+func (m *ServiceAccount) XXX_DiscardUnknown() {
+	xxx_messageInfo_ServiceAccount.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ServiceAccount proto.InternalMessageInfo
+
+func (m *ServiceAccount) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *ServiceAccount) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *ServiceAccount) GetGroups() []string {
+	if m != nil {
+		return m.Groups
+	}
+	return nil
+}
+
+func (m *ServiceAccount) GetClients() []string {
+	if m != nil {
+		return m.Clients
+	}
+	return nil
+}
+
+func (m *ServiceAccount) GetPublicKeys() []string {
+	if m != nil {
+		return m.PublicKeys
+	}
+	return nil
+}
+
+func (m *ServiceAccount) GetCreatedAt() int64 {
+	if m != nil {
+		return m.CreatedAt
+	}
+	return 0
+}
+
+// CreateServiceAccountReq is a request to create a service account.
+type CreateServiceAccountReq struct {
+	ServiceAccount       *ServiceAccount `protobuf:"bytes,1,opt,name=service_account,json=serviceAccount,proto3" json:"service_account,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}        `json:"-"`
+	XXX_unrecognized     []byte          `json:"-"`
+	XXX_sizecache        int32           `json:"-"`
+}
+
+func (m *CreateServiceAccountReq) Reset()         { *m = CreateServiceAccountReq{} }
+func (m *CreateServiceAccountReq) String() string { return proto.CompactTextString(m) }
+func (*CreateServiceAccountReq) ProtoMessage()    {}
+func (*CreateServiceAccountReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14cbb315f08d2e3f, []int{45}
+}
+
+func (m *CreateServiceAccountReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CreateServiceAccountReq.Unmarshal(m, b)
+}
+func (m *CreateServiceAccountReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CreateServiceAccountReq.Marshal(b, m, deterministic)
+}
+func (m *CreateServiceAccountReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CreateServiceAccountReq.Merge(m, src)
+}
+func (m *CreateServiceAccountReq) XXX_Size() int {
+	return xxx_messageInfo_CreateServiceAccountReq.Size(m)
+}
+func (m *CreateServiceAccountReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_CreateServiceAccountReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CreateServiceAccountReq proto.InternalMessageInfo
+
+func (m *CreateServiceAccountReq) GetServiceAccount() *ServiceAccount {
+	if m != nil {
+		return m.ServiceAccount
+	}
+	return nil
+}
+
+// CreateServiceAccountResp returns the result of creating a service account.
+type CreateServiceAccountResp struct {
+	AlreadyExists        bool     `protobuf:"varint,1,opt,name=already_exists,json=alreadyExists,proto3" json:"already_exists,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CreateServiceAccountResp) Reset()         { *m = CreateServiceAccountResp{} }
+func (m *CreateServiceAccountResp) String() string { return proto.CompactTextString(m) }
+func (*CreateServiceAccountResp) ProtoMessage()    {}
+func (*CreateServiceAccountResp) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14cbb315f08d2e3f, []int{46}
+}
+
+func (m *CreateServiceAccountResp) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CreateServiceAccountResp.Unmarshal(m, b)
+}
+func (m *CreateServiceAccountResp) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CreateServiceAccountResp.Marshal(b, m, deterministic)
+}
+func (m *CreateServiceAccountResp) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CreateServiceAccountResp.Merge(m, src)
+}
+func (m *CreateServiceAccountResp) XXX_Size() int {
+	return xxx_messageInfo_CreateServiceAccountResp.Size(m)
+}
+func (m *CreateServiceAccountResp) XXX_DiscardUnknown() {
+	xxx_messageInfo_CreateServiceAccountResp.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CreateServiceAccountResp proto.InternalMessageInfo
+
+func (m *CreateServiceAccountResp) GetAlreadyExists() bool {
+	if m != nil {
+		return m.AlreadyExists
+	}
+	return false
+}
+
+// UpdateServiceAccountReq is a request to update a service account. Fields
+// left empty are kept.
+type UpdateServiceAccountReq struct {
+	Id      string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name    string   `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Groups  []string `protobuf:"bytes,3,rep,name=groups,proto3" json:"groups,omitempty"`
+	Clients []string `protobuf:"bytes,4,rep,name=clients,proto3" json:"clients,omitempty"`
+	// Replaces all keys of the service account.
+	PublicKeys           []string `protobuf:"bytes,5,rep,name=public_keys,json=publicKeys,proto3" json:"public_keys,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *UpdateServiceAccountReq) Reset()         { *m = UpdateServiceAccountReq{} }
+func (m *UpdateServiceAccountReq) String() string { return proto.CompactTextString(m) }
+func (*UpdateServiceAccountReq) ProtoMessage()    {}
+func (*UpdateServiceAccountReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14cbb315f08d2e3f, []int{47}
+}
+
+func (m *UpdateServiceAccountReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_UpdateServiceAccountReq.Unmarshal(m, b)
+}
+func (m *UpdateServiceAccountReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_UpdateServiceAccountReq.Marshal(b, m, deterministic)
+}
+func (m *UpdateServiceAccountReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_UpdateServiceAccountReq.Merge(m, src)
+}
+func (m *UpdateServiceAccountReq) XXX_Size() int {
+	return xxx_messageInfo_UpdateServiceAccountReq.Size(m)
+}
+func (m *UpdateServiceAccountReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_UpdateServiceAccountReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_UpdateServiceAccountReq proto.InternalMessageInfo
+
+func (m *UpdateServiceAccountReq) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *UpdateServiceAccountReq) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *UpdateServiceAccountReq) GetGroups() []string {
+	if m != nil {
+		return m.Groups
+	}
+	return nil
+}
+
+func (m *UpdateServiceAccountReq) GetClients() []string {
+	if m != nil {
+		return m.Clients
+	}
+	return nil
+}
+
+func (m *UpdateServiceAccountReq) GetPublicKeys() []string {
+	if m != nil {
+		return m.PublicKeys
+	}
+	return nil
+}
+
+// UpdateServiceAccountResp returns the result of updating a service account.
+type UpdateServiceAccountResp struct {
+	NotFound             bool     `protobuf:"varint,1,opt,name=not_found,json=notFound,proto3" json:"not_found,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *UpdateServiceAccountResp) Reset()         { *m = UpdateServiceAccountResp{} }
+func (m *UpdateServiceAccountResp) String() string { return proto.CompactTextString(m) }
+func (*UpdateServiceAccountResp) ProtoMessage()    {}
+func (*UpdateServiceAccountResp) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14cbb315f08d2e3f, []int{48}
+}
+
+func (m *UpdateServiceAccountResp) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_UpdateServiceAccountResp.Unmarshal(m, b)
+}
+func (m *UpdateServiceAccountResp) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_UpdateServiceAccountResp.Marshal(b, m, deterministic)
+}
+func (m *UpdateServiceAccountResp) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_UpdateServiceAccountResp.Merge(m, src)
+}
+func (m *UpdateServiceAccountResp) XXX_Size() int {
+	return xxx_messageInfo_UpdateServiceAccountResp.Size(m)
+}
+func (m *UpdateServiceAccountResp) XXX_DiscardUnknown() {
+	xxx_messageInfo_UpdateServiceAccountResp.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_UpdateServiceAccountResp proto.InternalMessageInfo
+
+func (m *UpdateServiceAccountResp) GetNotFound() bool {
+	if m != nil {
+		return m.NotFound
+	}
+	return false
+}
+
+// ListServiceAccountsReq is a request to list all service accounts.
+type ListServiceAccountsReq struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListServiceAccountsReq) Reset()         { *m = ListServiceAccountsReq{} }
+func (m *ListServiceAccountsReq) String() string { return proto.CompactTextString(m) }
+func (*ListServiceAccountsReq) ProtoMessage()    {}
+func (*ListServiceAccountsReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14cbb315f08d2e3f, []int{49}
+}
+
+func (m *ListServiceAccountsReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListServiceAccountsReq.Unmarshal(m, b)
+}
+func (m *ListServiceAccountsReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListServiceAccountsReq.Marshal(b, m, deterministic)
+}
+func (m *ListServiceAccountsReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListServiceAccountsReq.Merge(m, src)
+}
+func (m *ListServiceAccountsReq) XXX_Size() int {
+	return xxx_messageInfo_ListServiceAccountsReq.Size(m)
+}
+func (m *ListServiceAccountsReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListServiceAccountsReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListServiceAccountsReq proto.InternalMessageInfo
+
+// ListServiceAccountsResp returns the service accounts.
+type ListServiceAccountsResp struct {
+	ServiceAccounts      []*ServiceAccount `protobuf:"bytes,1,rep,name=service_accounts,json=serviceAccounts,proto3" json:"service_accounts,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *ListServiceAccountsResp) Reset()         { *m = ListServiceAccountsResp{} }
+func (m *ListServiceAccountsResp) String() string { return proto.CompactTextString(m) }
+func (*ListServiceAccountsResp) ProtoMessage()    {}
+func (*ListServiceAccountsResp) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14cbb315f08d2e3f, []int{50}
+}
+
+func (m *ListServiceAccountsResp) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListServiceAccountsResp.Unmarshal(m, b)
+}
+func (m *ListServiceAccountsResp) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListServiceAccountsResp.Marshal(b, m, deterministic)
+}
+func (m *ListServiceAccountsResp) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListServiceAccountsResp.Merge(m, src)
+}
+func (m *ListServiceAccountsResp) XXX_Size() int {
+	return xxx_messageInfo_ListServiceAccountsResp.Size(m)
+}
+func (m *ListServiceAccountsResp) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListServiceAccountsResp.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListServiceAccountsResp proto.InternalMessageInfo
+
+func (m *ListServiceAccountsResp) GetServiceAccounts() []*ServiceAccount {
+	if m != nil {
+		return m.ServiceAccounts
+	}
+	return nil
+}
+
+// DeleteServiceAccountReq is a request to delete a service account.
+type DeleteServiceAccountReq struct {
+	Id                   string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeleteServiceAccountReq) Reset()         { *m = DeleteServiceAccountReq{} }
+func (m *DeleteServiceAccountReq) String() string { return proto.CompactTextString(m) }
+func (*DeleteServiceAccountReq) ProtoMessage()    {}
+func (*DeleteServiceAccountReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14cbb315f08d2e3f, []int{51}
+}
+
+func (m *DeleteServiceAccountReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeleteServiceAccountReq.Unmarshal(m, b)
+}
+func (m *DeleteServiceAccountReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeleteServiceAccountReq.Marshal(b, m, deterministic)
+}
+func (m *DeleteServiceAccountReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteServiceAccountReq.Merge(m, src)
+}
+func (m *DeleteServiceAccountReq) XXX_Size() int {
+	return xxx_messageInfo_DeleteServiceAccountReq.Size(m)
+}
+func (m *DeleteServiceAccountReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteServiceAccountReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteServiceAccountReq proto.InternalMessageInfo
+
+func (m *DeleteServiceAccountReq) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+// DeleteServiceAccountResp returns the result of deleting a service account.
+type DeleteServiceAccountResp struct {
+	NotFound             bool     `protobuf:"varint,1,opt,name=not_found,json=notFound,proto3" json:"not_found,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeleteServiceAccountResp) Reset()         { *m = DeleteServiceAccountResp{} }
+func (m *DeleteServiceAccountResp) String() string { return proto.CompactTextString(m) }
+func (*DeleteServiceAccountResp) ProtoMessage()    {}
+func (*DeleteServiceAccountResp) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14cbb315f08d2e3f, []int{52}
+}
+
+func (m *DeleteServiceAccountResp) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeleteServiceAccountResp.Unmarshal(m, b)
+}
+func (m *DeleteServiceAccountResp) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeleteServiceAccountResp.Marshal(b, m, deterministic)
+}
+func (m *DeleteServiceAccountResp) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteServiceAccountResp.Merge(m, src)
+}
+func (m *DeleteServiceAccountResp) XXX_Size() int {
+	return xxx_messageInfo_DeleteServiceAccountResp.Size(m)
+}
+func (m *DeleteServiceAccountResp) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteServiceAccountResp.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteServiceAccountResp proto.InternalMessageInfo
+
+func (m *DeleteServiceAccountResp) GetNotFound() bool {
+	if m != nil {
+		return m.NotFound
+	}
+	return false
+}
+
+// CreatePreAuthorizedCodeReq is a request to create a pre-authorized code.
+type CreatePreAuthorizedCodeReq struct {
+	ClientId string   `protobuf:"bytes,1,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	Scopes   []string `protobuf:"bytes,2,rep,name=scopes,proto3" json:"scopes,omitempty"`
+	// Claims of the identity tokens are issued for.
+	UserId   string   `protobuf:"bytes,3,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Username string   `protobuf:"bytes,4,opt,name=username,proto3" json:"username,omitempty"`
+	Email    string   `protobuf:"bytes,5,opt,name=email,proto3" json:"email,omitempty"`
+	Groups   []string `protobuf:"bytes,6,rep,name=groups,proto3" json:"groups,omitempty"`
+	// Connector ID of issued tokens. Defaults to "pre_authorized_code".
+	ConnectorId string `protobuf:"bytes,7,opt,name=connector_id,json=connectorId,proto3" json:"connector_id,omitempty"`
+	// PIN the code must be exchanged with. Empty creates a code without a PIN.
+	Pin string `protobuf:"bytes,8,opt,name=pin,proto3" json:"pin,omitempty"`
+	// Seconds the code is valid for. 0 defaults to 5 minutes.
+	ExpiresIn            int64    `protobuf:"varint,9,opt,name=expires_in,json=expiresIn,proto3" json:"expires_in,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CreatePreAuthorizedCodeReq) Reset()         { *m = CreatePreAuthorizedCodeReq{} }
+func (m *CreatePreAuthorizedCodeReq) String() string { return proto.CompactTextString(m) }
+func (*CreatePreAuthorizedCodeReq) ProtoMessage()    {}
+func (*CreatePreAuthorizedCodeReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14cbb315f08d2e3f, []int{53}
+}
+
+func (m *CreatePreAuthorizedCodeReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CreatePreAuthorizedCodeReq.Unmarshal(m, b)
+}
+func (m *CreatePreAuthorizedCodeReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CreatePreAuthorizedCodeReq.Marshal(b, m, deterministic)
+}
+func (m *CreatePreAuthorizedCodeReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CreatePreAuthorizedCodeReq.Merge(m, src)
+}
+func (m *CreatePreAuthorizedCodeReq) XXX_Size() int {
+	return xxx_messageInfo_CreatePreAuthorizedCodeReq.Size(m)
+}
+func (m *CreatePreAuthorizedCodeReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_CreatePreAuthorizedCodeReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CreatePreAuthorizedCodeReq proto.InternalMessageInfo
+
+func (m *CreatePreAuthorizedCodeReq) GetClientId() string {
+	if m != nil {
+		return m.ClientId
+	}
+	return ""
+}
+
+func (m *CreatePreAuthorizedCodeReq) GetScopes() []string {
+	if m != nil {
+		return m.Scopes
+	}
+	return nil
+}
+
+func (m *CreatePreAuthorizedCodeReq) GetUserId() string {
+	if m != nil {
+		return m.UserId
+	}
+	return ""
+}
+
+func (m *CreatePreAuthorizedCodeReq) GetUsername() string {
+	if m != nil {
+		return m.Username
+	}
+	return ""
+}
+
+func (m *CreatePreAuthorizedCodeReq) GetEmail() string {
+	if m != nil {
+		return m.Email
+	}
+	return ""
+}
+
+func (m *CreatePreAuthorizedCodeReq) GetGroups() []string {
+	if m != nil {
+		return m.Groups
+	}
+	return nil
+}
+
+func (m *CreatePreAuthorizedCodeReq) GetConnectorId() string {
+	if m != nil {
+		return m.ConnectorId
+	}
+	return ""
+}
+
+func (m *CreatePreAuthorizedCodeReq) GetPin() string {
+	if m != nil {
+		return m.Pin
+	}
+	return ""
+}
+
+func (m *CreatePreAuthorizedCodeReq) GetExpiresIn() int64 {
+	if m != nil {
+		return m.ExpiresIn
+	}
+	return 0
+}
+
+// CreatePreAuthorizedCodeResp returns the created pre-authorized code.
+type CreatePreAuthorizedCodeResp struct {
+	// The code to pass to the token endpoint.
+	Code string `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	// Unix time the code expires at.
+	Expiry               int64    `protobuf:"varint,2,opt,name=expiry,proto3" json:"expiry,omitempty"`
+	ClientNotFound       bool     `protobuf:"varint,3,opt,name=client_not_found,json=clientNotFound,proto3" json:"client_not_found,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CreatePreAuthorizedCodeResp) Reset()         { *m = CreatePreAuthorizedCodeResp{} }
+func (m *CreatePreAuthorizedCodeResp) String() string { return proto.CompactTextString(m) }
+func (*CreatePreAuthorizedCodeResp) ProtoMessage()    {}
+func (*CreatePreAuthorizedCodeResp) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14cbb315f08d2e3f, []int{54}
+}
+
+func (m *CreatePreAuthorizedCodeResp) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CreatePreAuthorizedCodeResp.Unmarshal(m, b)
+}
+func (m *CreatePreAuthorizedCodeResp) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CreatePreAuthorizedCodeResp.Marshal(b, m, deterministic)
+}
+func (m *CreatePreAuthorizedCodeResp) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CreatePreAuthorizedCodeResp.Merge(m, src)
+}
+func (m *CreatePreAuthorizedCodeResp) XXX_Size() int {
+	return xxx_messageInfo_CreatePreAuthorizedCodeResp.Size(m)
+}
+func (m *CreatePreAuthorizedCodeResp) XXX_DiscardUnknown() {
+	xxx_messageInfo_CreatePreAuthorizedCodeResp.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CreatePreAuthorizedCodeResp proto.InternalMessageInfo
+
+func (m *CreatePreAuthorizedCodeResp) GetCode() string {
+	if m != nil {
+		return m.Code
+	}
+	return ""
+}
+
+func (m *CreatePreAuthorizedCodeResp) GetExpiry() int64 {
+	if m != nil {
+		return m.Expiry
+	}
+	return 0
+}
+
+func (m *CreatePreAuthorizedCodeResp) GetClientNotFound() bool {
+	if m != nil {
+		return m.ClientNotFound
+	}
+	return false
+}
+
+// WebAuthnCredential is a passkey registered by a local user.
+type WebAuthnCredential struct {
+	// Base64url encoded credential ID.
+	Id     string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	UserId string `protobuf:"bytes,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	// Unix times the credential was registered and last used at.
+	CreatedAt            int64    `protobuf:"varint,3,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	LastUsed             int64    `protobuf:"varint,4,opt,name=last_used,json=lastUsed,proto3" json:"last_used,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *WebAuthnCredential) Reset()         { *m = WebAuthnCredential{} }
+func (m *WebAuthnCredential) String() string { return proto.CompactTextString(m) }
+func (*WebAuthnCredential) ProtoMessage()    {}
+func (*WebAuthnCredential) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14cbb315f08d2e3f, []int{55}
+}
+
+func (m *WebAuthnCredential) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WebAuthnCredential.Unmarshal(m, b)
+}
+func (m *WebAuthnCredential) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WebAuthnCredential.Marshal(b, m, deterministic)
+}
+func (m *WebAuthnCredential) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WebAuthnCredential.Merge(m, src)
+}
+func (m *WebAuthnCredential) XXX_Size() int {
+	return xxx_messageInfo_WebAuthnCredential.Size(m)
+}
+func (m *WebAuthnCredential) XXX_DiscardUnknown() {
+	xxx_messageInfo_WebAuthnCredential.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WebAuthnCredential proto.InternalMessageInfo
+
+func (m *WebAuthnCredential) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *WebAuthnCredential) GetUserId() string {
+	if m != nil {
+		return m.UserId
+	}
+	return ""
+}
+
+func (m *WebAuthnCredential) GetCreatedAt() int64 {
+	if m != nil {
+		return m.CreatedAt
+	}
+	return 0
+}
+
+func (m *WebAuthnCredential) GetLastUsed() int64 {
+	if m != nil {
+		return m.LastUsed
+	}
+	return 0
+}
+
+// ListWebAuthnCredentialsReq is a request to list the passkeys of a local user.
+type ListWebAuthnCredentialsReq struct {
+	Email                string   `protobuf:"bytes,1,opt,name=email,proto3" json:"email,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListWebAuthnCredentialsReq) Reset()         { *m = ListWebAuthnCredentialsReq{} }
+func (m *ListWebAuthnCredentialsReq) String() string { return proto.CompactTextString(m) }
+func (*ListWebAuthnCredentialsReq) ProtoMessage()    {}
+func (*ListWebAuthnCredentialsReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14cbb315f08d2e3f, []int{56}
+}
+
+func (m *ListWebAuthnCredentialsReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListWebAuthnCredentialsReq.Unmarshal(m, b)
+}
+func (m *ListWebAuthnCredentialsReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListWebAuthnCredentialsReq.Marshal(b, m, deterministic)
+}
+func (m *ListWebAuthnCredentialsReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListWebAuthnCredentialsReq.Merge(m, src)
+}
+func (m *ListWebAuthnCredentialsReq) XXX_Size() int {
+	return xxx_messageInfo_ListWebAuthnCredentialsReq.Size(m)
+}
+func (m *ListWebAuthnCredentialsReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListWebAuthnCredentialsReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListWebAuthnCredentialsReq proto.InternalMessageInfo
+
+func (m *ListWebAuthnCredentialsReq) GetEmail() string {
+	if m != nil {
+		return m.Email
+	}
+	return ""
+}
+
+// ListWebAuthnCredentialsResp returns the passkeys of a local user.
+type ListWebAuthnCredentialsResp struct {
+	Credentials          []*WebAuthnCredential `protobuf:"bytes,1,rep,name=credentials,proto3" json:"credentials,omitempty"`
+	NotFound             bool                  `protobuf:"varint,2,opt,name=not_found,json=notFound,proto3" json:"not_found,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}              `json:"-"`
+	XXX_unrecognized     []byte                `json:"-"`
+	XXX_sizecache        int32                 `json:"-"`
+}
+
+func (m *ListWebAuthnCredentialsResp) Reset()         { *m = ListWebAuthnCredentialsResp{} }
+func (m *ListWebAuthnCredentialsResp) String() string { return proto.CompactTextString(m) }
+func (*ListWebAuthnCredentialsResp) ProtoMessage()    {}
+func (*ListWebAuthnCredentialsResp) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14cbb315f08d2e3f, []int{57}
+}
+
+func (m *ListWebAuthnCredentialsResp) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListWebAuthnCredentialsResp.Unmarshal(m, b)
+}
+func (m *ListWebAuthnCredentialsResp) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListWebAuthnCredentialsResp.Marshal(b, m, deterministic)
+}
+func (m *ListWebAuthnCredentialsResp) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListWebAuthnCredentialsResp.Merge(m, src)
+}
+func (m *ListWebAuthnCredentialsResp) XXX_Size() int {
+	return xxx_messageInfo_ListWebAuthnCredentialsResp.Size(m)
+}
+func (m *ListWebAuthnCredentialsResp) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListWebAuthnCredentialsResp.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListWebAuthnCredentialsResp proto.InternalMessageInfo
+
+func (m *ListWebAuthnCredentialsResp) GetCredentials() []*WebAuthnCredential {
+	if m != nil {
+		return m.Credentials
+	}
+	return nil
+}
+
+func (m *ListWebAuthnCredentialsResp) GetNotFound() bool {
+	if m != nil {
+		return m.NotFound
+	}
+	return false
+}
+
+// DeleteWebAuthnCredentialsReq is a request to delete passkeys of a local user.
+type DeleteWebAuthnCredentialsReq struct {
+	Email string `protobuf:"bytes,1,opt,name=email,proto3" json:"email,omitempty"`
+	// ID of the passkey to delete. Empty deletes all passkeys of the user.
+	Id                   string   `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeleteWebAuthnCredentialsReq) Reset()         { *m = DeleteWebAuthnCredentialsReq{} }
+func (m *DeleteWebAuthnCredentialsReq) String() string { return proto.CompactTextString(m) }
+func (*DeleteWebAuthnCredentialsReq) ProtoMessage()    {}
+func (*DeleteWebAuthnCredentialsReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14cbb315f08d2e3f, []int{58}
+}
+
+func (m *DeleteWebAuthnCredentialsReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeleteWebAuthnCredentialsReq.Unmarshal(m, b)
+}
+func (m *DeleteWebAuthnCredentialsReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeleteWebAuthnCredentialsReq.Marshal(b, m, deterministic)
+}
+func (m *DeleteWebAuthnCredentialsReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteWebAuthnCredentialsReq.Merge(m, src)
+}
+func (m *DeleteWebAuthnCredentialsReq) XXX_Size() int {
+	return xxx_messageInfo_DeleteWebAuthnCredentialsReq.Size(m)
+}
+func (m *DeleteWebAuthnCredentialsReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteWebAuthnCredentialsReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteWebAuthnCredentialsReq proto.InternalMessageInfo
+
+func (m *DeleteWebAuthnCredentialsReq) GetEmail() string {
+	if m != nil {
+		return m.Email
+	}
+	return ""
+}
+
+func (m *DeleteWebAuthnCredentialsReq) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+// DeleteWebAuthnCredentialsResp returns the result of deleting passkeys.
+type DeleteWebAuthnCredentialsResp struct {
+	NotFound             bool     `protobuf:"varint,1,opt,name=not_found,json=notFound,proto3" json:"not_found,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeleteWebAuthnCredentialsResp) Reset()         { *m = DeleteWebAuthnCredentialsResp{} }
+func (m *DeleteWebAuthnCredentialsResp) String() string { return proto.CompactTextString(m) }
+func (*DeleteWebAuthnCredentialsResp) ProtoMessage()    {}
+func (*DeleteWebAuthnCredentialsResp) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14cbb315f08d2e3f, []int{59}
+}
+
+func (m *DeleteWebAuthnCredentialsResp) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeleteWebAuthnCredentialsResp.Unmarshal(m, b)
+}
+func (m *DeleteWebAuthnCredentialsResp) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeleteWebAuthnCredentialsResp.Marshal(b, m, deterministic)
+}
+func (m *DeleteWebAuthnCredentialsResp) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteWebAuthnCredentialsResp.Merge(m, src)
+}
+func (m *DeleteWebAuthnCredentialsResp) XXX_Size() int {
+	return xxx_messageInfo_DeleteWebAuthnCredentialsResp.Size(m)
+}
+func (m *DeleteWebAuthnCredentialsResp) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteWebAuthnCredentialsResp.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteWebAuthnCredentialsResp proto.InternalMessageInfo
+
+func (m *DeleteWebAuthnCredentialsResp) GetNotFound() bool {
+	if m != nil {
+		return m.NotFound
+	}
+	return false
+}
+
+// ResetTOTPReq is a request to reset the TOTP enrollment of a local user.
+type ResetTOTPReq struct {
+	Email                string   `protobuf:"bytes,1,opt,name=email,proto3" json:"email,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ResetTOTPReq) Reset()         { *m = ResetTOTPReq{} }
+func (m *ResetTOTPReq) String() string { return proto.CompactTextString(m) }
+func (*ResetTOTPReq) ProtoMessage()    {}
+func (*ResetTOTPReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14cbb315f08d2e3f, []int{60}
+}
+
+func (m *ResetTOTPReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ResetTOTPReq.Unmarshal(m, b)
+}
+func (m *ResetTOTPReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ResetTOTPReq.Marshal(b, m, deterministic)
+}
+func (m *ResetTOTPReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ResetTOTPReq.Merge(m, src)
+}
+func (m *ResetTOTPReq) XXX_Size() int {
+	return xxx_messageInfo_ResetTOTPReq.Size(m)
+}
+func (m *ResetTOTPReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_ResetTOTPReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ResetTOTPReq proto.InternalMessageInfo
+
+func (m *ResetTOTPReq) GetEmail() string {
+	if m != nil {
+		return m.Email
+	}
+	return ""
+}
+
+// ResetTOTPResp returns the result of resetting a TOTP enrollment.
+type ResetTOTPResp struct {
+	NotFound             bool     `protobuf:"varint,1,opt,name=not_found,json=notFound,proto3" json:"not_found,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ResetTOTPResp) Reset()         { *m = ResetTOTPResp{} }
+func (m *ResetTOTPResp) String() string { return proto.CompactTextString(m) }
+func (*ResetTOTPResp) ProtoMessage()    {}
+func (*ResetTOTPResp) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14cbb315f08d2e3f, []int{61}
+}
+
+func (m *ResetTOTPResp) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ResetTOTPResp.Unmarshal(m, b)
+}
+func (m *ResetTOTPResp) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ResetTOTPResp.Marshal(b, m, deterministic)
+}
+func (m *ResetTOTPResp) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ResetTOTPResp.Merge(m, src)
+}
+func (m *ResetTOTPResp) XXX_Size() int {
+	return xxx_messageInfo_ResetTOTPResp.Size(m)
+}
+func (m *ResetTOTPResp) XXX_DiscardUnknown() {
+	xxx_messageInfo_ResetTOTPResp.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ResetTOTPResp proto.InternalMessageInfo
+
+func (m *ResetTOTPResp) GetNotFound() bool {
+	if m != nil {
+		return m.NotFound
+	}
+	return false
+}
+
+func init() {
+	proto.RegisterType((*Client)(nil), "api.Client")
+	proto.RegisterType((*CreateClientReq)(nil), "api.CreateClientReq")
+	proto.RegisterType((*CreateClientResp)(nil), "api.CreateClientResp")
+	proto.RegisterType((*DeleteClientReq)(nil), "api.DeleteClientReq")
+	proto.RegisterType((*DeleteClientResp)(nil), "api.DeleteClientResp")
+	proto.RegisterType((*UpdateClientReq)(nil), "api.UpdateClientReq")
+	proto.RegisterType((*UpdateClientResp)(nil), "api.UpdateClientResp")
+	proto.RegisterType((*Password)(nil), "api.Password")
+	proto.RegisterType((*CreatePasswordReq)(nil), "api.CreatePasswordReq")
+	proto.RegisterType((*CreatePasswordResp)(nil), "api.CreatePasswordResp")
+	proto.RegisterType((*UpdatePasswordReq)(nil), "api.UpdatePasswordReq")
+	proto.RegisterType((*UpdatePasswordResp)(nil), "api.UpdatePasswordResp")
+	proto.RegisterType((*DeletePasswordReq)(nil), "api.DeletePasswordReq")
+	proto.RegisterType((*DeletePasswordResp)(nil), "api.DeletePasswordResp")
+	proto.RegisterType((*ListPasswordReq)(nil), "api.ListPasswordReq")
+	proto.RegisterType((*ListPasswordResp)(nil), "api.ListPasswordResp")
+	proto.RegisterType((*VersionReq)(nil), "api.VersionReq")
+	proto.RegisterType((*VersionResp)(nil), "api.VersionResp")
+	proto.RegisterType((*RefreshTokenRef)(nil), "api.RefreshTokenRef")
+	proto.RegisterType((*ListRefreshReq)(nil), "api.ListRefreshReq")
+	proto.RegisterType((*ListRefreshResp)(nil), "api.ListRefreshResp")
+	proto.RegisterType((*RevokeRefreshReq)(nil), "api.RevokeRefreshReq")
+	proto.RegisterType((*RevokeRefreshResp)(nil), "api.RevokeRefreshResp")
+	proto.RegisterType((*VerifyPasswordReq)(nil), "api.VerifyPasswordReq")
+	proto.RegisterType((*VerifyPasswordResp)(nil), "api.VerifyPasswordResp")
+	proto.RegisterType((*Feature)(nil), "api.Feature")
+	proto.RegisterType((*ListFeaturesReq)(nil), "api.ListFeaturesReq")
+	proto.RegisterType((*ListFeaturesResp)(nil), "api.ListFeaturesResp")
+	proto.RegisterType((*AuditEvent)(nil), "api.AuditEvent")
+	proto.RegisterType((*ListAuditEventsReq)(nil), "api.ListAuditEventsReq")
+	proto.RegisterType((*ListAuditEventsResp)(nil), "api.ListAuditEventsResp")
+	proto.RegisterType((*ExportUserDataReq)(nil), "api.ExportUserDataReq")
+	proto.RegisterType((*ExportUserDataResp)(nil), "api.ExportUserDataResp")
+	proto.RegisterType((*EraseUserDataReq)(nil), "api.EraseUserDataReq")
+	proto.RegisterType((*EraseUserDataResp)(nil), "api.EraseUserDataResp")
+	proto.RegisterType((*ValidateTemplatesReq)(nil), "api.ValidateTemplatesReq")
+	proto.RegisterType((*ValidateTemplatesResp)(nil), "api.ValidateTemplatesResp")
+	proto.RegisterType((*APIKey)(nil), "api.APIKey")
+	proto.RegisterType((*CreateAPIKeyReq)(nil), "api.CreateAPIKeyReq")
+	proto.RegisterType((*CreateAPIKeyResp)(nil), "api.CreateAPIKeyResp")
+	proto.RegisterType((*ListAPIKeysReq)(nil), "api.ListAPIKeysReq")
+	proto.RegisterType((*ListAPIKeysResp)(nil), "api.ListAPIKeysResp")
+	proto.RegisterType((*RevokeAPIKeyReq)(nil), "api.RevokeAPIKeyReq")
+	proto.RegisterType((*RevokeAPIKeyResp)(nil), "api.RevokeAPIKeyResp")
+	proto.RegisterType((*ServiceAccount)(nil), "api.ServiceAccount")
+	proto.RegisterType((*CreateServiceAccountReq)(nil), "api.CreateServiceAccountReq")
+	proto.RegisterType((*CreateServiceAccountResp)(nil), "api.CreateServiceAccountResp")
+	proto.RegisterType((*UpdateServiceAccountReq)(nil), "api.UpdateServiceAccountReq")
+	proto.RegisterType((*UpdateServiceAccountResp)(nil), "api.UpdateServiceAccountResp")
+	proto.RegisterType((*ListServiceAccountsReq)(nil), "api.ListServiceAccountsReq")
+	proto.RegisterType((*ListServiceAccountsResp)(nil), "api.ListServiceAccountsResp")
+	proto.RegisterType((*DeleteServiceAccountReq)(nil), "api.DeleteServiceAccountReq")
+	proto.RegisterType((*DeleteServiceAccountResp)(nil), "api.DeleteServiceAccountResp")
+	proto.RegisterType((*CreatePreAuthorizedCodeReq)(nil), "api.CreatePreAuthorizedCodeReq")
+	proto.RegisterType((*CreatePreAuthorizedCodeResp)(nil), "api.CreatePreAuthorizedCodeResp")
+	proto.RegisterType((*WebAuthnCredential)(nil), "api.WebAuthnCredential")
+	proto.RegisterType((*ListWebAuthnCredentialsReq)(nil), "api.ListWebAuthnCredentialsReq")
+	proto.RegisterType((*ListWebAuthnCredentialsResp)(nil), "api.ListWebAuthnCredentialsResp")
+	proto.RegisterType((*DeleteWebAuthnCredentialsReq)(nil), "api.DeleteWebAuthnCredentialsReq")
+	proto.RegisterType((*DeleteWebAuthnCredentialsResp)(nil), "api.DeleteWebAuthnCredentialsResp")
+	proto.RegisterType((*ResetTOTPReq)(nil), "api.ResetTOTPReq")
+	proto.RegisterType((*ResetTOTPResp)(nil), "api.ResetTOTPResp")
+}
+
+func init() { proto.RegisterFile("api/v2/api.proto", fileDescriptor_14cbb315f08d2e3f) }
+
+var fileDescriptor_14cbb315f08d2e3f = []byte{
+	// 2277 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xc4, 0x59, 0x5f, 0x73, 0xdb, 0xc6,
+	0x11, 0x0f, 0x09, 0xf1, 0xdf, 0x92, 0x12, 0xc9, 0x33, 0x25, 0xc2, 0xb0, 0x3d, 0x91, 0x91, 0xb4,
+	0x95, 0xa7, 0x89, 0xdd, 0xb8, 0x99, 0x66, 0xd2, 0xb8, 0x6e, 0x55, 0x59, 0x6e, 0x34, 0x75, 0x13,
+	0x0f, 0x6a, 0x39, 0xd3, 0x97, 0x70, 0x20, 0xe0, 0x6c, 0x5d, 0x0c, 0x01, 0xe8, 0x1d, 0x28, 0x89,
+	0xf9, 0x04, 0x7d, 0xe9, 0x4c, 0xa7, 0x1f, 0xa0, 0x33, 0x7d, 0xe9, 0x5b, 0xbf, 0x4d, 0x3f, 0x4c,
+	0x1f, 0x3b, 0xf7, 0x0f, 0x3c, 0x80, 0x00, 0xa9, 0x3c, 0xf5, 0x0d, 0xfb, 0xbb, 0xbd, 0xbd, 0xbb,
+	0xdf, 0xee, 0xed, 0xed, 0x1d, 0x60, 0xe4, 0xa7, 0xe4, 0xd1, 0xe5, 0xe3, 0x47, 0x7e, 0x4a, 0x1e,
+	0xa6, 0x34, 0xc9, 0x12, 0x64, 0xf9, 0x29, 0x71, 0xff, 0x63, 0x41, 0xfb, 0x28, 0x22, 0x38, 0xce,
+	0xd0, 0x0e, 0x34, 0x49, 0x68, 0x37, 0xf6, 0x1b, 0x07, 0x3d, 0xaf, 0x49, 0x42, 0xb4, 0x07, 0x6d,
+	0x86, 0x03, 0x8a, 0x33, 0xbb, 0x29, 0x30, 0x25, 0xa1, 0x0f, 0x60, 0x9b, 0xe2, 0x90, 0x50, 0x1c,
+	0x64, 0xb3, 0x39, 0x25, 0xcc, 0xb6, 0xf6, 0xad, 0x83, 0x9e, 0x37, 0xd0, 0xe0, 0x29, 0x25, 0x8c,
+	0x2b, 0x65, 0x74, 0xce, 0x32, 0x1c, 0xce, 0x52, 0x8c, 0x29, 0xb3, 0xb7, 0xa4, 0x92, 0x02, 0x5f,
+	0x72, 0x8c, 0x8f, 0x90, 0xce, 0xcf, 0x22, 0x12, 0xd8, 0xad, 0xfd, 0xc6, 0x41, 0xd7, 0x53, 0x12,
+	0x42, 0xb0, 0x15, 0xfb, 0x17, 0xd8, 0x6e, 0x8b, 0x71, 0xc5, 0x37, 0xba, 0x0d, 0xdd, 0x28, 0x79,
+	0x9b, 0xcc, 0xe6, 0x34, 0xb2, 0x3b, 0x02, 0xef, 0x70, 0xf9, 0x94, 0x46, 0x7c, 0x2c, 0x3f, 0x8a,
+	0x92, 0x2b, 0x1c, 0xce, 0x02, 0x12, 0x52, 0x66, 0x77, 0xe5, 0x58, 0x0a, 0x3c, 0xe2, 0x18, 0x7a,
+	0x1f, 0xfa, 0x72, 0xfe, 0xb3, 0x73, 0x9f, 0x9d, 0xdb, 0x3d, 0x61, 0x02, 0x24, 0xf4, 0xa5, 0xcf,
+	0xce, 0xd1, 0x14, 0x3a, 0x59, 0xc2, 0xf8, 0x8a, 0x6c, 0x90, 0xeb, 0xcd, 0x12, 0x76, 0x4a, 0x09,
+	0xba, 0x07, 0x90, 0x26, 0x11, 0x09, 0x16, 0xa2, 0xad, 0x2f, 0xda, 0x7a, 0x12, 0xe1, 0xcd, 0x0e,
+	0x74, 0x83, 0x24, 0xce, 0xfc, 0x20, 0x63, 0xf6, 0x40, 0x0c, 0x9c, 0xcb, 0xe8, 0x01, 0xa7, 0x3d,
+	0x8d, 0x48, 0xe0, 0x67, 0x24, 0x89, 0x67, 0xd9, 0x22, 0xc5, 0xf6, 0xb6, 0x30, 0x30, 0x34, 0xf0,
+	0x57, 0x8b, 0x14, 0x73, 0x2e, 0xf0, 0x75, 0x4a, 0xe8, 0xc2, 0xde, 0xd9, 0x6f, 0x1c, 0x58, 0x9e,
+	0x92, 0xd0, 0xa7, 0xb0, 0x77, 0xe6, 0x07, 0xef, 0x82, 0x73, 0x3f, 0x8e, 0x71, 0x34, 0xe3, 0x6b,
+	0x9e, 0x0b, 0xde, 0xed, 0xa1, 0x30, 0x34, 0x31, 0x5a, 0x5f, 0x88, 0xc6, 0x53, 0x4a, 0xdc, 0x5f,
+	0xc0, 0xf0, 0x88, 0x62, 0x3f, 0xc3, 0xd2, 0xb7, 0x1e, 0xfe, 0x33, 0xfa, 0x00, 0xda, 0x81, 0x10,
+	0x84, 0x8b, 0xfb, 0x8f, 0xfb, 0x0f, 0x79, 0x28, 0xa8, 0x76, 0xd5, 0xe4, 0x7e, 0x0b, 0xa3, 0x62,
+	0x3f, 0x96, 0xa2, 0x1f, 0xc1, 0x8e, 0x1f, 0x51, 0xec, 0x87, 0x8b, 0x19, 0xbe, 0x26, 0x2c, 0x63,
+	0xc2, 0x40, 0xd7, 0xdb, 0x56, 0xe8, 0xb1, 0x00, 0x0d, 0xfb, 0xcd, 0x7a, 0xfb, 0xf7, 0x61, 0xf8,
+	0x0c, 0x47, 0xd8, 0x9c, 0x57, 0x29, 0xec, 0xdc, 0x47, 0x30, 0x2a, 0xaa, 0xb0, 0x14, 0xdd, 0x81,
+	0x5e, 0x9c, 0x64, 0xb3, 0x37, 0xc9, 0x3c, 0x0e, 0xd5, 0xe8, 0xdd, 0x38, 0xc9, 0x9e, 0x73, 0xd9,
+	0xfd, 0xb7, 0x05, 0xc3, 0xd3, 0x34, 0xf4, 0xd7, 0x18, 0x5d, 0x8d, 0xd9, 0xe6, 0x4d, 0x62, 0xd6,
+	0xaa, 0x88, 0x59, 0x1d, 0x9b, 0x5b, 0x35, 0xb1, 0xd9, 0xda, 0x10, 0x9b, 0xed, 0xcd, 0xb1, 0xd9,
+	0x59, 0x17, 0x9b, 0xdd, 0x35, 0xb1, 0xd9, 0x5b, 0x17, 0x9b, 0x70, 0x83, 0xd8, 0xec, 0x6f, 0x8a,
+	0xcd, 0xc1, 0x0d, 0x63, 0x73, 0x7b, 0x4d, 0x6c, 0x3e, 0x82, 0x51, 0xd1, 0x5d, 0x9b, 0x1c, 0x4c,
+	0xa0, 0xfb, 0xd2, 0x67, 0xec, 0x2a, 0xa1, 0x21, 0x9a, 0x40, 0x0b, 0x5f, 0xf8, 0x24, 0x52, 0xbe,
+	0x95, 0x02, 0x77, 0x8a, 0x60, 0x8e, 0x47, 0xde, 0xc0, 0x13, 0xdf, 0x7c, 0xed, 0x73, 0x86, 0xa9,
+	0x70, 0x96, 0x25, 0x94, 0x73, 0x99, 0xf3, 0xc9, 0xbf, 0x67, 0x24, 0x54, 0x7e, 0x6c, 0x73, 0xf1,
+	0x24, 0x74, 0x9f, 0xc2, 0x58, 0xc6, 0xbf, 0x1e, 0x90, 0x07, 0xd3, 0x03, 0xe8, 0xa6, 0x4a, 0x54,
+	0x7b, 0x67, 0x5b, 0xc4, 0x76, 0xae, 0x93, 0x37, 0xbb, 0x5f, 0x00, 0x2a, 0xf7, 0xbf, 0xf1, 0x0e,
+	0x72, 0xdf, 0xc2, 0x58, 0x12, 0x63, 0x0e, 0x5e, 0xbd, 0xe0, 0xdb, 0xd0, 0x8d, 0xf1, 0xd5, 0xcc,
+	0x58, 0x74, 0x27, 0xc6, 0x57, 0x22, 0x56, 0xee, 0xc3, 0x80, 0x37, 0x95, 0xd6, 0xde, 0x8f, 0xf1,
+	0xd5, 0xa9, 0x82, 0xdc, 0x4f, 0x00, 0x95, 0x07, 0xda, 0xe4, 0x83, 0x07, 0x30, 0x96, 0xbb, 0x72,
+	0xe3, 0xdc, 0xb8, 0xf5, 0xb2, 0xea, 0x26, 0xeb, 0x63, 0x18, 0xbe, 0x20, 0x2c, 0x33, 0x6c, 0xbb,
+	0xbf, 0x86, 0x51, 0x11, 0x62, 0x29, 0xfa, 0x29, 0xf4, 0x34, 0xd3, 0x9c, 0x42, 0x6b, 0xd5, 0x13,
+	0xcb, 0x76, 0x77, 0x00, 0xf0, 0x1a, 0x53, 0x46, 0x92, 0x98, 0x9b, 0xfb, 0x0c, 0xfa, 0xb9, 0xc4,
+	0x52, 0x79, 0xb6, 0xd1, 0x4b, 0x4c, 0xd5, 0xd4, 0x95, 0x84, 0x46, 0xc0, 0x4f, 0x45, 0x41, 0x69,
+	0xcb, 0xe3, 0x9f, 0xee, 0xf7, 0x30, 0xf4, 0xf0, 0x1b, 0x8a, 0xd9, 0xf9, 0xab, 0xe4, 0x1d, 0x8e,
+	0x3d, 0xfc, 0x66, 0x25, 0xb9, 0xdc, 0x81, 0x9e, 0x4c, 0x6f, 0x3c, 0x9e, 0xe4, 0x59, 0xd9, 0x95,
+	0xc0, 0x49, 0xc8, 0x77, 0x68, 0x20, 0x22, 0x22, 0x9c, 0xf9, 0x99, 0xc8, 0x0e, 0x96, 0xd7, 0x53,
+	0xc8, 0x61, 0xc6, 0xfb, 0x46, 0x3e, 0xcb, 0xb8, 0xbb, 0x42, 0x71, 0xde, 0x59, 0x5e, 0x97, 0x03,
+	0xa7, 0x0c, 0x73, 0xd2, 0x77, 0x38, 0x07, 0x6a, 0x7c, 0xce, 0xb8, 0x11, 0xb8, 0x8d, 0x42, 0xe0,
+	0x7e, 0x05, 0xc3, 0x82, 0x2a, 0x4b, 0xd1, 0x17, 0xb0, 0x43, 0xa5, 0x38, 0xcb, 0xf8, 0xd4, 0x35,
+	0x65, 0x13, 0x41, 0x59, 0x69, 0x51, 0xde, 0x36, 0x35, 0x00, 0xe6, 0x7e, 0x09, 0x23, 0x0f, 0x5f,
+	0x26, 0xef, 0xf0, 0x0d, 0x06, 0x5f, 0x4b, 0x80, 0xfb, 0x33, 0x18, 0x97, 0x2c, 0x6d, 0x8a, 0x86,
+	0x63, 0x18, 0xbf, 0xc6, 0x94, 0xbc, 0x59, 0x6c, 0xde, 0x07, 0x8e, 0xb1, 0x35, 0xd5, 0xc0, 0xf9,
+	0x5e, 0xfc, 0x03, 0xa0, 0xb2, 0x19, 0x96, 0xf2, 0x1e, 0x97, 0x1c, 0x25, 0x38, 0x1f, 0x58, 0xcb,
+	0xc5, 0x59, 0x35, 0x4b, 0xb3, 0x3a, 0x85, 0xce, 0x73, 0xec, 0x67, 0x73, 0x8a, 0xf3, 0x33, 0xa0,
+	0x61, 0x9c, 0x01, 0x77, 0xa1, 0xc7, 0xe6, 0x69, 0x9a, 0xd0, 0x0c, 0xeb, 0xbe, 0x4b, 0x00, 0xd9,
+	0xd0, 0xc1, 0xb1, 0x7f, 0x16, 0xe1, 0x50, 0xec, 0xc7, 0xae, 0xa7, 0x45, 0x1d, 0xfa, 0xca, 0x34,
+	0xe3, 0xb1, 0xfa, 0x04, 0x46, 0x45, 0x88, 0xa5, 0xe8, 0x00, 0xba, 0x6f, 0x94, 0xac, 0xdc, 0x38,
+	0x10, 0x6e, 0x54, 0x4a, 0x5e, 0xde, 0xea, 0xfe, 0xb5, 0x09, 0x70, 0x38, 0x0f, 0x49, 0x76, 0x7c,
+	0x59, 0x55, 0xd5, 0x21, 0xd8, 0x12, 0xa9, 0x5e, 0xb2, 0x25, 0xbe, 0x39, 0x27, 0x0c, 0x73, 0x16,
+	0xb2, 0x85, 0x4e, 0x95, 0x5a, 0x16, 0xfa, 0x44, 0x9d, 0x77, 0x96, 0x27, 0xbe, 0x8b, 0xfe, 0x6e,
+	0x95, 0x02, 0xde, 0x86, 0x0e, 0x9b, 0x9f, 0x7d, 0x87, 0x83, 0x4c, 0xd5, 0x6f, 0x5a, 0xe4, 0x99,
+	0x29, 0x48, 0xe2, 0x18, 0x07, 0x59, 0x22, 0x82, 0x48, 0x9e, 0x73, 0xfd, 0x1c, 0x93, 0xbb, 0x85,
+	0x25, 0x73, 0x1a, 0xe0, 0x19, 0x49, 0x75, 0x1d, 0xd7, 0x93, 0xc8, 0x49, 0xca, 0xb8, 0xed, 0x0b,
+	0xcc, 0x98, 0xff, 0x16, 0xab, 0xb3, 0x4e, 0x8b, 0xbc, 0x85, 0x8a, 0x28, 0x0b, 0x45, 0xf5, 0xd6,
+	0xf5, 0xb4, 0xe8, 0xfe, 0xb3, 0x01, 0x88, 0xd3, 0xb9, 0xe4, 0x84, 0x93, 0x6c, 0x4e, 0xb3, 0x51,
+	0x9c, 0xe6, 0xda, 0xed, 0xac, 0xe9, 0xb3, 0x0c, 0xfa, 0x26, 0xd0, 0x62, 0x24, 0x0e, 0x34, 0x47,
+	0x52, 0xe0, 0xe8, 0x3c, 0xce, 0x48, 0xa4, 0xf6, 0xbc, 0x14, 0x38, 0x1a, 0x91, 0x0b, 0x22, 0xb9,
+	0x69, 0x79, 0x52, 0x70, 0x9f, 0xc2, 0xad, 0x95, 0x29, 0xb2, 0x14, 0xfd, 0x04, 0xda, 0x58, 0x48,
+	0xca, 0xe5, 0x43, 0xe1, 0xf2, 0xa5, 0x96, 0xa7, 0x9a, 0xdd, 0x8f, 0x61, 0x7c, 0x7c, 0xcd, 0x43,
+	0x8d, 0xa7, 0xf8, 0x67, 0x7e, 0xe6, 0xaf, 0x5d, 0xa1, 0x7b, 0x0c, 0xa8, 0xac, 0xce, 0x52, 0xbe,
+	0xb4, 0xd0, 0xcf, 0x7c, 0xa1, 0x3c, 0xf0, 0xc4, 0xf7, 0xfa, 0x1d, 0xf1, 0x11, 0x8c, 0x8e, 0xa9,
+	0xcf, 0xf0, 0xcd, 0x06, 0xfd, 0x1a, 0xc6, 0x25, 0xed, 0x0d, 0x79, 0x80, 0x07, 0x03, 0xa6, 0x3e,
+	0x9b, 0x53, 0xbc, 0xf4, 0x44, 0x4f, 0x21, 0x27, 0xa1, 0xfb, 0x1d, 0x4c, 0x5e, 0xfb, 0x11, 0xe1,
+	0xe7, 0xd8, 0x2b, 0x7c, 0x91, 0x46, 0x7e, 0x86, 0x99, 0x4a, 0x53, 0x57, 0xf8, 0x6c, 0x16, 0x92,
+	0x3c, 0xb9, 0x5f, 0xe1, 0xb3, 0x67, 0x84, 0x8a, 0xfa, 0x4e, 0x2b, 0x8a, 0x66, 0x69, 0x72, 0x90,
+	0x83, 0x5c, 0x69, 0x02, 0xad, 0xec, 0x1c, 0xe7, 0xe7, 0xa6, 0x14, 0xdc, 0x47, 0xb0, 0x5b, 0x31,
+	0x96, 0x3c, 0x48, 0x30, 0xa5, 0x09, 0x95, 0x2e, 0xea, 0x79, 0x4a, 0x72, 0xff, 0xd1, 0x84, 0xf6,
+	0xe1, 0xcb, 0x93, 0xdf, 0xe3, 0xc5, 0x0f, 0x3b, 0x2e, 0x74, 0x6a, 0xb1, 0x8c, 0xd4, 0xc2, 0x0f,
+	0xab, 0x20, 0x49, 0xb1, 0xbe, 0x44, 0x29, 0xc9, 0xcc, 0xc7, 0xad, 0x42, 0x3e, 0x36, 0x4b, 0x9f,
+	0x76, 0xa9, 0xf4, 0xc9, 0xf3, 0x68, 0xc7, 0xcc, 0xa3, 0x7b, 0xd0, 0x7e, 0x4b, 0x93, 0x79, 0xbe,
+	0xe7, 0x94, 0xb4, 0xb2, 0x65, 0x7b, 0x95, 0x5b, 0xd6, 0x38, 0xe0, 0xa0, 0x7c, 0xc0, 0x2d, 0x6b,
+	0xc7, 0xbe, 0x59, 0x3b, 0xba, 0xff, 0x6d, 0xe8, 0x2b, 0x8a, 0xa4, 0x89, 0x7b, 0xae, 0xc0, 0x4c,
+	0xa3, 0x86, 0x99, 0x66, 0x25, 0x33, 0x56, 0x1d, 0x33, 0x5b, 0xb5, 0xcc, 0xb4, 0xea, 0x98, 0x69,
+	0x57, 0x33, 0xd3, 0x59, 0xcb, 0x4c, 0x77, 0x95, 0x99, 0xe5, 0xd2, 0x7b, 0x85, 0xa5, 0x67, 0x30,
+	0x2a, 0xae, 0x9c, 0xa5, 0xe8, 0x43, 0xe8, 0xf8, 0x29, 0x99, 0xbd, 0xc3, 0x8b, 0xc2, 0xf5, 0x4c,
+	0x69, 0xb4, 0xfd, 0x94, 0xf0, 0x50, 0x1a, 0x81, 0xc5, 0x35, 0x24, 0x05, 0xfc, 0x13, 0x1d, 0xc0,
+	0x48, 0x51, 0xb6, 0xdc, 0x47, 0xf2, 0x84, 0xd9, 0x91, 0xf8, 0x57, 0x7a, 0xb7, 0x7e, 0x2c, 0x8b,
+	0x09, 0x69, 0x91, 0x6d, 0xa2, 0xdb, 0xfd, 0x1c, 0x86, 0x05, 0x75, 0x96, 0xa2, 0x1f, 0x43, 0x57,
+	0xcd, 0x51, 0x27, 0xa4, 0xc2, 0x24, 0x3b, 0x72, 0x92, 0x8c, 0x5f, 0xf2, 0xe4, 0x89, 0xbf, 0xf4,
+	0x6c, 0xc5, 0x25, 0xaf, 0xa8, 0xb2, 0xa9, 0x26, 0xf8, 0x57, 0x03, 0x76, 0xfe, 0x88, 0xe9, 0x25,
+	0x09, 0xf0, 0x61, 0x10, 0x24, 0xf3, 0xea, 0x93, 0xad, 0x2a, 0x40, 0x94, 0xf7, 0xac, 0x82, 0xf7,
+	0x6c, 0xe8, 0xc8, 0x95, 0xea, 0x3d, 0xa5, 0x45, 0x7e, 0x17, 0x93, 0xaf, 0x10, 0x72, 0x9d, 0x2d,
+	0xd1, 0x0a, 0x12, 0xe2, 0xab, 0x2b, 0xc5, 0x7b, 0xbb, 0x14, 0xef, 0xee, 0x37, 0x30, 0x95, 0xce,
+	0x2d, 0xce, 0x96, 0x93, 0xf0, 0x04, 0x86, 0x4c, 0x82, 0x33, 0x5f, 0xa2, 0xca, 0xd7, 0xb7, 0x04,
+	0x8d, 0xa5, 0x0e, 0x3b, 0xac, 0x20, 0xbb, 0x87, 0x60, 0x57, 0x1b, 0xbe, 0xf9, 0x05, 0xe3, 0x6f,
+	0x0d, 0x98, 0xca, 0xc2, 0x7f, 0x75, 0x72, 0xff, 0x1f, 0x36, 0xdd, 0xcf, 0xc0, 0xae, 0x9e, 0xd1,
+	0xa6, 0x80, 0xb0, 0x61, 0x8f, 0xc7, 0x67, 0xb1, 0x9b, 0x28, 0x9f, 0xfe, 0x04, 0xd3, 0xca, 0x16,
+	0x96, 0xa2, 0xa7, 0x30, 0x2a, 0x79, 0x40, 0x47, 0x72, 0xa5, 0x0b, 0x86, 0x45, 0x17, 0x30, 0xf7,
+	0x01, 0x4c, 0xe5, 0xd5, 0x66, 0x23, 0x7f, 0x7c, 0x61, 0xd5, 0xaa, 0x9b, 0x16, 0xf6, 0x97, 0x26,
+	0x38, 0xea, 0x0e, 0x49, 0xf1, 0xe1, 0x3c, 0x3b, 0x4f, 0x28, 0xf9, 0x1e, 0x87, 0x47, 0x49, 0x88,
+	0x37, 0xe6, 0xc8, 0x65, 0x3e, 0x6c, 0xd6, 0xe5, 0x43, 0xab, 0x36, 0x1f, 0x6e, 0xd5, 0xe5, 0xc3,
+	0x56, 0x75, 0x3e, 0x6c, 0xaf, 0xcd, 0x87, 0x15, 0xc5, 0xdd, 0x08, 0xac, 0x94, 0xc4, 0x2a, 0x53,
+	0xf2, 0x4f, 0x71, 0xc2, 0xf3, 0x9c, 0x88, 0xd9, 0x8c, 0xc4, 0x2a, 0x4b, 0xf6, 0x14, 0x72, 0x12,
+	0xbb, 0x0c, 0xee, 0xd4, 0x32, 0x21, 0x0b, 0x96, 0x20, 0x09, 0xf3, 0x32, 0x9c, 0x7f, 0x1b, 0x39,
+	0xb7, 0x59, 0x78, 0xaa, 0xb8, 0x79, 0x9e, 0x5c, 0x00, 0xfa, 0x06, 0x9f, 0xf1, 0xe1, 0xe2, 0x23,
+	0x8a, 0x43, 0x1c, 0x67, 0xc4, 0x8f, 0x56, 0xb6, 0x87, 0xc1, 0x68, 0xb3, 0xc0, 0x68, 0x31, 0x3d,
+	0x58, 0x6b, 0xef, 0x7b, 0x5b, 0xa5, 0xfb, 0xde, 0x63, 0x70, 0x78, 0xe4, 0xae, 0x0e, 0xcf, 0xea,
+	0x6f, 0xdb, 0x73, 0xb8, 0x53, 0xdb, 0x87, 0xa5, 0xe8, 0x73, 0xe8, 0x07, 0x4b, 0x48, 0x05, 0xfb,
+	0x54, 0x04, 0xfb, 0x6a, 0x17, 0xcf, 0xd4, 0x5d, 0x5f, 0xfb, 0x3d, 0x83, 0xbb, 0x32, 0xbc, 0x7f,
+	0xc8, 0x64, 0x15, 0x8b, 0xcd, 0x7c, 0x93, 0x3c, 0x81, 0x7b, 0x6b, 0xac, 0x6c, 0xda, 0x29, 0x1f,
+	0xc2, 0xc0, 0xc3, 0x0c, 0x67, 0xaf, 0xbe, 0x7e, 0xf5, 0xb2, 0x9e, 0xa0, 0x8f, 0x60, 0xdb, 0xd0,
+	0xda, 0x60, 0xf3, 0xf1, 0xdf, 0x87, 0x60, 0x3d, 0xc3, 0xd7, 0xe8, 0x57, 0x30, 0x30, 0x1f, 0x42,
+	0x91, 0xbc, 0x34, 0x97, 0xde, 0x54, 0x9d, 0xdd, 0x0a, 0x94, 0xa5, 0xee, 0x7b, 0xbc, 0xbb, 0xf9,
+	0xc6, 0xa5, 0xba, 0x97, 0x5e, 0x29, 0x9d, 0xdd, 0x0a, 0x54, 0x77, 0x37, 0xdf, 0x40, 0x55, 0xf7,
+	0xd2, 0xcb, 0xa9, 0xb3, 0x5b, 0x81, 0x8a, 0xee, 0x47, 0xb0, 0x53, 0x7c, 0x85, 0x42, 0x7b, 0xc6,
+	0x44, 0x8d, 0x5b, 0xb5, 0x33, 0xad, 0xc4, 0xb5, 0x91, 0xe2, 0x23, 0x91, 0x32, 0xb2, 0xf2, 0x44,
+	0xe5, 0x4c, 0x2b, 0x71, 0x6d, 0xa4, 0xf8, 0x16, 0xa4, 0x8c, 0xac, 0xbc, 0x25, 0x39, 0xd3, 0x4a,
+	0x5c, 0x18, 0x79, 0x0a, 0xdb, 0xe6, 0x53, 0x10, 0x53, 0x74, 0x94, 0x5e, 0x8c, 0x9c, 0xdd, 0x0a,
+	0x54, 0xf4, 0xff, 0x04, 0xe0, 0x77, 0x38, 0x53, 0xcf, 0x3f, 0x48, 0x5e, 0xa2, 0x96, 0x4f, 0x43,
+	0xce, 0xa8, 0x08, 0x88, 0x2e, 0xbf, 0x84, 0xbe, 0xf1, 0x9c, 0x82, 0x6e, 0xe5, 0xa6, 0x97, 0xcf,
+	0x21, 0xce, 0x64, 0x15, 0x14, 0x7d, 0x7f, 0x03, 0xdb, 0xb2, 0xb6, 0xd1, 0xbd, 0x77, 0xd5, 0x83,
+	0x4b, 0xf1, 0x39, 0xc5, 0xd9, 0xab, 0x82, 0x35, 0x6b, 0xc5, 0x97, 0x0b, 0xc5, 0xda, 0xca, 0xab,
+	0x88, 0x33, 0xad, 0xc4, 0x75, 0x0c, 0x99, 0xaf, 0x08, 0x06, 0x69, 0xc6, 0x5b, 0x83, 0xb3, 0x5b,
+	0x81, 0x8a, 0xee, 0xcf, 0x55, 0xfd, 0xb7, 0xbc, 0x92, 0xa2, 0x69, 0xae, 0x5b, 0xbc, 0x4b, 0x3b,
+	0x76, 0x75, 0x83, 0x5e, 0x4b, 0xf1, 0xae, 0xa9, 0xd6, 0xb2, 0x72, 0x5f, 0x75, 0xa6, 0x95, 0xb8,
+	0xa6, 0xb4, 0x70, 0x77, 0x54, 0x94, 0x96, 0x6f, 0x9f, 0xce, 0x5e, 0x15, 0x2c, 0x2c, 0xbc, 0x80,
+	0xf1, 0xca, 0x05, 0x0e, 0xdd, 0x96, 0xec, 0x55, 0x5c, 0x22, 0x1d, 0xa7, 0xae, 0x49, 0x73, 0x6b,
+	0x56, 0xf0, 0x85, 0xec, 0x90, 0x17, 0xbd, 0xce, 0x6e, 0x05, 0x6a, 0x46, 0x97, 0xc4, 0x98, 0x11,
+	0x5d, 0xcb, 0xe2, 0xdc, 0x99, 0xac, 0x82, 0x7a, 0x68, 0xb3, 0x72, 0x46, 0x13, 0x23, 0x8a, 0xca,
+	0x43, 0x97, 0x4b, 0x6c, 0xf7, 0x3d, 0x74, 0x0a, 0x93, 0xaa, 0x2a, 0x12, 0xdd, 0x35, 0xe6, 0xba,
+	0x52, 0xdc, 0x38, 0xf7, 0xd6, 0xb4, 0x6a, 0xb3, 0x55, 0x65, 0x9c, 0x32, 0x5b, 0x53, 0x73, 0x3a,
+	0xf7, 0xd6, 0xb4, 0x0a, 0xb3, 0x9e, 0x7c, 0x17, 0x29, 0xb6, 0x31, 0x74, 0x27, 0xe7, 0x66, 0xb5,
+	0xfc, 0x73, 0xee, 0xd6, 0x37, 0xea, 0xa9, 0x56, 0x15, 0x66, 0x6a, 0xaa, 0x35, 0xe5, 0x9d, 0x73,
+	0x6f, 0x4d, 0xab, 0x30, 0xfb, 0x2d, 0x4c, 0x6b, 0x6a, 0x15, 0xf4, 0xbe, 0x99, 0x64, 0x2b, 0x6a,
+	0x3a, 0x67, 0x7f, 0xbd, 0x82, 0xb6, 0x5f, 0x73, 0xce, 0x2b, 0xfb, 0xf5, 0x95, 0x83, 0xb3, 0xbf,
+	0x5e, 0x41, 0xd8, 0x0f, 0xe1, 0x76, 0xed, 0x51, 0x8c, 0xee, 0x1b, 0xab, 0xaf, 0x19, 0xc3, 0xdd,
+	0xa4, 0x22, 0x46, 0xf9, 0x14, 0x7a, 0xf9, 0x61, 0x8c, 0xc6, 0x2a, 0x48, 0x97, 0x47, 0xb8, 0x83,
+	0xca, 0x10, 0xef, 0xf5, 0xdb, 0x09, 0xa0, 0x20, 0xb9, 0x78, 0x18, 0x24, 0x14, 0x27, 0xec, 0x61,
+	0x88, 0xaf, 0xb9, 0xd6, 0x59, 0x5b, 0xfc, 0xc6, 0xfe, 0xf9, 0xff, 0x06, 0x00, 0x29, 0x7b, 0xf5,
+	0xef, 0xda, 0x1e, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// DexClient is the client API for Dex service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type DexClient interface {
+	// CreateClient creates a client.
+	CreateClient(ctx context.Context, in *CreateClientReq, opts ...grpc.CallOption) (*CreateClientResp, error)
+	// UpdateClient updates an existing client
+	UpdateClient(ctx context.Context, in *UpdateClientReq, opts ...grpc.CallOption) (*UpdateClientResp, error)
+	// DeleteClient deletes the provided client.
+	DeleteClient(ctx context.Context, in *DeleteClientReq, opts ...grpc.CallOption) (*DeleteClientResp, error)
+	// CreatePassword creates a password.
+	CreatePassword(ctx context.Context, in *CreatePasswordReq, opts ...grpc.CallOption) (*CreatePasswordResp, error)
+	// UpdatePassword modifies existing password.
+	UpdatePassword(ctx context.Context, in *UpdatePasswordReq, opts ...grpc.CallOption) (*UpdatePasswordResp, error)
+	// DeletePassword deletes the password.
+	DeletePassword(ctx context.Context, in *DeletePasswordReq, opts ...grpc.CallOption) (*DeletePasswordResp, error)
+	// ListPassword lists all password entries.
+	ListPasswords(ctx context.Context, in *ListPasswordReq, opts ...grpc.CallOption) (*ListPasswordResp, error)
+	// GetVersion returns version information of the server.
+	GetVersion(ctx context.Context, in *VersionReq, opts ...grpc.CallOption) (*VersionResp, error)
+	// ListRefresh lists all the refresh token entries for a particular user.
+	ListRefresh(ctx context.Context, in *ListRefreshReq, opts ...grpc.CallOption) (*ListRefreshResp, error)
+	// RevokeRefresh revokes the refresh token for the provided user-client pair.
+	//
+	// Note that each user-client pair can have only one refresh token at a time.
+	RevokeRefresh(ctx context.Context, in *RevokeRefreshReq, opts ...grpc.CallOption) (*RevokeRefreshResp, error)
+	// VerifyPassword returns whether a password matches a hash for a specific email or not.
+	VerifyPassword(ctx context.Context, in *VerifyPasswordReq, opts ...grpc.CallOption) (*VerifyPasswordResp, error)
+	// ListFeatures lists the experimental features and whether they are enabled.
+	ListFeatures(ctx context.Context, in *ListFeaturesReq, opts ...grpc.CallOption) (*ListFeaturesResp, error)
+	// ListAuditEvents queries the audit events persisted by the server.
+	ListAuditEvents(ctx context.Context, in *ListAuditEventsReq, opts ...grpc.CallOption) (*ListAuditEventsResp, error)
+	// ExportUserData exports all data stored about a user as JSON.
+	ExportUserData(ctx context.Context, in *ExportUserDataReq, opts ...grpc.CallOption) (*ExportUserDataResp, error)
+	// EraseUserData deletes the sessions, refresh tokens, passkeys and local
+	// password of a user and anonymizes the user's audit events.
+	EraseUserData(ctx context.Context, in *EraseUserDataReq, opts ...grpc.CallOption) (*EraseUserDataResp, error)
+	// ValidateTemplates renders the web templates in a directory with sample
+	// data and reports templates which fail to load or render.
+	ValidateTemplates(ctx context.Context, in *ValidateTemplatesReq, opts ...grpc.CallOption) (*ValidateTemplatesResp, error)
+	// CreateAPIKey creates an API key which can be exchanged for tokens.
+	CreateAPIKey(ctx context.Context, in *CreateAPIKeyReq, opts ...grpc.CallOption) (*CreateAPIKeyResp, error)
+	// ListAPIKeys lists API keys without their secrets.
+	ListAPIKeys(ctx context.Context, in *ListAPIKeysReq, opts ...grpc.CallOption) (*ListAPIKeysResp, error)
+	// RevokeAPIKey deletes an API key.
+	RevokeAPIKey(ctx context.Context, in *RevokeAPIKeyReq, opts ...grpc.CallOption) (*RevokeAPIKeyResp, error)
+	// CreateServiceAccount creates a service account.
+	CreateServiceAccount(ctx context.Context, in *CreateServiceAccountReq, opts ...grpc.CallOption) (*CreateServiceAccountResp, error)
+	// UpdateServiceAccount updates an existing service account.
+	UpdateServiceAccount(ctx context.Context, in *UpdateServiceAccountReq, opts ...grpc.CallOption) (*UpdateServiceAccountResp, error)
+	// ListServiceAccounts lists all service accounts.
+	ListServiceAccounts(ctx context.Context, in *ListServiceAccountsReq, opts ...grpc.CallOption) (*ListServiceAccountsResp, error)
+	// DeleteServiceAccount deletes a service account.
+	DeleteServiceAccount(ctx context.Context, in *DeleteServiceAccountReq, opts ...grpc.CallOption) (*DeleteServiceAccountResp, error)
+	// CreatePreAuthorizedCode creates a single-use code which can be exchanged for
+	// tokens without a login.
+	CreatePreAuthorizedCode(ctx context.Context, in *CreatePreAuthorizedCodeReq, opts ...grpc.CallOption) (*CreatePreAuthorizedCodeResp, error)
+	// ListWebAuthnCredentials lists the passkeys of a local user.
+	ListWebAuthnCredentials(ctx context.Context, in *ListWebAuthnCredentialsReq, opts ...grpc.CallOption) (*ListWebAuthnCredentialsResp, error)
+	// DeleteWebAuthnCredentials deletes passkeys of a local user, for example
+	// when they lost their authenticator.
+	DeleteWebAuthnCredentials(ctx context.Context, in *DeleteWebAuthnCredentialsReq, opts ...grpc.CallOption) (*DeleteWebAuthnCredentialsResp, error)
+	// ResetTOTP deletes the TOTP secret and backup codes of a local user, for
+	// example when they lost their authenticator app.
+	ResetTOTP(ctx context.Context, in *ResetTOTPReq, opts ...grpc.CallOption) (*ResetTOTPResp, error)
+}
+
+type dexClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewDexClient(cc *grpc.ClientConn) DexClient {
+	return &dexClient{cc}
+}
+
+func (c *dexClient) CreateClient(ctx context.Context, in *CreateClientReq, opts ...grpc.CallOption) (*CreateClientResp, error) {
+	out := new(CreateClientResp)
+	err := c.cc.Invoke(ctx, "/api.Dex/CreateClient", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dexClient) UpdateClient(ctx context.Context, in *UpdateClientReq, opts ...grpc.CallOption) (*UpdateClientResp, error) {
+	out := new(UpdateClientResp)
+	err := c.cc.Invoke(ctx, "/api.Dex/UpdateClient", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dexClient) DeleteClient(ctx context.Context, in *DeleteClientReq, opts ...grpc.CallOption) (*DeleteClientResp, error) {
+	out := new(DeleteClientResp)
+	err := c.cc.Invoke(ctx, "/api.Dex/DeleteClient", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dexClient) CreatePassword(ctx context.Context, in *CreatePasswordReq, opts ...grpc.CallOption) (*CreatePasswordResp, error) {
+	out := new(CreatePasswordResp)
+	err := c.cc.Invoke(ctx, "/api.Dex/CreatePassword", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dexClient) UpdatePassword(ctx context.Context, in *UpdatePasswordReq, opts ...grpc.CallOption) (*UpdatePasswordResp, error) {
+	out := new(UpdatePasswordResp)
+	err := c.cc.Invoke(ctx, "/api.Dex/UpdatePassword", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dexClient) DeletePassword(ctx context.Context, in *DeletePasswordReq, opts ...grpc.CallOption) (*DeletePasswordResp, error) {
+	out := new(DeletePasswordResp)
+	err := c.cc.Invoke(ctx, "/api.Dex/DeletePassword", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dexClient) ListPasswords(ctx context.Context, in *ListPasswordReq, opts ...grpc.CallOption) (*ListPasswordResp, error) {
+	out := new(ListPasswordResp)
+	err := c.cc.Invoke(ctx, "/api.Dex/ListPasswords", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dexClient) GetVersion(ctx context.Context, in *VersionReq, opts ...grpc.CallOption) (*VersionResp, error) {
+	out := new(VersionResp)
+	err := c.cc.Invoke(ctx, "/api.Dex/GetVersion", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dexClient) ListRefresh(ctx context.Context, in *ListRefreshReq, opts ...grpc.CallOption) (*ListRefreshResp, error) {
+	out := new(ListRefreshResp)
+	err := c.cc.Invoke(ctx, "/api.Dex/ListRefresh", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dexClient) RevokeRefresh(ctx context.Context, in *RevokeRefreshReq, opts ...grpc.CallOption) (*RevokeRefreshResp, error) {
+	out := new(RevokeRefreshResp)
+	err := c.cc.Invoke(ctx, "/api.Dex/RevokeRefresh", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dexClient) VerifyPassword(ctx context.Context, in *VerifyPasswordReq, opts ...grpc.CallOption) (*VerifyPasswordResp, error) {
+	out := new(VerifyPasswordResp)
+	err := c.cc.Invoke(ctx, "/api.Dex/VerifyPassword", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dexClient) ListFeatures(ctx context.Context, in *ListFeaturesReq, opts ...grpc.CallOption) (*ListFeaturesResp, error) {
+	out := new(ListFeaturesResp)
+	err := c.cc.Invoke(ctx, "/api.Dex/ListFeatures", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dexClient) ListAuditEvents(ctx context.Context, in *ListAuditEventsReq, opts ...grpc.CallOption) (*ListAuditEventsResp, error) {
+	out := new(ListAuditEventsResp)
+	err := c.cc.Invoke(ctx, "/api.Dex/ListAuditEvents", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dexClient) ExportUserData(ctx context.Context, in *ExportUserDataReq, opts ...grpc.CallOption) (*ExportUserDataResp, error) {
+	out := new(ExportUserDataResp)
+	err := c.cc.Invoke(ctx, "/api.Dex/ExportUserData", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dexClient) EraseUserData(ctx context.Context, in *EraseUserDataReq, opts ...grpc.CallOption) (*EraseUserDataResp, error) {
+	out := new(EraseUserDataResp)
+	err := c.cc.Invoke(ctx, "/api.Dex/EraseUserData", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dexClient) ValidateTemplates(ctx context.Context, in *ValidateTemplatesReq, opts ...grpc.CallOption) (*ValidateTemplatesResp, error) {
+	out := new(ValidateTemplatesResp)
+	err := c.cc.Invoke(ctx, "/api.Dex/ValidateTemplates", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dexClient) CreateAPIKey(ctx context.Context, in *CreateAPIKeyReq, opts ...grpc.CallOption) (*CreateAPIKeyResp, error) {
+	out := new(CreateAPIKeyResp)
+	err := c.cc.Invoke(ctx, "/api.Dex/CreateAPIKey", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dexClient) ListAPIKeys(ctx context.Context, in *ListAPIKeysReq, opts ...grpc.CallOption) (*ListAPIKeysResp, error) {
+	out := new(ListAPIKeysResp)
+	err := c.cc.Invoke(ctx, "/api.Dex/ListAPIKeys", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dexClient) RevokeAPIKey(ctx context.Context, in *RevokeAPIKeyReq, opts ...grpc.CallOption) (*RevokeAPIKeyResp, error) {
+	out := new(RevokeAPIKeyResp)
+	err := c.cc.Invoke(ctx, "/api.Dex/RevokeAPIKey", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dexClient) CreateServiceAccount(ctx context.Context, in *CreateServiceAccountReq, opts ...grpc.CallOption) (*CreateServiceAccountResp, error) {
+	out := new(CreateServiceAccountResp)
+	err := c.cc.Invoke(ctx, "/api.Dex/CreateServiceAccount", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dexClient) UpdateServiceAccount(ctx context.Context, in *UpdateServiceAccountReq, opts ...grpc.CallOption) (*UpdateServiceAccountResp, error) {
+	out := new(UpdateServiceAccountResp)
+	err := c.cc.Invoke(ctx, "/api.Dex/UpdateServiceAccount", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dexClient) ListServiceAccounts(ctx context.Context, in *ListServiceAccountsReq, opts ...grpc.CallOption) (*ListServiceAccountsResp, error) {
+	out := new(ListServiceAccountsResp)
+	err := c.cc.Invoke(ctx, "/api.Dex/ListServiceAccounts", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dexClient) DeleteServiceAccount(ctx context.Context, in *DeleteServiceAccountReq, opts ...grpc.CallOption) (*DeleteServiceAccountResp, error) {
+	out := new(DeleteServiceAccountResp)
+	err := c.cc.Invoke(ctx, "/api.Dex/DeleteServiceAccount", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dexClient) CreatePreAuthorizedCode(ctx context.Context, in *CreatePreAuthorizedCodeReq, opts ...grpc.CallOption) (*CreatePreAuthorizedCodeResp, error) {
+	out := new(CreatePreAuthorizedCodeResp)
+	err := c.cc.Invoke(ctx, "/api.Dex/CreatePreAuthorizedCode", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dexClient) ListWebAuthnCredentials(ctx context.Context, in *ListWebAuthnCredentialsReq, opts ...grpc.CallOption) (*ListWebAuthnCredentialsResp, error) {
+	out := new(ListWebAuthnCredentialsResp)
+	err := c.cc.Invoke(ctx, "/api.Dex/ListWebAuthnCredentials", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dexClient) DeleteWebAuthnCredentials(ctx context.Context, in *DeleteWebAuthnCredentialsReq, opts ...grpc.CallOption) (*DeleteWebAuthnCredentialsResp, error) {
+	out := new(DeleteWebAuthnCredentialsResp)
+	err := c.cc.Invoke(ctx, "/api.Dex/DeleteWebAuthnCredentials", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dexClient) ResetTOTP(ctx context.Context, in *ResetTOTPReq, opts ...grpc.CallOption) (*ResetTOTPResp, error) {
+	out := new(ResetTOTPResp)
+	err := c.cc.Invoke(ctx, "/api.Dex/ResetTOTP", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DexServer is the server API for Dex service.
+type DexServer interface {
 	// CreateClient creates a client.
 	CreateClient(context.Context, *CreateClientReq) (*CreateClientResp, error)
 	// UpdateClient updates an existing client
@@ -1462,6 +3926,43 @@ type DexServer interface {
 	RevokeRefresh(context.Context, *RevokeRefreshReq) (*RevokeRefreshResp, error)
 	// VerifyPassword returns whether a password matches a hash for a specific email or not.
 	VerifyPassword(context.Context, *VerifyPasswordReq) (*VerifyPasswordResp, error)
+	// ListFeatures lists the experimental features and whether they are enabled.
+	ListFeatures(context.Context, *ListFeaturesReq) (*ListFeaturesResp, error)
+	// ListAuditEvents queries the audit events persisted by the server.
+	ListAuditEvents(context.Context, *ListAuditEventsReq) (*ListAuditEventsResp, error)
+	// ExportUserData exports all data stored about a user as JSON.
+	ExportUserData(context.Context, *ExportUserDataReq) (*ExportUserDataResp, error)
+	// EraseUserData deletes the sessions, refresh tokens, passkeys and local
+	// password of a user and anonymizes the user's audit events.
+	EraseUserData(context.Context, *EraseUserDataReq) (*EraseUserDataResp, error)
+	// ValidateTemplates renders the web templates in a directory with sample
+	// data and reports templates which fail to load or render.
+	ValidateTemplates(context.Context, *ValidateTemplatesReq) (*ValidateTemplatesResp, error)
+	// CreateAPIKey creates an API key which can be exchanged for tokens.
+	CreateAPIKey(context.Context, *CreateAPIKeyReq) (*CreateAPIKeyResp, error)
+	// ListAPIKeys lists API keys without their secrets.
+	ListAPIKeys(context.Context, *ListAPIKeysReq) (*ListAPIKeysResp, error)
+	// RevokeAPIKey deletes an API key.
+	RevokeAPIKey(context.Context, *RevokeAPIKeyReq) (*RevokeAPIKeyResp, error)
+	// CreateServiceAccount creates a service account.
+	CreateServiceAccount(context.Context, *CreateServiceAccountReq) (*CreateServiceAccountResp, error)
+	// UpdateServiceAccount updates an existing service account.
+	UpdateServiceAccount(context.Context, *UpdateServiceAccountReq) (*UpdateServiceAccountResp, error)
+	// ListServiceAccounts lists all service accounts.
+	ListServiceAccounts(context.Context, *ListServiceAccountsReq) (*ListServiceAccountsResp, error)
+	// DeleteServiceAccount deletes a service account.
+	DeleteServiceAccount(context.Context, *DeleteServiceAccountReq) (*DeleteServiceAccountResp, error)
+	// CreatePreAuthorizedCode creates a single-use code which can be exchanged for
+	// tokens without a login.
+	CreatePreAuthorizedCode(context.Context, *CreatePreAuthorizedCodeReq) (*CreatePreAuthorizedCodeResp, error)
+	// ListWebAuthnCredentials lists the passkeys of a local user.
+	ListWebAuthnCredentials(context.Context, *ListWebAuthnCredentialsReq) (*ListWebAuthnCredentialsResp, error)
+	// DeleteWebAuthnCredentials deletes passkeys of a local user, for example
+	// when they lost their authenticator.
+	DeleteWebAuthnCredentials(context.Context, *DeleteWebAuthnCredentialsReq) (*DeleteWebAuthnCredentialsResp, error)
+	// ResetTOTP deletes the TOTP secret and backup codes of a local user, for
+	// example when they lost their authenticator app.
+	ResetTOTP(context.Context, *ResetTOTPReq) (*ResetTOTPResp, error)
 }
 
 // UnimplementedDexServer can be embedded to have forward compatible implementations.
@@ -1501,6 +4002,54 @@ func (*UnimplementedDexServer) RevokeRefresh(ctx context.Context, req *RevokeRef
 func (*UnimplementedDexServer) VerifyPassword(ctx context.Context, req *VerifyPasswordReq) (*VerifyPasswordResp, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method VerifyPassword not implemented")
 }
+func (*UnimplementedDexServer) ListFeatures(ctx context.Context, req *ListFeaturesReq) (*ListFeaturesResp, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListFeatures not implemented")
+}
+func (*UnimplementedDexServer) ListAuditEvents(ctx context.Context, req *ListAuditEventsReq) (*ListAuditEventsResp, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAuditEvents not implemented")
+}
+func (*UnimplementedDexServer) ExportUserData(ctx context.Context, req *ExportUserDataReq) (*ExportUserDataResp, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExportUserData not implemented")
+}
+func (*UnimplementedDexServer) EraseUserData(ctx context.Context, req *EraseUserDataReq) (*EraseUserDataResp, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EraseUserData not implemented")
+}
+func (*UnimplementedDexServer) ValidateTemplates(ctx context.Context, req *ValidateTemplatesReq) (*ValidateTemplatesResp, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ValidateTemplates not implemented")
+}
+func (*UnimplementedDexServer) CreateAPIKey(ctx context.Context, req *CreateAPIKeyReq) (*CreateAPIKeyResp, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateAPIKey not implemented")
+}
+func (*UnimplementedDexServer) ListAPIKeys(ctx context.Context, req *ListAPIKeysReq) (*ListAPIKeysResp, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAPIKeys not implemented")
+}
+func (*UnimplementedDexServer) RevokeAPIKey(ctx context.Context, req *RevokeAPIKeyReq) (*RevokeAPIKeyResp, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeAPIKey not implemented")
+}
+func (*UnimplementedDexServer) CreateServiceAccount(ctx context.Context, req *CreateServiceAccountReq) (*CreateServiceAccountResp, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateServiceAccount not implemented")
+}
+func (*UnimplementedDexServer) UpdateServiceAccount(ctx context.Context, req *UpdateServiceAccountReq) (*UpdateServiceAccountResp, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateServiceAccount not implemented")
+}
+func (*UnimplementedDexServer) ListServiceAccounts(ctx context.Context, req *ListServiceAccountsReq) (*ListServiceAccountsResp, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListServiceAccounts not implemented")
+}
+func (*UnimplementedDexServer) DeleteServiceAccount(ctx context.Context, req *DeleteServiceAccountReq) (*DeleteServiceAccountResp, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteServiceAccount not implemented")
+}
+func (*UnimplementedDexServer) CreatePreAuthorizedCode(ctx context.Context, req *CreatePreAuthorizedCodeReq) (*CreatePreAuthorizedCodeResp, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreatePreAuthorizedCode not implemented")
+}
+func (*UnimplementedDexServer) ListWebAuthnCredentials(ctx context.Context, req *ListWebAuthnCredentialsReq) (*ListWebAuthnCredentialsResp, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListWebAuthnCredentials not implemented")
+}
+func (*UnimplementedDexServer) DeleteWebAuthnCredentials(ctx context.Context, req *DeleteWebAuthnCredentialsReq) (*DeleteWebAuthnCredentialsResp, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteWebAuthnCredentials not implemented")
+}
+func (*UnimplementedDexServer) ResetTOTP(ctx context.Context, req *ResetTOTPReq) (*ResetTOTPResp, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ResetTOTP not implemented")
+}
 
 func RegisterDexServer(s *grpc.Server, srv DexServer) {
 	s.RegisterService(&_Dex_serviceDesc, srv)
@@ -1704,6 +4253,294 @@ func _Dex_VerifyPassword_Handler(srv interface{}, ctx context.Context, dec func(
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Dex_ListFeatures_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListFeaturesReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DexServer).ListFeatures(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Dex/ListFeatures",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DexServer).ListFeatures(ctx, req.(*ListFeaturesReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Dex_ListAuditEvents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAuditEventsReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DexServer).ListAuditEvents(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Dex/ListAuditEvents",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DexServer).ListAuditEvents(ctx, req.(*ListAuditEventsReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Dex_ExportUserData_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExportUserDataReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DexServer).ExportUserData(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Dex/ExportUserData",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DexServer).ExportUserData(ctx, req.(*ExportUserDataReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Dex_EraseUserData_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EraseUserDataReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DexServer).EraseUserData(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Dex/EraseUserData",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DexServer).EraseUserData(ctx, req.(*EraseUserDataReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Dex_ValidateTemplates_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ValidateTemplatesReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DexServer).ValidateTemplates(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Dex/ValidateTemplates",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DexServer).ValidateTemplates(ctx, req.(*ValidateTemplatesReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Dex_CreateAPIKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateAPIKeyReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DexServer).CreateAPIKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Dex/CreateAPIKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DexServer).CreateAPIKey(ctx, req.(*CreateAPIKeyReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Dex_ListAPIKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAPIKeysReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DexServer).ListAPIKeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Dex/ListAPIKeys",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DexServer).ListAPIKeys(ctx, req.(*ListAPIKeysReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Dex_RevokeAPIKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeAPIKeyReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DexServer).RevokeAPIKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Dex/RevokeAPIKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DexServer).RevokeAPIKey(ctx, req.(*RevokeAPIKeyReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Dex_CreateServiceAccount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateServiceAccountReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DexServer).CreateServiceAccount(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Dex/CreateServiceAccount",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DexServer).CreateServiceAccount(ctx, req.(*CreateServiceAccountReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Dex_UpdateServiceAccount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateServiceAccountReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DexServer).UpdateServiceAccount(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Dex/UpdateServiceAccount",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DexServer).UpdateServiceAccount(ctx, req.(*UpdateServiceAccountReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Dex_ListServiceAccounts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListServiceAccountsReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DexServer).ListServiceAccounts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Dex/ListServiceAccounts",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DexServer).ListServiceAccounts(ctx, req.(*ListServiceAccountsReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Dex_DeleteServiceAccount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteServiceAccountReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DexServer).DeleteServiceAccount(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Dex/DeleteServiceAccount",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DexServer).DeleteServiceAccount(ctx, req.(*DeleteServiceAccountReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Dex_CreatePreAuthorizedCode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreatePreAuthorizedCodeReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DexServer).CreatePreAuthorizedCode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Dex/CreatePreAuthorizedCode",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DexServer).CreatePreAuthorizedCode(ctx, req.(*CreatePreAuthorizedCodeReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Dex_ListWebAuthnCredentials_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListWebAuthnCredentialsReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DexServer).ListWebAuthnCredentials(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Dex/ListWebAuthnCredentials",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DexServer).ListWebAuthnCredentials(ctx, req.(*ListWebAuthnCredentialsReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Dex_DeleteWebAuthnCredentials_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteWebAuthnCredentialsReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DexServer).DeleteWebAuthnCredentials(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Dex/DeleteWebAuthnCredentials",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DexServer).DeleteWebAuthnCredentials(ctx, req.(*DeleteWebAuthnCredentialsReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Dex_ResetTOTP_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResetTOTPReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DexServer).ResetTOTP(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Dex/ResetTOTP",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DexServer).ResetTOTP(ctx, req.(*ResetTOTPReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _Dex_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "api.Dex",
 	HandlerType: (*DexServer)(nil),
@@ -1752,6 +4589,70 @@ var _Dex_serviceDesc = grpc.ServiceDesc{
 			MethodName: "VerifyPassword",
 			Handler:    _Dex_VerifyPassword_Handler,
 		},
+		{
+			MethodName: "ListFeatures",
+			Handler:    _Dex_ListFeatures_Handler,
+		},
+		{
+			MethodName: "ListAuditEvents",
+			Handler:    _Dex_ListAuditEvents_Handler,
+		},
+		{
+			MethodName: "ExportUserData",
+			Handler:    _Dex_ExportUserData_Handler,
+		},
+		{
+			MethodName: "EraseUserData",
+			Handler:    _Dex_EraseUserData_Handler,
+		},
+		{
+			MethodName: "ValidateTemplates",
+			Handler:    _Dex_ValidateTemplates_Handler,
+		},
+		{
+			MethodName: "CreateAPIKey",
+			Handler:    _Dex_CreateAPIKey_Handler,
+		},
+		{
+			MethodName: "ListAPIKeys",
+			Handler:    _Dex_ListAPIKeys_Handler,
+		},
+		{
+			MethodName: "RevokeAPIKey",
+			Handler:    _Dex_RevokeAPIKey_Handler,
+		},
+		{
+			MethodName: "CreateServiceAccount",
+			Handler:    _Dex_CreateServiceAccount_Handler,
+		},
+		{
+			MethodName: "UpdateServiceAccount",
+			Handler:    _Dex_UpdateServiceAccount_Handler,
+		},
+		{
+			MethodName: "ListServiceAccounts",
+			Handler:    _Dex_ListServiceAccounts_Handler,
+		},
+		{
+			MethodName: "DeleteServiceAccount",
+			Handler:    _Dex_DeleteServiceAccount_Handler,
+		},
+		{
+			MethodName: "CreatePreAuthorizedCode",
+			Handler:    _Dex_CreatePreAuthorizedCode_Handler,
+		},
+		{
+			MethodName: "ListWebAuthnCredentials",
+			Handler:    _Dex_ListWebAuthnCredentials_Handler,
+		},
+		{
+			MethodName: "DeleteWebAuthnCredentials",
+			Handler:    _Dex_DeleteWebAuthnCredentials_Handler,
+		},
+		{
+			MethodName: "ResetTOTP",
+			Handler:    _Dex_ResetTOTP_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "api/v2/api.proto",
@@ -1,17 +1,30 @@
 package main
 
 import (
+	"context"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
 	"os"
 	"strings"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
+	jose "gopkg.in/square/go-jose.v2"
 
 	"github.com/dexidp/dex/pkg/log"
+	"github.com/dexidp/dex/pkg/secret"
 	"github.com/dexidp/dex/server"
 	"github.com/dexidp/dex/storage"
+	"github.com/dexidp/dex/storage/cache"
+	"github.com/dexidp/dex/storage/dynamodb"
+	"github.com/dexidp/dex/storage/encrypted"
 	"github.com/dexidp/dex/storage/etcd"
 	"github.com/dexidp/dex/storage/kubernetes"
 	"github.com/dexidp/dex/storage/memory"
@@ -28,6 +41,88 @@ type Config struct {
 	GRPC      GRPC      `json:"grpc"`
 	Expiry    Expiry    `json:"expiry"`
 	Logger    Logger    `json:"logger"`
+	Audit     Audit     `json:"audit"`
+
+	// Alerts configures notifying operators about repeated failures.
+	Alerts Alerts `json:"alerts"`
+
+	// Admin configures access to administrative endpoints.
+	Admin Admin `json:"admin"`
+
+	// SigningMigration configures a new signing key to migrate to.
+	SigningMigration SigningMigration `json:"signingMigration"`
+
+	// ClockDrift configures periodic checks of the local clock.
+	ClockDrift ClockDrift `json:"clockDrift"`
+
+	// GroupSync configures periodic refreshes of the identities of users
+	// with refresh tokens.
+	GroupSync GroupSync `json:"groupSync"`
+
+	// IDTokenReplay configures one-time-use ID tokens for some clients.
+	IDTokenReplay IDTokenReplay `json:"idTokenReplay"`
+
+	// FailureDelay slows down responses to failed authentication attempts.
+	FailureDelay FailureDelay `json:"failureDelay"`
+
+	// LoadShedding rejects low-priority requests while the storage is
+	// degraded.
+	LoadShedding LoadShedding `json:"loadShedding"`
+
+	// Priorities assigns priority classes to endpoint groups. See
+	// server.Config.Priorities for the groups and classes.
+	Priorities map[string]string `json:"priorities"`
+
+	// SPIFFE configures workloads authenticating as clients with SPIFFE
+	// SVIDs.
+	SPIFFE SPIFFE `json:"spiffe"`
+
+	// Regions configures serving from several regions, each reading from a
+	// replica of the storage.
+	Regions Regions `json:"regions"`
+
+	// Reload configures reloading static clients, passwords and connectors
+	// while serving.
+	Reload Reload `json:"reload"`
+
+	// AccessWindows restrict when matching clients and users can obtain new
+	// tokens.
+	AccessWindows []AccessWindow `json:"accessWindows"`
+
+	// ShadowPolicies are evaluated alongside the active policies without
+	// affecting responses.
+	ShadowPolicies *ShadowPolicies `json:"shadowPolicies"`
+
+	// ClaimTransforms transform the identities returned by connectors
+	// before tokens are issued for them.
+	ClaimTransforms []ClaimTransform `json:"claimTransforms"`
+
+	// Credentials lists the types of verifiable credentials issued by the
+	// credential endpoint.
+	Credentials []CredentialType `json:"credentials"`
+
+	// Authorization configures an external authorizer consulted before any
+	// tokens are issued.
+	Authorization Authorization `json:"authorization"`
+
+	// Features lists the experimental features to enable.
+	Features []server.Feature `json:"features"`
+
+	// WebFinger configures issuer discovery by user email.
+	WebFinger WebFinger `json:"webFinger"`
+
+	// Discovery lists fields to add to or override in the discovery document.
+	Discovery map[string]interface{} `json:"discovery"`
+
+	// TermsOfService users must accept before tokens are issued to them.
+	TermsOfService TermsOfService `json:"termsOfService"`
+
+	// WebAuthn configures passkeys as a second factor of the password db.
+	WebAuthn WebAuthn `json:"webAuthn"`
+
+	// TOTP configures authenticator app codes as a second factor of the
+	// password db.
+	TOTP TOTP `json:"totp"`
 
 	Frontend server.WebConfig `json:"frontend"`
 
@@ -47,6 +142,10 @@ type Config struct {
 	// querying the storage. Cannot be specified without enabling a passwords
 	// database.
 	StaticPasswords []password `json:"staticPasswords"`
+
+	// PasswordHashing configures the hashes passwords are migrated to when
+	// users log in.
+	PasswordHashing Hashing `json:"passwordHashing"`
 }
 
 //Validate the configuration
@@ -66,6 +165,10 @@ func (c Config) Validate() error {
 		{c.GRPC.TLSKey != "" && c.GRPC.Addr == "", "no address specified for gRPC"},
 		{(c.GRPC.TLSCert == "") != (c.GRPC.TLSKey == ""), "must specific both a gRPC TLS cert and key"},
 		{c.GRPC.TLSCert == "" && c.GRPC.TLSClientCA != "", "cannot specify gRPC TLS client CA without a gRPC TLS cert"},
+		{c.Admin.HTTP != "" && c.Admin.Token == "", "cannot specify an admin listener without an admin token"},
+		{!c.EnablePasswordDB && c.WebAuthn.Mode != "", "cannot enable WebAuthn without enabling password db"},
+		{!c.EnablePasswordDB && c.TOTP.Mode != "", "cannot enable TOTP without enabling password db"},
+		{c.WebAuthn.Mode != "" && c.TOTP.Mode != "", "cannot enable both WebAuthn and TOTP"},
 	}
 
 	var checkErrors []string
@@ -75,6 +178,49 @@ func (c Config) Validate() error {
 			checkErrors = append(checkErrors, check.errMsg)
 		}
 	}
+	for _, client := range c.StaticClients {
+		for _, cidr := range client.AllowedCIDRs {
+			if _, _, err := net.ParseCIDR(cidr); err != nil {
+				checkErrors = append(checkErrors, fmt.Sprintf("invalid allowed CIDR %q for client %q", cidr, client.ID))
+			}
+		}
+		if err := server.ValidateClientClaims(client.Claims); err != nil {
+			checkErrors = append(checkErrors, fmt.Sprintf("invalid claims for client %q: %v", client.ID, err))
+		}
+		if err := server.ValidateClientMetadata(client); err != nil {
+			checkErrors = append(checkErrors, fmt.Sprintf("invalid metadata for client %q: %v", client.ID, err))
+		}
+		if !client.Expiry.IsZero() {
+			checkErrors = append(checkErrors, fmt.Sprintf("static client %q can't expire", client.ID))
+		}
+	}
+	connectorIDs := make(map[string]bool, len(c.StaticConnectors))
+	for _, conn := range c.StaticConnectors {
+		connectorIDs[conn.ID] = true
+	}
+	for _, conn := range c.StaticConnectors {
+		if err := server.ValidateConnectorID(conn.ID); err != nil {
+			checkErrors = append(checkErrors, err.Error())
+		}
+		if conn.Fallback != "" && (conn.Fallback == conn.ID || !connectorIDs[conn.Fallback]) {
+			checkErrors = append(checkErrors, fmt.Sprintf("invalid fallback %q for connector %q: must be another connector", conn.Fallback, conn.ID))
+		}
+	}
+	checkOfflineAccessRules := func(rules []server.OfflineAccessRule, name string) {
+		for i, rule := range rules {
+			for _, grantType := range rule.GrantTypes {
+				switch grantType {
+				case "authorization_code", "refresh_token", "password":
+				default:
+					checkErrors = append(checkErrors, fmt.Sprintf("invalid grant type %q in %s %d", grantType, name, i))
+				}
+			}
+		}
+	}
+	checkOfflineAccessRules(c.OAuth2.OfflineAccessRules, "offline access rule")
+	if c.ShadowPolicies != nil {
+		checkOfflineAccessRules(c.ShadowPolicies.OfflineAccessRules, "shadow offline access rule")
+	}
 	if len(checkErrors) != 0 {
 		return fmt.Errorf("invalid Config:\n\t-\t%s", strings.Join(checkErrors, "\n\t-\t"))
 	}
@@ -106,6 +252,15 @@ func (p *password) UnmarshalJSON(b []byte) error {
 		return fmt.Errorf("no password hash provided")
 	}
 
+	// If this value is a valid argon2id hash, use it.
+	if strings.HasPrefix(data.Hash, "$argon2id$") {
+		if _, err := secret.ParseArgon2id(data.Hash); err != nil {
+			return fmt.Errorf("malformed argon2id hash: %v", err)
+		}
+		p.Hash = []byte(data.Hash)
+		return nil
+	}
+
 	// If this value is a valid bcrypt, use it.
 	_, bcryptErr := bcrypt.Cost([]byte(data.Hash))
 	if bcryptErr == nil {
@@ -135,6 +290,625 @@ type OAuth2 struct {
 	AlwaysShowLoginScreen bool `json:"alwaysShowLoginScreen"`
 	// This is the connector that can be used for password grant
 	PasswordConnector string `json:"passwordConnector"`
+	// If specified, API keys can be exchanged for tokens at the token endpoint.
+	AllowAPIKeys bool `json:"allowAPIKeys"`
+	// If specified, pre-authorized codes can be exchanged for tokens at the
+	// token endpoint.
+	AllowPreAuthorizedCodes bool `json:"allowPreAuthorizedCodes"`
+	// If specified, service accounts can exchange JWT assertions for tokens.
+	AllowServiceAccounts bool `json:"allowServiceAccounts"`
+	// If specified, revoke the refresh token of a grant when reuse of one of
+	// its refresh tokens or its auth code is detected.
+	RevokeOnTokenReuse bool `json:"revokeOnTokenReuse"`
+	// Additional scopes clients are allowed to request.
+	CustomScopes []Scope `json:"customScopes"`
+	// Disable refresh tokens for matching clients, connectors and grant types.
+	OfflineAccessRules []server.OfflineAccessRule `json:"offlineAccessRules"`
+	// Decide whether refreshes contact the connector, by client and
+	// connector.
+	RefreshPolicies []RefreshPolicy `json:"refreshPolicies"`
+	// If specified, client secrets are stored hashed.
+	ClientSecretHashing Hashing `json:"clientSecretHashing"`
+}
+
+// RefreshPolicy is the config format for deciding whether refreshes contact
+// the connector. See server.RefreshPolicy for the semantics.
+type RefreshPolicy struct {
+	Clients    []string `json:"clients"`
+	Connectors []string `json:"connectors"`
+	Local      bool     `json:"local"`
+	// MaxStaleness is a duration, such as "24h".
+	MaxStaleness string `json:"maxStaleness"`
+}
+
+func (p RefreshPolicy) toServer() (server.RefreshPolicy, error) {
+	policy := server.RefreshPolicy{
+		Clients:    p.Clients,
+		Connectors: p.Connectors,
+		Local:      p.Local,
+	}
+	if p.MaxStaleness != "" {
+		if !p.Local {
+			return policy, errors.New("maxStaleness requires local refreshes")
+		}
+		d, err := time.ParseDuration(p.MaxStaleness)
+		if err != nil {
+			return policy, fmt.Errorf("invalid maxStaleness %q: %v", p.MaxStaleness, err)
+		}
+		policy.MaxStaleness = d
+	}
+	return policy, nil
+}
+
+// Hashing configures how secrets are hashed for storage.
+type Hashing struct {
+	// Algorithm is either "bcrypt" or "argon2id". Hashing is disabled if empty.
+	Algorithm string `json:"algorithm"`
+
+	// Cost of bcrypt. Defaults to 10.
+	Cost int `json:"cost"`
+
+	// Parameters of argon2id. Memory is in KiB. Default to 64 MiB, 3
+	// iterations and 4 threads.
+	Memory      uint32 `json:"memory"`
+	Iterations  uint32 `json:"iterations"`
+	Parallelism uint8  `json:"parallelism"`
+}
+
+// hasher returns the configured hasher, or nil if hashing is disabled.
+func (h Hashing) hasher() (secret.Hasher, error) {
+	switch h.Algorithm {
+	case "":
+		return nil, nil
+	case "bcrypt":
+		return secret.NewBcrypt(h.Cost)
+	case "argon2id":
+		return secret.NewArgon2id(secret.Argon2idParams{
+			Memory:      h.Memory,
+			Iterations:  h.Iterations,
+			Parallelism: h.Parallelism,
+		})
+	}
+	return nil, fmt.Errorf("unknown hashing algorithm %q", h.Algorithm)
+}
+
+// Scope is a custom scope and the description shown to users when a client
+// requests it.
+type Scope struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Client IDs added to the audience of ID tokens issued for the scope.
+	Audiences []string `json:"audiences"`
+}
+
+// TermsOfService is a document users must accept after logging in. Users are
+// prompted again whenever the version changes.
+type TermsOfService struct {
+	Version string `json:"version"`
+	URL     string `json:"url"`
+	Text    string `json:"text"`
+}
+
+// WebAuthn configures passkeys and security keys as a second factor of the
+// password db.
+type WebAuthn struct {
+	// Mode is "optional" or "required". Empty disables passkeys.
+	Mode string `json:"mode"`
+	// Relying party ID and name. The ID defaults to the issuer's host name.
+	RPID   string `json:"rpID"`
+	RPName string `json:"rpName"`
+	// Origins of the login page. Defaults to the issuer's origin.
+	Origins                 []string `json:"origins"`
+	RequireUserVerification bool     `json:"requireUserVerification"`
+}
+
+// TOTP configures authenticator app codes as a second factor of the password
+// db.
+type TOTP struct {
+	// Mode is "optional" or "required". Empty disables TOTP.
+	Mode string `json:"mode"`
+	// Issuer shown in authenticator apps, defaults to "dex".
+	Issuer string `json:"issuer"`
+	// Number of backup codes users are given when enrolling, defaults to 10.
+	BackupCodes int `json:"backupCodes"`
+}
+
+// Audit holds configuration for delivering audit events. Events are always
+// written to the log.
+type Audit struct {
+	// If specified, every audit event is also POSTed as JSON to this URL.
+	Webhook string `json:"webhook"`
+	// If specified, audit events are persisted in the storage for this
+	// duration and can be queried through the gRPC API.
+	Retention string `json:"retention"`
+	// If specified, every audit event is also published as JSON to a NATS
+	// subject.
+	NATS *AuditNATS `json:"nats"`
+	// If specified, every audit event is also produced to a Kafka topic.
+	Kafka *AuditKafka `json:"kafka"`
+}
+
+// AuditNATS configures publishing audit events to NATS.
+type AuditNATS struct {
+	// URL of the NATS server, for example "nats://nats:4222".
+	URL string `json:"url"`
+	// Defaults to "dex.audit".
+	Subject string `json:"subject"`
+}
+
+// AuditKafka configures producing audit events to Kafka through a Kafka REST
+// proxy.
+type AuditKafka struct {
+	// URL of the REST proxy, for example "http://kafka-rest:8082".
+	ProxyURL string `json:"proxyURL"`
+	// Defaults to "dex.audit".
+	Topic string `json:"topic"`
+	// Record format: "json" (default), "jsonschema" or "avro".
+	Format string `json:"format"`
+	// ID of the event schema in the schema registry. If unset, dex's event
+	// schema is registered by the proxy.
+	ValueSchemaID int `json:"valueSchemaID"`
+}
+
+// Alerts holds configuration for notifying operators when storage, signing
+// or a connector fail repeatedly. Alerts are sent to every configured
+// destination.
+type Alerts struct {
+	// Webhook receives each alert as a JSON body.
+	Webhook string `json:"webhook"`
+	// SlackWebhook is a Slack incoming webhook URL.
+	SlackWebhook string `json:"slackWebhook"`
+	// PagerDutyRoutingKey is the integration key of a PagerDuty service.
+	PagerDutyRoutingKey string `json:"pagerDutyRoutingKey"`
+
+	// Threshold is the number of failures within Window that trigger an alert.
+	Threshold int `json:"threshold"`
+	// Window is the duration failures are counted over.
+	Window string `json:"window"`
+}
+
+// WebFinger holds configuration for the WebFinger endpoint.
+type WebFinger struct {
+	// Domains of the users to point to this issuer. If empty, the endpoint
+	// answers for users of any domain.
+	Domains []string `json:"domains"`
+}
+
+// Admin holds configuration for administrative endpoints.
+type Admin struct {
+	// Token is a bearer token granting access to the detailed view of the
+	// status endpoint. Administrative views are disabled if empty.
+	Token string `json:"token"`
+
+	// HTTP is the address of a listener serving pprof profiles, expvar
+	// variables, goroutine dumps and a stream of audit events to requests
+	// carrying the token.
+	HTTP string `json:"http"`
+}
+
+// SigningMigration holds configuration for migrating to a new signing key.
+// While configured, tokens are signed by the new key and the storage keys
+// sign them in the shadow.
+type SigningMigration struct {
+	// KeyFile is a PEM encoded RSA or ECDSA private key. Migration is
+	// disabled if empty.
+	KeyFile string `json:"keyFile"`
+
+	// KeyID is published in the JWKS. Defaults to the key's thumbprint.
+	KeyID string `json:"keyID"`
+}
+
+// ClockDrift holds configuration for comparing the local clock against an
+// NTP server.
+type ClockDrift struct {
+	// NTPServer is the address of the NTP server. Checks are disabled if empty.
+	NTPServer string `json:"ntpServer"`
+
+	// Threshold is the drift beyond which a warning is reported.
+	Threshold string `json:"threshold"`
+
+	// Interval defines how often the clock is checked.
+	Interval string `json:"interval"`
+}
+
+// GroupSync holds configuration for periodically refreshing identities from
+// connectors.
+type GroupSync struct {
+	// Interval defines how often identities are refreshed. The sync is
+	// disabled if empty.
+	Interval string `json:"interval"`
+
+	// Connectors limits the sync to these connector IDs.
+	Connectors []string `json:"connectors"`
+}
+
+// IDTokenReplay is the config format for tracking issued ID tokens. See
+// server.IDTokenReplay for the semantics.
+type IDTokenReplay struct {
+	// Clients whose ID tokens are tracked. Tracking is disabled if empty.
+	Clients []string `json:"clients"`
+
+	// MaxTokens bounds the number of tracked tokens.
+	MaxTokens int `json:"maxTokens"`
+}
+
+// FailureDelay is the config format for delaying responses to failed
+// authentication attempts. See server.FailureDelay for the semantics.
+type FailureDelay struct {
+	Min string `json:"min"`
+	Max string `json:"max"`
+}
+
+func (f FailureDelay) toServer() (server.FailureDelay, error) {
+	var (
+		d   server.FailureDelay
+		err error
+	)
+	if f.Min != "" {
+		if d.Min, err = time.ParseDuration(f.Min); err != nil {
+			return d, fmt.Errorf("invalid min %q: %v", f.Min, err)
+		}
+	}
+	if f.Max != "" {
+		if d.Max, err = time.ParseDuration(f.Max); err != nil {
+			return d, fmt.Errorf("invalid max %q: %v", f.Max, err)
+		}
+	}
+	if d.Min < 0 || d.Max < d.Min {
+		return d, fmt.Errorf("min %v and max %v must satisfy 0 <= min <= max", d.Min, d.Max)
+	}
+	return d, nil
+}
+
+// LoadShedding is the config format for rejecting low-priority requests
+// while the storage is degraded. See server.LoadShedding for the semantics.
+type LoadShedding struct {
+	LatencyThreshold   string  `json:"latencyThreshold"`
+	ErrorRateThreshold float64 `json:"errorRateThreshold"`
+	Window             string  `json:"window"`
+	RetryAfter         string  `json:"retryAfter"`
+}
+
+func (l LoadShedding) toServer() (server.LoadShedding, error) {
+	d := server.LoadShedding{ErrorRateThreshold: l.ErrorRateThreshold}
+	for _, f := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"latency threshold", l.LatencyThreshold, &d.LatencyThreshold},
+		{"window", l.Window, &d.Window},
+		{"retry after", l.RetryAfter, &d.RetryAfter},
+	} {
+		if f.value == "" {
+			continue
+		}
+		v, err := time.ParseDuration(f.value)
+		if err != nil || v < 0 {
+			return d, fmt.Errorf("invalid %s %q", f.name, f.value)
+		}
+		*f.dst = v
+	}
+	if d.ErrorRateThreshold < 0 || d.ErrorRateThreshold > 1 {
+		return d, fmt.Errorf("error rate threshold %v must be between 0 and 1", d.ErrorRateThreshold)
+	}
+	return d, nil
+}
+
+// Regions is the config format for multi-region deployments. The storage
+// configured under "storage" is the primary, which takes all writes.
+type Regions struct {
+	// Primary is the region of the primary storage.
+	Primary string `json:"primary"`
+
+	// Local is the region of this instance. Environment variables are
+	// expanded, so every region can share a config file.
+	Local string `json:"local"`
+
+	// Consistency chooses the reads served by the local replica. See
+	// storage.Consistency for the modes.
+	Consistency string `json:"consistency"`
+
+	// Replicas are the storages of the other regions.
+	Replicas []Replica `json:"replicas"`
+}
+
+// Replica is the config format for the storage of a region.
+type Replica struct {
+	Region  string  `json:"region"`
+	Storage Storage `json:"storage"`
+}
+
+// localReplica returns the replica of the local region, or nil if the
+// primary is local.
+func (r Regions) localReplica() (*Replica, error) {
+	local := os.ExpandEnv(r.Local)
+	if r.Primary == "" || local == "" {
+		return nil, errors.New("primary and local regions are required")
+	}
+	if _, err := storage.ParseConsistency(r.Consistency); err != nil {
+		return nil, err
+	}
+	var replica *Replica
+	seen := map[string]bool{r.Primary: true}
+	for i, rep := range r.Replicas {
+		if rep.Storage.Config == nil {
+			return nil, fmt.Errorf("no storage for replica of region %q", rep.Region)
+		}
+		if seen[rep.Region] {
+			return nil, fmt.Errorf("duplicate region %q", rep.Region)
+		}
+		seen[rep.Region] = true
+		if rep.Region == local {
+			replica = &r.Replicas[i]
+		}
+	}
+	if replica == nil && local != r.Primary {
+		return nil, fmt.Errorf("no replica of local region %q", local)
+	}
+	return replica, nil
+}
+
+// SPIFFE is the config format for authenticating workloads with SPIFFE
+// SVIDs. See server.SPIFFEConfig for the semantics.
+type SPIFFE struct {
+	TrustDomains []SPIFFETrustDomain `json:"trustDomains"`
+	Clients      []SPIFFEClient      `json:"clients"`
+}
+
+// SPIFFETrustDomain is the config format for a SPIFFE trust domain.
+type SPIFFETrustDomain struct {
+	Name string `json:"name"`
+
+	// X509BundleFile is a PEM file of the trust domain's X.509 roots.
+	X509BundleFile string `json:"x509BundleFile"`
+
+	// JWTBundleFile is a JSON web key set file of the trust domain's JWT-SVID
+	// signing keys.
+	JWTBundleFile string `json:"jwtBundleFile"`
+}
+
+// SPIFFEClient is the config format for a client workloads authenticate as.
+type SPIFFEClient struct {
+	ClientID        string   `json:"clientID"`
+	SPIFFEIDs       []string `json:"spiffeIDs"`
+	SPIFFEIDSubject bool     `json:"spiffeIDSubject"`
+}
+
+// x509SVIDs reports whether any trust domain accepts X.509-SVIDs, which are
+// presented as TLS client certificates.
+func (s SPIFFE) x509SVIDs() bool {
+	for _, td := range s.TrustDomains {
+		if td.X509BundleFile != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (s SPIFFE) toServer() (server.SPIFFEConfig, error) {
+	c := server.SPIFFEConfig{}
+	for _, td := range s.TrustDomains {
+		std := server.SPIFFETrustDomain{Name: td.Name}
+		if td.X509BundleFile != "" {
+			data, err := ioutil.ReadFile(td.X509BundleFile)
+			if err != nil {
+				return c, fmt.Errorf("trust domain %s: %v", td.Name, err)
+			}
+			for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+				if block.Type != "CERTIFICATE" {
+					continue
+				}
+				cert, err := x509.ParseCertificate(block.Bytes)
+				if err != nil {
+					return c, fmt.Errorf("trust domain %s: %v", td.Name, err)
+				}
+				std.X509Roots = append(std.X509Roots, cert)
+			}
+			if len(std.X509Roots) == 0 {
+				return c, fmt.Errorf("trust domain %s: no certificates found in %s", td.Name, td.X509BundleFile)
+			}
+		}
+		if td.JWTBundleFile != "" {
+			data, err := ioutil.ReadFile(td.JWTBundleFile)
+			if err != nil {
+				return c, fmt.Errorf("trust domain %s: %v", td.Name, err)
+			}
+			var keys jose.JSONWebKeySet
+			if err := json.Unmarshal(data, &keys); err != nil {
+				return c, fmt.Errorf("trust domain %s: invalid JWT bundle: %v", td.Name, err)
+			}
+			std.JWTKeys = keys.Keys
+		}
+		c.TrustDomains = append(c.TrustDomains, std)
+	}
+	for _, client := range s.Clients {
+		c.Clients = append(c.Clients, server.SPIFFEClient(client))
+	}
+	return c, nil
+}
+
+// ClaimTransform is the config format for a step transforming the identities
+// returned by connectors. See server.ClaimTransform for the operations.
+type ClaimTransform struct {
+	Connectors  []string `json:"connectors"`
+	Op          string   `json:"op"`
+	Claim       string   `json:"claim"`
+	From        string   `json:"from"`
+	Value       string   `json:"value"`
+	Pattern     string   `json:"pattern"`
+	Replacement string   `json:"replacement"`
+	Prefix      string   `json:"prefix"`
+}
+
+// CredentialType is the config format for a type of verifiable credential.
+// See server.CredentialType for the semantics.
+type CredentialType struct {
+	ID           string   `json:"id"`
+	Clients      []string `json:"clients"`
+	AllowUnbound bool     `json:"allowUnbound"`
+	Format       string   `json:"format"`
+	Type         string   `json:"type"`
+	Claims       []string `json:"claims"`
+
+	// ValidFor is a duration such as "8h".
+	ValidFor string `json:"validFor"`
+}
+
+func (c CredentialType) toServer() (server.CredentialType, error) {
+	t := server.CredentialType{
+		ID:           c.ID,
+		Clients:      c.Clients,
+		AllowUnbound: c.AllowUnbound,
+		Format:       c.Format,
+		Type:         c.Type,
+		Claims:       c.Claims,
+	}
+	if c.ValidFor != "" {
+		validFor, err := time.ParseDuration(c.ValidFor)
+		if err != nil {
+			return t, fmt.Errorf("invalid validFor: %v", err)
+		}
+		t.ValidFor = validFor
+	}
+	return t, nil
+}
+
+// Authorization is the config format for an external authorizer. See
+// server.HTTPAuthorizer for the protocol.
+type Authorization struct {
+	// URL of the endpoint. If empty, no authorizer is consulted.
+	URL string `json:"url"`
+	// OPA is set if the endpoint is an Open Policy Agent data API.
+	OPA bool `json:"opa"`
+	// Timeout of calls to the endpoint, defaults to 5s.
+	Timeout string `json:"timeout"`
+}
+
+func (a Authorization) toServer() (*server.HTTPAuthorizer, error) {
+	if u, err := url.Parse(a.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid url %q, expected an http or https URL", a.URL)
+	}
+	timeout := 5 * time.Second
+	if a.Timeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(a.Timeout); err != nil {
+			return nil, fmt.Errorf("invalid timeout %q: %v", a.Timeout, err)
+		}
+	}
+	return server.NewHTTPAuthorizer(a.URL, a.OPA, timeout), nil
+}
+
+// AccessWindow is the config format for restricting when clients and users
+// can obtain tokens. See server.AccessWindow for the semantics.
+type AccessWindow struct {
+	Clients []string `json:"clients"`
+	Users   []string `json:"users"`
+
+	// Days of the week, e.g. "Mon", "Tue".
+	Days []string `json:"days"`
+	// Time of day in 24 hour "HH:MM" format.
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// IANA time zone name, defaults to UTC.
+	Timezone string `json:"timezone"`
+
+	// RFC 3339 timestamps bounding the allowed period.
+	NotBefore string `json:"notBefore"`
+	NotAfter  string `json:"notAfter"`
+
+	Message string `json:"message"`
+}
+
+// ShadowPolicies is the config format for candidate policies. See
+// server.ShadowPolicies for the semantics; a list that is set, even if
+// empty, replaces the active one in the shadow evaluation.
+type ShadowPolicies struct {
+	AccessWindows      []AccessWindow             `json:"accessWindows"`
+	OfflineAccessRules []server.OfflineAccessRule `json:"offlineAccessRules"`
+}
+
+func (p ShadowPolicies) toServer() (*server.ShadowPolicies, error) {
+	shadow := &server.ShadowPolicies{OfflineAccessRules: p.OfflineAccessRules}
+	if p.AccessWindows != nil {
+		shadow.AccessWindows = make([]server.AccessWindow, 0, len(p.AccessWindows))
+	}
+	for i, a := range p.AccessWindows {
+		window, err := a.toServer()
+		if err != nil {
+			return nil, fmt.Errorf("access window %d: %v", i, err)
+		}
+		shadow.AccessWindows = append(shadow.AccessWindows, window)
+	}
+	return shadow, nil
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func (a AccessWindow) toServer() (server.AccessWindow, error) {
+	w := server.AccessWindow{
+		Clients:  a.Clients,
+		Users:    a.Users,
+		Message:  a.Message,
+		Location: time.UTC,
+	}
+	for _, d := range a.Days {
+		day, ok := weekdays[strings.ToLower(d)]
+		if !ok {
+			return w, fmt.Errorf("invalid day %q", d)
+		}
+		w.Days = append(w.Days, day)
+	}
+	if (a.Start == "") != (a.End == "") {
+		return w, errors.New("start and end must be specified together")
+	}
+	if a.Start != "" {
+		var err error
+		if w.Start, err = parseClock(a.Start); err != nil {
+			return w, err
+		}
+		if w.End, err = parseClock(a.End); err != nil {
+			return w, err
+		}
+	}
+	if a.Timezone != "" {
+		loc, err := time.LoadLocation(a.Timezone)
+		if err != nil {
+			return w, fmt.Errorf("invalid timezone %q: %v", a.Timezone, err)
+		}
+		w.Location = loc
+	}
+	if a.NotBefore != "" {
+		t, err := time.Parse(time.RFC3339, a.NotBefore)
+		if err != nil {
+			return w, fmt.Errorf("invalid notBefore %q: %v", a.NotBefore, err)
+		}
+		w.NotBefore = t
+	}
+	if a.NotAfter != "" {
+		t, err := time.Parse(time.RFC3339, a.NotAfter)
+		if err != nil {
+			return w, fmt.Errorf("invalid notAfter %q: %v", a.NotAfter, err)
+		}
+		w.NotAfter = t
+	}
+	return w, nil
 }
 
 // Web is the config format for the HTTP server.
@@ -144,11 +918,38 @@ type Web struct {
 	TLSCert        string   `json:"tlsCert"`
 	TLSKey         string   `json:"tlsKey"`
 	AllowedOrigins []string `json:"allowedOrigins"`
+	TrustedProxies []string `json:"trustedProxies"`
+	// TrustedProxyHeader is the header trusted proxies report client
+	// addresses in. Defaults to X-Forwarded-For.
+	TrustedProxyHeader string `json:"trustedProxyHeader"`
 }
 
 // Telemetry is the config format for telemetry including the HTTP server config.
 type Telemetry struct {
 	HTTP string `json:"http"`
+
+	// MetricLabels limits the distinct values of client and connector labels.
+	MetricLabels MetricLabels `json:"metricLabels"`
+}
+
+// MetricLabels is the config format for limiting metric label values. See
+// server.MetricLabels for the semantics.
+type MetricLabels struct {
+	ClientID  MetricLabelPolicy `json:"clientID"`
+	Connector MetricLabelPolicy `json:"connector"`
+}
+
+// MetricLabelPolicy decides which values of a metric label are reported.
+type MetricLabelPolicy struct {
+	Allow     []string `json:"allow"`
+	MaxValues int      `json:"maxValues"`
+}
+
+func (m MetricLabels) toServer() server.MetricLabels {
+	return server.MetricLabels{
+		ClientID:  server.MetricLabelPolicy(m.ClientID),
+		Connector: server.MetricLabelPolicy(m.Connector),
+	}
 }
 
 // GRPC is the config for the gRPC API.
@@ -165,6 +966,124 @@ type GRPC struct {
 type Storage struct {
 	Type   string        `json:"type"`
 	Config StorageConfig `json:"config"`
+
+	// Cache configures caching reads of rarely changing objects.
+	Cache StorageCache `json:"cache"`
+
+	// Encryption configures encrypting sensitive fields at rest.
+	Encryption StorageEncryption `json:"encryption"`
+}
+
+// open opens the storage, encrypting and caching as configured. Replicas are
+// only read from, so their values are never reencrypted. They receive the
+// values the primary reencrypted through replication.
+func (s Storage) open(logger log.Logger, replica bool) (storage.Storage, error) {
+	c, err := s.Cache.toCache()
+	if err != nil {
+		return nil, fmt.Errorf("invalid cache: %v", err)
+	}
+	keys, err := s.Encryption.toKeys()
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption: %v", err)
+	}
+	st, err := s.Config.Open(logger)
+	if err != nil {
+		return nil, err
+	}
+	if st, err = encrypted.New(st, keys); err != nil {
+		return nil, err
+	}
+	if s.Encryption.Reencrypt && !replica {
+		enc := st
+		go func() {
+			n, err := encrypted.Reencrypt(context.Background(), enc)
+			if err != nil {
+				logger.Errorf("failed to reencrypt storage: %v", err)
+				return
+			}
+			logger.Infof("reencrypted %d objects with key %q", n, keys[0].ID)
+		}()
+	}
+	return cache.New(st, c), nil
+}
+
+// StorageEncryption is the config format for encrypting sensitive fields at
+// rest. See the encrypted package for the fields and format.
+type StorageEncryption struct {
+	// Keys are key encryption keys. New values are encrypted with the first
+	// key, and values encrypted with any of them can be read. To rotate
+	// keys, add a new first key, and remove the previous one once every
+	// value is encrypted with the new one.
+	Keys []EncryptionKey `json:"keys"`
+
+	// Reencrypt encrypts values with the first key on startup, if they're
+	// encrypted with another key or not at all.
+	Reencrypt bool `json:"reencrypt"`
+}
+
+// EncryptionKey is the config format for a key encryption key.
+type EncryptionKey struct {
+	ID string `json:"id"`
+
+	// Key is the base64 encoded 32 byte key.
+	Key string `json:"key"`
+	// KeyEnv names an environment variable holding the key instead.
+	KeyEnv string `json:"keyEnv"`
+}
+
+func (e StorageEncryption) toKeys() ([]encrypted.Key, error) {
+	if e.Reencrypt && len(e.Keys) == 0 {
+		return nil, errors.New("reencrypt requires keys")
+	}
+	keys := make([]encrypted.Key, len(e.Keys))
+	for i, k := range e.Keys {
+		value := k.Key
+		if k.KeyEnv != "" {
+			if k.Key != "" {
+				return nil, fmt.Errorf("key and keyEnv fields are exclusive for key %q", k.ID)
+			}
+			value = os.Getenv(k.KeyEnv)
+		}
+		key, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("key %q is not base64 encoded: %v", k.ID, err)
+		}
+		keys[i] = encrypted.Key{ID: k.ID, Key: key}
+	}
+	return keys, nil
+}
+
+// StorageCache is the config format for how long objects are cached. See
+// cache.Config for the semantics.
+type StorageCache struct {
+	Clients    string `json:"clients"`
+	Keys       string `json:"keys"`
+	Connectors string `json:"connectors"`
+	Passwords  string `json:"passwords"`
+}
+
+func (c StorageCache) toCache() (cache.Config, error) {
+	var d cache.Config
+	for _, f := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"clients", c.Clients, &d.Clients},
+		{"keys", c.Keys, &d.Keys},
+		{"connectors", c.Connectors, &d.Connectors},
+		{"passwords", c.Passwords, &d.Passwords},
+	} {
+		if f.value == "" {
+			continue
+		}
+		v, err := time.ParseDuration(f.value)
+		if err != nil || v < 0 {
+			return d, fmt.Errorf("invalid %s duration %q", f.name, f.value)
+		}
+		*f.dst = v
+	}
+	return d, nil
 }
 
 // StorageConfig is a configuration that can create a storage.
@@ -173,6 +1092,7 @@ type StorageConfig interface {
 }
 
 var storages = map[string]func() StorageConfig{
+	"dynamodb":   func() StorageConfig { return new(dynamodb.DynamoDB) },
 	"etcd":       func() StorageConfig { return new(etcd.Etcd) },
 	"kubernetes": func() StorageConfig { return new(kubernetes.Config) },
 	"memory":     func() StorageConfig { return new(memory.Config) },
@@ -185,8 +1105,10 @@ var storages = map[string]func() StorageConfig{
 // dynamically determine the type of the storage config.
 func (s *Storage) UnmarshalJSON(b []byte) error {
 	var store struct {
-		Type   string          `json:"type"`
-		Config json.RawMessage `json:"config"`
+		Type       string            `json:"type"`
+		Config     json.RawMessage   `json:"config"`
+		Cache      StorageCache      `json:"cache"`
+		Encryption StorageEncryption `json:"encryption"`
 	}
 	if err := json.Unmarshal(b, &store); err != nil {
 		return fmt.Errorf("parse storage: %v", err)
@@ -204,8 +1126,10 @@ func (s *Storage) UnmarshalJSON(b []byte) error {
 		}
 	}
 	*s = Storage{
-		Type:   store.Type,
-		Config: storageConfig,
+		Type:       store.Type,
+		Config:     storageConfig,
+		Cache:      store.Cache,
+		Encryption: store.Encryption,
 	}
 	return nil
 }
@@ -217,6 +1141,16 @@ type Connector struct {
 	Name string `json:"name"`
 	ID   string `json:"id"`
 
+	// ID of the connector users are offered if this connector fails to
+	// reach its upstream identity provider.
+	Fallback string `json:"fallback"`
+
+	// Groups filtering the users the connector logs in, and the groups
+	// issued for them. See server.GroupFilter.
+	AllowedGroups  []string `json:"allowedGroups"`
+	DeniedGroups   []string `json:"deniedGroups"`
+	RequiredGroups []string `json:"requiredGroups"`
+
 	Config server.ConnectorConfig `json:"config"`
 }
 
@@ -224,9 +1158,14 @@ type Connector struct {
 // dynamically determine the type of the connector config.
 func (c *Connector) UnmarshalJSON(b []byte) error {
 	var conn struct {
-		Type string `json:"type"`
-		Name string `json:"name"`
-		ID   string `json:"id"`
+		Type     string `json:"type"`
+		Name     string `json:"name"`
+		ID       string `json:"id"`
+		Fallback string `json:"fallback"`
+
+		AllowedGroups  []string `json:"allowedGroups"`
+		DeniedGroups   []string `json:"deniedGroups"`
+		RequiredGroups []string `json:"requiredGroups"`
 
 		Config json.RawMessage `json:"config"`
 	}
@@ -246,9 +1185,15 @@ func (c *Connector) UnmarshalJSON(b []byte) error {
 		}
 	}
 	*c = Connector{
-		Type:   conn.Type,
-		Name:   conn.Name,
-		ID:     conn.ID,
+		Type:     conn.Type,
+		Name:     conn.Name,
+		ID:       conn.ID,
+		Fallback: conn.Fallback,
+
+		AllowedGroups:  conn.AllowedGroups,
+		DeniedGroups:   conn.DeniedGroups,
+		RequiredGroups: conn.RequiredGroups,
+
 		Config: connConfig,
 	}
 	return nil
@@ -279,6 +1224,18 @@ type Expiry struct {
 
 	// AuthRequests defines the duration of time for which the AuthRequests will be valid.
 	AuthRequests string `json:"authRequests"`
+
+	// RevokedTokens defines the duration of time for which revoked refresh
+	// tokens are reported as revoked by introspection.
+	RevokedTokens string `json:"revokedTokens"`
+
+	// Sessions defines how long users stay logged in to dex across
+	// authorization requests. Sessions are disabled if unset.
+	Sessions string `json:"sessions"`
+
+	// ClockSkewTolerance defines how long after their expiry AuthRequests and
+	// AuthCodes are still accepted, to account for clock drift.
+	ClockSkewTolerance string `json:"clockSkewTolerance"`
 }
 
 // Logger holds configuration required to customize logging for dex.
@@ -288,4 +1245,8 @@ type Logger struct {
 
 	// Format specifies the format to be used for logging.
 	Format string `json:"format"`
+
+	// Redact maps kinds of personal information (email, username, subject,
+	// ip) to how they're redacted in logs (full, partial, hashed).
+	Redact log.Redaction `json:"redact"`
 }
@@ -3,14 +3,18 @@ package main
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/ghodss/yaml"
 	"github.com/kylelemons/godebug/pretty"
 
 	"github.com/dexidp/dex/connector/mock"
 	"github.com/dexidp/dex/connector/oidc"
+	"github.com/dexidp/dex/pkg/log"
 	"github.com/dexidp/dex/server"
 	"github.com/dexidp/dex/storage"
+	"github.com/dexidp/dex/storage/cache"
+	"github.com/dexidp/dex/storage/memory"
 	"github.com/dexidp/dex/storage/sql"
 )
 
@@ -375,3 +379,243 @@ logger:
 		t.Errorf("got!=want: %s", diff)
 	}
 }
+
+func TestAccessWindowToServer(t *testing.T) {
+	a := AccessWindow{
+		Clients:  []string{"contractor-app"},
+		Days:     []string{"Mon", "fri"},
+		Start:    "09:00",
+		End:      "17:30",
+		NotAfter: "2021-01-01T00:00:00Z",
+	}
+	got, err := a.toServer()
+	if err != nil {
+		t.Fatalf("failed to convert access window: %v", err)
+	}
+	want := server.AccessWindow{
+		Clients:  []string{"contractor-app"},
+		Days:     []time.Weekday{time.Monday, time.Friday},
+		Start:    9 * time.Hour,
+		End:      17*time.Hour + 30*time.Minute,
+		Location: time.UTC,
+		NotAfter: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if diff := pretty.Compare(want, got); diff != "" {
+		t.Errorf("access window did not match expected: %s", diff)
+	}
+
+	invalid := []AccessWindow{
+		{Days: []string{"Funday"}},
+		{Start: "09:00"},
+		{Start: "9am", End: "5pm"},
+		{Timezone: "Mars/Olympus_Mons"},
+		{NotBefore: "yesterday"},
+	}
+	for _, a := range invalid {
+		if _, err := a.toServer(); err == nil {
+			t.Errorf("expected error converting %+v", a)
+		}
+	}
+}
+
+func TestUnmarshalPasswordHashes(t *testing.T) {
+	tests := []struct {
+		hash    string
+		wantErr bool
+	}{
+		{hash: "$2a$10$33EMT0cVYVlPy6WAMCLsceLYjWhuHpbz5yuZxu/GAFj03J9Lytjuy"},
+		{hash: "$argon2id$v=19$m=65536,t=3,p=4$c2FsdHNhbHRzYWx0c2FsdA$a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U"},
+		{hash: "$argon2id$v=19$m=65536,t=3$c2FsdA$a2V5", wantErr: true},
+		{hash: "plaintext", wantErr: true},
+	}
+	for _, tc := range tests {
+		var p password
+		err := yaml.Unmarshal([]byte("email: jane@example.com\nhash: \""+tc.hash+"\""), &p)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected error", tc.hash)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.hash, err)
+		} else if string(p.Hash) != tc.hash {
+			t.Errorf("%s: got hash %q", tc.hash, p.Hash)
+		}
+	}
+}
+
+func TestFailureDelayToServer(t *testing.T) {
+	got, err := FailureDelay{Min: "200ms", Max: "1s"}.toServer()
+	if err != nil {
+		t.Fatalf("failed to convert failure delay: %v", err)
+	}
+	if want := (server.FailureDelay{Min: 200 * time.Millisecond, Max: time.Second}); got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	invalid := []FailureDelay{
+		{Min: "soon"},
+		{Max: "later"},
+		{Min: "1s", Max: "200ms"},
+		{Min: "-1s"},
+	}
+	for _, f := range invalid {
+		if _, err := f.toServer(); err == nil {
+			t.Errorf("expected error converting %+v", f)
+		}
+	}
+}
+
+func TestLoadSheddingToServer(t *testing.T) {
+	got, err := LoadShedding{LatencyThreshold: "250ms", ErrorRateThreshold: 0.1, RetryAfter: "1m"}.toServer()
+	if err != nil {
+		t.Fatalf("failed to convert load shedding: %v", err)
+	}
+	if want := (server.LoadShedding{LatencyThreshold: 250 * time.Millisecond, ErrorRateThreshold: 0.1, RetryAfter: time.Minute}); got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	invalid := []LoadShedding{
+		{LatencyThreshold: "slow"},
+		{Window: "-1m"},
+		{ErrorRateThreshold: 1.5},
+	}
+	for _, l := range invalid {
+		if _, err := l.toServer(); err == nil {
+			t.Errorf("expected error converting %+v", l)
+		}
+	}
+}
+
+func TestUnmarshalStorageCache(t *testing.T) {
+	rawConfig := []byte(`
+type: memory
+cache:
+  clients: 1m
+  keys: 30s
+`)
+	var s Storage
+	if err := yaml.Unmarshal(rawConfig, &s); err != nil {
+		t.Fatalf("failed to decode storage: %v", err)
+	}
+	got, err := s.Cache.toCache()
+	if err != nil {
+		t.Fatalf("failed to convert cache: %v", err)
+	}
+	if want := (cache.Config{Clients: time.Minute, Keys: 30 * time.Second}); got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	for _, c := range []StorageCache{{Clients: "forever"}, {Passwords: "-1m"}} {
+		if _, err := c.toCache(); err == nil {
+			t.Errorf("expected error converting %+v", c)
+		}
+	}
+}
+
+func TestUnmarshalConnectorGroupFilter(t *testing.T) {
+	rawConfig := []byte(`
+type: mockCallback
+id: mock
+name: Example
+allowedGroups: ["admins", "developers"]
+deniedGroups: ["contractors"]
+requiredGroups: ["developers"]
+`)
+	var c Connector
+	if err := yaml.Unmarshal(rawConfig, &c); err != nil {
+		t.Fatalf("failed to decode connector: %v", err)
+	}
+	want := Connector{
+		Type:           "mockCallback",
+		Name:           "Example",
+		ID:             "mock",
+		AllowedGroups:  []string{"admins", "developers"},
+		DeniedGroups:   []string{"contractors"},
+		RequiredGroups: []string{"developers"},
+		Config:         &mock.CallbackConfig{},
+	}
+	if diff := pretty.Compare(c, want); diff != "" {
+		t.Errorf("got!=want: %s", diff)
+	}
+}
+
+func TestStorageEncryptionToKeys(t *testing.T) {
+	os.Setenv("DEX_TEST_STORAGE_KEY", "AgICAgICAgICAgICAgICAgICAgICAgICAgICAgICAgI=")
+	defer os.Unsetenv("DEX_TEST_STORAGE_KEY")
+
+	keys, err := StorageEncryption{Keys: []EncryptionKey{
+		{ID: "new", KeyEnv: "DEX_TEST_STORAGE_KEY"},
+		{ID: "old", Key: "AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE="},
+	}}.toKeys()
+	if err != nil {
+		t.Fatalf("failed to convert keys: %v", err)
+	}
+	if len(keys) != 2 || keys[0].ID != "new" || len(keys[0].Key) != 32 || keys[0].Key[0] != 2 || keys[1].Key[0] != 1 {
+		t.Errorf("unexpected keys %+v", keys)
+	}
+
+	invalid := []StorageEncryption{
+		{Reencrypt: true},
+		{Keys: []EncryptionKey{{ID: "a", Key: "not base64!"}}},
+		{Keys: []EncryptionKey{{ID: "a", Key: "AQ==", KeyEnv: "DEX_TEST_STORAGE_KEY"}}},
+	}
+	for _, e := range invalid {
+		if _, err := e.toKeys(); err == nil {
+			t.Errorf("expected error converting %+v", e)
+		}
+	}
+}
+
+func TestRegionsLocalReplica(t *testing.T) {
+	replicas := []Replica{
+		{Region: "us-east-1", Storage: Storage{Type: "memory", Config: &memory.Config{}}},
+		{Region: "ap-south-1", Storage: Storage{Type: "memory", Config: &memory.Config{}}},
+	}
+
+	os.Setenv("DEX_TEST_REGION", "ap-south-1")
+	defer os.Unsetenv("DEX_TEST_REGION")
+	replica, err := Regions{Primary: "eu-west-1", Local: "$DEX_TEST_REGION", Replicas: replicas}.localReplica()
+	if err != nil {
+		t.Fatalf("failed to find local replica: %v", err)
+	}
+	if replica == nil || replica.Region != "ap-south-1" {
+		t.Errorf("expected replica of ap-south-1, got %+v", replica)
+	}
+
+	replica, err = Regions{Primary: "eu-west-1", Local: "eu-west-1", Consistency: "bounded", Replicas: replicas}.localReplica()
+	if err != nil {
+		t.Fatalf("failed to find local replica: %v", err)
+	}
+	if replica != nil {
+		t.Errorf("expected no replica in the primary region, got %+v", replica)
+	}
+
+	invalid := []Regions{
+		{Local: "eu-west-1", Replicas: replicas},
+		{Primary: "eu-west-1", Local: "us-west-2", Replicas: replicas},
+		{Primary: "eu-west-1", Local: "us-east-1", Consistency: "eventual", Replicas: replicas},
+		{Primary: "eu-west-1", Local: "eu-west-1", Replicas: append(replicas, Replica{Region: "eu-west-1", Storage: replicas[0].Storage})},
+		{Primary: "eu-west-1", Local: "us-east-1", Replicas: []Replica{{Region: "us-east-1"}}},
+	}
+	for _, r := range invalid {
+		if _, err := r.localReplica(); err == nil {
+			t.Errorf("expected error finding local replica of %+v", r)
+		}
+	}
+}
+
+func TestUnmarshalLoggerRedact(t *testing.T) {
+	var l Logger
+	if err := yaml.Unmarshal([]byte("level: info\nredact:\n  email: partial\n  ip: hashed\n"), &l); err != nil {
+		t.Fatal(err)
+	}
+	want := log.Redaction{log.KindEmail: log.ModePartial, log.KindIP: log.ModeHashed}
+	if diff := pretty.Compare(want, l.Redact); diff != "" {
+		t.Errorf("got!=want: %s", diff)
+	}
+	if err := l.Redact.Validate(); err != nil {
+		t.Errorf("unexpected validation error: %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ghodss/yaml"
+
+	"github.com/dexidp/dex/pkg/log"
+	"github.com/dexidp/dex/server"
+	"github.com/dexidp/dex/storage"
+)
+
+// Reload is the config format for reloading static clients, passwords and
+// connectors from the config file while serving. SIGHUP always reloads
+// them. Other changes to the config file require a restart.
+type Reload struct {
+	// Interval checks the config file for changes every interval. Unset
+	// disables watching the file.
+	Interval string `json:"interval"`
+}
+
+// staticObjects returns the static clients, passwords and connectors of the
+// config.
+func (c Config) staticObjects(logger log.Logger) (storage.StaticObjects, error) {
+	var static storage.StaticObjects
+
+	static.Clients = make([]storage.Client, len(c.StaticClients))
+	for i, client := range c.StaticClients {
+		if client.Name == "" {
+			return static, fmt.Errorf("invalid config: Name field is required for a client")
+		}
+		if client.ID == "" && client.IDEnv == "" {
+			return static, fmt.Errorf("invalid config: ID or IDEnv field is required for a client")
+		}
+		if client.IDEnv != "" {
+			if client.ID != "" {
+				return static, fmt.Errorf("invalid config: ID and IDEnv fields are exclusive for client %q", client.ID)
+			}
+			client.ID = os.Getenv(client.IDEnv)
+		}
+		if client.Secret == "" && client.SecretEnv == "" && !client.Public {
+			return static, fmt.Errorf("invalid config: Secret or SecretEnv field is required for client %q", client.ID)
+		}
+		if client.SecretEnv != "" {
+			if client.Secret != "" {
+				return static, fmt.Errorf("invalid config: Secret and SecretEnv fields are exclusive for client %q", client.ID)
+			}
+			client.Secret = os.Getenv(client.SecretEnv)
+		}
+		logger.Infof("config static client: %s", client.Name)
+		static.Clients[i] = client
+	}
+
+	static.Passwords = make([]storage.Password, len(c.StaticPasswords))
+	for i, p := range c.StaticPasswords {
+		static.Passwords[i] = storage.Password(p)
+	}
+
+	static.Connectors = make([]storage.Connector, len(c.StaticConnectors))
+	for i, c := range c.StaticConnectors {
+		if c.ID == "" || c.Name == "" || c.Type == "" {
+			return static, fmt.Errorf("invalid config: ID, Type and Name fields are required for a connector")
+		}
+		if c.Config == nil {
+			return static, fmt.Errorf("invalid config: no config field for connector %q", c.ID)
+		}
+		logger.Infof("config connector: %s", c.ID)
+
+		// convert to a storage connector object
+		conn, err := ToStorageConnector(c)
+		if err != nil {
+			return static, fmt.Errorf("failed to initialize storage connectors: %v", err)
+		}
+		static.Connectors[i] = conn
+	}
+
+	if c.EnablePasswordDB {
+		static.Connectors = append(static.Connectors, storage.Connector{
+			ID:   server.LocalConnector,
+			Name: "Email",
+			Type: server.LocalConnector,
+		})
+		logger.Infof("config connector: local passwords enabled")
+	}
+	return static, nil
+}
+
+// configReloader reloads the static objects of a config file.
+type configReloader struct {
+	configFile string
+	static     *storage.ReloadableStatic
+	logger     log.Logger
+
+	// The config file as last loaded.
+	data []byte
+}
+
+// reload reloads the static objects if the config file changed, or always
+// if force is set. Invalid configs are logged and ignored.
+func (r *configReloader) reload(force bool) {
+	data, err := ioutil.ReadFile(r.configFile)
+	if err != nil {
+		r.logger.Errorf("reload: failed to read config file %s: %v", r.configFile, err)
+		return
+	}
+	if !force && bytes.Equal(data, r.data) {
+		return
+	}
+	r.data = data
+
+	var c Config
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		r.logger.Errorf("reload: error parse config file %s: %v", r.configFile, err)
+		return
+	}
+	if err := c.Validate(); err != nil {
+		r.logger.Errorf("reload: %v", err)
+		return
+	}
+	static, err := c.staticObjects(r.logger)
+	if err != nil {
+		r.logger.Errorf("reload: %v", err)
+		return
+	}
+	r.static.Reload(static)
+	r.logger.Infof("reloaded %d static clients, %d static passwords and %d connectors from %s",
+		len(static.Clients), len(static.Passwords), len(static.Connectors), r.configFile)
+}
+
+// run reloads on SIGHUP, and on changes to the config file if interval is
+// non-zero.
+func (r *configReloader) run(interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-hup:
+			r.reload(true)
+		case <-tick:
+			r.reload(false)
+		}
+	}
+}
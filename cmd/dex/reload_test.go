@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dexidp/dex/storage"
+	"github.com/dexidp/dex/storage/memory"
+)
+
+const reloadTestConfig = `
+issuer: http://127.0.0.1:5556/dex
+storage:
+  type: memory
+web:
+  http: 127.0.0.1:5556
+staticClients:
+- id: %s
+  name: Example
+  secret: secret
+  redirectURIs: ["http://127.0.0.1:5555/callback"]
+`
+
+func TestConfigReloader(t *testing.T) {
+	ctx := context.Background()
+	logger, err := newLogger("error", "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "dex-reload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	configFile := filepath.Join(dir, "config.yaml")
+
+	write := func(content string) {
+		t.Helper()
+		if err := ioutil.WriteFile(configFile, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := &configReloader{
+		configFile: configFile,
+		static:     storage.WithReloadableStatic(memory.New(logger), storage.StaticObjects{}, logger),
+		logger:     logger,
+	}
+	clientFound := func(id string) bool {
+		t.Helper()
+		_, err := r.static.GetClient(ctx, id)
+		if err != nil && err != storage.ErrNotFound {
+			t.Fatalf("get client %q: %v", id, err)
+		}
+		return err == nil
+	}
+
+	write(fmt.Sprintf(reloadTestConfig, "first"))
+	r.reload(false)
+	if !clientFound("first") {
+		t.Errorf("expected static client of the config file to be loaded")
+	}
+
+	write(fmt.Sprintf(reloadTestConfig, "second"))
+	r.reload(false)
+	if clientFound("first") || !clientFound("second") {
+		t.Errorf("expected static clients to be replaced after the config file changed")
+	}
+
+	// Invalid configs keep the current static objects.
+	write("issuer: ''\n")
+	r.reload(true)
+	if !clientFound("second") {
+		t.Errorf("expected invalid config to be ignored")
+	}
+}
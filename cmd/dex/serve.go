@@ -2,13 +2,17 @@ package main
 
 import (
 	"context"
+	"crypto"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
 	"time"
@@ -21,9 +25,14 @@ import (
 	"github.com/spf13/cobra"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
+	"gopkg.in/square/go-jose.v2"
 
 	"github.com/dexidp/dex/api/v2"
+	"github.com/dexidp/dex/pkg/alert"
+	"github.com/dexidp/dex/pkg/audit"
 	"github.com/dexidp/dex/pkg/log"
 	"github.com/dexidp/dex/server"
 	"github.com/dexidp/dex/storage"
@@ -72,6 +81,13 @@ func serve(cmd *cobra.Command, args []string) error {
 	if c.Logger.Level != "" {
 		logger.Infof("config using log level: %s", c.Logger.Level)
 	}
+	if len(c.Logger.Redact) > 0 {
+		if err := c.Logger.Redact.Validate(); err != nil {
+			return fmt.Errorf("invalid config: logger redact: %v", err)
+		}
+		logger = log.NewRedactingLogger(logger, c.Logger.Redact)
+		logger.Infof("config redacting personal information in logs: %v", c.Logger.Redact)
+	}
 	if err := c.Validate(); err != nil {
 		return err
 	}
@@ -146,75 +162,51 @@ func serve(cmd *cobra.Command, args []string) error {
 		grpcOptions = append(grpcOptions, grpc.Creds(credentials.NewTLS(&tlsConfig)))
 	}
 
-	s, err := c.Storage.Config.Open(logger)
+	s, err := c.Storage.open(logger, false)
 	if err != nil {
 		return fmt.Errorf("failed to initialize storage: %v", err)
 	}
 	logger.Infof("config storage: %s", c.Storage.Type)
-
-	if len(c.StaticClients) > 0 {
-		for i, client := range c.StaticClients {
-			if client.Name == "" {
-				return fmt.Errorf("invalid config: Name field is required for a client")
-			}
-			if client.ID == "" && client.IDEnv == "" {
-				return fmt.Errorf("invalid config: ID or IDEnv field is required for a client")
-			}
-			if client.IDEnv != "" {
-				if client.ID != "" {
-					return fmt.Errorf("invalid config: ID and IDEnv fields are exclusive for client %q", client.ID)
-				}
-				c.StaticClients[i].ID = os.Getenv(client.IDEnv)
-			}
-			if client.Secret == "" && client.SecretEnv == "" && !client.Public {
-				return fmt.Errorf("invalid config: Secret or SecretEnv field is required for client %q", client.ID)
-			}
-			if client.SecretEnv != "" {
-				if client.Secret != "" {
-					return fmt.Errorf("invalid config: Secret and SecretEnv fields are exclusive for client %q", client.ID)
-				}
-				c.StaticClients[i].Secret = os.Getenv(client.SecretEnv)
-			}
-			logger.Infof("config static client: %s", client.Name)
-		}
-		s = storage.WithStaticClients(s, c.StaticClients)
-	}
-	if len(c.StaticPasswords) > 0 {
-		passwords := make([]storage.Password, len(c.StaticPasswords))
-		for i, p := range c.StaticPasswords {
-			passwords[i] = storage.Password(p)
-		}
-		s = storage.WithStaticPasswords(s, passwords, logger)
+	if c.Storage.Cache != (StorageCache{}) {
+		logger.Infof("config storage cache: clients %q, keys %q, connectors %q, passwords %q",
+			c.Storage.Cache.Clients, c.Storage.Cache.Keys, c.Storage.Cache.Connectors, c.Storage.Cache.Passwords)
 	}
 
-	storageConnectors := make([]storage.Connector, len(c.StaticConnectors))
-	for i, c := range c.StaticConnectors {
-		if c.ID == "" || c.Name == "" || c.Type == "" {
-			return fmt.Errorf("invalid config: ID, Type and Name fields are required for a connector")
-		}
-		if c.Config == nil {
-			return fmt.Errorf("invalid config: no config field for connector %q", c.ID)
-		}
-		logger.Infof("config connector: %s", c.ID)
-
-		// convert to a storage connector object
-		conn, err := ToStorageConnector(c)
+	if c.Regions.Primary != "" || len(c.Regions.Replicas) > 0 {
+		replica, err := c.Regions.localReplica()
 		if err != nil {
-			return fmt.Errorf("failed to initialize storage connectors: %v", err)
+			return fmt.Errorf("invalid config: regions: %v", err)
+		}
+		consistency, _ := storage.ParseConsistency(c.Regions.Consistency)
+		if replica != nil {
+			local, err := replica.Storage.open(logger, true)
+			if err != nil {
+				return fmt.Errorf("failed to initialize storage of region %q: %v", replica.Region, err)
+			}
+			s = storage.WithRegions(s, local, consistency, logger)
+			logger.Infof("config region: %s, reading from %s storage with %s consistency, writing to region %s", replica.Region, replica.Storage.Type, consistency, c.Regions.Primary)
+		} else {
+			logger.Infof("config region: %s, primary", c.Regions.Primary)
 		}
-		storageConnectors[i] = conn
 	}
 
-	if c.EnablePasswordDB {
-		storageConnectors = append(storageConnectors, storage.Connector{
-			ID:   server.LocalConnector,
-			Name: "Email",
-			Type: server.LocalConnector,
-		})
-		logger.Infof("config connector: local passwords enabled")
+	static, err := c.staticObjects(logger)
+	if err != nil {
+		return err
 	}
+	reloadable := storage.WithReloadableStatic(s, static, logger)
+	s = reloadable
 
-	s = storage.WithStaticConnectors(s, storageConnectors)
+	var reloadInterval time.Duration
+	if c.Reload.Interval != "" {
+		reloadInterval, err = time.ParseDuration(c.Reload.Interval)
+		if err != nil || reloadInterval <= 0 {
+			return fmt.Errorf("invalid config value %q for reload interval", c.Reload.Interval)
+		}
+		logger.Infof("config reloading static clients, passwords and connectors every %v if changed", reloadInterval)
+	}
+	reloader := &configReloader{configFile: configFile, static: reloadable, logger: logger, data: configData}
+	go reloader.run(reloadInterval)
 
 	if len(c.OAuth2.ResponseTypes) > 0 {
 		logger.Infof("config response types accepted: %s", c.OAuth2.ResponseTypes)
@@ -225,25 +217,246 @@ func serve(cmd *cobra.Command, args []string) error {
 	if c.OAuth2.PasswordConnector != "" {
 		logger.Infof("config using password grant connector: %s", c.OAuth2.PasswordConnector)
 	}
+	if c.OAuth2.AllowAPIKeys {
+		logger.Infof("config allowing API keys")
+	}
+	if c.OAuth2.AllowPreAuthorizedCodes {
+		logger.Infof("config allowing pre-authorized codes")
+	}
+	if c.OAuth2.AllowServiceAccounts {
+		logger.Infof("config allowing service accounts")
+	}
 	if len(c.Web.AllowedOrigins) > 0 {
 		logger.Infof("config allowed origins: %s", c.Web.AllowedOrigins)
 	}
+	if len(c.Web.TrustedProxies) > 0 {
+		logger.Infof("config trusted proxies: %s", c.Web.TrustedProxies)
+		if c.Web.TrustedProxyHeader != "" {
+			logger.Infof("config trusted proxy header: %s", c.Web.TrustedProxyHeader)
+		}
+	}
+	clientSecretHasher, err := c.OAuth2.ClientSecretHashing.hasher()
+	if err != nil {
+		return fmt.Errorf("invalid config value for oauth2 clientSecretHashing: %v", err)
+	}
+	if clientSecretHasher != nil {
+		logger.Infof("config hashing client secrets with %s", c.OAuth2.ClientSecretHashing.Algorithm)
+	}
+	passwordHasher, err := c.PasswordHashing.hasher()
+	if err != nil {
+		return fmt.Errorf("invalid config value for passwordHashing: %v", err)
+	}
+	if passwordHasher != nil {
+		logger.Infof("config rehashing passwords with %s", c.PasswordHashing.Algorithm)
+	}
 
 	// explicitly convert to UTC.
 	now := func() time.Time { return time.Now().UTC() }
 
 	serverConfig := server.Config{
-		SupportedResponseTypes: c.OAuth2.ResponseTypes,
-		SkipApprovalScreen:     c.OAuth2.SkipApprovalScreen,
-		AlwaysShowLoginScreen:  c.OAuth2.AlwaysShowLoginScreen,
-		PasswordConnector:      c.OAuth2.PasswordConnector,
-		AllowedOrigins:         c.Web.AllowedOrigins,
-		Issuer:                 c.Issuer,
-		Storage:                s,
-		Web:                    c.Frontend,
-		Logger:                 logger,
-		Now:                    now,
-		PrometheusRegistry:     prometheusRegistry,
+		SupportedResponseTypes:  c.OAuth2.ResponseTypes,
+		SkipApprovalScreen:      c.OAuth2.SkipApprovalScreen,
+		AlwaysShowLoginScreen:   c.OAuth2.AlwaysShowLoginScreen,
+		PasswordConnector:       c.OAuth2.PasswordConnector,
+		AllowAPIKeys:            c.OAuth2.AllowAPIKeys,
+		AllowPreAuthorizedCodes: c.OAuth2.AllowPreAuthorizedCodes,
+		AllowServiceAccounts:    c.OAuth2.AllowServiceAccounts,
+		RevokeOnTokenReuse:      c.OAuth2.RevokeOnTokenReuse,
+		ClientSecretHasher:      clientSecretHasher,
+		PasswordHasher:          passwordHasher,
+		MetricLabels:            c.Telemetry.MetricLabels.toServer(),
+		OfflineAccessRules:      c.OAuth2.OfflineAccessRules,
+		Features:                c.Features,
+		DiscoveryOverrides:      c.Discovery,
+		WebFingerDomains:        c.WebFinger.Domains,
+		AllowedOrigins:          c.Web.AllowedOrigins,
+		TrustedProxies:          c.Web.TrustedProxies,
+		TrustedProxyHeader:      c.Web.TrustedProxyHeader,
+		Issuer:                  c.Issuer,
+		Storage:                 s,
+		StorageType:             c.Storage.Type,
+		AdminToken:              c.Admin.Token,
+		Web:                     c.Frontend,
+		Logger:                  logger,
+		Now:                     now,
+		PrometheusRegistry:      prometheusRegistry,
+	}
+	for _, conn := range c.StaticConnectors {
+		if conn.Fallback == "" {
+			continue
+		}
+		logger.Infof("config connector %s falls back to: %s", conn.ID, conn.Fallback)
+		if serverConfig.ConnectorFallbacks == nil {
+			serverConfig.ConnectorFallbacks = make(map[string]string)
+		}
+		serverConfig.ConnectorFallbacks[conn.ID] = conn.Fallback
+	}
+	for _, conn := range c.StaticConnectors {
+		f := server.GroupFilter{
+			Allowed:  conn.AllowedGroups,
+			Denied:   conn.DeniedGroups,
+			Required: conn.RequiredGroups,
+		}
+		if len(f.Allowed) == 0 && len(f.Denied) == 0 && len(f.Required) == 0 {
+			continue
+		}
+		logger.Infof("config connector %s group filter: allowed=%q, denied=%q, required=%q", conn.ID, f.Allowed, f.Denied, f.Required)
+		if serverConfig.ConnectorGroupFilters == nil {
+			serverConfig.ConnectorGroupFilters = make(map[string]server.GroupFilter)
+		}
+		serverConfig.ConnectorGroupFilters[conn.ID] = f
+	}
+	if c.Audit.Webhook != "" {
+		logger.Infof("config audit webhook: %s", c.Audit.Webhook)
+		serverConfig.EventConsumers = append(serverConfig.EventConsumers, server.EventConsumer{
+			Name: "webhook",
+			Sink: audit.NewWebhookSink(c.Audit.Webhook),
+		})
+	}
+	if c.Audit.NATS != nil {
+		if c.Audit.NATS.URL == "" {
+			return errors.New("invalid config: audit nats requires a url")
+		}
+		subject := c.Audit.NATS.Subject
+		if subject == "" {
+			subject = "dex.audit"
+		}
+		logger.Infof("config audit nats subject: %s", subject)
+		serverConfig.EventConsumers = append(serverConfig.EventConsumers, server.EventConsumer{
+			Name: "nats",
+			Sink: audit.NewNATSSink(c.Audit.NATS.URL, subject),
+		})
+	}
+	if c.Audit.Kafka != nil {
+		if c.Audit.Kafka.ProxyURL == "" {
+			return errors.New("invalid config: audit kafka requires a proxyURL")
+		}
+		topic := c.Audit.Kafka.Topic
+		if topic == "" {
+			topic = "dex.audit"
+		}
+		sink, err := audit.NewKafkaSink(c.Audit.Kafka.ProxyURL, topic, c.Audit.Kafka.Format, c.Audit.Kafka.ValueSchemaID)
+		if err != nil {
+			return fmt.Errorf("invalid config: %v", err)
+		}
+		logger.Infof("config audit kafka topic: %s", topic)
+		serverConfig.EventConsumers = append(serverConfig.EventConsumers, server.EventConsumer{
+			Name: "kafka",
+			Sink: sink,
+		})
+	}
+	if c.Audit.Retention != "" {
+		retention, err := time.ParseDuration(c.Audit.Retention)
+		if err != nil {
+			return fmt.Errorf("invalid config value %q for audit retention: %v", c.Audit.Retention, err)
+		}
+		if retention <= 0 {
+			return fmt.Errorf("invalid config value %q for audit retention: must be positive", c.Audit.Retention)
+		}
+		logger.Infof("config audit retention: %v", retention)
+		serverConfig.AuditRetention = retention
+	}
+	var notifiers []alert.Notifier
+	if c.Alerts.Webhook != "" {
+		notifiers = append(notifiers, alert.NewWebhookNotifier(c.Alerts.Webhook))
+	}
+	if c.Alerts.SlackWebhook != "" {
+		notifiers = append(notifiers, alert.NewSlackNotifier(c.Alerts.SlackWebhook))
+	}
+	if c.Alerts.PagerDutyRoutingKey != "" {
+		notifiers = append(notifiers, alert.NewPagerDutyNotifier(c.Alerts.PagerDutyRoutingKey))
+	}
+	if len(notifiers) > 0 {
+		logger.Infof("config alerts: %d destinations", len(notifiers))
+		serverConfig.Alerts = server.Alerts{
+			Notifier:  alert.Multi(notifiers...),
+			Threshold: c.Alerts.Threshold,
+		}
+		if c.Alerts.Window != "" {
+			window, err := time.ParseDuration(c.Alerts.Window)
+			if err != nil {
+				return fmt.Errorf("invalid config value %q for alerts window: %v", c.Alerts.Window, err)
+			}
+			serverConfig.Alerts.Window = window
+		}
+	}
+	for _, scope := range c.OAuth2.CustomScopes {
+		serverConfig.CustomScopes = append(serverConfig.CustomScopes, server.Scope{
+			Name:        scope.Name,
+			Description: scope.Description,
+			Audiences:   scope.Audiences,
+		})
+	}
+	if c.TermsOfService.Version != "" {
+		logger.Infof("config terms of service version: %s", c.TermsOfService.Version)
+		serverConfig.TermsOfService = server.TermsOfService{
+			Version: c.TermsOfService.Version,
+			URL:     c.TermsOfService.URL,
+			Text:    c.TermsOfService.Text,
+		}
+	}
+	if c.WebAuthn.Mode != "" {
+		logger.Infof("config WebAuthn mode: %s", c.WebAuthn.Mode)
+		serverConfig.WebAuthn = server.WebAuthn{
+			Mode:                    c.WebAuthn.Mode,
+			RPID:                    c.WebAuthn.RPID,
+			RPName:                  c.WebAuthn.RPName,
+			Origins:                 c.WebAuthn.Origins,
+			RequireUserVerification: c.WebAuthn.RequireUserVerification,
+		}
+	}
+	if c.TOTP.Mode != "" {
+		logger.Infof("config TOTP mode: %s", c.TOTP.Mode)
+		serverConfig.TOTP = server.TOTP{
+			Mode:        c.TOTP.Mode,
+			Issuer:      c.TOTP.Issuer,
+			BackupCodes: c.TOTP.BackupCodes,
+		}
+	}
+	for i, a := range c.AccessWindows {
+		window, err := a.toServer()
+		if err != nil {
+			return fmt.Errorf("invalid config value for access window %d: %v", i, err)
+		}
+		serverConfig.AccessWindows = append(serverConfig.AccessWindows, window)
+	}
+	if len(c.ClaimTransforms) > 0 {
+		logger.Infof("config claim transforms: %d", len(c.ClaimTransforms))
+		for _, t := range c.ClaimTransforms {
+			serverConfig.ClaimTransforms = append(serverConfig.ClaimTransforms, server.ClaimTransform(t))
+		}
+	}
+	for i, ct := range c.Credentials {
+		credentialType, err := ct.toServer()
+		if err != nil {
+			return fmt.Errorf("invalid config value for credential type %d: %v", i, err)
+		}
+		logger.Infof("config credential type: %s (%s)", credentialType.ID, credentialType.Format)
+		serverConfig.CredentialTypes = append(serverConfig.CredentialTypes, credentialType)
+	}
+	for i, p := range c.OAuth2.RefreshPolicies {
+		policy, err := p.toServer()
+		if err != nil {
+			return fmt.Errorf("invalid config value for refresh policy %d: %v", i, err)
+		}
+		serverConfig.RefreshPolicies = append(serverConfig.RefreshPolicies, policy)
+	}
+	if c.ShadowPolicies != nil {
+		logger.Infof("config shadow policies enabled")
+		shadow, err := c.ShadowPolicies.toServer()
+		if err != nil {
+			return fmt.Errorf("invalid config value for shadow policies: %v", err)
+		}
+		serverConfig.ShadowPolicies = shadow
+	}
+	if c.Authorization.URL != "" {
+		authorizer, err := c.Authorization.toServer()
+		if err != nil {
+			return fmt.Errorf("invalid config value for authorization: %v", err)
+		}
+		u, _ := url.Parse(c.Authorization.URL)
+		logger.Infof("config authorization endpoint: %s (opa: %v)", u.Redacted(), c.Authorization.OPA)
+		serverConfig.Authorizer = authorizer
 	}
 	if c.Expiry.SigningKeys != "" {
 		signingKeys, err := time.ParseDuration(c.Expiry.SigningKeys)
@@ -269,6 +482,104 @@ func serve(cmd *cobra.Command, args []string) error {
 		logger.Infof("config auth requests valid for: %v", authRequests)
 		serverConfig.AuthRequestsValidFor = authRequests
 	}
+	if c.Expiry.RevokedTokens != "" {
+		revokedTokens, err := time.ParseDuration(c.Expiry.RevokedTokens)
+		if err != nil {
+			return fmt.Errorf("invalid config value %q for revoked tokens expiry: %v", c.Expiry.RevokedTokens, err)
+		}
+		logger.Infof("config revoked tokens reported for: %v", revokedTokens)
+		serverConfig.RevokedTokensValidFor = revokedTokens
+	}
+	if c.Expiry.Sessions != "" {
+		sessions, err := time.ParseDuration(c.Expiry.Sessions)
+		if err != nil {
+			return fmt.Errorf("invalid config value %q for sessions expiry: %v", c.Expiry.Sessions, err)
+		}
+		logger.Infof("config sessions valid for: %v", sessions)
+		serverConfig.SessionsValidFor = sessions
+	}
+	if c.Expiry.ClockSkewTolerance != "" {
+		clockSkew, err := time.ParseDuration(c.Expiry.ClockSkewTolerance)
+		if err != nil {
+			return fmt.Errorf("invalid config value %q for clock skew tolerance: %v", c.Expiry.ClockSkewTolerance, err)
+		}
+		logger.Infof("config clock skew tolerance: %v", clockSkew)
+		serverConfig.ClockSkewTolerance = clockSkew
+	}
+
+	if c.SigningMigration.KeyFile != "" {
+		key, err := loadSigningKey(c.SigningMigration.KeyFile, c.SigningMigration.KeyID)
+		if err != nil {
+			return fmt.Errorf("invalid config value %q for signing migration key: %v", c.SigningMigration.KeyFile, err)
+		}
+		signer, err := server.NewKeySigner(key)
+		if err != nil {
+			return fmt.Errorf("invalid config value %q for signing migration key: %v", c.SigningMigration.KeyFile, err)
+		}
+		logger.Infof("config signing migration to key %s (%s)", key.KeyID, signer.Algorithm())
+		serverConfig.MigrationSigner = signer
+	}
+	if c.ClockDrift.NTPServer != "" {
+		logger.Infof("config clock drift checks against: %s", c.ClockDrift.NTPServer)
+		serverConfig.ClockDriftCheck.NTPServer = c.ClockDrift.NTPServer
+		if c.ClockDrift.Threshold != "" {
+			threshold, err := time.ParseDuration(c.ClockDrift.Threshold)
+			if err != nil {
+				return fmt.Errorf("invalid config value %q for clock drift threshold: %v", c.ClockDrift.Threshold, err)
+			}
+			serverConfig.ClockDriftCheck.Threshold = threshold
+		}
+		if c.ClockDrift.Interval != "" {
+			interval, err := time.ParseDuration(c.ClockDrift.Interval)
+			if err != nil {
+				return fmt.Errorf("invalid config value %q for clock drift interval: %v", c.ClockDrift.Interval, err)
+			}
+			serverConfig.ClockDriftCheck.Interval = interval
+		}
+	}
+	if len(c.IDTokenReplay.Clients) > 0 {
+		logger.Infof("config ID token replay detection for clients: %s", strings.Join(c.IDTokenReplay.Clients, ", "))
+		serverConfig.IDTokenReplay = server.IDTokenReplay(c.IDTokenReplay)
+	}
+	if c.GroupSync.Interval != "" {
+		interval, err := time.ParseDuration(c.GroupSync.Interval)
+		if err != nil {
+			return fmt.Errorf("invalid config value %q for group sync interval: %v", c.GroupSync.Interval, err)
+		}
+		logger.Infof("config group sync every %s", interval)
+		serverConfig.GroupSync = server.GroupSync{Interval: interval, Connectors: c.GroupSync.Connectors}
+	}
+	if c.LoadShedding != (LoadShedding{}) {
+		loadShedding, err := c.LoadShedding.toServer()
+		if err != nil {
+			return fmt.Errorf("invalid config value for load shedding: %v", err)
+		}
+		logger.Infof("config load shedding at storage p99 latency %v or error rate %v", loadShedding.LatencyThreshold, loadShedding.ErrorRateThreshold)
+		serverConfig.LoadShedding = loadShedding
+	}
+	if len(c.Priorities) > 0 {
+		logger.Infof("config endpoint priorities: %v", c.Priorities)
+		serverConfig.Priorities = c.Priorities
+	}
+	if c.FailureDelay != (FailureDelay{}) {
+		failureDelay, err := c.FailureDelay.toServer()
+		if err != nil {
+			return fmt.Errorf("invalid config value for failure delay: %v", err)
+		}
+		logger.Infof("config delaying failed authentication by %v to %v", failureDelay.Min, failureDelay.Max)
+		serverConfig.FailureDelay = failureDelay
+	}
+
+	if len(c.SPIFFE.TrustDomains) > 0 {
+		spiffe, err := c.SPIFFE.toServer()
+		if err != nil {
+			return fmt.Errorf("invalid config value for spiffe: %v", err)
+		}
+		for _, td := range spiffe.TrustDomains {
+			logger.Infof("config spiffe trust domain: %s", td.Name)
+		}
+		serverConfig.SPIFFE = spiffe
+	}
 
 	serv, err := server.NewServer(context.Background(), serverConfig)
 	if err != nil {
@@ -278,7 +589,7 @@ func serve(cmd *cobra.Command, args []string) error {
 	telemetryServ := http.NewServeMux()
 	telemetryServ.Handle("/metrics", promhttp.HandlerFor(prometheusRegistry, promhttp.HandlerOpts{}))
 
-	errc := make(chan error, 3)
+	errc := make(chan error, 5)
 	if c.Telemetry.HTTP != "" {
 		logger.Infof("listening (http/telemetry) on %s", c.Telemetry.HTTP)
 		go func() {
@@ -286,6 +597,13 @@ func serve(cmd *cobra.Command, args []string) error {
 			errc <- fmt.Errorf("listening on %s failed: %v", c.Telemetry.HTTP, err)
 		}()
 	}
+	if c.Admin.HTTP != "" {
+		logger.Infof("listening (http/admin) on %s", c.Admin.HTTP)
+		go func() {
+			err := http.ListenAndServe(c.Admin.HTTP, serv.AdminHandler())
+			errc <- fmt.Errorf("listening on %s failed: %v", c.Admin.HTTP, err)
+		}()
+	}
 	if c.Web.HTTP != "" {
 		logger.Infof("listening (http) on %s", c.Web.HTTP)
 		go func() {
@@ -303,6 +621,11 @@ func serve(cmd *cobra.Command, args []string) error {
 				MinVersion:               tls.VersionTLS12,
 			},
 		}
+		if c.SPIFFE.x509SVIDs() {
+			// Client certificates are verified against the trust bundle of
+			// the SPIFFE ID's trust domain by the token endpoint.
+			httpsSrv.TLSConfig.ClientAuth = tls.RequestClientCert
+		}
 
 		logger.Infof("listening (https) on %s", c.Web.HTTPS)
 		go func() {
@@ -318,13 +641,8 @@ func serve(cmd *cobra.Command, args []string) error {
 				if err != nil {
 					return fmt.Errorf("listening on %s failed: %v", c.GRPC.Addr, err)
 				}
-				s := grpc.NewServer(grpcOptions...)
-				api.RegisterDexServer(s, server.NewAPI(serverConfig.Storage, logger))
+				s := newGRPCServer(c.GRPC, grpcOptions, server.NewAPI(serverConfig.Storage, logger, serverConfig.Features, serverConfig.ClientSecretHasher, serv.Events(), serverConfig.RevokedTokensValidFor), logger)
 				grpcMetrics.InitializeMetrics(s)
-				if c.GRPC.Reflection {
-					logger.Info("enabling reflection in grpc service")
-					reflection.Register(s)
-				}
 				err = s.Serve(list)
 				return fmt.Errorf("listening on %s failed: %v", c.GRPC.Addr, err)
 			}()
@@ -334,11 +652,66 @@ func serve(cmd *cobra.Command, args []string) error {
 	return <-errc
 }
 
+// newGRPCServer returns a server for the gRPC API. It also serves the
+// standard health service, for gRPC probes, and reflection if enabled.
+func newGRPCServer(c GRPC, opts []grpc.ServerOption, dex api.DexServer, logger log.Logger) *grpc.Server {
+	s := grpc.NewServer(opts...)
+	api.RegisterDexServer(s, dex)
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus("api.Dex", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(s, healthServer)
+
+	if c.Reflection {
+		logger.Info("enabling reflection in grpc service")
+		reflection.Register(s)
+	}
+	return s
+}
+
 var (
 	logLevels  = []string{"debug", "info", "error"}
 	logFormats = []string{"json", "text"}
 )
 
+// loadSigningKey reads a PEM encoded RSA or ECDSA private key.
+func loadSigningKey(path, keyID string) (*jose.JSONWebKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	jwk := &jose.JSONWebKey{Key: key, KeyID: keyID, Use: "sig"}
+	if jwk.KeyID == "" {
+		thumbprint, err := jwk.Thumbprint(crypto.SHA256)
+		if err != nil {
+			return nil, err
+		}
+		jwk.KeyID = hex.EncodeToString(thumbprint)
+	}
+	return jwk, nil
+}
+
 type utcFormatter struct {
 	f logrus.Formatter
 }
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+
+	"github.com/dexidp/dex/api/v2"
+)
+
+func TestGRPCServerServices(t *testing.T) {
+	logger, err := newLogger("error", "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newGRPCServer(GRPC{Reflection: true}, nil, &api.UnimplementedDexServer{}, logger)
+	go s.Serve(list)
+	defer s.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, list.Addr().String(), grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	for _, service := range []string{"", "api.Dex"} {
+		resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			t.Fatalf("health check %q: %v", service, err)
+		}
+		if resp.Status != healthpb.HealthCheckResponse_SERVING {
+			t.Errorf("expected %q to be serving, got %s", service, resp.Status)
+		}
+	}
+
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		t.Fatalf("reflection: %v", err)
+	}
+	err = stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	})
+	if err != nil {
+		t.Fatalf("send reflection request: %v", err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("receive reflection response: %v", err)
+	}
+	services := map[string]bool{}
+	for _, svc := range resp.GetListServicesResponse().GetService() {
+		services[svc.Name] = true
+	}
+	for _, want := range []string{"api.Dex", "grpc.health.v1.Health"} {
+		if !services[want] {
+			t.Errorf("expected reflection to list %s, got %v", want, services)
+		}
+	}
+}
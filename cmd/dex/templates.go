@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/dexidp/dex/server"
+)
+
+func commandTemplates() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "templates",
+		Short: "Manage the web templates.",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+			os.Exit(2)
+		},
+	}
+	cmd.AddCommand(commandTemplatesValidate())
+	return cmd
+}
+
+func commandTemplatesValidate() *cobra.Command {
+	var (
+		web       server.WebConfig
+		issuerURL string
+	)
+	cmd := &cobra.Command{
+		Use:     "validate",
+		Short:   "Render the web templates with sample data and report templates which fail.",
+		Example: "dex templates validate --web-dir ./web --templates-dir ./my-templates",
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) != 0 {
+				fmt.Fprintln(os.Stderr, "surplus arguments")
+				os.Exit(2)
+			}
+			errs := server.ValidateTemplates(web, issuerURL)
+			for _, err := range errs {
+				fmt.Fprintln(os.Stderr, err)
+			}
+			if len(errs) > 0 {
+				os.Exit(1)
+			}
+			fmt.Println("templates are valid")
+		},
+	}
+	flags := cmd.Flags()
+	flags.StringVar(&web.Dir, "web-dir", "./web", "Web directory, containing the static, templates and themes directories.")
+	flags.StringVar(&web.TemplatesDir, "templates-dir", "", "Templates directory. Defaults to ( web dir )/templates.")
+	flags.StringVar(&web.Theme, "theme", "coreos", "Theme to render the templates with.")
+	flags.StringVar(&web.Issuer, "issuer", "dex", "Issuer name shown by the templates.")
+	flags.StringVar(&issuerURL, "issuer-url", "http://127.0.0.1:5556/dex", "Issuer URL the templates are served under.")
+	return cmd
+}
@@ -135,7 +135,7 @@ func (c *crowdConnector) Login(ctx context.Context, s connector.Scopes, username
 	if s.Groups {
 		userGroups, err := c.getGroups(ctx, client, s.Groups, ident.Username)
 		if err != nil {
-			return connector.Identity{}, false, fmt.Errorf("crowd: failed to query groups: %v", err)
+			return connector.Identity{}, false, fmt.Errorf("crowd: failed to query groups: %w", err)
 		}
 		ident.Groups = userGroups
 	}
@@ -162,7 +162,7 @@ func (c *crowdConnector) Refresh(ctx context.Context, s connector.Scopes, ident
 
 	user, err := c.user(ctx, client, data.Username)
 	if err != nil {
-		return ident, fmt.Errorf("crowd: get user %q: %v", data.Username, err)
+		return ident, log.PIIErrorf("crowd: get user %q: %v", log.Username(data.Username), err)
 	}
 
 	newIdent, err := c.identityFromCrowdUser(user)
@@ -180,7 +180,7 @@ func (c *crowdConnector) Refresh(ctx context.Context, s connector.Scopes, ident
 	if s.Groups {
 		userGroups, err := c.getGroups(ctx, client, s.Groups, newIdent.Username)
 		if err != nil {
-			return connector.Identity{}, fmt.Errorf("crowd: failed to query groups: %v", err)
+			return connector.Identity{}, fmt.Errorf("crowd: failed to query groups: %w", err)
 		}
 		newIdent.Groups = userGroups
 	}
@@ -400,7 +400,7 @@ func (c *crowdConnector) getGroups(ctx context.Context, client *http.Client, gro
 	if len(c.Groups) > 0 {
 		filteredGroups := groups.Filter(crowdGroups, c.Groups)
 		if len(filteredGroups) == 0 {
-			return nil, fmt.Errorf("crowd: user %q is not in any of the required groups", userLogin)
+			return nil, log.PIIErrorf("crowd: user %q is not in any of the required groups", log.Username(userLogin))
 		}
 		return filteredGroups, nil
 	} else if groupScope {
@@ -352,7 +352,7 @@ func (b *bitbucketConnector) getGroups(ctx context.Context, client *http.Client,
 	if len(b.teams) > 0 {
 		filteredTeams := groups.Filter(bitbucketTeams, b.teams)
 		if len(filteredTeams) == 0 {
-			return nil, fmt.Errorf("bitbucket: user %q is not in any of the required teams", userLogin)
+			return nil, log.PIIErrorf("bitbucket: user %q is not in any of the required teams", log.Username(userLogin))
 		}
 		return filteredTeams, nil
 	} else if groupScope {
@@ -4,6 +4,7 @@ package connector
 import (
 	"context"
 	"net/http"
+	"time"
 )
 
 // Connector is a mechanism for federating login to a remote identity service.
@@ -36,6 +37,11 @@ type Identity struct {
 	//
 	// This data is never shared with end users, OAuth clients, or through the API.
 	ConnectorData []byte
+
+	// MayAct is the subject of the "may_act" claim of an exchanged token, the
+	// only party allowed to act on behalf of this identity. Only set by
+	// TokenIdentityConnector.
+	MayAct string
 }
 
 // PasswordConnector is an interface implemented by connectors which take a
@@ -91,6 +97,32 @@ type SAMLConnector interface {
 	HandlePOST(s Scopes, samlResponse, inResponseTo string) (identity Identity, err error)
 }
 
+// LinkConnector is an interface implemented by passwordless connectors which
+// email users a single-use link to log in with. The server creates, stores
+// and checks the links, the connector only delivers them.
+type LinkConnector interface {
+	// Identity returns the identity of the owner of an email address. It
+	// returns an error if the address is invalid or not allowed to log in.
+	Identity(email string) (Identity, error)
+
+	// SendLink emails a login link to an address accepted by Identity.
+	SendLink(ctx context.Context, email, link string) error
+
+	// Limits returns how long links are valid and how often they may be sent.
+	Limits() LinkLimits
+}
+
+// LinkLimits restricts the links sent by a LinkConnector.
+type LinkLimits struct {
+	// ValidFor is how long a link can be used after it's sent.
+	ValidFor time.Duration
+	// ResendAfter is how long a user must wait before another link is sent
+	// to the same address.
+	ResendAfter time.Duration
+	// PerHour is the maximum number of links sent to an address in an hour.
+	PerHour int
+}
+
 // RefreshConnector is a connector that can update the client claims.
 type RefreshConnector interface {
 	// Refresh is called when a client attempts to claim a refresh token. The
@@ -98,3 +130,19 @@ type RefreshConnector interface {
 	// changes since the token was last refreshed.
 	Refresh(ctx context.Context, s Scopes, identity Identity) (Identity, error)
 }
+
+// Token types of OAuth 2.0 Token Exchange, see RFC 8693 section 3.
+const (
+	TokenTypeAccessToken = "urn:ietf:params:oauth:token-type:access_token"
+	TokenTypeIDToken     = "urn:ietf:params:oauth:token-type:id_token"
+)
+
+// TokenIdentityConnector is a connector that can validate tokens issued by
+// its upstream provider, letting clients exchange them for tokens issued by
+// the server.
+type TokenIdentityConnector interface {
+	// TokenIdentity returns the identity of the subject of a token of the
+	// given type, one of the TokenType constants. It returns an error if the
+	// token is invalid or of an unsupported type.
+	TokenIdentity(ctx context.Context, tokenType, token string) (Identity, error)
+}
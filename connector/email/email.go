@@ -0,0 +1,215 @@
+// Package email implements a passwordless connector which emails users a
+// single-use link to log in with.
+package email
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/dexidp/dex/connector"
+	"github.com/dexidp/dex/pkg/log"
+)
+
+const (
+	defaultLinkValidFor    = 10 * time.Minute
+	defaultResendAfter     = time.Minute
+	defaultMaxLinksPerHour = 5
+	defaultSubject         = "Your login link"
+)
+
+// Config holds the configuration parameters for the email connector.
+//
+// An example config:
+//
+//     type: email
+//     config:
+//       host: smtp.example.com:587
+//       username: dex
+//       password: smtp-password
+//       from: dex@example.com
+//       # Only addresses of these domains can log in.
+//       allowedDomains:
+//       - example.com
+//       linkValidFor: 10m
+//       resendAfter: 1m
+//       maxLinksPerHour: 5
+//
+type Config struct {
+	// Host is the "host:port" of the SMTP server mail is sent through. The
+	// connection is upgraded with STARTTLS if the server supports it.
+	Host string `json:"host"`
+
+	// Username and Password authenticate to the SMTP server, if set.
+	Username string `json:"username"`
+	Password string `json:"password"`
+
+	// From is the sender address of the emails.
+	From string `json:"from"`
+
+	// Subject is the subject of the emails. Defaults to "Your login link".
+	Subject string `json:"subject"`
+
+	// AllowedDomains restricts the addresses which can log in to these
+	// domains. If empty, any address can log in.
+	AllowedDomains []string `json:"allowedDomains"`
+
+	// LinkValidFor is how long a link can be used. Defaults to 10m.
+	LinkValidFor string `json:"linkValidFor"`
+
+	// ResendAfter is how long a user must wait before another link is sent
+	// to the same address. Defaults to 1m.
+	ResendAfter string `json:"resendAfter"`
+
+	// MaxLinksPerHour is the maximum number of links sent to an address in
+	// an hour. Defaults to 5.
+	MaxLinksPerHour int `json:"maxLinksPerHour"`
+}
+
+// sendFunc sends an email, it has the signature of smtp.SendMail.
+type sendFunc func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+
+// Open returns a connector which emails login links.
+func (c *Config) Open(id string, logger log.Logger) (connector.Connector, error) {
+	return c.open(logger, smtp.SendMail)
+}
+
+func (c *Config) open(logger log.Logger, send sendFunc) (*emailConnector, error) {
+	if c.Host == "" {
+		return nil, errors.New("email: no SMTP host specified")
+	}
+	host, _, err := net.SplitHostPort(c.Host)
+	if err != nil {
+		return nil, fmt.Errorf("email: invalid SMTP host %q: %v", c.Host, err)
+	}
+	from, err := mail.ParseAddress(c.From)
+	if err != nil {
+		return nil, fmt.Errorf("email: invalid from address %q: %v", c.From, err)
+	}
+
+	limits := connector.LinkLimits{
+		ValidFor:    defaultLinkValidFor,
+		ResendAfter: defaultResendAfter,
+		PerHour:     defaultMaxLinksPerHour,
+	}
+	if c.LinkValidFor != "" {
+		if limits.ValidFor, err = time.ParseDuration(c.LinkValidFor); err != nil || limits.ValidFor <= 0 {
+			return nil, fmt.Errorf("email: invalid linkValidFor %q", c.LinkValidFor)
+		}
+	}
+	if c.ResendAfter != "" {
+		if limits.ResendAfter, err = time.ParseDuration(c.ResendAfter); err != nil || limits.ResendAfter < 0 {
+			return nil, fmt.Errorf("email: invalid resendAfter %q", c.ResendAfter)
+		}
+	}
+	if c.MaxLinksPerHour < 0 {
+		return nil, fmt.Errorf("email: invalid maxLinksPerHour %d", c.MaxLinksPerHour)
+	}
+	if c.MaxLinksPerHour > 0 {
+		limits.PerHour = c.MaxLinksPerHour
+	}
+
+	conn := &emailConnector{
+		host:    c.Host,
+		from:    from,
+		subject: c.Subject,
+		limits:  limits,
+		send:    send,
+		logger:  logger,
+	}
+	if conn.subject == "" {
+		conn.subject = defaultSubject
+	}
+	if c.Username != "" {
+		conn.auth = smtp.PlainAuth("", c.Username, c.Password, host)
+	}
+	for _, d := range c.AllowedDomains {
+		conn.allowedDomains = append(conn.allowedDomains, strings.ToLower(d))
+	}
+	return conn, nil
+}
+
+type emailConnector struct {
+	host           string
+	auth           smtp.Auth
+	from           *mail.Address
+	subject        string
+	allowedDomains []string
+	limits         connector.LinkLimits
+
+	send   sendFunc
+	logger log.Logger
+}
+
+var (
+	_ connector.LinkConnector    = (*emailConnector)(nil)
+	_ connector.RefreshConnector = (*emailConnector)(nil)
+)
+
+// Identity returns the identity of the owner of the address. Opening the link
+// proves the user controls the address, so the email is verified.
+func (c *emailConnector) Identity(email string) (connector.Identity, error) {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Name != "" || addr.Address != email {
+		return connector.Identity{}, log.PIIErrorf("invalid email address %q", log.Email(email))
+	}
+	email = strings.ToLower(email)
+	if len(c.allowedDomains) > 0 {
+		domain := email[strings.LastIndex(email, "@")+1:]
+		allowed := false
+		for _, d := range c.allowedDomains {
+			if domain == d {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return connector.Identity{}, fmt.Errorf("email domain %q is not allowed", domain)
+		}
+	}
+	return connector.Identity{
+		UserID:        email,
+		Username:      email,
+		Email:         email,
+		EmailVerified: true,
+	}, nil
+}
+
+// SendLink emails the login link to the address.
+func (c *emailConnector) SendLink(ctx context.Context, email, link string) error {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", c.from.String())
+	fmt.Fprintf(&msg, "To: %s\r\n", email)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", c.subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString("Open the link below to log in. It can only be used once and expires in ")
+	msg.WriteString(c.limits.ValidFor.String())
+	msg.WriteString(".\r\n\r\n")
+	msg.WriteString(link)
+	msg.WriteString("\r\n\r\nIf you didn't try to log in, you can ignore this email.\r\n")
+
+	if err := c.send(c.host, c.auth, c.from.Address, []string{email}, msg.Bytes()); err != nil {
+		return fmt.Errorf("email: send login link: %v", err)
+	}
+	return nil
+}
+
+// Refresh checks that the address is still allowed to log in.
+func (c *emailConnector) Refresh(ctx context.Context, s connector.Scopes, identity connector.Identity) (connector.Identity, error) {
+	return c.Identity(identity.Email)
+}
+
+// Limits returns how long links are valid and how often they may be sent.
+func (c *emailConnector) Limits() connector.LinkLimits {
+	return c.limits
+}
@@ -0,0 +1,138 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/dexidp/dex/connector"
+)
+
+var logger = &logrus.Logger{Out: ioutil.Discard, Formatter: &logrus.TextFormatter{}}
+
+func TestOpen(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{"valid", Config{Host: "smtp.example.com:25", From: "Dex <dex@example.com>"}, false},
+		{"no host", Config{From: "dex@example.com"}, true},
+		{"no port", Config{Host: "smtp.example.com", From: "dex@example.com"}, true},
+		{"invalid from", Config{Host: "smtp.example.com:25", From: "dex"}, true},
+		{"invalid validity", Config{Host: "smtp.example.com:25", From: "dex@example.com", LinkValidFor: "0s"}, true},
+		{"invalid resend", Config{Host: "smtp.example.com:25", From: "dex@example.com", ResendAfter: "soon"}, true},
+		{"invalid rate", Config{Host: "smtp.example.com:25", From: "dex@example.com", MaxLinksPerHour: -1}, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.config.Open("email", logger)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("expected error %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+
+	c, err := (&Config{Host: "smtp.example.com:25", From: "dex@example.com"}).open(logger, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	limits := c.Limits()
+	if limits.ValidFor != defaultLinkValidFor || limits.ResendAfter != defaultResendAfter || limits.PerHour != defaultMaxLinksPerHour {
+		t.Errorf("unexpected default limits %+v", limits)
+	}
+}
+
+func TestIdentity(t *testing.T) {
+	c, err := (&Config{
+		Host:           "smtp.example.com:25",
+		From:           "dex@example.com",
+		AllowedDomains: []string{"Example.com"},
+	}).open(logger, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ident, err := c.Identity("Jane@example.COM")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ident.UserID != "jane@example.com" || ident.Email != "jane@example.com" || !ident.EmailVerified {
+		t.Errorf("unexpected identity %+v", ident)
+	}
+
+	if _, err := c.Refresh(context.Background(), connector.Scopes{}, ident); err != nil {
+		t.Errorf("expected refresh of an allowed address to succeed: %v", err)
+	}
+	c.allowedDomains = []string{"example.org"}
+	if _, err := c.Refresh(context.Background(), connector.Scopes{}, ident); err == nil {
+		t.Errorf("expected refresh to fail once the domain is no longer allowed")
+	}
+	c.allowedDomains = []string{"example.com"}
+
+	for _, email := range []string{
+		"jane",
+		"Jane <jane@example.com>",
+		"jane@example.com\r\nBcc: eve@example.org",
+		"jane@example.org",
+		"jane@sub.example.com",
+	} {
+		if _, err := c.Identity(email); err == nil {
+			t.Errorf("expected %q to be rejected", email)
+		}
+	}
+}
+
+func TestSendLink(t *testing.T) {
+	var (
+		gotAddr string
+		gotFrom string
+		gotTo   []string
+		gotMsg  string
+	)
+	send := func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		gotAddr, gotFrom, gotTo, gotMsg = addr, from, to, string(msg)
+		return nil
+	}
+	c, err := (&Config{
+		Host:         "smtp.example.com:587",
+		From:         "Dex <dex@example.com>",
+		Subject:      "Log in to Example",
+		LinkValidFor: "5m",
+	}).open(logger, send)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	link := "https://dex.example.com/link?token=abc"
+	if err := c.SendLink(context.Background(), "jane@example.com", link); err != nil {
+		t.Fatal(err)
+	}
+	if gotAddr != "smtp.example.com:587" || gotFrom != "dex@example.com" || len(gotTo) != 1 || gotTo[0] != "jane@example.com" {
+		t.Errorf("unexpected envelope %q %q %q", gotAddr, gotFrom, gotTo)
+	}
+	for _, want := range []string{
+		"From: \"Dex\" <dex@example.com>\r\n",
+		"To: jane@example.com\r\n",
+		"Subject: Log in to Example\r\n",
+		link,
+		"expires in " + (5 * time.Minute).String(),
+	} {
+		if !strings.Contains(gotMsg, want) {
+			t.Errorf("expected message to contain %q, got %q", want, gotMsg)
+		}
+	}
+
+	c.send = func(string, smtp.Auth, string, []string, []byte) error {
+		return errors.New("connection refused")
+	}
+	if err := c.SendLink(context.Background(), "jane@example.com", link); err == nil {
+		t.Errorf("expected send error to be returned")
+	}
+}
@@ -378,7 +378,7 @@ func (c *githubConnector) groupsForOrgs(ctx context.Context, client *http.Client
 		if len(org.Teams) == 0 {
 			inOrgNoTeams = true
 		} else if teams = groups_pkg.Filter(teams, org.Teams); len(teams) == 0 {
-			c.logger.Infof("github: user %q in org %q but no teams", userName, org.Name)
+			c.logger.Infof("github: user %q in org %q but no teams", log.Username(userName), org.Name)
 		}
 
 		for _, teamName := range teams {
@@ -388,7 +388,7 @@ func (c *githubConnector) groupsForOrgs(ctx context.Context, client *http.Client
 	if inOrgNoTeams || len(groups) > 0 {
 		return groups, nil
 	}
-	return groups, fmt.Errorf("github: user %q not in required orgs or teams", userName)
+	return groups, log.PIIErrorf("github: user %q not in required orgs or teams", log.Username(userName))
 }
 
 func (c *githubConnector) userGroups(ctx context.Context, client *http.Client) ([]string, error) {
@@ -639,7 +639,7 @@ func (c *githubConnector) userInOrg(ctx context.Context, client *http.Client, us
 	switch resp.StatusCode {
 	case http.StatusNoContent:
 	case http.StatusFound, http.StatusNotFound:
-		c.logger.Infof("github: user %q not in org %q or application not authorized to read org data", userName, orgName)
+		c.logger.Infof("github: user %q not in org %q or application not authorized to read org data", log.Username(userName), orgName)
 	default:
 		err = fmt.Errorf("github: unexpected return status: %q", resp.Status)
 	}
@@ -160,7 +160,7 @@ func (c *gitlabConnector) HandleCallback(s connector.Scopes, r *http.Request) (i
 	if c.groupsRequired(s.Groups) {
 		groups, err := c.getGroups(ctx, client, s.Groups, user.Username)
 		if err != nil {
-			return identity, fmt.Errorf("gitlab: get groups: %v", err)
+			return identity, fmt.Errorf("gitlab: get groups: %w", err)
 		}
 		identity.Groups = groups
 	}
@@ -204,7 +204,7 @@ func (c *gitlabConnector) Refresh(ctx context.Context, s connector.Scopes, ident
 	if c.groupsRequired(s.Groups) {
 		groups, err := c.getGroups(ctx, client, s.Groups, user.Username)
 		if err != nil {
-			return ident, fmt.Errorf("gitlab: get groups: %v", err)
+			return ident, fmt.Errorf("gitlab: get groups: %w", err)
 		}
 		ident.Groups = groups
 	}
@@ -289,7 +289,7 @@ func (c *gitlabConnector) getGroups(ctx context.Context, client *http.Client, gr
 	if len(c.groups) > 0 {
 		filteredGroups := groups.Filter(gitlabGroups, c.groups)
 		if len(filteredGroups) == 0 {
-			return nil, fmt.Errorf("gitlab: user %q is not in any of the required groups", userLogin)
+			return nil, log.PIIErrorf("gitlab: user %q is not in any of the required groups", log.Username(userLogin))
 		}
 		return filteredGroups, nil
 	} else if groupScope {
@@ -177,7 +177,7 @@ func (p *conn) Refresh(
 		return identity, err
 	}
 	if !ok {
-		return identity, fmt.Errorf("keystone: user %q does not exist", identity.UserID)
+		return identity, log.PIIErrorf("keystone: user %q does not exist", log.Subject(identity.UserID))
 	}
 	if scopes.Groups {
 		groups, err := p.getUserGroups(ctx, identity.UserID, token)
@@ -285,7 +285,7 @@ func (p *conn) getUserGroups(ctx context.Context, userID string, token string) (
 	req = req.WithContext(ctx)
 	resp, err := client.Do(req)
 	if err != nil {
-		p.Logger.Errorf("keystone: error while fetching user %q groups\n", userID)
+		p.Logger.Errorf("keystone: error while fetching user %q groups\n", log.Subject(userID))
 		return nil, err
 	}
 
@@ -390,7 +390,7 @@ func (c *ldapConnector) identityFromEntry(user ldap.Entry) (ident connector.Iden
 	ident.EmailVerified = true
 
 	if len(missing) != 0 {
-		err := fmt.Errorf("ldap: entry %q missing following required attribute(s): %q", user.DN, missing)
+		err := log.PIIErrorf("ldap: entry %q missing following required attribute(s): %q", log.Username(user.DN), missing)
 		return connector.Identity{}, err
 	}
 	return ident, nil
@@ -427,23 +427,24 @@ func (c *ldapConnector) userEntry(conn *ldap.Conn, username string) (user ldap.E
 		req.Attributes = append(req.Attributes, c.UserSearch.PreferredUsernameAttrAttr)
 	}
 
+	// Search filters contain the username.
 	c.logger.Infof("performing ldap search %s %s %s",
-		req.BaseDN, scopeString(req.Scope), req.Filter)
+		req.BaseDN, scopeString(req.Scope), log.Username(req.Filter))
 	resp, err := conn.Search(req)
 	if err != nil {
-		return ldap.Entry{}, false, fmt.Errorf("ldap: search with filter %q failed: %v", req.Filter, err)
+		return ldap.Entry{}, false, log.PIIErrorf("ldap: search with filter %q failed: %v", log.Username(req.Filter), err)
 	}
 
 	switch n := len(resp.Entries); n {
 	case 0:
-		c.logger.Errorf("ldap: no results returned for filter: %q", filter)
+		c.logger.Errorf("ldap: no results returned for filter: %q", log.Username(filter))
 		return ldap.Entry{}, false, nil
 	case 1:
 		user = *resp.Entries[0]
-		c.logger.Infof("username %q mapped to entry %s", username, user.DN)
+		c.logger.Infof("username %q mapped to entry %s", log.Username(username), log.Username(user.DN))
 		return user, true, nil
 	default:
-		return ldap.Entry{}, false, fmt.Errorf("ldap: filter returned multiple (%d) results: %q", n, filter)
+		return ldap.Entry{}, false, log.PIIErrorf("ldap: filter returned multiple (%d) results: %q", n, log.Username(filter))
 	}
 }
 
@@ -477,16 +478,16 @@ func (c *ldapConnector) Login(ctx context.Context, s connector.Scopes, username,
 			if ldapErr, ok := err.(*ldap.Error); ok {
 				switch ldapErr.ResultCode {
 				case ldap.LDAPResultInvalidCredentials:
-					c.logger.Errorf("ldap: invalid password for user %q", user.DN)
+					c.logger.Errorf("ldap: invalid password for user %q", log.Username(user.DN))
 					incorrectPass = true
 					return nil
 				case ldap.LDAPResultConstraintViolation:
-					c.logger.Errorf("ldap: constraint violation for user %q: %s", user.DN, ldapErr.Error())
+					c.logger.Errorf("ldap: constraint violation for user %q: %s", log.Username(user.DN), ldapErr.Error())
 					incorrectPass = true
 					return nil
 				}
 			} // will also catch all ldap.Error without a case statement above
-			return fmt.Errorf("ldap: failed to bind as dn %q: %v", user.DN, err)
+			return log.PIIErrorf("ldap: failed to bind as dn %q: %v", log.Username(user.DN), err)
 		}
 		return nil
 	})
@@ -537,7 +538,7 @@ func (c *ldapConnector) Refresh(ctx context.Context, s connector.Scopes, ident c
 			return err
 		}
 		if !found {
-			return fmt.Errorf("ldap: user not found %q", data.Username)
+			return log.PIIErrorf("ldap: user not found %q", log.Username(data.Username))
 		}
 		user = entry
 		return nil
@@ -546,7 +547,7 @@ func (c *ldapConnector) Refresh(ctx context.Context, s connector.Scopes, ident c
 		return ident, err
 	}
 	if user.DN != data.Entry.DN {
-		return ident, fmt.Errorf("ldap: refresh for username %q expected DN %q got %q", data.Username, data.Entry.DN, user.DN)
+		return ident, log.PIIErrorf("ldap: refresh for username %q expected DN %q got %q", log.Username(data.Username), log.Username(data.Entry.DN), log.Username(user.DN))
 	}
 
 	newIdent, err := c.identityFromEntry(user)
@@ -567,7 +568,7 @@ func (c *ldapConnector) Refresh(ctx context.Context, s connector.Scopes, ident c
 
 func (c *ldapConnector) groups(ctx context.Context, user ldap.Entry) ([]string, error) {
 	if c.GroupSearch.BaseDN == "" {
-		c.logger.Debugf("No groups returned for %q because no groups baseDN has been configured.", getAttr(user, c.UserSearch.NameAttr))
+		c.logger.Debugf("No groups returned for %q because no groups baseDN has been configured.", log.Username(getAttr(user, c.UserSearch.NameAttr)))
 		return nil, nil
 	}
 
@@ -589,7 +590,7 @@ func (c *ldapConnector) groups(ctx context.Context, user ldap.Entry) ([]string,
 			gotGroups := false
 			if err := c.do(ctx, func(conn *ldap.Conn) error {
 				c.logger.Infof("performing ldap search %s %s %s",
-					req.BaseDN, scopeString(req.Scope), req.Filter)
+					req.BaseDN, scopeString(req.Scope), log.Username(req.Filter))
 				resp, err := conn.Search(req)
 				if err != nil {
 					return fmt.Errorf("ldap: search failed: %v", err)
@@ -602,7 +603,7 @@ func (c *ldapConnector) groups(ctx context.Context, user ldap.Entry) ([]string,
 			}
 			if !gotGroups {
 				// TODO(ericchiang): Is this going to spam the logs?
-				c.logger.Errorf("ldap: groups search with filter %q returned no groups", filter)
+				c.logger.Errorf("ldap: groups search with filter %q returned no groups", log.Username(filter))
 			}
 		}
 	}
@@ -29,7 +29,8 @@ func NewCallbackConnector(logger log.Logger) connector.Connector {
 }
 
 var (
-	_ connector.CallbackConnector = &Callback{}
+	_ connector.CallbackConnector      = &Callback{}
+	_ connector.TokenIdentityConnector = &Callback{}
 
 	_ connector.PasswordConnector = passwordConnector{}
 	_ connector.RefreshConnector  = passwordConnector{}
@@ -66,6 +67,14 @@ func (m *Callback) Refresh(ctx context.Context, s connector.Scopes, identity con
 	return m.Identity, nil
 }
 
+// TokenIdentity returns the identity for any token but "invalid".
+func (m *Callback) TokenIdentity(ctx context.Context, tokenType, token string) (connector.Identity, error) {
+	if token == "invalid" {
+		return connector.Identity{}, errors.New("invalid token")
+	}
+	return m.Identity, nil
+}
+
 // CallbackConfig holds the configuration parameters for a connector which requires no interaction.
 type CallbackConfig struct{}
 
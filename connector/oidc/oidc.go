@@ -57,6 +57,10 @@ type Config struct {
 
 	// PromptType will be used fot the prompt parameter (when offline_access, by default prompt=consent)
 	PromptType string `json:"promptType"`
+
+	// Clock drift tolerated when checking the expiry of upstream ID tokens,
+	// as a duration string such as "2m".
+	ClockSkewTolerance string `json:"clockSkewTolerance"`
 }
 
 // Domains that don't support basic auth. golang.org/x/oauth2 has an internal
@@ -121,6 +125,14 @@ func (c *Config) Open(id string, logger log.Logger) (conn connector.Connector, e
 		c.PromptType = "consent"
 	}
 
+	var clockSkew time.Duration
+	if c.ClockSkewTolerance != "" {
+		if clockSkew, err = time.ParseDuration(c.ClockSkewTolerance); err != nil {
+			cancel()
+			return nil, fmt.Errorf("invalid clockSkewTolerance %q: %v", c.ClockSkewTolerance, err)
+		}
+	}
+
 	clientID := c.ClientID
 	return &oidcConnector{
 		provider:    provider,
@@ -133,7 +145,10 @@ func (c *Config) Open(id string, logger log.Logger) (conn connector.Connector, e
 			RedirectURL:  c.RedirectURI,
 		},
 		verifier: provider.Verifier(
-			&oidc.Config{ClientID: clientID},
+			&oidc.Config{
+				ClientID: clientID,
+				Now:      func() time.Time { return time.Now().Add(-clockSkew) },
+			},
 		),
 		logger:                    logger,
 		cancel:                    cancel,
@@ -150,6 +165,8 @@ func (c *Config) Open(id string, logger log.Logger) (conn connector.Connector, e
 var (
 	_ connector.CallbackConnector = (*oidcConnector)(nil)
 	_ connector.RefreshConnector  = (*oidcConnector)(nil)
+
+	_ connector.TokenIdentityConnector = (*oidcConnector)(nil)
 )
 
 type oidcConnector struct {
@@ -264,6 +281,54 @@ func (c *oidcConnector) createIdentity(ctx context.Context, identity connector.I
 		}
 	}
 
+	cd := connectorData{
+		RefreshToken: []byte(token.RefreshToken),
+	}
+
+	connData, err := json.Marshal(&cd)
+	if err != nil {
+		return identity, fmt.Errorf("oidc: failed to encode connector data: %v", err)
+	}
+
+	identity, err = c.identityFromClaims(idToken.Subject, claims)
+	if err != nil {
+		return identity, err
+	}
+	identity.ConnectorData = connData
+	return identity, nil
+}
+
+// TokenIdentity validates an ID token issued by the provider to dex. Access
+// tokens are only accepted if they're JWTs signed by the provider with dex's
+// client ID as audience, the way ID tokens are. Opaque access tokens accepted
+// by the userinfo endpoint may have been issued to any client of the
+// provider, so they aren't.
+func (c *oidcConnector) TokenIdentity(ctx context.Context, tokenType, token string) (connector.Identity, error) {
+	switch tokenType {
+	case connector.TokenTypeIDToken, connector.TokenTypeAccessToken:
+	default:
+		return connector.Identity{}, fmt.Errorf("oidc: unsupported token type %q", tokenType)
+	}
+	idToken, err := c.verifier.Verify(ctx, token)
+	if err != nil {
+		return connector.Identity{}, fmt.Errorf("oidc: failed to verify token: %v", err)
+	}
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return connector.Identity{}, fmt.Errorf("oidc: failed to decode claims: %v", err)
+	}
+
+	identity, err := c.identityFromClaims(idToken.Subject, claims)
+	if err != nil {
+		return identity, err
+	}
+	if mayAct, ok := claims["may_act"].(map[string]interface{}); ok {
+		identity.MayAct, _ = mayAct["sub"].(string)
+	}
+	return identity, nil
+}
+
+func (c *oidcConnector) identityFromClaims(subject string, claims map[string]interface{}) (identity connector.Identity, err error) {
 	userNameKey := "name"
 	if c.userNameKey != "" {
 		userNameKey = c.userNameKey
@@ -310,21 +375,11 @@ func (c *oidcConnector) createIdentity(ctx context.Context, identity connector.I
 		}
 	}
 
-	cd := connectorData{
-		RefreshToken: []byte(token.RefreshToken),
-	}
-
-	connData, err := json.Marshal(&cd)
-	if err != nil {
-		return identity, fmt.Errorf("oidc: failed to encode connector data: %v", err)
-	}
-
 	identity = connector.Identity{
-		UserID:        idToken.Subject,
+		UserID:        subject,
 		Username:      name,
 		Email:         email,
 		EmailVerified: emailVerified,
-		ConnectorData: connData,
 	}
 
 	if c.userIDKey != "" {
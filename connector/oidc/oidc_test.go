@@ -2,6 +2,7 @@ package oidc
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"encoding/base64"
@@ -188,6 +189,63 @@ func TestHandleCallback(t *testing.T) {
 	}
 }
 
+func TestTokenIdentity(t *testing.T) {
+	testServer, err := setupServer(map[string]interface{}{
+		"sub":            "subvalue",
+		"name":           "namevalue",
+		"email":          "emailvalue",
+		"email_verified": true,
+		"may_act":        map[string]interface{}{"sub": "actor"},
+	})
+	if err != nil {
+		t.Fatal("failed to setup test server", err)
+	}
+	defer testServer.Close()
+
+	conn, err := newConnector(Config{
+		Issuer:       testServer.URL,
+		ClientID:     "clientID",
+		ClientSecret: "clientSecret",
+		RedirectURI:  fmt.Sprintf("%s/callback", testServer.URL),
+	})
+	if err != nil {
+		t.Fatal("failed to create new connector", err)
+	}
+
+	resp, err := http.Post(testServer.URL+"/token", "application/x-www-form-urlencoded", nil)
+	if err != nil {
+		t.Fatal("failed to get token", err)
+	}
+	defer resp.Body.Close()
+	var token struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		t.Fatal("failed to decode token", err)
+	}
+
+	identity, err := conn.TokenIdentity(context.Background(), connector.TokenTypeIDToken, token.IDToken)
+	if err != nil {
+		t.Fatal("token identity failed", err)
+	}
+	expectEquals(t, identity.UserID, "subvalue")
+	expectEquals(t, identity.Username, "namevalue")
+	expectEquals(t, identity.MayAct, "actor")
+
+	if _, err := conn.TokenIdentity(context.Background(), connector.TokenTypeIDToken, token.IDToken+"x"); err == nil {
+		t.Error("expected invalid signature to be rejected")
+	}
+	if _, err := conn.TokenIdentity(context.Background(), connector.TokenTypeAccessToken, token.IDToken); err != nil {
+		t.Errorf("expected access token issued to dex to be accepted, got %v", err)
+	}
+	if _, err := conn.TokenIdentity(context.Background(), connector.TokenTypeAccessToken, "opaque"); err == nil {
+		t.Error("expected opaque access token to be rejected")
+	}
+	if _, err := conn.TokenIdentity(context.Background(), "urn:ietf:params:oauth:token-type:saml2", token.IDToken); err == nil {
+		t.Error("expected unsupported token type to be rejected")
+	}
+}
+
 func setupServer(tok map[string]interface{}) (*httptest.Server, error) {
 	key, err := rsa.GenerateKey(rand.Reader, 1024)
 	if err != nil {
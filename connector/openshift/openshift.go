@@ -169,7 +169,7 @@ func (c *openshiftConnector) HandleCallback(s connector.Scopes, r *http.Request)
 		validGroups := validateAllowedGroups(user.Groups, c.groups)
 
 		if !validGroups {
-			return identity, fmt.Errorf("openshift: user %q is not in any of the required groups", user.Name)
+			return identity, log.PIIErrorf("openshift: user %q is not in any of the required groups", log.Username(user.Name))
 		}
 	}
 
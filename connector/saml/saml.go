@@ -43,8 +43,8 @@ const (
 	// subject confirmation methods
 	subjectConfirmationMethodBearer = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
 
-	// allowed clock drift for timestamp validation
-	allowedClockDrift = time.Duration(30) * time.Second
+	// default allowed clock drift for timestamp validation
+	defaultClockDrift = time.Duration(30) * time.Second
 )
 
 var (
@@ -117,6 +117,10 @@ type Config struct {
 	//		urn:oasis:names:tc:SAML:2.0:nameid-format:persistent
 	//
 	NameIDPolicyFormat string `json:"nameIDPolicyFormat"`
+
+	// Clock drift tolerated when checking the validity window of assertions,
+	// as a duration string such as "2m". Defaults to 30 seconds.
+	ClockSkewTolerance string `json:"clockSkewTolerance"`
 }
 
 type certStore struct {
@@ -156,11 +160,21 @@ func (c *Config) openConnector(logger log.Logger) (*provider, error) {
 		return nil, fmt.Errorf("missing required fields %q", missing)
 	}
 
+	clockDrift := defaultClockDrift
+	if c.ClockSkewTolerance != "" {
+		d, err := time.ParseDuration(c.ClockSkewTolerance)
+		if err != nil {
+			return nil, fmt.Errorf("invalid clockSkewTolerance %q: %v", c.ClockSkewTolerance, err)
+		}
+		clockDrift = d
+	}
+
 	p := &provider{
 		entityIssuer:  c.EntityIssuer,
 		ssoIssuer:     c.SSOIssuer,
 		ssoURL:        c.SSOURL,
 		now:           time.Now,
+		clockDrift:    clockDrift,
 		usernameAttr:  c.UsernameAttr,
 		emailAttr:     c.EmailAttr,
 		groupsAttr:    c.GroupsAttr,
@@ -233,6 +247,9 @@ type provider struct {
 
 	now func() time.Time
 
+	// Allowed clock drift when validating timestamps.
+	clockDrift time.Duration
+
 	// If nil, don't do signature validation.
 	validator *dsig.ValidationContext
 
@@ -379,8 +396,8 @@ func (p *provider) HandlePOST(s connector.Scopes, samlResponse, inResponseTo str
 
 	// Log the actual attributes we got back from the server. This helps debug
 	// configuration errors on the server side, where the SAML server doesn't
-	// send us the correct attributes.
-	p.logger.Infof("parsed and verified saml response attributes %s", attributes)
+	// send us the correct attributes. The attributes identify the user.
+	p.logger.Infof("parsed and verified saml response attributes %s", log.Username(fmt.Sprint(attributes)))
 
 	// Grab the email.
 	if ident.Email, _ = attributes.get(p.emailAttr); ident.Email == "" {
@@ -497,10 +514,10 @@ func (p *provider) validateSubject(subject *subject, inResponseTo string) error
 			notBefore := time.Time(data.NotBefore)
 			notOnOrAfter := time.Time(data.NotOnOrAfter)
 			now := p.now()
-			if !notBefore.IsZero() && before(now, notBefore) {
+			if !notBefore.IsZero() && p.before(now, notBefore) {
 				return fmt.Errorf("at %s got response that cannot be processed before %s", now, notBefore)
 			}
-			if !notOnOrAfter.IsZero() && after(now, notOnOrAfter) {
+			if !notOnOrAfter.IsZero() && p.after(now, notOnOrAfter) {
 				return fmt.Errorf("at %s got response that cannot be processed because it expired at %s", now, notOnOrAfter)
 			}
 			if r := data.Recipient; r != "" && r != p.redirectURI {
@@ -530,12 +547,12 @@ func (p *provider) validateConditions(conditions *conditions) error {
 	// Ensure the conditions haven't expired.
 	now := p.now()
 	notBefore := time.Time(conditions.NotBefore)
-	if !notBefore.IsZero() && before(now, notBefore) {
+	if !notBefore.IsZero() && p.before(now, notBefore) {
 		return fmt.Errorf("at %s got response that cannot be processed before %s", now, notBefore)
 	}
 
 	notOnOrAfter := time.Time(conditions.NotOnOrAfter)
-	if !notOnOrAfter.IsZero() && after(now, notOnOrAfter) {
+	if !notOnOrAfter.IsZero() && p.after(now, notOnOrAfter) {
 		return fmt.Errorf("at %s got response that cannot be processed because it expired at %s", now, notOnOrAfter)
 	}
 
@@ -624,12 +641,12 @@ func verifyResponseSig(validator *dsig.ValidationContext, data []byte) (signed [
 
 // before determines if a given time is before the current time, with an
 // allowed clock drift.
-func before(now, notBefore time.Time) bool {
-	return now.Add(allowedClockDrift).Before(notBefore)
+func (p *provider) before(now, notBefore time.Time) bool {
+	return now.Add(p.clockDrift).Before(notBefore)
 }
 
 // after determines if a given time is after the current time, with an
 // allowed clock drift.
-func after(now, notOnOrAfter time.Time) bool {
-	return now.After(notOnOrAfter.Add(allowedClockDrift))
+func (p *provider) after(now, notOnOrAfter time.Time) bool {
+	return now.After(notOnOrAfter.Add(p.clockDrift))
 }
@@ -0,0 +1,185 @@
+// Package alert delivers notifications about failures that need an operator's
+// attention, such as a storage outage, to paging and chat systems.
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Kind is the subsystem that's failing.
+type Kind string
+
+// Alert kinds.
+const (
+	KindStorage   Kind = "storage"
+	KindSigning   Kind = "signing"
+	KindConnector Kind = "connector"
+)
+
+// Alert reports that a subsystem failed repeatedly within a window.
+type Alert struct {
+	Kind Kind `json:"kind"`
+	// Subject identifies the failing component within the subsystem, for
+	// example the connector ID.
+	Subject string    `json:"subject,omitempty"`
+	Time    time.Time `json:"time"`
+
+	Failures  int           `json:"failures"`
+	Window    time.Duration `json:"window"`
+	LastError string        `json:"lastError"`
+}
+
+// Summary returns a one line description of the alert.
+func (a Alert) Summary() string {
+	subject := string(a.Kind)
+	if a.Subject != "" {
+		subject += " " + a.Subject
+	}
+	return fmt.Sprintf("dex: %s failed %d times in %s: %s", subject, a.Failures, a.Window, a.LastError)
+}
+
+// Notifier delivers alerts.
+type Notifier interface {
+	Notify(ctx context.Context, a Alert) error
+}
+
+// Multi returns a notifier that delivers alerts to all the given notifiers.
+// All notifiers are attempted, the first error encountered is returned.
+func Multi(notifiers ...Notifier) Notifier {
+	return multiNotifier(notifiers)
+}
+
+type multiNotifier []Notifier
+
+func (m multiNotifier) Notify(ctx context.Context, a Alert) error {
+	var firstErr error
+	for _, n := range m {
+		if err := n.Notify(ctx, a); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func newClient() *http.Client {
+	return &http.Client{Timeout: 5 * time.Second}
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("alert: marshal body: %v", err)
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("alert: create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("alert: post: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("alert: unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// WebhookNotifier posts each alert as a JSON body to a URL.
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewWebhookNotifier returns a notifier posting alerts to url.
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{URL: url, Client: newClient()}
+}
+
+// Notify implements Notifier.
+func (w *WebhookNotifier) Notify(ctx context.Context, a Alert) error {
+	return postJSON(ctx, w.Client, w.URL, a)
+}
+
+// SlackNotifier posts alerts to a Slack incoming webhook.
+type SlackNotifier struct {
+	WebhookURL string
+	Client     *http.Client
+}
+
+// NewSlackNotifier returns a notifier posting to the Slack incoming webhook.
+func NewSlackNotifier(webhookURL string) *SlackNotifier {
+	return &SlackNotifier{WebhookURL: webhookURL, Client: newClient()}
+}
+
+// Notify implements Notifier.
+func (s *SlackNotifier) Notify(ctx context.Context, a Alert) error {
+	return postJSON(ctx, s.Client, s.WebhookURL, struct {
+		Text string `json:"text"`
+	}{a.Summary()})
+}
+
+// PagerDutyEventsURL is the PagerDuty Events API v2 endpoint.
+const PagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// PagerDutyNotifier triggers PagerDuty incidents through the Events API v2.
+type PagerDutyNotifier struct {
+	RoutingKey string
+	// URL of the events API. Defaults to PagerDutyEventsURL.
+	URL    string
+	Client *http.Client
+}
+
+// NewPagerDutyNotifier returns a notifier triggering incidents for the
+// integration with the given routing key.
+func NewPagerDutyNotifier(routingKey string) *PagerDutyNotifier {
+	return &PagerDutyNotifier{RoutingKey: routingKey, URL: PagerDutyEventsURL, Client: newClient()}
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string           `json:"routing_key"`
+	EventAction string           `json:"event_action"`
+	DedupKey    string           `json:"dedup_key"`
+	Payload     pagerDutyPayload `json:"payload"`
+}
+
+type pagerDutyPayload struct {
+	Summary  string `json:"summary"`
+	Source   string `json:"source"`
+	Severity string `json:"severity"`
+	Class    string `json:"class"`
+}
+
+// Notify implements Notifier. Alerts for the same component share a dedup
+// key, so repeated alerts update a single incident.
+func (p *PagerDutyNotifier) Notify(ctx context.Context, a Alert) error {
+	url := p.URL
+	if url == "" {
+		url = PagerDutyEventsURL
+	}
+	dedupKey := "dex-" + string(a.Kind)
+	if a.Subject != "" {
+		dedupKey += "-" + a.Subject
+	}
+	return postJSON(ctx, p.Client, url, pagerDutyEvent{
+		RoutingKey:  p.RoutingKey,
+		EventAction: "trigger",
+		DedupKey:    dedupKey,
+		Payload: pagerDutyPayload{
+			Summary:  a.Summary(),
+			Source:   "dex",
+			Severity: "critical",
+			Class:    string(a.Kind),
+		},
+	})
+}
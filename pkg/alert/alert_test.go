@@ -0,0 +1,87 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testAlert = Alert{
+	Kind:      KindConnector,
+	Subject:   "github",
+	Failures:  5,
+	Window:    5 * time.Minute,
+	LastError: "connection refused",
+}
+
+func receive(t *testing.T, got interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("unexpected content type %q", ct)
+		}
+		if err := json.NewDecoder(r.Body).Decode(got); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+	}))
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var got Alert
+	srv := receive(t, &got)
+	defer srv.Close()
+
+	if err := NewWebhookNotifier(srv.URL).Notify(context.Background(), testAlert); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+	if got.Kind != testAlert.Kind || got.Subject != testAlert.Subject || got.Failures != testAlert.Failures {
+		t.Errorf("webhook received %+v, want %+v", got, testAlert)
+	}
+}
+
+func TestSlackNotifier(t *testing.T) {
+	var got struct {
+		Text string `json:"text"`
+	}
+	srv := receive(t, &got)
+	defer srv.Close()
+
+	if err := NewSlackNotifier(srv.URL).Notify(context.Background(), testAlert); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+	if !strings.Contains(got.Text, "connector github failed 5 times") {
+		t.Errorf("unexpected slack message %q", got.Text)
+	}
+}
+
+func TestPagerDutyNotifier(t *testing.T) {
+	var got pagerDutyEvent
+	srv := receive(t, &got)
+	defer srv.Close()
+
+	n := NewPagerDutyNotifier("routing-key")
+	n.URL = srv.URL
+	if err := n.Notify(context.Background(), testAlert); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+	if got.RoutingKey != "routing-key" || got.EventAction != "trigger" || got.DedupKey != "dex-connector-github" {
+		t.Errorf("unexpected event %+v", got)
+	}
+	if got.Payload.Severity != "critical" || got.Payload.Summary != testAlert.Summary() {
+		t.Errorf("unexpected payload %+v", got.Payload)
+	}
+}
+
+func TestNotifierError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	if err := NewWebhookNotifier(srv.URL).Notify(context.Background(), testAlert); err == nil {
+		t.Error("expected error on non-2xx response")
+	}
+}
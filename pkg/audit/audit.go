@@ -0,0 +1,177 @@
+// Package audit provides security relevant events emitted by dex and sinks
+// that deliver them to operators.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/dexidp/dex/pkg/log"
+)
+
+// Severity indicates how urgently an event should be looked at.
+type Severity string
+
+// Event severities.
+const (
+	SeverityInfo    Severity = "info"
+	SeverityWarning Severity = "warning"
+	SeverityHigh    Severity = "high"
+)
+
+// Event types.
+const (
+	// EventRefreshTokenReuse is emitted when a refresh token that has already
+	// been rotated is presented again.
+	EventRefreshTokenReuse = "refresh_token_reuse"
+	// EventAuthCodeReuse is emitted when an auth code that has already been
+	// redeemed is presented again.
+	EventAuthCodeReuse = "auth_code_reuse"
+	// EventClientNetworkDenied is emitted when a client requests tokens from
+	// an address outside of its allowed networks.
+	EventClientNetworkDenied = "client_network_denied"
+	// EventClockDrift is emitted when the local clock has drifted from the
+	// configured time source beyond the allowed threshold.
+	EventClockDrift = "clock_drift"
+	// EventDeviceFingerprintMismatch is emitted when a refresh token bound to
+	// a device is presented without that device's fingerprint.
+	EventDeviceFingerprintMismatch = "device_fingerprint_mismatch"
+	// EventLogin is emitted when a user logs in through a connector.
+	EventLogin = "login"
+	// EventApprovalGranted and EventApprovalDenied are emitted when a user
+	// answers the approval screen.
+	EventApprovalGranted = "approval_granted"
+	EventApprovalDenied  = "approval_denied"
+	// EventUserDataErased is emitted when the data stored about a user is
+	// erased through the API. The event carries the erasure ID in place of
+	// the user's subject.
+	EventUserDataErased = "user_data_erased"
+	// EventConnectorFallback is emitted when a connector fails to reach its
+	// upstream identity provider and the user is offered its fallback
+	// connector instead.
+	EventConnectorFallback = "connector_fallback"
+	// EventAPIKeyUsed is emitted when an API key is exchanged for tokens.
+	EventAPIKeyUsed = "api_key_used"
+	// EventPreAuthorizedCodeUsed is emitted when a pre-authorized code is
+	// exchanged for tokens.
+	EventPreAuthorizedCodeUsed = "pre_authorized_code_used"
+	// EventCredentialIssued is emitted when verifiable credentials are
+	// issued by the credential endpoint.
+	EventCredentialIssued = "credential_issued"
+	// EventServiceAccountToken is emitted when a service account is issued
+	// tokens.
+	EventServiceAccountToken = "service_account_token"
+	// EventWorkloadToken is emitted when a workload authenticated with a
+	// SPIFFE SVID is issued tokens. The event's subject is the SPIFFE ID.
+	EventWorkloadToken = "workload_token"
+	// EventAuthorizationDenied is emitted when the external authorizer denies
+	// issuing tokens.
+	EventAuthorizationDenied = "authorization_denied"
+	// EventClientExpired is emitted when an expired client is deleted along
+	// with its tokens.
+	EventClientExpired = "client_expired"
+	// EventTokenExchange is emitted when an upstream token is exchanged for
+	// a token issued by dex.
+	EventTokenExchange = "token_exchange"
+	// EventIDTokenReplay is emitted when a resource server presents a
+	// one-time-use ID token that was already presented.
+	EventIDTokenReplay = "id_token_replay"
+	// EventLogout is emitted when a user ends their session.
+	EventLogout = "logout"
+	// EventWebAuthnRegistered is emitted when a user registers a passkey.
+	EventWebAuthnRegistered = "webauthn_registered"
+	// EventWebAuthnFailed is emitted when a passkey registration or login
+	// fails verification. Its severity is high if the authenticator may have
+	// been cloned.
+	EventWebAuthnFailed = "webauthn_failed"
+	// EventLoginLinkSent is emitted when a passwordless connector emails a
+	// login link. Using the link emits EventLogin.
+	EventLoginLinkSent = "login_link_sent"
+	// EventTOTPEnrolled is emitted when a user enrolls an authenticator app.
+	EventTOTPEnrolled = "totp_enrolled"
+	// EventTOTPFailed is emitted when a user enters an invalid code of their
+	// authenticator app or backup code.
+	EventTOTPFailed = "totp_failed"
+	// EventTOTPBackupCodeUsed is emitted when a user logs in with a backup
+	// code instead of their authenticator app.
+	EventTOTPBackupCodeUsed = "totp_backup_code_used"
+)
+
+// Event is a single audit record.
+type Event struct {
+	Type     string    `json:"type"`
+	Severity Severity  `json:"severity"`
+	Time     time.Time `json:"time"`
+
+	ClientID    string `json:"clientID,omitempty"`
+	Subject     string `json:"subject,omitempty"`
+	ConnectorID string `json:"connectorID,omitempty"`
+
+	// SourceIPs holds the addresses involved in the event. For reuse events
+	// the first entry is the original requester and the last one the client
+	// that presented the credential again.
+	SourceIPs []string `json:"sourceIPs,omitempty"`
+
+	Message string `json:"message,omitempty"`
+	// Revoked reports whether the affected session was revoked in response.
+	Revoked bool `json:"revoked,omitempty"`
+}
+
+// Sink receives audit events.
+type Sink interface {
+	Emit(ctx context.Context, e Event) error
+}
+
+// Multi returns a sink that delivers events to all the given sinks. All
+// sinks are attempted, the first error encountered is returned.
+func Multi(sinks ...Sink) Sink {
+	return multiSink(sinks)
+}
+
+type multiSink []Sink
+
+func (m multiSink) Emit(ctx context.Context, e Event) error {
+	var firstErr error
+	for _, s := range m {
+		if err := s.Emit(ctx, e); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// NewLoggerSink returns a sink that writes events to the logger. High
+// severity events are logged as errors, everything else as info. Subjects
+// and source IPs are redacted if the logger redacts them.
+func NewLoggerSink(logger log.Logger) Sink {
+	return loggerSink{logger}
+}
+
+type loggerSink struct {
+	logger log.Logger
+}
+
+func (l loggerSink) Emit(ctx context.Context, e Event) error {
+	e.Subject = log.Redact(l.logger, log.Subject(e.Subject))
+	if len(e.SourceIPs) > 0 {
+		ips := make([]string, len(e.SourceIPs))
+		for i, ip := range e.SourceIPs {
+			ips[i] = log.Redact(l.logger, log.IP(ip))
+		}
+		e.SourceIPs = ips
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	switch e.Severity {
+	case SeverityHigh:
+		l.logger.Errorf("audit: %s", data)
+	case SeverityWarning:
+		l.logger.Warnf("audit: %s", data)
+	default:
+		l.logger.Infof("audit: %s", data)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dexidp/dex/pkg/log"
+)
+
+type recordingLogger struct {
+	log.Logger
+	lines []string
+}
+
+func (r *recordingLogger) Errorf(format string, args ...interface{}) {
+	r.lines = append(r.lines, fmt.Sprintf(format, args...))
+}
+
+func TestLoggerSinkRedacts(t *testing.T) {
+	rec := new(recordingLogger)
+	sink := NewLoggerSink(log.NewRedactingLogger(rec, log.Redaction{log.KindSubject: log.ModeFull, log.KindIP: log.ModePartial}))
+
+	e := Event{Type: EventRefreshTokenReuse, Severity: SeverityHigh, Subject: "CgR1c2Vy", SourceIPs: []string{"192.0.2.17"}}
+	if err := sink.Emit(context.Background(), e); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.lines) != 1 {
+		t.Fatalf("expected one log line, got %d", len(rec.lines))
+	}
+	line := rec.lines[0]
+	if strings.Contains(line, "CgR1c2Vy") || strings.Contains(line, "192.0.2.17") {
+		t.Errorf("log line leaks personal information: %s", line)
+	}
+	if !strings.Contains(line, `"sourceIPs":["192.0.2.0/24"]`) {
+		t.Errorf("expected partially redacted IP: %s", line)
+	}
+	if e.SourceIPs[0] != "192.0.2.17" {
+		t.Error("redacting modified the emitted event")
+	}
+}
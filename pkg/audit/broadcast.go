@@ -0,0 +1,51 @@
+package audit
+
+import (
+	"context"
+	"sync"
+)
+
+// Broadcaster is a sink that delivers events to any number of subscribers,
+// such as live dashboards. It never blocks: subscribers that don't keep up
+// miss events.
+type Broadcaster struct {
+	mu   sync.Mutex
+	subs map[chan Event]struct{}
+}
+
+// NewBroadcaster returns a broadcaster without subscribers.
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{subs: make(map[chan Event]struct{})}
+}
+
+// Emit implements Sink.
+func (b *Broadcaster) Emit(ctx context.Context, e Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+	return nil
+}
+
+// Subscribe returns a channel receiving events emitted from now on, buffering
+// up to buffer events. Calling cancel unsubscribes and closes the channel.
+func (b *Broadcaster) Subscribe(buffer int) (events <-chan Event, cancel func()) {
+	ch := make(chan Event, buffer)
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package audit
+
+import (
+	"context"
+	"testing"
+)
+
+func TestBroadcaster(t *testing.T) {
+	ctx := context.Background()
+	b := NewBroadcaster()
+
+	// Events without subscribers are dropped.
+	if err := b.Emit(ctx, Event{Type: "before"}); err != nil {
+		t.Fatal(err)
+	}
+
+	fast, cancelFast := b.Subscribe(2)
+	slow, cancelSlow := b.Subscribe(1)
+	defer cancelSlow()
+
+	for _, typ := range []string{"first", "second"} {
+		if err := b.Emit(ctx, Event{Type: typ}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, want := range []string{"first", "second"} {
+		if e := <-fast; e.Type != want {
+			t.Errorf("expected event %q, got %q", want, e.Type)
+		}
+	}
+	// The slow subscriber's buffer was full, it missed the second event.
+	if e := <-slow; e.Type != "first" {
+		t.Errorf("expected event %q, got %q", "first", e.Type)
+	}
+	select {
+	case e := <-slow:
+		t.Errorf("expected no more events, got %q", e.Type)
+	default:
+	}
+
+	cancelFast()
+	cancelFast()
+	if _, ok := <-fast; ok {
+		t.Error("expected channel to be closed after cancel")
+	}
+	if err := b.Emit(ctx, Event{Type: "after"}); err != nil {
+		t.Fatal(err)
+	}
+	if e := <-slow; e.Type != "after" {
+		t.Errorf("expected event %q, got %q", "after", e.Type)
+	}
+}
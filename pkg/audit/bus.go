@@ -0,0 +1,125 @@
+package audit
+
+import (
+	"context"
+	"sync"
+
+	"github.com/dexidp/dex/pkg/log"
+)
+
+// Bus is a sink that delivers events to named consumers, such as the audit
+// log, webhooks and metrics, so code emitting events doesn't need to know
+// who consumes them.
+//
+// Synchronous consumers receive each event before Emit returns. Asynchronous
+// consumers have their own queue and goroutine, so a slow consumer delays
+// neither the caller nor other consumers. Events that don't fit in the queue
+// of an asynchronous consumer are dropped.
+type Bus struct {
+	logger log.Logger
+
+	// OnDrop, if set, is called with the name of the consumer when an event
+	// is dropped because the consumer's queue is full.
+	OnDrop func(consumer string, e Event)
+	// OnError, if set, is called with the name of the consumer when it fails
+	// to handle an event.
+	OnError func(consumer string, e Event, err error)
+
+	mu        sync.RWMutex
+	consumers []*consumer
+	wg        sync.WaitGroup
+	closed    bool
+}
+
+type consumer struct {
+	name  string
+	sink  Sink
+	queue chan Event // nil for synchronous consumers
+}
+
+// NewBus returns a bus without consumers. Errors of asynchronous consumers
+// are logged.
+func NewBus(logger log.Logger) *Bus {
+	return &Bus{logger: logger}
+}
+
+// Subscribe adds a synchronous consumer. Its errors are returned by Emit.
+func (b *Bus) Subscribe(name string, s Sink) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.consumers = append(b.consumers, &consumer{name: name, sink: s})
+}
+
+// SubscribeAsync adds an asynchronous consumer queueing up to queueSize
+// events.
+func (b *Bus) SubscribeAsync(name string, s Sink, queueSize int) {
+	c := &consumer{name: name, sink: s, queue: make(chan Event, queueSize)}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.consumers = append(b.consumers, c)
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		for e := range c.queue {
+			// The context of the emitting request may already be
+			// canceled.
+			if err := c.sink.Emit(context.Background(), e); err != nil {
+				b.logger.Errorf("event consumer %s failed to handle event %q: %v", c.name, e.Type, err)
+				b.failed(c.name, e, err)
+			}
+		}
+	}()
+}
+
+// Emit implements Sink. All synchronous consumers are attempted, the first
+// error encountered is returned.
+func (b *Bus) Emit(ctx context.Context, e Event) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var firstErr error
+	for _, c := range b.consumers {
+		if c.queue == nil {
+			if err := c.sink.Emit(ctx, e); err != nil {
+				b.failed(c.name, e, err)
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+			continue
+		}
+		if b.closed {
+			continue
+		}
+		select {
+		case c.queue <- e:
+		default:
+			if b.OnDrop != nil {
+				b.OnDrop(c.name, e)
+			}
+		}
+	}
+	return firstErr
+}
+
+func (b *Bus) failed(consumer string, e Event, err error) {
+	if b.OnError != nil {
+		b.OnError(consumer, e, err)
+	}
+}
+
+// Close stops the asynchronous consumers once they've handled the events
+// already queued. Events emitted afterwards only reach synchronous
+// consumers.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		for _, c := range b.consumers {
+			if c.queue != nil {
+				close(c.queue)
+			}
+		}
+	}
+	b.mu.Unlock()
+	b.wg.Wait()
+}
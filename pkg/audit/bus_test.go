@@ -0,0 +1,97 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+type funcSink func(ctx context.Context, e Event) error
+
+func (f funcSink) Emit(ctx context.Context, e Event) error { return f(ctx, e) }
+
+func TestBus(t *testing.T) {
+	ctx := context.Background()
+	bus := NewBus(new(recordingLogger))
+
+	var dropped []string
+	bus.OnDrop = func(consumer string, e Event) {
+		dropped = append(dropped, consumer+":"+e.Type)
+	}
+
+	var syncEvents []string
+	bus.Subscribe("sync", funcSink(func(ctx context.Context, e Event) error {
+		syncEvents = append(syncEvents, e.Type)
+		return nil
+	}))
+	bus.Subscribe("failing", funcSink(func(ctx context.Context, e Event) error {
+		return errors.New("unavailable")
+	}))
+
+	// The asynchronous consumer blocks on the first event until released,
+	// so its queue of one fills up.
+	release := make(chan struct{})
+	started := make(chan struct{})
+	var (
+		mu          sync.Mutex
+		asyncEvents []string
+	)
+	bus.SubscribeAsync("async", funcSink(func(ctx context.Context, e Event) error {
+		if e.Type == "first" {
+			close(started)
+			<-release
+		}
+		mu.Lock()
+		asyncEvents = append(asyncEvents, e.Type)
+		mu.Unlock()
+		return nil
+	}), 1)
+
+	if err := bus.Emit(ctx, Event{Type: "first"}); err == nil {
+		t.Error("expected error of synchronous consumer to be returned")
+	}
+	<-started
+	for _, typ := range []string{"second", "third"} {
+		bus.Emit(ctx, Event{Type: typ})
+	}
+	if len(syncEvents) != 3 {
+		t.Errorf("expected synchronous consumer to receive 3 events, got %v", syncEvents)
+	}
+	if len(dropped) != 1 || dropped[0] != "async:third" {
+		t.Errorf("expected third event to be dropped, got %v", dropped)
+	}
+
+	close(release)
+	bus.Close()
+	if len(asyncEvents) != 2 || asyncEvents[0] != "first" || asyncEvents[1] != "second" {
+		t.Errorf("expected queued events to be delivered before close returns, got %v", asyncEvents)
+	}
+
+	bus.Emit(ctx, Event{Type: "after"})
+	if len(syncEvents) != 4 || len(asyncEvents) != 2 {
+		t.Errorf("expected only synchronous consumers to receive events after close")
+	}
+}
+
+func TestBusAsyncErrors(t *testing.T) {
+	logger := new(recordingLogger)
+	bus := NewBus(logger)
+	var failed []string
+	bus.OnError = func(consumer string, e Event, err error) {
+		failed = append(failed, consumer)
+	}
+	bus.SubscribeAsync("webhook", funcSink(func(ctx context.Context, e Event) error {
+		return errors.New("unavailable")
+	}), 1)
+	if err := bus.Emit(context.Background(), Event{Type: EventLogin}); err != nil {
+		t.Errorf("expected errors of asynchronous consumers not to be returned, got %v", err)
+	}
+	bus.Close()
+	if len(logger.lines) != 1 {
+		t.Errorf("expected error to be logged, got %v", logger.lines)
+	}
+	if len(failed) != 1 || failed[0] != "webhook" {
+		t.Errorf("expected failure to be reported, got %v", failed)
+	}
+}
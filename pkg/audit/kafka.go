@@ -0,0 +1,214 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Record formats of the Kafka REST proxy.
+const (
+	// KafkaFormatJSON produces schemaless JSON records.
+	KafkaFormatJSON = "json"
+	// KafkaFormatJSONSchema and KafkaFormatAvro produce records validated
+	// against a schema of the schema registry.
+	KafkaFormatJSONSchema = "jsonschema"
+	KafkaFormatAvro       = "avro"
+)
+
+// avroEventSchema is the Avro schema of events, which is registered by the
+// proxy unless the sink refers to a registered schema by ID. Fields aren't
+// optional, so the JSON encoding of events is valid Avro JSON.
+const avroEventSchema = `{
+  "type": "record",
+  "name": "Event",
+  "namespace": "io.dexidp.audit",
+  "fields": [
+    {"name": "type", "type": "string"},
+    {"name": "severity", "type": "string"},
+    {"name": "time", "type": "string"},
+    {"name": "clientID", "type": "string"},
+    {"name": "subject", "type": "string"},
+    {"name": "connectorID", "type": "string"},
+    {"name": "sourceIPs", "type": {"type": "array", "items": "string"}},
+    {"name": "message", "type": "string"},
+    {"name": "revoked", "type": "boolean"}
+  ]
+}`
+
+// jsonEventSchema is the JSON schema of events.
+const jsonEventSchema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "Event",
+  "type": "object",
+  "properties": {
+    "type": {"type": "string"},
+    "severity": {"type": "string"},
+    "time": {"type": "string", "format": "date-time"},
+    "clientID": {"type": "string"},
+    "subject": {"type": "string"},
+    "connectorID": {"type": "string"},
+    "sourceIPs": {"type": "array", "items": {"type": "string"}},
+    "message": {"type": "string"},
+    "revoked": {"type": "boolean"}
+  },
+  "required": ["type", "severity", "time"]
+}`
+
+// KafkaSink produces each event as a record to a Kafka topic through a
+// Kafka REST proxy, using its v2 API. Records are keyed by the event's
+// subject, so the events of a user are in the same partition and stay in
+// order.
+//
+// https://docs.confluent.io/platform/current/kafka-rest/api.html
+type KafkaSink struct {
+	// URL of the REST proxy.
+	URL   string
+	Topic string
+	// One of the KafkaFormat constants, defaults to KafkaFormatJSON.
+	Format string
+	// ValueSchemaID is the ID of the event schema in the schema registry.
+	// If zero, records of the jsonschema and avro formats carry dex's event
+	// schema, which the proxy registers.
+	ValueSchemaID int
+	// Client is used to deliver events. If nil, http.DefaultClient is used.
+	Client *http.Client
+}
+
+// NewKafkaSink returns a sink producing events to topic through the REST
+// proxy at proxyURL.
+func NewKafkaSink(proxyURL, topic, format string, valueSchemaID int) (*KafkaSink, error) {
+	switch format {
+	case "":
+		format = KafkaFormatJSON
+	case KafkaFormatJSON, KafkaFormatJSONSchema, KafkaFormatAvro:
+	default:
+		return nil, fmt.Errorf("audit: unsupported kafka record format %q", format)
+	}
+	if topic == "" {
+		return nil, errors.New("audit: kafka topic required")
+	}
+	return &KafkaSink{
+		URL:           proxyURL,
+		Topic:         topic,
+		Format:        format,
+		ValueSchemaID: valueSchemaID,
+		Client:        &http.Client{Timeout: 5 * time.Second},
+	}, nil
+}
+
+// kafkaValue is the record value of an event. Unlike Event it has no
+// optional fields, as required by the Avro schema.
+type kafkaValue struct {
+	Type        string   `json:"type"`
+	Severity    Severity `json:"severity"`
+	Time        string   `json:"time"`
+	ClientID    string   `json:"clientID"`
+	Subject     string   `json:"subject"`
+	ConnectorID string   `json:"connectorID"`
+	SourceIPs   []string `json:"sourceIPs"`
+	Message     string   `json:"message"`
+	Revoked     bool     `json:"revoked"`
+}
+
+type kafkaRecord struct {
+	Key   interface{} `json:"key,omitempty"`
+	Value kafkaValue  `json:"value"`
+}
+
+type kafkaRequest struct {
+	KeySchema     string        `json:"key_schema,omitempty"`
+	ValueSchema   string        `json:"value_schema,omitempty"`
+	ValueSchemaID int           `json:"value_schema_id,omitempty"`
+	Records       []kafkaRecord `json:"records"`
+}
+
+// Emit implements Sink.
+func (k *KafkaSink) Emit(ctx context.Context, e Event) error {
+	format := k.Format
+	if format == "" {
+		format = KafkaFormatJSON
+	}
+	value := kafkaValue{
+		Type:        e.Type,
+		Severity:    e.Severity,
+		Time:        e.Time.UTC().Format(time.RFC3339Nano),
+		ClientID:    e.ClientID,
+		Subject:     e.Subject,
+		ConnectorID: e.ConnectorID,
+		SourceIPs:   e.SourceIPs,
+		Message:     e.Message,
+		Revoked:     e.Revoked,
+	}
+	if value.SourceIPs == nil {
+		value.SourceIPs = []string{}
+	}
+	req := kafkaRequest{Records: []kafkaRecord{{Value: value}}}
+	// Events without a subject are spread over the partitions.
+	if e.Subject != "" {
+		req.Records[0].Key = e.Subject
+	}
+	switch format {
+	case KafkaFormatAvro:
+		if e.Subject != "" {
+			req.KeySchema = `"string"`
+		}
+		req.ValueSchema = avroEventSchema
+	case KafkaFormatJSONSchema:
+		if e.Subject != "" {
+			req.KeySchema = `{"type": "string"}`
+		}
+		req.ValueSchema = jsonEventSchema
+	}
+	if format != KafkaFormatJSON && k.ValueSchemaID != 0 {
+		req.ValueSchema = ""
+		req.ValueSchemaID = k.ValueSchemaID
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("audit: marshal kafka record: %v", err)
+	}
+	u := strings.TrimSuffix(k.URL, "/") + "/topics/" + url.PathEscape(k.Topic)
+	r, err := http.NewRequest("POST", u, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("audit: create kafka request: %v", err)
+	}
+	r.Header.Set("Content-Type", "application/vnd.kafka."+format+".v2+json")
+	r.Header.Set("Accept", "application/vnd.kafka.v2+json")
+
+	client := k.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(r.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("audit: produce to kafka: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("audit: kafka proxy returned unexpected status %s", resp.Status)
+	}
+	// The proxy reports errors of single records in the offsets.
+	var produced struct {
+		Offsets []struct {
+			ErrorCode int    `json:"error_code"`
+			Error     string `json:"error"`
+		} `json:"offsets"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&produced); err != nil {
+		return fmt.Errorf("audit: decode kafka proxy response: %v", err)
+	}
+	for _, o := range produced.Offsets {
+		if o.ErrorCode != 0 || o.Error != "" {
+			return fmt.Errorf("audit: kafka proxy failed to produce record: %s (%d)", o.Error, o.ErrorCode)
+		}
+	}
+	return nil
+}
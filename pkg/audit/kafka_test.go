@@ -0,0 +1,77 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestKafkaSink(t *testing.T) {
+	var (
+		contentType, path string
+		got               kafkaRequest
+		response          = `{"offsets":[{"partition":0,"offset":1}]}`
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		path = r.URL.Path
+		got = kafkaRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		w.Write([]byte(response))
+	}))
+	defer srv.Close()
+
+	e := Event{
+		Type:     EventLogin,
+		Severity: SeverityInfo,
+		Time:     time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		Subject:  "CgExEgVsb2NhbA",
+	}
+	tests := []struct {
+		format          string
+		schemaID        int
+		wantContentType string
+		wantSchema      bool
+	}{
+		{"", 0, "application/vnd.kafka.json.v2+json", false},
+		{KafkaFormatAvro, 0, "application/vnd.kafka.avro.v2+json", true},
+		{KafkaFormatJSONSchema, 42, "application/vnd.kafka.jsonschema.v2+json", false},
+	}
+	for _, tc := range tests {
+		sink, err := NewKafkaSink(srv.URL+"/", "dex.audit", tc.format, tc.schemaID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sink.Emit(context.Background(), e); err != nil {
+			t.Errorf("%q: emit: %v", tc.format, err)
+			continue
+		}
+		if contentType != tc.wantContentType || path != "/topics/dex.audit" {
+			t.Errorf("%q: unexpected request %s with content type %q", tc.format, path, contentType)
+		}
+		if len(got.Records) != 1 || got.Records[0].Key != e.Subject || got.Records[0].Value.Type != EventLogin {
+			t.Errorf("%q: unexpected records %+v", tc.format, got.Records)
+		}
+		if (got.ValueSchema != "") != tc.wantSchema || got.ValueSchemaID != tc.schemaID {
+			t.Errorf("%q: unexpected schema %q with ID %d", tc.format, got.ValueSchema, got.ValueSchemaID)
+		}
+	}
+
+	response = `{"offsets":[{"error_code":50002,"error":"schema mismatch"}]}`
+	sink, err := NewKafkaSink(srv.URL, "dex.audit", KafkaFormatAvro, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Emit(context.Background(), e); err == nil {
+		t.Error("expected record error to be returned")
+	}
+
+	if _, err := NewKafkaSink(srv.URL, "dex.audit", "protobuf", 0); err == nil {
+		t.Error("expected unsupported format to be rejected")
+	}
+}
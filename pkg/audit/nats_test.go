@@ -0,0 +1,90 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestNATSSink(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	type published struct {
+		connect, pub, pong string
+		event           Event
+		err             error
+	}
+	done := make(chan published, 1)
+	go func() {
+		var p published
+		defer func() { done <- p }()
+		conn, err := l.Accept()
+		if err != nil {
+			p.err = err
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		fmt.Fprint(conn, "INFO {\"server_id\":\"test\"}\r\n")
+		if p.connect, p.err = r.ReadString('\n'); p.err != nil {
+			return
+		}
+		if p.pub, p.err = r.ReadString('\n'); p.err != nil {
+			return
+		}
+		payload, err := r.ReadString('\n')
+		if err != nil {
+			p.err = err
+			return
+		}
+		if p.err = json.Unmarshal([]byte(strings.TrimSpace(payload)), &p.event); p.err != nil {
+			return
+		}
+		fmt.Fprint(conn, "PING\r\n")
+		p.pong, p.err = r.ReadString('\n')
+	}()
+
+	sink := NewNATSSink("nats://dex:secret@"+l.Addr().String(), "dex.audit")
+	defer sink.Close()
+	if err := sink.Emit(context.Background(), Event{Type: EventLogin, ClientID: "example-app"}); err != nil {
+		t.Fatalf("emit: %v", err)
+	}
+
+	p := <-done
+	if p.err != nil {
+		t.Fatalf("server: %v", p.err)
+	}
+	if !strings.HasPrefix(p.connect, "CONNECT ") || !strings.Contains(p.connect, `"user":"dex"`) || !strings.Contains(p.connect, `"pass":"secret"`) {
+		t.Errorf("unexpected connect message %q", p.connect)
+	}
+	if !strings.HasPrefix(p.pub, "PUB dex.audit ") {
+		t.Errorf("unexpected publish message %q", p.pub)
+	}
+	if p.event.Type != EventLogin || p.event.ClientID != "example-app" {
+		t.Errorf("unexpected event %+v", p.event)
+	}
+	if p.pong != "PONG\r\n" {
+		t.Errorf("expected ping to be answered, got %q", p.pong)
+	}
+}
+
+func TestNATSSinkUnreachable(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	if err := NewNATSSink("nats://"+addr, "dex.audit").Emit(context.Background(), Event{Type: EventLogin}); err == nil {
+		t.Error("expected error publishing to unreachable server")
+	}
+}
@@ -0,0 +1,53 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// WebhookSink posts each event as a JSON body to a URL.
+type WebhookSink struct {
+	URL string
+	// Client is used to deliver events. If nil, a client with a short
+	// timeout is used so a slow receiver can't stall token issuance.
+	Client *http.Client
+}
+
+// NewWebhookSink returns a sink posting events to url.
+func NewWebhookSink(url string) *WebhookSink {
+	return &WebhookSink{
+		URL:    url,
+		Client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Emit implements Sink.
+func (w *WebhookSink) Emit(ctx context.Context, e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("audit: marshal event: %v", err)
+	}
+	req, err := http.NewRequest("POST", w.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("audit: create webhook request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("audit: post webhook: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("audit: webhook returned unexpected status %s", resp.Status)
+	}
+	return nil
+}
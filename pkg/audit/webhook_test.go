@@ -0,0 +1,46 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebhookSink(t *testing.T) {
+	var got Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("unexpected content type %q", ct)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode event: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	want := Event{
+		Type:      EventRefreshTokenReuse,
+		Severity:  SeverityHigh,
+		ClientID:  "example-app",
+		SourceIPs: []string{"10.0.0.1", "10.0.0.2"},
+	}
+	if err := NewWebhookSink(srv.URL).Emit(context.Background(), want); err != nil {
+		t.Fatalf("emit: %v", err)
+	}
+	if got.Type != want.Type || got.ClientID != want.ClientID || len(got.SourceIPs) != 2 {
+		t.Errorf("webhook received %+v, want %+v", got, want)
+	}
+}
+
+func TestWebhookSinkError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	if err := NewWebhookSink(srv.URL).Emit(context.Background(), Event{Type: EventAuthCodeReuse}); err == nil {
+		t.Error("expected error from failing webhook")
+	}
+}
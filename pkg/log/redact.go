@@ -0,0 +1,235 @@
+package log
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Kind is a category of personally identifiable information.
+type Kind string
+
+// Kinds of personally identifiable information.
+const (
+	KindEmail    Kind = "email"
+	KindUsername Kind = "username"
+	KindSubject  Kind = "subject"
+	KindIP       Kind = "ip"
+)
+
+// Mode is how a kind of personally identifiable information is redacted.
+type Mode string
+
+// Redaction modes.
+const (
+	// ModeFull replaces the whole value.
+	ModeFull Mode = "full"
+	// ModePartial keeps enough of the value to tell values apart at a
+	// glance: the first letter and domain of emails, the network of IPs and
+	// the first letters of other values.
+	ModePartial Mode = "partial"
+	// ModeHashed replaces the value with a short hash, so log lines about
+	// the same user can still be correlated.
+	ModeHashed Mode = "hashed"
+)
+
+// redactedValue replaces values redacted with ModeFull.
+const redactedValue = "[redacted]"
+
+// PII marks a logged value as personally identifiable information. Loggers
+// returned by NewRedactingLogger redact it, other loggers print the value.
+type PII struct {
+	Kind  Kind
+	Value string
+}
+
+func (p PII) String() string { return p.Value }
+
+// Email marks an email address.
+func Email(v string) PII { return PII{KindEmail, v} }
+
+// Username marks a username or other name identifying a user, such as an
+// LDAP DN.
+func Username(v string) PII { return PII{KindUsername, v} }
+
+// Subject marks the ID of a user.
+func Subject(v string) PII { return PII{KindSubject, v} }
+
+// IP marks an IP address.
+func IP(v string) PII { return PII{KindIP, v} }
+
+// PIIErrorf formats an error like fmt.Errorf. Loggers returned by
+// NewRedactingLogger redact the arguments marked with PII in its message,
+// also when it's wrapped by other errors with %w.
+func PIIErrorf(format string, args ...interface{}) error {
+	var pii []PII
+	for _, arg := range args {
+		if p, ok := arg.(PII); ok && p.Value != "" {
+			pii = append(pii, p)
+		}
+	}
+	return &piiError{fmt.Errorf(format, args...), pii}
+}
+
+type piiError struct {
+	err error
+	pii []PII
+}
+
+func (e *piiError) Error() string { return e.err.Error() }
+
+func (e *piiError) Unwrap() error { return errors.Unwrap(e.err) }
+
+// Redaction maps kinds of personally identifiable information to the mode
+// they're redacted with. Kinds without a mode are logged as is.
+type Redaction map[Kind]Mode
+
+// Validate checks that all kinds and modes are known.
+func (r Redaction) Validate() error {
+	for kind, mode := range r {
+		switch kind {
+		case KindEmail, KindUsername, KindSubject, KindIP:
+		default:
+			return fmt.Errorf("unknown kind of personal information %q", kind)
+		}
+		switch mode {
+		case ModeFull, ModePartial, ModeHashed:
+		default:
+			return fmt.Errorf("unknown redaction mode %q for %s", mode, kind)
+		}
+	}
+	return nil
+}
+
+// Redact returns the value of p redacted according to its kind.
+func (r Redaction) Redact(p PII) string {
+	if p.Value == "" {
+		return ""
+	}
+	switch r[p.Kind] {
+	case ModeFull:
+		return redactedValue
+	case ModeHashed:
+		v := p.Value
+		if p.Kind == KindEmail {
+			v = strings.ToLower(v)
+		}
+		sum := sha256.Sum256([]byte(v))
+		return "sha256:" + hex.EncodeToString(sum[:6])
+	case ModePartial:
+		return partial(p)
+	}
+	return p.Value
+}
+
+func partial(p PII) string {
+	switch p.Kind {
+	case KindEmail:
+		if i := strings.LastIndex(p.Value, "@"); i > 0 {
+			return p.Value[:1] + "***" + p.Value[i:]
+		}
+	case KindIP:
+		if ip := net.ParseIP(p.Value); ip != nil {
+			if ip4 := ip.To4(); ip4 != nil {
+				return ip4.Mask(net.CIDRMask(24, 32)).String() + "/24"
+			}
+			return ip.Mask(net.CIDRMask(48, 128)).String() + "/48"
+		}
+		return redactedValue
+	}
+	n := len(p.Value) / 4
+	if n > 3 {
+		n = 3
+	}
+	return p.Value[:n] + "***"
+}
+
+// NewRedactingLogger returns a logger which redacts PII arguments according
+// to r before passing them to l. Only values marked with PII, and errors
+// created by PIIErrorf, are redacted. Identifiers in other arguments are
+// logged as is.
+func NewRedactingLogger(l Logger, r Redaction) Logger {
+	return redactingLogger{l, r}
+}
+
+type redactingLogger struct {
+	l Logger
+	r Redaction
+}
+
+// Redact returns the value of p as l logs it. Use it for values that are
+// formatted before being logged, like fields of JSON documents.
+func Redact(l Logger, p PII) string {
+	if rl, ok := l.(redactingLogger); ok {
+		return rl.r.Redact(p)
+	}
+	return p.Value
+}
+
+func (rl redactingLogger) redact(args []interface{}) []interface{} {
+	var out []interface{}
+	for i, arg := range args {
+		var redacted string
+		switch v := arg.(type) {
+		case PII:
+			redacted = rl.r.Redact(v)
+		case error:
+			msg, ok := rl.r.redactError(v)
+			if !ok {
+				continue
+			}
+			redacted = msg
+		default:
+			continue
+		}
+		if out == nil {
+			out = append([]interface{}(nil), args...)
+		}
+		out[i] = redacted
+	}
+	if out == nil {
+		return args
+	}
+	return out
+}
+
+// redactError returns the message of err with the PII of the errors created
+// by PIIErrorf in its chain redacted. It reports false if there are none.
+func (r Redaction) redactError(err error) (string, bool) {
+	msg, found := err.Error(), false
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		pe, ok := e.(*piiError)
+		if !ok {
+			continue
+		}
+		found = true
+		for _, p := range pe.pii {
+			msg = strings.ReplaceAll(msg, p.Value, r.Redact(p))
+		}
+	}
+	return msg, found
+}
+
+func (rl redactingLogger) Debug(args ...interface{}) { rl.l.Debug(rl.redact(args)...) }
+func (rl redactingLogger) Info(args ...interface{})  { rl.l.Info(rl.redact(args)...) }
+func (rl redactingLogger) Warn(args ...interface{})  { rl.l.Warn(rl.redact(args)...) }
+func (rl redactingLogger) Error(args ...interface{}) { rl.l.Error(rl.redact(args)...) }
+
+func (rl redactingLogger) Debugf(format string, args ...interface{}) {
+	rl.l.Debugf(format, rl.redact(args)...)
+}
+
+func (rl redactingLogger) Infof(format string, args ...interface{}) {
+	rl.l.Infof(format, rl.redact(args)...)
+}
+
+func (rl redactingLogger) Warnf(format string, args ...interface{}) {
+	rl.l.Warnf(format, rl.redact(args)...)
+}
+
+func (rl redactingLogger) Errorf(format string, args ...interface{}) {
+	rl.l.Errorf(format, rl.redact(args)...)
+}
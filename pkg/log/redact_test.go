@@ -0,0 +1,95 @@
+package log
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		mode Mode
+		pii  PII
+		want string
+	}{
+		{"", Email("jane@example.com"), "jane@example.com"},
+		{ModeFull, Email("jane@example.com"), "[redacted]"},
+		{ModePartial, Email("jane@example.com"), "j***@example.com"},
+		{ModePartial, Email("not-an-email"), "not***"},
+		{ModePartial, IP("192.0.2.17"), "192.0.2.0/24"},
+		{ModePartial, IP("2001:db8:1:2::1"), "2001:db8:1::/48"},
+		{ModePartial, IP("garbage"), "[redacted]"},
+		{ModePartial, Username("janedoe"), "j***"},
+		{ModePartial, Subject("CgR1c2VyEgVsb2NhbA"), "CgR***"},
+		{ModeFull, Subject(""), ""},
+	}
+	for _, tc := range tests {
+		r := Redaction{tc.pii.Kind: tc.mode}
+		if got := r.Redact(tc.pii); got != tc.want {
+			t.Errorf("%s %s %q: expected %q, got %q", tc.mode, tc.pii.Kind, tc.pii.Value, tc.want, got)
+		}
+	}
+
+	r := Redaction{KindEmail: ModeHashed}
+	hashed := r.Redact(Email("jane@example.com"))
+	if !strings.HasPrefix(hashed, "sha256:") || strings.Contains(hashed, "jane") {
+		t.Errorf("unexpected hash %q", hashed)
+	}
+	if other := r.Redact(Email("Jane@Example.com")); other != hashed {
+		t.Errorf("expected emails to be hashed case insensitively, got %q and %q", hashed, other)
+	}
+}
+
+func TestRedactionValidate(t *testing.T) {
+	if err := (Redaction{KindEmail: ModePartial, KindIP: ModeHashed}).Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := (Redaction{"phone": ModeFull}).Validate(); err == nil {
+		t.Error("expected unknown kind to be rejected")
+	}
+	if err := (Redaction{KindEmail: "mask"}).Validate(); err == nil {
+		t.Error("expected unknown mode to be rejected")
+	}
+}
+
+type recordingLogger struct {
+	Logger
+	lines []string
+}
+
+func (r *recordingLogger) Infof(format string, args ...interface{}) {
+	r.lines = append(r.lines, fmt.Sprintf(format, args...))
+}
+
+func TestRedactingLogger(t *testing.T) {
+	rec := new(recordingLogger)
+	l := NewRedactingLogger(rec, Redaction{KindEmail: ModeFull, KindIP: ModePartial})
+
+	args := []interface{}{Email("jane@example.com"), IP("192.0.2.17"), Username("jane")}
+	l.Infof("login email=%q ip=%s username=%s", args...)
+	if want := `login email="[redacted]" ip=192.0.2.0/24 username=jane`; rec.lines[0] != want {
+		t.Errorf("expected %q, got %q", want, rec.lines[0])
+	}
+	if args[0] != Email("jane@example.com") {
+		t.Error("redacting modified the caller's arguments")
+	}
+
+	// Without redaction, marked values print as is.
+	if got := fmt.Sprintf("%q", Email("jane@example.com")); got != `"jane@example.com"` {
+		t.Errorf("unexpected unredacted value %s", got)
+	}
+}
+
+func TestRedactingLoggerErrors(t *testing.T) {
+	rec := new(recordingLogger)
+	l := NewRedactingLogger(rec, Redaction{KindUsername: ModeFull})
+
+	err := PIIErrorf("user %q is not in any of the required groups", Username("jane"))
+	l.Infof("login failed: %v", fmt.Errorf("get groups: %w", err))
+	if want := `login failed: get groups: user "[redacted]" is not in any of the required groups`; rec.lines[0] != want {
+		t.Errorf("expected %q, got %q", want, rec.lines[0])
+	}
+	if want := `user "jane" is not in any of the required groups`; err.Error() != want {
+		t.Errorf("expected unredacted message %q, got %q", want, err.Error())
+	}
+}
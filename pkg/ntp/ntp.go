@@ -0,0 +1,100 @@
+// Package ntp implements a minimal SNTP client (RFC 4330) for measuring the
+// offset of the local clock.
+package ntp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// ntpEpochOffset is the number of seconds between the NTP epoch (1900) and
+// the Unix epoch (1970).
+const ntpEpochOffset = 2208988800
+
+// DefaultPort is the port used when an address doesn't specify one.
+const DefaultPort = "123"
+
+type packet struct {
+	Settings       uint8 // leap indicator, version and mode
+	Stratum        uint8
+	Poll           int8
+	Precision      int8
+	RootDelay      uint32
+	RootDispersion uint32
+	ReferenceID    uint32
+	RefTime        uint64
+	OrigTime       uint64
+	RxTime         uint64
+	TxTime         uint64
+}
+
+func toNTP(t time.Time) uint64 {
+	nsec := uint64(t.Sub(time.Unix(-ntpEpochOffset, 0)))
+	sec := nsec / 1e9
+	frac := (nsec - sec*1e9) << 32 / 1e9
+	return sec<<32 | frac
+}
+
+func fromNTP(v uint64) time.Time {
+	sec := int64(v >> 32)
+	frac := int64(v & 0xffffffff)
+	nsec := frac * 1e9 >> 32
+	return time.Unix(sec-ntpEpochOffset, nsec)
+}
+
+// Offset queries the NTP server at addr and returns how far the local clock
+// is behind the server's. A negative offset means the local clock is ahead.
+//
+// If addr has no port, DefaultPort is used.
+func Offset(ctx context.Context, addr string) (time.Duration, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, DefaultPort)
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", addr)
+	if err != nil {
+		return 0, fmt.Errorf("ntp: dial %s: %v", addr, err)
+	}
+	defer conn.Close()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(5 * time.Second)
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return 0, fmt.Errorf("ntp: set deadline: %v", err)
+	}
+
+	// Version 4, client mode.
+	req := packet{Settings: 4<<3 | 3}
+	t0 := time.Now()
+	req.TxTime = toNTP(t0)
+	if err := binary.Write(conn, binary.BigEndian, &req); err != nil {
+		return 0, fmt.Errorf("ntp: send request: %v", err)
+	}
+
+	var resp packet
+	if err := binary.Read(conn, binary.BigEndian, &resp); err != nil {
+		return 0, fmt.Errorf("ntp: read response: %v", err)
+	}
+	t3 := time.Now()
+
+	if resp.Settings&0x7 != 4 {
+		return 0, fmt.Errorf("ntp: unexpected mode %d in response", resp.Settings&0x7)
+	}
+	if resp.Stratum == 0 {
+		return 0, errors.New("ntp: server sent kiss-of-death response")
+	}
+	if resp.OrigTime != req.TxTime {
+		return 0, errors.New("ntp: response doesn't match request")
+	}
+
+	t1 := fromNTP(resp.RxTime)
+	t2 := fromNTP(resp.TxTime)
+	return (t1.Sub(t0) + t2.Sub(t3)) / 2, nil
+}
@@ -0,0 +1,84 @@
+package ntp
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+// serve answers a single SNTP request with a clock that's skew ahead of the
+// local one.
+func serve(t *testing.T, conn net.PacketConn, skew time.Duration, stratum uint8) {
+	buf := make([]byte, 48)
+	n, addr, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Errorf("read request: %v", err)
+		return
+	}
+	var req packet
+	if err := binary.Read(bytes.NewReader(buf[:n]), binary.BigEndian, &req); err != nil {
+		t.Errorf("parse request: %v", err)
+		return
+	}
+
+	now := time.Now().Add(skew)
+	resp := packet{
+		Settings: 4<<3 | 4,
+		Stratum:  stratum,
+		OrigTime: req.TxTime,
+		RxTime:   toNTP(now),
+		TxTime:   toNTP(now),
+	}
+	var out bytes.Buffer
+	binary.Write(&out, binary.BigEndian, &resp)
+	if _, err := conn.WriteTo(out.Bytes(), addr); err != nil {
+		t.Errorf("write response: %v", err)
+	}
+}
+
+func TestOffset(t *testing.T) {
+	tests := []struct {
+		name    string
+		skew    time.Duration
+		stratum uint8
+		wantErr bool
+	}{
+		{name: "ahead", skew: 2 * time.Minute, stratum: 2},
+		{name: "behind", skew: -90 * time.Second, stratum: 2},
+		{name: "kiss of death", stratum: 0, wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			go serve(t, conn, tc.skew, tc.stratum)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			offset, err := Offset(ctx, conn.LocalAddr().String())
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("wanted error=%t, got %v", tc.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if diff := offset - tc.skew; diff < -time.Second || diff > time.Second {
+				t.Errorf("expected offset of about %s, got %s", tc.skew, offset)
+			}
+		})
+	}
+}
+
+func TestNTPTimeRoundTrip(t *testing.T) {
+	now := time.Now()
+	got := fromNTP(toNTP(now))
+	if diff := got.Sub(now); diff < -time.Microsecond || diff > time.Microsecond {
+		t.Errorf("expected %s, got %s", now, got)
+	}
+}
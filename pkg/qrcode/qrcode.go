@@ -0,0 +1,517 @@
+// Package qrcode encodes short strings, such as the otpauth URIs authenticator
+// apps are provisioned with, as QR codes.
+//
+// Only what such strings need is implemented: data is encoded in byte mode
+// with error correction level M, in versions 1 to 10, which holds up to 213
+// bytes. The encoding follows ISO/IEC 18004.
+package qrcode
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	maxVersion = 10
+
+	// quietZone is the width of the light border around the code, in
+	// modules.
+	quietZone = 4
+)
+
+// Error correction codewords per block and number of blocks of each version,
+// at error correction level M.
+var (
+	eccPerBlock = [maxVersion + 1]int{-1, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26}
+	numBlocks   = [maxVersion + 1]int{-1, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5}
+)
+
+// Format information of level M, its error correction bits are appended by
+// formatBits.
+const eclM = 0
+
+// ErrTooLong is returned for data which doesn't fit in the largest supported
+// version.
+var ErrTooLong = errors.New("qrcode: data too long")
+
+// Code is an encoded QR code.
+type Code struct {
+	// Size is the number of modules on each side, without the quiet zone.
+	Size int
+
+	version  int
+	modules  [][]bool
+	function [][]bool
+}
+
+// Dark reports whether the module at column x and row y is dark.
+func (c *Code) Dark(x, y int) bool {
+	return c.modules[y][x]
+}
+
+// Encode returns the smallest QR code holding data.
+func Encode(data []byte) (*Code, error) {
+	version := 1
+	for ; version <= maxVersion; version++ {
+		if 4+countBits(version)+8*len(data) <= 8*numDataCodewords(version) {
+			break
+		}
+	}
+	if version > maxVersion {
+		return nil, ErrTooLong
+	}
+
+	// A single byte mode segment, followed by the terminator and padding.
+	var b bitBuffer
+	b.append(0x4, 4)
+	b.append(uint32(len(data)), countBits(version))
+	for _, d := range data {
+		b.append(uint32(d), 8)
+	}
+	capacity := 8 * numDataCodewords(version)
+	terminator := capacity - len(b)
+	if terminator > 4 {
+		terminator = 4
+	}
+	b.append(0, terminator)
+	b.append(0, (8-len(b)%8)%8)
+	for pad := uint32(0xec); len(b) < capacity; pad ^= 0xec ^ 0x11 {
+		b.append(pad, 8)
+	}
+
+	c := newCode(version)
+	c.drawFunctionPatterns()
+	c.drawCodewords(addECCAndInterleave(b.bytes(), version))
+
+	// Use the mask which makes the code easiest to read.
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormatBits(mask)
+		if p := c.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		c.applyMask(mask)
+	}
+	c.applyMask(best)
+	c.drawFormatBits(best)
+	return c, nil
+}
+
+// SVG returns the code as an SVG image, including the quiet zone. Modules
+// are one unit wide, the image is meant to be scaled with CSS.
+func (c *Code) SVG() string {
+	n := c.Size + 2*quietZone
+	var path strings.Builder
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.modules[y][x] {
+				fmt.Fprintf(&path, "M%d,%dh1v1h-1z", x+quietZone, y+quietZone)
+			}
+		}
+	}
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+
+		`<rect width="100%%" height="100%%" fill="#ffffff"/><path d="%s" fill="#000000"/></svg>`, n, n, path.String())
+}
+
+// countBits is the length of the character count of byte mode segments.
+func countBits(version int) int {
+	if version < 10 {
+		return 8
+	}
+	return 16
+}
+
+// numRawDataModules is the number of modules which aren't part of function
+// patterns, including remainder bits.
+func numRawDataModules(version int) int {
+	n := (16*version+128)*version + 64
+	if version >= 2 {
+		numAlign := version/7 + 2
+		n -= (25*numAlign-10)*numAlign - 55
+		if version >= 7 {
+			n -= 36
+		}
+	}
+	return n
+}
+
+func numDataCodewords(version int) int {
+	return numRawDataModules(version)/8 - eccPerBlock[version]*numBlocks[version]
+}
+
+// alignmentPositions returns the coordinates of the centers of alignment
+// patterns, in both directions.
+func alignmentPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	numAlign := version/7 + 2
+	step := (version*4 + numAlign*2 + 1) / (numAlign*2 - 2) * 2
+	positions := make([]int, numAlign)
+	positions[0] = 6
+	for i, pos := numAlign-1, version*4+10; i > 0; i, pos = i-1, pos-step {
+		positions[i] = pos
+	}
+	return positions
+}
+
+func newCode(version int) *Code {
+	size := version*4 + 17
+	c := &Code{Size: size, version: version}
+	c.modules = make([][]bool, size)
+	c.function = make([][]bool, size)
+	for i := range c.modules {
+		c.modules[i] = make([]bool, size)
+		c.function[i] = make([]bool, size)
+	}
+	return c
+}
+
+func (c *Code) setFunction(x, y int, dark bool) {
+	c.modules[y][x] = dark
+	c.function[y][x] = true
+}
+
+func (c *Code) drawFunctionPatterns() {
+	for i := 0; i < c.Size; i++ {
+		c.setFunction(6, i, i%2 == 0)
+		c.setFunction(i, 6, i%2 == 0)
+	}
+
+	// Finder patterns and their separators.
+	for _, p := range [][2]int{{3, 3}, {c.Size - 4, 3}, {3, c.Size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := p[0]+dx, p[1]+dy
+				if x < 0 || x >= c.Size || y < 0 || y >= c.Size {
+					continue
+				}
+				d := max(abs(dx), abs(dy))
+				c.setFunction(x, y, d != 2 && d != 4)
+			}
+		}
+	}
+
+	// Alignment patterns, except where they'd overlap finder patterns.
+	positions := alignmentPositions(c.version)
+	last := len(positions) - 1
+	for i := range positions {
+		for j := range positions {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					c.setFunction(positions[i]+dx, positions[j]+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	// Reserve the format bits until a mask is chosen.
+	c.drawFormatBits(0)
+	c.drawVersion()
+}
+
+// formatBits returns the format information of a mask, with its error
+// correction bits.
+func formatBits(mask int) uint32 {
+	data := uint32(eclM<<3 | mask)
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+func (c *Code) drawFormatBits(mask int) {
+	bits := formatBits(mask)
+
+	// Around the top left finder pattern.
+	for i := 0; i <= 5; i++ {
+		c.setFunction(8, i, bit(bits, i))
+	}
+	c.setFunction(8, 7, bit(bits, 6))
+	c.setFunction(8, 8, bit(bits, 7))
+	c.setFunction(7, 8, bit(bits, 8))
+	for i := 9; i < 15; i++ {
+		c.setFunction(14-i, 8, bit(bits, i))
+	}
+
+	// Along the other finder patterns.
+	for i := 0; i < 8; i++ {
+		c.setFunction(c.Size-1-i, 8, bit(bits, i))
+	}
+	for i := 8; i < 15; i++ {
+		c.setFunction(8, c.Size-15+i, bit(bits, i))
+	}
+	c.setFunction(8, c.Size-8, true)
+}
+
+func (c *Code) drawVersion() {
+	if c.version < 7 {
+		return
+	}
+	rem := uint32(c.version)
+	for i := 0; i < 12; i++ {
+		rem = rem<<1 ^ (rem>>11)*0x1f25
+	}
+	bits := uint32(c.version)<<12 | rem
+	for i := 0; i < 18; i++ {
+		a, b := c.Size-11+i%3, i/3
+		c.setFunction(a, b, bit(bits, i))
+		c.setFunction(b, a, bit(bits, i))
+	}
+}
+
+// drawCodewords places the data and error correction codewords in the zigzag
+// order of the standard, skipping function patterns.
+func (c *Code) drawCodewords(data []byte) {
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			// The vertical timing pattern.
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert
+				}
+				if !c.function[y][x] && i < len(data)*8 {
+					c.modules[y][x] = data[i>>3]>>(7-uint(i&7))&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+// applyMask inverts the data modules selected by the mask. Applying a mask
+// twice undoes it.
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !c.function[y][x] && masked(mask, x, y) {
+				c.modules[y][x] = !c.modules[y][x]
+			}
+		}
+	}
+}
+
+func masked(mask, x, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	default:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+}
+
+// finderLike are the module patterns which could be mistaken for finder
+// patterns.
+var finderLike = [][]bool{
+	{true, false, true, true, true, false, true, false, false, false, false},
+	{false, false, false, false, true, false, true, true, true, false, true},
+}
+
+// penalty scores how hard the code is to read, following the rules of the
+// standard.
+func (c *Code) penalty() int {
+	p := 0
+	line := make([]bool, c.Size)
+	for _, vertical := range []bool{false, true} {
+		for i := 0; i < c.Size; i++ {
+			for j := range line {
+				if vertical {
+					line[j] = c.modules[j][i]
+				} else {
+					line[j] = c.modules[i][j]
+				}
+			}
+
+			// Runs of five or more modules of the same color.
+			run := 1
+			for j := 1; j <= c.Size; j++ {
+				if j < c.Size && line[j] == line[j-1] {
+					run++
+					continue
+				}
+				if run >= 5 {
+					p += run - 2
+				}
+				run = 1
+			}
+
+			for j := 0; j+11 <= c.Size; j++ {
+				for _, pattern := range finderLike {
+					match := true
+					for k, dark := range pattern {
+						if line[j+k] != dark {
+							match = false
+							break
+						}
+					}
+					if match {
+						p += 40
+					}
+				}
+			}
+		}
+	}
+
+	// Blocks of two by two modules of the same color.
+	dark := 0
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.modules[y][x] {
+				dark++
+			}
+			if x+1 < c.Size && y+1 < c.Size {
+				m := c.modules[y][x]
+				if m == c.modules[y][x+1] && m == c.modules[y+1][x] && m == c.modules[y+1][x+1] {
+					p += 3
+				}
+			}
+		}
+	}
+
+	// Balance of dark and light modules.
+	total := c.Size * c.Size
+	p += abs(dark*20-total*10) / total * 10
+	return p
+}
+
+// addECCAndInterleave splits the data codewords in blocks, appends error
+// correction codewords to each and interleaves the blocks.
+func addECCAndInterleave(data []byte, version int) []byte {
+	blocks := numBlocks[version]
+	eccLen := eccPerBlock[version]
+	raw := numRawDataModules(version) / 8
+	numShort := blocks - raw%blocks
+	shortLen := raw / blocks
+
+	divisor := rsDivisor(eccLen)
+	all := make([][]byte, blocks)
+	k := 0
+	for i := range all {
+		n := shortLen - eccLen
+		if i >= numShort {
+			n++
+		}
+		block := make([]byte, 0, shortLen+1)
+		block = append(block, data[k:k+n]...)
+		k += n
+		ecc := rsRemainder(block, divisor)
+		if i < numShort {
+			// Padding so that all blocks are the same length, skipped
+			// when interleaving.
+			block = append(block, 0)
+		}
+		all[i] = append(block, ecc...)
+	}
+
+	result := make([]byte, 0, raw)
+	for i := range all[0] {
+		for j, block := range all {
+			if i != shortLen-eccLen || j >= numShort {
+				result = append(result, block[i])
+			}
+		}
+	}
+	return result
+}
+
+// rsDivisor returns the generator polynomial of a Reed-Solomon code of the
+// degree, without its leading term, highest coefficients first.
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMul(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMul(root, 0x02)
+	}
+	return result
+}
+
+// rsRemainder returns the error correction codewords of data.
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, d := range divisor {
+			result[i] ^= gfMul(d, factor)
+		}
+	}
+	return result
+}
+
+// gfMul multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1.
+func gfMul(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = z<<1 ^ (z>>7)*0x11d
+		z ^= int(y>>uint(i)&1) * int(x)
+	}
+	return byte(z)
+}
+
+type bitBuffer []bool
+
+func (b *bitBuffer) append(v uint32, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*b = append(*b, v>>uint(i)&1 == 1)
+	}
+}
+
+func (b bitBuffer) bytes() []byte {
+	result := make([]byte, len(b)/8)
+	for i, set := range b {
+		if set {
+			result[i/8] |= 0x80 >> uint(i%8)
+		}
+	}
+	return result
+}
+
+func bit(v uint32, i int) bool {
+	return v>>uint(i)&1 == 1
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func max(x, y int) int {
+	if x > y {
+		return x
+	}
+	return y
+}
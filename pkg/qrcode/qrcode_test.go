@@ -0,0 +1,200 @@
+package qrcode
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// decode reads the data back from a code, checking its format information
+// and error correction codewords on the way.
+func decode(t *testing.T, c *Code) []byte {
+	t.Helper()
+	version := (c.Size - 17) / 4
+
+	// Format information around the top left finder pattern.
+	var format uint32
+	for i := 0; i <= 5; i++ {
+		format |= b2u(c.Dark(8, i)) << uint(i)
+	}
+	format |= b2u(c.Dark(8, 7))<<6 | b2u(c.Dark(8, 8))<<7 | b2u(c.Dark(7, 8))<<8
+	for i := 9; i < 15; i++ {
+		format |= b2u(c.Dark(14-i, 8)) << uint(i)
+	}
+	mask := -1
+	for m := 0; m < 8; m++ {
+		if formatBits(m) == format {
+			mask = m
+		}
+	}
+	if mask < 0 {
+		t.Fatalf("unknown format information %015b", format)
+	}
+	// The copy along the other finder patterns.
+	for i := 0; i < 8; i++ {
+		if c.Dark(c.Size-1-i, 8) != bit(format, i) {
+			t.Fatalf("format information copies differ")
+		}
+	}
+
+	// Read the codewords with a fresh copy of the function patterns.
+	ref := newCode(version)
+	ref.drawFunctionPatterns()
+	var raw []byte
+	var cur byte
+	n := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert
+				}
+				if ref.function[y][x] {
+					continue
+				}
+				cur = cur<<1 | byte(b2u(c.Dark(x, y) != masked(mask, x, y)))
+				if n++; n%8 == 0 {
+					raw = append(raw, cur)
+				}
+			}
+		}
+	}
+
+	// Deinterleave the blocks and check their syndromes.
+	blocks := numBlocks[version]
+	eccLen := eccPerBlock[version]
+	total := numRawDataModules(version) / 8
+	raw = raw[:total]
+	numShort := blocks - total%blocks
+	shortLen := total / blocks
+	all := make([][]byte, blocks)
+	k := 0
+	for i := 0; i < shortLen+1; i++ {
+		for j := range all {
+			if i == shortLen-eccLen && j < numShort {
+				continue
+			}
+			all[j] = append(all[j], raw[k])
+			k++
+		}
+	}
+	var data []byte
+	for _, block := range all {
+		root := byte(1)
+		for i := 0; i < eccLen; i++ {
+			var s byte
+			for _, b := range block {
+				s = gfMul(s, root) ^ b
+			}
+			if s != 0 {
+				t.Fatalf("block has non-zero syndrome %d", i)
+			}
+			root = gfMul(root, 0x02)
+		}
+		data = append(data, block[:len(block)-eccLen]...)
+	}
+
+	// A single byte mode segment.
+	var bits bitBuffer
+	for _, d := range data {
+		bits.append(uint32(d), 8)
+	}
+	read := func(n int) int {
+		v := 0
+		for i := 0; i < n; i++ {
+			v = v<<1 | int(b2u(bits[0]))
+			bits = bits[1:]
+		}
+		return v
+	}
+	if mode := read(4); mode != 0x4 {
+		t.Fatalf("expected byte mode, got %04b", mode)
+	}
+	length := read(countBits(version))
+	result := make([]byte, length)
+	for i := range result {
+		result[i] = byte(read(8))
+	}
+	return result
+}
+
+func b2u(b bool) uint32 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func TestEncode(t *testing.T) {
+	uri := "otpauth://totp/dex:jane@example.com?algorithm=SHA1&digits=6&issuer=dex&period=30&secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	tests := []struct {
+		data    string
+		version int
+	}{
+		{"", 1},
+		{"12345678901234", 1},
+		{"123456789012345", 2},
+		{uri, 7},
+		{strings.Repeat("a", 152), 8},
+		{strings.Repeat("a", 153), 9},
+		{strings.Repeat("b", 213), 10},
+	}
+	for _, tc := range tests {
+		c, err := Encode([]byte(tc.data))
+		if err != nil {
+			t.Fatalf("encode %d bytes: %v", len(tc.data), err)
+		}
+		if c.version != tc.version || c.Size != tc.version*4+17 {
+			t.Errorf("expected %d bytes to be encoded in version %d, got %d", len(tc.data), tc.version, c.version)
+		}
+		if got := decode(t, c); !bytes.Equal(got, []byte(tc.data)) {
+			t.Errorf("expected to decode %q, got %q", tc.data, got)
+		}
+	}
+
+	if _, err := Encode(bytes.Repeat([]byte("c"), 214)); err != ErrTooLong {
+		t.Errorf("expected too long data to be refused, got %v", err)
+	}
+}
+
+func TestAlignmentPositions(t *testing.T) {
+	want := map[int][]int{
+		1:  nil,
+		2:  {6, 18},
+		6:  {6, 34},
+		7:  {6, 22, 38},
+		10: {6, 28, 50},
+	}
+	for version, positions := range want {
+		got := alignmentPositions(version)
+		if len(got) != len(positions) {
+			t.Errorf("version %d: expected %v, got %v", version, positions, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != positions[i] {
+				t.Errorf("version %d: expected %v, got %v", version, positions, got)
+				break
+			}
+		}
+	}
+}
+
+func TestSVG(t *testing.T) {
+	c, err := Encode([]byte("dex"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	svg := c.SVG()
+	if !strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 29 29"`) {
+		t.Errorf("unexpected SVG %s", svg)
+	}
+	// The top left module of the finder pattern, past the quiet zone.
+	if !strings.Contains(svg, `d="M4,4h1v1h-1z`) {
+		t.Errorf("expected the finder pattern to be drawn, got %s", svg)
+	}
+}
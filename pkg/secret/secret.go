@@ -0,0 +1,181 @@
+// Package secret hashes secrets, such as client secrets, for storage and
+// verifies secrets against stored hashes.
+//
+// Hashes are encoded in the modular crypt format: bcrypt hashes start with
+// "$2a$", "$2b$" or "$2y$", argon2id hashes use the PHC string format
+// "$argon2id$v=19$m=<memory>,t=<iterations>,p=<parallelism>$<salt>$<hash>".
+package secret
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Hasher hashes secrets.
+type Hasher interface {
+	// Hash returns the encoded hash of the secret.
+	Hash(secret []byte) (string, error)
+	// NeedsRehash reports whether the encoded hash was produced by another
+	// algorithm or with other parameters than this hasher uses, and should
+	// be replaced by a new hash once the secret is known.
+	NeedsRehash(encoded string) bool
+}
+
+// IsHash reports whether the value is a hash in a format supported by
+// Verify.
+func IsHash(value string) bool {
+	return isBcrypt(value) || strings.HasPrefix(value, argon2idPrefix)
+}
+
+// Verify reports whether the secret matches the encoded hash.
+func Verify(encoded string, secret []byte) (bool, error) {
+	switch {
+	case isBcrypt(encoded):
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), secret)
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return false, nil
+		}
+		return err == nil, err
+	case strings.HasPrefix(encoded, argon2idPrefix):
+		p, salt, key, err := decodeArgon2id(encoded)
+		if err != nil {
+			return false, err
+		}
+		got := argon2.IDKey(secret, salt, p.Iterations, p.Memory, p.Parallelism, uint32(len(key)))
+		return subtle.ConstantTimeCompare(got, key) == 1, nil
+	}
+	return false, errors.New("secret: unsupported hash format")
+}
+
+func isBcrypt(value string) bool {
+	return strings.HasPrefix(value, "$2a$") || strings.HasPrefix(value, "$2b$") || strings.HasPrefix(value, "$2y$")
+}
+
+// NewBcrypt returns a hasher using bcrypt with the given cost. A cost of
+// zero uses bcrypt.DefaultCost.
+func NewBcrypt(cost int) (Hasher, error) {
+	if cost == 0 {
+		cost = bcrypt.DefaultCost
+	}
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("secret: bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	return bcryptHasher(cost), nil
+}
+
+type bcryptHasher int
+
+func (b bcryptHasher) Hash(secret []byte) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword(secret, int(b))
+	return string(hash), err
+}
+
+func (b bcryptHasher) NeedsRehash(encoded string) bool {
+	if !isBcrypt(encoded) {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != int(b)
+}
+
+const argon2idPrefix = "$argon2id$"
+
+// Argon2idParams are the parameters of argon2id. Zero values are replaced by
+// the defaults recommended by RFC 9106 for memory constrained environments.
+type Argon2idParams struct {
+	// Memory in KiB. Defaults to 64 MiB.
+	Memory uint32
+	// Number of passes over the memory. Defaults to 3.
+	Iterations uint32
+	// Number of threads. Defaults to 4.
+	Parallelism uint8
+}
+
+func (p Argon2idParams) withDefaults() Argon2idParams {
+	if p.Memory == 0 {
+		p.Memory = 64 * 1024
+	}
+	if p.Iterations == 0 {
+		p.Iterations = 3
+	}
+	if p.Parallelism == 0 {
+		p.Parallelism = 4
+	}
+	return p
+}
+
+// NewArgon2id returns a hasher using argon2id.
+func NewArgon2id(p Argon2idParams) (Hasher, error) {
+	p = p.withDefaults()
+	if p.Memory < 8*uint32(p.Parallelism) {
+		return nil, errors.New("secret: argon2id memory must be at least 8 KiB per thread")
+	}
+	return argon2idHasher(p), nil
+}
+
+type argon2idHasher Argon2idParams
+
+const (
+	argon2idSaltLen = 16
+	argon2idKeyLen  = 32
+)
+
+func (a argon2idHasher) Hash(secret []byte) (string, error) {
+	salt := make([]byte, argon2idSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey(secret, salt, a.Iterations, a.Memory, a.Parallelism, argon2idKeyLen)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version,
+		a.Memory, a.Iterations, a.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (a argon2idHasher) NeedsRehash(encoded string) bool {
+	if !strings.HasPrefix(encoded, argon2idPrefix) {
+		return true
+	}
+	p, _, _, err := decodeArgon2id(encoded)
+	return err != nil || p != Argon2idParams(a)
+}
+
+// ParseArgon2id returns the parameters of an encoded argon2id hash.
+func ParseArgon2id(encoded string) (Argon2idParams, error) {
+	if !strings.HasPrefix(encoded, argon2idPrefix) {
+		return Argon2idParams{}, errors.New("secret: not an argon2id hash")
+	}
+	p, _, _, err := decodeArgon2id(encoded)
+	return p, err
+}
+
+func decodeArgon2id(encoded string) (p Argon2idParams, salt, key []byte, err error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return p, nil, nil, errors.New("secret: malformed argon2id hash")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, errors.New("secret: unsupported argon2id version")
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return p, nil, nil, errors.New("secret: malformed argon2id parameters")
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return p, nil, nil, errors.New("secret: malformed argon2id salt")
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(key) == 0 {
+		return p, nil, nil, errors.New("secret: malformed argon2id hash")
+	}
+	if p.Iterations == 0 || p.Parallelism == 0 {
+		return p, nil, nil, errors.New("secret: malformed argon2id parameters")
+	}
+	return p, salt, key, nil
+}
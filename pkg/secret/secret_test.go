@@ -0,0 +1,109 @@
+package secret
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestHashers(t *testing.T) {
+	bcryptHasher, err := NewBcrypt(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	argon2idHasher, err := NewArgon2id(Argon2idParams{Memory: 1024, Iterations: 1, Parallelism: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, h := range map[string]Hasher{"bcrypt": bcryptHasher, "argon2id": argon2idHasher} {
+		t.Run(name, func(t *testing.T) {
+			hash, err := h.Hash([]byte("s3cret"))
+			if err != nil {
+				t.Fatalf("hash: %v", err)
+			}
+			if !IsHash(hash) {
+				t.Fatalf("expected %q to be recognized as a hash", hash)
+			}
+			if ok, err := Verify(hash, []byte("s3cret")); err != nil || !ok {
+				t.Errorf("expected secret to verify, got %t, %v", ok, err)
+			}
+			if ok, err := Verify(hash, []byte("other")); err != nil || ok {
+				t.Errorf("expected other secret not to verify, got %t, %v", ok, err)
+			}
+			if h.NeedsRehash(hash) {
+				t.Errorf("expected hash to match the hasher's parameters")
+			}
+		})
+	}
+
+	bcryptHash, _ := bcryptHasher.Hash([]byte("s3cret"))
+	if !argon2idHasher.NeedsRehash(bcryptHash) {
+		t.Error("expected bcrypt hash to need rehashing with argon2id")
+	}
+	stronger, _ := NewArgon2id(Argon2idParams{Memory: 2048, Iterations: 1, Parallelism: 1})
+	argon2idHash, _ := argon2idHasher.Hash([]byte("s3cret"))
+	if !stronger.NeedsRehash(argon2idHash) {
+		t.Error("expected argon2id hash to need rehashing when parameters change")
+	}
+	if !strings.HasPrefix(argon2idHash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Errorf("unexpected argon2id encoding %q", argon2idHash)
+	}
+	if p, err := ParseArgon2id(argon2idHash); err != nil || p != (Argon2idParams{Memory: 1024, Iterations: 1, Parallelism: 1}) {
+		t.Errorf("unexpected argon2id parameters %+v, %v", p, err)
+	}
+}
+
+func TestVerifyMalformed(t *testing.T) {
+	for _, encoded := range []string{
+		"plaintext",
+		"$argon2id$v=19$m=1024,t=1,p=1$c2FsdA",
+		"$argon2id$v=18$m=1024,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=1024,t=0,p=1$c2FsdA$a2V5",
+	} {
+		if ok, err := Verify(encoded, []byte("s3cret")); ok || err == nil {
+			t.Errorf("%q: expected an error, got %t, %v", encoded, ok, err)
+		}
+	}
+}
+
+// The benchmarks report the time a single hash takes with common parameters.
+// Pick parameters that keep a login well below a second on production
+// hardware, for example with:
+//
+//	go test -run=NONE -bench=. ./pkg/secret
+func BenchmarkBcrypt(b *testing.B) {
+	for _, cost := range []int{10, 12, 14} {
+		h, err := NewBcrypt(cost)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(fmt.Sprintf("cost=%d", cost), func(b *testing.B) {
+			benchmarkHasher(b, h)
+		})
+	}
+}
+
+func BenchmarkArgon2id(b *testing.B) {
+	for _, p := range []Argon2idParams{
+		{Memory: 19 * 1024, Iterations: 2, Parallelism: 1},
+		{Memory: 64 * 1024, Iterations: 3, Parallelism: 4},
+		{Memory: 256 * 1024, Iterations: 3, Parallelism: 4},
+	} {
+		h, err := NewArgon2id(p)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(fmt.Sprintf("m=%d,t=%d,p=%d", p.Memory, p.Iterations, p.Parallelism), func(b *testing.B) {
+			benchmarkHasher(b, h)
+		})
+	}
+}
+
+func benchmarkHasher(b *testing.B, h Hasher) {
+	for i := 0; i < b.N; i++ {
+		if _, err := h.Hash([]byte("s3cret")); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// Package totp implements time-based one-time passwords (RFC 6238), as shown
+// by authenticator apps.
+//
+// Only the parameters every app supports are implemented: codes are six
+// digits long, derived with HMAC-SHA1 from the time in 30 second steps.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+const (
+	// Digits is the length of codes.
+	Digits = 6
+	// Period is the time each code is valid for.
+	Period = 30 * time.Second
+
+	secretSize = 20
+	// skew is the number of steps codes may be early or late, to allow for
+	// clock drift and slow typists.
+	skew = 1
+)
+
+// encoding is the unpadded base32 encoding apps expect secrets in.
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewSecret returns a new random secret.
+func NewSecret() ([]byte, error) {
+	secret := make([]byte, secretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("totp: generate secret: %v", err)
+	}
+	return secret, nil
+}
+
+// Encode returns the secret as users type it into apps which can't scan QR
+// codes.
+func Encode(secret []byte) string {
+	return encoding.EncodeToString(secret)
+}
+
+// URI returns the otpauth URI apps are provisioned with, usually through a
+// QR code. The issuer and account name are shown in the app.
+func URI(secret []byte, issuer, account string) string {
+	u := url.URL{
+		Scheme: "otpauth",
+		Host:   "totp",
+		Path:   "/" + issuer + ":" + account,
+	}
+	q := url.Values{}
+	q.Set("secret", Encode(secret))
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(Digits))
+	q.Set("period", fmt.Sprint(int(Period/time.Second)))
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// Step returns the time step of t.
+func Step(t time.Time) int64 {
+	return t.Unix() / int64(Period/time.Second)
+}
+
+// Code returns the code of a time step.
+func Code(secret []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation, RFC 4226 section 5.3.
+	offset := sum[len(sum)-1] & 0x0f
+	n := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", Digits, n%1000000)
+}
+
+// Validate checks a code entered at now. Codes of steps up to lastStep are
+// refused, so that a code can't be used twice. It returns the step of the
+// code, which callers must store as the new lastStep.
+func Validate(secret []byte, code string, now time.Time, lastStep int64) (step int64, ok bool) {
+	if len(code) != Digits {
+		return 0, false
+	}
+	current := Step(now)
+	for s := current - skew; s <= current+skew; s++ {
+		if s <= lastStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(Code(secret, s)), []byte(code)) == 1 {
+			return s, true
+		}
+	}
+	return 0, false
+}
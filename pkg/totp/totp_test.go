@@ -0,0 +1,67 @@
+package totp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+// Test vectors of RFC 6238 appendix B, truncated to six digits.
+var rfcSecret = []byte("12345678901234567890")
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tc := range tests {
+		if got := Code(rfcSecret, Step(time.Unix(tc.unix, 0))); got != tc.want {
+			t.Errorf("code at %d: want %s, got %s", tc.unix, tc.want, got)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	step := Step(now)
+
+	got, ok := Validate(rfcSecret, "005924", now, 0)
+	if !ok || got != step {
+		t.Fatalf("expected current code to be valid at step %d, got %d, %v", step, got, ok)
+	}
+	if _, ok := Validate(rfcSecret, "005924", now, step); ok {
+		t.Errorf("expected code to be refused once used")
+	}
+	if got, ok := Validate(rfcSecret, Code(rfcSecret, step-1), now, 0); !ok || got != step-1 {
+		t.Errorf("expected previous code to be valid, got %d, %v", got, ok)
+	}
+	if got, ok := Validate(rfcSecret, Code(rfcSecret, step+1), now, step); !ok || got != step+1 {
+		t.Errorf("expected next code to be valid, got %d, %v", got, ok)
+	}
+	for _, code := range []string{Code(rfcSecret, step-2), Code(rfcSecret, step+2), "00592", "0059245", "abcdef"} {
+		if _, ok := Validate(rfcSecret, code, now, 0); ok {
+			t.Errorf("expected code %q to be refused", code)
+		}
+	}
+}
+
+func TestURI(t *testing.T) {
+	u, err := url.Parse(URI(rfcSecret, "Example", "jane@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/Example:jane@example.com" {
+		t.Errorf("unexpected URI %s", u)
+	}
+	q := u.Query()
+	if q.Get("secret") != "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" || q.Get("issuer") != "Example" || q.Get("digits") != "6" || q.Get("period") != "30" {
+		t.Errorf("unexpected parameters %v", q)
+	}
+}
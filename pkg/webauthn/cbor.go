@@ -0,0 +1,137 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// maxCBORDepth limits the nesting of decoded items, authenticators never
+// nest deeply.
+const maxCBORDepth = 16
+
+var errShortCBOR = errors.New("webauthn: unexpected end of CBOR data")
+
+// decodeCBOR decodes the first CBOR item of data and returns the bytes
+// following it. Only the subset of CBOR used by WebAuthn is supported:
+// integers, byte and text strings, arrays, maps and simple values. Integers
+// are returned as int64, maps as map[interface{}]interface{}.
+func decodeCBOR(data []byte) (interface{}, []byte, error) {
+	return decodeCBORItem(data, 0)
+}
+
+func decodeCBORItem(data []byte, depth int) (interface{}, []byte, error) {
+	if depth > maxCBORDepth {
+		return nil, nil, errors.New("webauthn: CBOR data nested too deeply")
+	}
+	if len(data) == 0 {
+		return nil, nil, errShortCBOR
+	}
+	major, info := data[0]>>5, data[0]&0x1f
+	data = data[1:]
+
+	// Simple values and floats carry their value in the additional
+	// information, which isn't a length.
+	if major == 7 {
+		switch info {
+		case 20:
+			return false, data, nil
+		case 21:
+			return true, data, nil
+		case 22, 23:
+			return nil, data, nil
+		}
+		return nil, nil, fmt.Errorf("webauthn: unsupported CBOR simple value %d", info)
+	}
+
+	var arg uint64
+	switch {
+	case info < 24:
+		arg = uint64(info)
+	case info == 24:
+		if len(data) < 1 {
+			return nil, nil, errShortCBOR
+		}
+		arg, data = uint64(data[0]), data[1:]
+	case info == 25:
+		if len(data) < 2 {
+			return nil, nil, errShortCBOR
+		}
+		arg, data = uint64(binary.BigEndian.Uint16(data)), data[2:]
+	case info == 26:
+		if len(data) < 4 {
+			return nil, nil, errShortCBOR
+		}
+		arg, data = uint64(binary.BigEndian.Uint32(data)), data[4:]
+	case info == 27:
+		if len(data) < 8 {
+			return nil, nil, errShortCBOR
+		}
+		arg, data = binary.BigEndian.Uint64(data), data[8:]
+	default:
+		// Indefinite lengths aren't allowed in the canonical encoding
+		// authenticators use.
+		return nil, nil, fmt.Errorf("webauthn: unsupported CBOR length encoding %d", info)
+	}
+
+	switch major {
+	case 0:
+		if arg > 1<<63-1 {
+			return nil, nil, errors.New("webauthn: CBOR integer overflows")
+		}
+		return int64(arg), data, nil
+	case 1:
+		if arg > 1<<63-1 {
+			return nil, nil, errors.New("webauthn: CBOR integer overflows")
+		}
+		return -1 - int64(arg), data, nil
+	case 2, 3:
+		if uint64(len(data)) < arg {
+			return nil, nil, errShortCBOR
+		}
+		b := make([]byte, arg)
+		copy(b, data)
+		if major == 3 {
+			return string(b), data[arg:], nil
+		}
+		return b, data[arg:], nil
+	case 4:
+		// Every item takes at least a byte.
+		if uint64(len(data)) < arg {
+			return nil, nil, errShortCBOR
+		}
+		items := make([]interface{}, arg)
+		for i := range items {
+			var err error
+			if items[i], data, err = decodeCBORItem(data, depth+1); err != nil {
+				return nil, nil, err
+			}
+		}
+		return items, data, nil
+	case 5:
+		if uint64(len(data)) < 2*arg {
+			return nil, nil, errShortCBOR
+		}
+		m := make(map[interface{}]interface{}, arg)
+		for i := uint64(0); i < arg; i++ {
+			var (
+				key, value interface{}
+				err        error
+			)
+			if key, data, err = decodeCBORItem(data, depth+1); err != nil {
+				return nil, nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, nil, fmt.Errorf("webauthn: unsupported CBOR map key %T", key)
+			}
+			if value, data, err = decodeCBORItem(data, depth+1); err != nil {
+				return nil, nil, err
+			}
+			m[key] = value
+		}
+		return m, data, nil
+	}
+	return nil, nil, fmt.Errorf("webauthn: unsupported CBOR major type %d", major)
+}
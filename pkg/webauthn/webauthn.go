@@ -0,0 +1,285 @@
+// Package webauthn verifies WebAuthn registrations and assertions, so passkeys
+// and security keys can be used as an authentication factor.
+//
+// Only what a relying party needs to check credentials is implemented:
+// attestation statements are not verified, every registration is treated as
+// if it used the "none" attestation format. Credential public keys are
+// returned and stored in their COSE encoding, ES256 and RS256 keys are
+// supported.
+package webauthn
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Authenticator data flags.
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttested     = 0x40
+)
+
+// COSE key parameters and algorithms.
+const (
+	coseKeyType   = 1
+	coseAlgorithm = 3
+	coseCurve     = -1
+	coseX         = -2
+	coseY         = -3
+	coseModulus   = -1
+	coseExponent  = -2
+
+	coseKeyTypeEC2 = 2
+	coseKeyTypeRSA = 3
+	coseCurveP256  = 1
+
+	// AlgES256 is ECDSA with P-256 and SHA-256.
+	AlgES256 = -7
+	// AlgRS256 is RSASSA-PKCS1-v1_5 with SHA-256.
+	AlgRS256 = -257
+)
+
+// RelyingParty holds the parameters credentials are scoped to.
+type RelyingParty struct {
+	// ID is the relying party ID, a domain credentials are bound to.
+	ID string
+	// Origins are the origins the browser may report for the ceremony.
+	Origins []string
+	// RequireUserVerification rejects ceremonies in which the authenticator
+	// didn't verify the user, for example with a PIN or biometrics.
+	RequireUserVerification bool
+}
+
+// Credential is a newly registered credential.
+type Credential struct {
+	// ID is the credential ID chosen by the authenticator.
+	ID []byte
+	// PublicKey is the COSE encoded public key of the credential.
+	PublicKey []byte
+	// SignCount is the signature counter reported by the authenticator.
+	SignCount uint32
+}
+
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+type authenticatorData struct {
+	rpIDHash  []byte
+	flags     byte
+	signCount uint32
+
+	// Only set if the attested credential data flag is set.
+	credentialID []byte
+	publicKey    []byte
+}
+
+// VerifyRegistration verifies the response of navigator.credentials.create()
+// to the challenge, and returns the new credential.
+func (rp RelyingParty) VerifyRegistration(challenge, clientDataJSON, attestationObject []byte) (*Credential, error) {
+	if err := rp.verifyClientData(clientDataJSON, "webauthn.create", challenge); err != nil {
+		return nil, err
+	}
+
+	obj, _, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := obj.(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.New("webauthn: attestation object is not a map")
+	}
+	rawAuthData, ok := m["authData"].([]byte)
+	if !ok {
+		return nil, errors.New("webauthn: attestation object has no authenticator data")
+	}
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if err := rp.verifyAuthenticatorData(authData); err != nil {
+		return nil, err
+	}
+	if authData.flags&flagAttested == 0 {
+		return nil, errors.New("webauthn: authenticator data has no attested credential")
+	}
+	if _, err := ParsePublicKey(authData.publicKey); err != nil {
+		return nil, err
+	}
+	return &Credential{
+		ID:        authData.credentialID,
+		PublicKey: authData.publicKey,
+		SignCount: authData.signCount,
+	}, nil
+}
+
+// VerifyAssertion verifies the response of navigator.credentials.get() to the
+// challenge against the COSE encoded public key of the credential, and
+// returns the signature counter reported by the authenticator.
+//
+// Callers should reject assertions whose counter doesn't exceed the stored
+// one, unless both are zero, since that indicates a cloned authenticator.
+func (rp RelyingParty) VerifyAssertion(challenge, publicKey, clientDataJSON, rawAuthData, signature []byte) (uint32, error) {
+	if err := rp.verifyClientData(clientDataJSON, "webauthn.get", challenge); err != nil {
+		return 0, err
+	}
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return 0, err
+	}
+	if err := rp.verifyAuthenticatorData(authData); err != nil {
+		return 0, err
+	}
+
+	key, err := ParsePublicKey(publicKey)
+	if err != nil {
+		return 0, err
+	}
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte{}, rawAuthData...), clientDataHash[:]...))
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		var sig struct {
+			R, S *big.Int
+		}
+		rest, err := asn1.Unmarshal(signature, &sig)
+		if err != nil || len(rest) > 0 {
+			return 0, errors.New("webauthn: malformed ECDSA signature")
+		}
+		if !ecdsa.Verify(key, digest[:], sig.R, sig.S) {
+			return 0, errors.New("webauthn: invalid signature")
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+			return 0, errors.New("webauthn: invalid signature")
+		}
+	}
+	return authData.signCount, nil
+}
+
+func (rp RelyingParty) verifyClientData(data []byte, typ string, challenge []byte) error {
+	var c clientData
+	if err := json.Unmarshal(data, &c); err != nil {
+		return fmt.Errorf("webauthn: malformed client data: %v", err)
+	}
+	if c.Type != typ {
+		return fmt.Errorf("webauthn: expected client data of type %q, got %q", typ, c.Type)
+	}
+	got, err := base64.RawURLEncoding.DecodeString(c.Challenge)
+	if err != nil || len(challenge) == 0 || subtle.ConstantTimeCompare(got, challenge) != 1 {
+		return errors.New("webauthn: challenge mismatch")
+	}
+	for _, origin := range rp.Origins {
+		if c.Origin == origin {
+			return nil
+		}
+	}
+	return fmt.Errorf("webauthn: unexpected origin %q", c.Origin)
+}
+
+func (rp RelyingParty) verifyAuthenticatorData(a *authenticatorData) error {
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(a.rpIDHash, rpIDHash[:]) {
+		return errors.New("webauthn: credential is scoped to another relying party")
+	}
+	if a.flags&flagUserPresent == 0 {
+		return errors.New("webauthn: user was not present")
+	}
+	if rp.RequireUserVerification && a.flags&flagUserVerified == 0 {
+		return errors.New("webauthn: user was not verified")
+	}
+	return nil
+}
+
+func parseAuthenticatorData(data []byte) (*authenticatorData, error) {
+	if len(data) < 37 {
+		return nil, errors.New("webauthn: authenticator data too short")
+	}
+	a := &authenticatorData{
+		rpIDHash:  data[:32],
+		flags:     data[32],
+		signCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	if a.flags&flagAttested == 0 {
+		return a, nil
+	}
+
+	// The attested credential data holds the authenticator's AAGUID, the
+	// length prefixed credential ID, and the CBOR encoded public key.
+	data = data[37:]
+	if len(data) < 18 {
+		return nil, errors.New("webauthn: attested credential data too short")
+	}
+	n := int(binary.BigEndian.Uint16(data[16:18]))
+	data = data[18:]
+	if len(data) < n || n == 0 {
+		return nil, errors.New("webauthn: malformed credential ID")
+	}
+	a.credentialID = data[:n]
+	data = data[n:]
+	_, rest, err := decodeCBOR(data)
+	if err != nil {
+		return nil, err
+	}
+	a.publicKey = data[:len(data)-len(rest)]
+	return a, nil
+}
+
+// ParsePublicKey parses a COSE encoded ES256 or RS256 public key.
+func ParsePublicKey(data []byte) (crypto.PublicKey, error) {
+	v, rest, err := decodeCBOR(data)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := v.(map[interface{}]interface{})
+	if !ok || len(rest) > 0 {
+		return nil, errors.New("webauthn: malformed COSE key")
+	}
+	kty, _ := m[int64(coseKeyType)].(int64)
+	alg, _ := m[int64(coseAlgorithm)].(int64)
+	switch {
+	case kty == coseKeyTypeEC2 && alg == AlgES256:
+		crv, _ := m[int64(coseCurve)].(int64)
+		x, _ := m[int64(coseX)].([]byte)
+		y, _ := m[int64(coseY)].([]byte)
+		if crv != coseCurveP256 || len(x) != 32 || len(y) != 32 {
+			return nil, errors.New("webauthn: malformed EC2 key")
+		}
+		key := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("webauthn: EC2 key is not on its curve")
+		}
+		return key, nil
+	case kty == coseKeyTypeRSA && alg == AlgRS256:
+		n, _ := m[int64(coseModulus)].([]byte)
+		e, _ := m[int64(coseExponent)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("webauthn: malformed RSA key")
+		}
+		var exp int
+		for _, b := range e {
+			exp = exp<<8 | int(b)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exp}, nil
+	}
+	return nil, fmt.Errorf("webauthn: unsupported COSE key type %d with algorithm %d", kty, alg)
+}
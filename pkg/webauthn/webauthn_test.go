@@ -0,0 +1,103 @@
+package webauthn_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dexidp/dex/pkg/webauthn"
+	"github.com/dexidp/dex/pkg/webauthn/webauthntest"
+)
+
+const origin = "https://dex.example.com"
+
+var rp = webauthn.RelyingParty{ID: "dex.example.com", Origins: []string{origin}}
+
+func TestRegistration(t *testing.T) {
+	a, err := webauthntest.New(rp.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	challenge := []byte("registration-challenge")
+	clientData, attestation := a.Register(challenge, origin)
+
+	cred, err := rp.VerifyRegistration(challenge, clientData, attestation)
+	if err != nil {
+		t.Fatalf("verify registration: %v", err)
+	}
+	if !bytes.Equal(cred.ID, a.ID) || !bytes.Equal(cred.PublicKey, a.PublicKey()) {
+		t.Errorf("unexpected credential %+v", cred)
+	}
+
+	if _, err := rp.VerifyRegistration([]byte("other"), clientData, attestation); err == nil {
+		t.Errorf("expected registration for another challenge to be rejected")
+	}
+	clientData, attestation = a.Register(challenge, "https://evil.example.com")
+	if _, err := rp.VerifyRegistration(challenge, clientData, attestation); err == nil {
+		t.Errorf("expected registration from another origin to be rejected")
+	}
+	other, err := webauthntest.New("evil.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientData, attestation = other.Register(challenge, origin)
+	if _, err := rp.VerifyRegistration(challenge, clientData, attestation); err == nil {
+		t.Errorf("expected credential for another relying party to be rejected")
+	}
+	if _, err := rp.VerifyRegistration(challenge, clientData, attestation[:len(attestation)-5]); err == nil {
+		t.Errorf("expected truncated attestation object to be rejected")
+	}
+}
+
+func TestAssertion(t *testing.T) {
+	a, err := webauthntest.New(rp.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	challenge := []byte("assertion-challenge")
+	clientData, authData, sig := a.Assert(challenge, origin)
+
+	signCount, err := rp.VerifyAssertion(challenge, a.PublicKey(), clientData, authData, sig)
+	if err != nil {
+		t.Fatalf("verify assertion: %v", err)
+	}
+	if signCount != 1 {
+		t.Errorf("expected sign count 1, got %d", signCount)
+	}
+
+	if _, err := rp.VerifyAssertion([]byte("other"), a.PublicKey(), clientData, authData, sig); err == nil {
+		t.Errorf("expected assertion for another challenge to be rejected")
+	}
+	other, err := webauthntest.New(rp.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rp.VerifyAssertion(challenge, other.PublicKey(), clientData, authData, sig); err == nil {
+		t.Errorf("expected assertion signed by another key to be rejected")
+	}
+	registration := webauthntest.ClientData("webauthn.create", challenge, origin)
+	if _, err := rp.VerifyAssertion(challenge, a.PublicKey(), registration, authData, a.Sign(authData, registration)); err == nil {
+		t.Errorf("expected client data of a registration to be rejected")
+	}
+
+	strict := rp
+	strict.RequireUserVerification = true
+	clientData, authData, sig = a.Assert(challenge, origin)
+	if _, err := strict.VerifyAssertion(challenge, a.PublicKey(), clientData, authData, sig); err == nil {
+		t.Errorf("expected assertion without user verification to be rejected")
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	invalid := [][]byte{
+		nil,
+		{0xa0},             // empty map
+		{0xa1, 0x01, 0x02}, // EC2 key without parameters
+		{0x9f},             // indefinite length array
+		{0x5b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, // huge byte string
+	}
+	for _, data := range invalid {
+		if _, err := webauthn.ParsePublicKey(data); err == nil {
+			t.Errorf("expected error parsing COSE key %x", data)
+		}
+	}
+}
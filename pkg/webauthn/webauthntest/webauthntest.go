@@ -0,0 +1,173 @@
+// Package webauthntest implements a software authenticator for testing
+// WebAuthn relying parties.
+package webauthntest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"math/big"
+	"sort"
+)
+
+// Authenticator is an ES256 authenticator holding a single credential.
+type Authenticator struct {
+	// RPID is the relying party ID credentials are scoped to.
+	RPID string
+	// ID is the credential ID.
+	ID []byte
+	// SignCount is the signature counter, incremented by each assertion.
+	SignCount uint32
+
+	key *ecdsa.PrivateKey
+}
+
+// New returns an authenticator with a new credential for the relying party.
+func New(rpID string) (*Authenticator, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	return &Authenticator{RPID: rpID, ID: id, key: key}, nil
+}
+
+// PublicKey returns the COSE encoding of the credential's public key.
+func (a *Authenticator) PublicKey() []byte {
+	return encode(map[int64]interface{}{
+		1:  int64(2),
+		3:  int64(-7),
+		-1: int64(1),
+		-2: pad(a.key.X),
+		-3: pad(a.key.Y),
+	})
+}
+
+// Register returns the client data and attestation object of a "none"
+// attestation of the credential.
+func (a *Authenticator) Register(challenge []byte, origin string) (clientDataJSON, attestationObject []byte) {
+	authData := a.authData(0x41)
+	authData = append(authData, make([]byte, 16)...)
+	authData = append(authData, byte(len(a.ID)>>8), byte(len(a.ID)))
+	authData = append(authData, a.ID...)
+	authData = append(authData, a.PublicKey()...)
+	attestationObject = encode(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": authData,
+	})
+	return ClientData("webauthn.create", challenge, origin), attestationObject
+}
+
+// Assert increments the signature counter and returns the client data,
+// authenticator data and signature of an assertion.
+func (a *Authenticator) Assert(challenge []byte, origin string) (clientDataJSON, authData, signature []byte) {
+	a.SignCount++
+	clientDataJSON = ClientData("webauthn.get", challenge, origin)
+	authData = a.authData(0x01)
+	signature = a.Sign(authData, clientDataJSON)
+	return clientDataJSON, authData, signature
+}
+
+// Sign signs the authenticator data and client data with the credential's key.
+func (a *Authenticator) Sign(authData, clientDataJSON []byte) []byte {
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	r, s, err := ecdsa.Sign(rand.Reader, a.key, digest[:])
+	if err != nil {
+		panic(err)
+	}
+	sig, err := asn1.Marshal(struct{ R, S *big.Int }{r, s})
+	if err != nil {
+		panic(err)
+	}
+	return sig
+}
+
+func (a *Authenticator) authData(flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.RPID))
+	data := append(rpIDHash[:], flags, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(data[33:], a.SignCount)
+	return data
+}
+
+// ClientData returns the client data a browser reports for a ceremony.
+func ClientData(typ string, challenge []byte, origin string) []byte {
+	data, err := json.Marshal(map[string]string{
+		"type":      typ,
+		"challenge": base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":    origin,
+	})
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func pad(n *big.Int) []byte {
+	b := n.Bytes()
+	return append(make([]byte, 32-len(b)), b...)
+}
+
+// encode encodes the CBOR types used by authenticators. Map keys are sorted
+// to keep the encoding deterministic.
+func encode(v interface{}) []byte {
+	switch v := v.(type) {
+	case int64:
+		if v < 0 {
+			return head(1, uint64(-1-v))
+		}
+		return head(0, uint64(v))
+	case []byte:
+		return append(head(2, uint64(len(v))), v...)
+	case string:
+		return append(head(3, uint64(len(v))), v...)
+	case map[int64]interface{}:
+		keys := make([]int64, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+		out := head(5, uint64(len(v)))
+		for _, k := range keys {
+			out = append(out, encode(k)...)
+			out = append(out, encode(v[k])...)
+		}
+		return out
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		out := head(5, uint64(len(v)))
+		for _, k := range keys {
+			out = append(out, encode(k)...)
+			out = append(out, encode(v[k])...)
+		}
+		return out
+	}
+	panic("webauthntest: unsupported CBOR type")
+}
+
+func head(major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n < 1<<8:
+		return []byte{major<<5 | 24, byte(n)}
+	case n < 1<<16:
+		return []byte{major<<5 | 25, byte(n >> 8), byte(n)}
+	}
+	b := []byte{major<<5 | 26, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(b[1:], uint32(n))
+	return b
+}
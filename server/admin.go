@@ -0,0 +1,46 @@
+package server
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	runtimepprof "runtime/pprof"
+)
+
+// AdminHandler returns a handler for administrators, exposing runtime
+// diagnostics (the pprof profiles, expvar variables and a dump of all
+// goroutines), Grafana dashboards built from dex's metrics, a live stream
+// of audit events and the users' sessions, which can be revoked. Profiles
+// reveal memory contents, so every request must carry the admin token and
+// the handler should only be served on an internal listener.
+func (s *Server) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/events", s.handleAuditStream)
+	mux.HandleFunc("/sessions", s.handleAdminSessions)
+	mux.HandleFunc("/sessions/", s.handleAdminSessions)
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/debug/goroutines", handleGoroutineDump)
+	mux.HandleFunc("/debug/dashboards", handleDashboards)
+	mux.HandleFunc("/debug/dashboards/", handleDashboards)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.isAdmin(r) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="dex"`)
+			http.Error(w, "Unauthorized.", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// handleGoroutineDump writes the stacks of all goroutines, in the format of
+// an unrecovered panic.
+func handleGoroutineDump(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	runtimepprof.Lookup("goroutine").WriteTo(w, 2)
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAdminHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.AdminToken = "s3cret"
+	})
+	defer httpServer.Close()
+	h := s.AdminHandler()
+
+	tests := []struct {
+		path     string
+		token    string
+		wantCode int
+		wantBody string
+	}{
+		{"/debug/goroutines", "", http.StatusUnauthorized, ""},
+		{"/debug/goroutines", "wrong", http.StatusUnauthorized, ""},
+		{"/debug/goroutines", "s3cret", http.StatusOK, "TestAdminHandler"},
+		{"/debug/vars", "s3cret", http.StatusOK, `"memstats"`},
+		{"/debug/pprof/", "s3cret", http.StatusOK, "goroutine"},
+		{"/debug/pprof/heap?debug=1", "s3cret", http.StatusOK, "heap profile"},
+		{"/debug/dashboards", "s3cret", http.StatusOK, `"token.json"`},
+		{"/debug/dashboards/token.json", "s3cret", http.StatusOK, "http_request_duration_seconds_bucket"},
+		{"/debug/dashboards/unknown.json", "s3cret", http.StatusNotFound, ""},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		if tc.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != tc.wantCode {
+			t.Errorf("%s: expected status %d, got %d", tc.path, tc.wantCode, rr.Code)
+			continue
+		}
+		if !strings.Contains(rr.Body.String(), tc.wantBody) {
+			t.Errorf("%s: expected body to contain %q", tc.path, tc.wantBody)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/dexidp/dex/pkg/alert"
+)
+
+// Alerts configures paging operators about repeated internal failures.
+type Alerts struct {
+	// Receives alerts. Alerting is disabled if nil.
+	Notifier alert.Notifier
+
+	// Number of failures within Window that trigger an alert. Defaults to 5.
+	Threshold int
+
+	// Defaults to 5 minutes. At most one alert is sent per component and
+	// window.
+	Window time.Duration
+}
+
+type failureKey struct {
+	kind    alert.Kind
+	subject string
+}
+
+type failureHistory struct {
+	times   []time.Time
+	alerted time.Time
+}
+
+// failureTracker counts failures per component and notifies once a
+// component crosses the threshold.
+type failureTracker struct {
+	notifier  alert.Notifier
+	threshold int
+	window    time.Duration
+
+	mu       sync.Mutex
+	failures map[failureKey]*failureHistory
+}
+
+func newFailureTracker(c Alerts) *failureTracker {
+	if c.Notifier == nil {
+		return nil
+	}
+	threshold := c.Threshold
+	if threshold <= 0 {
+		threshold = 5
+	}
+	return &failureTracker{
+		notifier:  c.Notifier,
+		threshold: threshold,
+		window:    value(c.Window, 5*time.Minute),
+		failures:  make(map[failureKey]*failureHistory),
+	}
+}
+
+// record adds a failure and returns the alert to send, if any.
+func (t *failureTracker) record(now time.Time, kind alert.Kind, subject string, err error) (alert.Alert, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := failureKey{kind, subject}
+	h, ok := t.failures[key]
+	if !ok {
+		h = new(failureHistory)
+		t.failures[key] = h
+	}
+
+	cutoff := now.Add(-t.window)
+	i := 0
+	for _, ts := range h.times {
+		if ts.After(cutoff) {
+			h.times[i] = ts
+			i++
+		}
+	}
+	h.times = append(h.times[:i], now)
+
+	if len(h.times) < t.threshold || h.alerted.After(cutoff) {
+		return alert.Alert{}, false
+	}
+	h.alerted = now
+	return alert.Alert{
+		Kind:      kind,
+		Subject:   subject,
+		Time:      now,
+		Failures:  len(h.times),
+		Window:    t.window,
+		LastError: err.Error(),
+	}, true
+}
+
+// reportFailure records a failure of an internal component. Once the
+// component fails often enough, operators are notified in the background so
+// the request isn't held up by the notifier.
+func (s *Server) reportFailure(kind alert.Kind, subject string, err error) {
+	if s.alerts == nil {
+		return
+	}
+	a, ok := s.alerts.record(s.now(), kind, subject, err)
+	if !ok {
+		return
+	}
+	s.logger.Errorf("alerting operators: %s", a.Summary())
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := s.alerts.notifier.Notify(ctx, a); err != nil {
+			s.logger.Errorf("failed to send alert: %v", err)
+		}
+	}()
+}
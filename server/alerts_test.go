@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dexidp/dex/pkg/alert"
+)
+
+type recordingNotifier struct {
+	mu     sync.Mutex
+	alerts []alert.Alert
+}
+
+func (r *recordingNotifier) Notify(ctx context.Context, a alert.Alert) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.alerts = append(r.alerts, a)
+	return nil
+}
+
+func TestFailureTracker(t *testing.T) {
+	tracker := newFailureTracker(Alerts{
+		Notifier:  new(recordingNotifier),
+		Threshold: 3,
+		Window:    time.Minute,
+	})
+	now := time.Now()
+	errFail := errors.New("connection refused")
+
+	record := func(offset time.Duration, subject string) bool {
+		_, ok := tracker.record(now.Add(offset), alert.KindConnector, subject, errFail)
+		return ok
+	}
+
+	if record(0, "github") || record(time.Second, "github") {
+		t.Fatal("alerted below the threshold")
+	}
+	if record(2*time.Second, "ldap") {
+		t.Fatal("failures of other connectors counted towards the threshold")
+	}
+	if !record(3*time.Second, "github") {
+		t.Fatal("expected an alert at the threshold")
+	}
+	if record(4*time.Second, "github") {
+		t.Fatal("alerted twice within the window")
+	}
+	// The earlier failures fall out of the window.
+	if record(2*time.Minute, "github") {
+		t.Fatal("alerted for failures outside of the window")
+	}
+	if record(2*time.Minute+time.Second, "github") || !record(2*time.Minute+2*time.Second, "github") {
+		t.Fatal("expected a new alert once the threshold is crossed again")
+	}
+}
+
+func TestReportFailure(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	notifier := new(recordingNotifier)
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.Alerts = Alerts{Notifier: notifier, Threshold: 2}
+	})
+	defer httpServer.Close()
+
+	s.reportFailure(alert.KindStorage, "", errors.New("timeout"))
+	s.reportFailure(alert.KindStorage, "", errors.New("timeout"))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		notifier.mu.Lock()
+		n := len(notifier.alerts)
+		notifier.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	notifier.mu.Lock()
+	defer notifier.mu.Unlock()
+	if len(notifier.alerts) != 1 {
+		t.Fatalf("expected one alert, got %d", len(notifier.alerts))
+	}
+	if a := notifier.alerts[0]; a.Kind != alert.KindStorage || a.Failures != 2 || a.LastError != "timeout" {
+		t.Errorf("unexpected alert %+v", a)
+	}
+}
@@ -4,11 +4,17 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 
 	"github.com/dexidp/dex/api/v2"
+	"github.com/dexidp/dex/pkg/audit"
 	"github.com/dexidp/dex/pkg/log"
+	"github.com/dexidp/dex/pkg/secret"
 	"github.com/dexidp/dex/server/internal"
 	"github.com/dexidp/dex/storage"
 	"github.com/dexidp/dex/version"
@@ -16,7 +22,7 @@ import (
 
 // apiVersion increases every time a new call is added to the API. Clients should use this info
 // to determine if the server supports specific features.
-const apiVersion = 2
+const apiVersion = 8
 
 const (
 	// recCost is the recommended bcrypt cost, which balances hash strength and
@@ -27,19 +33,73 @@ const (
 	// high enough to ensure secure encryption, low enough to not put unnecessary
 	// load on a dex server.
 	upBoundCost = 16
+
+	// minArgon2idMemory and maxArgon2idMemory bound the memory, in KiB, of
+	// argon2id password hashes. The lower bound is the minimum recommended by
+	// OWASP, the upper bound keeps logins from exhausting the server's memory.
+	minArgon2idMemory = 19 * 1024
+	maxArgon2idMemory = 1024 * 1024
+
+	// maxArgon2idIterations is the upper bound on passes of argon2id hashes.
+	maxArgon2idIterations = 16
+
+	// defaultAuditEventsLimit and maxAuditEventsLimit bound the number of
+	// events returned by a single ListAuditEvents call.
+	defaultAuditEventsLimit = 100
+	maxAuditEventsLimit     = 1000
 )
 
-// NewAPI returns a server which implements the gRPC API interface.
-func NewAPI(s storage.Storage, logger log.Logger) api.DexServer {
+// NewAPI returns a server which implements the gRPC API interface. features
+// are the experimental features enabled in the server's config. If hasher is
+// not nil, client secrets are stored hashed. Erasures of user data are
+// reported to sink, which defaults to the logger. Revoked refresh tokens are
+// reported as revoked by introspection for revokedTokensValidFor, which
+// defaults to 24 hours.
+func NewAPI(s storage.Storage, logger log.Logger, features []Feature, hasher secret.Hasher, sink audit.Sink, revokedTokensValidFor time.Duration) api.DexServer {
+	if sink == nil {
+		sink = audit.NewLoggerSink(logger)
+	}
 	return dexAPI{
-		s:      s,
-		logger: logger,
+		s:                     s,
+		logger:                logger,
+		features:              features,
+		hasher:                hasher,
+		audit:                 sink,
+		revokedTokensValidFor: value(revokedTokensValidFor, 24*time.Hour),
 	}
 }
 
 type dexAPI struct {
-	s      storage.Storage
-	logger log.Logger
+	s        storage.Storage
+	logger   log.Logger
+	features []Feature
+	hasher   secret.Hasher
+	audit    audit.Sink
+
+	revokedTokensValidFor time.Duration
+}
+
+// storedClientSecret returns the value stored for a client secret supplied
+// either in plaintext or as a hash.
+func (d dexAPI) storedClientSecret(plaintext, hash string) (string, error) {
+	if hash != "" {
+		if plaintext != "" {
+			return "", errors.New("only one of secret and secret_hash may be set")
+		}
+		if !secret.IsHash(hash) {
+			return "", errors.New("secret_hash must be a bcrypt or argon2id hash")
+		}
+		if strings.HasPrefix(hash, "$argon2id$") {
+			if err := checkArgon2idParams(hash); err != nil {
+				return "", err
+			}
+		}
+		return hash, nil
+	}
+	if d.hasher == nil || plaintext == "" {
+		return plaintext, nil
+	}
+	return d.hasher.Hash([]byte(plaintext))
 }
 
 func (d dexAPI) CreateClient(ctx context.Context, req *api.CreateClientReq) (*api.CreateClientResp, error) {
@@ -50,25 +110,44 @@ func (d dexAPI) CreateClient(ctx context.Context, req *api.CreateClientReq) (*ap
 	if req.Client.Id == "" {
 		req.Client.Id = storage.NewID()
 	}
-	if req.Client.Secret == "" {
+	if req.Client.Secret == "" && req.Client.SecretHash == "" {
 		req.Client.Secret = storage.NewID() + storage.NewID()
 	}
+	if err := validateCIDRs(req.Client.AllowedCidrs); err != nil {
+		return nil, fmt.Errorf("create client: %w", err)
+	}
+	clientSecret, err := d.storedClientSecret(req.Client.Secret, req.Client.SecretHash)
+	if err != nil {
+		return nil, fmt.Errorf("create client: %w", err)
+	}
 
 	c := storage.Client{
-		ID:           req.Client.Id,
-		Secret:       req.Client.Secret,
-		RedirectURIs: req.Client.RedirectUris,
-		TrustedPeers: req.Client.TrustedPeers,
-		Public:       req.Client.Public,
-		Name:         req.Client.Name,
-		LogoURL:      req.Client.LogoUrl,
-	}
-	if err := d.s.CreateClient(c); err != nil {
-		if err == storage.ErrAlreadyExists {
+		ID:                   req.Client.Id,
+		Secret:               clientSecret,
+		RedirectURIs:         req.Client.RedirectUris,
+		TrustedPeers:         req.Client.TrustedPeers,
+		Public:               req.Client.Public,
+		Name:                 req.Client.Name,
+		LogoURL:              req.Client.LogoUrl,
+		AllowedCIDRs:         req.Client.AllowedCidrs,
+		TOSURI:               req.Client.TosUri,
+		PolicyURI:            req.Client.PolicyUri,
+		Contacts:             req.Client.Contacts,
+		ApplicationType:      req.Client.ApplicationType,
+		BackchannelLogoutURI: req.Client.BackchannelLogoutUri,
+	}
+	if req.Client.Expiry != 0 {
+		c.Expiry = time.Unix(req.Client.Expiry, 0).UTC()
+	}
+	if err := ValidateClientMetadata(c); err != nil {
+		return nil, fmt.Errorf("create client: %w", err)
+	}
+	if err := d.s.CreateClient(ctx, c); err != nil {
+		if errors.Is(err, storage.ErrAlreadyExists) {
 			return &api.CreateClientResp{AlreadyExists: true}, nil
 		}
 		d.logger.Errorf("api: failed to create client: %v", err)
-		return nil, fmt.Errorf("create client: %v", err)
+		return nil, fmt.Errorf("create client: %w", err)
 	}
 
 	return &api.CreateClientResp{
@@ -80,8 +159,28 @@ func (d dexAPI) UpdateClient(ctx context.Context, req *api.UpdateClientReq) (*ap
 	if req.Id == "" {
 		return nil, errors.New("update client: no client ID supplied")
 	}
+	if err := validateCIDRs(req.AllowedCidrs); err != nil {
+		return nil, fmt.Errorf("update client: %w", err)
+	}
+	metadata := storage.Client{
+		TOSURI:               req.TosUri,
+		PolicyURI:            req.PolicyUri,
+		Contacts:             req.Contacts,
+		ApplicationType:      req.ApplicationType,
+		BackchannelLogoutURI: req.BackchannelLogoutUri,
+	}
+	if err := ValidateClientMetadata(metadata); err != nil {
+		return nil, fmt.Errorf("update client: %w", err)
+	}
+	var clientSecret string
+	if req.SecretHash != "" {
+		var err error
+		if clientSecret, err = d.storedClientSecret("", req.SecretHash); err != nil {
+			return nil, fmt.Errorf("update client: %w", err)
+		}
+	}
 
-	err := d.s.UpdateClient(req.Id, func(old storage.Client) (storage.Client, error) {
+	err := d.s.UpdateClient(ctx, req.Id, func(old storage.Client) (storage.Client, error) {
 		if req.RedirectUris != nil {
 			old.RedirectURIs = req.RedirectUris
 		}
@@ -94,37 +193,74 @@ func (d dexAPI) UpdateClient(ctx context.Context, req *api.UpdateClientReq) (*ap
 		if req.LogoUrl != "" {
 			old.LogoURL = req.LogoUrl
 		}
+		if req.AllowedCidrs != nil {
+			old.AllowedCIDRs = req.AllowedCidrs
+		}
+		if clientSecret != "" {
+			old.Secret = clientSecret
+		}
+		if req.TosUri != "" {
+			old.TOSURI = req.TosUri
+		}
+		if req.PolicyUri != "" {
+			old.PolicyURI = req.PolicyUri
+		}
+		if req.Contacts != nil {
+			old.Contacts = req.Contacts
+		}
+		if req.ApplicationType != "" {
+			old.ApplicationType = req.ApplicationType
+		}
+		if req.Expiry != 0 {
+			old.Expiry = time.Unix(req.Expiry, 0).UTC()
+		}
+		if req.BackchannelLogoutUri != "" {
+			old.BackchannelLogoutURI = req.BackchannelLogoutUri
+		}
 		return old, nil
 	})
 
 	if err != nil {
-		if err == storage.ErrNotFound {
+		if errors.Is(err, storage.ErrNotFound) {
 			return &api.UpdateClientResp{NotFound: true}, nil
 		}
 		d.logger.Errorf("api: failed to update the client: %v", err)
-		return nil, fmt.Errorf("update client: %v", err)
+		return nil, fmt.Errorf("update client: %w", err)
 	}
 	return &api.UpdateClientResp{}, nil
 }
 
 func (d dexAPI) DeleteClient(ctx context.Context, req *api.DeleteClientReq) (*api.DeleteClientResp, error) {
-	err := d.s.DeleteClient(req.Id)
+	err := d.s.DeleteClient(ctx, req.Id)
 	if err != nil {
-		if err == storage.ErrNotFound {
+		if errors.Is(err, storage.ErrNotFound) {
 			return &api.DeleteClientResp{NotFound: true}, nil
 		}
 		d.logger.Errorf("api: failed to delete client: %v", err)
-		return nil, fmt.Errorf("delete client: %v", err)
+		return nil, fmt.Errorf("delete client: %w", err)
 	}
 	return &api.DeleteClientResp{}, nil
 }
 
+// validateCIDRs returns an error if any of the given networks can't be parsed.
+func validateCIDRs(cidrs []string) error {
+	for _, cidr := range cidrs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("invalid allowed CIDR %q: %w", cidr, err)
+		}
+	}
+	return nil
+}
+
 // checkCost returns an error if the hash provided does not meet lower or upper
 // bound cost requirements.
 func checkCost(hash []byte) error {
+	if strings.HasPrefix(string(hash), "$argon2id$") {
+		return checkArgon2idParams(string(hash))
+	}
 	actual, err := bcrypt.Cost(hash)
 	if err != nil {
-		return fmt.Errorf("parsing bcrypt hash: %v", err)
+		return fmt.Errorf("parsing bcrypt hash: %w", err)
 	}
 	if actual < bcrypt.DefaultCost {
 		return fmt.Errorf("given hash cost = %d does not meet minimum cost requirement = %d", actual, bcrypt.DefaultCost)
@@ -135,6 +271,22 @@ func checkCost(hash []byte) error {
 	return nil
 }
 
+// checkArgon2idParams returns an error if the parameters of the argon2id hash
+// are out of bounds.
+func checkArgon2idParams(hash string) error {
+	p, err := secret.ParseArgon2id(hash)
+	if err != nil {
+		return fmt.Errorf("parsing argon2id hash: %w", err)
+	}
+	if p.Memory < minArgon2idMemory || p.Memory > maxArgon2idMemory {
+		return fmt.Errorf("given hash memory = %d KiB is not between %d and %d KiB", p.Memory, minArgon2idMemory, maxArgon2idMemory)
+	}
+	if p.Iterations > maxArgon2idIterations {
+		return fmt.Errorf("given hash iterations = %d is above upper bound = %d", p.Iterations, maxArgon2idIterations)
+	}
+	return nil
+}
+
 func (d dexAPI) CreatePassword(ctx context.Context, req *api.CreatePasswordReq) (*api.CreatePasswordResp, error) {
 	if req.Password == nil {
 		return nil, errors.New("no password supplied")
@@ -156,12 +308,12 @@ func (d dexAPI) CreatePassword(ctx context.Context, req *api.CreatePasswordReq)
 		Username: req.Password.Username,
 		UserID:   req.Password.UserId,
 	}
-	if err := d.s.CreatePassword(p); err != nil {
-		if err == storage.ErrAlreadyExists {
+	if err := d.s.CreatePassword(ctx, p); err != nil {
+		if errors.Is(err, storage.ErrAlreadyExists) {
 			return &api.CreatePasswordResp{AlreadyExists: true}, nil
 		}
 		d.logger.Errorf("api: failed to create password: %v", err)
-		return nil, fmt.Errorf("create password: %v", err)
+		return nil, fmt.Errorf("create password: %w", err)
 	}
 
 	return &api.CreatePasswordResp{}, nil
@@ -193,12 +345,12 @@ func (d dexAPI) UpdatePassword(ctx context.Context, req *api.UpdatePasswordReq)
 		return old, nil
 	}
 
-	if err := d.s.UpdatePassword(req.Email, updater); err != nil {
-		if err == storage.ErrNotFound {
+	if err := d.s.UpdatePassword(ctx, req.Email, updater); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
 			return &api.UpdatePasswordResp{NotFound: true}, nil
 		}
 		d.logger.Errorf("api: failed to update password: %v", err)
-		return nil, fmt.Errorf("update password: %v", err)
+		return nil, fmt.Errorf("update password: %w", err)
 	}
 
 	return &api.UpdatePasswordResp{}, nil
@@ -209,13 +361,13 @@ func (d dexAPI) DeletePassword(ctx context.Context, req *api.DeletePasswordReq)
 		return nil, errors.New("no email supplied")
 	}
 
-	err := d.s.DeletePassword(req.Email)
+	err := d.s.DeletePassword(ctx, req.Email)
 	if err != nil {
-		if err == storage.ErrNotFound {
+		if errors.Is(err, storage.ErrNotFound) {
 			return &api.DeletePasswordResp{NotFound: true}, nil
 		}
 		d.logger.Errorf("api: failed to delete password: %v", err)
-		return nil, fmt.Errorf("delete password: %v", err)
+		return nil, fmt.Errorf("delete password: %w", err)
 	}
 	return &api.DeletePasswordResp{}, nil
 }
@@ -228,10 +380,10 @@ func (d dexAPI) GetVersion(ctx context.Context, req *api.VersionReq) (*api.Versi
 }
 
 func (d dexAPI) ListPasswords(ctx context.Context, req *api.ListPasswordReq) (*api.ListPasswordResp, error) {
-	passwordList, err := d.s.ListPasswords()
+	passwordList, err := d.s.ListPasswords(ctx)
 	if err != nil {
 		d.logger.Errorf("api: failed to list passwords: %v", err)
-		return nil, fmt.Errorf("list passwords: %v", err)
+		return nil, fmt.Errorf("list passwords: %w", err)
 	}
 
 	var passwords []*api.Password
@@ -258,18 +410,18 @@ func (d dexAPI) VerifyPassword(ctx context.Context, req *api.VerifyPasswordReq)
 		return nil, errors.New("no password to verify supplied")
 	}
 
-	password, err := d.s.GetPassword(req.Email)
+	password, err := d.s.GetPassword(ctx, req.Email)
 	if err != nil {
-		if err == storage.ErrNotFound {
+		if errors.Is(err, storage.ErrNotFound) {
 			return &api.VerifyPasswordResp{
 				NotFound: true,
 			}, nil
 		}
 		d.logger.Errorf("api: there was an error retrieving the password: %v", err)
-		return nil, fmt.Errorf("verify password: %v", err)
+		return nil, fmt.Errorf("verify password: %w", err)
 	}
 
-	if err := bcrypt.CompareHashAndPassword(password.Hash, []byte(req.Password)); err != nil {
+	if ok, err := secret.Verify(string(password.Hash), []byte(req.Password)); !ok {
 		d.logger.Infof("api: password check failed: %v", err)
 		return &api.VerifyPasswordResp{
 			Verified: false,
@@ -288,16 +440,16 @@ func (d dexAPI) ListRefresh(ctx context.Context, req *api.ListRefreshReq) (*api.
 	}
 
 	var refreshTokenRefs []*api.RefreshTokenRef
-	offlineSessions, err := d.s.GetOfflineSessions(id.UserId, id.ConnId)
+	offlineSessions, err := d.s.GetOfflineSessions(ctx, id.UserId, id.ConnId)
 	if err != nil {
-		if err == storage.ErrNotFound {
+		if errors.Is(err, storage.ErrNotFound) {
 			// This means that this user-client pair does not have a refresh token yet.
 			// An empty list should be returned instead of an error.
 			return &api.ListRefreshResp{
 				RefreshTokens: refreshTokenRefs,
 			}, nil
 		}
-		d.logger.Errorf("api: failed to list refresh tokens %t here : %v", err == storage.ErrNotFound, err)
+		d.logger.Errorf("api: failed to list refresh tokens %t here : %v", errors.Is(err, storage.ErrNotFound), err)
 		return nil, err
 	}
 
@@ -330,7 +482,7 @@ func (d dexAPI) RevokeRefresh(ctx context.Context, req *api.RevokeRefreshReq) (*
 	updater := func(old storage.OfflineSessions) (storage.OfflineSessions, error) {
 		refreshRef := old.Refresh[req.ClientId]
 		if refreshRef == nil || refreshRef.ID == "" {
-			d.logger.Errorf("api: refresh token issued to client %q for user %q not found for deletion", req.ClientId, id.UserId)
+			d.logger.Errorf("api: refresh token issued to client %q for user %q not found for deletion", req.ClientId, log.Subject(id.UserId))
 			notFound = true
 			return old, storage.ErrNotFound
 		}
@@ -343,8 +495,8 @@ func (d dexAPI) RevokeRefresh(ctx context.Context, req *api.RevokeRefreshReq) (*
 		return old, nil
 	}
 
-	if err := d.s.UpdateOfflineSessions(id.UserId, id.ConnId, updater); err != nil {
-		if err == storage.ErrNotFound {
+	if err := d.s.UpdateOfflineSessions(ctx, id.UserId, id.ConnId, updater); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
 			return &api.RevokeRefreshResp{NotFound: true}, nil
 		}
 		d.logger.Errorf("api: failed to update offline session object: %v", err)
@@ -359,10 +511,423 @@ func (d dexAPI) RevokeRefresh(ctx context.Context, req *api.RevokeRefreshReq) (*
 	//
 	// TODO(ericchiang): we don't have any good recourse if this call fails.
 	// Consider garbage collection of refresh tokens with no associated ref.
-	if err := d.s.DeleteRefresh(refreshID); err != nil {
+	if err := d.s.DeleteRefresh(ctx, refreshID); err != nil {
 		d.logger.Errorf("failed to delete refresh token: %v", err)
 		return nil, err
 	}
+	if err := tombstoneRefresh(ctx, d.s, refreshID, req.ClientId, revocationRevoked, time.Now(), d.revokedTokensValidFor); err != nil {
+		d.logger.Errorf("api: failed to record revocation of refresh token: %v", err)
+	}
 
 	return &api.RevokeRefreshResp{}, nil
 }
+
+func (d dexAPI) ListFeatures(ctx context.Context, req *api.ListFeaturesReq) (*api.ListFeaturesResp, error) {
+	enabled := make(map[Feature]bool, len(d.features))
+	for _, f := range d.features {
+		enabled[f] = true
+	}
+
+	var features []*api.Feature
+	for f, implemented := range knownFeatures {
+		features = append(features, &api.Feature{
+			Name:      string(f),
+			Supported: implemented,
+			Enabled:   enabled[f],
+		})
+	}
+	sort.Slice(features, func(i, j int) bool { return features[i].Name < features[j].Name })
+	return &api.ListFeaturesResp{Features: features}, nil
+}
+
+func (d dexAPI) ListAuditEvents(ctx context.Context, req *api.ListAuditEventsReq) (*api.ListAuditEventsResp, error) {
+	if req.Limit < 0 {
+		return nil, errors.New("limit must not be negative")
+	}
+	filter := storage.AuditEventFilter{
+		Subject:  req.Subject,
+		ClientID: req.ClientId,
+		Type:     req.Type,
+		Limit:    int(req.Limit),
+	}
+	if req.Since != 0 {
+		filter.Since = time.Unix(req.Since, 0)
+	}
+	if req.Until != 0 {
+		filter.Until = time.Unix(req.Until, 0)
+	}
+	switch {
+	case filter.Limit == 0:
+		filter.Limit = defaultAuditEventsLimit
+	case filter.Limit > maxAuditEventsLimit:
+		filter.Limit = maxAuditEventsLimit
+	}
+
+	events, err := d.s.ListAuditEvents(ctx, filter)
+	if err != nil {
+		d.logger.Errorf("api: failed to list audit events: %v", err)
+		return nil, fmt.Errorf("list audit events: %w", err)
+	}
+
+	resp := &api.ListAuditEventsResp{}
+	for _, e := range events {
+		resp.Events = append(resp.Events, &api.AuditEvent{
+			Id:          e.ID,
+			Type:        e.Type,
+			Severity:    e.Severity,
+			Time:        e.Time.Unix(),
+			ClientId:    e.ClientID,
+			Subject:     e.Subject,
+			ConnectorId: e.ConnectorID,
+			SourceIps:   e.SourceIPs,
+			Message:     e.Message,
+			Revoked:     e.Revoked,
+		})
+	}
+	return resp, nil
+}
+
+func (d dexAPI) CreateAPIKey(ctx context.Context, req *api.CreateAPIKeyReq) (*api.CreateAPIKeyResp, error) {
+	if req.ClientId == "" {
+		return nil, errors.New("no client supplied")
+	}
+	if req.UserId == "" {
+		return nil, errors.New("no user ID supplied")
+	}
+	if len(req.Scopes) == 0 {
+		return nil, errors.New("no scopes supplied")
+	}
+	if _, err := d.s.GetClient(ctx, req.ClientId); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return &api.CreateAPIKeyResp{ClientNotFound: true}, nil
+		}
+		d.logger.Errorf("api: failed to get client: %v", err)
+		return nil, fmt.Errorf("create api key: %w", err)
+	}
+
+	k := storage.APIKey{
+		ClientID: req.ClientId,
+		Name:     req.Name,
+		Scopes:   req.Scopes,
+		Claims: storage.Claims{
+			UserID:   req.UserId,
+			Username: req.Username,
+			Email:    req.Email,
+			Groups:   req.Groups,
+		},
+		ConnectorID: req.ConnectorId,
+		CreatedAt:   time.Now().UTC().Round(time.Second),
+	}
+	if k.ConnectorID == "" {
+		k.ConnectorID = apiKeyConnectorID
+	}
+	if req.Expiry != 0 {
+		k.Expiry = time.Unix(req.Expiry, 0).UTC()
+	}
+	key := newAPIKey(&k)
+	if err := d.s.CreateAPIKey(ctx, k); err != nil {
+		d.logger.Errorf("api: failed to create api key: %v", err)
+		return nil, fmt.Errorf("create api key: %w", err)
+	}
+	return &api.CreateAPIKeyResp{
+		ApiKey: toAPIKey(k),
+		Key:    key,
+	}, nil
+}
+
+func (d dexAPI) ListAPIKeys(ctx context.Context, req *api.ListAPIKeysReq) (*api.ListAPIKeysResp, error) {
+	keys, err := d.s.ListAPIKeys(ctx)
+	if err != nil {
+		d.logger.Errorf("api: failed to list api keys: %v", err)
+		return nil, fmt.Errorf("list api keys: %w", err)
+	}
+
+	resp := &api.ListAPIKeysResp{}
+	for _, k := range keys {
+		if req.ClientId != "" && k.ClientID != req.ClientId {
+			continue
+		}
+		resp.ApiKeys = append(resp.ApiKeys, toAPIKey(k))
+	}
+	return resp, nil
+}
+
+func (d dexAPI) RevokeAPIKey(ctx context.Context, req *api.RevokeAPIKeyReq) (*api.RevokeAPIKeyResp, error) {
+	if req.Id == "" {
+		return nil, errors.New("no id supplied")
+	}
+	if err := d.s.DeleteAPIKey(ctx, req.Id); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return &api.RevokeAPIKeyResp{NotFound: true}, nil
+		}
+		d.logger.Errorf("api: failed to revoke api key: %v", err)
+		return nil, fmt.Errorf("revoke api key: %w", err)
+	}
+	return &api.RevokeAPIKeyResp{}, nil
+}
+
+func toAPIKey(k storage.APIKey) *api.APIKey {
+	key := &api.APIKey{
+		Id:          k.ID,
+		ClientId:    k.ClientID,
+		Name:        k.Name,
+		Scopes:      k.Scopes,
+		UserId:      k.Claims.UserID,
+		Username:    k.Claims.Username,
+		Email:       k.Claims.Email,
+		Groups:      k.Claims.Groups,
+		ConnectorId: k.ConnectorID,
+		CreatedAt:   k.CreatedAt.Unix(),
+	}
+	if !k.Expiry.IsZero() {
+		key.Expiry = k.Expiry.Unix()
+	}
+	return key
+}
+
+func (d dexAPI) CreateServiceAccount(ctx context.Context, req *api.CreateServiceAccountReq) (*api.CreateServiceAccountResp, error) {
+	if req.ServiceAccount == nil {
+		return nil, errors.New("no service account supplied")
+	}
+	if req.ServiceAccount.Id == "" {
+		return nil, errors.New("no service account ID supplied")
+	}
+	keys, err := parseServiceAccountKeys(req.ServiceAccount.PublicKeys)
+	if err != nil {
+		return nil, fmt.Errorf("create service account: %w", err)
+	}
+
+	a := storage.ServiceAccount{
+		ID:         req.ServiceAccount.Id,
+		Name:       req.ServiceAccount.Name,
+		Groups:     req.ServiceAccount.Groups,
+		Clients:    req.ServiceAccount.Clients,
+		PublicKeys: keys,
+		CreatedAt:  time.Now().UTC().Round(time.Second),
+	}
+	if err := d.s.CreateServiceAccount(ctx, a); err != nil {
+		if errors.Is(err, storage.ErrAlreadyExists) {
+			return &api.CreateServiceAccountResp{AlreadyExists: true}, nil
+		}
+		d.logger.Errorf("api: failed to create service account: %v", err)
+		return nil, fmt.Errorf("create service account: %w", err)
+	}
+	return &api.CreateServiceAccountResp{}, nil
+}
+
+func (d dexAPI) UpdateServiceAccount(ctx context.Context, req *api.UpdateServiceAccountReq) (*api.UpdateServiceAccountResp, error) {
+	if req.Id == "" {
+		return nil, errors.New("update service account: no service account ID supplied")
+	}
+	keys, err := parseServiceAccountKeys(req.PublicKeys)
+	if err != nil {
+		return nil, fmt.Errorf("update service account: %w", err)
+	}
+
+	err = d.s.UpdateServiceAccount(ctx, req.Id, func(old storage.ServiceAccount) (storage.ServiceAccount, error) {
+		if req.Name != "" {
+			old.Name = req.Name
+		}
+		if req.Groups != nil {
+			old.Groups = req.Groups
+		}
+		if req.Clients != nil {
+			old.Clients = req.Clients
+		}
+		if keys != nil {
+			old.PublicKeys = keys
+		}
+		return old, nil
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return &api.UpdateServiceAccountResp{NotFound: true}, nil
+		}
+		d.logger.Errorf("api: failed to update service account: %v", err)
+		return nil, fmt.Errorf("update service account: %w", err)
+	}
+	return &api.UpdateServiceAccountResp{}, nil
+}
+
+func (d dexAPI) ListServiceAccounts(ctx context.Context, req *api.ListServiceAccountsReq) (*api.ListServiceAccountsResp, error) {
+	accounts, err := d.s.ListServiceAccounts(ctx)
+	if err != nil {
+		d.logger.Errorf("api: failed to list service accounts: %v", err)
+		return nil, fmt.Errorf("list service accounts: %w", err)
+	}
+
+	resp := &api.ListServiceAccountsResp{}
+	for _, a := range accounts {
+		account := &api.ServiceAccount{
+			Id:        a.ID,
+			Name:      a.Name,
+			Groups:    a.Groups,
+			Clients:   a.Clients,
+			CreatedAt: a.CreatedAt.Unix(),
+		}
+		for _, key := range a.PublicKeys {
+			data, err := key.MarshalJSON()
+			if err != nil {
+				return nil, fmt.Errorf("list service accounts: %w", err)
+			}
+			account.PublicKeys = append(account.PublicKeys, string(data))
+		}
+		resp.ServiceAccounts = append(resp.ServiceAccounts, account)
+	}
+	return resp, nil
+}
+
+func (d dexAPI) DeleteServiceAccount(ctx context.Context, req *api.DeleteServiceAccountReq) (*api.DeleteServiceAccountResp, error) {
+	if req.Id == "" {
+		return nil, errors.New("no service account ID supplied")
+	}
+	if err := d.s.DeleteServiceAccount(ctx, req.Id); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return &api.DeleteServiceAccountResp{NotFound: true}, nil
+		}
+		d.logger.Errorf("api: failed to delete service account: %v", err)
+		return nil, fmt.Errorf("delete service account: %w", err)
+	}
+	return &api.DeleteServiceAccountResp{}, nil
+}
+
+func (d dexAPI) CreatePreAuthorizedCode(ctx context.Context, req *api.CreatePreAuthorizedCodeReq) (*api.CreatePreAuthorizedCodeResp, error) {
+	if req.ClientId == "" {
+		return nil, errors.New("no client supplied")
+	}
+	if req.UserId == "" {
+		return nil, errors.New("no user ID supplied")
+	}
+	if len(req.Scopes) == 0 {
+		return nil, errors.New("no scopes supplied")
+	}
+	if req.ExpiresIn < 0 {
+		return nil, errors.New("negative expiry supplied")
+	}
+	if _, err := d.s.GetClient(ctx, req.ClientId); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return &api.CreatePreAuthorizedCodeResp{ClientNotFound: true}, nil
+		}
+		d.logger.Errorf("api: failed to get client: %v", err)
+		return nil, fmt.Errorf("create pre-authorized code: %w", err)
+	}
+
+	validFor := defaultPreAuthCodeValidFor
+	if req.ExpiresIn != 0 {
+		validFor = time.Duration(req.ExpiresIn) * time.Second
+	}
+	now := time.Now().UTC().Round(time.Second)
+	c := storage.PreAuthorizedCode{
+		ClientID: req.ClientId,
+		Scopes:   req.Scopes,
+		Claims: storage.Claims{
+			UserID:   req.UserId,
+			Username: req.Username,
+			Email:    req.Email,
+			Groups:   req.Groups,
+		},
+		ConnectorID: req.ConnectorId,
+		CreatedAt:   now,
+		Expiry:      now.Add(validFor),
+	}
+	if c.ConnectorID == "" {
+		c.ConnectorID = preAuthCodeConnectorID
+	}
+	if err := newPreAuthorizedCode(&c, req.Pin); err != nil {
+		d.logger.Errorf("api: failed to hash pin: %v", err)
+		return nil, fmt.Errorf("create pre-authorized code: %w", err)
+	}
+	if err := d.s.CreatePreAuthorizedCode(ctx, c); err != nil {
+		d.logger.Errorf("api: failed to create pre-authorized code: %v", err)
+		return nil, fmt.Errorf("create pre-authorized code: %w", err)
+	}
+	return &api.CreatePreAuthorizedCodeResp{
+		Code:   c.ID,
+		Expiry: c.Expiry.Unix(),
+	}, nil
+}
+
+// localWebAuthnCredentials returns the passkeys of the local user with the
+// email.
+func (d dexAPI) localWebAuthnCredentials(ctx context.Context, email string) ([]storage.WebAuthnCredential, error) {
+	p, err := d.s.GetPassword(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	return d.s.ListWebAuthnCredentials(ctx, p.UserID, LocalConnector)
+}
+
+func (d dexAPI) ListWebAuthnCredentials(ctx context.Context, req *api.ListWebAuthnCredentialsReq) (*api.ListWebAuthnCredentialsResp, error) {
+	if req.Email == "" {
+		return nil, errors.New("no email supplied")
+	}
+	creds, err := d.localWebAuthnCredentials(ctx, req.Email)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return &api.ListWebAuthnCredentialsResp{NotFound: true}, nil
+		}
+		d.logger.Errorf("api: failed to list webauthn credentials: %v", err)
+		return nil, fmt.Errorf("list webauthn credentials: %w", err)
+	}
+	resp := &api.ListWebAuthnCredentialsResp{}
+	for _, c := range creds {
+		resp.Credentials = append(resp.Credentials, &api.WebAuthnCredential{
+			Id:        c.ID,
+			UserId:    c.UserID,
+			CreatedAt: c.CreatedAt.Unix(),
+			LastUsed:  c.LastUsed.Unix(),
+		})
+	}
+	return resp, nil
+}
+
+func (d dexAPI) DeleteWebAuthnCredentials(ctx context.Context, req *api.DeleteWebAuthnCredentialsReq) (*api.DeleteWebAuthnCredentialsResp, error) {
+	if req.Email == "" {
+		return nil, errors.New("no email supplied")
+	}
+	creds, err := d.localWebAuthnCredentials(ctx, req.Email)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return &api.DeleteWebAuthnCredentialsResp{NotFound: true}, nil
+		}
+		d.logger.Errorf("api: failed to list webauthn credentials: %v", err)
+		return nil, fmt.Errorf("delete webauthn credentials: %w", err)
+	}
+	found := false
+	for _, c := range creds {
+		if req.Id != "" && c.ID != req.Id {
+			continue
+		}
+		found = true
+		if err := d.s.DeleteWebAuthnCredential(ctx, c.ID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			d.logger.Errorf("api: failed to delete webauthn credential: %v", err)
+			return nil, fmt.Errorf("delete webauthn credentials: %w", err)
+		}
+	}
+	if req.Id != "" && !found {
+		return &api.DeleteWebAuthnCredentialsResp{NotFound: true}, nil
+	}
+	return &api.DeleteWebAuthnCredentialsResp{}, nil
+}
+
+func (d dexAPI) ResetTOTP(ctx context.Context, req *api.ResetTOTPReq) (*api.ResetTOTPResp, error) {
+	if req.Email == "" {
+		return nil, errors.New("no email supplied")
+	}
+	p, err := d.s.GetPassword(ctx, req.Email)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return &api.ResetTOTPResp{NotFound: true}, nil
+		}
+		d.logger.Errorf("api: failed to get password: %v", err)
+		return nil, fmt.Errorf("reset totp: %w", err)
+	}
+	if err := d.s.DeleteTOTPSecret(ctx, p.UserID, LocalConnector); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return &api.ResetTOTPResp{NotFound: true}, nil
+		}
+		d.logger.Errorf("api: failed to delete totp secret: %v", err)
+		return nil, fmt.Errorf("reset totp: %w", err)
+	}
+	return &api.ResetTOTPResp{}, nil
+}
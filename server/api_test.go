@@ -36,7 +36,7 @@ func newAPI(s storage.Storage, logger log.Logger, t *testing.T) *apiClient {
 	}
 
 	serv := grpc.NewServer()
-	api.RegisterDexServer(serv, NewAPI(s, logger))
+	api.RegisterDexServer(serv, NewAPI(s, logger, nil, nil, nil, 0))
 	go serv.Serve(l)
 
 	// Dial will retry automatically if the serv.Serve() goroutine
@@ -151,7 +151,7 @@ func TestPassword(t *testing.T) {
 		t.Fatalf("Unable to update password: %v", err)
 	}
 
-	pass, err := s.GetPassword(updateReq.Email)
+	pass, err := s.GetPassword(ctx, updateReq.Email)
 	if err != nil {
 		t.Fatalf("Unable to retrieve password: %v", err)
 	}
@@ -261,7 +261,7 @@ func TestRefreshToken(t *testing.T) {
 		ConnectorData: []byte(`{"some":"data"}`),
 	}
 
-	if err := s.CreateRefresh(r); err != nil {
+	if err := s.CreateRefresh(ctx, r); err != nil {
 		t.Fatalf("create refresh token: %v", err)
 	}
 
@@ -279,7 +279,7 @@ func TestRefreshToken(t *testing.T) {
 	}
 	session.Refresh[tokenRef.ClientID] = &tokenRef
 
-	if err := s.CreateOfflineSessions(session); err != nil {
+	if err := s.CreateOfflineSessions(ctx, session); err != nil {
 		t.Fatalf("create offline session: %v", err)
 	}
 
@@ -323,6 +323,9 @@ func TestRefreshToken(t *testing.T) {
 	if resp.NotFound {
 		t.Errorf("refresh token session wasn't found")
 	}
+	if revoked, err := s.GetRevokedToken(ctx, r.ID); err != nil || revoked.Reason != revocationRevoked {
+		t.Errorf("expected a tombstone of the revoked refresh token, got %+v, %v", revoked, err)
+	}
 
 	// Try to delete again.
 	//
@@ -414,6 +417,45 @@ func TestUpdateClient(t *testing.T) {
 				NotFound: false,
 			},
 		},
+		"update client metadata": {
+			setup:   createClient,
+			cleanup: deleteClient,
+			req: &api.UpdateClientReq{
+				Id:              "test",
+				TosUri:          "https://example.com/tos",
+				PolicyUri:       "https://example.com/privacy",
+				Contacts:        []string{"admin@example.com"},
+				ApplicationType: "native",
+			},
+			wantErr: false,
+			want: &api.UpdateClientResp{
+				NotFound: false,
+			},
+		},
+		"update client expiry": {
+			setup:   createClient,
+			cleanup: deleteClient,
+			req: &api.UpdateClientReq{
+				Id:     "test",
+				Expiry: 1893456000,
+			},
+			wantErr: false,
+			want: &api.UpdateClientResp{
+				NotFound: false,
+			},
+		},
+		"update client with invalid application type": {
+			setup:   createClient,
+			cleanup: deleteClient,
+			req: &api.UpdateClientReq{
+				Id:              "test",
+				ApplicationType: "desktop",
+			},
+			wantErr: true,
+			want: &api.UpdateClientResp{
+				NotFound: false,
+			},
+		},
 		"update client without ID": {
 			setup:   createClient,
 			cleanup: deleteClient,
@@ -463,7 +505,7 @@ func TestUpdateClient(t *testing.T) {
 					t.Errorf("expected in response NotFound: %t", tc.want.NotFound)
 				}
 
-				client, err := s.GetClient(tc.req.Id)
+				client, err := s.GetClient(ctx, tc.req.Id)
 				if err != nil {
 					t.Errorf("no client found in the storage: %v", err)
 				}
@@ -489,6 +531,13 @@ func TestUpdateClient(t *testing.T) {
 						t.Errorf("expected trusted peer: %s", peer)
 					}
 				}
+				if tc.req.TosUri != client.TOSURI || tc.req.PolicyUri != client.PolicyURI ||
+					tc.req.ApplicationType != client.ApplicationType || len(tc.req.Contacts) != len(client.Contacts) {
+					t.Errorf("expected stored client with metadata of %v, found %+v", tc.req, client)
+				}
+				if tc.req.Expiry != 0 && tc.req.Expiry != client.Expiry.Unix() {
+					t.Errorf("expected stored client to expire at %d, found %v", tc.req.Expiry, client.Expiry)
+				}
 			}
 
 			if tc.cleanup != nil {
@@ -506,3 +555,31 @@ func find(item string, items []string) bool {
 	}
 	return false
 }
+
+func TestListFeatures(t *testing.T) {
+	logger := &logrus.Logger{
+		Out:       os.Stderr,
+		Formatter: &logrus.TextFormatter{DisableColors: true},
+		Level:     logrus.DebugLevel,
+	}
+
+	s := memory.New(logger)
+	client := newAPI(s, logger, t)
+	defer client.Close()
+
+	resp, err := client.ListFeatures(context.Background(), &api.ListFeaturesReq{})
+	if err != nil {
+		t.Fatalf("Unable to list features: %v", err)
+	}
+	if len(resp.Features) != len(knownFeatures) {
+		t.Fatalf("Expected %d features, got %d", len(knownFeatures), len(resp.Features))
+	}
+	for _, f := range resp.Features {
+		if f.Enabled {
+			t.Errorf("Feature %q reported enabled without being configured", f.Name)
+		}
+		if f.Supported != knownFeatures[Feature(f.Name)] {
+			t.Errorf("Feature %q reported supported = %t", f.Name, f.Supported)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dexidp/dex/pkg/audit"
+	"github.com/dexidp/dex/storage"
+)
+
+// apiKeyPrefix starts every API key, so leaked keys are easy to recognize,
+// for example by secret scanners.
+const apiKeyPrefix = "dex_"
+
+// apiKeyConnectorID is the connector ID of tokens issued for API keys
+// created without one.
+const apiKeyConnectorID = "api_key"
+
+// newAPIKey returns a key for k, which must not have an ID or hash yet, and
+// sets both. The key is only returned once, storage only keeps its hash.
+func newAPIKey(k *storage.APIKey) string {
+	k.ID = storage.NewID()
+	secret := storage.NewID() + storage.NewID()
+	k.Hash = hashAPIKeySecret(secret)
+	return apiKeyPrefix + k.ID + "_" + secret
+}
+
+// parseAPIKey splits a key into its ID and secret.
+func parseAPIKey(key string) (id, secret string, ok bool) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return "", "", false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(key, apiKeyPrefix), "_", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+func hashAPIKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// apiKeyScopes returns the scopes requested with an API key, which must be a
+// subset of the key's scopes. No scopes requests all of them.
+func apiKeyScopes(k storage.APIKey, requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		return k.Scopes, nil
+	}
+	for _, scope := range requested {
+		if !contains(k.Scopes, scope) {
+			return nil, fmt.Errorf("scope %q is not granted to the API key", scope)
+		}
+	}
+	return requested, nil
+}
+
+// handleAPIKeyGrant exchanges an API key for an ID token and access token of
+// the key's client. It returns the ID of the client once the key has been
+// authenticated.
+func (s *Server) handleAPIKeyGrant(w http.ResponseWriter, r *http.Request) string {
+	ctx := r.Context()
+	id, secret, ok := parseAPIKey(r.PostFormValue("api_key"))
+	if !ok {
+		s.tokenErrHelper(w, errInvalidClient, "Invalid API key.", http.StatusUnauthorized)
+		return ""
+	}
+	k, err := s.storage.GetAPIKey(ctx, id)
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			s.logger.Errorf("failed to get API key: %v", err)
+			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+			return ""
+		}
+		// Compare against a dummy hash so unknown keys take as long as wrong
+		// secrets.
+		k.Hash = hashAPIKeySecret("")
+	}
+	hash := hashAPIKeySecret(secret)
+	if err != nil || subtle.ConstantTimeCompare([]byte(hash), []byte(k.Hash)) != 1 {
+		s.delayFailure(ctx)
+		s.tokenErrHelper(w, errInvalidClient, "Invalid API key.", http.StatusUnauthorized)
+		return ""
+	}
+	if !k.Expiry.IsZero() && s.expired(k.Expiry) {
+		s.tokenErrHelper(w, errInvalidClient, "API key has expired.", http.StatusUnauthorized)
+		return ""
+	}
+
+	client, err := s.getClient(ctx, k.ClientID)
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			s.logger.Errorf("failed to get client: %v", err)
+			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		} else {
+			s.tokenErrHelper(w, errInvalidClient, "Invalid API key.", http.StatusUnauthorized)
+		}
+		return ""
+	}
+	if !s.checkClientNetwork(w, r, client) {
+		return client.ID
+	}
+
+	scopes, err := apiKeyScopes(k, strings.Fields(r.PostFormValue("scope")))
+	if err != nil {
+		s.tokenErrHelper(w, errInvalidScope, err.Error(), http.StatusBadRequest)
+		return client.ID
+	}
+	if msg, ok := s.checkAccessWindows(client.ID, k.Claims); !ok {
+		s.tokenErrHelper(w, errAccessDenied, msg, http.StatusForbidden)
+		return client.ID
+	}
+
+	connID := k.ConnectorID
+	if connID == "" {
+		connID = apiKeyConnectorID
+	}
+	scopes, msg, ok := s.authorize(r, grantTypeAPIKey, client.ID, connID, k.Claims, scopes)
+	if !ok {
+		s.tokenErrHelper(w, errAccessDenied, msg, http.StatusForbidden)
+		return client.ID
+	}
+	accessToken, err := s.newAccessToken(ctx, client.ID, k.Claims, scopes, "", connID)
+	if err != nil {
+		s.logger.Errorf("failed to create new access token: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return client.ID
+	}
+	idToken, expiry, err := s.newIDToken(ctx, client.ID, k.Claims, scopes, "", accessToken, connID)
+	if err != nil {
+		s.logger.Errorf("failed to create ID token: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return client.ID
+	}
+
+	s.emitAudit(ctx, audit.Event{
+		Type:        audit.EventAPIKeyUsed,
+		Severity:    audit.SeverityInfo,
+		ClientID:    client.ID,
+		Subject:     subjectFor(k.Claims.UserID, connID),
+		ConnectorID: connID,
+		SourceIPs:   []string{remoteIP(r)},
+		Message:     "API key " + k.ID,
+	})
+	// API keys are long-lived already, they are never exchanged for refresh
+	// tokens.
+	s.writeAccessToken(w, idToken, accessToken, "", expiry)
+	return client.ID
+}
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"gopkg.in/square/go-jose.v2"
+
+	"github.com/dexidp/dex/api/v2"
+	"github.com/dexidp/dex/pkg/audit"
+	"github.com/dexidp/dex/storage"
+	"github.com/dexidp/dex/storage/memory"
+)
+
+func TestParseAPIKey(t *testing.T) {
+	var k storage.APIKey
+	key := newAPIKey(&k)
+	id, secret, ok := parseAPIKey(key)
+	if !ok || id != k.ID || hashAPIKeySecret(secret) != k.Hash {
+		t.Errorf("failed to parse new API key %q", key)
+	}
+	for _, key := range []string{"", "dex_", "dex_id", "dex_id_", "dex__secret", "key_id_secret"} {
+		if _, _, ok := parseAPIKey(key); ok {
+			t.Errorf("expected %q to be rejected", key)
+		}
+	}
+}
+
+func TestAPIKeyGrant(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sink := new(recordingSink)
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.AllowAPIKeys = true
+		c.AuditSink = sink
+	})
+	defer httpServer.Close()
+
+	if err := s.storage.CreateClient(ctx, storage.Client{ID: "backup", Secret: "backup-secret"}); err != nil {
+		t.Fatal(err)
+	}
+	a := NewAPI(s.storage, logger, nil, nil, nil, 0)
+	create := func(expiry int64) *api.CreateAPIKeyResp {
+		resp, err := a.CreateAPIKey(ctx, &api.CreateAPIKeyReq{
+			ClientId: "backup",
+			Name:     "nightly backup",
+			Scopes:   []string{"openid", "groups"},
+			UserId:   "backup-bot",
+			Groups:   []string{"operators"},
+			Expiry:   expiry,
+		})
+		if err != nil {
+			t.Fatalf("create api key: %v", err)
+		}
+		return resp
+	}
+	exchange := func(key, scope string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, tokenRequest(storage.Client{}, "10.0.0.1:1234", url.Values{
+			"grant_type": {grantTypeAPIKey},
+			"api_key":    {key},
+			"scope":      {scope},
+		}))
+		return rr
+	}
+
+	resp := create(0)
+	rr := exchange(resp.Key, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected API key to be exchanged, got %d: %s", rr.Code, rr.Body)
+	}
+	var tokens struct {
+		IDToken      string `json:"id_token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &tokens); err != nil {
+		t.Fatal(err)
+	}
+	if tokens.RefreshToken != "" {
+		t.Errorf("expected no refresh token for API keys")
+	}
+	jws, err := jose.ParseSigned(tokens.IDToken)
+	if err != nil {
+		t.Fatalf("failed to parse id token: %v", err)
+	}
+	var claims struct {
+		Aud    string   `json:"aud"`
+		Groups []string `json:"groups"`
+	}
+	if err := json.Unmarshal(jws.UnsafePayloadWithoutVerification(), &claims); err != nil {
+		t.Fatal(err)
+	}
+	if claims.Aud != "backup" || len(claims.Groups) != 1 || claims.Groups[0] != "operators" {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+	if len(sink.events) != 1 || sink.events[0].Type != audit.EventAPIKeyUsed || sink.events[0].ClientID != "backup" {
+		t.Errorf("expected an api_key_used audit event, got %+v", sink.events)
+	}
+
+	if rr := exchange(resp.Key, "openid"); rr.Code != http.StatusOK {
+		t.Errorf("expected subset of the key's scopes to be allowed, got %d", rr.Code)
+	}
+	if rr := exchange(resp.Key, "openid email"); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected scope outside of the key's scopes to be rejected, got %d", rr.Code)
+	}
+	if rr := exchange(resp.Key+"x", ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected wrong secret to be rejected, got %d", rr.Code)
+	}
+
+	expired := create(time.Now().Add(-time.Minute).Unix())
+	if rr := exchange(expired.Key, ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected expired key to be rejected, got %d", rr.Code)
+	}
+
+	if _, err := a.RevokeAPIKey(ctx, &api.RevokeAPIKeyReq{Id: resp.ApiKey.Id}); err != nil {
+		t.Fatalf("revoke api key: %v", err)
+	}
+	if rr := exchange(resp.Key, ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected revoked key to be rejected, got %d", rr.Code)
+	}
+}
+
+func TestAPIKeysAPI(t *testing.T) {
+	ctx := context.Background()
+	s := memory.New(logger)
+	if err := s.CreateClient(ctx, storage.Client{ID: "backup"}); err != nil {
+		t.Fatal(err)
+	}
+	a := NewAPI(s, logger, nil, nil, nil, 0)
+
+	resp, err := a.CreateAPIKey(ctx, &api.CreateAPIKeyReq{ClientId: "missing", UserId: "1", Scopes: []string{"openid"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.ClientNotFound {
+		t.Errorf("expected API key of unknown client to be rejected")
+	}
+
+	resp, err = a.CreateAPIKey(ctx, &api.CreateAPIKeyReq{ClientId: "backup", UserId: "1", Scopes: []string{"openid"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := s.GetAPIKey(ctx, resp.ApiKey.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Hash == "" || stored.Hash == resp.Key || stored.ConnectorID != apiKeyConnectorID {
+		t.Errorf("unexpected stored API key %+v", stored)
+	}
+
+	list, err := a.ListAPIKeys(ctx, &api.ListAPIKeysReq{ClientId: "backup"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.ApiKeys) != 1 || list.ApiKeys[0].Id != resp.ApiKey.Id {
+		t.Errorf("unexpected API keys %+v", list.ApiKeys)
+	}
+	list, err = a.ListAPIKeys(ctx, &api.ListAPIKeysReq{ClientId: "other"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.ApiKeys) != 0 {
+		t.Errorf("expected no API keys of other clients, got %+v", list.ApiKeys)
+	}
+
+	revoked, err := a.RevokeAPIKey(ctx, &api.RevokeAPIKeyReq{Id: resp.ApiKey.Id})
+	if err != nil || revoked.NotFound {
+		t.Fatalf("revoke api key: %v %+v", err, revoked)
+	}
+	revoked, err = a.RevokeAPIKey(ctx, &api.RevokeAPIKeyReq{Id: resp.ApiKey.Id})
+	if err != nil || !revoked.NotFound {
+		t.Errorf("expected revoked key to be not found: %v %+v", err, revoked)
+	}
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// assetHashes maps the paths of static and theme assets, relative to the
+// issuer URL, to a hash of their content. Templates add the hash to asset
+// URLs so browsers can cache assets until they change.
+type assetHashes map[string]string
+
+// hashAssets adds the hashes of all files in dir, served under prefix.
+func (a assetHashes) hashAssets(prefix, dir string) error {
+	return filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			return fmt.Errorf("hash %s: %w", p, err)
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		a[path.Join(prefix, filepath.ToSlash(rel))] = hex.EncodeToString(h.Sum(nil))[:16]
+		return nil
+	})
+}
+
+// versionedURL appends the content hash of the asset to its URL.
+func (a assetHashes) versionedURL(assetPath, u string) string {
+	if v, ok := a[path.Clean("/" + assetPath)[1:]]; ok {
+		return u + "?v=" + v
+	}
+	return u
+}
+
+// cacheAssets sets the caching headers of the assets served under prefix.
+// Requests carrying the current content hash can be cached for good, any
+// other request has to be revalidated.
+func (a assetHashes) cacheAssets(prefix string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v := r.URL.Query().Get("v")
+		if v != "" && v == a[path.Join(prefix, path.Clean("/"+r.URL.Path))] {
+			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		} else {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+		h.ServeHTTP(w, r)
+	})
+}
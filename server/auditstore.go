@@ -0,0 +1,29 @@
+package server
+
+import (
+	"context"
+
+	"github.com/dexidp/dex/pkg/audit"
+	"github.com/dexidp/dex/storage"
+)
+
+// storageSink persists audit events, so they can be queried through the
+// ListAuditEvents API call.
+type storageSink struct {
+	s storage.Storage
+}
+
+func (st storageSink) Emit(ctx context.Context, e audit.Event) error {
+	return st.s.CreateAuditEvent(ctx, storage.AuditEvent{
+		ID:          storage.NewID(),
+		Type:        e.Type,
+		Severity:    string(e.Severity),
+		Time:        e.Time,
+		ClientID:    e.ClientID,
+		Subject:     e.Subject,
+		ConnectorID: e.ConnectorID,
+		SourceIPs:   e.SourceIPs,
+		Message:     e.Message,
+		Revoked:     e.Revoked,
+	})
+}
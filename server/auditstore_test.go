@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dexidp/dex/api/v2"
+	"github.com/dexidp/dex/pkg/audit"
+	"github.com/dexidp/dex/storage"
+)
+
+func TestListAuditEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.AuditSink = new(recordingSink)
+		c.AuditRetention = 24 * time.Hour
+		c.Now = func() time.Time { return now }
+	})
+	defer httpServer.Close()
+
+	s.emitAudit(ctx, audit.Event{Type: audit.EventLogin, Severity: audit.SeverityInfo, Time: now.Add(-2 * time.Hour), ClientID: "app", Subject: "alice"})
+	s.emitAudit(ctx, audit.Event{Type: audit.EventRefreshTokenReuse, Severity: audit.SeverityHigh, Time: now.Add(-time.Hour), ClientID: "app", Subject: "alice", SourceIPs: []string{"192.0.2.1", "198.51.100.7"}, Revoked: true})
+	s.emitAudit(ctx, audit.Event{Type: audit.EventLogin, Severity: audit.SeverityInfo, ClientID: "cli", Subject: "bob"})
+	s.emitAudit(ctx, audit.Event{Type: audit.EventLogin, Severity: audit.SeverityInfo, Time: now.Add(-48 * time.Hour), ClientID: "app", Subject: "alice"})
+
+	client := newAPI(s.storage, logger, t)
+	defer client.Close()
+
+	tests := []struct {
+		name string
+		req  *api.ListAuditEventsReq
+		want []string // subject:type of the expected events, newest first
+	}{
+		{"all", &api.ListAuditEventsReq{}, []string{"bob:login", "alice:refresh_token_reuse", "alice:login", "alice:login"}},
+		{"subject", &api.ListAuditEventsReq{Subject: "bob"}, []string{"bob:login"}},
+		{"client and type", &api.ListAuditEventsReq{ClientId: "app", Type: audit.EventLogin}, []string{"alice:login", "alice:login"}},
+		{"time range", &api.ListAuditEventsReq{Since: now.Add(-3 * time.Hour).Unix(), Until: now.Unix()}, []string{"alice:refresh_token_reuse", "alice:login"}},
+		{"limit", &api.ListAuditEventsReq{Limit: 1}, []string{"bob:login"}},
+	}
+	for _, tc := range tests {
+		resp, err := client.ListAuditEvents(ctx, tc.req)
+		if err != nil {
+			t.Fatalf("%s: list audit events: %v", tc.name, err)
+		}
+		var got []string
+		for _, e := range resp.Events {
+			got = append(got, e.Subject+":"+e.Type)
+		}
+		if len(got) != len(tc.want) {
+			t.Errorf("%s: expected events %v, got %v", tc.name, tc.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("%s: expected events %v, got %v", tc.name, tc.want, got)
+				break
+			}
+		}
+	}
+
+	resp, err := client.ListAuditEvents(ctx, &api.ListAuditEventsReq{Type: audit.EventRefreshTokenReuse})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Events) != 1 {
+		t.Fatalf("expected one reuse event, got %d", len(resp.Events))
+	}
+	e := resp.Events[0]
+	if e.Severity != string(audit.SeverityHigh) || !e.Revoked || len(e.SourceIps) != 2 || e.Time != now.Add(-time.Hour).Unix() || e.Id == "" {
+		t.Errorf("unexpected event %+v", e)
+	}
+
+	n, err := s.storage.PruneAuditEvents(ctx, now.Add(-s.auditRetention))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("expected the event older than the retention to be pruned, pruned %d", n)
+	}
+}
+
+func TestAuditEventsNotPersistedByDefault(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.AuditSink = new(recordingSink)
+	})
+	defer httpServer.Close()
+
+	s.emitAudit(ctx, audit.Event{Type: audit.EventLogin, Severity: audit.SeverityInfo, ClientID: "app"})
+	events, err := s.storage.ListAuditEvents(ctx, storage.AuditEventFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Errorf("expected no persisted events without a retention, got %d", len(events))
+	}
+}
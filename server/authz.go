@@ -0,0 +1,182 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dexidp/dex/pkg/audit"
+	"github.com/dexidp/dex/storage"
+)
+
+// AuthorizationRequest describes tokens about to be issued, for an external
+// authorizer to decide on.
+type AuthorizationRequest struct {
+	// Subject is the "sub" claim of the tokens.
+	Subject     string   `json:"subject"`
+	UserID      string   `json:"userID"`
+	Username    string   `json:"username,omitempty"`
+	Email       string   `json:"email,omitempty"`
+	Groups      []string `json:"groups,omitempty"`
+	ClientID    string   `json:"clientID"`
+	ConnectorID string   `json:"connectorID"`
+	GrantType   string   `json:"grantType"`
+	Scopes      []string `json:"scopes"`
+	RemoteIP    string   `json:"remoteIP"`
+	UserAgent   string   `json:"userAgent,omitempty"`
+}
+
+// AuthorizationDecision is the answer of an external authorizer.
+type AuthorizationDecision struct {
+	Allow bool `json:"allow"`
+	// Reason is shown to the user if the request is denied.
+	Reason string `json:"reason,omitempty"`
+	// Scopes, if set, restricts the tokens to these of the requested
+	// scopes. Scopes that weren't requested are ignored.
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+// Authorizer is an external service consulted before any tokens are issued.
+// Unlike a PolicyEngine it sees the whole request and may reduce the scopes
+// tokens are issued for.
+type Authorizer interface {
+	Authorize(ctx context.Context, req AuthorizationRequest) (AuthorizationDecision, error)
+}
+
+// HTTPAuthorizer posts each AuthorizationRequest as JSON to an endpoint,
+// which responds with an AuthorizationDecision.
+//
+// If OPA is set, the endpoint is an Open Policy Agent data API, such as
+// "http://opa:8181/v1/data/dex/authz". The request is wrapped as {"input":
+// ...} and the decision read from the "result" of the response, which is
+// either a boolean or a decision object. An undefined result denies.
+//
+// https://www.openpolicyagent.org/docs/latest/rest-api/#get-a-document-with-input
+type HTTPAuthorizer struct {
+	URL string
+	OPA bool
+	// Client is used to call the endpoint. If nil, http.DefaultClient is
+	// used.
+	Client *http.Client
+}
+
+// NewHTTPAuthorizer returns an authorizer calling url, giving up after
+// timeout.
+func NewHTTPAuthorizer(url string, opa bool, timeout time.Duration) *HTTPAuthorizer {
+	return &HTTPAuthorizer{URL: url, OPA: opa, Client: &http.Client{Timeout: timeout}}
+}
+
+// Authorize implements Authorizer.
+func (h *HTTPAuthorizer) Authorize(ctx context.Context, req AuthorizationRequest) (AuthorizationDecision, error) {
+	var payload interface{} = req
+	if h.OPA {
+		payload = struct {
+			Input AuthorizationRequest `json:"input"`
+		}{req}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return AuthorizationDecision{}, fmt.Errorf("marshal authorization request: %w", err)
+	}
+	r, err := http.NewRequest("POST", h.URL, bytes.NewReader(body))
+	if err != nil {
+		return AuthorizationDecision{}, fmt.Errorf("create authorization request: %w", err)
+	}
+	r.Header.Set("Content-Type", "application/json")
+
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(r.WithContext(ctx))
+	if err != nil {
+		return AuthorizationDecision{}, fmt.Errorf("call authorizer: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return AuthorizationDecision{}, fmt.Errorf("authorizer returned unexpected status %s", resp.Status)
+	}
+
+	var decision AuthorizationDecision
+	if !h.OPA {
+		if err := json.NewDecoder(resp.Body).Decode(&decision); err != nil {
+			return decision, fmt.Errorf("decode authorization decision: %w", err)
+		}
+		return decision, nil
+	}
+	var opa struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&opa); err != nil {
+		return decision, fmt.Errorf("decode authorization decision: %w", err)
+	}
+	if len(opa.Result) == 0 {
+		return decision, nil
+	}
+	if err := json.Unmarshal(opa.Result, &decision.Allow); err == nil {
+		return decision, nil
+	}
+	if err := json.Unmarshal(opa.Result, &decision); err != nil {
+		return decision, fmt.Errorf("decode authorization decision: %w", err)
+	}
+	return decision, nil
+}
+
+// authorize consults the external authorizer, if any, before tokens are
+// issued for scopes. It returns the scopes to issue the tokens for, or false
+// and a message shown to the user if the authorizer denies the request or
+// can't be reached.
+func (s *Server) authorize(r *http.Request, grantType, clientID, connID string, claims storage.Claims, scopes []string) (granted []string, denied string, ok bool) {
+	if s.authorizer == nil {
+		return scopes, "", true
+	}
+	req := AuthorizationRequest{
+		Subject:     subjectFor(claims.UserID, connID),
+		UserID:      claims.UserID,
+		Username:    claims.Username,
+		Email:       claims.Email,
+		Groups:      claims.Groups,
+		ClientID:    clientID,
+		ConnectorID: connID,
+		GrantType:   grantType,
+		Scopes:      scopes,
+		RemoteIP:    remoteIP(r),
+		UserAgent:   r.UserAgent(),
+	}
+	if req.Scopes == nil {
+		req.Scopes = []string{}
+	}
+	decision, err := s.authorizer.Authorize(r.Context(), req)
+	if err != nil {
+		s.logger.Errorf("failed to authorize tokens for client %s: %v", clientID, err)
+		return nil, "Authorization service unavailable.", false
+	}
+	if !decision.Allow {
+		s.emitAudit(r.Context(), audit.Event{
+			Type:        audit.EventAuthorizationDenied,
+			Severity:    audit.SeverityWarning,
+			ClientID:    clientID,
+			Subject:     req.Subject,
+			ConnectorID: connID,
+			SourceIPs:   []string{req.RemoteIP},
+			Message:     decision.Reason,
+		})
+		if decision.Reason == "" {
+			return nil, "Access denied by authorization policy.", false
+		}
+		return nil, decision.Reason, false
+	}
+	if decision.Scopes == nil {
+		return scopes, "", true
+	}
+	granted = []string{}
+	for _, scope := range scopes {
+		if contains(decision.Scopes, scope) {
+			granted = append(granted, scope)
+		}
+	}
+	return granted, "", true
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	jose "gopkg.in/square/go-jose.v2"
+
+	"github.com/dexidp/dex/pkg/audit"
+	"github.com/dexidp/dex/storage"
+)
+
+func TestHTTPAuthorizer(t *testing.T) {
+	tests := []struct {
+		name     string
+		opa      bool
+		response string
+		want     AuthorizationDecision
+	}{
+		{"decision", false, `{"allow": true, "scopes": ["openid"]}`, AuthorizationDecision{Allow: true, Scopes: []string{"openid"}}},
+		{"opa boolean", true, `{"result": true}`, AuthorizationDecision{Allow: true}},
+		{"opa decision", true, `{"result": {"allow": false, "reason": "No."}}`, AuthorizationDecision{Reason: "No."}},
+		{"opa undefined", true, `{}`, AuthorizationDecision{}},
+	}
+	for _, tc := range tests {
+		var input map[string]interface{}
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&input)
+			fmt.Fprint(w, tc.response)
+		}))
+		a := &HTTPAuthorizer{URL: srv.URL, OPA: tc.opa}
+		got, err := a.Authorize(context.Background(), AuthorizationRequest{ClientID: "app"})
+		srv.Close()
+		if err != nil {
+			t.Errorf("%s: authorize: %v", tc.name, err)
+			continue
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+			t.Errorf("%s: expected decision %+v, got %+v", tc.name, tc.want, got)
+		}
+		if tc.opa {
+			input, _ = input["input"].(map[string]interface{})
+		}
+		if input["clientID"] != "app" {
+			t.Errorf("%s: unexpected request %v", tc.name, input)
+		}
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	if _, err := (&HTTPAuthorizer{URL: srv.URL}).Authorize(context.Background(), AuthorizationRequest{}); err == nil {
+		t.Error("expected error for unexpected status")
+	}
+}
+
+func TestAuthorizerPasswordGrant(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var input AuthorizationRequest
+	opa := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input AuthorizationRequest `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		input = req.Input
+		if contains(input.Scopes, "groups") {
+			fmt.Fprint(w, `{"result": {"allow": false, "reason": "Groups are off limits."}}`)
+			return
+		}
+		fmt.Fprint(w, `{"result": {"allow": true, "scopes": ["openid"]}}`)
+	}))
+	defer opa.Close()
+
+	sink := new(recordingSink)
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.PasswordConnector = "password"
+		c.AuditSink = sink
+		c.Authorizer = &HTTPAuthorizer{URL: opa.URL, OPA: true}
+	})
+	defer httpServer.Close()
+
+	conn := storage.Connector{
+		ID:     "password",
+		Type:   "mockPassword",
+		Name:   "Password",
+		Config: []byte(`{"username": "jane", "password": "hunter2"}`),
+	}
+	if err := s.storage.CreateConnector(ctx, conn); err != nil {
+		t.Fatalf("create connector: %v", err)
+	}
+	client := storage.Client{ID: "test", Secret: "barfoo", RedirectURIs: []string{"https://example.com/callback"}}
+	if err := s.storage.CreateClient(ctx, client); err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+	login := func(scope string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, tokenRequest(client, "10.0.0.1:1234", url.Values{
+			"grant_type": {grantTypePassword},
+			"scope":      {scope},
+			"username":   {"jane"},
+			"password":   {"hunter2"},
+		}))
+		return rr
+	}
+
+	rr := login("openid email")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected password grant to succeed, got %d: %s", rr.Code, rr.Body)
+	}
+	if input.ClientID != "test" || input.ConnectorID != "password" || input.GrantType != grantTypePassword ||
+		input.RemoteIP != "10.0.0.1" || input.Email != "kilgore@kilgore.trout" {
+		t.Errorf("unexpected authorization request %+v", input)
+	}
+	var resp struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode token response: %v", err)
+	}
+	jws, err := jose.ParseSigned(resp.IDToken)
+	if err != nil {
+		t.Fatalf("parse id token: %v", err)
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(jws.UnsafePayloadWithoutVerification(), &claims); err != nil {
+		t.Fatalf("decode id token claims: %v", err)
+	}
+	if _, ok := claims["email"]; ok {
+		t.Errorf("expected email scope to be removed by the authorizer, got claims %v", claims)
+	}
+
+	rr = login("openid groups")
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected password grant to be denied, got %d: %s", rr.Code, rr.Body)
+	}
+	var errResp struct {
+		Description string `json:"error_description"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &errResp)
+	if errResp.Description != "Groups are off limits." {
+		t.Errorf("expected reason of the authorizer, got %q", errResp.Description)
+	}
+	var denied int
+	for _, e := range sink.events {
+		if e.Type == audit.EventAuthorizationDenied {
+			denied++
+		}
+	}
+	if denied != 1 {
+		t.Errorf("expected one %s event, got %v", audit.EventAuthorizationDenied, sink.events)
+	}
+}
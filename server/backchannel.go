@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dexidp/dex/pkg/alert"
+	"github.com/dexidp/dex/storage"
+)
+
+// backchannelLogoutEvent identifies logout tokens, so they can't be confused
+// with ID tokens.
+//
+// https://openid.net/specs/openid-connect-backchannel-1_0.html#LogoutToken
+const backchannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+// backchannelLogoutTimeout bounds how long dex waits for each client to
+// acknowledge a logout token.
+const backchannelLogoutTimeout = 10 * time.Second
+
+// logoutTokenClaims are the claims of a logout token. It names the user in
+// sub but carries no sid: ID tokens don't carry one either, and the session
+// ID is the value of the session cookie.
+type logoutTokenClaims struct {
+	Issuer   string                 `json:"iss"`
+	Subject  string                 `json:"sub"`
+	Audience audience               `json:"aud"`
+	IssuedAt int64                  `json:"iat"`
+	Expiry   int64                  `json:"exp"`
+	ID       string                 `json:"jti"`
+	Events   map[string]interface{} `json:"events"`
+}
+
+// sendBackchannelLogout notifies the clients the user logged in to during the
+// session that it ended. Tokens are sent to the clients in parallel and in
+// the background, so a slow client doesn't hold up the logout or the other
+// clients; failures are only logged.
+func (s *Server) sendBackchannelLogout(session storage.Session) {
+	for _, clientID := range session.ClientIDs {
+		go func(clientID string) {
+			ctx, cancel := context.WithTimeout(context.Background(), backchannelLogoutTimeout)
+			defer cancel()
+			if err := s.backchannelLogout(ctx, clientID, session); err != nil {
+				s.logger.Errorf("failed to send back-channel logout to client %q: %v", clientID, err)
+			}
+		}(clientID)
+	}
+}
+
+func (s *Server) backchannelLogout(ctx context.Context, clientID string, session storage.Session) error {
+	client, err := s.storage.GetClient(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil
+		}
+		return fmt.Errorf("get client: %w", err)
+	}
+	if client.BackchannelLogoutURI == "" {
+		return nil
+	}
+
+	now := s.now()
+	payload, err := json.Marshal(logoutTokenClaims{
+		Issuer:   s.issuerURL.String(),
+		Subject:  subjectFor(session.Claims.UserID, session.ConnectorID),
+		Audience: audience{clientID},
+		IssuedAt: now.Unix(),
+		Expiry:   now.Add(2 * time.Minute).Unix(),
+		ID:       storage.NewID(),
+		Events:   map[string]interface{}{backchannelLogoutEvent: struct{}{}},
+	})
+	if err != nil {
+		return fmt.Errorf("marshal logout token: %w", err)
+	}
+	token, err := s.signToken(ctx, payload)
+	if err != nil {
+		return err
+	}
+
+	form := url.Values{"logout_token": {token}}
+	req, err := http.NewRequest(http.MethodPost, client.BackchannelLogoutURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// signToken signs a JWT with the current signing key, or the migration signer
+// while signing keys are migrated.
+func (s *Server) signToken(ctx context.Context, payload []byte) (string, error) {
+	if m := s.signingMigration; m != nil {
+		token, err := m.signer.Sign(ctx, payload)
+		if err != nil {
+			s.reportFailure(alert.KindSigning, "migration", err)
+			return "", fmt.Errorf("failed to sign payload with migration signer: %w", err)
+		}
+		return token, nil
+	}
+	keys, err := s.storage.GetKeys(ctx)
+	if err != nil {
+		s.reportFailure(alert.KindStorage, "", err)
+		return "", fmt.Errorf("failed to get keys: %w", err)
+	}
+	if keys.SigningKey == nil {
+		err := errors.New("no key to sign payload with")
+		s.reportFailure(alert.KindSigning, "", err)
+		return "", err
+	}
+	alg, err := signatureAlgorithm(keys.SigningKey)
+	if err != nil {
+		s.reportFailure(alert.KindSigning, "", err)
+		return "", err
+	}
+	token, err := signPayload(keys.SigningKey, alg, payload)
+	if err != nil {
+		s.reportFailure(alert.KindSigning, "", err)
+		return "", fmt.Errorf("failed to sign payload: %w", err)
+	}
+	return token, nil
+}
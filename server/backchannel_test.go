@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	oidc "github.com/coreos/go-oidc"
+
+	"github.com/dexidp/dex/storage"
+)
+
+func TestBackchannelLogout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tokens := make(chan string, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens <- r.PostFormValue("logout_token")
+	}))
+	defer receiver.Close()
+
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.AdminToken = "s3cret"
+		c.SessionsValidFor = time.Hour
+	})
+	defer httpServer.Close()
+
+	client := storage.Client{ID: "app", Secret: "secret", BackchannelLogoutURI: receiver.URL}
+	if err := s.storage.CreateClient(ctx, client); err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+	newSession := func() storage.Session {
+		session := storage.Session{
+			ID:          storage.NewID(),
+			ConnectorID: "mock",
+			Claims:      storage.Claims{UserID: "0-385-28089-0"},
+			ClientIDs:   []string{client.ID, "unregistered"},
+			CreatedAt:   s.now(),
+			Expiry:      s.now().Add(time.Hour),
+		}
+		if err := s.storage.CreateSession(ctx, session); err != nil {
+			t.Fatalf("create session: %v", err)
+		}
+		return session
+	}
+	verifier := oidc.NewVerifier(s.issuerURL.String(), s.verificationKeys(), &oidc.Config{ClientID: client.ID})
+	checkToken := func(session storage.Session) {
+		t.Helper()
+		var raw string
+		select {
+		case raw = <-tokens:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no logout token received")
+		}
+		token, err := verifier.Verify(ctx, raw)
+		if err != nil {
+			t.Fatalf("verify logout token: %v", err)
+		}
+		var claims struct {
+			SessionID string                 `json:"sid"`
+			Nonce     string                 `json:"nonce"`
+			Events    map[string]interface{} `json:"events"`
+		}
+		if err := token.Claims(&claims); err != nil {
+			t.Fatalf("decode logout token: %v", err)
+		}
+		if claims.SessionID != "" || claims.Nonce != "" || token.Subject != subjectFor(session.Claims.UserID, session.ConnectorID) {
+			t.Errorf("unexpected logout token claims %+v, subject %q", claims, token.Subject)
+		}
+		if _, ok := claims.Events[backchannelLogoutEvent]; !ok {
+			t.Errorf("expected logout event, got %v", claims.Events)
+		}
+	}
+
+	// The user logs out.
+	session := newSession()
+	req := httptest.NewRequest(http.MethodGet, "/logout", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: session.ID})
+	s.ServeHTTP(httptest.NewRecorder(), req)
+	checkToken(session)
+
+	// An administrator revokes the session.
+	session = newSession()
+	admin := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer s3cret")
+		rr := httptest.NewRecorder()
+		s.AdminHandler().ServeHTTP(rr, req)
+		return rr
+	}
+	rr := admin(http.MethodGet, "/sessions")
+	var list []adminSession
+	if err := json.NewDecoder(rr.Body).Decode(&list); err != nil || len(list) != 1 || list[0].ID != session.ID {
+		t.Fatalf("expected session to be listed, got %v %v", list, err)
+	}
+	if rr := admin(http.MethodDelete, "/sessions/"+session.ID); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected session to be revoked, got %d: %s", rr.Code, strings.TrimSpace(rr.Body.String()))
+	}
+	checkToken(session)
+	if rr := admin(http.MethodDelete, "/sessions/"+session.ID); rr.Code != http.StatusNotFound {
+		t.Errorf("expected revoked session to be gone, got %d", rr.Code)
+	}
+}
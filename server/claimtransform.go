@@ -0,0 +1,178 @@
+package server
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/dexidp/dex/connector"
+)
+
+// Operations of claim transforms.
+const (
+	// ClaimOpRename moves the value of the From claim into the claim.
+	ClaimOpRename = "rename"
+	// ClaimOpSet sets the claim to Value. For groups, Value is added to the
+	// groups.
+	ClaimOpSet = "set"
+	// ClaimOpReplace replaces the matches of Pattern in the claim, or in
+	// each group, with Replacement. Replacement may refer to submatches,
+	// like regexp.Regexp.ReplaceAllString.
+	ClaimOpReplace = "replace"
+	// ClaimOpStripPrefix removes Prefix from the claim, or from each group.
+	ClaimOpStripPrefix = "stripPrefix"
+	// ClaimOpFilter keeps only the groups matching Pattern.
+	ClaimOpFilter = "filter"
+)
+
+// Claims transforms can change.
+const (
+	claimUsername          = "username"
+	claimPreferredUsername = "preferred_username"
+	claimEmail             = "email"
+	claimGroups            = "groups"
+)
+
+// ClaimTransform is a step of the pipeline applied to the identities
+// returned by connectors, before they're stored and tokens are issued for
+// them. Transforms run in order, each on the result of the previous one.
+//
+// Identities are transformed whenever dex gets them from a connector: on
+// login, on password grants, on token exchanges and when refreshes or group
+// syncs contact the connector. Refreshes that don't contact the connector
+// reuse the stored, already transformed claims.
+type ClaimTransform struct {
+	// IDs of the connectors whose identities are transformed. Empty matches
+	// all connectors.
+	Connectors []string
+
+	// One of the ClaimOp constants.
+	Op string
+	// The claim to transform: "username", "preferred_username", "email" or
+	// "groups".
+	Claim string
+
+	// Parameters of the operation.
+	From        string
+	Value       string
+	Pattern     string
+	Replacement string
+	Prefix      string
+}
+
+type claimTransform struct {
+	ClaimTransform
+	pattern *regexp.Regexp
+}
+
+// newClaimTransforms validates the transforms and compiles their patterns.
+func newClaimTransforms(transforms []ClaimTransform) ([]claimTransform, error) {
+	compiled := make([]claimTransform, 0, len(transforms))
+	for i, t := range transforms {
+		c := claimTransform{ClaimTransform: t}
+		switch t.Claim {
+		case claimUsername, claimPreferredUsername, claimEmail, claimGroups:
+		default:
+			return nil, fmt.Errorf("claim transform %d: unsupported claim %q", i, t.Claim)
+		}
+		isGroups := t.Claim == claimGroups
+		var err error
+		switch t.Op {
+		case ClaimOpRename:
+			switch {
+			case isGroups:
+				err = fmt.Errorf("groups can't be renamed")
+			case t.From == t.Claim:
+				err = fmt.Errorf("claim %q can't be renamed to itself", t.Claim)
+			case t.From != claimUsername && t.From != claimPreferredUsername && t.From != claimEmail:
+				err = fmt.Errorf("unsupported claim %q to rename", t.From)
+			}
+		case ClaimOpSet:
+			if isGroups && t.Value == "" {
+				err = fmt.Errorf("empty group can't be added")
+			}
+		case ClaimOpReplace, ClaimOpFilter:
+			if t.Op == ClaimOpFilter && !isGroups {
+				err = fmt.Errorf("only groups can be filtered")
+				break
+			}
+			if c.pattern, err = regexp.Compile(t.Pattern); err != nil {
+				err = fmt.Errorf("invalid pattern %q: %w", t.Pattern, err)
+			}
+		case ClaimOpStripPrefix:
+			if t.Prefix == "" {
+				err = fmt.Errorf("no prefix to strip")
+			}
+		default:
+			err = fmt.Errorf("unsupported operation %q", t.Op)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("claim transform %d: %w", i, err)
+		}
+		compiled = append(compiled, c)
+	}
+	return compiled, nil
+}
+
+// stringClaim returns the field of the identity holding a string claim.
+func stringClaim(ident *connector.Identity, claim string) *string {
+	switch claim {
+	case claimUsername:
+		return &ident.Username
+	case claimPreferredUsername:
+		return &ident.PreferredUsername
+	case claimEmail:
+		return &ident.Email
+	}
+	return nil
+}
+
+func (t claimTransform) apply(ident *connector.Identity) {
+	if t.Claim == claimGroups {
+		var groups []string
+		for _, g := range ident.Groups {
+			switch t.Op {
+			case ClaimOpReplace:
+				g = t.pattern.ReplaceAllString(g, t.Replacement)
+			case ClaimOpStripPrefix:
+				g = strings.TrimPrefix(g, t.Prefix)
+			case ClaimOpFilter:
+				if !t.pattern.MatchString(g) {
+					continue
+				}
+			}
+			if g != "" && !contains(groups, g) {
+				groups = append(groups, g)
+			}
+		}
+		if t.Op == ClaimOpSet && !contains(groups, t.Value) {
+			groups = append(groups, t.Value)
+		}
+		ident.Groups = groups
+		return
+	}
+
+	value := stringClaim(ident, t.Claim)
+	switch t.Op {
+	case ClaimOpRename:
+		from := stringClaim(ident, t.From)
+		*value, *from = *from, ""
+	case ClaimOpSet:
+		*value = t.Value
+	case ClaimOpReplace:
+		*value = t.pattern.ReplaceAllString(*value, t.Replacement)
+	case ClaimOpStripPrefix:
+		*value = strings.TrimPrefix(*value, t.Prefix)
+	}
+}
+
+// transformIdentity applies the claim transforms matching the connector to
+// an identity returned by it.
+func (s *Server) transformIdentity(connID string, ident connector.Identity) connector.Identity {
+	for _, t := range s.claimTransforms {
+		if len(t.Connectors) == 0 || contains(t.Connectors, connID) {
+			t.apply(&ident)
+		}
+	}
+	return ident
+}
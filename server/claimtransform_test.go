@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dexidp/dex/connector"
+	"github.com/dexidp/dex/storage"
+)
+
+func TestNewClaimTransforms(t *testing.T) {
+	for name, tc := range map[string]struct {
+		transform ClaimTransform
+		valid     bool
+	}{
+		"rename":              {ClaimTransform{Op: ClaimOpRename, Claim: "preferred_username", From: "email"}, true},
+		"rename groups":       {ClaimTransform{Op: ClaimOpRename, Claim: "groups", From: "email"}, false},
+		"rename to itself":    {ClaimTransform{Op: ClaimOpRename, Claim: "email", From: "email"}, false},
+		"add group":           {ClaimTransform{Op: ClaimOpSet, Claim: "groups", Value: "employees"}, true},
+		"add empty group":     {ClaimTransform{Op: ClaimOpSet, Claim: "groups"}, false},
+		"replace":             {ClaimTransform{Op: ClaimOpReplace, Claim: "email", Pattern: "@corp$"}, true},
+		"invalid pattern":     {ClaimTransform{Op: ClaimOpReplace, Claim: "email", Pattern: "("}, false},
+		"filter email":        {ClaimTransform{Op: ClaimOpFilter, Claim: "email", Pattern: "."}, false},
+		"strip empty prefix":  {ClaimTransform{Op: ClaimOpStripPrefix, Claim: "groups"}, false},
+		"unsupported claim":   {ClaimTransform{Op: ClaimOpSet, Claim: "sub", Value: "root"}, false},
+		"unsupported op":      {ClaimTransform{Op: "delete", Claim: "email"}, false},
+		"filter groups":       {ClaimTransform{Op: ClaimOpFilter, Claim: "groups", Pattern: "^team-"}, true},
+		"strip groups prefix": {ClaimTransform{Op: ClaimOpStripPrefix, Claim: "groups", Prefix: "org:"}, true},
+	} {
+		if _, err := newClaimTransforms([]ClaimTransform{tc.transform}); (err == nil) != tc.valid {
+			t.Errorf("%s: expected valid=%v, got %v", name, tc.valid, err)
+		}
+	}
+}
+
+func TestTransformIdentity(t *testing.T) {
+	transforms, err := newClaimTransforms([]ClaimTransform{
+		{Op: ClaimOpRename, Claim: claimPreferredUsername, From: claimUsername},
+		{Op: ClaimOpReplace, Claim: claimEmail, Pattern: `@corp\.example\.com$`, Replacement: "@example.com"},
+		{Op: ClaimOpStripPrefix, Claim: claimGroups, Prefix: "org:"},
+		{Op: ClaimOpFilter, Claim: claimGroups, Pattern: "^team-"},
+		{Op: ClaimOpSet, Claim: claimGroups, Value: "employees", Connectors: []string{"ldap"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{claimTransforms: transforms}
+
+	ident := connector.Identity{
+		UserID:   "1",
+		Username: "jane",
+		Email:    "jane.doe@corp.example.com",
+		Groups:   []string{"org:team-a", "team-a", "org:admins", "team-b"},
+	}
+	got := s.transformIdentity("github", ident)
+	want := connector.Identity{
+		UserID:            "1",
+		PreferredUsername: "jane",
+		Email:             "jane.doe@example.com",
+		Groups:            []string{"team-a", "team-b"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	if ident.Groups[0] != "org:team-a" {
+		t.Errorf("expected the groups of the original identity not to change, got %v", ident.Groups)
+	}
+	if got := s.transformIdentity("ldap", ident); !contains(got.Groups, "employees") {
+		t.Errorf("expected group to be added for matching connector, got %v", got.Groups)
+	}
+}
+
+func TestClaimTransformsOnLogin(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.ClaimTransforms = []ClaimTransform{
+			{Op: ClaimOpSet, Claim: claimGroups, Value: "dex-users"},
+			{Op: ClaimOpReplace, Claim: claimEmail, Pattern: "kilgore.trout$", Replacement: "example.com"},
+		}
+	})
+	defer httpServer.Close()
+
+	authReq := storage.AuthRequest{
+		ID:          storage.NewID(),
+		ClientID:    "app",
+		ConnectorID: "mock",
+		Expiry:      time.Now().Add(time.Minute),
+	}
+	if err := s.storage.CreateAuthRequest(ctx, authReq); err != nil {
+		t.Fatalf("create auth request: %v", err)
+	}
+	conn, err := s.getConnector(ctx, "mock")
+	if err != nil {
+		t.Fatalf("get connector: %v", err)
+	}
+	ident := connector.Identity{UserID: "1", Email: "jane@kilgore.trout", Groups: []string{"authors"}}
+	if _, err := s.finalizeLogin(ctx, ident, authReq, conn.Connector); err != nil {
+		t.Fatalf("finalize login: %v", err)
+	}
+
+	authReq, err = s.storage.GetAuthRequest(ctx, authReq.ID)
+	if err != nil {
+		t.Fatalf("get auth request: %v", err)
+	}
+	if authReq.Claims.Email != "jane@example.com" {
+		t.Errorf("expected transformed email, got %q", authReq.Claims.Email)
+	}
+	if want := []string{"authors", "dex-users"}; !reflect.DeepEqual(authReq.Claims.Groups, want) {
+		t.Errorf("expected groups %v, got %v", want, authReq.Claims.Groups)
+	}
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// reservedClaims can't be set by the claims of a client, because they're
+// set by dex or defined by the JWT and OpenID Connect specs.
+var reservedClaims = map[string]bool{
+	"iss":                true,
+	"sub":                true,
+	"aud":                true,
+	"exp":                true,
+	"nbf":                true,
+	"iat":                true,
+	"jti":                true,
+	"azp":                true,
+	"nonce":              true,
+	"auth_time":          true,
+	"acr":                true,
+	"amr":                true,
+	"at_hash":            true,
+	"c_hash":             true,
+	"email":              true,
+	"email_verified":     true,
+	"groups":             true,
+	"name":               true,
+	"preferred_username": true,
+	"federated_claims":   true,
+	"act":                true,
+}
+
+// ValidateClientClaims checks that the claims of a client don't replace
+// claims set by dex.
+func ValidateClientClaims(claims map[string]interface{}) error {
+	var reserved []string
+	for name := range claims {
+		if reservedClaims[name] || name == "" {
+			reserved = append(reserved, fmt.Sprintf("%q", name))
+		}
+	}
+	if len(reserved) > 0 {
+		sort.Strings(reserved)
+		return fmt.Errorf("reserved claim(s) %v can't be set", reserved)
+	}
+	return nil
+}
+
+// addClientClaims adds the claims of a client to the marshaled token
+// claims. Reserved claims are skipped, so clients stored before they were
+// validated can't replace claims set by dex.
+func addClientClaims(payload []byte, claims map[string]interface{}) ([]byte, error) {
+	if len(claims) == 0 {
+		return payload, nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return nil, err
+	}
+	for name, v := range claims {
+		if reservedClaims[name] || name == "" {
+			continue
+		}
+		fields[name] = v
+	}
+	return json.Marshal(fields)
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"gopkg.in/square/go-jose.v2"
+
+	"github.com/dexidp/dex/storage"
+)
+
+func TestValidateClientClaims(t *testing.T) {
+	if err := ValidateClientClaims(map[string]interface{}{"tier": "internal", "tenant_id": "acme"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := ValidateClientClaims(map[string]interface{}{"tier": "internal", "sub": "admin", "email": "a@b"}); err == nil {
+		t.Errorf("expected error for reserved claims")
+	}
+	if err := ValidateClientClaims(map[string]interface{}{"act": map[string]interface{}{"sub": "admin"}}); err == nil {
+		t.Errorf("expected error for the act claim")
+	}
+}
+
+func TestClientClaims(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, nil)
+	defer httpServer.Close()
+
+	if err := s.storage.CreateClient(ctx, storage.Client{
+		ID: "app",
+		Claims: map[string]interface{}{
+			"tier":   "internal",
+			"tenant": map[string]interface{}{"id": "acme"},
+			// Stored before it was validated, must not replace the email.
+			"email": "admin@example.com",
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	idToken, _, err := s.newIDToken(ctx, "app", storage.Claims{UserID: "1", Email: "jane@example.com"}, []string{"openid", "email"}, "", "", "mock")
+	if err != nil {
+		t.Fatalf("failed to create id token: %v", err)
+	}
+	jws, err := jose.ParseSigned(idToken)
+	if err != nil {
+		t.Fatalf("failed to parse id token: %v", err)
+	}
+	var claims struct {
+		Tier   string            `json:"tier"`
+		Tenant map[string]string `json:"tenant"`
+		Email  string            `json:"email"`
+		Aud    string            `json:"aud"`
+	}
+	if err := json.Unmarshal(jws.UnsafePayloadWithoutVerification(), &claims); err != nil {
+		t.Fatal(err)
+	}
+	if claims.Tier != "internal" || claims.Tenant["id"] != "acme" {
+		t.Errorf("expected client claims in token, got %+v", claims)
+	}
+	if claims.Email != "jane@example.com" || claims.Aud != "app" {
+		t.Errorf("client claims replaced standard claims: %+v", claims)
+	}
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dexidp/dex/pkg/audit"
+	"github.com/dexidp/dex/storage"
+)
+
+// getClient returns a client that can authenticate. Expired clients are
+// reported as not found until they're garbage collected.
+func (s *Server) getClient(ctx context.Context, id string) (storage.Client, error) {
+	client, err := s.storage.GetClient(ctx, id)
+	if err != nil {
+		return client, err
+	}
+	if !client.Expiry.IsZero() && s.expired(client.Expiry) {
+		return storage.Client{}, storage.ErrNotFound
+	}
+	return client, nil
+}
+
+// collectExpiredClients deletes the clients which expired before now, along
+// with their refresh tokens and API keys, and emits a client_expired audit
+// event for each. It returns the number of deleted clients.
+func (s *Server) collectExpiredClients(ctx context.Context, now time.Time) (int, error) {
+	clients, err := s.storage.ListClients(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("list clients: %w", err)
+	}
+	var expired []storage.Client
+	for _, c := range clients {
+		if !c.Expiry.IsZero() && now.After(c.Expiry) {
+			expired = append(expired, c)
+		}
+	}
+	if len(expired) == 0 {
+		return 0, nil
+	}
+
+	refreshTokens, err := s.storage.ListRefreshTokens(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("list refresh tokens: %w", err)
+	}
+	apiKeys, err := s.storage.ListAPIKeys(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("list API keys: %w", err)
+	}
+
+	var n int
+	for _, c := range expired {
+		// Delete the client first, so it can't obtain new tokens while the
+		// existing ones are deleted.
+		if err := s.storage.DeleteClient(ctx, c.ID); err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				continue
+			}
+			return n, fmt.Errorf("delete client %s: %w", c.ID, err)
+		}
+		n++
+
+		var revoked, deletedKeys int
+		for _, r := range refreshTokens {
+			if r.ClientID != c.ID {
+				continue
+			}
+			if err := s.revokeRefreshFamily(ctx, r.Claims.UserID, r.ConnectorID, c.ID, r.ID, revocationClientExpired); err != nil {
+				s.logger.Errorf("failed to revoke refresh token of expired client %s: %v", c.ID, err)
+				continue
+			}
+			revoked++
+		}
+		for _, k := range apiKeys {
+			if k.ClientID != c.ID {
+				continue
+			}
+			if err := s.storage.DeleteAPIKey(ctx, k.ID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+				s.logger.Errorf("failed to delete API key of expired client %s: %v", c.ID, err)
+				continue
+			}
+			deletedKeys++
+		}
+
+		s.emitAudit(ctx, audit.Event{
+			Type:     audit.EventClientExpired,
+			Severity: audit.SeverityInfo,
+			ClientID: c.ID,
+			Message:  fmt.Sprintf("client expired at %s: deleted %d refresh tokens, %d API keys", c.Expiry.UTC().Format(time.RFC3339), revoked, deletedKeys),
+			Revoked:  revoked > 0,
+		})
+	}
+	return n, nil
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dexidp/dex/pkg/audit"
+	"github.com/dexidp/dex/storage"
+)
+
+func TestExpiredClient(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sink := new(recordingSink)
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.PasswordConnector = "password"
+		c.AuditSink = sink
+	})
+	defer httpServer.Close()
+
+	conn := storage.Connector{
+		ID:     "password",
+		Type:   "mockPassword",
+		Name:   "Password",
+		Config: []byte(`{"username": "jane", "password": "hunter2"}`),
+	}
+	if err := s.storage.CreateConnector(ctx, conn); err != nil {
+		t.Fatalf("create connector: %v", err)
+	}
+	client := storage.Client{
+		ID:           "preview",
+		Secret:       "barfoo",
+		RedirectURIs: []string{"https://example.com/callback"},
+		TrustedPeers: []string{"other"},
+		Expiry:       time.Now().Add(-time.Minute),
+	}
+	if err := s.storage.CreateClient(ctx, client); err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+	other := storage.Client{ID: "other", Secret: "foobar", Expiry: time.Now().Add(time.Hour)}
+	if err := s.storage.CreateClient(ctx, other); err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, tokenRequest(client, "10.0.0.1:1234", url.Values{
+		"grant_type": {grantTypePassword},
+		"scope":      {"openid"},
+		"username":   {"jane"},
+		"password":   {"hunter2"},
+	}))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected expired client to be rejected, got %d: %s", rr.Code, rr.Body)
+	}
+
+	// Expired clients don't take part in cross-client trust.
+	if trusted, err := s.validateCrossClientTrust(ctx, other.ID, client.ID); err != nil || trusted {
+		t.Errorf("expected expired peer not to be trusted, got %t, %v", trusted, err)
+	}
+
+	refresh := storage.RefreshToken{
+		ID:          "refresh",
+		Token:       "token",
+		ClientID:    client.ID,
+		ConnectorID: conn.ID,
+		Claims:      storage.Claims{UserID: "jane"},
+		CreatedAt:   time.Now(),
+		LastUsed:    time.Now(),
+	}
+	if err := s.storage.CreateRefresh(ctx, refresh); err != nil {
+		t.Fatalf("create refresh token: %v", err)
+	}
+	key := storage.APIKey{ID: "key", Hash: "hash", ClientID: client.ID, CreatedAt: time.Now()}
+	if err := s.storage.CreateAPIKey(ctx, key); err != nil {
+		t.Fatalf("create API key: %v", err)
+	}
+
+	n, err := s.collectExpiredClients(ctx, time.Now())
+	if err != nil {
+		t.Fatalf("collect expired clients: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 client to be collected, got %d", n)
+	}
+	if _, err := s.storage.GetClient(ctx, client.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected expired client to be deleted, got %v", err)
+	}
+	if _, err := s.storage.GetClient(ctx, other.ID); err != nil {
+		t.Errorf("expected unexpired client to be kept, got %v", err)
+	}
+	if _, err := s.storage.GetRefresh(ctx, refresh.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected refresh token to be deleted, got %v", err)
+	}
+	if _, err := s.storage.GetAPIKey(ctx, key.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected API key to be deleted, got %v", err)
+	}
+
+	var expired []audit.Event
+	for _, e := range sink.events {
+		if e.Type == audit.EventClientExpired {
+			expired = append(expired, e)
+		}
+	}
+	if len(expired) != 1 || expired[0].ClientID != client.ID || !expired[0].Revoked {
+		t.Errorf("expected one %s event for %s, got %v", audit.EventClientExpired, client.ID, expired)
+	}
+}
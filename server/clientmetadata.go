@@ -0,0 +1,49 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/dexidp/dex/storage"
+)
+
+// Application types of OAuth 2.0 Dynamic Client Registration.
+const (
+	applicationTypeWeb    = "web"
+	applicationTypeNative = "native"
+)
+
+// ValidateClientMetadata checks the registration metadata of a client shown
+// to end users. The terms of service and policy URIs must be absolute http
+// or https URLs, so they can't run scripts when followed from the approval
+// screen. The back-channel logout URI must be an absolute http or https URL
+// without a fragment, as required by OpenID Connect Back-Channel Logout.
+func ValidateClientMetadata(c storage.Client) error {
+	switch c.ApplicationType {
+	case "", applicationTypeWeb, applicationTypeNative:
+	default:
+		return fmt.Errorf("invalid application type %q, expected %q or %q", c.ApplicationType, applicationTypeWeb, applicationTypeNative)
+	}
+	for name, uri := range map[string]string{"terms of service URI": c.TOSURI, "policy URI": c.PolicyURI} {
+		if uri == "" {
+			continue
+		}
+		u, err := url.Parse(uri)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid %s %q, expected an http or https URL", name, uri)
+		}
+	}
+	if uri := c.BackchannelLogoutURI; uri != "" {
+		u, err := url.Parse(uri)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Fragment != "" {
+			return fmt.Errorf("invalid back-channel logout URI %q, expected an http or https URL without a fragment", uri)
+		}
+	}
+	for _, contact := range c.Contacts {
+		if contact == "" {
+			return errors.New("empty contact")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/dexidp/dex/storage"
+)
+
+func TestValidateClientMetadata(t *testing.T) {
+	tests := []struct {
+		name    string
+		client  storage.Client
+		wantErr bool
+	}{
+		{"empty", storage.Client{}, false},
+		{"valid", storage.Client{
+			TOSURI:               "https://example.com/tos",
+			PolicyURI:            "http://example.com/privacy",
+			Contacts:             []string{"admin@example.com"},
+			ApplicationType:      "native",
+			BackchannelLogoutURI: "https://example.com/logout",
+		}, false},
+		{"unknown application type", storage.Client{ApplicationType: "desktop"}, true},
+		{"script URI", storage.Client{TOSURI: "javascript:alert(1)"}, true},
+		{"relative URI", storage.Client{PolicyURI: "/privacy"}, true},
+		{"logout URI with fragment", storage.Client{BackchannelLogoutURI: "https://example.com/logout#x"}, true},
+		{"empty contact", storage.Client{Contacts: []string{""}}, true},
+	}
+	for _, tc := range tests {
+		err := ValidateClientMetadata(tc.client)
+		if err != nil && !tc.wantErr {
+			t.Errorf("%s: %v", tc.name, err)
+		}
+		if err == nil && tc.wantErr {
+			t.Errorf("%s: expected error", tc.name)
+		}
+	}
+}
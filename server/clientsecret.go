@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"sync"
+
+	"github.com/dexidp/dex/pkg/secret"
+	"github.com/dexidp/dex/storage"
+)
+
+// verifyClientSecret compares the secret presented by a client with the
+// stored one. Stored secrets are hashes, or plaintext for clients created
+// before secret hashing was enabled.
+func verifyClientSecret(stored, presented string) (bool, error) {
+	if secret.IsHash(stored) {
+		return secret.Verify(stored, []byte(presented))
+	}
+	return subtle.ConstantTimeCompare([]byte(stored), []byte(presented)) == 1, nil
+}
+
+// upgradeClientSecret replaces a plaintext secret, or a hash made with
+// outdated parameters, by a hash from the configured hasher. It's called
+// once the client has authenticated with the secret, which migrates clients
+// to hashed secrets as they're used.
+func (s *Server) upgradeClientSecret(ctx context.Context, client storage.Client, presented string) {
+	if s.clientSecretHasher == nil || client.Public || client.Secret == "" {
+		return
+	}
+	if secret.IsHash(client.Secret) && !s.clientSecretHasher.NeedsRehash(client.Secret) {
+		return
+	}
+	// Don't hash the secrets of clients that can't be updated on every
+	// request, static clients for example.
+	if s.secretUpgrades.failed(client.ID, client.Secret) {
+		return
+	}
+	hash, err := s.clientSecretHasher.Hash([]byte(presented))
+	if err != nil {
+		s.logger.Errorf("failed to hash secret of client %s: %v", client.ID, err)
+		return
+	}
+	err = s.storage.UpdateClient(ctx, client.ID, func(old storage.Client) (storage.Client, error) {
+		// Leave the secret alone if it was rotated in the meantime.
+		if old.Secret == client.Secret {
+			old.Secret = hash
+		}
+		return old, nil
+	})
+	if err != nil {
+		// Static clients can't be updated, their secrets stay as configured.
+		s.logger.Debugf("failed to store hashed secret of client %s: %v", client.ID, err)
+		s.secretUpgrades.add(client.ID, client.Secret)
+	}
+}
+
+// failedUpgrades remembers the stored values that couldn't be replaced by a
+// new hash, so they aren't hashed again each time they're used. A value
+// that changes, for instance when it's reconfigured, is upgraded again.
+type failedUpgrades struct {
+	mu     sync.Mutex
+	values map[string]string
+}
+
+func newFailedUpgrades() *failedUpgrades {
+	return &failedUpgrades{values: make(map[string]string)}
+}
+
+// failed reports whether upgrading the stored value for key failed before.
+func (f *failedUpgrades) failed(key, value string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	v, ok := f.values[key]
+	return ok && v == value
+}
+
+func (f *failedUpgrades) add(key, value string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.values[key] = value
+}
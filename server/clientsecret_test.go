@@ -0,0 +1,193 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dexidp/dex/api/v2"
+	"github.com/dexidp/dex/pkg/secret"
+	"github.com/dexidp/dex/storage"
+	"github.com/dexidp/dex/storage/memory"
+)
+
+func TestHashedClientSecrets(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hasher, err := secret.NewBcrypt(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.ClientSecretHasher = hasher
+	})
+	defer httpServer.Close()
+
+	hash, err := hasher.Hash([]byte("hashedsecret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clients := []storage.Client{
+		{ID: "hashed", Secret: hash, RedirectURIs: []string{"https://example.com/callback"}},
+		{ID: "plaintext", Secret: "plaintextsecret", RedirectURIs: []string{"https://example.com/callback"}},
+	}
+	for _, client := range clients {
+		if err := s.storage.CreateClient(ctx, client); err != nil {
+			t.Fatalf("failed to create client: %v", err)
+		}
+	}
+
+	// Invalid grants get past client authentication, which is all that's
+	// tested here.
+	authenticate := func(clientID, clientSecret string) int {
+		code := storage.AuthCode{
+			ID:          storage.NewID(),
+			ClientID:    clientID,
+			RedirectURI: "https://example.com/callback",
+			Scopes:      []string{"openid"},
+			ConnectorID: "mock",
+			Claims:      storage.Claims{UserID: "1", Email: "jane.doe@example.com"},
+			Expiry:      time.Now().Add(time.Minute),
+		}
+		if err := s.storage.CreateAuthCode(ctx, code); err != nil {
+			t.Fatalf("failed to create auth code: %v", err)
+		}
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, tokenRequest(storage.Client{ID: clientID, Secret: clientSecret}, "10.0.0.1:1234", url.Values{
+			"grant_type":   {grantTypeAuthorizationCode},
+			"code":         {code.ID},
+			"redirect_uri": {code.RedirectURI},
+		}))
+		return rr.Code
+	}
+
+	tests := []struct {
+		client, secret string
+		want           int
+	}{
+		{"hashed", "hashedsecret", http.StatusOK},
+		{"hashed", hash, http.StatusUnauthorized},
+		{"hashed", "wrong", http.StatusUnauthorized},
+		{"plaintext", "wrong", http.StatusUnauthorized},
+		{"plaintext", "plaintextsecret", http.StatusOK},
+		// Authenticating migrated the plaintext secret to a hash.
+		{"plaintext", "plaintextsecret", http.StatusOK},
+	}
+	for i, tc := range tests {
+		if got := authenticate(tc.client, tc.secret); got != tc.want {
+			t.Errorf("%d: expected status %d authenticating %s, got %d", i, tc.want, tc.client, got)
+		}
+	}
+
+	client, err := s.storage.GetClient(ctx, "plaintext")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !secret.IsHash(client.Secret) {
+		t.Errorf("expected plaintext secret to be replaced by a hash, got %q", client.Secret)
+	}
+}
+
+func TestAPIClientSecretHashes(t *testing.T) {
+	ctx := context.Background()
+	hasher, err := secret.NewBcrypt(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := memory.New(logger)
+	a := NewAPI(s, logger, nil, hasher, nil, 0)
+
+	// Plaintext secrets are hashed before they're stored.
+	resp, err := a.CreateClient(ctx, &api.CreateClientReq{Client: &api.Client{Id: "plaintext", Secret: "s3cret"}})
+	if err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+	if resp.Client.Secret != "s3cret" {
+		t.Errorf("expected the plaintext secret in the response, got %q", resp.Client.Secret)
+	}
+	stored, err := s.GetClient(ctx, "plaintext")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := secret.Verify(stored.Secret, []byte("s3cret")); err != nil || !ok {
+		t.Errorf("expected stored secret to be a hash of the secret, got %q", stored.Secret)
+	}
+
+	// Pre-hashed secrets are stored as is.
+	hash, _ := hasher.Hash([]byte("other"))
+	if _, err := a.CreateClient(ctx, &api.CreateClientReq{Client: &api.Client{Id: "prehashed", SecretHash: hash}}); err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+	if stored, _ := s.GetClient(ctx, "prehashed"); stored.Secret != hash {
+		t.Errorf("expected the supplied hash to be stored, got %q", stored.Secret)
+	}
+
+	weak, err := secret.NewArgon2id(secret.Argon2idParams{Memory: 8, Iterations: 1, Parallelism: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	weakHash, _ := weak.Hash([]byte("other"))
+
+	invalid := []*api.Client{
+		{Id: "both", Secret: "s3cret", SecretHash: hash},
+		{Id: "nothash", SecretHash: "s3cret"},
+		{Id: "weakhash", SecretHash: weakHash},
+	}
+	for _, c := range invalid {
+		if _, err := a.CreateClient(ctx, &api.CreateClientReq{Client: c}); err == nil {
+			t.Errorf("%s: expected create client to fail", c.Id)
+		}
+	}
+
+	newHash, _ := hasher.Hash([]byte("rotated"))
+	if _, err := a.UpdateClient(ctx, &api.UpdateClientReq{Id: "plaintext", SecretHash: newHash}); err != nil {
+		t.Fatalf("update client: %v", err)
+	}
+	if stored, _ := s.GetClient(ctx, "plaintext"); stored.Secret != newHash {
+		t.Errorf("expected update to replace the secret hash, got %q", stored.Secret)
+	}
+}
+
+type countingHasher struct {
+	secret.Hasher
+	hashed int
+}
+
+func (h *countingHasher) Hash(s []byte) (string, error) {
+	h.hashed++
+	return h.Hasher.Hash(s)
+}
+
+func TestUpgradeStaticClientSecret(t *testing.T) {
+	ctx := context.Background()
+	bcrypt, err := secret.NewBcrypt(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hasher := &countingHasher{Hasher: bcrypt}
+	client := storage.Client{ID: "static", Secret: "plaintextsecret"}
+	s := &Server{
+		storage:            storage.WithStaticClients(memory.New(logger), []storage.Client{client}),
+		clientSecretHasher: hasher,
+		secretUpgrades:     newFailedUpgrades(),
+		logger:             logger,
+	}
+
+	for i := 0; i < 3; i++ {
+		s.upgradeClientSecret(ctx, client, "plaintextsecret")
+	}
+	if hasher.hashed != 1 {
+		t.Errorf("expected the static secret to be hashed once, got %d", hasher.hashed)
+	}
+
+	// A reconfigured secret is tried again.
+	client.Secret = "othersecret"
+	s.upgradeClientSecret(ctx, client, "othersecret")
+	if hasher.hashed != 2 {
+		t.Errorf("expected the changed secret to be hashed, got %d hashes", hasher.hashed)
+	}
+}
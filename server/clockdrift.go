@@ -0,0 +1,70 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dexidp/dex/pkg/audit"
+	"github.com/dexidp/dex/pkg/ntp"
+)
+
+// ClockDriftCheck periodically compares the local clock against an NTP
+// server. Drift silently breaks expiry checks and the validation of issued
+// tokens by clients, so it's reported as a warning audit event.
+type ClockDriftCheck struct {
+	// Address of the NTP server, with an optional port. The check is
+	// disabled if empty.
+	NTPServer string
+
+	// Drift beyond which a warning is reported. Defaults to 30 seconds.
+	Threshold time.Duration
+
+	// How often to check. Defaults to 1 hour.
+	Interval time.Duration
+}
+
+// startClockDriftCheck checks the clock once immediately, then at every
+// interval in a new goroutine until the context is canceled.
+func (s *Server) startClockDriftCheck(ctx context.Context, c ClockDriftCheck) {
+	if c.NTPServer == "" {
+		return
+	}
+	threshold := value(c.Threshold, 30*time.Second)
+	interval := value(c.Interval, time.Hour)
+
+	go func() {
+		for {
+			s.checkClockDrift(ctx, c.NTPServer, threshold, ntp.Offset)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+		}
+	}()
+}
+
+func (s *Server) checkClockDrift(ctx context.Context, server string, threshold time.Duration, offsetFunc func(context.Context, string) (time.Duration, error)) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	offset, err := offsetFunc(ctx, server)
+	if err != nil {
+		s.logger.Errorf("failed to check clock drift against %s: %v", server, err)
+		return
+	}
+
+	direction, drift := "behind", offset
+	if drift < 0 {
+		direction, drift = "ahead of", -drift
+	}
+	if drift <= threshold {
+		return
+	}
+	s.emitAudit(ctx, audit.Event{
+		Type:     audit.EventClockDrift,
+		Severity: audit.SeverityWarning,
+		Message:  fmt.Sprintf("local clock is %s %s %s", drift, direction, server),
+	})
+}
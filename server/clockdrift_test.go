@@ -0,0 +1,46 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dexidp/dex/pkg/audit"
+)
+
+func TestCheckClockDrift(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sink := new(recordingSink)
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.AuditSink = sink
+	})
+	defer httpServer.Close()
+
+	tests := []struct {
+		offset    time.Duration
+		err       error
+		wantEvent bool
+	}{
+		{offset: 10 * time.Second},
+		{offset: -10 * time.Second},
+		{offset: 2 * time.Minute, wantEvent: true},
+		{offset: -2 * time.Minute, wantEvent: true},
+		{err: errors.New("timeout")},
+	}
+	for _, tc := range tests {
+		sink.events = nil
+		s.checkClockDrift(ctx, "ntp.example.com", 30*time.Second, func(context.Context, string) (time.Duration, error) {
+			return tc.offset, tc.err
+		})
+		if got := len(sink.events) == 1; got != tc.wantEvent {
+			t.Errorf("offset %s: wanted event=%t, got %+v", tc.offset, tc.wantEvent, sink.events)
+			continue
+		}
+		if tc.wantEvent && (sink.events[0].Type != audit.EventClockDrift || sink.events[0].Severity != audit.SeverityWarning) {
+			t.Errorf("offset %s: unexpected event %+v", tc.offset, sink.events[0])
+		}
+	}
+}
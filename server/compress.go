@@ -0,0 +1,139 @@
+package server
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Responses shorter than this fit in a single packet anyway and aren't
+// worth compressing.
+const minCompressSize = 1024
+
+// Content types compressed by compress. Images are left alone, they're
+// already compressed.
+var compressibleTypes = []string{
+	"text/html",
+	"text/css",
+	"text/plain",
+	"application/javascript",
+	"application/json",
+	"image/svg+xml",
+}
+
+var gzipWriters = sync.Pool{
+	New: func() interface{} { return gzip.NewWriter(nil) },
+}
+
+// compress gzips responses of compressible content types for clients that
+// accept it.
+func compress(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(r) || r.Method == http.MethodHead || r.Header.Get("Range") != "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w}
+		defer cw.close()
+		h.ServeHTTP(cw, r)
+	})
+}
+
+// acceptsGzip reports whether the Accept-Encoding header of r lists gzip
+// with a non-zero quality.
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		parts := strings.Split(enc, ";")
+		if strings.TrimSpace(parts[0]) != "gzip" {
+			continue
+		}
+		for _, param := range parts[1:] {
+			if q := strings.TrimSpace(param); strings.HasPrefix(q, "q=") {
+				if v, err := strconv.ParseFloat(q[2:], 64); err == nil && v == 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// compressWriter decides whether to compress when the handler writes the
+// header, based on the status, content type and length of the response.
+type compressWriter struct {
+	http.ResponseWriter
+
+	wroteHeader bool
+	gz          *gzip.Writer
+}
+
+func (c *compressWriter) WriteHeader(code int) {
+	if c.wroteHeader {
+		return
+	}
+	c.wroteHeader = true
+
+	h := c.Header()
+	if shouldCompress(code, h) {
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", "gzip")
+		c.gz = gzipWriters.Get().(*gzip.Writer)
+		c.gz.Reset(c.ResponseWriter)
+	}
+	c.ResponseWriter.WriteHeader(code)
+}
+
+func shouldCompress(code int, h http.Header) bool {
+	if code < 200 || code == http.StatusNoContent || code == http.StatusNotModified || code == http.StatusPartialContent {
+		return false
+	}
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	if n, err := strconv.Atoi(h.Get("Content-Length")); err == nil && n < minCompressSize {
+		return false
+	}
+	contentType := h.Get("Content-Type")
+	for _, t := range compressibleTypes {
+		if strings.HasPrefix(contentType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *compressWriter) Write(b []byte) (int, error) {
+	if !c.wroteHeader {
+		if c.Header().Get("Content-Type") == "" {
+			c.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		c.WriteHeader(http.StatusOK)
+	}
+	if c.gz != nil {
+		return c.gz.Write(b)
+	}
+	return c.ResponseWriter.Write(b)
+}
+
+func (c *compressWriter) Flush() {
+	if c.gz != nil {
+		c.gz.Flush()
+	}
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (c *compressWriter) close() {
+	if c.gz == nil {
+		return
+	}
+	c.gz.Close()
+	c.gz.Reset(nil)
+	gzipWriters.Put(c.gz)
+	c.gz = nil
+}
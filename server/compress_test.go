@@ -0,0 +1,100 @@
+package server
+
+import (
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+	body := strings.Repeat(`{"hello":"world"}`, 100)
+	h := compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", r.URL.Query().Get("type"))
+		w.Write([]byte(body))
+	}))
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		contentType    string
+		wantGzip       bool
+	}{
+		{"json", "gzip, deflate", "application/json", true},
+		{"html", "gzip", "text/html; charset=utf-8", true},
+		{"image", "gzip", "image/png", false},
+		{"no accept encoding", "", "application/json", false},
+		{"gzip refused", "gzip;q=0", "application/json", false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/?type="+url.QueryEscape(tc.contentType), nil)
+			if tc.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			gotGzip := rr.Header().Get("Content-Encoding") == "gzip"
+			if gotGzip != tc.wantGzip {
+				t.Fatalf("expected gzip %t, got Content-Encoding %q", tc.wantGzip, rr.Header().Get("Content-Encoding"))
+			}
+			got := rr.Body.String()
+			if gotGzip {
+				zr, err := gzip.NewReader(rr.Body)
+				if err != nil {
+					t.Fatalf("gzip reader: %v", err)
+				}
+				data, err := ioutil.ReadAll(zr)
+				if err != nil {
+					t.Fatalf("decompress: %v", err)
+				}
+				got = string(data)
+			}
+			if got != body {
+				t.Errorf("body mismatch, got %d bytes", len(got))
+			}
+		})
+	}
+}
+
+func TestStaticAssetCaching(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, nil)
+	defer httpServer.Close()
+
+	// The login page references assets by their content hash.
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/auth?client_id=test&redirect_uri=http://localhost&response_type=code&scope=openid", nil))
+	i := strings.Index(rr.Body.String(), "static/main.css?v=")
+	if i < 0 {
+		t.Fatalf("expected versioned stylesheet URL in %q", rr.Body.String())
+	}
+	assetURL := rr.Body.String()[i:]
+	assetURL = "/" + assetURL[:strings.IndexByte(assetURL, '"')]
+
+	tests := []struct {
+		path         string
+		cacheControl string
+	}{
+		{assetURL, "public, max-age=31536000, immutable"},
+		{"/static/main.css?v=stale", "no-cache"},
+		{"/static/main.css", "no-cache"},
+	}
+	for _, tc := range tests {
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		if rr.Code != http.StatusOK {
+			t.Errorf("%s: expected status 200, got %d", tc.path, rr.Code)
+		}
+		if got := rr.Header().Get("Cache-Control"); got != tc.cacheControl {
+			t.Errorf("%s: expected Cache-Control %q, got %q", tc.path, tc.cacheControl, got)
+		}
+	}
+}
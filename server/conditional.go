@@ -0,0 +1,46 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// contentETag returns a strong ETag derived from the content, so every
+// replica serving the same document returns the same ETag.
+func contentETag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// serveConditionalJSON writes the JSON document with ETag and Last-Modified
+// headers, and answers If-None-Match and If-Modified-Since requests for an
+// unchanged document with 304 Not Modified.
+func serveConditionalJSON(w http.ResponseWriter, r *http.Request, data []byte, modified time.Time) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", contentETag(data))
+	http.ServeContent(w, r, "", modified, bytes.NewReader(data))
+}
+
+// lastModified remembers when a document served by this instance last
+// changed.
+type lastModified struct {
+	mu       sync.Mutex
+	etag     string
+	modified time.Time
+}
+
+// observe returns the modification time of the document with the given
+// ETag, which is now if it differs from the previously observed one.
+func (l *lastModified) observe(etag string, now time.Time) time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if etag != l.etag {
+		l.etag = etag
+		l.modified = now
+	}
+	return l.modified
+}
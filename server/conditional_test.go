@@ -0,0 +1,51 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestConditionalRequests(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, nil)
+	defer httpServer.Close()
+
+	for _, path := range []string{"/keys", "/.well-known/openid-configuration"} {
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", path, rr.Code)
+		}
+		etag, modified := rr.Header().Get("ETag"), rr.Header().Get("Last-Modified")
+		if etag == "" || modified == "" {
+			t.Fatalf("%s: expected ETag and Last-Modified headers, got %q and %q", path, etag, modified)
+		}
+
+		tests := []struct {
+			name   string
+			header string
+			value  string
+			want   int
+		}{
+			{"matching etag", "If-None-Match", etag, http.StatusNotModified},
+			{"other etag", "If-None-Match", `"other"`, http.StatusOK},
+			{"not modified since", "If-Modified-Since", modified, http.StatusNotModified},
+		}
+		for _, tc := range tests {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set(tc.header, tc.value)
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, req)
+			if rr.Code != tc.want {
+				t.Errorf("%s %s: expected status %d, got %d", path, tc.name, tc.want, rr.Code)
+			}
+			if tc.want == http.StatusNotModified && rr.Body.Len() != 0 {
+				t.Errorf("%s %s: expected empty body, got %q", path, tc.name, rr.Body.String())
+			}
+		}
+	}
+}
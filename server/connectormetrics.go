@@ -0,0 +1,69 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Connector operations measured by connectorMetrics.
+const (
+	connectorOpLogin    = "login"
+	connectorOpCallback = "callback"
+	connectorOpRefresh  = "refresh"
+	// connectorOpSendLink sends a login link to a user.
+	connectorOpSendLink = "send_link"
+	// connectorOpTokenIdentity validates an upstream token being exchanged.
+	connectorOpTokenIdentity = "token_identity"
+)
+
+// Outcomes of connector operations. Failures are rejected credentials, errors
+// are upstream or connector problems.
+const (
+	connectorSuccess = "success"
+	connectorFailure = "failure"
+	connectorError   = "error"
+)
+
+// connectorMetrics records how long connectors take to log users in and
+// refresh their identities, and how often they fail, so slow or failing
+// upstream identity providers can be told apart.
+type connectorMetrics struct {
+	durations  *prometheus.HistogramVec
+	connectors *labelGuard
+}
+
+func newConnectorMetrics(registry *prometheus.Registry, connectors MetricLabelPolicy) (*connectorMetrics, error) {
+	m := &connectorMetrics{
+		durations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "connector_operation_duration_seconds",
+			Help:    "Duration of connector logins, callbacks and refreshes, by connector, operation and outcome.",
+			Buckets: []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+		}, []string{"connector", "operation", "outcome"}),
+		connectors: newLabelGuard(connectors),
+	}
+	if registry != nil {
+		if err := registry.Register(m.durations); err != nil {
+			return nil, fmt.Errorf("register connector metrics: %w", err)
+		}
+	}
+	return m, nil
+}
+
+// observe records an operation of the connector which started at start.
+func (m *connectorMetrics) observe(connID, operation, outcome string, start time.Time) {
+	m.durations.WithLabelValues(m.connectors.value(connID), operation, outcome).Observe(time.Since(start).Seconds())
+}
+
+// connectorOutcome returns the outcome of an operation which returned err
+// and, for password logins, whether the credentials were valid.
+func connectorOutcome(valid bool, err error) string {
+	switch {
+	case err != nil:
+		return connectorError
+	case !valid:
+		return connectorFailure
+	}
+	return connectorSuccess
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/dexidp/dex/storage"
+)
+
+func TestConnectorMetrics(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	registry := prometheus.NewRegistry()
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.PrometheusRegistry = registry
+		c.PasswordConnector = "password"
+	})
+	defer httpServer.Close()
+
+	conn := storage.Connector{
+		ID:     "password",
+		Type:   "mockPassword",
+		Name:   "Password",
+		Config: []byte(`{"username": "jane", "password": "hunter2"}`),
+	}
+	if err := s.storage.CreateConnector(ctx, conn); err != nil {
+		t.Fatalf("create connector: %v", err)
+	}
+	client := storage.Client{ID: "test", Secret: "barfoo", RedirectURIs: []string{"https://example.com/callback"}}
+	if err := s.storage.CreateClient(ctx, client); err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+
+	for _, password := range []string{"hunter2", "wrong", "wrong"} {
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, tokenRequest(client, "10.0.0.1:1234", url.Values{
+			"grant_type": {grantTypePassword},
+			"scope":      {"openid"},
+			"username":   {"jane"},
+			"password":   {password},
+		}))
+		if password == "hunter2" && rr.Code != http.StatusOK {
+			t.Fatalf("expected password grant to succeed, got %d: %s", rr.Code, rr.Body)
+		}
+	}
+
+	if n := testutil.CollectAndCount(s.connectorMetrics.durations); n != 2 {
+		t.Errorf("expected series for successful and failed logins, got %d", n)
+	}
+	tests := []struct {
+		outcome string
+		want    uint64
+	}{
+		{connectorSuccess, 1},
+		{connectorFailure, 2},
+		{connectorError, 0},
+	}
+	for _, tc := range tests {
+		if got := sampleCount(t, registry, "password", connectorOpLogin, tc.outcome); got != tc.want {
+			t.Errorf("%s: expected %d observations, got %d", tc.outcome, tc.want, got)
+		}
+	}
+
+	for code, want := range map[string]float64{"200": 1, "401": 2} {
+		if got := testutil.ToFloat64(s.tokenMetrics.requests.WithLabelValues("test", grantTypePassword, code)); got != want {
+			t.Errorf("expected %v token requests with status %s, got %v", want, code, got)
+		}
+	}
+}
+
+// sampleCount returns the number of observations of the connector histogram
+// with the given labels.
+func sampleCount(t *testing.T, registry *prometheus.Registry, connID, operation, outcome string) uint64 {
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"connector": connID, "operation": operation, "outcome": outcome}
+	for _, family := range families {
+		if family.GetName() != "connector_operation_duration_seconds" {
+			continue
+		}
+	metrics:
+		for _, m := range family.GetMetric() {
+			for _, label := range m.GetLabel() {
+				if want[label.GetName()] != label.GetValue() {
+					continue metrics
+				}
+			}
+			return m.GetHistogram().GetSampleCount()
+		}
+	}
+	return 0
+}
@@ -0,0 +1,565 @@
+package server
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"github.com/dexidp/dex/pkg/audit"
+	"github.com/dexidp/dex/storage"
+)
+
+// Formats of the credentials issued by the credential endpoint.
+const (
+	// CredentialFormatSDJWT issues SD-JWT VCs, in which each claim is
+	// selectively disclosable.
+	CredentialFormatSDJWT = "dc+sd-jwt"
+	// CredentialFormatJWTVC issues W3C verifiable credentials as JWTs.
+	CredentialFormatJWTVC = "jwt_vc_json"
+)
+
+// Errors of the credential endpoint, see OpenID for Verifiable Credential
+// Issuance section 8.3.1.
+const (
+	errInvalidCredentialRequest       = "invalid_credential_request"
+	errUnknownCredentialConfiguration = "unknown_credential_configuration"
+	errInvalidProof                   = "invalid_proof"
+	errCredentialRequestDenied        = "credential_request_denied"
+)
+
+const (
+	// The typ header of key proofs.
+	credentialProofType = "openid4vci-proof+jwt"
+	// How long ago key proofs may have been signed.
+	maxCredentialProofAge = 5 * time.Minute
+	// How many credentials a single request can get.
+	maxCredentialProofs = 10
+	// How many proofs are used between sweeps of the expired ones.
+	credentialProofsSweepEvery = 100
+
+	sdJWTDigestAlg         = "sha-256"
+	w3cCredentialsContext  = "https://www.w3.org/2018/credentials/v1"
+	verifiableCredentialID = "VerifiableCredential"
+)
+
+// Claims which can be issued in credentials, taken from the access token.
+var credentialClaims = map[string]bool{
+	claimEmail:             true,
+	"email_verified":       true,
+	"name":                 true,
+	claimPreferredUsername: true,
+	claimGroups:            true,
+}
+
+// CredentialType is a type of verifiable credential issued by the credential
+// endpoint, as described by OpenID for Verifiable Credential Issuance.
+// Credentials are derived from the claims of the access token presented to
+// the endpoint.
+type CredentialType struct {
+	// ID of the credential configuration wallets request.
+	ID string
+	// IDs of the clients whose access tokens may be presented for the
+	// credential, usually the wallets. Required, so access tokens of other
+	// clients can't be turned into credentials.
+	Clients []string
+	// AllowUnbound issues credentials without holder binding to requests
+	// without key proofs. Such requests are rejected otherwise.
+	AllowUnbound bool
+	// One of the CredentialFormat constants.
+	Format string
+	// The vct claim of SD-JWT VCs, or the type W3C VCs have besides
+	// "VerifiableCredential".
+	Type string
+	// Claims of the access token issued in the credential: "email",
+	// "email_verified", "name", "preferred_username" and "groups". Access
+	// tokens missing any of them are rejected.
+	Claims []string
+	// How long credentials are valid for. Credentials are signed with the
+	// rotated signing keys, so this defaults to and can't exceed how long ID
+	// tokens are valid for, after which the key may no longer be published.
+	ValidFor time.Duration
+}
+
+// newCredentialTypes validates the credential types and indexes them by ID.
+func newCredentialTypes(types []CredentialType, idTokensValidFor time.Duration) (map[string]CredentialType, error) {
+	byID := make(map[string]CredentialType, len(types))
+	for i, t := range types {
+		if t.ID == "" {
+			return nil, fmt.Errorf("credential type %d: no ID", i)
+		}
+		if _, ok := byID[t.ID]; ok {
+			return nil, fmt.Errorf("credential type %q: duplicate ID", t.ID)
+		}
+		switch t.Format {
+		case CredentialFormatSDJWT, CredentialFormatJWTVC:
+		default:
+			return nil, fmt.Errorf("credential type %q: unsupported format %q", t.ID, t.Format)
+		}
+		if t.Type == "" {
+			return nil, fmt.Errorf("credential type %q: no type", t.ID)
+		}
+		if len(t.Clients) == 0 {
+			return nil, fmt.Errorf("credential type %q: no clients", t.ID)
+		}
+		for _, claim := range t.Claims {
+			if !credentialClaims[claim] {
+				return nil, fmt.Errorf("credential type %q: unsupported claim %q", t.ID, claim)
+			}
+		}
+		if t.ValidFor > idTokensValidFor {
+			return nil, fmt.Errorf("credential type %q: valid for longer than ID tokens (%s)", t.ID, idTokensValidFor)
+		}
+		if t.ValidFor <= 0 {
+			t.ValidFor = idTokensValidFor
+		}
+		byID[t.ID] = t
+	}
+	return byID, nil
+}
+
+// credentialIssuerMetadata is the document served at
+// /.well-known/openid-credential-issuer, see OpenID for Verifiable Credential
+// Issuance section 11.2.
+type credentialIssuerMetadata struct {
+	Issuer         string                        `json:"credential_issuer"`
+	Endpoint       string                        `json:"credential_endpoint"`
+	Configurations map[string]credentialMetadata `json:"credential_configurations_supported"`
+}
+
+type credentialMetadata struct {
+	Format         string                   `json:"format"`
+	VCT            string                   `json:"vct,omitempty"`
+	Definition     *credentialDefinition    `json:"credential_definition,omitempty"`
+	BindingMethods []string                 `json:"cryptographic_binding_methods_supported"`
+	SigningAlgs    []string                 `json:"credential_signing_alg_values_supported"`
+	ProofTypes     map[string]proofMetadata `json:"proof_types_supported"`
+}
+
+type credentialDefinition struct {
+	Type []string `json:"type"`
+}
+
+type proofMetadata struct {
+	SigningAlgs []string `json:"proof_signing_alg_values_supported"`
+}
+
+// credentialProofAlgs are the algorithms key proofs may be signed with.
+var credentialProofAlgs = []string{
+	string(jose.ES256), string(jose.ES384), string(jose.ES512),
+	string(jose.RS256), string(jose.RS384), string(jose.RS512),
+	string(jose.PS256), string(jose.EdDSA),
+}
+
+// credentialIssuerHandler serves the credential issuer metadata.
+func (s *Server) credentialIssuerHandler() (http.HandlerFunc, error) {
+	m := credentialIssuerMetadata{
+		Issuer:         s.issuerURL.String(),
+		Endpoint:       s.absURL("/credential"),
+		Configurations: make(map[string]credentialMetadata, len(s.credentialTypes)),
+	}
+	for id, t := range s.credentialTypes {
+		c := credentialMetadata{
+			Format:         t.Format,
+			BindingMethods: []string{"jwk"},
+			// Credentials are always signed with the storage keys.
+			SigningAlgs: []string{string(jose.RS256)},
+			ProofTypes:  map[string]proofMetadata{"jwt": {SigningAlgs: credentialProofAlgs}},
+		}
+		if t.Format == CredentialFormatSDJWT {
+			c.VCT = t.Type
+		} else {
+			c.Definition = &credentialDefinition{Type: []string{verifiableCredentialID, t.Type}}
+		}
+		m.Configurations[id] = c
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal credential issuer metadata: %w", err)
+	}
+	modified := s.now()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serveConditionalJSON(w, r, data, modified)
+	}), nil
+}
+
+// credentialProofs tracks the IDs of the key proofs used until they're too
+// old to be accepted, so each proof gets a single credential.
+//
+// Like codeRedemptions, it's kept in memory, so a proof replayed against
+// another replica goes unnoticed.
+type credentialProofs struct {
+	mu     sync.Mutex
+	proofs map[string]time.Time
+	// added counts proofs since the last sweep.
+	added int
+}
+
+func newCredentialProofs() *credentialProofs {
+	return &credentialProofs{proofs: make(map[string]time.Time)}
+}
+
+// use records the proof, which expires at expiry, and reports false if it
+// was already used.
+func (c *credentialProofs) use(now time.Time, id string, expiry time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if old, ok := c.proofs[id]; ok && !now.After(old) {
+		return false
+	}
+	if c.added++; c.added >= credentialProofsSweepEvery {
+		c.added = 0
+		for id, old := range c.proofs {
+			if now.After(old) {
+				delete(c.proofs, id)
+			}
+		}
+	}
+	c.proofs[id] = expiry
+	return true
+}
+
+// credentialRequest is the body of a request to the credential endpoint.
+type credentialRequest struct {
+	ConfigurationID string `json:"credential_configuration_id"`
+	Proofs          *struct {
+		JWT []string `json:"jwt"`
+	} `json:"proofs"`
+}
+
+type credentialResponse struct {
+	Credentials []issuedCredential `json:"credentials"`
+}
+
+type issuedCredential struct {
+	Credential string `json:"credential"`
+}
+
+// credentialSubject holds the claims of an access token credentials are
+// derived from.
+type credentialSubject struct {
+	Subject           string       `json:"sub"`
+	AuthorizingParty  string       `json:"azp"`
+	Audience          jwt.Audience `json:"aud"`
+	Email             string       `json:"email"`
+	EmailVerified     *bool        `json:"email_verified"`
+	Name              string       `json:"name"`
+	PreferredUsername string       `json:"preferred_username"`
+	Groups            []string     `json:"groups"`
+}
+
+// claims returns the claims of the credential type, and false if the access
+// token is missing any of them.
+func (c credentialSubject) claims(t CredentialType) (map[string]interface{}, bool) {
+	claims := make(map[string]interface{}, len(t.Claims))
+	for _, name := range t.Claims {
+		var v interface{}
+		switch name {
+		case claimEmail:
+			if c.Email != "" {
+				v = c.Email
+			}
+		case "email_verified":
+			if c.EmailVerified != nil {
+				v = *c.EmailVerified
+			}
+		case "name":
+			if c.Name != "" {
+				v = c.Name
+			}
+		case claimPreferredUsername:
+			if c.PreferredUsername != "" {
+				v = c.PreferredUsername
+			}
+		case claimGroups:
+			if len(c.Groups) > 0 {
+				v = c.Groups
+			}
+		}
+		if v == nil {
+			return nil, false
+		}
+		claims[name] = v
+	}
+	return claims, true
+}
+
+// handleCredential handles the credential endpoint of OpenID for Verifiable
+// Credential Issuance. Requests are authorized with an access token issued by
+// dex to one of the clients of the credential type, and get one credential
+// for each key proof, bound to the proof's key, or a single unbound
+// credential without proofs if the credential type allows it.
+func (s *Server) handleCredential(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if r.Method != http.MethodPost {
+		s.tokenErrHelper(w, errInvalidCredentialRequest, "method not allowed", http.StatusBadRequest)
+		return
+	}
+	const prefix = "Bearer "
+	auth := r.Header.Get("authorization")
+	if len(auth) < len(prefix) || !strings.EqualFold(prefix, auth[:len(prefix)]) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		s.tokenErrHelper(w, errAccessDenied, "Invalid bearer token.", http.StatusUnauthorized)
+		return
+	}
+	token, err := s.tokenVerifier().Verify(ctx, auth[len(prefix):])
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		s.tokenErrHelper(w, "invalid_token", "Invalid or expired access token.", http.StatusUnauthorized)
+		return
+	}
+	var subject credentialSubject
+	if err := token.Claims(&subject); err != nil {
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+
+	var req credentialRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		s.tokenErrHelper(w, errInvalidCredentialRequest, "Couldn't parse request body.", http.StatusBadRequest)
+		return
+	}
+	t, ok := s.credentialTypes[req.ConfigurationID]
+	if !ok {
+		s.tokenErrHelper(w, errUnknownCredentialConfiguration, fmt.Sprintf("Unknown credential configuration %q.", req.ConfigurationID), http.StatusBadRequest)
+		return
+	}
+	clientID := subject.AuthorizingParty
+	if clientID == "" && len(subject.Audience) > 0 {
+		clientID = subject.Audience[0]
+	}
+	if !contains(t.Clients, clientID) {
+		s.tokenErrHelper(w, errCredentialRequestDenied, "Client may not request the credential.", http.StatusForbidden)
+		return
+	}
+	claims, ok := subject.claims(t)
+	if !ok {
+		s.tokenErrHelper(w, errCredentialRequestDenied, "Access token is missing claims of the credential.", http.StatusForbidden)
+		return
+	}
+
+	// A nil key issues a credential without holder binding.
+	keys := []*jose.JSONWebKey{nil}
+	if req.Proofs == nil && !t.AllowUnbound {
+		s.tokenErrHelper(w, errInvalidProof, "Credential requires a key proof.", http.StatusBadRequest)
+		return
+	}
+	if req.Proofs != nil {
+		if len(req.Proofs.JWT) == 0 || len(req.Proofs.JWT) > maxCredentialProofs {
+			s.tokenErrHelper(w, errInvalidProof, fmt.Sprintf("Expected 1 to %d jwt proofs.", maxCredentialProofs), http.StatusBadRequest)
+			return
+		}
+		keys = keys[:0]
+		for _, proof := range req.Proofs.JWT {
+			key, err := s.verifyCredentialProof(proof)
+			if err != nil {
+				s.tokenErrHelper(w, errInvalidProof, err.Error(), http.StatusBadRequest)
+				return
+			}
+			keys = append(keys, key)
+		}
+	}
+
+	var resp credentialResponse
+	for _, key := range keys {
+		credential, err := s.newCredential(ctx, t, subject.Subject, claims, key)
+		if err != nil {
+			s.logger.Errorf("failed to issue credential: %v", err)
+			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+			return
+		}
+		resp.Credentials = append(resp.Credentials, issuedCredential{Credential: credential})
+	}
+
+	s.emitAudit(ctx, audit.Event{
+		Type:      audit.EventCredentialIssued,
+		Severity:  audit.SeverityInfo,
+		ClientID:  clientID,
+		Subject:   subject.Subject,
+		SourceIPs: []string{remoteIP(r)},
+		Message:   fmt.Sprintf("issued %d credential(s) of configuration %q", len(keys), t.ID),
+	})
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		s.logger.Errorf("failed to marshal credential response: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
+
+// verifyCredentialProof verifies a JWT key proof, see OpenID for Verifiable
+// Credential Issuance appendix F.1, and returns the key it proves possession
+// of. Dex has no nonce endpoint, so proofs are checked to be recent instead,
+// and their jti to be unused.
+func (s *Server) verifyCredentialProof(proof string) (*jose.JSONWebKey, error) {
+	tok, err := jwt.ParseSigned(proof)
+	if err != nil {
+		return nil, errors.New("malformed jwt proof")
+	}
+	if len(tok.Headers) != 1 {
+		return nil, errors.New("jwt proof must have a single signature")
+	}
+	header := tok.Headers[0]
+	if typ, _ := header.ExtraHeaders[jose.HeaderType].(string); typ != credentialProofType {
+		return nil, fmt.Errorf("jwt proof must have typ %q", credentialProofType)
+	}
+	if !contains(credentialProofAlgs, header.Algorithm) {
+		return nil, fmt.Errorf("unsupported jwt proof algorithm %q", header.Algorithm)
+	}
+	key := header.JSONWebKey
+	if key == nil || !key.IsPublic() || !key.Valid() {
+		return nil, errors.New("jwt proof must carry a public jwk")
+	}
+	var claims jwt.Claims
+	if err := tok.Claims(key, &claims); err != nil {
+		return nil, errors.New("invalid jwt proof signature")
+	}
+	if !claims.Audience.Contains(s.issuerURL.String()) {
+		return nil, errors.New("jwt proof audience must be the credential issuer")
+	}
+	now := s.now()
+	if claims.IssuedAt == nil {
+		return nil, errors.New("jwt proof has no iat")
+	}
+	issuedAt := claims.IssuedAt.Time()
+	if issuedAt.After(now.Add(s.clockSkewTolerance)) || issuedAt.Before(now.Add(-maxCredentialProofAge-s.clockSkewTolerance)) {
+		return nil, errors.New("jwt proof is not recent")
+	}
+	if claims.ID == "" {
+		return nil, errors.New("jwt proof has no jti")
+	}
+	thumbprint, err := key.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return nil, errors.New("jwt proof must carry a public jwk")
+	}
+	// Proofs are only accepted until they're too old, so that's how long
+	// they're remembered.
+	id := base64.RawURLEncoding.EncodeToString(thumbprint) + " " + claims.ID
+	if !s.credentialProofs.use(now, id, issuedAt.Add(maxCredentialProofAge+s.clockSkewTolerance)) {
+		return nil, errors.New("jwt proof was already used")
+	}
+	return key, nil
+}
+
+// newCredential issues a credential of the type with the claims, bound to the
+// key if it isn't nil.
+func (s *Server) newCredential(ctx context.Context, t CredentialType, subject string, claims map[string]interface{}, key *jose.JSONWebKey) (string, error) {
+	issuedAt := s.now()
+	payload := map[string]interface{}{
+		"iss": s.issuerURL.String(),
+		"sub": subject,
+		"iat": issuedAt.Unix(),
+		"nbf": issuedAt.Unix(),
+		"exp": issuedAt.Add(t.ValidFor).Unix(),
+	}
+	if key != nil {
+		payload["cnf"] = map[string]interface{}{"jwk": key}
+	}
+
+	if t.Format == CredentialFormatJWTVC {
+		payload["jti"] = storage.NewID()
+		payload["vc"] = map[string]interface{}{
+			"@context":          []string{w3cCredentialsContext},
+			"type":              []string{verifiableCredentialID, t.Type},
+			"issuer":            s.issuerURL.String(),
+			"issuanceDate":      issuedAt.UTC().Format(time.RFC3339),
+			"credentialSubject": claims,
+		}
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return "", err
+		}
+		return s.signCredential(ctx, "JWT", data)
+	}
+
+	// SD-JWT VCs carry the digests of the claims, which are disclosed
+	// separately, see the SD-JWT spec section 4.2.
+	names := make([]string, 0, len(claims))
+	for name := range claims {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	disclosures := make([]string, 0, len(names))
+	digests := make([]string, 0, len(names))
+	for _, name := range names {
+		disclosure, err := newDisclosure(name, claims[name])
+		if err != nil {
+			return "", err
+		}
+		sum := sha256.Sum256([]byte(disclosure))
+		disclosures = append(disclosures, disclosure)
+		digests = append(digests, base64.RawURLEncoding.EncodeToString(sum[:]))
+	}
+	// Sorted digests don't reveal the order of the claims.
+	sort.Strings(digests)
+	payload["vct"] = t.Type
+	payload["_sd"] = digests
+	payload["_sd_alg"] = sdJWTDigestAlg
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	jwt, err := s.signCredential(ctx, CredentialFormatSDJWT, data)
+	if err != nil {
+		return "", err
+	}
+	return jwt + "~" + strings.Join(disclosures, "~") + "~", nil
+}
+
+// newDisclosure returns the disclosure of a claim, a salted JSON array of its
+// name and value.
+func newDisclosure(name string, value interface{}) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	data, err := json.Marshal([]interface{}{base64.RawURLEncoding.EncodeToString(salt), name, value})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// signCredential signs a credential with the current signing key, with a typ
+// header. Credentials are signed with the storage keys even while migrating
+// signing keys, since the migration signer can't set headers.
+func (s *Server) signCredential(ctx context.Context, typ string, payload []byte) (string, error) {
+	keys, err := s.storage.GetKeys(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get keys: %w", err)
+	}
+	if keys.SigningKey == nil {
+		return "", errors.New("no key to sign payload with")
+	}
+	alg, err := signatureAlgorithm(keys.SigningKey)
+	if err != nil {
+		return "", err
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{Key: keys.SigningKey, Algorithm: alg}, (&jose.SignerOptions{}).WithType(jose.ContentType(typ)))
+	if err != nil {
+		return "", fmt.Errorf("new signer: %w", err)
+	}
+	signature, err := signer.Sign(payload)
+	if err != nil {
+		return "", fmt.Errorf("signing payload: %w", err)
+	}
+	return signature.CompactSerialize()
+}
@@ -0,0 +1,228 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"github.com/dexidp/dex/storage"
+)
+
+func TestNewCredentialTypes(t *testing.T) {
+	wallets := []string{"wallet"}
+	invalid := []CredentialType{
+		{Format: CredentialFormatSDJWT, Type: "Employee", Clients: wallets},
+		{ID: "employee", Format: "ldp_vc", Type: "Employee", Clients: wallets},
+		{ID: "employee", Format: CredentialFormatSDJWT, Clients: wallets},
+		{ID: "employee", Format: CredentialFormatSDJWT, Type: "Employee"},
+		{ID: "employee", Format: CredentialFormatSDJWT, Type: "Employee", Clients: wallets, Claims: []string{"sub"}},
+		{ID: "employee", Format: CredentialFormatSDJWT, Type: "Employee", Clients: wallets, ValidFor: 48 * time.Hour},
+	}
+	for _, c := range invalid {
+		if _, err := newCredentialTypes([]CredentialType{c}, 24*time.Hour); err == nil {
+			t.Errorf("expected error validating credential type %+v", c)
+		}
+	}
+	dup := CredentialType{ID: "employee", Format: CredentialFormatJWTVC, Type: "Employee", Clients: wallets}
+	if _, err := newCredentialTypes([]CredentialType{dup, dup}, 24*time.Hour); err == nil {
+		t.Errorf("expected error for duplicate credential type IDs")
+	}
+	types, err := newCredentialTypes([]CredentialType{dup}, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if types["employee"].ValidFor != 24*time.Hour {
+		t.Errorf("expected credentials to be valid as long as ID tokens, got %s", types["employee"].ValidFor)
+	}
+}
+
+func TestCredentialEndpoint(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.CredentialTypes = []CredentialType{
+			{ID: "email", Clients: []string{"wallet"}, Format: CredentialFormatSDJWT, Type: "https://example.com/email", Claims: []string{"email", "email_verified"}},
+			{ID: "member", Clients: []string{"wallet"}, AllowUnbound: true, Format: CredentialFormatJWTVC, Type: "MemberCredential", Claims: []string{"groups"}},
+			{ID: "unbound", Clients: []string{"wallet"}, AllowUnbound: true, Format: CredentialFormatSDJWT, Type: "https://example.com/email", Claims: []string{"email"}},
+		}
+	})
+	defer httpServer.Close()
+
+	claims := storage.Claims{UserID: "1", Email: "jane@example.com", EmailVerified: true}
+	accessToken, err := s.newAccessToken(ctx, "wallet", claims, []string{"openid", "email"}, "", "mock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherToken, err := s.newAccessToken(ctx, "app", claims, []string{"openid", "email"}, "", "mock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := s.storage.GetKeys(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := func(token string, body interface{}) *httptest.ResponseRecorder {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := httptest.NewRequest(http.MethodPost, "/credential", bytes.NewReader(data))
+		r.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, r)
+		return rr
+	}
+
+	holder, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newProofWithID := func(aud string, issuedAt time.Time, id string) string {
+		opts := (&jose.SignerOptions{EmbedJWK: true}).WithType(credentialProofType)
+		signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: holder}, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		proof, err := jwt.Signed(signer).Claims(jwt.Claims{ID: id, Audience: jwt.Audience{aud}, IssuedAt: jwt.NewNumericDate(issuedAt)}).CompactSerialize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return proof
+	}
+	newProof := func(aud string, issuedAt time.Time) string {
+		return newProofWithID(aud, issuedAt, storage.NewID())
+	}
+	proofs := func(proof string) map[string]interface{} {
+		return map[string]interface{}{
+			"credential_configuration_id": "email",
+			"proofs":                      map[string][]string{"jwt": {proof}},
+		}
+	}
+
+	proof := newProof(s.issuerURL.String(), time.Now())
+	rr := request(accessToken, proofs(proof))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected credential to be issued, got %d: %s", rr.Code, rr.Body)
+	}
+	var resp credentialResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Credentials) != 1 {
+		t.Fatalf("expected a single credential, got %s", rr.Body)
+	}
+
+	// The SD-JWT is signed by dex and discloses each claim separately.
+	parts := strings.Split(resp.Credentials[0].Credential, "~")
+	if len(parts) != 4 || parts[3] != "" {
+		t.Fatalf("expected an SD-JWT with two disclosures, got %q", resp.Credentials[0].Credential)
+	}
+	jws, err := jose.ParseSigned(parts[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ := jws.Signatures[0].Header.ExtraHeaders[jose.HeaderType]; typ != CredentialFormatSDJWT {
+		t.Errorf("expected typ %q, got %v", CredentialFormatSDJWT, typ)
+	}
+	payload, err := jws.Verify(keys.SigningKeyPub)
+	if err != nil {
+		t.Fatalf("verify credential: %v", err)
+	}
+	var sdJWT struct {
+		VCT    string   `json:"vct"`
+		Digest []string `json:"_sd"`
+		Email  string   `json:"email"`
+		Cnf    struct {
+			JWK jose.JSONWebKey `json:"jwk"`
+		} `json:"cnf"`
+	}
+	if err := json.Unmarshal(payload, &sdJWT); err != nil {
+		t.Fatal(err)
+	}
+	if sdJWT.VCT != "https://example.com/email" || sdJWT.Email != "" {
+		t.Errorf("unexpected SD-JWT payload %s", payload)
+	}
+	if !sdJWT.Cnf.JWK.Valid() || sdJWT.Cnf.JWK.Key.(*ecdsa.PublicKey).X.Cmp(holder.X) != 0 {
+		t.Errorf("expected credential to be bound to the holder's key, got %s", payload)
+	}
+	disclosed := make(map[string]interface{})
+	for _, disclosure := range parts[1:3] {
+		sum := sha256.Sum256([]byte(disclosure))
+		if !contains(sdJWT.Digest, base64.RawURLEncoding.EncodeToString(sum[:])) {
+			t.Errorf("disclosure %q has no digest in the credential", disclosure)
+		}
+		data, err := base64.RawURLEncoding.DecodeString(disclosure)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var d []interface{}
+		if err := json.Unmarshal(data, &d); err != nil || len(d) != 3 {
+			t.Fatalf("malformed disclosure %s", data)
+		}
+		disclosed[d[1].(string)] = d[2]
+	}
+	if disclosed["email"] != "jane@example.com" || disclosed["email_verified"] != true {
+		t.Errorf("unexpected disclosed claims %v", disclosed)
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		body   interface{}
+		status int
+	}{
+		{"invalid token", "invalid", proofs(""), http.StatusUnauthorized},
+		{"other client", otherToken, proofs(newProof(s.issuerURL.String(), time.Now())), http.StatusForbidden},
+		{"replayed proof", accessToken, proofs(proof), http.StatusBadRequest},
+		{"proof without jti", accessToken, proofs(newProofWithID(s.issuerURL.String(), time.Now(), "")), http.StatusBadRequest},
+		{"unknown configuration", accessToken, map[string]string{"credential_configuration_id": "unknown"}, http.StatusBadRequest},
+		{"missing claims", accessToken, map[string]string{"credential_configuration_id": "member"}, http.StatusForbidden},
+		{"proof for another issuer", accessToken, proofs(newProof("https://other.example.com", time.Now())), http.StatusBadRequest},
+		{"stale proof", accessToken, proofs(newProof(s.issuerURL.String(), time.Now().Add(-time.Hour))), http.StatusBadRequest},
+		{"unbound", accessToken, map[string]string{"credential_configuration_id": "email"}, http.StatusBadRequest},
+		{"allowed unbound", accessToken, map[string]string{"credential_configuration_id": "unbound"}, http.StatusOK},
+	}
+	for _, tc := range tests {
+		if rr := request(tc.token, tc.body); rr.Code != tc.status {
+			t.Errorf("%s: expected status %d, got %d: %s", tc.name, tc.status, rr.Code, rr.Body)
+		}
+	}
+
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/.well-known/openid-credential-issuer", nil))
+	var metadata credentialIssuerMetadata
+	if err := json.Unmarshal(rr.Body.Bytes(), &metadata); err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Endpoint != s.absURL("/credential") || metadata.Configurations["member"].Definition == nil {
+		t.Errorf("unexpected credential issuer metadata %s", rr.Body)
+	}
+}
+
+func TestCredentialEndpointDisabled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, nil)
+	defer httpServer.Close()
+
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/credential", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected credential endpoint not to be served, got %d", rr.Code)
+	}
+}
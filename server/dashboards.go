@@ -0,0 +1,139 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+)
+
+// Handlers of the login flow, matched against the handler label of the HTTP
+// metrics.
+const loginHandlers = `/auth.*|/callback.*|/approval`
+
+type dashboardTarget struct {
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat"`
+}
+
+type dashboardPanel struct {
+	title   string
+	unit    string
+	targets []dashboardTarget
+}
+
+// dashboard describes a Grafana dashboard showing the rate, errors and
+// duration of the requests to a part of dex.
+type dashboard struct {
+	name   string
+	title  string
+	panels []dashboardPanel
+}
+
+// dashboards are served to administrators as Grafana dashboards, built from
+// the metrics dex exports.
+var dashboards = []dashboard{
+	{
+		name:  "login",
+		title: "Dex / Login",
+		panels: []dashboardPanel{
+			{"Requests", "reqps", []dashboardTarget{
+				{`sum by (handler) (rate(http_requests_total{handler=~"` + loginHandlers + `"}[5m]))`, "{{handler}}"},
+			}},
+			{"Errors", "reqps", []dashboardTarget{
+				{`sum by (handler, code) (rate(http_requests_total{handler=~"` + loginHandlers + `", code=~"5.."}[5m]))`, "{{handler}} {{code}}"},
+			}},
+			{"Duration (p99)", "s", []dashboardTarget{
+				{`histogram_quantile(0.99, sum by (le, handler) (rate(http_request_duration_seconds_bucket{handler=~"` + loginHandlers + `"}[5m])))`, "{{handler}}"},
+			}},
+			{"Connector duration (p99)", "s", []dashboardTarget{
+				{`histogram_quantile(0.99, sum by (le, connector, operation) (rate(connector_operation_duration_seconds_bucket[5m])))`, "{{connector}} {{operation}}"},
+			}},
+			{"Connector failures", "ops", []dashboardTarget{
+				{`sum by (connector, operation, outcome) (rate(connector_operation_duration_seconds_count{outcome!="` + connectorSuccess + `"}[5m]))`, "{{connector}} {{operation}} {{outcome}}"},
+			}},
+		},
+	},
+	{
+		name:  "token",
+		title: "Dex / Tokens",
+		panels: []dashboardPanel{
+			{"Requests", "reqps", []dashboardTarget{
+				{`sum by (grant_type) (rate(token_requests_total[5m]))`, "{{grant_type}}"},
+			}},
+			{"Errors", "reqps", []dashboardTarget{
+				{`sum by (grant_type, code) (rate(token_requests_total{code!~"2.."}[5m]))`, "{{grant_type}} {{code}}"},
+			}},
+			{"Duration", "s", []dashboardTarget{
+				{`histogram_quantile(0.5, sum by (le) (rate(http_request_duration_seconds_bucket{handler="/token"}[5m])))`, "p50"},
+				{`histogram_quantile(0.99, sum by (le) (rate(http_request_duration_seconds_bucket{handler="/token"}[5m])))`, "p99"},
+			}},
+			{"Requests by client", "reqps", []dashboardTarget{
+				{`topk(10, sum by (client_id) (rate(token_requests_total[5m])))`, "{{client_id}}"},
+			}},
+		},
+	},
+}
+
+// grafana returns the dashboard in Grafana's JSON model. The Prometheus data
+// source is chosen when the dashboard is imported.
+func (d dashboard) grafana() map[string]interface{} {
+	var panels []map[string]interface{}
+	for i, p := range d.panels {
+		panels = append(panels, map[string]interface{}{
+			"id":         i + 1,
+			"type":       "timeseries",
+			"title":      p.title,
+			"datasource": "${DS_PROMETHEUS}",
+			"gridPos":    map[string]int{"h": 8, "w": 12, "x": 12 * (i % 2), "y": 8 * (i / 2)},
+			"fieldConfig": map[string]interface{}{
+				"defaults": map[string]string{"unit": p.unit},
+			},
+			"targets": p.targets,
+		})
+	}
+	return map[string]interface{}{
+		"__inputs": []map[string]string{{
+			"name":     "DS_PROMETHEUS",
+			"label":    "Prometheus",
+			"type":     "datasource",
+			"pluginId": "prometheus",
+		}},
+		"uid":           "dex-" + d.name,
+		"title":         d.title,
+		"tags":          []string{"dex"},
+		"schemaVersion": 27,
+		"time":          map[string]string{"from": "now-6h", "to": "now"},
+		"refresh":       "1m",
+		"panels":        panels,
+	}
+}
+
+// handleDashboards lists the dashboards, or serves the one named by the last
+// path element, such as "login.json".
+func handleDashboards(w http.ResponseWriter, r *http.Request) {
+	var v interface{}
+	if name := path.Base(r.URL.Path); name == "dashboards" {
+		var names []string
+		for _, d := range dashboards {
+			names = append(names, d.name+".json")
+		}
+		v = names
+	} else {
+		for _, d := range dashboards {
+			if d.name+".json" == name {
+				v = d.grafana()
+			}
+		}
+	}
+	if v == nil {
+		http.NotFound(w, r)
+		return
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
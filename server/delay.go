@@ -0,0 +1,42 @@
+package server
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// FailureDelay slows down responses to failed authentication attempts, such
+// as wrong passwords or client secrets, to make guessing them at high speed
+// impractical. Unlike rate limits it doesn't affect legitimate users, who
+// rarely fail more than a couple of times.
+type FailureDelay struct {
+	// Lower and upper bound of the delay. Each failure waits a random
+	// duration in between, so response times don't reveal anything. The
+	// delay is disabled if Max is zero.
+	Min time.Duration
+	Max time.Duration
+}
+
+// duration returns a random duration between Min and Max.
+func (f FailureDelay) duration() time.Duration {
+	if f.Max <= f.Min {
+		return f.Max
+	}
+	return f.Min + time.Duration(rand.Int63n(int64(f.Max-f.Min)+1))
+}
+
+// delayFailure blocks for the configured failure delay, or until the request
+// is canceled.
+func (s *Server) delayFailure(ctx context.Context) {
+	d := s.failureDelay.duration()
+	if d <= 0 {
+		return
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+	}
+}
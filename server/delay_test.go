@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dexidp/dex/storage"
+)
+
+func TestFailureDelayDuration(t *testing.T) {
+	tests := []struct {
+		delay    FailureDelay
+		min, max time.Duration
+	}{
+		{FailureDelay{}, 0, 0},
+		{FailureDelay{Max: time.Second}, 0, time.Second},
+		{FailureDelay{Min: time.Second, Max: time.Second}, time.Second, time.Second},
+		{FailureDelay{Min: 100 * time.Millisecond, Max: 200 * time.Millisecond}, 100 * time.Millisecond, 200 * time.Millisecond},
+	}
+	for _, tc := range tests {
+		for i := 0; i < 100; i++ {
+			if d := tc.delay.duration(); d < tc.min || d > tc.max {
+				t.Fatalf("%+v: duration %v not between %v and %v", tc.delay, d, tc.min, tc.max)
+			}
+		}
+	}
+}
+
+func TestFailureDelayCanceled(t *testing.T) {
+	s := &Server{failureDelay: FailureDelay{Min: time.Hour, Max: time.Hour}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.delayFailure(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("delay didn't end when the request was canceled")
+	}
+}
+
+func TestFailureDelayInvalidClient(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const delay = 50 * time.Millisecond
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.FailureDelay = FailureDelay{Min: delay, Max: delay}
+	})
+	defer httpServer.Close()
+
+	client := storage.Client{ID: "test", Secret: "barfoo", RedirectURIs: []string{"https://example.com/callback"}}
+	if err := s.storage.CreateClient(ctx, client); err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	for _, secret := range []string{"wrong", ""} {
+		client.Secret = secret
+		start := time.Now()
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, tokenRequest(client, "10.0.0.1:1234", url.Values{"grant_type": {grantTypeAuthorizationCode}}))
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected status %d, got %d", http.StatusUnauthorized, rr.Code)
+		}
+		if elapsed := time.Since(start); elapsed < delay {
+			t.Errorf("expected failed authentication to be delayed by %v, took %v", delay, elapsed)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Discovery fields describing dex's own endpoints and behavior. They can't
+// be overridden, because clients relying on a different value would break.
+var fixedDiscoveryFields = map[string]bool{
+	"issuer":                                true,
+	"authorization_endpoint":                true,
+	"token_endpoint":                        true,
+	"jwks_uri":                              true,
+	"userinfo_endpoint":                     true,
+	"introspection_endpoint":                true,
+	"end_session_endpoint":                  true,
+	"backchannel_logout_supported":          true,
+	"backchannel_logout_session_supported":  true,
+	"response_types_supported":              true,
+	"id_token_signing_alg_values_supported": true,
+	"grant_types_supported":                 true,
+}
+
+// Discovery fields which must be present in the document.
+var requiredDiscoveryFields = []string{"subject_types_supported"}
+
+// applyDiscoveryOverrides adds the fields to the marshaled discovery
+// document, replacing existing fields of the same name.
+func applyDiscoveryOverrides(doc []byte, overrides map[string]interface{}) ([]byte, error) {
+	if len(overrides) == 0 {
+		return doc, nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(doc, &fields); err != nil {
+		return nil, err
+	}
+	for name, v := range overrides {
+		if err := validateDiscoveryField(name, v); err != nil {
+			return nil, fmt.Errorf("invalid discovery field %q: %w", name, err)
+		}
+		fields[name] = v
+	}
+	for _, name := range requiredDiscoveryFields {
+		if list, ok := fields[name].([]interface{}); !ok || len(list) == 0 {
+			return nil, fmt.Errorf("discovery field %q must not be empty", name)
+		}
+	}
+	return json.MarshalIndent(fields, "", "  ")
+}
+
+// validateDiscoveryField checks that the value has the type the metadata
+// specs define for fields with that name.
+func validateDiscoveryField(name string, v interface{}) error {
+	if fixedDiscoveryFields[name] {
+		return fmt.Errorf("field is set by dex and can't be overridden")
+	}
+	if v == nil {
+		return fmt.Errorf("value must not be null")
+	}
+	switch {
+	case name == "mtls_endpoint_aliases":
+		aliases, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("value must be an object")
+		}
+		for alias, u := range aliases {
+			if err := validateDiscoveryURL(u); err != nil {
+				return fmt.Errorf("alias %q: %w", alias, err)
+			}
+		}
+	case strings.HasSuffix(name, "_supported"):
+		list, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("value must be a list of strings")
+		}
+		for _, e := range list {
+			if _, ok := e.(string); !ok {
+				return fmt.Errorf("value must be a list of strings")
+			}
+		}
+	case strings.HasSuffix(name, "_endpoint"), strings.HasSuffix(name, "_uri"),
+		name == "service_documentation", name == "op_policy_uri", name == "op_tos_uri":
+		return validateDiscoveryURL(v)
+	}
+	return nil
+}
+
+func validateDiscoveryURL(v interface{}) error {
+	s, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("value must be a URL")
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+	if !u.IsAbs() || u.Host == "" {
+		return fmt.Errorf("%q is not an absolute URL", s)
+	}
+	return nil
+}
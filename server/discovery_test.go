@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/dexidp/dex/storage"
+	"github.com/dexidp/dex/storage/memory"
+)
+
+func TestDiscoveryOverrides(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.DiscoveryOverrides = map[string]interface{}{
+			"service_documentation": "https://docs.example.com",
+			"claims_supported":      []interface{}{"sub", "groups"},
+			"mtls_endpoint_aliases": map[string]interface{}{"token_endpoint": "https://mtls.example.com/token"},
+		}
+	})
+	defer httpServer.Close()
+
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil))
+	var got map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode discovery: %v", err)
+	}
+	if got["service_documentation"] != "https://docs.example.com" {
+		t.Errorf("expected service_documentation to be added, got %v", got["service_documentation"])
+	}
+	if want := []interface{}{"sub", "groups"}; !reflect.DeepEqual(got["claims_supported"], want) {
+		t.Errorf("expected claims_supported %v, got %v", want, got["claims_supported"])
+	}
+	if got["token_endpoint"] != s.absURL("/token") {
+		t.Errorf("expected generated fields to be kept, got token_endpoint %v", got["token_endpoint"])
+	}
+}
+
+func TestDiscoveryOverridesInvalid(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tests := map[string]map[string]interface{}{
+		"fixed field":       {"token_endpoint": "https://example.com/token"},
+		"null":              {"service_documentation": nil},
+		"relative URL":      {"service_documentation": "/docs"},
+		"list of numbers":   {"claims_supported": []interface{}{1.0}},
+		"empty required":    {"subject_types_supported": []interface{}{}},
+		"bad alias":         {"mtls_endpoint_aliases": map[string]interface{}{"token_endpoint": "token"}},
+		"aliases not a map": {"mtls_endpoint_aliases": "https://example.com"},
+	}
+	for name, overrides := range tests {
+		s := memory.New(logger)
+		if err := s.CreateConnector(ctx, storage.Connector{ID: "mock", Type: "mockCallback", Name: "Mock"}); err != nil {
+			t.Fatalf("create connector: %v", err)
+		}
+		config := Config{
+			Issuer:             "http://localhost",
+			Storage:            s,
+			Web:                WebConfig{Dir: "../web"},
+			Logger:             logger,
+			DiscoveryOverrides: overrides,
+		}
+		if _, err := newServer(ctx, config, staticRotationStrategy(testKey)); err == nil {
+			t.Errorf("%s: expected overrides %v to be rejected", name, overrides)
+		}
+	}
+}
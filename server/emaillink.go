@@ -0,0 +1,274 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dexidp/dex/connector"
+	"github.com/dexidp/dex/pkg/alert"
+	"github.com/dexidp/dex/pkg/audit"
+	"github.com/dexidp/dex/pkg/log"
+	"github.com/dexidp/dex/storage"
+)
+
+// emailLinkPage is the data the email link template is rendered with.
+type emailLinkPage struct {
+	PostURL  string
+	BackLink bool
+	Email    string
+	// Sent is set once a link was sent to Email, the page then offers to
+	// send another one.
+	Sent bool
+	// Token is set when a user opened a link, the page then asks them to
+	// confirm logging in.
+	Token string
+	// Notice is shown to explain why a request was refused.
+	Notice           string
+	ExpiresInMinutes int
+}
+
+// loginLinkCookieName is the cookie binding login links to the browser that
+// requested them. It holds the ID of the auth request the link was sent for.
+const loginLinkCookieName = "dex_login_link"
+
+// sentLinks tracks when login links were sent to each address, so that users
+// can't be flooded with emails. Like codeRedemptions it's kept in memory, so
+// each instance enforces the limits on its own.
+type sentLinks struct {
+	mu   sync.Mutex
+	sent map[string][]time.Time
+	// swept is when addresses without recent links were last forgotten.
+	swept time.Time
+}
+
+func newSentLinks() *sentLinks {
+	return &sentLinks{sent: make(map[string][]time.Time)}
+}
+
+// recentLinks returns the times of links sent in the last hour.
+func recentLinks(times []time.Time, now time.Time) []time.Time {
+	i := 0
+	for i < len(times) && !now.Before(times[i].Add(time.Hour)) {
+		i++
+	}
+	return times[i:]
+}
+
+// allow records a link sent to the address and returns zero if the limits
+// allow it, otherwise it returns how long to wait before sending another.
+func (l *sentLinks) allow(address string, limits connector.LinkLimits, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// Only the address's own links are looked at on every send, others are
+	// forgotten once an hour.
+	if now.Sub(l.swept) >= time.Hour {
+		l.swept = now
+		for a, times := range l.sent {
+			if len(recentLinks(times, now)) == 0 {
+				delete(l.sent, a)
+			}
+		}
+	}
+
+	times := recentLinks(l.sent[address], now)
+	if len(times) > 0 {
+		if wait := times[len(times)-1].Add(limits.ResendAfter).Sub(now); wait > 0 {
+			return wait
+		}
+	}
+	if limits.PerHour > 0 && len(times) >= limits.PerHour {
+		return times[len(times)-limits.PerHour].Add(time.Hour).Sub(now)
+	}
+	l.sent[address] = append(times, now)
+	return 0
+}
+
+// waitText describes a duration to wait in whole seconds or minutes.
+func waitText(d time.Duration) string {
+	if d <= time.Minute {
+		s := int((d + time.Second - 1) / time.Second)
+		if s == 1 {
+			return "1 second"
+		}
+		return fmt.Sprintf("%d seconds", s)
+	}
+	m := int((d + time.Minute - 1) / time.Minute)
+	return fmt.Sprintf("%d minutes", m)
+}
+
+// sendLoginLink emails a link which logs the user in to the auth request. It
+// handles the email form of link connectors, and its resend button.
+func (s *Server) sendLoginLink(w http.ResponseWriter, r *http.Request, authReq storage.AuthRequest, connID string, conn connector.LinkConnector, showBacklink bool) {
+	ctx := r.Context()
+	limits := conn.Limits()
+	page := emailLinkPage{
+		PostURL:          r.URL.String(),
+		BackLink:         showBacklink,
+		Email:            strings.TrimSpace(r.FormValue("email")),
+		ExpiresInMinutes: int((limits.ValidFor + time.Minute - 1) / time.Minute),
+	}
+
+	identity, err := conn.Identity(page.Email)
+	if err != nil {
+		s.logger.Debugf("Refused to send login link: %v", err)
+		page.Notice = "This email address can't be used to log in."
+		if err := s.templates.emailLink(r, w, page); err != nil {
+			s.logger.Errorf("Server template error: %v", err)
+		}
+		return
+	}
+	page.Email = identity.Email
+	// Limits only refuse addresses which were sent a link before, so the
+	// user is told to look for that one.
+	page.Sent = true
+
+	if wait := s.sentLinks.allow(connID+"/"+identity.Email, limits, s.now()); wait > 0 {
+		page.Notice = fmt.Sprintf("A login link was sent recently. Try again in %s.", waitText(wait))
+		w.WriteHeader(http.StatusTooManyRequests)
+		if err := s.templates.emailLink(r, w, page); err != nil {
+			s.logger.Errorf("Server template error: %v", err)
+		}
+		return
+	}
+
+	now := s.now()
+	link := storage.LoginLink{
+		ID:            storage.NewID(),
+		AuthRequestID: authReq.ID,
+		ConnectorID:   connID,
+		Email:         identity.Email,
+		CreatedAt:     now,
+		Expiry:        now.Add(limits.ValidFor),
+	}
+	if err := s.storage.CreateLoginLink(ctx, link); err != nil {
+		s.logger.Errorf("Failed to create login link: %v", err)
+		s.renderError(r, w, http.StatusInternalServerError, "Database error.")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     loginLinkCookieName,
+		Value:    authReq.ID,
+		Path:     s.absPath("/link"),
+		MaxAge:   int(limits.ValidFor.Seconds()),
+		Secure:   s.issuerURL.Scheme == "https",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	start := time.Now()
+	err = conn.SendLink(ctx, identity.Email, s.absURL("/link")+"?token="+link.ID)
+	s.connectorMetrics.observe(connID, connectorOpSendLink, connectorOutcome(true, err), start)
+	if err != nil {
+		s.logger.Errorf("Failed to send login link: %v", err)
+		s.reportFailure(alert.KindConnector, connID, err)
+		s.renderError(r, w, http.StatusInternalServerError, "Failed to send the login link.")
+		return
+	}
+	s.emitAudit(ctx, audit.Event{
+		Type:        audit.EventLoginLinkSent,
+		Severity:    audit.SeverityInfo,
+		ClientID:    authReq.ClientID,
+		Subject:     subjectFor(identity.UserID, connID),
+		ConnectorID: connID,
+		SourceIPs:   []string{remoteIP(r)},
+		Message:     "login link sent",
+	})
+
+	if err := s.templates.emailLink(r, w, page); err != nil {
+		s.logger.Errorf("Server template error: %v", err)
+	}
+}
+
+// handleLoginLink logs a user in with a link sent by a link connector. Opening
+// the link asks the user to confirm, so that mail scanners fetching links
+// don't use them up. Links only work in the browser that requested them, so
+// that a link requested by someone else for the user's address doesn't log
+// them in to that person's auth request.
+func (s *Server) handleLoginLink(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	token := r.FormValue("token")
+
+	switch r.Method {
+	case http.MethodGet:
+		if err := s.templates.emailLink(r, w, emailLinkPage{PostURL: r.URL.Path, Token: token}); err != nil {
+			s.logger.Errorf("Server template error: %v", err)
+		}
+		return
+	case http.MethodPost:
+	default:
+		s.renderError(r, w, http.StatusBadRequest, "Unsupported request method.")
+		return
+	}
+
+	link, err := s.storage.ConsumeLoginLink(ctx, token)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			s.delayFailure(ctx)
+			s.renderError(r, w, http.StatusBadRequest, "This login link is invalid or was already used.")
+			return
+		}
+		s.logger.Errorf("Failed to consume login link: %v", err)
+		s.renderError(r, w, http.StatusInternalServerError, "Database error.")
+		return
+	}
+	if s.now().After(link.Expiry) {
+		s.renderError(r, w, http.StatusBadRequest, "This login link has expired.")
+		return
+	}
+	// The link is used up either way, it was sent for a login started
+	// elsewhere.
+	if cookie, err := r.Cookie(loginLinkCookieName); err != nil || cookie.Value != link.AuthRequestID {
+		s.logger.Errorf("Login link opened in another browser than it was requested from, remote IP %s", log.IP(remoteIP(r)))
+		s.renderError(r, w, http.StatusBadRequest, "Open the login link in the browser you requested it from.")
+		return
+	}
+
+	authReq, err := s.storage.GetAuthRequest(ctx, link.AuthRequestID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			s.renderError(r, w, http.StatusBadRequest, "Login session expired.")
+			return
+		}
+		s.logger.Errorf("Failed to get auth request: %v", err)
+		s.renderError(r, w, http.StatusInternalServerError, "Database error.")
+		return
+	}
+	// The user may have logged in another way since the link was sent.
+	if authReq.ConnectorID != link.ConnectorID || authReq.LoggedIn {
+		s.renderError(r, w, http.StatusBadRequest, "This login link is no longer valid.")
+		return
+	}
+
+	conn, err := s.getConnector(ctx, link.ConnectorID)
+	if err != nil {
+		s.logger.Errorf("Failed to get connector with id %q : %v", link.ConnectorID, err)
+		s.renderError(r, w, http.StatusInternalServerError, "Requested resource does not exist.")
+		return
+	}
+	linkConnector, ok := conn.Connector.(connector.LinkConnector)
+	if !ok {
+		s.renderError(r, w, http.StatusInternalServerError, "Requested resource does not exist.")
+		return
+	}
+	// Ask the connector again, in case the address is no longer allowed.
+	identity, err := linkConnector.Identity(link.Email)
+	if err != nil {
+		s.logger.Errorf("Login link refused: %v", err)
+		s.renderError(r, w, http.StatusForbidden, "This email address can't be used to log in.")
+		return
+	}
+
+	redirectURL, err := s.finalizeLogin(ctx, identity, authReq, conn.Connector)
+	if err != nil {
+		s.renderLoginError(r, w, err)
+		return
+	}
+	s.startSession(ctx, w, authReq.ClientID, link.ConnectorID, identity)
+	http.SetCookie(w, &http.Cookie{Name: loginLinkCookieName, Path: s.absPath("/link"), MaxAge: -1})
+
+	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+}
@@ -0,0 +1,224 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dexidp/dex/connector"
+	"github.com/dexidp/dex/pkg/audit"
+	"github.com/dexidp/dex/storage"
+)
+
+// linkRecorder is a link connector which records the links it's asked to
+// send instead of emailing them.
+type linkRecorder struct {
+	links []string
+}
+
+func (l *linkRecorder) Identity(email string) (connector.Identity, error) {
+	if !strings.HasSuffix(email, "@example.com") {
+		return connector.Identity{}, errors.New("unknown email domain")
+	}
+	return connector.Identity{UserID: email, Email: email, EmailVerified: true}, nil
+}
+
+func (l *linkRecorder) SendLink(ctx context.Context, email, link string) error {
+	l.links = append(l.links, link)
+	return nil
+}
+
+func (l *linkRecorder) Limits() connector.LinkLimits {
+	return connector.LinkLimits{ValidFor: 10 * time.Minute, ResendAfter: time.Minute, PerHour: 5}
+}
+
+func TestSentLinks(t *testing.T) {
+	limits := connector.LinkLimits{ResendAfter: time.Minute, PerHour: 3}
+	l := newSentLinks()
+	now := time.Now()
+
+	if wait := l.allow("jane", limits, now); wait != 0 {
+		t.Fatalf("expected first link to be allowed, got wait %v", wait)
+	}
+	if wait := l.allow("jane", limits, now.Add(30*time.Second)); wait != 30*time.Second {
+		t.Errorf("expected to wait for the resend delay, got %v", wait)
+	}
+	if wait := l.allow("john", limits, now.Add(30*time.Second)); wait != 0 {
+		t.Errorf("expected other addresses not to be limited, got %v", wait)
+	}
+	l.allow("jane", limits, now.Add(time.Minute))
+	l.allow("jane", limits, now.Add(2*time.Minute))
+	if wait := l.allow("jane", limits, now.Add(3*time.Minute)); wait != 57*time.Minute {
+		t.Errorf("expected to wait for the first link to leave the hour, got %v", wait)
+	}
+	if wait := l.allow("jane", limits, now.Add(time.Hour+time.Minute)); wait != 0 {
+		t.Errorf("expected a link to be allowed after an hour, got %v", wait)
+	}
+	if _, ok := l.sent["john"]; ok {
+		t.Errorf("expected addresses without recent links to be forgotten")
+	}
+}
+
+func TestEmailLink(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sink := &recordingSink{}
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.AuditSink = sink
+	})
+	defer httpServer.Close()
+
+	if err := s.storage.CreateConnector(ctx, storage.Connector{ID: "email", Type: "email", Name: "Email", ResourceVersion: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	links := &linkRecorder{}
+	s.connectors["email"] = Connector{ResourceVersion: "1", Connector: links}
+
+	newAuthRequest := func() storage.AuthRequest {
+		authReq := storage.AuthRequest{
+			ID:            storage.NewID(),
+			ClientID:      "test",
+			ResponseTypes: []string{responseTypeCode},
+			RedirectURI:   "https://example.com/callback",
+			Expiry:        time.Now().Add(time.Hour),
+		}
+		if err := s.storage.CreateAuthRequest(ctx, authReq); err != nil {
+			t.Fatal(err)
+		}
+		return authReq
+	}
+	// The browser's cookies.
+	cookies := make(map[string]*http.Cookie)
+	do := func(method, target string, form url.Values) *httptest.ResponseRecorder {
+		var req *http.Request
+		if form != nil {
+			req = httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		} else {
+			req = httptest.NewRequest(method, target, nil)
+		}
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+		for _, c := range rr.Result().Cookies() {
+			if c.MaxAge < 0 {
+				delete(cookies, c.Name)
+			} else {
+				cookies[c.Name] = c
+			}
+		}
+		return rr
+	}
+	token := func() string {
+		u, err := url.Parse(links.links[len(links.links)-1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u.Query().Get("token")
+	}
+
+	authReq := newAuthRequest()
+	rr := do(http.MethodGet, "/auth/email?req="+authReq.ID, nil)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `name="email"`) {
+		t.Fatalf("expected the email form, got %d: %s", rr.Code, rr.Body)
+	}
+
+	rr = do(http.MethodPost, "/auth/email?req="+authReq.ID, url.Values{"email": {"jane@example.org"}})
+	if !strings.Contains(rr.Body.String(), "can&#39;t be used to log in") || len(links.links) != 0 {
+		t.Errorf("expected address to be refused, got %d: %s", rr.Code, rr.Body)
+	}
+
+	rr = do(http.MethodPost, "/auth/email?req="+authReq.ID, url.Values{"email": {" jane@example.com "}})
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Check Your Inbox") {
+		t.Fatalf("expected link to be sent, got %d: %s", rr.Code, rr.Body)
+	}
+	if len(links.links) != 1 || !strings.HasPrefix(links.links[0], s.absURL("/link")+"?token=") {
+		t.Fatalf("unexpected links %q", links.links)
+	}
+	if last := sink.events[len(sink.events)-1]; last.Type != audit.EventLoginLinkSent || last.ConnectorID != "email" {
+		t.Errorf("expected a login_link_sent event, got %+v", last)
+	}
+
+	rr = do(http.MethodPost, "/auth/email?req="+authReq.ID, url.Values{"email": {"jane@example.com"}, "resend": {"true"}})
+	if rr.Code != http.StatusTooManyRequests || len(links.links) != 1 {
+		t.Errorf("expected resending right away to be refused, got %d", rr.Code)
+	}
+
+	// Opening the link asks for confirmation without using it.
+	rr = do(http.MethodGet, "/link?token="+token(), nil)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), token()) {
+		t.Fatalf("expected confirmation page, got %d: %s", rr.Code, rr.Body)
+	}
+	rr = do(http.MethodPost, "/link", url.Values{"token": {token()}})
+	if rr.Code != http.StatusSeeOther || !strings.Contains(rr.Header().Get("Location"), "/approval?req="+authReq.ID) {
+		t.Fatalf("expected redirect to approval, got %d %q: %s", rr.Code, rr.Header().Get("Location"), rr.Body)
+	}
+	got, err := s.storage.GetAuthRequest(ctx, authReq.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.LoggedIn || got.Claims.Email != "jane@example.com" || !got.Claims.EmailVerified {
+		t.Errorf("expected auth request to be logged in, got %+v", got)
+	}
+
+	// Links are single use.
+	if rr := do(http.MethodPost, "/link", url.Values{"token": {token()}}); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected used link to be rejected, got %d", rr.Code)
+	}
+
+	// Links expire.
+	authReq = newAuthRequest()
+	now := s.now()
+	expired := storage.LoginLink{
+		ID:            storage.NewID(),
+		AuthRequestID: authReq.ID,
+		ConnectorID:   "email",
+		Email:         "john@example.com",
+		CreatedAt:     now.Add(-time.Hour),
+		Expiry:        now.Add(-time.Minute),
+	}
+	if err := s.storage.CreateLoginLink(ctx, expired); err != nil {
+		t.Fatal(err)
+	}
+	if rr := do(http.MethodPost, "/link", url.Values{"token": {expired.ID}}); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected expired link to be rejected, got %d", rr.Code)
+	}
+
+	// Links can't be used once the user chose another connector.
+	rr = do(http.MethodPost, "/auth/email?req="+authReq.ID, url.Values{"email": {"john@example.com"}})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected link to be sent, got %d", rr.Code)
+	}
+	if err := s.storage.UpdateAuthRequest(ctx, authReq.ID, func(a storage.AuthRequest) (storage.AuthRequest, error) {
+		a.ConnectorID = "mock"
+		return a, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if rr := do(http.MethodPost, "/link", url.Values{"token": {token()}}); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected link for another connector to be rejected, got %d", rr.Code)
+	}
+
+	// Links only work in the browser that requested them.
+	authReq = newAuthRequest()
+	rr = do(http.MethodPost, "/auth/email?req="+authReq.ID, url.Values{"email": {"joe@example.com"}})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected link to be sent, got %d", rr.Code)
+	}
+	delete(cookies, loginLinkCookieName)
+	rr = do(http.MethodPost, "/link", url.Values{"token": {token()}})
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "browser you requested it from") {
+		t.Errorf("expected link opened in another browser to be rejected, got %d: %s", rr.Code, rr.Body)
+	}
+	if got, _ := s.storage.GetAuthRequest(ctx, authReq.ID); got.LoggedIn {
+		t.Errorf("expected auth request not to be logged in")
+	}
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/dexidp/dex/pkg/audit"
+)
+
+// defaultEventQueueSize is the queue size of event consumers which don't
+// set one.
+const defaultEventQueueSize = 256
+
+// EventConsumer receives every audit event asynchronously, with its own
+// queue, so a slow consumer such as a webhook doesn't delay token issuance.
+// Events are dropped while the queue is full.
+type EventConsumer struct {
+	// Name identifies the consumer in logs and metrics.
+	Name string
+	Sink audit.Sink
+	// Defaults to 256.
+	QueueSize int
+}
+
+// eventMetrics counts audit events by type and severity, and the events
+// consumers dropped or failed to deliver.
+type eventMetrics struct {
+	events   *prometheus.CounterVec
+	dropped  *prometheus.CounterVec
+	failures *prometheus.CounterVec
+}
+
+func newEventMetrics(registry *prometheus.Registry) (*eventMetrics, error) {
+	m := &eventMetrics{
+		events: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "audit_events_total",
+			Help: "Count of audit events by type and severity.",
+		}, []string{"type", "severity"}),
+		dropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "audit_events_dropped_total",
+			Help: "Count of audit events dropped by event consumers that didn't keep up.",
+		}, []string{"consumer"}),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "audit_event_delivery_failures_total",
+			Help: "Count of audit events event consumers failed to deliver.",
+		}, []string{"consumer"}),
+	}
+	if registry != nil {
+		for _, c := range []prometheus.Collector{m.events, m.dropped, m.failures} {
+			if err := registry.Register(c); err != nil {
+				return nil, fmt.Errorf("register event metrics: %w", err)
+			}
+		}
+	}
+	return m, nil
+}
+
+// Emit implements audit.Sink.
+func (m *eventMetrics) Emit(ctx context.Context, e audit.Event) error {
+	m.events.WithLabelValues(e.Type, string(e.Severity)).Inc()
+	return nil
+}
+
+// eventStorageSink persists the events of the bus. User data erasures are
+// skipped, the API persists them itself even without a retention.
+type eventStorageSink struct {
+	storageSink
+}
+
+func (st eventStorageSink) Emit(ctx context.Context, e audit.Event) error {
+	if e.Type == audit.EventUserDataErased {
+		return nil
+	}
+	return st.storageSink.Emit(ctx, e)
+}
+
+// newEventBus returns the bus every audit event of the server is emitted to.
+// The audit sink, the storage, the event stream and metrics consume events
+// synchronously, the configured consumers asynchronously.
+func (s *Server) newEventBus(consumers []EventConsumer, registry *prometheus.Registry) (*audit.Bus, error) {
+	for _, c := range consumers {
+		if c.Name == "" || c.Sink == nil {
+			return nil, errors.New("event consumers require a name and sink")
+		}
+	}
+	metrics, err := newEventMetrics(registry)
+	if err != nil {
+		return nil, err
+	}
+	bus := audit.NewBus(s.logger)
+	bus.OnDrop = func(consumer string, e audit.Event) {
+		metrics.dropped.WithLabelValues(consumer).Inc()
+	}
+	bus.OnError = func(consumer string, e audit.Event, err error) {
+		metrics.failures.WithLabelValues(consumer).Inc()
+	}
+	bus.Subscribe("audit", s.audit)
+	if s.auditRetention > 0 {
+		bus.Subscribe("storage", eventStorageSink{storageSink{s.storage}})
+	}
+	bus.Subscribe("stream", s.auditStream)
+	bus.Subscribe("metrics", metrics)
+	for _, c := range consumers {
+		size := c.QueueSize
+		if size <= 0 {
+			size = defaultEventQueueSize
+		}
+		bus.SubscribeAsync(c.Name, c.Sink, size)
+	}
+	return bus, nil
+}
+
+// Events returns the sink audit events of the server are emitted to. Other
+// components, such as the gRPC API, can emit their events to it so they
+// reach the same consumers.
+func (s *Server) Events() audit.Sink {
+	return s.events
+}
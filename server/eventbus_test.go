@@ -0,0 +1,54 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/dexidp/dex/pkg/audit"
+)
+
+func TestEventConsumers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sink := new(recordingSink)
+	consumer := new(recordingSink)
+	registry := prometheus.NewRegistry()
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.AuditSink = sink
+		c.EventConsumers = []EventConsumer{{Name: "webhook", Sink: consumer}}
+		c.PrometheusRegistry = registry
+	})
+	defer httpServer.Close()
+
+	s.emitAudit(ctx, audit.Event{Type: audit.EventLogin, Severity: audit.SeverityInfo})
+	s.emitAudit(ctx, audit.Event{Type: audit.EventRefreshTokenReuse, Severity: audit.SeverityHigh})
+	s.events.Close()
+
+	if len(sink.events) != 2 || len(consumer.events) != 2 {
+		t.Errorf("expected both sinks to receive 2 events, got %d and %d", len(sink.events), len(consumer.events))
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var series int
+	for _, f := range families {
+		if f.GetName() == "audit_events_total" {
+			series = len(f.GetMetric())
+		}
+	}
+	if series != 2 {
+		t.Errorf("expected events of 2 types to be counted, got %d", series)
+	}
+}
+
+func TestEventConsumersRequireName(t *testing.T) {
+	s := &Server{}
+	if _, err := s.newEventBus([]EventConsumer{{Sink: new(recordingSink)}}, nil); err == nil {
+		t.Error("expected consumer without a name to be rejected")
+	}
+}
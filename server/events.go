@@ -0,0 +1,54 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// auditStreamBuffer is the number of events buffered for each stream before
+// a slow reader starts missing events.
+const auditStreamBuffer = 64
+
+// auditStreamKeepAlive is how often an idle stream sends a comment, so
+// proxies don't close the connection.
+const auditStreamKeepAlive = 30 * time.Second
+
+// handleAuditStream streams audit events as server-sent events, named by the
+// event type with the JSON encoded event as data.
+func (s *Server) handleAuditStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported.", http.StatusInternalServerError)
+		return
+	}
+	events, cancel := s.auditStream.Subscribe(auditStreamBuffer)
+	defer cancel()
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(auditStreamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case e := <-events:
+			data, err := json.Marshal(e)
+			if err != nil {
+				s.logger.Errorf("failed to marshal audit event: %v", err)
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+		}
+		flusher.Flush()
+	}
+}
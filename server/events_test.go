@@ -0,0 +1,62 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dexidp/dex/pkg/audit"
+)
+
+func TestAuditStream(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.AdminToken = "s3cret"
+		c.AuditSink = new(recordingSink)
+	})
+	defer httpServer.Close()
+	admin := httptest.NewServer(s.AdminHandler())
+	defer admin.Close()
+
+	req, err := http.NewRequest(http.MethodGet, admin.URL+"/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer s3cret")
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected an event stream, got content type %q", ct)
+	}
+
+	s.emitAudit(ctx, audit.Event{Type: audit.EventLogin, Severity: audit.SeverityInfo, ClientID: "app"})
+
+	r := bufio.NewReader(resp.Body)
+	var lines []string
+	for len(lines) < 2 {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read event: %v", err)
+		}
+		lines = append(lines, strings.TrimSpace(line))
+	}
+	if lines[0] != "event: "+audit.EventLogin {
+		t.Errorf("expected event name line, got %q", lines[0])
+	}
+	var e audit.Event
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &e); err != nil {
+		t.Fatalf("failed to decode event data %q: %v", lines[1], err)
+	}
+	if e.Type != audit.EventLogin || e.ClientID != "app" {
+		t.Errorf("unexpected event %+v", e)
+	}
+}
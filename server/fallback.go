@@ -0,0 +1,73 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/dexidp/dex/pkg/audit"
+	"github.com/dexidp/dex/storage"
+)
+
+// offerFallback is called when the connector connID failed to log a user in
+// because of an upstream error. If the connector has a fallback, the login
+// page is shown with only the fallback connector and a notice explaining
+// why, and offerFallback returns true. The auth request remembers the
+// failed connector, so logins through the fallback can be told apart.
+func (s *Server) offerFallback(w http.ResponseWriter, r *http.Request, authReq storage.AuthRequest, connID string) bool {
+	fallbackID, ok := s.connectorFallbacks[connID]
+	if !ok {
+		return false
+	}
+	ctx := r.Context()
+	primary, err := s.storage.GetConnector(ctx, connID)
+	if err != nil {
+		s.logger.Errorf("failed to get connector %q: %v", connID, err)
+		return false
+	}
+	fallback, err := s.storage.GetConnector(ctx, fallbackID)
+	if err != nil {
+		s.logger.Errorf("failed to get fallback connector %q of connector %q: %v", fallbackID, connID, err)
+		return false
+	}
+
+	updater := func(a storage.AuthRequest) (storage.AuthRequest, error) {
+		a.FallbackFrom = connID
+		return a, nil
+	}
+	if err := s.storage.UpdateAuthRequest(ctx, authReq.ID, updater); err != nil {
+		s.logger.Errorf("failed to set fallback connector on auth request: %v", err)
+		return false
+	}
+
+	s.logger.Infof("connector %q failed, offering fallback connector %q", connID, fallbackID)
+	s.emitAudit(ctx, audit.Event{
+		Type:        audit.EventConnectorFallback,
+		Severity:    audit.SeverityWarning,
+		ClientID:    authReq.ClientID,
+		ConnectorID: connID,
+		SourceIPs:   []string{remoteIP(r)},
+		Message:     fmt.Sprintf("offered fallback connector %q", fallbackID),
+	})
+
+	connectors := []connectorInfo{{
+		ID:   fallback.ID,
+		Name: fallback.Name,
+		Type: fallback.Type,
+		URL:  s.absPath("/auth", fallback.ID) + "?req=" + authReq.ID,
+	}}
+	notice := fmt.Sprintf("%s is unavailable right now. You can log in with %s instead.", primary.Name, fallback.Name)
+	if err := s.templates.login(r, w, connectors, notice, r.URL.Path); err != nil {
+		s.logger.Errorf("Server template error: %v", err)
+	}
+	return true
+}
+
+// fallbackMessage returns the message marking audit events of users who
+// logged in through the fallback of a connector which failed earlier during
+// the auth request, or an empty string.
+func (s *Server) fallbackMessage(authReq storage.AuthRequest) string {
+	if authReq.FallbackFrom == "" || s.connectorFallbacks[authReq.FallbackFrom] != authReq.ConnectorID {
+		return ""
+	}
+	return fmt.Sprintf("logged in through fallback for connector %q", authReq.FallbackFrom)
+}
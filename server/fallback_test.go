@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dexidp/dex/connector"
+	"github.com/dexidp/dex/pkg/audit"
+	"github.com/dexidp/dex/storage"
+)
+
+// unreachableConnector fails like a connector whose upstream identity
+// provider can't be reached.
+type unreachableConnector struct{}
+
+func (unreachableConnector) LoginURL(s connector.Scopes, callbackURL, state string) (string, error) {
+	return "", errors.New("dial tcp: connection refused")
+}
+
+func (unreachableConnector) HandleCallback(s connector.Scopes, r *http.Request) (connector.Identity, error) {
+	return connector.Identity{}, errors.New("dial tcp: connection refused")
+}
+
+func TestConnectorFallback(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sink := &recordingSink{}
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.AuditSink = sink
+		c.ConnectorFallbacks = map[string]string{"corp": "mock"}
+	})
+	defer httpServer.Close()
+
+	if err := s.storage.CreateConnector(ctx, storage.Connector{ID: "corp", Type: "mockCallback", Name: "Corporate SSO", ResourceVersion: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	s.connectors["corp"] = Connector{ResourceVersion: "1", Connector: unreachableConnector{}}
+
+	authReq := storage.AuthRequest{ID: "req1", ClientID: "app", Scopes: []string{"openid"}, Expiry: time.Now().Add(time.Hour)}
+	if err := s.storage.CreateAuthRequest(ctx, authReq); err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/auth/corp?req=req1", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected the fallback to be offered, got %d: %s", rr.Code, rr.Body)
+	}
+	body := rr.Body.String()
+	if !strings.Contains(body, "Corporate SSO is unavailable right now. You can log in with Mock instead.") {
+		t.Errorf("expected a notice about the fallback, got %s", body)
+	}
+	if !strings.Contains(body, "/auth/mock?req=req1") || strings.Contains(body, "/auth/corp?") {
+		t.Errorf("expected only the fallback connector to be offered, got %s", body)
+	}
+	if len(sink.events) != 1 || sink.events[0].Type != audit.EventConnectorFallback || sink.events[0].ConnectorID != "corp" {
+		t.Errorf("expected a connector fallback event, got %+v", sink.events)
+	}
+
+	got, err := s.storage.GetAuthRequest(ctx, "req1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.FallbackFrom != "corp" {
+		t.Errorf("expected auth request to remember the failed connector, got %q", got.FallbackFrom)
+	}
+	if msg := s.fallbackMessage(got); msg != "" {
+		t.Errorf("expected no marker before logging in through the fallback, got %q", msg)
+	}
+	got.ConnectorID = "mock"
+	if msg := s.fallbackMessage(got); msg == "" {
+		t.Errorf("expected logins through the fallback to be marked")
+	}
+
+	// Connectors without a fallback still fail with an error page.
+	s.connectorFallbacks = nil
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/auth/corp?req=req1", nil))
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("expected login error, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Feature is an experimental capability that is disabled unless listed in
+// the server's config.
+type Feature string
+
+// Feature flags known to dex.
+const (
+	FeatureDeviceFlow                  Feature = "device_flow"
+	FeatureTokenExchange               Feature = "token_exchange"
+	FeaturePushedAuthorizationRequests Feature = "pushed_authorization_requests"
+	FeatureCIBA                        Feature = "ciba"
+)
+
+// knownFeatures maps every feature flag to whether this version of dex
+// implements it. Enabling an unimplemented feature is a config error.
+var knownFeatures = map[Feature]bool{
+	FeatureDeviceFlow:                  false,
+	FeatureTokenExchange:               true,
+	FeaturePushedAuthorizationRequests: false,
+	FeatureCIBA:                        false,
+}
+
+// featureGrantTypes lists the grant types a feature adds to the token
+// endpoint. These are only advertised by discovery while the feature is
+// enabled.
+var featureGrantTypes = map[Feature]string{
+	FeatureDeviceFlow:    "urn:ietf:params:oauth:grant-type:device_code",
+	FeatureTokenExchange: "urn:ietf:params:oauth:grant-type:token-exchange",
+	FeatureCIBA:          "urn:openid:params:grant-type:ciba",
+}
+
+func newFeatureSet(enabled []Feature) (map[Feature]bool, error) {
+	set := make(map[Feature]bool, len(enabled))
+	for _, f := range enabled {
+		implemented, ok := knownFeatures[f]
+		if !ok {
+			return nil, fmt.Errorf("unknown feature %q", f)
+		}
+		if !implemented {
+			return nil, fmt.Errorf("feature %q is not supported by this version of dex", f)
+		}
+		set[f] = true
+	}
+	return set, nil
+}
+
+func (s *Server) featureEnabled(f Feature) bool {
+	return s.features[f]
+}
+
+// supportedGrantTypes returns the grant types advertised by discovery.
+func (s *Server) supportedGrantTypes() []string {
+	grantTypes := []string{grantTypeAuthorizationCode, grantTypeRefreshToken}
+	if s.passwordConnector != "" {
+		grantTypes = append(grantTypes, grantTypePassword)
+	}
+	if s.allowAPIKeys {
+		grantTypes = append(grantTypes, grantTypeAPIKey)
+	}
+	if s.allowServiceAccounts {
+		grantTypes = append(grantTypes, grantTypeJWTBearer)
+	}
+	if s.allowPreAuthCodes {
+		grantTypes = append(grantTypes, grantTypePreAuthorizedCode)
+	}
+	if s.spiffe != nil {
+		grantTypes = append(grantTypes, grantTypeClientCredentials)
+	}
+	if s.supportedResponseTypes[responseTypeToken] || s.supportedResponseTypes[responseTypeIDToken] {
+		grantTypes = append(grantTypes, "implicit")
+	}
+	for f, grantType := range featureGrantTypes {
+		if s.featureEnabled(f) {
+			grantTypes = append(grantTypes, grantType)
+		}
+	}
+	sort.Strings(grantTypes)
+	return grantTypes
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewFeatureSet(t *testing.T) {
+	if _, err := newFeatureSet([]Feature{"time_travel"}); err == nil {
+		t.Errorf("expected unknown feature to be rejected")
+	}
+	for f, implemented := range knownFeatures {
+		_, err := newFeatureSet([]Feature{f})
+		if implemented && err != nil {
+			t.Errorf("enable feature %q: %v", f, err)
+		}
+		if !implemented && err == nil {
+			t.Errorf("expected unimplemented feature %q to be rejected", f)
+		}
+	}
+}
+
+func TestSupportedGrantTypes(t *testing.T) {
+	s := &Server{supportedResponseTypes: map[string]bool{responseTypeCode: true}}
+	want := []string{grantTypeAuthorizationCode, grantTypeRefreshToken}
+	if got := s.supportedGrantTypes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected grant types %q, got %q", want, got)
+	}
+
+	s.passwordConnector = "local"
+	s.features = map[Feature]bool{FeatureTokenExchange: true}
+	s.allowAPIKeys = true
+	s.allowServiceAccounts = true
+	want = []string{grantTypeAuthorizationCode, grantTypePassword, grantTypeRefreshToken, grantTypeAPIKey, "urn:ietf:params:oauth:grant-type:jwt-bearer", "urn:ietf:params:oauth:grant-type:token-exchange"}
+	if got := s.supportedGrantTypes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected grant types %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/dexidp/dex/pkg/audit"
+	"github.com/dexidp/dex/storage"
+)
+
+// deviceFingerprint returns the hash of the device fingerprint supplied with
+// a token request, or an empty string if the client didn't supply one.
+//
+// Clients bind refresh tokens to a device by sending a stable identifier of
+// the device, or a hash of one, as "device_fingerprint" when the tokens are
+// issued. Only its hash is stored.
+func deviceFingerprint(r *http.Request) string {
+	fingerprint := r.PostFormValue("device_fingerprint")
+	if fingerprint == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(fingerprint))
+	return hex.EncodeToString(sum[:])
+}
+
+// checkDeviceFingerprint reports whether a refresh request may use the
+// refresh token. Requests for a token bound to a device must present that
+// device's fingerprint, other requests are reported to the audit sink.
+func (s *Server) checkDeviceFingerprint(r *http.Request, refresh storage.RefreshToken) bool {
+	if refresh.DeviceFingerprint == "" {
+		return true
+	}
+	got := deviceFingerprint(r)
+	if subtle.ConstantTimeCompare([]byte(got), []byte(refresh.DeviceFingerprint)) == 1 {
+		return true
+	}
+
+	message := "refresh token presented with a different device fingerprint"
+	if got == "" {
+		message = "refresh token presented without its device fingerprint"
+	}
+	s.emitAudit(r.Context(), audit.Event{
+		Type:        audit.EventDeviceFingerprintMismatch,
+		Severity:    audit.SeverityHigh,
+		ClientID:    refresh.ClientID,
+		Subject:     subjectFor(refresh.Claims.UserID, refresh.ConnectorID),
+		ConnectorID: refresh.ConnectorID,
+		SourceIPs:   []string{refresh.LastUsedIP, remoteIP(r)},
+		Message:     message,
+	})
+	return false
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dexidp/dex/pkg/audit"
+	"github.com/dexidp/dex/storage"
+)
+
+func TestDeviceFingerprintBinding(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sink := new(recordingSink)
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.AuditSink = sink
+	})
+	defer httpServer.Close()
+
+	client := storage.Client{
+		ID:           "testclient",
+		Secret:       "testclientsecret",
+		RedirectURIs: []string{"https://example.com/callback"},
+	}
+	if err := s.storage.CreateClient(ctx, client); err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	code := storage.AuthCode{
+		ID:          storage.NewID(),
+		ClientID:    client.ID,
+		RedirectURI: client.RedirectURIs[0],
+		Scopes:      []string{"openid", "offline_access"},
+		ConnectorID: "mock",
+		Claims:      storage.Claims{UserID: "1", Email: "jane.doe@example.com"},
+		Expiry:      time.Now().Add(time.Minute),
+	}
+	if err := s.storage.CreateAuthCode(ctx, code); err != nil {
+		t.Fatalf("failed to create auth code: %v", err)
+	}
+
+	var refreshToken string
+	request := func(form url.Values) int {
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, tokenRequest(client, "10.0.0.1:1234", form))
+		if rr.Code == http.StatusOK {
+			var resp struct {
+				RefreshToken string `json:"refresh_token"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode token response: %v", err)
+			}
+			refreshToken = resp.RefreshToken
+		}
+		return rr.Code
+	}
+	refresh := func(fingerprint string) int {
+		form := url.Values{"grant_type": {grantTypeRefreshToken}, "refresh_token": {refreshToken}}
+		if fingerprint != "" {
+			form.Set("device_fingerprint", fingerprint)
+		}
+		return request(form)
+	}
+
+	code1 := request(url.Values{
+		"grant_type":         {grantTypeAuthorizationCode},
+		"code":               {code.ID},
+		"redirect_uri":       {code.RedirectURI},
+		"device_fingerprint": {"laptop-1"},
+	})
+	if code1 != http.StatusOK || refreshToken == "" {
+		t.Fatalf("expected refresh token redeeming code, got status %d", code1)
+	}
+
+	if got := refresh("laptop-1"); got != http.StatusOK {
+		t.Fatalf("expected refresh from the bound device to succeed, got %d", got)
+	}
+	if got := refresh(""); got != http.StatusBadRequest {
+		t.Errorf("expected refresh without fingerprint to fail, got %d", got)
+	}
+	if got := refresh("laptop-2"); got != http.StatusBadRequest {
+		t.Errorf("expected refresh from another device to fail, got %d", got)
+	}
+	// Rejected requests don't consume the token.
+	if got := refresh("laptop-1"); got != http.StatusOK {
+		t.Errorf("expected refresh from the bound device to succeed, got %d", got)
+	}
+
+	if len(sink.events) != 2 {
+		t.Fatalf("expected two audit events, got %d", len(sink.events))
+	}
+	for _, e := range sink.events {
+		if e.Type != audit.EventDeviceFingerprintMismatch || e.Severity != audit.SeverityHigh || e.ClientID != client.ID {
+			t.Errorf("unexpected audit event %+v", e)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/dexidp/dex/connector"
+)
+
+// GroupFilter restricts the users a connector logs in by their groups, and
+// the groups issued for them. Groups are matched after claim transforms.
+type GroupFilter struct {
+	// If set, only the listed groups are kept in the groups claim, and users
+	// in none of them are rejected.
+	Allowed []string
+	// Users in any of the listed groups are rejected.
+	Denied []string
+	// Users missing any of the listed groups are rejected.
+	Required []string
+}
+
+// groupFilterError is returned for users rejected by the group filter of a
+// connector.
+type groupFilterError struct {
+	connID string
+	reason string
+}
+
+func (e *groupFilterError) Error() string {
+	return fmt.Sprintf("connector %q rejected user: %s", e.connID, e.reason)
+}
+
+// validateGroupFilters rejects filters with empty groups, and filters no
+// user can pass.
+func validateGroupFilters(filters map[string]GroupFilter) error {
+	for connID, f := range filters {
+		for _, groups := range [][]string{f.Allowed, f.Denied, f.Required} {
+			if contains(groups, "") {
+				return fmt.Errorf("group filter of connector %q: empty group", connID)
+			}
+		}
+		for _, g := range f.Required {
+			if contains(f.Denied, g) {
+				return fmt.Errorf("group filter of connector %q: group %q is both required and denied", connID, g)
+			}
+			if len(f.Allowed) > 0 && !contains(f.Allowed, g) {
+				return fmt.Errorf("group filter of connector %q: required group %q is not allowed", connID, g)
+			}
+		}
+	}
+	return nil
+}
+
+// filterGroups applies the group filter of the connector to an identity
+// returned by it. It returns a *groupFilterError if the user is rejected.
+func (s *Server) filterGroups(connID string, ident connector.Identity) (connector.Identity, error) {
+	f, ok := s.groupFilters[connID]
+	if !ok {
+		return ident, nil
+	}
+	for _, g := range f.Denied {
+		if contains(ident.Groups, g) {
+			return ident, &groupFilterError{connID, fmt.Sprintf("member of denied group %q", g)}
+		}
+	}
+	for _, g := range f.Required {
+		if !contains(ident.Groups, g) {
+			return ident, &groupFilterError{connID, fmt.Sprintf("not a member of required group %q", g)}
+		}
+	}
+	if len(f.Allowed) == 0 {
+		return ident, nil
+	}
+	var groups []string
+	for _, g := range ident.Groups {
+		if contains(f.Allowed, g) {
+			groups = append(groups, g)
+		}
+	}
+	if len(groups) == 0 {
+		return ident, &groupFilterError{connID, "not a member of any allowed group"}
+	}
+	ident.Groups = groups
+	return ident, nil
+}
+
+// connectorScopes returns the scopes to pass to the connector. Connectors
+// with a group filter are always asked for groups, since users can't be
+// checked otherwise. The groups claim is still only issued if requested.
+func (s *Server) connectorScopes(connID string, scopes []string) connector.Scopes {
+	parsed := parseScopes(scopes)
+	if _, ok := s.groupFilters[connID]; ok {
+		parsed.Groups = true
+	}
+	return parsed
+}
+
+// renderLoginError renders an error returned by finalizeLogin.
+func (s *Server) renderLoginError(r *http.Request, w http.ResponseWriter, err error) {
+	var filterErr *groupFilterError
+	if errors.As(err, &filterErr) {
+		s.logger.Infof("login rejected: %v", err)
+		s.renderError(r, w, http.StatusForbidden, "You're not a member of the groups allowed to log in.")
+		return
+	}
+	s.logger.Errorf("Failed to finalize login: %v", err)
+	s.renderError(r, w, http.StatusInternalServerError, "Login error.")
+}
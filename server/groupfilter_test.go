@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dexidp/dex/connector"
+	"github.com/dexidp/dex/storage"
+)
+
+func TestValidateGroupFilters(t *testing.T) {
+	invalid := []GroupFilter{
+		{Allowed: []string{""}},
+		{Denied: []string{"admins", ""}},
+		{Required: []string{"admins"}, Denied: []string{"admins"}},
+		{Required: []string{"admins"}, Allowed: []string{"developers"}},
+	}
+	for _, f := range invalid {
+		if err := validateGroupFilters(map[string]GroupFilter{"mock": f}); err == nil {
+			t.Errorf("expected error validating group filter %+v", f)
+		}
+	}
+	valid := GroupFilter{Allowed: []string{"admins", "developers"}, Denied: []string{"contractors"}, Required: []string{"developers"}}
+	if err := validateGroupFilters(map[string]GroupFilter{"mock": valid}); err != nil {
+		t.Errorf("validate group filter: %v", err)
+	}
+}
+
+func TestFilterGroups(t *testing.T) {
+	s := &Server{groupFilters: map[string]GroupFilter{
+		"ldap": {
+			Allowed:  []string{"admins", "developers"},
+			Denied:   []string{"contractors"},
+			Required: []string{"developers"},
+		},
+	}}
+
+	tests := []struct {
+		name       string
+		connID     string
+		groups     []string
+		wantGroups []string
+		wantErr    bool
+	}{
+		{"unfiltered connector", "github", []string{"contractors"}, []string{"contractors"}, false},
+		{"allowed", "ldap", []string{"developers", "printers"}, []string{"developers"}, false},
+		{"denied", "ldap", []string{"developers", "contractors"}, nil, true},
+		{"missing required", "ldap", []string{"admins"}, nil, true},
+		{"no groups", "ldap", nil, nil, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := s.filterGroups(tc.connID, connector.Identity{UserID: "1", Groups: tc.groups})
+			if tc.wantErr {
+				var filterErr *groupFilterError
+				if !errors.As(err, &filterErr) {
+					t.Fatalf("expected group filter error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("filter groups: %v", err)
+			}
+			if !reflect.DeepEqual(got.Groups, tc.wantGroups) {
+				t.Errorf("expected groups %v, got %v", tc.wantGroups, got.Groups)
+			}
+		})
+	}
+
+	if !s.connectorScopes("ldap", []string{"openid"}).Groups {
+		t.Errorf("expected groups to be requested from connectors with a group filter")
+	}
+	if s.connectorScopes("github", []string{"openid"}).Groups {
+		t.Errorf("expected groups not to be requested from connectors without a group filter")
+	}
+}
+
+func TestGroupFilterOnLogin(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.ConnectorGroupFilters = map[string]GroupFilter{"mock": {Denied: []string{"contractors"}}}
+	})
+	defer httpServer.Close()
+
+	authReq := storage.AuthRequest{
+		ID:          storage.NewID(),
+		ClientID:    "app",
+		ConnectorID: "mock",
+		Expiry:      time.Now().Add(time.Minute),
+	}
+	if err := s.storage.CreateAuthRequest(ctx, authReq); err != nil {
+		t.Fatalf("create auth request: %v", err)
+	}
+	conn, err := s.getConnector(ctx, "mock")
+	if err != nil {
+		t.Fatalf("get connector: %v", err)
+	}
+	ident := connector.Identity{UserID: "1", Groups: []string{"authors", "contractors"}}
+	_, err = s.finalizeLogin(ctx, ident, authReq, conn.Connector)
+	if err == nil {
+		t.Fatalf("expected login of a denied user to fail")
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/callback", nil)
+	w := httptest.NewRecorder()
+	s.renderLoginError(r, w, err)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status %d, got %d", http.StatusForbidden, w.Code)
+	}
+
+	authReq, err = s.storage.GetAuthRequest(ctx, authReq.ID)
+	if err != nil {
+		t.Fatalf("get auth request: %v", err)
+	}
+	if authReq.LoggedIn {
+		t.Errorf("expected auth request of a denied user not to be logged in")
+	}
+}
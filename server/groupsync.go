@@ -0,0 +1,201 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dexidp/dex/connector"
+	"github.com/dexidp/dex/pkg/alert"
+	"github.com/dexidp/dex/pkg/log"
+	"github.com/dexidp/dex/storage"
+)
+
+// GroupSync periodically refreshes the identities of users with refresh
+// tokens from their connectors, so the claims stored with long-lived refresh
+// tokens don't go stale between refreshes by the client.
+//
+// Only connectors implementing connector.RefreshConnector are synced.
+// Connectors which rotate upstream refresh tokens may fail a refresh by the
+// client that races with a sync of the same user.
+type GroupSync struct {
+	// How often to sync. The sync is disabled if zero.
+	Interval time.Duration
+
+	// IDs of the connectors to sync. If empty, all connectors able to
+	// refresh identities are synced.
+	Connectors []string
+}
+
+// startGroupSync syncs identities at every interval in a new goroutine until
+// the context is canceled.
+func (s *Server) startGroupSync(ctx context.Context, c GroupSync) {
+	if c.Interval == 0 {
+		return
+	}
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(c.Interval):
+				if n, err := s.syncGroups(ctx, c.Connectors); err != nil {
+					s.logger.Errorf("group sync failed: %v", err)
+				} else if n > 0 {
+					s.logger.Infof("group sync updated %d identities", n)
+				}
+			}
+		}
+	}()
+}
+
+type offlineSessionKey struct {
+	userID, connID string
+}
+
+// syncGroups refreshes the identity of every user with refresh tokens issued
+// through one of connIDs, or any connector if empty. It returns the number
+// of identities whose stored claims changed.
+func (s *Server) syncGroups(ctx context.Context, connIDs []string) (int, error) {
+	refreshTokens, err := s.storage.ListRefreshTokens(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("list refresh tokens: %w", err)
+	}
+	sessions := make(map[offlineSessionKey][]storage.RefreshToken)
+	var keys []offlineSessionKey
+	for _, r := range refreshTokens {
+		if len(connIDs) > 0 && !contains(connIDs, r.ConnectorID) {
+			continue
+		}
+		key := offlineSessionKey{r.Claims.UserID, r.ConnectorID}
+		if _, ok := sessions[key]; !ok {
+			keys = append(keys, key)
+		}
+		sessions[key] = append(sessions[key], r)
+	}
+
+	var n int
+	for _, key := range keys {
+		if ctx.Err() != nil {
+			return n, ctx.Err()
+		}
+		changed, err := s.syncIdentity(ctx, key, sessions[key])
+		if err != nil {
+			s.logger.Errorf("group sync of user %q of connector %q failed: %v", log.Subject(key.userID), key.connID, err)
+			continue
+		}
+		if changed {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// syncIdentity refreshes the identity of a user from the connector and
+// updates the claims of the user's refresh tokens.
+func (s *Server) syncIdentity(ctx context.Context, key offlineSessionKey, refreshTokens []storage.RefreshToken) (changed bool, err error) {
+	conn, err := s.getConnector(ctx, key.connID)
+	if err != nil {
+		return false, fmt.Errorf("get connector: %w", err)
+	}
+	refreshConn, ok := conn.Connector.(connector.RefreshConnector)
+	if !ok {
+		return false, nil
+	}
+
+	// The most recently used refresh token holds the freshest claims.
+	latest := refreshTokens[0]
+	for _, r := range refreshTokens[1:] {
+		if r.LastUsed.After(latest.LastUsed) {
+			latest = r
+		}
+	}
+	if len(latest.ConnectorData) > 0 {
+		// Refresh tokens of older versions keep the connector data
+		// themselves. Leave them to the next refresh by the client, which
+		// moves the data to the offline session.
+		return false, nil
+	}
+	var connectorData []byte
+	if session, err := s.storage.GetOfflineSessions(ctx, key.userID, key.connID); err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			return false, fmt.Errorf("get offline session: %w", err)
+		}
+	} else {
+		connectorData = session.ConnectorData
+	}
+
+	ident := connector.Identity{
+		UserID:            latest.Claims.UserID,
+		Username:          latest.Claims.Username,
+		PreferredUsername: latest.Claims.PreferredUsername,
+		Email:             latest.Claims.Email,
+		EmailVerified:     latest.Claims.EmailVerified,
+		Groups:            latest.Claims.Groups,
+		ConnectorData:     connectorData,
+	}
+	start := time.Now()
+	newIdent, err := refreshConn.Refresh(ctx, connector.Scopes{OfflineAccess: true, Groups: true}, ident)
+	s.connectorMetrics.observe(key.connID, connectorOpRefresh, connectorOutcome(true, err), start)
+	if err != nil {
+		s.reportFailure(alert.KindConnector, key.connID, err)
+		return false, fmt.Errorf("refresh identity: %w", err)
+	}
+	newIdent, err = s.filterGroups(key.connID, s.transformIdentity(key.connID, newIdent))
+	if err != nil {
+		return false, err
+	}
+
+	refreshedAt := s.now()
+	err = s.storage.UpdateOfflineSessions(ctx, key.userID, key.connID, func(old storage.OfflineSessions) (storage.OfflineSessions, error) {
+		// Don't overwrite data stored by a concurrent refresh.
+		if bytes.Equal(old.ConnectorData, connectorData) {
+			old.ConnectorData = newIdent.ConnectorData
+		}
+		for _, r := range refreshTokens {
+			if ref := old.Refresh[r.ClientID]; ref != nil && ref.ID == r.ID {
+				ref.IdentityRefreshedAt = refreshedAt
+			}
+		}
+		return old, nil
+	})
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return false, fmt.Errorf("update offline session: %w", err)
+	}
+
+	for _, r := range refreshTokens {
+		if !claimsChanged(r.Claims, newIdent) {
+			continue
+		}
+		changed = true
+		err := s.storage.UpdateRefreshToken(ctx, r.ID, func(old storage.RefreshToken) (storage.RefreshToken, error) {
+			// UserID intentionally ignored, like when refreshing.
+			old.Claims.Username = newIdent.Username
+			old.Claims.PreferredUsername = newIdent.PreferredUsername
+			old.Claims.Email = newIdent.Email
+			old.Claims.EmailVerified = newIdent.EmailVerified
+			old.Claims.Groups = newIdent.Groups
+			return old, nil
+		})
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return changed, fmt.Errorf("update refresh token: %w", err)
+		}
+	}
+	return changed, nil
+}
+
+func claimsChanged(claims storage.Claims, ident connector.Identity) bool {
+	if claims.Username != ident.Username || claims.PreferredUsername != ident.PreferredUsername ||
+		claims.Email != ident.Email || claims.EmailVerified != ident.EmailVerified ||
+		len(claims.Groups) != len(ident.Groups) {
+		return true
+	}
+	for i, g := range claims.Groups {
+		if ident.Groups[i] != g {
+			return true
+		}
+	}
+	return false
+}
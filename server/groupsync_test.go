@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dexidp/dex/storage"
+)
+
+func TestSyncGroups(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, nil)
+	defer httpServer.Close()
+
+	stale := storage.Claims{
+		UserID:        "0-385-28089-0",
+		Username:      "Kilgore Trout",
+		Email:         "kilgore@kilgore.trout",
+		EmailVerified: true,
+		Groups:        []string{"readers"},
+	}
+	for _, clientID := range []string{"app", "cli"} {
+		refresh := storage.RefreshToken{
+			ID:          "refresh-" + clientID,
+			Token:       "token",
+			ClientID:    clientID,
+			ConnectorID: "mock",
+			Claims:      stale,
+			CreatedAt:   time.Now(),
+			LastUsed:    time.Now(),
+		}
+		if err := s.storage.CreateRefresh(ctx, refresh); err != nil {
+			t.Fatalf("create refresh token: %v", err)
+		}
+	}
+	session := storage.OfflineSessions{
+		UserID:        stale.UserID,
+		ConnID:        "mock",
+		Refresh:       map[string]*storage.RefreshTokenRef{},
+		ConnectorData: []byte(`{"old": true}`),
+	}
+	if err := s.storage.CreateOfflineSessions(ctx, session); err != nil {
+		t.Fatalf("create offline session: %v", err)
+	}
+
+	if n, err := s.syncGroups(ctx, []string{"other"}); err != nil || n != 0 {
+		t.Errorf("expected other connectors not to be synced, got %d, %v", n, err)
+	}
+	n, err := s.syncGroups(ctx, nil)
+	if err != nil {
+		t.Fatalf("sync groups: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 identity to be updated, got %d", n)
+	}
+	for _, id := range []string{"refresh-app", "refresh-cli"} {
+		refresh, err := s.storage.GetRefresh(ctx, id)
+		if err != nil {
+			t.Fatalf("get refresh token: %v", err)
+		}
+		if len(refresh.Claims.Groups) != 1 || refresh.Claims.Groups[0] != "authors" {
+			t.Errorf("expected groups of %s to be synced, got %v", id, refresh.Claims.Groups)
+		}
+		if refresh.Token != "token" {
+			t.Errorf("expected token of %s not to be rotated", id)
+		}
+	}
+	if session, err := s.storage.GetOfflineSessions(ctx, stale.UserID, "mock"); err != nil {
+		t.Errorf("get offline session: %v", err)
+	} else if string(session.ConnectorData) != "foobar" {
+		t.Errorf("expected connector data to be updated, got %s", session.ConnectorData)
+	}
+
+	if n, err := s.syncGroups(ctx, nil); err != nil || n != 0 {
+		t.Errorf("expected synced identities not to change again, got %d, %v", n, err)
+	}
+}
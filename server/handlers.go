@@ -14,11 +14,14 @@ import (
 	"sync"
 	"time"
 
-	oidc "github.com/coreos/go-oidc"
+	"github.com/felixge/httpsnoop"
 	"github.com/gorilla/mux"
 	jose "gopkg.in/square/go-jose.v2"
 
 	"github.com/dexidp/dex/connector"
+	"github.com/dexidp/dex/pkg/alert"
+	"github.com/dexidp/dex/pkg/audit"
+	"github.com/dexidp/dex/pkg/log"
 	"github.com/dexidp/dex/server/internal"
 	"github.com/dexidp/dex/storage"
 )
@@ -66,6 +69,7 @@ func (h *healthChecker) runHealthCheck() {
 	passed := h.s.now().Sub(t)
 	if err != nil {
 		h.s.logger.Errorf("Storage health check failed: %v", err)
+		h.s.reportFailure(alert.KindStorage, "", err)
 	}
 
 	// Make sure to only hold the mutex to access the fields, and not while
@@ -77,6 +81,7 @@ func (h *healthChecker) runHealthCheck() {
 }
 
 func checkStorageHealth(s storage.Storage, now func() time.Time) error {
+	ctx := context.Background()
 	a := storage.AuthRequest{
 		ID:       storage.NewID(),
 		ClientID: storage.NewID(),
@@ -85,11 +90,11 @@ func checkStorageHealth(s storage.Storage, now func() time.Time) error {
 		Expiry: now().Add(time.Minute),
 	}
 
-	if err := s.CreateAuthRequest(a); err != nil {
-		return fmt.Errorf("create auth request: %v", err)
+	if err := s.CreateAuthRequest(ctx, a); err != nil {
+		return fmt.Errorf("create auth request: %w", err)
 	}
-	if err := s.DeleteAuthRequest(a.ID); err != nil {
-		return fmt.Errorf("delete auth request: %v", err)
+	if err := s.DeleteAuthRequest(ctx, a.ID); err != nil {
+		return fmt.Errorf("delete auth request: %w", err)
 	}
 	return nil
 }
@@ -108,8 +113,9 @@ func (h *healthChecker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handlePublicKeys(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
 	// TODO(ericchiang): Cache this.
-	keys, err := s.storage.GetKeys()
+	keys, err := s.storage.GetKeys(ctx)
 	if err != nil {
 		s.logger.Errorf("failed to get keys: %v", err)
 		s.renderError(r, w, http.StatusInternalServerError, "Internal server error.")
@@ -129,6 +135,9 @@ func (s *Server) handlePublicKeys(w http.ResponseWriter, r *http.Request) {
 	for i, verificationKey := range keys.VerificationKeys {
 		jwks.Keys[i+1] = *verificationKey.PublicKey
 	}
+	if s.signingMigration != nil {
+		jwks.Keys = append(jwks.Keys, *s.signingMigration.signer.PublicKey())
+	}
 
 	data, err := json.MarshalIndent(jwks, "", "  ")
 	if err != nil {
@@ -142,40 +151,52 @@ func (s *Server) handlePublicKeys(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d, must-revalidate", int(maxAge.Seconds())))
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
-	w.Write(data)
+	serveConditionalJSON(w, r, data, s.keysModified.observe(contentETag(data), s.now()))
 }
 
 type discovery struct {
-	Issuer        string   `json:"issuer"`
-	Auth          string   `json:"authorization_endpoint"`
-	Token         string   `json:"token_endpoint"`
-	Keys          string   `json:"jwks_uri"`
-	UserInfo      string   `json:"userinfo_endpoint"`
-	ResponseTypes []string `json:"response_types_supported"`
-	Subjects      []string `json:"subject_types_supported"`
-	IDTokenAlgs   []string `json:"id_token_signing_alg_values_supported"`
-	Scopes        []string `json:"scopes_supported"`
-	AuthMethods   []string `json:"token_endpoint_auth_methods_supported"`
-	Claims        []string `json:"claims_supported"`
+	Issuer         string   `json:"issuer"`
+	Auth           string   `json:"authorization_endpoint"`
+	Token          string   `json:"token_endpoint"`
+	Keys           string   `json:"jwks_uri"`
+	UserInfo       string   `json:"userinfo_endpoint"`
+	Introspection  string   `json:"introspection_endpoint"`
+	EndSession     string   `json:"end_session_endpoint"`
+	Backchannel    bool     `json:"backchannel_logout_supported"`
+	BackchannelSID bool     `json:"backchannel_logout_session_supported"`
+	ResponseTypes  []string `json:"response_types_supported"`
+	Subjects       []string `json:"subject_types_supported"`
+	IDTokenAlgs    []string `json:"id_token_signing_alg_values_supported"`
+	GrantTypes     []string `json:"grant_types_supported"`
+	Scopes         []string `json:"scopes_supported"`
+	AuthMethods    []string `json:"token_endpoint_auth_methods_supported"`
+	Claims         []string `json:"claims_supported"`
+	CodeChallenge  []string `json:"code_challenge_methods_supported"`
 }
 
 func (s *Server) discoveryHandler() (http.HandlerFunc, error) {
 	d := discovery{
-		Issuer:      s.issuerURL.String(),
-		Auth:        s.absURL("/auth"),
-		Token:       s.absURL("/token"),
-		Keys:        s.absURL("/keys"),
-		UserInfo:    s.absURL("/userinfo"),
-		Subjects:    []string{"public"},
-		IDTokenAlgs: []string{string(jose.RS256)},
-		Scopes:      []string{"openid", "email", "groups", "profile", "offline_access"},
-		AuthMethods: []string{"client_secret_basic"},
+		Issuer:        s.issuerURL.String(),
+		Auth:          s.absURL("/auth"),
+		Token:         s.absURL("/token"),
+		Keys:          s.absURL("/keys"),
+		UserInfo:      s.absURL("/userinfo"),
+		Introspection: s.absURL("/token/introspect"),
+		EndSession:    s.absURL("/logout"),
+		// Logout tokens are sent when sessions end. They name the user, not
+		// the session, since ID tokens carry no session ID.
+		Backchannel:    s.sessionsValidFor != 0,
+		BackchannelSID: false,
+		Subjects:       []string{"public"},
+		IDTokenAlgs:    s.idTokenAlgs(),
+		GrantTypes:     s.supportedGrantTypes(),
+		Scopes:         s.supportedScopes(),
+		AuthMethods:    []string{"client_secret_basic", "none"},
 		Claims: []string{
 			"aud", "email", "email_verified", "exp",
 			"iat", "iss", "locale", "name", "sub",
 		},
+		CodeChallenge: codeChallengeMethods,
 	}
 
 	for responseType := range s.supportedResponseTypes {
@@ -185,18 +206,22 @@ func (s *Server) discoveryHandler() (http.HandlerFunc, error) {
 
 	data, err := json.MarshalIndent(d, "", "  ")
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal discovery data: %v", err)
+		return nil, fmt.Errorf("failed to marshal discovery data: %w", err)
+	}
+	if data, err = applyDiscoveryOverrides(data, s.discoveryOverrides); err != nil {
+		return nil, fmt.Errorf("server: %w", err)
 	}
 
+	// The document only changes when the server restarts.
+	modified := s.now()
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
-		w.Write(data)
+		serveConditionalJSON(w, r, data, modified)
 	}), nil
 }
 
 // handleAuthorization handles the OAuth2 auth endpoint.
 func (s *Server) handleAuthorization(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
 	authReq, err := s.parseAuthorizationRequest(r)
 	if err != nil {
 		s.logger.Errorf("Failed to parse authorization request: %v", err)
@@ -224,13 +249,17 @@ func (s *Server) handleAuthorization(w http.ResponseWriter, r *http.Request) {
 	//
 	// See: https://github.com/dexidp/dex/issues/646
 	authReq.Expiry = s.now().Add(s.authRequestsValidFor)
-	if err := s.storage.CreateAuthRequest(*authReq); err != nil {
+	if err := s.storage.CreateAuthRequest(ctx, *authReq); err != nil {
 		s.logger.Errorf("Failed to create authorization request: %v", err)
 		s.renderError(r, w, http.StatusInternalServerError, "Failed to connect to the database.")
 		return
 	}
 
-	connectors, err := s.storage.ListConnectors()
+	if s.resumeSession(w, r, *authReq) {
+		return
+	}
+
+	connectors, err := s.storage.ListConnectors(ctx)
 	if err != nil {
 		s.logger.Errorf("Failed to get list of connectors: %v", err)
 		s.renderError(r, w, http.StatusInternalServerError, "Failed to retrieve connector list.")
@@ -270,14 +299,15 @@ func (s *Server) handleAuthorization(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if err := s.templates.login(r, w, connectorInfos, r.URL.Path); err != nil {
+	if err := s.templates.login(r, w, connectorInfos, "", r.URL.Path); err != nil {
 		s.logger.Errorf("Server template error: %v", err)
 	}
 }
 
 func (s *Server) handleConnectorLogin(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
 	connID := mux.Vars(r)["connector"]
-	conn, err := s.getConnector(connID)
+	conn, err := s.getConnector(ctx, connID)
 	if err != nil {
 		s.logger.Errorf("Failed to create authorization request: %v", err)
 		s.renderError(r, w, http.StatusBadRequest, "Requested resource does not exist")
@@ -286,10 +316,10 @@ func (s *Server) handleConnectorLogin(w http.ResponseWriter, r *http.Request) {
 
 	authReqID := r.FormValue("req")
 
-	authReq, err := s.storage.GetAuthRequest(authReqID)
+	authReq, err := s.storage.GetAuthRequest(ctx, authReqID)
 	if err != nil {
 		s.logger.Errorf("Failed to get auth request: %v", err)
-		if err == storage.ErrNotFound {
+		if errors.Is(err, storage.ErrNotFound) {
 			s.renderError(r, w, http.StatusBadRequest, "Login session expired.")
 		} else {
 			s.renderError(r, w, http.StatusInternalServerError, "Database error.")
@@ -303,14 +333,14 @@ func (s *Server) handleConnectorLogin(w http.ResponseWriter, r *http.Request) {
 			a.ConnectorID = connID
 			return a, nil
 		}
-		if err := s.storage.UpdateAuthRequest(authReqID, updater); err != nil {
+		if err := s.storage.UpdateAuthRequest(ctx, authReqID, updater); err != nil {
 			s.logger.Errorf("Failed to set connector ID on auth request: %v", err)
 			s.renderError(r, w, http.StatusInternalServerError, "Database error.")
 			return
 		}
 	}
 
-	scopes := parseScopes(authReq.Scopes)
+	scopes := s.connectorScopes(connID, authReq.Scopes)
 	showBacklink := len(s.connectors) > 1
 
 	switch r.Method {
@@ -323,6 +353,10 @@ func (s *Server) handleConnectorLogin(w http.ResponseWriter, r *http.Request) {
 			callbackURL, err := conn.LoginURL(scopes, s.absURL("/callback"), authReqID)
 			if err != nil {
 				s.logger.Errorf("Connector %q returned error when creating callback: %v", connID, err)
+				s.reportFailure(alert.KindConnector, connID, err)
+				if s.offerFallback(w, r, authReq, connID) {
+					return
+				}
 				s.renderError(r, w, http.StatusInternalServerError, "Login error.")
 				return
 			}
@@ -331,10 +365,17 @@ func (s *Server) handleConnectorLogin(w http.ResponseWriter, r *http.Request) {
 			if err := s.templates.password(r, w, r.URL.String(), "", usernamePrompt(conn), false, showBacklink, r.URL.Path); err != nil {
 				s.logger.Errorf("Server template error: %v", err)
 			}
+		case connector.LinkConnector:
+			if err := s.templates.emailLink(r, w, emailLinkPage{PostURL: r.URL.String(), BackLink: showBacklink}); err != nil {
+				s.logger.Errorf("Server template error: %v", err)
+			}
 		case connector.SAMLConnector:
 			action, value, err := conn.POSTData(scopes, authReqID)
 			if err != nil {
 				s.logger.Errorf("Creating SAML data: %v", err)
+				if s.offerFallback(w, r, authReq, connID) {
+					return
+				}
 				s.renderError(r, w, http.StatusInternalServerError, "Connector Login Error")
 				return
 			}
@@ -360,6 +401,10 @@ func (s *Server) handleConnectorLogin(w http.ResponseWriter, r *http.Request) {
 			s.renderError(r, w, http.StatusBadRequest, "Requested resource does not exist.")
 		}
 	case http.MethodPost:
+		if linkConnector, ok := conn.Connector.(connector.LinkConnector); ok {
+			s.sendLoginLink(w, r, authReq, connID, linkConnector, showBacklink)
+			return
+		}
 		passwordConnector, ok := conn.Connector.(connector.PasswordConnector)
 		if !ok {
 			s.renderError(r, w, http.StatusBadRequest, "Requested resource does not exist.")
@@ -369,24 +414,31 @@ func (s *Server) handleConnectorLogin(w http.ResponseWriter, r *http.Request) {
 		username := r.FormValue("login")
 		password := r.FormValue("password")
 
+		start := time.Now()
 		identity, ok, err := passwordConnector.Login(r.Context(), scopes, username, password)
+		s.connectorMetrics.observe(connID, connectorOpLogin, connectorOutcome(ok, err), start)
 		if err != nil {
 			s.logger.Errorf("Failed to login user: %v", err)
+			s.reportFailure(alert.KindConnector, connID, err)
+			if s.offerFallback(w, r, authReq, connID) {
+				return
+			}
 			s.renderError(r, w, http.StatusInternalServerError, fmt.Sprintf("Login error: %v", err))
 			return
 		}
 		if !ok {
+			s.delayFailure(ctx)
 			if err := s.templates.password(r, w, r.URL.String(), username, usernamePrompt(passwordConnector), true, showBacklink, r.URL.Path); err != nil {
 				s.logger.Errorf("Server template error: %v", err)
 			}
 			return
 		}
-		redirectURL, err := s.finalizeLogin(identity, authReq, conn.Connector)
+		redirectURL, err := s.finalizeLogin(ctx, identity, authReq, conn.Connector)
 		if err != nil {
-			s.logger.Errorf("Failed to finalize login: %v", err)
-			s.renderError(r, w, http.StatusInternalServerError, "Login error.")
+			s.renderLoginError(r, w, err)
 			return
 		}
+		s.startSession(ctx, w, authReq.ClientID, connID, identity)
 
 		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
 	default:
@@ -395,6 +447,7 @@ func (s *Server) handleConnectorLogin(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleConnectorCallback(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
 	var authID string
 	switch r.Method {
 	case http.MethodGet: // OAuth2 callback
@@ -412,9 +465,9 @@ func (s *Server) handleConnectorCallback(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	authReq, err := s.storage.GetAuthRequest(authID)
+	authReq, err := s.storage.GetAuthRequest(ctx, authID)
 	if err != nil {
-		if err == storage.ErrNotFound {
+		if errors.Is(err, storage.ErrNotFound) {
 			s.logger.Errorf("Invalid 'state' parameter provided: %v", err)
 			s.renderError(r, w, http.StatusBadRequest, "Requested resource does not exist.")
 			return
@@ -430,7 +483,7 @@ func (s *Server) handleConnectorCallback(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	conn, err := s.getConnector(authReq.ConnectorID)
+	conn, err := s.getConnector(ctx, authReq.ConnectorID)
 	if err != nil {
 		s.logger.Errorf("Failed to get connector with id %q : %v", authReq.ConnectorID, err)
 		s.renderError(r, w, http.StatusInternalServerError, "Requested resource does not exist.")
@@ -438,6 +491,7 @@ func (s *Server) handleConnectorCallback(w http.ResponseWriter, r *http.Request)
 	}
 
 	var identity connector.Identity
+	start := time.Now()
 	switch conn := conn.Connector.(type) {
 	case connector.CallbackConnector:
 		if r.Method != http.MethodGet {
@@ -445,38 +499,47 @@ func (s *Server) handleConnectorCallback(w http.ResponseWriter, r *http.Request)
 			s.renderError(r, w, http.StatusBadRequest, "Invalid request")
 			return
 		}
-		identity, err = conn.HandleCallback(parseScopes(authReq.Scopes), r)
+		identity, err = conn.HandleCallback(s.connectorScopes(authReq.ConnectorID, authReq.Scopes), r)
 	case connector.SAMLConnector:
 		if r.Method != http.MethodPost {
 			s.logger.Errorf("OAuth2 request mapped to SAML connector")
 			s.renderError(r, w, http.StatusBadRequest, "Invalid request")
 			return
 		}
-		identity, err = conn.HandlePOST(parseScopes(authReq.Scopes), r.PostFormValue("SAMLResponse"), authReq.ID)
+		identity, err = conn.HandlePOST(s.connectorScopes(authReq.ConnectorID, authReq.Scopes), r.PostFormValue("SAMLResponse"), authReq.ID)
 	default:
 		s.renderError(r, w, http.StatusInternalServerError, "Requested resource does not exist.")
 		return
 	}
+	s.connectorMetrics.observe(authReq.ConnectorID, connectorOpCallback, connectorOutcome(true, err), start)
 
 	if err != nil {
 		s.logger.Errorf("Failed to authenticate: %v", err)
+		s.reportFailure(alert.KindConnector, authReq.ConnectorID, err)
+		if s.offerFallback(w, r, authReq, authReq.ConnectorID) {
+			return
+		}
 		s.renderError(r, w, http.StatusInternalServerError, fmt.Sprintf("Failed to authenticate: %v", err))
 		return
 	}
 
-	redirectURL, err := s.finalizeLogin(identity, authReq, conn.Connector)
+	redirectURL, err := s.finalizeLogin(ctx, identity, authReq, conn.Connector)
 	if err != nil {
-		s.logger.Errorf("Failed to finalize login: %v", err)
-		s.renderError(r, w, http.StatusInternalServerError, "Login error.")
+		s.renderLoginError(r, w, err)
 		return
 	}
+	s.startSession(ctx, w, authReq.ClientID, authReq.ConnectorID, identity)
 
 	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
 }
 
 // finalizeLogin associates the user's identity with the current AuthRequest, then returns
 // the approval page's path.
-func (s *Server) finalizeLogin(identity connector.Identity, authReq storage.AuthRequest, conn connector.Connector) (string, error) {
+func (s *Server) finalizeLogin(ctx context.Context, identity connector.Identity, authReq storage.AuthRequest, conn connector.Connector) (string, error) {
+	identity, err := s.filterGroups(authReq.ConnectorID, s.transformIdentity(authReq.ConnectorID, identity))
+	if err != nil {
+		return "", err
+	}
 	claims := storage.Claims{
 		UserID:            identity.UserID,
 		Username:          identity.Username,
@@ -490,19 +553,32 @@ func (s *Server) finalizeLogin(identity connector.Identity, authReq storage.Auth
 		a.LoggedIn = true
 		a.Claims = claims
 		a.ConnectorData = identity.ConnectorData
+		// Set along with the identity, so the request can't be approved
+		// before the second factor is asked for.
+		a.SecondFactor = s.secondFactor(authReq.ConnectorID)
+		a.SecondFactorChallenge = nil
 		return a, nil
 	}
-	if err := s.storage.UpdateAuthRequest(authReq.ID, updater); err != nil {
-		return "", fmt.Errorf("failed to update auth request: %v", err)
+	if err := s.storage.UpdateAuthRequest(ctx, authReq.ID, updater); err != nil {
+		return "", fmt.Errorf("failed to update auth request: %w", err)
 	}
 
-	email := claims.Email
+	unverified := ""
 	if !claims.EmailVerified {
-		email = email + " (unverified)"
+		unverified = " (unverified)"
 	}
 
-	s.logger.Infof("login successful: connector %q, username=%q, preferred_username=%q, email=%q, groups=%q",
-		authReq.ConnectorID, claims.Username, claims.PreferredUsername, email, claims.Groups)
+	s.logger.Infof("login successful: connector %q, username=%q, preferred_username=%q, email=%q%s, groups=%q",
+		authReq.ConnectorID, log.Username(claims.Username), log.Username(claims.PreferredUsername),
+		log.Email(claims.Email), unverified, claims.Groups)
+	s.emitAudit(ctx, audit.Event{
+		Type:        audit.EventLogin,
+		Severity:    audit.SeverityInfo,
+		ClientID:    authReq.ClientID,
+		Subject:     subjectFor(claims.UserID, authReq.ConnectorID),
+		ConnectorID: authReq.ConnectorID,
+		Message:     s.fallbackMessage(authReq),
+	})
 
 	returnURL := path.Join(s.issuerURL.Path, "/approval") + "?req=" + authReq.ID
 	_, ok := conn.(connector.RefreshConnector)
@@ -511,8 +587,8 @@ func (s *Server) finalizeLogin(identity connector.Identity, authReq storage.Auth
 	}
 
 	// Try to retrieve an existing OfflineSession object for the corresponding user.
-	if session, err := s.storage.GetOfflineSessions(identity.UserID, authReq.ConnectorID); err != nil {
-		if err != storage.ErrNotFound {
+	if session, err := s.storage.GetOfflineSessions(ctx, identity.UserID, authReq.ConnectorID); err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
 			s.logger.Errorf("failed to get offline session: %v", err)
 			return "", err
 		}
@@ -525,13 +601,13 @@ func (s *Server) finalizeLogin(identity connector.Identity, authReq storage.Auth
 
 		// Create a new OfflineSession object for the user and add a reference object for
 		// the newly received refreshtoken.
-		if err := s.storage.CreateOfflineSessions(offlineSessions); err != nil {
+		if err := s.storage.CreateOfflineSessions(ctx, offlineSessions); err != nil {
 			s.logger.Errorf("failed to create offline session: %v", err)
 			return "", err
 		}
 	} else {
 		// Update existing OfflineSession obj with new RefreshTokenRef.
-		if err := s.storage.UpdateOfflineSessions(session.UserID, session.ConnID, func(old storage.OfflineSessions) (storage.OfflineSessions, error) {
+		if err := s.storage.UpdateOfflineSessions(ctx, session.UserID, session.ConnID, func(old storage.OfflineSessions) (storage.OfflineSessions, error) {
 			if len(identity.ConnectorData) > 0 {
 				old.ConnectorData = identity.ConnectorData
 			}
@@ -546,7 +622,8 @@ func (s *Server) finalizeLogin(identity connector.Identity, authReq storage.Auth
 }
 
 func (s *Server) handleApproval(w http.ResponseWriter, r *http.Request) {
-	authReq, err := s.storage.GetAuthRequest(r.FormValue("req"))
+	ctx := r.Context()
+	authReq, err := s.storage.GetAuthRequest(ctx, r.FormValue("req"))
 	if err != nil {
 		s.logger.Errorf("Failed to get auth request: %v", err)
 		s.renderError(r, w, http.StatusInternalServerError, "Database error.")
@@ -557,6 +634,31 @@ func (s *Server) handleApproval(w http.ResponseWriter, r *http.Request) {
 		s.renderError(r, w, http.StatusInternalServerError, "Login process not yet finalized.")
 		return
 	}
+	if authReq.SecondFactor != "" {
+		// Second factors are served at the path of their name.
+		http.Redirect(w, r, path.Join(s.issuerURL.Path, "/"+authReq.SecondFactor)+"?req="+authReq.ID, http.StatusSeeOther)
+		return
+	}
+	if msg, ok := s.checkAccessWindows(authReq.ClientID, authReq.Claims); !ok {
+		s.renderError(r, w, http.StatusForbidden, msg)
+		return
+	}
+	scopes, msg, ok := s.authorize(r, grantTypeAuthorizationCode, authReq.ClientID, authReq.ConnectorID, authReq.Claims, authReq.Scopes)
+	if !ok {
+		s.renderError(r, w, http.StatusForbidden, msg)
+		return
+	}
+	authReq.Scopes = scopes
+	accepted, err := s.termsAccepted(ctx, authReq.Claims.UserID, authReq.ConnectorID)
+	if err != nil {
+		s.logger.Errorf("Failed to get terms of service acceptance: %v", err)
+		s.renderError(r, w, http.StatusInternalServerError, "Database error.")
+		return
+	}
+	if !accepted {
+		http.Redirect(w, r, path.Join(s.issuerURL.Path, "/terms")+"?req="+authReq.ID, http.StatusSeeOther)
+		return
+	}
 
 	switch r.Method {
 	case http.MethodGet:
@@ -564,17 +666,31 @@ func (s *Server) handleApproval(w http.ResponseWriter, r *http.Request) {
 			s.sendCodeResponse(w, r, authReq)
 			return
 		}
-		client, err := s.storage.GetClient(authReq.ClientID)
+		client, err := s.getClient(ctx, authReq.ClientID)
 		if err != nil {
 			s.logger.Errorf("Failed to get client %q: %v", authReq.ClientID, err)
 			s.renderError(r, w, http.StatusInternalServerError, "Failed to retrieve client.")
 			return
 		}
-		if err := s.templates.approval(r, w, authReq.ID, authReq.Claims.Username, client.Name, authReq.Scopes, r.URL.Path); err != nil {
+		if err := s.templates.approval(r, w, authReq.ID, authReq.Claims.Username, client, authReq.Scopes, r.URL.Path); err != nil {
 			s.logger.Errorf("Server template error: %v", err)
 		}
 	case http.MethodPost:
-		if r.FormValue("approval") != "approve" {
+		approved := r.FormValue("approval") == "approve"
+		e := audit.Event{
+			Type:        audit.EventApprovalGranted,
+			Severity:    audit.SeverityInfo,
+			ClientID:    authReq.ClientID,
+			Subject:     subjectFor(authReq.Claims.UserID, authReq.ConnectorID),
+			ConnectorID: authReq.ConnectorID,
+			SourceIPs:   []string{remoteIP(r)},
+			Message:     s.fallbackMessage(authReq),
+		}
+		if !approved {
+			e.Type = audit.EventApprovalDenied
+		}
+		s.emitAudit(ctx, e)
+		if !approved {
 			s.renderError(r, w, http.StatusInternalServerError, "Approval rejected.")
 			return
 		}
@@ -583,13 +699,14 @@ func (s *Server) handleApproval(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) sendCodeResponse(w http.ResponseWriter, r *http.Request, authReq storage.AuthRequest) {
-	if s.now().After(authReq.Expiry) {
+	ctx := r.Context()
+	if s.expired(authReq.Expiry) {
 		s.renderError(r, w, http.StatusBadRequest, "User session has expired.")
 		return
 	}
 
-	if err := s.storage.DeleteAuthRequest(authReq.ID); err != nil {
-		if err != storage.ErrNotFound {
+	if err := s.storage.DeleteAuthRequest(ctx, authReq.ID); err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
 			s.logger.Errorf("Failed to delete authorization request: %v", err)
 			s.renderError(r, w, http.StatusInternalServerError, "Internal server error.")
 		} else {
@@ -633,8 +750,9 @@ func (s *Server) sendCodeResponse(w http.ResponseWriter, r *http.Request, authRe
 				Expiry:        s.now().Add(time.Minute * 30),
 				RedirectURI:   authReq.RedirectURI,
 				ConnectorData: authReq.ConnectorData,
+				PKCE:          authReq.PKCE,
 			}
-			if err := s.storage.CreateAuthCode(code); err != nil {
+			if err := s.storage.CreateAuthCode(ctx, code); err != nil {
 				s.logger.Errorf("Failed to create auth code: %v", err)
 				s.renderError(r, w, http.StatusInternalServerError, "Internal server error.")
 				return
@@ -642,8 +760,9 @@ func (s *Server) sendCodeResponse(w http.ResponseWriter, r *http.Request, authRe
 
 			// Implicit and hybrid flows that try to use the OOB redirect URI are
 			// rejected earlier. If we got here we're using the code flow.
-			if authReq.RedirectURI == redirectURIOOB {
-				if err := s.templates.oob(r, w, code.ID, r.URL.Path); err != nil {
+			if isOOBRedirectURI(authReq.RedirectURI) {
+				auto := authReq.RedirectURI == redirectURIOOBAuto
+				if err := s.templates.oob(r, w, code.ID, auto, code.Expiry.Sub(s.now()), r.URL.Path); err != nil {
 					s.logger.Errorf("Server template error: %v", err)
 				}
 				return
@@ -654,14 +773,14 @@ func (s *Server) sendCodeResponse(w http.ResponseWriter, r *http.Request, authRe
 			implicitOrHybrid = true
 			var err error
 
-			accessToken, err = s.newAccessToken(authReq.ClientID, authReq.Claims, authReq.Scopes, authReq.Nonce, authReq.ConnectorID)
+			accessToken, err = s.newAccessToken(ctx, authReq.ClientID, authReq.Claims, authReq.Scopes, authReq.Nonce, authReq.ConnectorID)
 			if err != nil {
 				s.logger.Errorf("failed to create new access token: %v", err)
 				s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
 				return
 			}
 
-			idToken, idTokenExpiry, err = s.newIDToken(authReq.ClientID, authReq.Claims, authReq.Scopes, authReq.Nonce, accessToken, authReq.ConnectorID)
+			idToken, idTokenExpiry, err = s.newIDToken(ctx, authReq.ClientID, authReq.Claims, authReq.Scopes, authReq.Nonce, accessToken, authReq.ConnectorID)
 			if err != nil {
 				s.logger.Errorf("failed to create ID token: %v", err)
 				s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
@@ -719,87 +838,206 @@ func (s *Server) sendCodeResponse(w http.ResponseWriter, r *http.Request, authRe
 }
 
 func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if group := tokenEndpointGroup(r.PostFormValue("grant_type")); group != "" && s.shed(w, group) {
+		s.tokenErrHelper(w, errTemporarilyUnavailable, "The server is overloaded, retry later.", http.StatusServiceUnavailable)
+		return
+	}
+
+	// API keys, service account assertions and pre-authorized codes
+	// authenticate the client tokens are issued for.
+	var handleGrant func(w http.ResponseWriter, r *http.Request) (clientID string)
+	switch grantType := r.PostFormValue("grant_type"); {
+	case s.allowAPIKeys && grantType == grantTypeAPIKey:
+		handleGrant = s.handleAPIKeyGrant
+	case s.allowServiceAccounts && grantType == grantTypeJWTBearer:
+		handleGrant = s.handleServiceAccountGrant
+	case s.allowPreAuthCodes && grantType == grantTypePreAuthorizedCode:
+		handleGrant = s.handlePreAuthorizedCodeGrant
+	}
+	if handleGrant != nil {
+		var clientID string
+		m := httpsnoop.CaptureMetricsFn(w, func(w http.ResponseWriter) {
+			clientID = handleGrant(w, r)
+		})
+		if clientID != "" {
+			s.tokenMetrics.observe(clientID, r.PostFormValue("grant_type"), m.Code)
+		}
+		return
+	}
+
+	var (
+		client   storage.Client
+		spiffeID string
+		policy   SPIFFEClient
+		ok       bool
+	)
+	// Workloads authenticate with an SVID instead of a client secret.
+	if s.spiffe.presented(r) {
+		client, spiffeID, policy, ok = s.authenticateSVID(w, r)
+	} else {
+		client, ok = s.authenticateClient(w, r)
+	}
+	if !ok || !s.checkClientNetwork(w, r, client) {
+		return
+	}
+
+	grantType := r.PostFormValue("grant_type")
+	m := httpsnoop.CaptureMetricsFn(w, func(w http.ResponseWriter) {
+		switch grantType {
+		case grantTypeAuthorizationCode:
+			s.handleAuthCode(w, r, client)
+		case grantTypeRefreshToken:
+			s.handleRefreshToken(w, r, client)
+		case grantTypePassword:
+			s.handlePasswordGrant(w, r, client)
+		case grantTypeClientCredentials:
+			if spiffeID == "" {
+				s.tokenErrHelper(w, errUnauthorizedClient, "Only workloads authenticated with an SVID may use the client credentials grant.", http.StatusBadRequest)
+				return
+			}
+			s.handleClientCredentials(w, r, client, spiffeID, policy)
+		case grantTypeTokenExchange:
+			if !s.featureEnabled(FeatureTokenExchange) {
+				s.tokenErrHelper(w, errUnsupportedGrantType, "", http.StatusBadRequest)
+				return
+			}
+			s.handleTokenExchange(w, r, client)
+		default:
+			s.tokenErrHelper(w, errInvalidGrant, "", http.StatusBadRequest)
+		}
+	})
+	s.tokenMetrics.observe(client.ID, grantType, m.Code)
+}
+
+// authenticateClient authenticates the client with its client ID and secret.
+// It writes an error response and returns false if authentication fails.
+func (s *Server) authenticateClient(w http.ResponseWriter, r *http.Request) (storage.Client, bool) {
+	ctx := r.Context()
 	clientID, clientSecret, ok := r.BasicAuth()
 	if ok {
 		var err error
 		if clientID, err = url.QueryUnescape(clientID); err != nil {
 			s.tokenErrHelper(w, errInvalidRequest, "client_id improperly encoded", http.StatusBadRequest)
-			return
+			return storage.Client{}, false
 		}
 		if clientSecret, err = url.QueryUnescape(clientSecret); err != nil {
 			s.tokenErrHelper(w, errInvalidRequest, "client_secret improperly encoded", http.StatusBadRequest)
-			return
+			return storage.Client{}, false
 		}
 	} else {
 		clientID = r.PostFormValue("client_id")
 		clientSecret = r.PostFormValue("client_secret")
 	}
 
-	client, err := s.storage.GetClient(clientID)
+	client, err := s.getClient(ctx, clientID)
 	if err != nil {
-		if err != storage.ErrNotFound {
+		if !errors.Is(err, storage.ErrNotFound) {
 			s.logger.Errorf("failed to get client: %v", err)
 			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
 		} else {
+			s.delayFailure(ctx)
 			s.tokenErrHelper(w, errInvalidClient, "Invalid client credentials.", http.StatusUnauthorized)
 		}
-		return
+		return storage.Client{}, false
 	}
-	if client.Secret != clientSecret {
+	if client.Public && clientSecret == "" && r.PostFormValue("grant_type") == grantTypeAuthorizationCode && r.PostFormValue("code_verifier") != "" {
+		// Public clients can't keep their secret, so they may redeem codes
+		// bound to them with PKCE without it. handleAuthCode rejects
+		// verifiers for codes without a code challenge.
+		return client, true
+	}
+	if ok, err := verifyClientSecret(client.Secret, clientSecret); err != nil {
+		s.logger.Errorf("failed to verify secret of client %s: %v", client.ID, err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return storage.Client{}, false
+	} else if !ok {
+		s.delayFailure(ctx)
 		s.tokenErrHelper(w, errInvalidClient, "Invalid client credentials.", http.StatusUnauthorized)
-		return
+		return storage.Client{}, false
 	}
+	s.upgradeClientSecret(ctx, client, clientSecret)
+	return client, true
+}
 
-	grantType := r.PostFormValue("grant_type")
-	switch grantType {
-	case grantTypeAuthorizationCode:
-		s.handleAuthCode(w, r, client)
-	case grantTypeRefreshToken:
-		s.handleRefreshToken(w, r, client)
-	case grantTypePassword:
-		s.handlePasswordGrant(w, r, client)
-	default:
-		s.tokenErrHelper(w, errInvalidGrant, "", http.StatusBadRequest)
+// checkClientNetwork writes an error response and returns false if the
+// request comes from outside the client's allowed networks.
+func (s *Server) checkClientNetwork(w http.ResponseWriter, r *http.Request, client storage.Client) bool {
+	ip := remoteIP(r)
+	allowed, err := validateClientNetwork(client, ip)
+	if err != nil {
+		s.logger.Errorf("failed to validate client network: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return false
+	}
+	if !allowed {
+		s.emitAudit(r.Context(), audit.Event{
+			Type:      audit.EventClientNetworkDenied,
+			Severity:  audit.SeverityWarning,
+			ClientID:  client.ID,
+			SourceIPs: []string{ip},
+			Message:   "token request from outside the client's allowed networks",
+		})
+		s.tokenErrHelper(w, errUnauthorizedClient, "Client is not allowed to request tokens from this network.", http.StatusForbidden)
+		return false
 	}
+	return true
 }
 
 // handle an access token request https://tools.ietf.org/html/rfc6749#section-4.1.3
 func (s *Server) handleAuthCode(w http.ResponseWriter, r *http.Request, client storage.Client) {
+	ctx := r.Context()
 	code := r.PostFormValue("code")
 	redirectURI := r.PostFormValue("redirect_uri")
 
-	authCode, err := s.storage.GetAuthCode(code)
-	if err != nil || s.now().After(authCode.Expiry) || authCode.ClientID != client.ID {
-		if err != storage.ErrNotFound {
-			s.logger.Errorf("failed to get auth code: %v", err)
+	// Consume the code before doing anything else so concurrent redemptions of
+	// the same code can't both be issued tokens.
+	authCode, err := s.storage.ConsumeAuthCode(ctx, code)
+	if err != nil || s.expired(authCode.Expiry) || authCode.ClientID != client.ID {
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			s.logger.Errorf("failed to consume auth code: %v", err)
 			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
 		} else {
+			if prev, ok := s.redeemedCodes.get(s.now(), code); ok && errors.Is(err, storage.ErrNotFound) {
+				s.reportAuthCodeReuse(r, prev)
+			}
 			s.tokenErrHelper(w, errInvalidRequest, "Invalid or expired code parameter.", http.StatusBadRequest)
 		}
 		return
 	}
+	s.redeemedCodes.add(s.now(), code, &redeemedCode{
+		clientID:    authCode.ClientID,
+		userID:      authCode.Claims.UserID,
+		connectorID: authCode.ConnectorID,
+		remoteIP:    remoteIP(r),
+		expiry:      authCode.Expiry,
+	})
 
 	if authCode.RedirectURI != redirectURI {
 		s.tokenErrHelper(w, errInvalidRequest, "redirect_uri did not match URI from initial request.", http.StatusBadRequest)
 		return
 	}
 
-	accessToken, err := s.newAccessToken(client.ID, authCode.Claims, authCode.Scopes, authCode.Nonce, authCode.ConnectorID)
-	if err != nil {
-		s.logger.Errorf("failed to create new access token: %v", err)
-		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+	codeVerifier := r.PostFormValue("code_verifier")
+	if authCode.PKCE.CodeChallenge != "" {
+		if !verifyCodeVerifier(authCode.PKCE, codeVerifier) {
+			s.tokenErrHelper(w, errInvalidGrant, "Invalid code_verifier.", http.StatusBadRequest)
+			return
+		}
+	} else if codeVerifier != "" {
+		s.tokenErrHelper(w, errInvalidRequest, "No code_challenge was sent with the auth request.", http.StatusBadRequest)
 		return
 	}
 
-	idToken, expiry, err := s.newIDToken(client.ID, authCode.Claims, authCode.Scopes, authCode.Nonce, accessToken, authCode.ConnectorID)
+	accessToken, err := s.newAccessToken(ctx, client.ID, authCode.Claims, authCode.Scopes, authCode.Nonce, authCode.ConnectorID)
 	if err != nil {
-		s.logger.Errorf("failed to create ID token: %v", err)
+		s.logger.Errorf("failed to create new access token: %v", err)
 		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
 		return
 	}
 
-	if err := s.storage.DeleteAuthCode(code); err != nil {
-		s.logger.Errorf("failed to delete auth code: %v", err)
+	idToken, expiry, err := s.newIDToken(ctx, client.ID, authCode.Claims, authCode.Scopes, authCode.Nonce, accessToken, authCode.ConnectorID)
+	if err != nil {
+		s.logger.Errorf("failed to create ID token: %v", err)
 		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
 		return
 	}
@@ -808,7 +1046,7 @@ func (s *Server) handleAuthCode(w http.ResponseWriter, r *http.Request, client s
 		// Ensure the connector supports refresh tokens.
 		//
 		// Connectors like `saml` do not implement RefreshConnector.
-		conn, err := s.getConnector(authCode.ConnectorID)
+		conn, err := s.getConnector(ctx, authCode.ConnectorID)
 		if err != nil {
 			s.logger.Errorf("connector with ID %q not found: %v", authCode.ConnectorID, err)
 			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
@@ -819,6 +1057,9 @@ func (s *Server) handleAuthCode(w http.ResponseWriter, r *http.Request, client s
 		if !ok {
 			return false
 		}
+		if !s.offlineAccessAllowed(authCode.ClientID, authCode.ConnectorID, grantTypeAuthorizationCode) {
+			return false
+		}
 
 		for _, scope := range authCode.Scopes {
 			if scope == scopeOfflineAccess {
@@ -840,6 +1081,9 @@ func (s *Server) handleAuthCode(w http.ResponseWriter, r *http.Request, client s
 			ConnectorData: authCode.ConnectorData,
 			CreatedAt:     s.now(),
 			LastUsed:      s.now(),
+			LastUsedIP:    remoteIP(r),
+
+			DeviceFingerprint: deviceFingerprint(r),
 		}
 		token := &internal.RefreshToken{
 			RefreshId: refresh.ID,
@@ -851,11 +1095,12 @@ func (s *Server) handleAuthCode(w http.ResponseWriter, r *http.Request, client s
 			return
 		}
 
-		if err := s.storage.CreateRefresh(refresh); err != nil {
+		if err := s.storage.CreateRefresh(ctx, refresh); err != nil {
 			s.logger.Errorf("failed to create refresh token: %v", err)
 			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
 			return
 		}
+		s.redeemedCodes.setRefreshID(code, refresh.ID)
 
 		// deleteToken determines if we need to delete the newly created refresh token
 		// due to a failure in updating/creating the OfflineSession object for the
@@ -864,7 +1109,7 @@ func (s *Server) handleAuthCode(w http.ResponseWriter, r *http.Request, client s
 		defer func() {
 			if deleteToken {
 				// Delete newly created refresh token from storage.
-				if err := s.storage.DeleteRefresh(refresh.ID); err != nil {
+				if err := s.storage.DeleteRefresh(ctx, refresh.ID); err != nil {
 					s.logger.Errorf("failed to delete refresh token: %v", err)
 					s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
 					return
@@ -880,8 +1125,8 @@ func (s *Server) handleAuthCode(w http.ResponseWriter, r *http.Request, client s
 		}
 
 		// Try to retrieve an existing OfflineSession object for the corresponding user.
-		if session, err := s.storage.GetOfflineSessions(refresh.Claims.UserID, refresh.ConnectorID); err != nil {
-			if err != storage.ErrNotFound {
+		if session, err := s.storage.GetOfflineSessions(ctx, refresh.Claims.UserID, refresh.ConnectorID); err != nil {
+			if !errors.Is(err, storage.ErrNotFound) {
 				s.logger.Errorf("failed to get offline session: %v", err)
 				s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
 				deleteToken = true
@@ -896,7 +1141,7 @@ func (s *Server) handleAuthCode(w http.ResponseWriter, r *http.Request, client s
 
 			// Create a new OfflineSession object for the user and add a reference object for
 			// the newly received refreshtoken.
-			if err := s.storage.CreateOfflineSessions(offlineSessions); err != nil {
+			if err := s.storage.CreateOfflineSessions(ctx, offlineSessions); err != nil {
 				s.logger.Errorf("failed to create offline session: %v", err)
 				s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
 				deleteToken = true
@@ -905,7 +1150,9 @@ func (s *Server) handleAuthCode(w http.ResponseWriter, r *http.Request, client s
 		} else {
 			if oldTokenRef, ok := session.Refresh[tokenRef.ClientID]; ok {
 				// Delete old refresh token from storage.
-				if err := s.storage.DeleteRefresh(oldTokenRef.ID); err != nil && err != storage.ErrNotFound {
+				if err := s.storage.DeleteRefresh(ctx, oldTokenRef.ID); err == nil {
+					s.tombstoneRefresh(ctx, oldTokenRef.ID, tokenRef.ClientID, revocationSuperseded)
+				} else if !errors.Is(err, storage.ErrNotFound) {
 					s.logger.Errorf("failed to delete refresh token: %v", err)
 					s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
 					deleteToken = true
@@ -914,7 +1161,7 @@ func (s *Server) handleAuthCode(w http.ResponseWriter, r *http.Request, client s
 			}
 
 			// Update existing OfflineSession obj with new RefreshTokenRef.
-			if err := s.storage.UpdateOfflineSessions(session.UserID, session.ConnID, func(old storage.OfflineSessions) (storage.OfflineSessions, error) {
+			if err := s.storage.UpdateOfflineSessions(ctx, session.UserID, session.ConnID, func(old storage.OfflineSessions) (storage.OfflineSessions, error) {
 				old.Refresh[tokenRef.ClientID] = &tokenRef
 				return old, nil
 			}); err != nil {
@@ -930,6 +1177,7 @@ func (s *Server) handleAuthCode(w http.ResponseWriter, r *http.Request, client s
 
 // handle a refresh token request https://tools.ietf.org/html/rfc6749#section-6
 func (s *Server) handleRefreshToken(w http.ResponseWriter, r *http.Request, client storage.Client) {
+	ctx := r.Context()
 	code := r.PostFormValue("refresh_token")
 	scope := r.PostFormValue("scope")
 	if code == "" {
@@ -948,10 +1196,10 @@ func (s *Server) handleRefreshToken(w http.ResponseWriter, r *http.Request, clie
 		token = &internal.RefreshToken{RefreshId: code, Token: ""}
 	}
 
-	refresh, err := s.storage.GetRefresh(token.RefreshId)
+	refresh, err := s.storage.GetRefresh(ctx, token.RefreshId)
 	if err != nil {
 		s.logger.Errorf("failed to get refresh token: %v", err)
-		if err == storage.ErrNotFound {
+		if errors.Is(err, storage.ErrNotFound) {
 			s.tokenErrHelper(w, errInvalidRequest, "Refresh token is invalid or has already been claimed by another client.", http.StatusBadRequest)
 		} else {
 			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
@@ -965,9 +1213,23 @@ func (s *Server) handleRefreshToken(w http.ResponseWriter, r *http.Request, clie
 	}
 	if refresh.Token != token.Token {
 		s.logger.Errorf("refresh token with id %s claimed twice", refresh.ID)
+		s.reportRefreshTokenReuse(r, refresh)
 		s.tokenErrHelper(w, errInvalidRequest, "Refresh token is invalid or has already been claimed by another client.", http.StatusBadRequest)
 		return
 	}
+	if !s.checkDeviceFingerprint(r, refresh) {
+		s.logger.Errorf("refresh token with id %s presented from another device", refresh.ID)
+		s.tokenErrHelper(w, errInvalidGrant, "Refresh token is bound to another device.", http.StatusBadRequest)
+		return
+	}
+	if !s.offlineAccessAllowed(client.ID, refresh.ConnectorID, grantTypeRefreshToken) {
+		s.tokenErrHelper(w, errInvalidGrant, "Refresh tokens are disabled for this client.", http.StatusBadRequest)
+		return
+	}
+	if msg, ok := s.checkAccessWindows(client.ID, refresh.Claims); !ok {
+		s.tokenErrHelper(w, errAccessDenied, msg, http.StatusForbidden)
+		return
+	}
 
 	// Per the OAuth2 spec, if the client has omitted the scopes, default to the original
 	// authorized scopes.
@@ -1001,19 +1263,26 @@ func (s *Server) handleRefreshToken(w http.ResponseWriter, r *http.Request, clie
 	}
 
 	var connectorData []byte
-	if session, err := s.storage.GetOfflineSessions(refresh.Claims.UserID, refresh.ConnectorID); err != nil {
-		if err != storage.ErrNotFound {
+	// When the identity was last refreshed from the connector.
+	identityRefreshedAt := refresh.CreatedAt
+	if session, err := s.storage.GetOfflineSessions(ctx, refresh.Claims.UserID, refresh.ConnectorID); err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
 			s.logger.Errorf("failed to get offline session: %v", err)
 			return
 		}
-	} else if len(refresh.ConnectorData) > 0 {
-		// Use the old connector data if it exists, should be deleted once used
-		connectorData = refresh.ConnectorData
 	} else {
-		connectorData = session.ConnectorData
+		if len(refresh.ConnectorData) > 0 {
+			// Use the old connector data if it exists, should be deleted once used
+			connectorData = refresh.ConnectorData
+		} else {
+			connectorData = session.ConnectorData
+		}
+		if ref := session.Refresh[refresh.ClientID]; ref != nil && ref.ID == refresh.ID && !ref.IdentityRefreshedAt.IsZero() {
+			identityRefreshedAt = ref.IdentityRefreshedAt
+		}
 	}
 
-	conn, err := s.getConnector(refresh.ConnectorID)
+	conn, err := s.getConnector(ctx, refresh.ConnectorID)
 	if err != nil {
 		s.logger.Errorf("connector with ID %q not found: %v", refresh.ConnectorID, err)
 		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
@@ -1030,18 +1299,28 @@ func (s *Server) handleRefreshToken(w http.ResponseWriter, r *http.Request, clie
 	}
 
 	// Can the connector refresh the identity? If so, attempt to refresh the data
-	// in the connector.
+	// in the connector, unless a refresh policy makes this a local refresh.
 	//
 	// TODO(ericchiang): We may want a strict mode where connectors that don't implement
 	// this interface can't perform refreshing.
-	if refreshConn, ok := conn.Connector.(connector.RefreshConnector); ok {
-		newIdent, err := refreshConn.Refresh(r.Context(), parseScopes(scopes), ident)
+	refreshConn, upstream := conn.Connector.(connector.RefreshConnector)
+	upstream = upstream && s.refreshUpstream(client.ID, refresh.ConnectorID, identityRefreshedAt)
+	if upstream {
+		start := time.Now()
+		newIdent, err := refreshConn.Refresh(r.Context(), s.connectorScopes(refresh.ConnectorID, scopes), ident)
+		s.connectorMetrics.observe(refresh.ConnectorID, connectorOpRefresh, connectorOutcome(true, err), start)
 		if err != nil {
 			s.logger.Errorf("failed to refresh identity: %v", err)
+			s.reportFailure(alert.KindConnector, refresh.ConnectorID, err)
 			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
 			return
 		}
-		ident = newIdent
+		ident, err = s.filterGroups(refresh.ConnectorID, s.transformIdentity(refresh.ConnectorID, newIdent))
+		if err != nil {
+			s.logger.Infof("refresh rejected: %v", err)
+			s.tokenErrHelper(w, errInvalidGrant, "User is not a member of the groups allowed to log in.", http.StatusBadRequest)
+			return
+		}
 	}
 
 	claims := storage.Claims{
@@ -1052,15 +1331,20 @@ func (s *Server) handleRefreshToken(w http.ResponseWriter, r *http.Request, clie
 		EmailVerified:     ident.EmailVerified,
 		Groups:            ident.Groups,
 	}
+	scopes, msg, ok := s.authorize(r, grantTypeRefreshToken, client.ID, refresh.ConnectorID, claims, scopes)
+	if !ok {
+		s.tokenErrHelper(w, errAccessDenied, msg, http.StatusForbidden)
+		return
+	}
 
-	accessToken, err := s.newAccessToken(client.ID, claims, scopes, refresh.Nonce, refresh.ConnectorID)
+	accessToken, err := s.newAccessToken(ctx, client.ID, claims, scopes, refresh.Nonce, refresh.ConnectorID)
 	if err != nil {
 		s.logger.Errorf("failed to create new access token: %v", err)
 		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
 		return
 	}
 
-	idToken, expiry, err := s.newIDToken(client.ID, claims, scopes, refresh.Nonce, accessToken, refresh.ConnectorID)
+	idToken, expiry, err := s.newIDToken(ctx, client.ID, claims, scopes, refresh.Nonce, accessToken, refresh.ConnectorID)
 	if err != nil {
 		s.logger.Errorf("failed to create ID token: %v", err)
 		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
@@ -1093,6 +1377,7 @@ func (s *Server) handleRefreshToken(w http.ResponseWriter, r *http.Request, clie
 		old.Claims.EmailVerified = ident.EmailVerified
 		old.Claims.Groups = ident.Groups
 		old.LastUsed = lastUsed
+		old.LastUsedIP = remoteIP(r)
 
 		// ConnectorData has been moved to OfflineSession
 		old.ConnectorData = []byte{}
@@ -1101,11 +1386,14 @@ func (s *Server) handleRefreshToken(w http.ResponseWriter, r *http.Request, clie
 
 	// Update LastUsed time stamp in refresh token reference object
 	// in offline session for the user.
-	if err := s.storage.UpdateOfflineSessions(refresh.Claims.UserID, refresh.ConnectorID, func(old storage.OfflineSessions) (storage.OfflineSessions, error) {
+	if err := s.storage.UpdateOfflineSessions(ctx, refresh.Claims.UserID, refresh.ConnectorID, func(old storage.OfflineSessions) (storage.OfflineSessions, error) {
 		if old.Refresh[refresh.ClientID].ID != refresh.ID {
 			return old, errors.New("refresh token invalid")
 		}
 		old.Refresh[refresh.ClientID].LastUsed = lastUsed
+		if upstream {
+			old.Refresh[refresh.ClientID].IdentityRefreshedAt = lastUsed
+		}
 		old.ConnectorData = ident.ConnectorData
 		return old, nil
 	}); err != nil {
@@ -1115,7 +1403,7 @@ func (s *Server) handleRefreshToken(w http.ResponseWriter, r *http.Request, clie
 	}
 
 	// Update refresh token in the storage.
-	if err := s.storage.UpdateRefreshToken(refresh.ID, updater); err != nil {
+	if err := s.storage.UpdateRefreshToken(ctx, refresh.ID, updater); err != nil {
 		s.logger.Errorf("failed to update refresh token: %v", err)
 		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
 		return
@@ -1126,6 +1414,10 @@ func (s *Server) handleRefreshToken(w http.ResponseWriter, r *http.Request, clie
 
 func (s *Server) handleUserInfo(w http.ResponseWriter, r *http.Request) {
 	const prefix = "Bearer "
+	if s.shed(w, endpointGroupUserInfo) {
+		s.tokenErrHelper(w, errTemporarilyUnavailable, "The server is overloaded, retry later.", http.StatusServiceUnavailable)
+		return
+	}
 
 	auth := r.Header.Get("authorization")
 	if len(auth) < len(prefix) || !strings.EqualFold(prefix, auth[:len(prefix)]) {
@@ -1135,8 +1427,7 @@ func (s *Server) handleUserInfo(w http.ResponseWriter, r *http.Request) {
 	}
 	rawIDToken := auth[len(prefix):]
 
-	verifier := oidc.NewVerifier(s.issuerURL.String(), &storageKeySet{s.storage}, &oidc.Config{SkipClientIDCheck: true})
-	idToken, err := verifier.Verify(r.Context(), rawIDToken)
+	idToken, err := s.tokenVerifier().Verify(r.Context(), rawIDToken)
 	if err != nil {
 		s.tokenErrHelper(w, errAccessDenied, err.Error(), http.StatusForbidden)
 		return
@@ -1153,6 +1444,7 @@ func (s *Server) handleUserInfo(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handlePasswordGrant(w http.ResponseWriter, r *http.Request, client storage.Client) {
+	ctx := r.Context()
 	// Parse the fields
 	if err := r.ParseForm(); err != nil {
 		s.tokenErrHelper(w, errInvalidRequest, "Couldn't parse data", http.StatusBadRequest)
@@ -1176,19 +1468,24 @@ func (s *Server) handlePasswordGrant(w http.ResponseWriter, r *http.Request, cli
 			hasOpenIDScope = true
 		case scopeOfflineAccess, scopeEmail, scopeProfile, scopeGroups, scopeFederatedID:
 		default:
-			peerID, ok := parseCrossClientScope(scope)
+			peerIDs, ok := s.requestedAudiences(scope)
 			if !ok {
-				unrecognized = append(unrecognized, scope)
+				if _, ok := s.customScopes[scope]; !ok {
+					unrecognized = append(unrecognized, scope)
+				}
 				continue
 			}
 
-			isTrusted, err := s.validateCrossClientTrust(client.ID, peerID)
-			if err != nil {
-				s.tokenErrHelper(w, errInvalidClient, fmt.Sprintf("Error validating cross client trust %v.", err), http.StatusBadRequest)
-				return
-			}
-			if !isTrusted {
-				invalidScopes = append(invalidScopes, scope)
+			for _, peerID := range peerIDs {
+				isTrusted, err := s.validateCrossClientTrust(ctx, client.ID, peerID)
+				if err != nil {
+					s.tokenErrHelper(w, errInvalidClient, fmt.Sprintf("Error validating cross client trust %v.", err), http.StatusBadRequest)
+					return
+				}
+				if !isTrusted {
+					invalidScopes = append(invalidScopes, scope)
+					break
+				}
 			}
 		}
 	}
@@ -1207,7 +1504,7 @@ func (s *Server) handlePasswordGrant(w http.ResponseWriter, r *http.Request, cli
 
 	// Which connector
 	connID := s.passwordConnector
-	conn, err := s.getConnector(connID)
+	conn, err := s.getConnector(ctx, connID)
 	if err != nil {
 		s.tokenErrHelper(w, errInvalidRequest, "Requested connector does not exist.", http.StatusBadRequest)
 		return
@@ -1222,16 +1519,28 @@ func (s *Server) handlePasswordGrant(w http.ResponseWriter, r *http.Request, cli
 	// Login
 	username := q.Get("username")
 	password := q.Get("password")
-	identity, ok, err := passwordConnector.Login(r.Context(), parseScopes(scopes), username, password)
+	start := time.Now()
+	identity, ok, err := passwordConnector.Login(r.Context(), s.connectorScopes(connID, scopes), username, password)
+	s.connectorMetrics.observe(connID, connectorOpLogin, connectorOutcome(ok, err), start)
 	if err != nil {
+		s.logger.Errorf("Failed to login user: %v", err)
+		s.reportFailure(alert.KindConnector, connID, err)
 		s.tokenErrHelper(w, errInvalidRequest, "Could not login user", http.StatusBadRequest)
 		return
 	}
 	if !ok {
+		s.delayFailure(ctx)
 		s.tokenErrHelper(w, errAccessDenied, "Invalid username or password", http.StatusUnauthorized)
 		return
 	}
 
+	identity, err = s.filterGroups(connID, s.transformIdentity(connID, identity))
+	if err != nil {
+		s.logger.Infof("login rejected: %v", err)
+		s.tokenErrHelper(w, errAccessDenied, "User is not a member of the groups allowed to log in.", http.StatusForbidden)
+		return
+	}
+
 	// Build the claims to send the id token
 	claims := storage.Claims{
 		UserID:            identity.UserID,
@@ -1241,9 +1550,52 @@ func (s *Server) handlePasswordGrant(w http.ResponseWriter, r *http.Request, cli
 		EmailVerified:     identity.EmailVerified,
 		Groups:            identity.Groups,
 	}
+	if msg, ok := s.checkAccessWindows(client.ID, claims); !ok {
+		s.tokenErrHelper(w, errAccessDenied, msg, http.StatusForbidden)
+		return
+	}
+	scopes, msg, ok := s.authorize(r, grantTypePassword, client.ID, connID, claims, scopes)
+	if !ok {
+		s.tokenErrHelper(w, errAccessDenied, msg, http.StatusForbidden)
+		return
+	}
+	// The password grant has no way to prompt the user, so they must have
+	// accepted the current terms of service through a browser login first.
+	accepted, err := s.termsAccepted(ctx, claims.UserID, connID)
+	if err != nil {
+		s.logger.Errorf("failed to get terms of service acceptance: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+	if !accepted {
+		s.tokenErrHelper(w, errAccessDenied, "The current terms of service have not been accepted.", http.StatusForbidden)
+		return
+	}
+	// Nor can it ask for a passkey.
+	webAuthnRequired, err := s.webAuthnRequired(ctx, claims.UserID, connID)
+	if err != nil {
+		s.logger.Errorf("failed to list WebAuthn credentials: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+	if webAuthnRequired {
+		s.tokenErrHelper(w, errAccessDenied, "A passkey is required, log in through a browser.", http.StatusForbidden)
+		return
+	}
+	// Nor for a TOTP code.
+	totpRequired, err := s.totpRequired(ctx, claims.UserID, connID)
+	if err != nil {
+		s.logger.Errorf("failed to get TOTP secret: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+	if totpRequired {
+		s.tokenErrHelper(w, errAccessDenied, "A TOTP code is required, log in through a browser.", http.StatusForbidden)
+		return
+	}
 
 	accessToken := storage.NewID()
-	idToken, expiry, err := s.newIDToken(client.ID, claims, scopes, nonce, accessToken, connID)
+	idToken, expiry, err := s.newIDToken(ctx, client.ID, claims, scopes, nonce, accessToken, connID)
 	if err != nil {
 		s.tokenErrHelper(w, errServerError, fmt.Sprintf("failed to create ID token: %v", err), http.StatusInternalServerError)
 		return
@@ -1257,6 +1609,9 @@ func (s *Server) handlePasswordGrant(w http.ResponseWriter, r *http.Request, cli
 		if !ok {
 			return false
 		}
+		if !s.offlineAccessAllowed(client.ID, connID, grantTypePassword) {
+			return false
+		}
 
 		for _, scope := range scopes {
 			if scope == scopeOfflineAccess {
@@ -1276,8 +1631,11 @@ func (s *Server) handlePasswordGrant(w http.ResponseWriter, r *http.Request, cli
 			Claims:      claims,
 			Nonce:       nonce,
 			// ConnectorData: authCode.ConnectorData,
-			CreatedAt: s.now(),
-			LastUsed:  s.now(),
+			CreatedAt:  s.now(),
+			LastUsed:   s.now(),
+			LastUsedIP: remoteIP(r),
+
+			DeviceFingerprint: deviceFingerprint(r),
 		}
 		token := &internal.RefreshToken{
 			RefreshId: refresh.ID,
@@ -1289,7 +1647,7 @@ func (s *Server) handlePasswordGrant(w http.ResponseWriter, r *http.Request, cli
 			return
 		}
 
-		if err := s.storage.CreateRefresh(refresh); err != nil {
+		if err := s.storage.CreateRefresh(ctx, refresh); err != nil {
 			s.logger.Errorf("failed to create refresh token: %v", err)
 			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
 			return
@@ -1302,7 +1660,7 @@ func (s *Server) handlePasswordGrant(w http.ResponseWriter, r *http.Request, cli
 		defer func() {
 			if deleteToken {
 				// Delete newly created refresh token from storage.
-				if err := s.storage.DeleteRefresh(refresh.ID); err != nil {
+				if err := s.storage.DeleteRefresh(ctx, refresh.ID); err != nil {
 					s.logger.Errorf("failed to delete refresh token: %v", err)
 					s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
 					return
@@ -1318,8 +1676,8 @@ func (s *Server) handlePasswordGrant(w http.ResponseWriter, r *http.Request, cli
 		}
 
 		// Try to retrieve an existing OfflineSession object for the corresponding user.
-		if session, err := s.storage.GetOfflineSessions(refresh.Claims.UserID, refresh.ConnectorID); err != nil {
-			if err != storage.ErrNotFound {
+		if session, err := s.storage.GetOfflineSessions(ctx, refresh.Claims.UserID, refresh.ConnectorID); err != nil {
+			if !errors.Is(err, storage.ErrNotFound) {
 				s.logger.Errorf("failed to get offline session: %v", err)
 				s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
 				deleteToken = true
@@ -1334,7 +1692,7 @@ func (s *Server) handlePasswordGrant(w http.ResponseWriter, r *http.Request, cli
 
 			// Create a new OfflineSession object for the user and add a reference object for
 			// the newly received refreshtoken.
-			if err := s.storage.CreateOfflineSessions(offlineSessions); err != nil {
+			if err := s.storage.CreateOfflineSessions(ctx, offlineSessions); err != nil {
 				s.logger.Errorf("failed to create offline session: %v", err)
 				s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
 				deleteToken = true
@@ -1343,8 +1701,8 @@ func (s *Server) handlePasswordGrant(w http.ResponseWriter, r *http.Request, cli
 		} else {
 			if oldTokenRef, ok := session.Refresh[tokenRef.ClientID]; ok {
 				// Delete old refresh token from storage.
-				if err := s.storage.DeleteRefresh(oldTokenRef.ID); err != nil {
-					if err == storage.ErrNotFound {
+				if err := s.storage.DeleteRefresh(ctx, oldTokenRef.ID); err != nil {
+					if errors.Is(err, storage.ErrNotFound) {
 						s.logger.Warnf("database inconsistent, refresh token missing: %v", oldTokenRef.ID)
 					} else {
 						s.logger.Errorf("failed to delete refresh token: %v", err)
@@ -1352,11 +1710,13 @@ func (s *Server) handlePasswordGrant(w http.ResponseWriter, r *http.Request, cli
 						deleteToken = true
 						return
 					}
+				} else {
+					s.tombstoneRefresh(ctx, oldTokenRef.ID, tokenRef.ClientID, revocationSuperseded)
 				}
 			}
 
 			// Update existing OfflineSession obj with new RefreshTokenRef.
-			if err := s.storage.UpdateOfflineSessions(session.UserID, session.ConnID, func(old storage.OfflineSessions) (storage.OfflineSessions, error) {
+			if err := s.storage.UpdateOfflineSessions(ctx, session.UserID, session.ConnID, func(old storage.OfflineSessions) (storage.OfflineSessions, error) {
 				old.Refresh[tokenRef.ClientID] = &tokenRef
 				return old, nil
 			}); err != nil {
@@ -5,10 +5,14 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
 
+	"github.com/dexidp/dex/pkg/audit"
 	"github.com/dexidp/dex/storage"
 )
 
@@ -30,7 +34,7 @@ type badStorage struct {
 	storage.Storage
 }
 
-func (b *badStorage) CreateAuthRequest(r storage.AuthRequest) error {
+func (b *badStorage) CreateAuthRequest(ctx context.Context, r storage.AuthRequest) error {
 	return errors.New("storage unavailable")
 }
 
@@ -54,10 +58,41 @@ type emptyStorage struct {
 	storage.Storage
 }
 
-func (*emptyStorage) GetAuthRequest(string) (storage.AuthRequest, error) {
+func (*emptyStorage) GetAuthRequest(context.Context, string) (storage.AuthRequest, error) {
 	return storage.AuthRequest{}, storage.ErrNotFound
 }
 
+// wrappingStorage adds context to the errors of client lookups, like storages
+// reporting which query failed.
+type wrappingStorage struct {
+	storage.Storage
+}
+
+func (s *wrappingStorage) GetClient(ctx context.Context, id string) (storage.Client, error) {
+	c, err := s.Storage.GetClient(ctx, id)
+	if err != nil {
+		return c, fmt.Errorf("get client %s: %w", id, err)
+	}
+	return c, nil
+}
+
+func TestHandleTokenWrappedNotFound(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, server := newTestServer(ctx, t, func(c *Config) {
+		c.Storage = &wrappingStorage{c.Storage}
+	})
+	defer httpServer.Close()
+
+	rr := httptest.NewRecorder()
+	client := storage.Client{ID: "unknown", Secret: "secret"}
+	server.ServeHTTP(rr, tokenRequest(client, "10.0.0.1:1234", url.Values{"grant_type": {grantTypeAuthorizationCode}}))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected wrapped not found error to be reported as invalid client, got %d", rr.Code)
+	}
+}
+
 func TestHandleInvalidOAuth2Callbacks(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -119,3 +154,92 @@ func TestHandleInvalidSAMLCallbacks(t *testing.T) {
 		}
 	}
 }
+
+func TestHandleTokenClientNetwork(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sink := new(recordingSink)
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.AuditSink = sink
+	})
+	defer httpServer.Close()
+
+	client := storage.Client{
+		ID:           "ci",
+		Secret:       "ci-secret",
+		AllowedCIDRs: []string{"10.20.0.0/16"},
+	}
+	if err := s.storage.CreateClient(ctx, client); err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	form := url.Values{"grant_type": {grantTypeAuthorizationCode}, "code": {"unknown"}}
+
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, tokenRequest(client, "192.0.2.1:1234", form))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected 403 from disallowed network, got %d", rr.Code)
+	}
+	if len(sink.events) != 1 || sink.events[0].Type != audit.EventClientNetworkDenied {
+		t.Errorf("expected a client_network_denied event, got %+v", sink.events)
+	}
+
+	// Requests from the allowed network proceed to the grant handler.
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, tokenRequest(client, "10.20.1.1:1234", form))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for unknown code, got %d", rr.Code)
+	}
+}
+
+func TestHandleAuthCodeClockSkew(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.ClockSkewTolerance = 2 * time.Minute
+	})
+	defer httpServer.Close()
+
+	client := storage.Client{
+		ID:           "testclient",
+		Secret:       "testclientsecret",
+		RedirectURIs: []string{"https://example.com/callback"},
+	}
+	if err := s.storage.CreateClient(ctx, client); err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	for _, tc := range []struct {
+		expiredFor time.Duration
+		wantCode   int
+	}{
+		{expiredFor: time.Minute, wantCode: http.StatusOK},
+		{expiredFor: 3 * time.Minute, wantCode: http.StatusBadRequest},
+	} {
+		code := storage.AuthCode{
+			ID:          storage.NewID(),
+			ClientID:    client.ID,
+			RedirectURI: client.RedirectURIs[0],
+			Scopes:      []string{"openid"},
+			ConnectorID: "mock",
+			Claims:      storage.Claims{UserID: "1", Email: "jane.doe@example.com"},
+			Expiry:      time.Now().Add(-tc.expiredFor),
+		}
+		if err := s.storage.CreateAuthCode(ctx, code); err != nil {
+			t.Fatalf("failed to create auth code: %v", err)
+		}
+
+		form := url.Values{
+			"grant_type":   {grantTypeAuthorizationCode},
+			"code":         {code.ID},
+			"redirect_uri": {code.RedirectURI},
+		}
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, tokenRequest(client, "10.0.0.1:1234", form))
+		if rr.Code != tc.wantCode {
+			t.Errorf("code expired for %s: expected %d, got %d", tc.expiredFor, tc.wantCode, rr.Code)
+		}
+	}
+}
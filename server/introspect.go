@@ -0,0 +1,227 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	oidc "github.com/coreos/go-oidc"
+
+	"github.com/dexidp/dex/server/internal"
+	"github.com/dexidp/dex/storage"
+)
+
+// Reasons refresh tokens are revoked for, reported by introspection.
+const (
+	revocationRevoked       = "revoked"
+	revocationReuseDetected = "reuse_detected"
+	revocationClientExpired = "client_expired"
+	revocationSuperseded    = "superseded"
+)
+
+// tombstoneRefresh records that a refresh token was revoked, so
+// introspection can tell it apart from a token that never existed until the
+// tombstone expires.
+func tombstoneRefresh(ctx context.Context, s storage.Storage, refreshID, clientID, reason string, now time.Time, validFor time.Duration) error {
+	err := s.CreateRevokedToken(ctx, storage.RevokedToken{
+		ID:        refreshID,
+		ClientID:  clientID,
+		Reason:    reason,
+		RevokedAt: now,
+		Expiry:    now.Add(validFor),
+	})
+	if err != nil && !errors.Is(err, storage.ErrAlreadyExists) {
+		return err
+	}
+	return nil
+}
+
+// tombstoneRefresh records the revocation of a refresh token, logging
+// failures. Failing to record it doesn't undo the revocation.
+func (s *Server) tombstoneRefresh(ctx context.Context, refreshID, clientID, reason string) {
+	if err := tombstoneRefresh(ctx, s.storage, refreshID, clientID, reason, s.now(), s.revokedTokensValidFor); err != nil {
+		s.logger.Errorf("failed to record revocation of refresh token: %v", err)
+	}
+}
+
+// introspection is the response of the introspection endpoint, see RFC 7662
+// section 2.2. Inactive tokens only carry the active field, unless they're
+// refresh tokens revoked recently enough to still have a tombstone.
+type introspection struct {
+	Active    bool     `json:"active"`
+	Scope     string   `json:"scope,omitempty"`
+	ClientID  string   `json:"client_id,omitempty"`
+	Username  string   `json:"username,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
+	Expiry    int64    `json:"exp,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	Audience  []string `json:"aud,omitempty"`
+	Issuer    string   `json:"iss,omitempty"`
+	ID        string   `json:"jti,omitempty"`
+
+	RevokedAt        int64  `json:"revoked_at,omitempty"`
+	RevocationReason string `json:"revocation_reason,omitempty"`
+}
+
+// handleIntrospect handles the token introspection endpoint. Clients can
+// introspect their own refresh tokens and access tokens issued for them.
+func (s *Server) handleIntrospect(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.tokenErrHelper(w, errInvalidRequest, "method not allowed", http.StatusBadRequest)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		s.tokenErrHelper(w, errInvalidRequest, "Couldn't parse data", http.StatusBadRequest)
+		return
+	}
+	if s.shed(w, endpointGroupIntrospection) {
+		s.tokenErrHelper(w, errTemporarilyUnavailable, "The server is overloaded, retry later.", http.StatusServiceUnavailable)
+		return
+	}
+	client, ok := s.authenticateClient(w, r)
+	if !ok {
+		return
+	}
+	token := r.PostForm.Get("token")
+	if token == "" {
+		s.tokenErrHelper(w, errInvalidRequest, "Missing token.", http.StatusBadRequest)
+		return
+	}
+
+	introspectors := []func(context.Context, storage.Client, string) (introspection, bool, error){
+		s.introspectAccessToken, s.introspectRefreshToken,
+	}
+	if r.PostForm.Get("token_type_hint") == "refresh_token" {
+		introspectors[0], introspectors[1] = introspectors[1], introspectors[0]
+	}
+	var resp introspection
+	for _, introspect := range introspectors {
+		result, ok, err := introspect(r.Context(), client, token)
+		if err != nil {
+			s.logger.Errorf("failed to introspect token: %v", err)
+			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+			return
+		}
+		if ok {
+			resp = result
+			break
+		}
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		s.logger.Errorf("failed to marshal introspection response: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
+
+// introspectRefreshToken reports whether the token is a refresh token of the
+// client, and if so describes it.
+func (s *Server) introspectRefreshToken(ctx context.Context, client storage.Client, raw string) (introspection, bool, error) {
+	token := new(internal.RefreshToken)
+	if err := internal.Unmarshal(raw, token); err != nil {
+		return introspection{}, false, nil
+	}
+	refresh, err := s.storage.GetRefresh(ctx, token.RefreshId)
+	switch {
+	case err == nil:
+		if refresh.ClientID != client.ID {
+			return introspection{}, false, nil
+		}
+		if refresh.Token != token.Token {
+			// Rotated away, the token is no longer valid.
+			return introspection{}, true, nil
+		}
+		return introspection{
+			Active:    true,
+			Scope:     strings.Join(refresh.Scopes, " "),
+			ClientID:  refresh.ClientID,
+			Username:  refresh.Claims.Username,
+			TokenType: "refresh_token",
+			IssuedAt:  refresh.CreatedAt.Unix(),
+			Subject:   subjectFor(refresh.Claims.UserID, refresh.ConnectorID),
+			Issuer:    s.issuerURL.String(),
+			ID:        refresh.ID,
+		}, true, nil
+	case !errors.Is(err, storage.ErrNotFound):
+		return introspection{}, false, err
+	}
+
+	revoked, err := s.storage.GetRevokedToken(ctx, token.RefreshId)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return introspection{}, false, nil
+		}
+		return introspection{}, false, err
+	}
+	if revoked.ClientID != client.ID {
+		return introspection{}, false, nil
+	}
+	return introspection{
+		ID:               revoked.ID,
+		RevokedAt:        revoked.RevokedAt.Unix(),
+		RevocationReason: revoked.Reason,
+	}, true, nil
+}
+
+// tokenVerifier verifies tokens signed by dex for any audience, allowing for
+// the clock skew tolerance.
+func (s *Server) tokenVerifier() *oidc.IDTokenVerifier {
+	verifier := oidc.NewVerifier(s.issuerURL.String(), s.verificationKeys(), &oidc.Config{
+		SkipClientIDCheck: true,
+		Now:               func() time.Time { return s.now().Add(-s.clockSkewTolerance) },
+	})
+	return verifier
+}
+
+// verificationKeys returns the keys tokens issued by dex are signed with.
+func (s *Server) verificationKeys() *storageKeySet {
+	keySet := &storageKeySet{Storage: s.storage}
+	if s.signingMigration != nil {
+		keySet.extra = append(keySet.extra, s.signingMigration.signer.PublicKey())
+	}
+	return keySet
+}
+
+// introspectAccessToken reports whether the token is an unexpired access
+// token issued for the client, and if so describes it.
+func (s *Server) introspectAccessToken(ctx context.Context, client storage.Client, raw string) (introspection, bool, error) {
+	token, err := s.tokenVerifier().Verify(ctx, raw)
+	if err != nil {
+		return introspection{}, false, nil
+	}
+	var claims struct {
+		AuthorizingParty string `json:"azp"`
+		Name             string `json:"name"`
+	}
+	if err := token.Claims(&claims); err != nil {
+		return introspection{}, false, nil
+	}
+	if !contains(token.Audience, client.ID) && claims.AuthorizingParty != client.ID {
+		return introspection{}, false, nil
+	}
+	clientID := claims.AuthorizingParty
+	if clientID == "" && len(token.Audience) > 0 {
+		clientID = token.Audience[0]
+	}
+	return introspection{
+		Active:    true,
+		ClientID:  clientID,
+		Username:  claims.Name,
+		TokenType: "Bearer",
+		Expiry:    token.Expiry.Unix(),
+		IssuedAt:  token.IssuedAt.Unix(),
+		Subject:   token.Subject,
+		Audience:  token.Audience,
+		Issuer:    token.Issuer,
+	}, true, nil
+}
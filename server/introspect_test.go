@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dexidp/dex/server/internal"
+	"github.com/dexidp/dex/storage"
+)
+
+func TestIntrospect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, nil)
+	defer httpServer.Close()
+
+	client := storage.Client{ID: "app", Secret: "secret"}
+	other := storage.Client{ID: "other", Secret: "secret"}
+	for _, c := range []storage.Client{client, other} {
+		if err := s.storage.CreateClient(ctx, c); err != nil {
+			t.Fatalf("create client: %v", err)
+		}
+	}
+	claims := storage.Claims{UserID: "1", Username: "jane", Email: "jane.doe@example.com"}
+	refresh := storage.RefreshToken{
+		ID:          storage.NewID(),
+		Token:       "token",
+		ClientID:    client.ID,
+		ConnectorID: "mock",
+		Scopes:      []string{"openid", "offline_access"},
+		Claims:      claims,
+		CreatedAt:   time.Now(),
+		LastUsed:    time.Now(),
+	}
+	if err := s.storage.CreateRefresh(ctx, refresh); err != nil {
+		t.Fatalf("create refresh token: %v", err)
+	}
+	rawRefresh, err := internal.Marshal(&internal.RefreshToken{RefreshId: refresh.ID, Token: refresh.Token})
+	if err != nil {
+		t.Fatal(err)
+	}
+	accessToken, err := s.newAccessToken(ctx, client.ID, claims, []string{"openid"}, "", "mock")
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+
+	introspect := func(c storage.Client, token, hint string) map[string]interface{} {
+		req := tokenRequest(c, "10.0.0.1:1234", url.Values{"token": {token}, "token_type_hint": {hint}})
+		req.URL.Path = "/token/introspect"
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body)
+		}
+		var resp map[string]interface{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode introspection response: %v", err)
+		}
+		return resp
+	}
+	inactive := func(name string, resp map[string]interface{}) {
+		if len(resp) != 1 || resp["active"] != false {
+			t.Errorf("%s: expected only an inactive token, got %v", name, resp)
+		}
+	}
+
+	if resp := introspect(client, rawRefresh, "refresh_token"); resp["active"] != true || resp["jti"] != refresh.ID || resp["token_type"] != "refresh_token" {
+		t.Errorf("expected active refresh token, got %v", resp)
+	}
+	if resp := introspect(client, accessToken, ""); resp["active"] != true || resp["client_id"] != client.ID || resp["sub"] != subjectFor("1", "mock") {
+		t.Errorf("expected active access token, got %v", resp)
+	}
+	inactive("access token of another client", introspect(other, accessToken, ""))
+	inactive("unknown token", introspect(client, "unknown", ""))
+
+	if err := s.revokeRefreshFamily(ctx, claims.UserID, "mock", client.ID, refresh.ID, revocationReuseDetected); err != nil {
+		t.Fatalf("revoke refresh token: %v", err)
+	}
+	resp := introspect(client, rawRefresh, "refresh_token")
+	if resp["active"] != false || resp["jti"] != refresh.ID || resp["revocation_reason"] != revocationReuseDetected || resp["revoked_at"] == nil {
+		t.Errorf("expected revoked refresh token, got %v", resp)
+	}
+	inactive("revoked refresh token of another client", introspect(other, rawRefresh, "refresh_token"))
+
+	if _, err := s.storage.GarbageCollect(ctx, time.Now().Add(25*time.Hour)); err != nil {
+		t.Fatalf("garbage collect: %v", err)
+	}
+	inactive("refresh token without tombstone", introspect(client, rawRefresh, "refresh_token"))
+}
@@ -0,0 +1,72 @@
+package server
+
+import "sync"
+
+// otherLabelValue replaces label values dropped by a labelGuard.
+const otherLabelValue = "other"
+
+// defaultMaxLabelValues is the default number of distinct values, besides
+// the allowed ones, a guarded label reports as is.
+const defaultMaxLabelValues = 100
+
+// MetricLabels limits the distinct values of metric labels whose values come
+// from clients or operators rather than from dex itself. Every distinct value
+// creates a new time series, so unbounded labels can overwhelm Prometheus.
+type MetricLabels struct {
+	// Policy for the client_id label.
+	ClientID MetricLabelPolicy
+	// Policy for the connector label.
+	Connector MetricLabelPolicy
+}
+
+// MetricLabelPolicy decides which values of a label are reported as is. All
+// other values are reported as "other".
+type MetricLabelPolicy struct {
+	// Values always reported as is.
+	Allow []string
+
+	// Number of further values reported as is, in the order they are first
+	// seen. Defaults to 100. Negative values report only allowed values.
+	MaxValues int
+}
+
+// labelGuard applies a MetricLabelPolicy.
+type labelGuard struct {
+	allow map[string]bool
+	max   int
+
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+func newLabelGuard(p MetricLabelPolicy) *labelGuard {
+	g := &labelGuard{
+		allow: make(map[string]bool, len(p.Allow)),
+		max:   p.MaxValues,
+		seen:  make(map[string]bool),
+	}
+	if g.max == 0 {
+		g.max = defaultMaxLabelValues
+	}
+	for _, v := range p.Allow {
+		g.allow[v] = true
+	}
+	return g
+}
+
+// value returns the value to report for v.
+func (g *labelGuard) value(v string) string {
+	if g.allow[v] {
+		return v
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.seen[v] {
+		return v
+	}
+	if len(g.seen) >= g.max {
+		return otherLabelValue
+	}
+	g.seen[v] = true
+	return v
+}
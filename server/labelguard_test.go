@@ -0,0 +1,50 @@
+package server
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestLabelGuard(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy MetricLabelPolicy
+		values []string
+		want   []string
+	}{
+		{
+			name:   "max values",
+			policy: MetricLabelPolicy{MaxValues: 2},
+			values: []string{"a", "b", "c", "a", "b", "d"},
+			want:   []string{"a", "b", "other", "a", "b", "other"},
+		},
+		{
+			name:   "allowed values don't count towards the maximum",
+			policy: MetricLabelPolicy{Allow: []string{"x"}, MaxValues: 1},
+			values: []string{"x", "a", "x", "b"},
+			want:   []string{"x", "a", "x", "other"},
+		},
+		{
+			name:   "only allowed values",
+			policy: MetricLabelPolicy{Allow: []string{"x"}, MaxValues: -1},
+			values: []string{"a", "x"},
+			want:   []string{"other", "x"},
+		},
+	}
+	for _, tc := range tests {
+		g := newLabelGuard(tc.policy)
+		for i, v := range tc.values {
+			if got := g.value(v); got != tc.want[i] {
+				t.Errorf("%s: value %d: expected %q, got %q", tc.name, i, tc.want[i], got)
+			}
+		}
+	}
+
+	g := newLabelGuard(MetricLabelPolicy{})
+	for i := 0; i < defaultMaxLabelValues; i++ {
+		g.value(strconv.Itoa(i))
+	}
+	if got := g.value("one too many"); got != otherLabelValue {
+		t.Errorf("expected values beyond the default maximum to be reported as %q, got %q", otherLabelValue, got)
+	}
+}
@@ -0,0 +1,272 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/dexidp/dex/storage"
+)
+
+// LoadShedding configures rejecting low-priority requests while the storage
+// is slow or failing, so interactive logins keep being served. Shedding is
+// disabled unless a threshold is set.
+type LoadShedding struct {
+	// 99th percentile latency of storage calls beyond which the storage is
+	// considered degraded.
+	LatencyThreshold time.Duration
+
+	// Fraction of failing storage calls, between 0 and 1, beyond which the
+	// storage is considered degraded.
+	ErrorRateThreshold float64
+
+	// Storage calls are measured over this window. Defaults to 1 minute.
+	Window time.Duration
+
+	// Sent to shed requests in the Retry-After header. Defaults to 30
+	// seconds.
+	RetryAfter time.Duration
+}
+
+const (
+	// Storage health isn't judged on fewer calls within the window.
+	minHealthSamples = 20
+	// Bounds the memory used to measure storage calls.
+	maxHealthSamples = 2000
+	// How often the health of the storage is re-evaluated.
+	healthEvaluationInterval = time.Second
+)
+
+type healthSample struct {
+	at      time.Time
+	latency time.Duration
+	failed  bool
+}
+
+// storageHealth measures the latency and failures of storage calls and
+// determines which requests to shed. Once the p99 latency or error rate
+// crosses its threshold, admin requests are shed; at one and a half times
+// the threshold background requests too, and at twice the threshold
+// refresh requests as well.
+type storageHealth struct {
+	config LoadShedding
+	now    func() time.Time
+
+	mu          sync.Mutex
+	samples     []healthSample
+	next        int
+	evaluatedAt time.Time
+	minPriority priority
+}
+
+func newStorageHealth(c LoadShedding, now func() time.Time) *storageHealth {
+	if c.LatencyThreshold <= 0 && c.ErrorRateThreshold <= 0 {
+		return nil
+	}
+	c.Window = value(c.Window, time.Minute)
+	c.RetryAfter = value(c.RetryAfter, 30*time.Second)
+	return &storageHealth{config: c, now: now}
+}
+
+// observe records a storage call which started at start. Missing or
+// conflicting objects are expected and don't count as failures.
+func (h *storageHealth) observe(start time.Time, err error) {
+	failed := err != nil && !errors.Is(err, storage.ErrNotFound) && !errors.Is(err, storage.ErrAlreadyExists)
+	sample := healthSample{at: h.now(), latency: time.Since(start), failed: failed}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.samples) < maxHealthSamples {
+		h.samples = append(h.samples, sample)
+		return
+	}
+	h.samples[h.next] = sample
+	h.next = (h.next + 1) % maxHealthSamples
+}
+
+// shedding reports whether requests of priority p are currently shed.
+func (h *storageHealth) shedding(p priority) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := h.now()
+	if now.Sub(h.evaluatedAt) >= healthEvaluationInterval {
+		h.minPriority = h.evaluate(now)
+		h.evaluatedAt = now
+	}
+	return p < h.minPriority
+}
+
+// evaluate returns the lowest priority of requests to serve, given the
+// storage calls within the window.
+func (h *storageHealth) evaluate(now time.Time) priority {
+	cutoff := now.Add(-h.config.Window)
+	var (
+		latencies []time.Duration
+		failures  int
+	)
+	for _, sample := range h.samples {
+		if sample.at.Before(cutoff) {
+			continue
+		}
+		latencies = append(latencies, sample.latency)
+		if sample.failed {
+			failures++
+		}
+	}
+	if len(latencies) < minHealthSamples {
+		return priorityAdmin
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	p99 := latencies[(len(latencies)*99-1)/100]
+	errorRate := float64(failures) / float64(len(latencies))
+
+	// How far past its threshold the worst signal is.
+	var ratio float64
+	if t := h.config.LatencyThreshold; t > 0 {
+		ratio = float64(p99) / float64(t)
+	}
+	if t := h.config.ErrorRateThreshold; t > 0 && errorRate/t > ratio {
+		ratio = errorRate / t
+	}
+	switch {
+	case ratio >= 2:
+		return priorityInteractive
+	case ratio >= 1.5:
+		return priorityRefresh
+	case ratio >= 1:
+		return priorityBackground
+	}
+	return priorityAdmin
+}
+
+func newShedCounter(registry *prometheus.Registry) (*prometheus.CounterVec, error) {
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_shed_total",
+		Help: "Count of requests rejected while the storage is degraded, by priority class.",
+	}, []string{"priority"})
+	if registry != nil {
+		if err := registry.Register(counter); err != nil {
+			return nil, fmt.Errorf("register load shedding metrics: %w", err)
+		}
+	}
+	return counter, nil
+}
+
+// shed reports whether a request of the endpoint group must be rejected
+// because the storage is degraded. If so, it sets the Retry-After header and
+// the caller responds with a 503 in the endpoint's error format.
+func (s *Server) shed(w http.ResponseWriter, group string) bool {
+	p := s.priority(group)
+	if s.storageHealth == nil || !s.storageHealth.shedding(p) {
+		return false
+	}
+	s.shedRequests.WithLabelValues(p.String()).Inc()
+	w.Header().Set("Retry-After", strconv.Itoa(int(s.storageHealth.config.RetryAfter.Seconds())))
+	return true
+}
+
+// healthRecorder measures the storage calls made while serving logins and
+// token requests.
+type healthRecorder struct {
+	storage.Storage
+
+	health *storageHealth
+}
+
+func (r healthRecorder) CreateAuthRequest(ctx context.Context, a storage.AuthRequest) error {
+	start := time.Now()
+	err := r.Storage.CreateAuthRequest(ctx, a)
+	r.health.observe(start, err)
+	return err
+}
+
+func (r healthRecorder) GetAuthRequest(ctx context.Context, id string) (storage.AuthRequest, error) {
+	start := time.Now()
+	v, err := r.Storage.GetAuthRequest(ctx, id)
+	r.health.observe(start, err)
+	return v, err
+}
+
+func (r healthRecorder) UpdateAuthRequest(ctx context.Context, id string, updater func(a storage.AuthRequest) (storage.AuthRequest, error)) error {
+	start := time.Now()
+	err := r.Storage.UpdateAuthRequest(ctx, id, updater)
+	r.health.observe(start, err)
+	return err
+}
+
+func (r healthRecorder) CreateAuthCode(ctx context.Context, c storage.AuthCode) error {
+	start := time.Now()
+	err := r.Storage.CreateAuthCode(ctx, c)
+	r.health.observe(start, err)
+	return err
+}
+
+func (r healthRecorder) GetAuthCode(ctx context.Context, id string) (storage.AuthCode, error) {
+	start := time.Now()
+	v, err := r.Storage.GetAuthCode(ctx, id)
+	r.health.observe(start, err)
+	return v, err
+}
+
+func (r healthRecorder) DeleteAuthCode(ctx context.Context, code string) error {
+	start := time.Now()
+	err := r.Storage.DeleteAuthCode(ctx, code)
+	r.health.observe(start, err)
+	return err
+}
+
+func (r healthRecorder) GetClient(ctx context.Context, id string) (storage.Client, error) {
+	start := time.Now()
+	v, err := r.Storage.GetClient(ctx, id)
+	r.health.observe(start, err)
+	return v, err
+}
+
+func (r healthRecorder) CreateRefresh(ctx context.Context, t storage.RefreshToken) error {
+	start := time.Now()
+	err := r.Storage.CreateRefresh(ctx, t)
+	r.health.observe(start, err)
+	return err
+}
+
+func (r healthRecorder) GetRefresh(ctx context.Context, id string) (storage.RefreshToken, error) {
+	start := time.Now()
+	v, err := r.Storage.GetRefresh(ctx, id)
+	r.health.observe(start, err)
+	return v, err
+}
+
+func (r healthRecorder) UpdateRefreshToken(ctx context.Context, id string, updater func(t storage.RefreshToken) (storage.RefreshToken, error)) error {
+	start := time.Now()
+	err := r.Storage.UpdateRefreshToken(ctx, id, updater)
+	r.health.observe(start, err)
+	return err
+}
+
+func (r healthRecorder) GetOfflineSessions(ctx context.Context, userID string, connID string) (storage.OfflineSessions, error) {
+	start := time.Now()
+	v, err := r.Storage.GetOfflineSessions(ctx, userID, connID)
+	r.health.observe(start, err)
+	return v, err
+}
+
+func (r healthRecorder) UpdateOfflineSessions(ctx context.Context, userID string, connID string, updater func(s storage.OfflineSessions) (storage.OfflineSessions, error)) error {
+	start := time.Now()
+	err := r.Storage.UpdateOfflineSessions(ctx, userID, connID, updater)
+	r.health.observe(start, err)
+	return err
+}
+
+func (r healthRecorder) GetKeys(ctx context.Context) (storage.Keys, error) {
+	start := time.Now()
+	v, err := r.Storage.GetKeys(ctx)
+	r.health.observe(start, err)
+	return v, err
+}
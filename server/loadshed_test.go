@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dexidp/dex/storage"
+)
+
+func TestStorageHealth(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := newStorageHealth(LoadShedding{LatencyThreshold: 100 * time.Millisecond, ErrorRateThreshold: 0.1}, func() time.Time { return now })
+
+	record := func(n int, latency time.Duration, err error) {
+		for i := 0; i < n; i++ {
+			h.observe(time.Now().Add(-latency), err)
+		}
+	}
+	check := func(name string, wantShed priority) {
+		t.Helper()
+		now = now.Add(healthEvaluationInterval)
+		for _, p := range []priority{priorityAdmin, priorityBackground, priorityRefresh, priorityInteractive} {
+			if got, want := h.shedding(p), p < wantShed; got != want {
+				t.Errorf("%s: expected shedding %s to be %t", name, p, want)
+			}
+		}
+	}
+
+	record(minHealthSamples-1, time.Second, errors.New("timeout"))
+	check("too few samples", priorityAdmin)
+
+	now = now.Add(time.Minute)
+	record(200, time.Millisecond, storage.ErrNotFound)
+	check("healthy", priorityAdmin)
+
+	record(30, 150*time.Millisecond, nil)
+	check("slow", priorityRefresh)
+
+	record(10, 300*time.Millisecond, nil)
+	check("very slow", priorityInteractive)
+
+	now = now.Add(time.Minute)
+	record(90, time.Millisecond, nil)
+	record(10, time.Millisecond, errors.New("connection refused"))
+	check("failing", priorityBackground)
+
+	now = now.Add(time.Minute)
+	check("idle", priorityAdmin)
+}
+
+func TestLoadShedding(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.LoadShedding = LoadShedding{LatencyThreshold: 100 * time.Millisecond, RetryAfter: time.Minute}
+		c.Priorities = map[string]string{endpointGroupUserInfo: "background"}
+	})
+	defer httpServer.Close()
+
+	client := storage.Client{ID: "test", Secret: "barfoo", RedirectURIs: []string{"https://example.com/callback"}}
+	if err := s.storage.CreateClient(ctx, client); err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+	for i := 0; i < minHealthSamples; i++ {
+		s.storageHealth.observe(time.Now().Add(-150*time.Millisecond), nil)
+	}
+
+	introspect := httptest.NewRequest(http.MethodPost, "/token/introspect", strings.NewReader("token=abc"))
+	introspect.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	introspect.SetBasicAuth(client.ID, client.Secret)
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, introspect)
+	if rr.Code != http.StatusServiceUnavailable || rr.Header().Get("Retry-After") != "60" {
+		t.Errorf("expected introspection to be shed, got %d with Retry-After %q", rr.Code, rr.Header().Get("Retry-After"))
+	}
+
+	userInfo := httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, userInfo)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected userinfo configured as background to be shed, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, tokenRequest(client, "10.0.0.1:1234", url.Values{"grant_type": {grantTypeRefreshToken}, "refresh_token": {"unknown"}}))
+	if rr.Code == http.StatusServiceUnavailable {
+		t.Errorf("expected refresh token grant to be served while background requests are shed")
+	}
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Middleware wraps the server's HTTP handler, for example to add logging or
+// headers required by the environment dex is embedded in.
+type Middleware func(http.Handler) http.Handler
+
+// Route is an additional endpoint served alongside dex's own endpoints.
+type Route struct {
+	// Path relative to the issuer URL, for example "/status".
+	Path string
+	// If set, every path below Path is routed to Handler with Path stripped
+	// from the request URL.
+	Prefix bool
+
+	Handler http.Handler
+}
+
+func (r Route) validate() error {
+	if !strings.HasPrefix(r.Path, "/") {
+		return fmt.Errorf("route %q must start with a /", r.Path)
+	}
+	if r.Handler == nil {
+		return fmt.Errorf("route %q has no handler", r.Path)
+	}
+	return nil
+}
+
+// chain wraps h with the middleware. The first middleware is the outermost
+// one and sees requests first.
+func chain(h http.Handler, middleware []Middleware) http.Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+	return h
+}
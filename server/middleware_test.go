@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dexidp/dex/storage"
+	"github.com/dexidp/dex/storage/memory"
+)
+
+func TestMiddlewareAndRoutes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var order []string
+	middleware := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				w.Header().Set("X-Middleware", name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.Issuer = c.Issuer + "/dex"
+		c.Middleware = []Middleware{middleware("outer"), middleware("inner")}
+		c.Routes = []Route{
+			{Path: "/custom", Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, "ok")
+			})},
+			{Path: "/admin", Prefix: true, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, r.URL.Path)
+			})},
+		}
+	})
+	defer httpServer.Close()
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/dex/custom", "ok"},
+		{"/dex/admin/users", "/users"},
+	}
+	for _, tc := range tests {
+		order = nil
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		if got := strings.TrimSpace(rr.Body.String()); got != tc.want {
+			t.Errorf("%s: expected body %q, got %q", tc.path, tc.want, got)
+		}
+		if strings.Join(order, ",") != "outer,inner" {
+			t.Errorf("%s: expected middleware to run outer first, got %q", tc.path, order)
+		}
+		if got := rr.Header().Get("X-Middleware"); got != "inner" {
+			t.Errorf("%s: expected header from inner middleware, got %q", tc.path, got)
+		}
+	}
+
+	// Built in endpoints are wrapped too.
+	order = nil
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/dex/.well-known/openid-configuration", nil))
+	if rr.Code != http.StatusOK || len(order) != 2 {
+		t.Errorf("expected discovery to be served through middleware, got %d %q", rr.Code, order)
+	}
+}
+
+func TestRouteConflicts(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := memory.New(logger)
+	if err := s.CreateConnector(ctx, storage.Connector{ID: "mock", Type: "mockCallback", Name: "Mock"}); err != nil {
+		t.Fatalf("create connector: %v", err)
+	}
+	config := Config{
+		Issuer:  "http://localhost",
+		Storage: s,
+		Web:     WebConfig{Dir: "../web"},
+		Logger:  logger,
+	}
+	for _, route := range []Route{
+		{Path: "/token", Handler: http.NotFoundHandler()},
+		{Path: "/callback", Handler: http.NotFoundHandler()},
+		{Path: "custom", Handler: http.NotFoundHandler()},
+		{Path: "/custom"},
+	} {
+		config.Routes = []Route{route}
+		if _, err := newServer(ctx, config, staticRotationStrategy(testKey)); err == nil {
+			t.Errorf("expected route %q to be rejected", route.Path)
+		}
+	}
+}
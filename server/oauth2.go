@@ -23,6 +23,7 @@ import (
 	jose "gopkg.in/square/go-jose.v2"
 
 	"github.com/dexidp/dex/connector"
+	"github.com/dexidp/dex/pkg/alert"
 	"github.com/dexidp/dex/server/internal"
 	"github.com/dexidp/dex/storage"
 )
@@ -80,7 +81,7 @@ func tokenErr(w http.ResponseWriter, typ, description string, statusCode int) er
 	}{typ, description}
 	body, err := json.Marshal(data)
 	if err != nil {
-		return fmt.Errorf("failed to marshal token error response: %v", err)
+		return fmt.Errorf("failed to marshal token error response: %w", err)
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
@@ -102,6 +103,7 @@ const (
 	errInvalidGrant            = "invalid_grant"
 	errInvalidClient           = "invalid_client"
 	errInvalidConnectorID      = "invalid_connector_id"
+	errInvalidTarget           = "invalid_target"
 )
 
 const (
@@ -116,12 +118,26 @@ const (
 
 const (
 	redirectURIOOB = "urn:ietf:wg:oauth:2.0:oob"
+	// Like redirectURIOOB, but the code is also put in the page title, for
+	// apps which read it from the browser's window title.
+	redirectURIOOBAuto = "urn:ietf:wg:oauth:2.0:oob:auto"
 )
 
+// isOOBRedirectURI reports whether the code is displayed to the user instead
+// of being redirected to the client.
+func isOOBRedirectURI(redirectURI string) bool {
+	return redirectURI == redirectURIOOB || redirectURI == redirectURIOOBAuto
+}
+
 const (
 	grantTypeAuthorizationCode = "authorization_code"
 	grantTypeRefreshToken      = "refresh_token"
 	grantTypePassword          = "password"
+	grantTypeAPIKey            = "urn:dexidp:params:oauth:grant-type:api-key"
+	grantTypeJWTBearer         = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+	grantTypeClientCredentials = "client_credentials"
+	grantTypeTokenExchange     = "urn:ietf:params:oauth:grant-type:token-exchange"
+	grantTypePreAuthorizedCode = "urn:ietf:params:oauth:grant-type:pre-authorized_code"
 )
 
 const (
@@ -182,11 +198,11 @@ func signPayload(key *jose.JSONWebKey, alg jose.SignatureAlgorithm, payload []by
 
 	signer, err := jose.NewSigner(signingKey, &jose.SignerOptions{})
 	if err != nil {
-		return "", fmt.Errorf("new signier: %v", err)
+		return "", fmt.Errorf("new signier: %w", err)
 	}
 	signature, err := signer.Sign(payload)
 	if err != nil {
-		return "", fmt.Errorf("signing payload: %v", err)
+		return "", fmt.Errorf("signing payload: %w", err)
 	}
 	return signature.CompactSerialize()
 }
@@ -219,7 +235,7 @@ func accessTokenHash(alg jose.SignatureAlgorithm, accessToken string) (string, e
 
 	hash := newHash()
 	if _, err := io.WriteString(hash, accessToken); err != nil {
-		return "", fmt.Errorf("computing hash: %v", err)
+		return "", fmt.Errorf("computing hash: %w", err)
 	}
 	sum := hash.Sum(nil)
 	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2]), nil
@@ -249,6 +265,7 @@ type idTokenClaims struct {
 	Audience         audience `json:"aud"`
 	Expiry           int64    `json:"exp"`
 	IssuedAt         int64    `json:"iat"`
+	ID               string   `json:"jti,omitempty"`
 	AuthorizingParty string   `json:"azp,omitempty"`
 	Nonce            string   `json:"nonce,omitempty"`
 
@@ -263,6 +280,14 @@ type idTokenClaims struct {
 	PreferredUsername string `json:"preferred_username,omitempty"`
 
 	FederatedIDClaims *federatedIDClaims `json:"federated_claims,omitempty"`
+
+	// Actor is the party acting on behalf of the subject of an exchanged
+	// token, see RFC 8693 section 4.1.
+	Actor *actorClaims `json:"act,omitempty"`
+}
+
+type actorClaims struct {
+	Subject string `json:"sub"`
 }
 
 type federatedIDClaims struct {
@@ -270,24 +295,34 @@ type federatedIDClaims struct {
 	UserID      string `json:"user_id,omitempty"`
 }
 
-func (s *Server) newAccessToken(clientID string, claims storage.Claims, scopes []string, nonce, connID string) (accessToken string, err error) {
-	idToken, _, err := s.newIDToken(clientID, claims, scopes, nonce, storage.NewID(), connID)
+func (s *Server) newAccessToken(ctx context.Context, clientID string, claims storage.Claims, scopes []string, nonce, connID string) (accessToken string, err error) {
+	idToken, _, err := s.newIDToken(ctx, clientID, claims, scopes, nonce, storage.NewID(), connID)
 	return idToken, err
 }
 
-func (s *Server) newIDToken(clientID string, claims storage.Claims, scopes []string, nonce, accessToken, connID string) (idToken string, expiry time.Time, err error) {
-	keys, err := s.storage.GetKeys()
+func (s *Server) newIDToken(ctx context.Context, clientID string, claims storage.Claims, scopes []string, nonce, accessToken, connID string) (idToken string, expiry time.Time, err error) {
+	return s.newActorIDToken(ctx, clientID, claims, scopes, nonce, accessToken, connID, nil)
+}
+
+// newActorIDToken is like newIDToken, additionally naming the actor of an
+// exchanged token, if any.
+func (s *Server) newActorIDToken(ctx context.Context, clientID string, claims storage.Claims, scopes []string, nonce, accessToken, connID string, actor *actorClaims) (idToken string, expiry time.Time, err error) {
+	keys, err := s.storage.GetKeys(ctx)
 	if err != nil {
 		s.logger.Errorf("Failed to get keys: %v", err)
+		s.reportFailure(alert.KindStorage, "", err)
 		return "", expiry, err
 	}
 
 	signingKey := keys.SigningKey
 	if signingKey == nil {
-		return "", expiry, fmt.Errorf("no key to sign payload with")
+		err := errors.New("no key to sign payload with")
+		s.reportFailure(alert.KindSigning, "", err)
+		return "", expiry, err
 	}
 	signingAlg, err := signatureAlgorithm(signingKey)
 	if err != nil {
+		s.reportFailure(alert.KindSigning, "", err)
 		return "", expiry, err
 	}
 
@@ -302,7 +337,10 @@ func (s *Server) newIDToken(clientID string, claims storage.Claims, scopes []str
 	subjectString, err := internal.Marshal(sub)
 	if err != nil {
 		s.logger.Errorf("failed to marshal offline session ID: %v", err)
-		return "", expiry, fmt.Errorf("failed to marshal offline session ID: %v", err)
+		return "", expiry, fmt.Errorf("failed to marshal offline session ID: %w", err)
+	}
+	if connID == spiffeIDConnectorID {
+		subjectString = claims.UserID
 	}
 
 	tok := idTokenClaims{
@@ -311,13 +349,22 @@ func (s *Server) newIDToken(clientID string, claims storage.Claims, scopes []str
 		Nonce:    nonce,
 		Expiry:   expiry.Unix(),
 		IssuedAt: issuedAt.Unix(),
+		ID:       s.trackIssuedToken(clientID, expiry),
+		Actor:    actor,
+	}
+
+	// While migrating signing keys, tokens are signed by the new signer so
+	// the at_hash must use its algorithm.
+	tokenAlg := signingAlg
+	if s.signingMigration != nil {
+		tokenAlg = s.signingMigration.signer.Algorithm()
 	}
 
 	if accessToken != "" {
-		atHash, err := accessTokenHash(signingAlg, accessToken)
+		atHash, err := accessTokenHash(tokenAlg, accessToken)
 		if err != nil {
 			s.logger.Errorf("error computing at_hash: %v", err)
-			return "", expiry, fmt.Errorf("error computing at_hash: %v", err)
+			return "", expiry, fmt.Errorf("error computing at_hash: %w", err)
 		}
 		tok.AccessTokenHash = atHash
 	}
@@ -338,21 +385,25 @@ func (s *Server) newIDToken(clientID string, claims storage.Claims, scopes []str
 				UserID:      claims.UserID,
 			}
 		default:
-			peerID, ok := parseCrossClientScope(scope)
+			peerIDs, ok := s.requestedAudiences(scope)
 			if !ok {
 				// Ignore unknown scopes. These are already validated during the
 				// initial auth request.
 				continue
 			}
-			isTrusted, err := s.validateCrossClientTrust(clientID, peerID)
-			if err != nil {
-				return "", expiry, err
-			}
-			if !isTrusted {
-				// TODO(ericchiang): propagate this error to the client.
-				return "", expiry, fmt.Errorf("peer (%s) does not trust client", peerID)
+			for _, peerID := range peerIDs {
+				isTrusted, err := s.validateCrossClientTrust(ctx, clientID, peerID)
+				if err != nil {
+					return "", expiry, err
+				}
+				if !isTrusted {
+					// TODO(ericchiang): propagate this error to the client.
+					return "", expiry, fmt.Errorf("peer (%s) does not trust client", peerID)
+				}
+				if !tok.Audience.contains(peerID) {
+					tok.Audience = append(tok.Audience, peerID)
+				}
 			}
-			tok.Audience = append(tok.Audience, peerID)
 		}
 	}
 
@@ -373,17 +424,41 @@ func (s *Server) newIDToken(clientID string, claims storage.Claims, scopes []str
 
 	payload, err := json.Marshal(tok)
 	if err != nil {
-		return "", expiry, fmt.Errorf("could not serialize claims: %v", err)
+		return "", expiry, fmt.Errorf("could not serialize claims: %w", err)
+	}
+
+	client, err := s.getClient(ctx, clientID)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		s.logger.Errorf("failed to get client %q: %v", clientID, err)
+		return "", expiry, fmt.Errorf("failed to get client: %w", err)
+	}
+	if payload, err = addClientClaims(payload, client.Claims); err != nil {
+		return "", expiry, fmt.Errorf("could not add client claims: %w", err)
+	}
+
+	if m := s.signingMigration; m != nil {
+		if idToken, err = m.signer.Sign(ctx, payload); err != nil {
+			s.reportFailure(alert.KindSigning, "migration", err)
+			return "", expiry, fmt.Errorf("failed to sign payload with migration signer: %w", err)
+		}
+		result, err := m.compare(signingKey, signingAlg, payload, idToken)
+		if err != nil {
+			s.logger.Errorf("shadow signature check failed: %v", err)
+		}
+		m.results.WithLabelValues(result).Inc()
+		return idToken, expiry, nil
 	}
 
 	if idToken, err = signPayload(signingKey, signingAlg, payload); err != nil {
-		return "", expiry, fmt.Errorf("failed to sign payload: %v", err)
+		s.reportFailure(alert.KindSigning, "", err)
+		return "", expiry, fmt.Errorf("failed to sign payload: %w", err)
 	}
 	return idToken, expiry, nil
 }
 
 // parse the initial request from the OAuth2 client.
 func (s *Server) parseAuthorizationRequest(r *http.Request) (*storage.AuthRequest, error) {
+	ctx := r.Context()
 	if err := r.ParseForm(); err != nil {
 		return nil, &authErr{"", "", errInvalidRequest, "Failed to parse request body."}
 	}
@@ -401,9 +476,9 @@ func (s *Server) parseAuthorizationRequest(r *http.Request) (*storage.AuthReques
 	scopes := strings.Fields(q.Get("scope"))
 	responseTypes := strings.Fields(q.Get("response_type"))
 
-	client, err := s.storage.GetClient(clientID)
+	client, err := s.getClient(ctx, clientID)
 	if err != nil {
-		if err == storage.ErrNotFound {
+		if errors.Is(err, storage.ErrNotFound) {
 			description := fmt.Sprintf("Invalid client_id (%q).", clientID)
 			return nil, &authErr{"", "", errUnauthorizedClient, description}
 		}
@@ -412,7 +487,7 @@ func (s *Server) parseAuthorizationRequest(r *http.Request) (*storage.AuthReques
 	}
 
 	if connectorID != "" {
-		connectors, err := s.storage.ListConnectors()
+		connectors, err := s.storage.ListConnectors(ctx)
 		if err != nil {
 			return nil, &authErr{"", "", errServerError, "Unable to retrieve connectors"}
 		}
@@ -442,18 +517,23 @@ func (s *Server) parseAuthorizationRequest(r *http.Request) (*storage.AuthReques
 			hasOpenIDScope = true
 		case scopeOfflineAccess, scopeEmail, scopeProfile, scopeGroups, scopeFederatedID:
 		default:
-			peerID, ok := parseCrossClientScope(scope)
+			peerIDs, ok := s.requestedAudiences(scope)
 			if !ok {
-				unrecognized = append(unrecognized, scope)
+				if _, ok := s.customScopes[scope]; !ok {
+					unrecognized = append(unrecognized, scope)
+				}
 				continue
 			}
 
-			isTrusted, err := s.validateCrossClientTrust(clientID, peerID)
-			if err != nil {
-				return nil, newErr(errServerError, "Internal server error.")
-			}
-			if !isTrusted {
-				invalidScopes = append(invalidScopes, scope)
+			for _, peerID := range peerIDs {
+				isTrusted, err := s.validateCrossClientTrust(ctx, clientID, peerID)
+				if err != nil {
+					return nil, newErr(errServerError, "Internal server error.")
+				}
+				if !isTrusted {
+					invalidScopes = append(invalidScopes, scope)
+					break
+				}
 			}
 		}
 	}
@@ -510,12 +590,17 @@ func (s *Server) parseAuthorizationRequest(r *http.Request) (*storage.AuthReques
 		}
 	}
 	if rt.token {
-		if redirectURI == redirectURIOOB {
-			err := fmt.Sprintf("Cannot use response type 'token' with redirect_uri '%s'.", redirectURIOOB)
+		if isOOBRedirectURI(redirectURI) {
+			err := fmt.Sprintf("Cannot use response type 'token' with redirect_uri '%s'.", redirectURI)
 			return nil, newErr("invalid_request", err)
 		}
 	}
 
+	pkce, err := parsePKCE(q.Get("code_challenge"), q.Get("code_challenge_method"))
+	if err != nil {
+		return nil, newErr(errInvalidRequest, "%v", err)
+	}
+
 	return &storage.AuthRequest{
 		ID:                  storage.NewID(),
 		ClientID:            client.ID,
@@ -526,6 +611,7 @@ func (s *Server) parseAuthorizationRequest(r *http.Request) (*storage.AuthReques
 		RedirectURI:         redirectURI,
 		ResponseTypes:       responseTypes,
 		ConnectorID:         connectorID,
+		PKCE:                pkce,
 	}, nil
 }
 
@@ -536,13 +622,13 @@ func parseCrossClientScope(scope string) (peerID string, ok bool) {
 	return
 }
 
-func (s *Server) validateCrossClientTrust(clientID, peerID string) (trusted bool, err error) {
+func (s *Server) validateCrossClientTrust(ctx context.Context, clientID, peerID string) (trusted bool, err error) {
 	if peerID == clientID {
 		return true, nil
 	}
-	peer, err := s.storage.GetClient(peerID)
+	peer, err := s.getClient(ctx, peerID)
 	if err != nil {
-		if err != storage.ErrNotFound {
+		if !errors.Is(err, storage.ErrNotFound) {
 			s.logger.Errorf("Failed to get client: %v", err)
 			return false, err
 		}
@@ -556,6 +642,28 @@ func (s *Server) validateCrossClientTrust(clientID, peerID string) (trusted bool
 	return false, nil
 }
 
+// validateClientNetwork reports whether the client may request tokens from
+// the given address. Clients without AllowedCIDRs are unrestricted.
+func validateClientNetwork(client storage.Client, remoteIP string) (bool, error) {
+	if len(client.AllowedCIDRs) == 0 {
+		return true, nil
+	}
+	ip := net.ParseIP(remoteIP)
+	if ip == nil {
+		return false, nil
+	}
+	for _, cidr := range client.AllowedCIDRs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return false, fmt.Errorf("client %q has invalid allowed CIDR %q: %w", client.ID, cidr, err)
+		}
+		if ipNet.Contains(ip) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func validateRedirectURI(client storage.Client, redirectURI string) bool {
 	if !client.Public {
 		for _, uri := range client.RedirectURIs {
@@ -566,7 +674,7 @@ func validateRedirectURI(client storage.Client, redirectURI string) bool {
 		return false
 	}
 
-	if redirectURI == redirectURIOOB {
+	if isOOBRedirectURI(redirectURI) {
 		return true
 	}
 
@@ -597,9 +705,12 @@ func validateConnectorID(connectors []storage.Connector, connectorID string) boo
 // storageKeySet implements the oidc.KeySet interface backed by Dex storage
 type storageKeySet struct {
 	storage.Storage
+
+	// Additional keys to verify with, such as the migration signer's key.
+	extra []*jose.JSONWebKey
 }
 
-func (s *storageKeySet) VerifySignature(_ context.Context, jwt string) (payload []byte, err error) {
+func (s *storageKeySet) VerifySignature(ctx context.Context, jwt string) (payload []byte, err error) {
 	jws, err := jose.ParseSigned(jwt)
 	if err != nil {
 		return nil, err
@@ -611,7 +722,7 @@ func (s *storageKeySet) VerifySignature(_ context.Context, jwt string) (payload
 		break
 	}
 
-	skeys, err := s.Storage.GetKeys()
+	skeys, err := s.Storage.GetKeys(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -620,6 +731,7 @@ func (s *storageKeySet) VerifySignature(_ context.Context, jwt string) (payload
 	for _, vk := range skeys.VerificationKeys {
 		keys = append(keys, vk.PublicKey)
 	}
+	keys = append(keys, s.extra...)
 
 	for _, key := range keys {
 		if keyID == "" || key.KeyID == keyID {
@@ -197,6 +197,43 @@ func TestParseAuthorizationRequest(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "PKCE code challenge",
+			clients: []storage.Client{
+				{
+					ID:           "foo",
+					RedirectURIs: []string{"https://example.com/foo"},
+				},
+			},
+			supportedResponseTypes: []string{"code"},
+			queryParams: map[string]string{
+				"client_id":             "foo",
+				"redirect_uri":          "https://example.com/foo",
+				"response_type":         "code",
+				"scope":                 "openid email profile",
+				"code_challenge":        "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM",
+				"code_challenge_method": "S256",
+			},
+		},
+		{
+			name: "unsupported PKCE code challenge method",
+			clients: []storage.Client{
+				{
+					ID:           "foo",
+					RedirectURIs: []string{"https://example.com/foo"},
+				},
+			},
+			supportedResponseTypes: []string{"code"},
+			queryParams: map[string]string{
+				"client_id":             "foo",
+				"redirect_uri":          "https://example.com/foo",
+				"response_type":         "code",
+				"scope":                 "openid email profile",
+				"code_challenge":        "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM",
+				"code_challenge_method": "S512",
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tc := range tests {
@@ -276,6 +313,19 @@ func TestValidRedirectURI(t *testing.T) {
 			redirectURI: "urn:ietf:wg:oauth:2.0:oob",
 			wantValid:   true,
 		},
+		{
+			client: storage.Client{
+				Public: true,
+			},
+			redirectURI: "urn:ietf:wg:oauth:2.0:oob:auto",
+			wantValid:   true,
+		},
+		{
+			client: storage.Client{
+				RedirectURIs: []string{"http://foo.com/bar"},
+			},
+			redirectURI: "urn:ietf:wg:oauth:2.0:oob:auto",
+		},
 		{
 			client: storage.Client{
 				Public: true,
@@ -314,9 +364,37 @@ func TestValidRedirectURI(t *testing.T) {
 	}
 }
 
+func TestValidateClientNetwork(t *testing.T) {
+	tests := []struct {
+		cidrs     []string
+		remoteIP  string
+		wantValid bool
+		wantErr   bool
+	}{
+		{remoteIP: "192.0.2.1", wantValid: true},
+		{cidrs: []string{"10.0.0.0/8"}, remoteIP: "10.1.2.3", wantValid: true},
+		{cidrs: []string{"10.0.0.0/8"}, remoteIP: "192.0.2.1"},
+		{cidrs: []string{"10.0.0.0/8", "fd00::/8"}, remoteIP: "fd00::1", wantValid: true},
+		{cidrs: []string{"10.0.0.0/8"}, remoteIP: "not-an-ip"},
+		{cidrs: []string{"10.0.0.0"}, remoteIP: "10.0.0.1", wantErr: true},
+	}
+	for _, test := range tests {
+		client := storage.Client{ID: "foo", AllowedCIDRs: test.cidrs}
+		got, err := validateClientNetwork(client, test.remoteIP)
+		if (err != nil) != test.wantErr {
+			t.Errorf("cidrs=%q, remoteIP=%q, wanted error=%t, got=%v", test.cidrs, test.remoteIP, test.wantErr, err)
+			continue
+		}
+		if got != test.wantValid {
+			t.Errorf("cidrs=%q, remoteIP=%q, wanted valid=%t, got=%t", test.cidrs, test.remoteIP, test.wantValid, got)
+		}
+	}
+}
+
 func TestStorageKeySet(t *testing.T) {
+	ctx := context.Background()
 	s := memory.New(logger)
-	if err := s.UpdateKeys(func(keys storage.Keys) (storage.Keys, error) {
+	if err := s.UpdateKeys(ctx, func(keys storage.Keys) (storage.Keys, error) {
 		keys.SigningKey = &jose.JSONWebKey{
 			Key:       testKey,
 			KeyID:     "testkey",
@@ -388,7 +466,7 @@ func TestStorageKeySet(t *testing.T) {
 				t.Fatal(err)
 			}
 
-			keySet := &storageKeySet{s}
+			keySet := &storageKeySet{Storage: s}
 
 			_, err = keySet.VerifySignature(context.Background(), jwt)
 			if (err != nil && !tc.wantErr) || (err == nil && tc.wantErr) {
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"time"
+
+	"github.com/dexidp/dex/pkg/audit"
+)
+
+// Option configures a server constructed by NewServer. Options are applied
+// to the Config after it's been populated, so they take precedence over it.
+type Option func(c *Config)
+
+// WithAuditSink sets the sink audit events are delivered to.
+func WithAuditSink(sink audit.Sink) Option {
+	return func(c *Config) { c.AuditSink = sink }
+}
+
+// WithTemplates loads the HTML templates from dir instead of the templates
+// directory of the web config.
+func WithTemplates(dir string) Option {
+	return func(c *Config) { c.Web.TemplatesDir = dir }
+}
+
+// WithClock sets the function the server uses to determine the current time.
+func WithClock(now func() time.Time) Option {
+	return func(c *Config) { c.Now = now }
+}
+
+// WithPolicyEngine sets a policy engine consulted whenever tokens are issued.
+func WithPolicyEngine(engine PolicyEngine) Option {
+	return func(c *Config) { c.PolicyEngine = engine }
+}
+
+// WithAuthorizer sets an external authorizer consulted before any tokens are
+// issued.
+func WithAuthorizer(authorizer Authorizer) Option {
+	return func(c *Config) { c.Authorizer = authorizer }
+}
+
+// WithMiddleware appends middleware wrapping every request.
+func WithMiddleware(middleware ...Middleware) Option {
+	return func(c *Config) { c.Middleware = append(c.Middleware, middleware...) }
+}
+
+// WithRoutes appends additional routes to serve.
+func WithRoutes(routes ...Route) Option {
+	return func(c *Config) { c.Routes = append(c.Routes, routes...) }
+}
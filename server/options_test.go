@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dexidp/dex/storage"
+	"github.com/dexidp/dex/storage/memory"
+)
+
+type denyAll string
+
+func (d denyAll) Allow(clientID string, claims storage.Claims) (string, bool) {
+	return string(d), false
+}
+
+func TestNewServerOptions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := memory.New(logger)
+	if err := s.CreateConnector(ctx, storage.Connector{ID: "mock", Type: "mockCallback", Name: "Mock"}); err != nil {
+		t.Fatalf("create connector: %v", err)
+	}
+	config := Config{
+		Issuer:  "http://localhost",
+		Storage: s,
+		Web:     WebConfig{Dir: "../web"},
+		Logger:  logger,
+	}
+
+	now := time.Date(2020, 3, 2, 9, 0, 0, 0, time.UTC)
+	sink := new(recordingSink)
+	server, err := NewServer(ctx, config,
+		WithClock(func() time.Time { return now }),
+		WithAuditSink(sink),
+		WithPolicyEngine(denyAll("Maintenance in progress.")),
+	)
+	if err != nil {
+		t.Fatalf("create server: %v", err)
+	}
+	if !server.now().Equal(now) {
+		t.Errorf("expected server to use the configured clock")
+	}
+	if server.audit != sink {
+		t.Errorf("expected server to use the configured audit sink")
+	}
+	if msg, ok := server.checkAccessWindows("app", storage.Claims{}); ok || msg != "Maintenance in progress." {
+		t.Errorf("expected policy engine to deny access, got %q %t", msg, ok)
+	}
+
+	empty, err := ioutil.TempDir("", "dex-templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(empty)
+	if _, err := NewServer(ctx, config, WithTemplates(empty)); err == nil {
+		t.Errorf("expected server to load templates from the configured directory")
+	}
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/dexidp/dex/storage"
+)
+
+// Code challenge methods of PKCE.
+//
+// https://tools.ietf.org/html/rfc7636#section-4.2
+const (
+	codeChallengeMethodPlain = "plain"
+	codeChallengeMethodS256  = "S256"
+)
+
+// codeChallengeMethods are the supported code challenge methods, strongest
+// first.
+var codeChallengeMethods = []string{codeChallengeMethodS256, codeChallengeMethodPlain}
+
+// parsePKCE validates the code challenge parameters of an auth request. The
+// method defaults to "plain" when a challenge is sent without one.
+func parsePKCE(challenge, method string) (storage.PKCE, error) {
+	if challenge == "" {
+		if method != "" {
+			return storage.PKCE{}, fmt.Errorf("code_challenge_method %q requires a code_challenge", method)
+		}
+		return storage.PKCE{}, nil
+	}
+	if method == "" {
+		method = codeChallengeMethodPlain
+	}
+	if method != codeChallengeMethodPlain && method != codeChallengeMethodS256 {
+		return storage.PKCE{}, fmt.Errorf("unsupported code_challenge_method %q", method)
+	}
+	if !validCodeVerifier(challenge) {
+		return storage.PKCE{}, errors.New("invalid code_challenge")
+	}
+	return storage.PKCE{CodeChallenge: challenge, CodeChallengeMethod: method}, nil
+}
+
+// validCodeVerifier reports whether s has the length and characters of a
+// code verifier. S256 challenges are 43 characters of base64url, so the same
+// check applies to challenges.
+//
+// https://tools.ietf.org/html/rfc7636#section-4.1
+func validCodeVerifier(s string) bool {
+	if len(s) < 43 || len(s) > 128 {
+		return false
+	}
+	for _, c := range s {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case c == '-', c == '.', c == '_', c == '~':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// verifyCodeVerifier reports whether the verifier answers the code challenge.
+func verifyCodeVerifier(pkce storage.PKCE, verifier string) bool {
+	if !validCodeVerifier(verifier) {
+		return false
+	}
+	expected := verifier
+	if pkce.CodeChallengeMethod == codeChallengeMethodS256 {
+		sum := sha256.Sum256([]byte(verifier))
+		expected = base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(pkce.CodeChallenge)) == 1
+}
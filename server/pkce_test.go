@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dexidp/dex/storage"
+)
+
+// Example verifier and S256 challenge from RFC 7636 appendix B.
+const (
+	testCodeVerifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	testCodeChallenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+)
+
+func TestParsePKCE(t *testing.T) {
+	tests := []struct {
+		challenge, method string
+		want              storage.PKCE
+		wantErr           bool
+	}{
+		{"", "", storage.PKCE{}, false},
+		{testCodeChallenge, "S256", storage.PKCE{CodeChallenge: testCodeChallenge, CodeChallengeMethod: "S256"}, false},
+		{testCodeVerifier, "", storage.PKCE{CodeChallenge: testCodeVerifier, CodeChallengeMethod: "plain"}, false},
+		{"", "S256", storage.PKCE{}, true},
+		{testCodeChallenge, "S512", storage.PKCE{}, true},
+		{"too-short", "plain", storage.PKCE{}, true},
+		{testCodeChallenge + "!", "plain", storage.PKCE{}, true},
+	}
+	for _, tc := range tests {
+		got, err := parsePKCE(tc.challenge, tc.method)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("parsePKCE(%q, %q): wanted error %t, got %v", tc.challenge, tc.method, tc.wantErr, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("parsePKCE(%q, %q): wanted %+v, got %+v", tc.challenge, tc.method, tc.want, got)
+		}
+	}
+}
+
+func TestAuthCodePKCE(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, nil)
+	defer httpServer.Close()
+
+	public := storage.Client{ID: "cli", Secret: "cli-secret", Public: true}
+	confidential := storage.Client{ID: "web", Secret: "web-secret", RedirectURIs: []string{"https://example.com/callback"}}
+	for _, c := range []storage.Client{public, confidential} {
+		if err := s.storage.CreateClient(ctx, c); err != nil {
+			t.Fatalf("failed to create client: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		client   storage.Client
+		pkce     storage.PKCE
+		verifier string
+		want     int
+	}{
+		{
+			name:     "public client with verifier",
+			client:   storage.Client{ID: public.ID},
+			pkce:     storage.PKCE{CodeChallenge: testCodeChallenge, CodeChallengeMethod: codeChallengeMethodS256},
+			verifier: testCodeVerifier,
+			want:     http.StatusOK,
+		},
+		{
+			name:     "plain challenge",
+			client:   confidential,
+			pkce:     storage.PKCE{CodeChallenge: testCodeVerifier, CodeChallengeMethod: codeChallengeMethodPlain},
+			verifier: testCodeVerifier,
+			want:     http.StatusOK,
+		},
+		{
+			name:     "wrong verifier",
+			client:   confidential,
+			pkce:     storage.PKCE{CodeChallenge: testCodeChallenge, CodeChallengeMethod: codeChallengeMethodS256},
+			verifier: testCodeChallenge,
+			want:     http.StatusBadRequest,
+		},
+		{
+			name:   "missing verifier",
+			client: confidential,
+			pkce:   storage.PKCE{CodeChallenge: testCodeChallenge, CodeChallengeMethod: codeChallengeMethodS256},
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:     "public client without challenge",
+			client:   storage.Client{ID: public.ID},
+			verifier: testCodeVerifier,
+			want:     http.StatusBadRequest,
+		},
+		{
+			name:     "confidential client without secret",
+			client:   storage.Client{ID: confidential.ID},
+			pkce:     storage.PKCE{CodeChallenge: testCodeChallenge, CodeChallengeMethod: codeChallengeMethodS256},
+			verifier: testCodeVerifier,
+			want:     http.StatusUnauthorized,
+		},
+	}
+	for _, tc := range tests {
+		code := storage.AuthCode{
+			ID:          storage.NewID(),
+			ClientID:    tc.client.ID,
+			RedirectURI: redirectURIOOB,
+			Scopes:      []string{"openid"},
+			ConnectorID: "mock",
+			Claims:      storage.Claims{UserID: "1", Email: "jane.doe@example.com"},
+			Expiry:      time.Now().Add(time.Minute),
+			PKCE:        tc.pkce,
+		}
+		if err := s.storage.CreateAuthCode(ctx, code); err != nil {
+			t.Fatalf("failed to create auth code: %v", err)
+		}
+		form := url.Values{
+			"grant_type":   {grantTypeAuthorizationCode},
+			"code":         {code.ID},
+			"redirect_uri": {code.RedirectURI},
+		}
+		if tc.verifier != "" {
+			form.Set("code_verifier", tc.verifier)
+		}
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, tokenRequest(tc.client, "10.0.0.1:1234", form))
+		if rr.Code != tc.want {
+			t.Errorf("%s: expected status %d, got %d: %s", tc.name, tc.want, rr.Code, rr.Body)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dexidp/dex/storage"
+)
+
+// AccessWindow restricts when matching clients or users can obtain new tokens,
+// for example limiting contractor accounts to business hours or an application
+// to a maintenance window.
+//
+// A window applies to a request if the client is listed in Clients and the
+// user, identified by email or username, is listed in Users. An empty list
+// matches everything. Every window that applies to a request must allow it.
+type AccessWindow struct {
+	Clients []string
+	Users   []string
+
+	// Days on which access is allowed. If empty, access is allowed every day.
+	Days []time.Weekday
+
+	// Start and End are offsets from midnight in Location bounding the
+	// allowed time of day. If both are zero, the whole day is allowed. If End
+	// is before Start the window wraps around midnight.
+	Start, End time.Duration
+
+	// Location the days and times are evaluated in. Defaults to UTC.
+	Location *time.Location
+
+	// NotBefore and NotAfter bound the allowed period. Zero values are
+	// unbounded.
+	NotBefore, NotAfter time.Time
+
+	// Message shown to the user when access is denied. If empty, a message
+	// describing the window is generated.
+	Message string
+}
+
+func (a AccessWindow) appliesTo(clientID string, claims storage.Claims) bool {
+	if len(a.Clients) > 0 && !contains(a.Clients, clientID) {
+		return false
+	}
+	if len(a.Users) > 0 && !contains(a.Users, claims.Email) && !contains(a.Users, claims.Username) {
+		return false
+	}
+	return true
+}
+
+func (a AccessWindow) allows(now time.Time) bool {
+	if !a.NotBefore.IsZero() && now.Before(a.NotBefore) {
+		return false
+	}
+	if !a.NotAfter.IsZero() && now.After(a.NotAfter) {
+		return false
+	}
+
+	loc := a.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	local := now.In(loc)
+
+	if len(a.Days) > 0 {
+		var ok bool
+		for _, d := range a.Days {
+			if local.Weekday() == d {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+
+	if a.Start == 0 && a.End == 0 {
+		return true
+	}
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	offset := local.Sub(midnight)
+	if a.Start <= a.End {
+		return offset >= a.Start && offset < a.End
+	}
+	return offset >= a.Start || offset < a.End
+}
+
+// describe produces the message shown to users denied by this window.
+func (a AccessWindow) describe() string {
+	if a.Message != "" {
+		return a.Message
+	}
+	var parts []string
+	if len(a.Days) > 0 {
+		days := make([]string, len(a.Days))
+		for i, d := range a.Days {
+			days[i] = d.String()[:3]
+		}
+		parts = append(parts, "on "+strings.Join(days, ", "))
+	}
+	if a.Start != 0 || a.End != 0 {
+		loc := a.Location
+		if loc == nil {
+			loc = time.UTC
+		}
+		parts = append(parts, fmt.Sprintf("between %s and %s (%s)", clock(a.Start), clock(a.End), loc))
+	}
+	if !a.NotBefore.IsZero() {
+		parts = append(parts, "from "+a.NotBefore.Format(time.RFC3339))
+	}
+	if !a.NotAfter.IsZero() {
+		parts = append(parts, "until "+a.NotAfter.Format(time.RFC3339))
+	}
+	if len(parts) == 0 {
+		return "Access is not allowed at this time."
+	}
+	return "Access is only allowed " + strings.Join(parts, " ") + "."
+}
+
+func clock(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+}
+
+// PolicyEngine decides whether a user may obtain tokens for a client, letting
+// embedders plug in access policies beyond the built in ones.
+type PolicyEngine interface {
+	// Allow returns false and a message shown to the user if the user may
+	// not obtain tokens for the client.
+	Allow(clientID string, claims storage.Claims) (denied string, ok bool)
+}
+
+// checkAccessWindows returns a user facing message if any access window or
+// the policy engine denies the client and user from obtaining tokens now.
+func (s *Server) checkAccessWindows(clientID string, claims storage.Claims) (denied string, ok bool) {
+	now := s.now()
+	denied, ok = evaluateAccess(s.accessWindows, s.policyEngine, now, clientID, claims)
+	s.shadowAccess(now, clientID, claims, ok)
+	return denied, ok
+}
+
+func evaluateAccess(windows []AccessWindow, engine PolicyEngine, now time.Time, clientID string, claims storage.Claims) (denied string, ok bool) {
+	for _, w := range windows {
+		if w.appliesTo(clientID, claims) && !w.allows(now) {
+			return w.describe(), false
+		}
+	}
+	if engine != nil {
+		return engine.Allow(clientID, claims)
+	}
+	return "", true
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// OfflineAccessRule disables refresh tokens for matching requests. Clients
+// still receive ID and access tokens when they request the offline_access
+// scope, but no refresh token.
+//
+// A rule matches if the client is listed in Clients, the connector the user
+// logged in with is listed in Connectors, and the grant type of the token
+// request is listed in GrantTypes. An empty list matches everything. Rules
+// matching the "refresh_token" grant type also stop existing refresh tokens
+// from being redeemed.
+type OfflineAccessRule struct {
+	Clients    []string
+	Connectors []string
+	GrantTypes []string
+}
+
+func (o OfflineAccessRule) matches(clientID, connID, grantType string) bool {
+	return (len(o.Clients) == 0 || contains(o.Clients, clientID)) &&
+		(len(o.Connectors) == 0 || contains(o.Connectors, connID)) &&
+		(len(o.GrantTypes) == 0 || contains(o.GrantTypes, grantType))
+}
+
+// offlineAccessAllowed reports whether a refresh token may be issued or
+// redeemed for the client, connector and grant type.
+func (s *Server) offlineAccessAllowed(clientID, connID, grantType string) bool {
+	allowed := evaluateOfflineAccess(s.offlineAccessRules, clientID, connID, grantType)
+	s.shadowOfflineAccess(clientID, connID, grantType, allowed)
+	return allowed
+}
+
+func evaluateOfflineAccess(rules []OfflineAccessRule, clientID, connID, grantType string) bool {
+	for _, rule := range rules {
+		if rule.matches(clientID, connID, grantType) {
+			return false
+		}
+	}
+	return true
+}
+
+// RefreshPolicy decides whether redeeming a refresh token contacts the
+// connector the user logged in with, to check the user is still active and
+// update their claims, or only reissues the claims stored with the token.
+//
+// A policy matches if the client is listed in Clients and the connector in
+// Connectors. An empty list matches everything. The first matching policy
+// applies. Without one, connectors able to refresh identities are contacted
+// on every refresh.
+type RefreshPolicy struct {
+	Clients    []string
+	Connectors []string
+
+	// Local refreshes don't contact the connector.
+	Local bool
+
+	// MaxStaleness bounds how long local refreshes reuse the stored claims.
+	// Once the identity was last refreshed from the connector longer ago, the
+	// connector is contacted again. Zero means the claims never go stale.
+	MaxStaleness time.Duration
+}
+
+func (p RefreshPolicy) matches(clientID, connID string) bool {
+	return (len(p.Clients) == 0 || contains(p.Clients, clientID)) &&
+		(len(p.Connectors) == 0 || contains(p.Connectors, connID))
+}
+
+// refreshUpstream reports whether a refresh token of the client should
+// refresh the identity from the connector, given when it was last refreshed.
+func (s *Server) refreshUpstream(clientID, connID string, refreshedAt time.Time) bool {
+	for _, p := range s.refreshPolicies {
+		if !p.matches(clientID, connID) {
+			continue
+		}
+		if !p.Local {
+			return true
+		}
+		return p.MaxStaleness > 0 && s.now().Sub(refreshedAt) > p.MaxStaleness
+	}
+	return true
+}
@@ -0,0 +1,239 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+
+	"github.com/dexidp/dex/storage"
+)
+
+func TestAccessWindowAllows(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("time zone data not available: %v", err)
+	}
+
+	businessHours := AccessWindow{
+		Days:     []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+		Start:    9 * time.Hour,
+		End:      17 * time.Hour,
+		Location: berlin,
+	}
+	overnight := AccessWindow{
+		Start: 22 * time.Hour,
+		End:   2 * time.Hour,
+	}
+	contract := AccessWindow{
+		NotBefore: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:  time.Date(2020, 6, 30, 0, 0, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name   string
+		window AccessWindow
+		now    time.Time
+		want   bool
+	}{
+		// 2020-03-02 is a Monday. Berlin is UTC+1 in March.
+		{"business hours", businessHours, time.Date(2020, 3, 2, 9, 0, 0, 0, time.UTC), true},
+		{"before business hours", businessHours, time.Date(2020, 3, 2, 7, 30, 0, 0, time.UTC), false},
+		{"end is exclusive", businessHours, time.Date(2020, 3, 2, 16, 0, 0, 0, time.UTC), false},
+		{"weekend", businessHours, time.Date(2020, 3, 7, 10, 0, 0, 0, time.UTC), false},
+		{"overnight late", overnight, time.Date(2020, 3, 2, 23, 0, 0, 0, time.UTC), true},
+		{"overnight early", overnight, time.Date(2020, 3, 2, 1, 0, 0, 0, time.UTC), true},
+		{"overnight daytime", overnight, time.Date(2020, 3, 2, 12, 0, 0, 0, time.UTC), false},
+		{"within contract", contract, time.Date(2020, 3, 2, 12, 0, 0, 0, time.UTC), true},
+		{"contract expired", contract, time.Date(2020, 7, 1, 12, 0, 0, 0, time.UTC), false},
+		{"contract not started", contract, time.Date(2019, 12, 31, 12, 0, 0, 0, time.UTC), false},
+	}
+	for _, tc := range tests {
+		if got := tc.window.allows(tc.now); got != tc.want {
+			t.Errorf("%s: allows(%s) = %t, want %t", tc.name, tc.now, got, tc.want)
+		}
+	}
+}
+
+func TestCheckAccessWindows(t *testing.T) {
+	now := time.Date(2020, 3, 7, 10, 0, 0, 0, time.UTC) // Saturday
+	s := &Server{
+		now: func() time.Time { return now },
+		accessWindows: []AccessWindow{{
+			Users: []string{"contractor@example.com"},
+			Days:  []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+		}},
+	}
+
+	if _, ok := s.checkAccessWindows("app", storage.Claims{Email: "employee@example.com"}); !ok {
+		t.Errorf("expected window not to apply to other users")
+	}
+	msg, ok := s.checkAccessWindows("app", storage.Claims{Email: "contractor@example.com"})
+	if ok {
+		t.Fatalf("expected contractor to be denied on weekends")
+	}
+	if want := "Access is only allowed on Mon, Tue, Wed, Thu, Fri."; msg != want {
+		t.Errorf("expected message %q, got %q", want, msg)
+	}
+}
+
+func TestOfflineAccessRules(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.OfflineAccessRules = []OfflineAccessRule{
+			{Clients: []string{"kiosk"}},
+			{Connectors: []string{"mock"}, GrantTypes: []string{grantTypePassword}},
+		}
+	})
+	defer httpServer.Close()
+
+	if s.offlineAccessAllowed("app", "mock", grantTypePassword) {
+		t.Errorf("expected password grants through the mock connector to be denied refresh tokens")
+	}
+	if !s.offlineAccessAllowed("app", "mock", grantTypeAuthorizationCode) {
+		t.Errorf("expected code grants through the mock connector to be allowed refresh tokens")
+	}
+
+	exchange := func(clientID string) (refreshToken string) {
+		client := storage.Client{
+			ID:           clientID,
+			Secret:       "secret",
+			RedirectURIs: []string{"https://example.com/callback"},
+		}
+		if err := s.storage.CreateClient(ctx, client); err != nil {
+			t.Fatalf("failed to create client: %v", err)
+		}
+		code := storage.AuthCode{
+			ID:          storage.NewID(),
+			ClientID:    client.ID,
+			RedirectURI: client.RedirectURIs[0],
+			Scopes:      []string{"openid", "offline_access"},
+			ConnectorID: "mock",
+			Claims:      storage.Claims{UserID: "1", Email: "jane.doe@example.com"},
+			Expiry:      time.Now().Add(time.Minute),
+		}
+		if err := s.storage.CreateAuthCode(ctx, code); err != nil {
+			t.Fatalf("failed to create auth code: %v", err)
+		}
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, tokenRequest(client, "10.0.0.1:1234", url.Values{
+			"grant_type":   {grantTypeAuthorizationCode},
+			"code":         {code.ID},
+			"redirect_uri": {code.RedirectURI},
+		}))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200 redeeming code, got %d: %s", clientID, rr.Code, rr.Body)
+		}
+		var resp struct {
+			RefreshToken string `json:"refresh_token"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode token response: %v", err)
+		}
+		return resp.RefreshToken
+	}
+
+	if exchange("kiosk") != "" {
+		t.Errorf("expected no refresh token for the kiosk client")
+	}
+	if exchange("app") == "" {
+		t.Errorf("expected a refresh token for other clients")
+	}
+}
+
+func TestRefreshPolicies(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.RefreshPolicies = []RefreshPolicy{
+			{Clients: []string{"local"}, Connectors: []string{"mock"}, Local: true, MaxStaleness: time.Hour},
+		}
+	})
+	defer httpServer.Close()
+
+	// login redeems a code with stale claims and refreshes the token,
+	// returning the email of the refreshed ID token.
+	login := func(clientID string) (refresh func() string) {
+		client := storage.Client{
+			ID:           clientID,
+			Secret:       "secret",
+			RedirectURIs: []string{"https://example.com/callback"},
+		}
+		if err := s.storage.CreateClient(ctx, client); err != nil {
+			t.Fatalf("failed to create client: %v", err)
+		}
+		code := storage.AuthCode{
+			ID:          storage.NewID(),
+			ClientID:    client.ID,
+			RedirectURI: client.RedirectURIs[0],
+			Scopes:      []string{"openid", "email", "offline_access"},
+			ConnectorID: "mock",
+			Claims:      storage.Claims{UserID: "0-385-28089-0", Email: "jane.doe@example.com", EmailVerified: true},
+			Expiry:      time.Now().Add(time.Minute),
+		}
+		if err := s.storage.CreateAuthCode(ctx, code); err != nil {
+			t.Fatalf("failed to create auth code: %v", err)
+		}
+		var resp struct {
+			RefreshToken string `json:"refresh_token"`
+			IDToken      string `json:"id_token"`
+		}
+		request := func(form url.Values) {
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, tokenRequest(client, "10.0.0.1:1234", form))
+			if rr.Code != http.StatusOK {
+				t.Fatalf("%s: expected 200, got %d: %s", clientID, rr.Code, rr.Body)
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode token response: %v", err)
+			}
+		}
+		request(url.Values{
+			"grant_type":   {grantTypeAuthorizationCode},
+			"code":         {code.ID},
+			"redirect_uri": {code.RedirectURI},
+		})
+		return func() string {
+			request(url.Values{"grant_type": {grantTypeRefreshToken}, "refresh_token": {resp.RefreshToken}})
+			jws, err := jose.ParseSigned(resp.IDToken)
+			if err != nil {
+				t.Fatalf("parse id token: %v", err)
+			}
+			var claims struct {
+				Email string `json:"email"`
+			}
+			if err := json.Unmarshal(jws.UnsafePayloadWithoutVerification(), &claims); err != nil {
+				t.Fatalf("decode id token claims: %v", err)
+			}
+			return claims.Email
+		}
+	}
+
+	if email := login("app")(); email != "kilgore@kilgore.trout" {
+		t.Errorf("expected refresh to update the identity from the connector, got email %q", email)
+	}
+	refresh := login("local")
+	if email := refresh(); email != "jane.doe@example.com" {
+		t.Errorf("expected local refresh to reuse the stored claims, got email %q", email)
+	}
+
+	s.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if email := refresh(); email != "kilgore@kilgore.trout" {
+		t.Errorf("expected stale claims to be refreshed from the connector, got email %q", email)
+	}
+	session, err := s.storage.GetOfflineSessions(ctx, "0-385-28089-0", "mock")
+	if err != nil {
+		t.Fatalf("get offline session: %v", err)
+	}
+	if ref := session.Refresh["local"]; ref == nil || ref.IdentityRefreshedAt.IsZero() {
+		t.Errorf("expected the time of the refresh from the connector to be recorded, got %+v", ref)
+	}
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/dexidp/dex/pkg/audit"
+	"github.com/dexidp/dex/storage"
+)
+
+// preAuthCodeConnectorID is the connector ID of tokens issued for
+// pre-authorized codes created without one.
+const preAuthCodeConnectorID = "pre_authorized_code"
+
+// defaultPreAuthCodeValidFor is how long pre-authorized codes created
+// without an expiry are valid for.
+const defaultPreAuthCodeValidFor = 5 * time.Minute
+
+// newPreAuthorizedCode sets the ID of the code, and the hash of its PIN if
+// pin isn't empty.
+func newPreAuthorizedCode(c *storage.PreAuthorizedCode, pin string) error {
+	c.ID = storage.NewID()
+	if pin == "" {
+		return nil
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(pin), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	c.PINHash = hash
+	return nil
+}
+
+// handlePreAuthorizedCodeGrant exchanges a pre-authorized code for an ID
+// token and access token of the code's client, as described by OpenID for
+// Verifiable Credential Issuance. The code authenticates the request, the
+// client doesn't need to. It returns the ID of the client once the code has
+// been consumed.
+//
+// Codes are consumed before their PIN is checked, so a wrong PIN invalidates
+// the code and PINs can't be guessed.
+func (s *Server) handlePreAuthorizedCodeGrant(w http.ResponseWriter, r *http.Request) string {
+	ctx := r.Context()
+	code := r.PostFormValue("pre-authorized_code")
+	if code == "" {
+		s.tokenErrHelper(w, errInvalidRequest, "Required param: pre-authorized_code.", http.StatusBadRequest)
+		return ""
+	}
+	c, err := s.storage.ConsumePreAuthorizedCode(ctx, code)
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			s.logger.Errorf("failed to consume pre-authorized code: %v", err)
+			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+			return ""
+		}
+		s.delayFailure(ctx)
+		s.tokenErrHelper(w, errInvalidGrant, "Invalid or expired pre-authorized code.", http.StatusBadRequest)
+		return ""
+	}
+	if s.expired(c.Expiry) {
+		s.tokenErrHelper(w, errInvalidGrant, "Invalid or expired pre-authorized code.", http.StatusBadRequest)
+		return ""
+	}
+	if clientID := r.PostFormValue("client_id"); clientID != "" && clientID != c.ClientID {
+		s.tokenErrHelper(w, errInvalidGrant, "Pre-authorized code was issued to another client.", http.StatusBadRequest)
+		return ""
+	}
+	if len(c.PINHash) > 0 {
+		pin := r.PostFormValue("tx_code")
+		if pin == "" {
+			s.tokenErrHelper(w, errInvalidRequest, "Required param: tx_code.", http.StatusBadRequest)
+			return ""
+		}
+		if bcrypt.CompareHashAndPassword(c.PINHash, []byte(pin)) != nil {
+			s.delayFailure(ctx)
+			s.tokenErrHelper(w, errInvalidGrant, "Invalid tx_code.", http.StatusBadRequest)
+			return ""
+		}
+	}
+
+	client, err := s.getClient(ctx, c.ClientID)
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			s.logger.Errorf("failed to get client: %v", err)
+			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		} else {
+			s.tokenErrHelper(w, errInvalidGrant, "Invalid or expired pre-authorized code.", http.StatusBadRequest)
+		}
+		return ""
+	}
+	if !s.checkClientNetwork(w, r, client) {
+		return client.ID
+	}
+
+	// Like API keys, codes can only be exchanged for a subset of their
+	// scopes.
+	scopes, err := apiKeyScopes(storage.APIKey{Scopes: c.Scopes}, strings.Fields(r.PostFormValue("scope")))
+	if err != nil {
+		s.tokenErrHelper(w, errInvalidScope, "Scope is not granted to the pre-authorized code.", http.StatusBadRequest)
+		return client.ID
+	}
+	if msg, ok := s.checkAccessWindows(client.ID, c.Claims); !ok {
+		s.tokenErrHelper(w, errAccessDenied, msg, http.StatusForbidden)
+		return client.ID
+	}
+
+	connID := c.ConnectorID
+	if connID == "" {
+		connID = preAuthCodeConnectorID
+	}
+	scopes, msg, ok := s.authorize(r, grantTypePreAuthorizedCode, client.ID, connID, c.Claims, scopes)
+	if !ok {
+		s.tokenErrHelper(w, errAccessDenied, msg, http.StatusForbidden)
+		return client.ID
+	}
+	accessToken, err := s.newAccessToken(ctx, client.ID, c.Claims, scopes, "", connID)
+	if err != nil {
+		s.logger.Errorf("failed to create new access token: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return client.ID
+	}
+	idToken, expiry, err := s.newIDToken(ctx, client.ID, c.Claims, scopes, "", accessToken, connID)
+	if err != nil {
+		s.logger.Errorf("failed to create ID token: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return client.ID
+	}
+
+	s.emitAudit(ctx, audit.Event{
+		Type:        audit.EventPreAuthorizedCodeUsed,
+		Severity:    audit.SeverityInfo,
+		ClientID:    client.ID,
+		Subject:     subjectFor(c.Claims.UserID, connID),
+		ConnectorID: connID,
+		SourceIPs:   []string{remoteIP(r)},
+	})
+	// Codes stand in for a login, not for a long-lived grant, they are never
+	// exchanged for refresh tokens.
+	s.writeAccessToken(w, idToken, accessToken, "", expiry)
+	return client.ID
+}
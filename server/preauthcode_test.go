@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dexidp/dex/api/v2"
+	"github.com/dexidp/dex/pkg/audit"
+	"github.com/dexidp/dex/storage"
+	"github.com/dexidp/dex/storage/memory"
+)
+
+func TestPreAuthorizedCodeGrant(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sink := new(recordingSink)
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.AllowPreAuthorizedCodes = true
+		c.AuditSink = sink
+	})
+	defer httpServer.Close()
+
+	if err := s.storage.CreateClient(ctx, storage.Client{ID: "wallet", Public: true}); err != nil {
+		t.Fatal(err)
+	}
+	a := NewAPI(s.storage, logger, nil, nil, nil, 0)
+	create := func(pin string, expiresIn int64) string {
+		resp, err := a.CreatePreAuthorizedCode(ctx, &api.CreatePreAuthorizedCodeReq{
+			ClientId:  "wallet",
+			Scopes:    []string{"openid", "email"},
+			UserId:    "jane",
+			Email:     "jane@example.com",
+			Pin:       pin,
+			ExpiresIn: expiresIn,
+		})
+		if err != nil {
+			t.Fatalf("create pre-authorized code: %v", err)
+		}
+		return resp.Code
+	}
+	exchange := func(form url.Values) *httptest.ResponseRecorder {
+		form.Set("grant_type", grantTypePreAuthorizedCode)
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, tokenRequest(storage.Client{}, "10.0.0.1:1234", form))
+		return rr
+	}
+
+	code := create("", 0)
+	rr := exchange(url.Values{"pre-authorized_code": {code}})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected pre-authorized code to be exchanged, got %d: %s", rr.Code, rr.Body)
+	}
+	var tokens struct {
+		IDToken      string `json:"id_token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &tokens); err != nil {
+		t.Fatal(err)
+	}
+	if tokens.IDToken == "" || tokens.RefreshToken != "" {
+		t.Errorf("expected an ID token and no refresh token, got %s", rr.Body)
+	}
+	if len(sink.events) != 1 || sink.events[0].Type != audit.EventPreAuthorizedCodeUsed || sink.events[0].ClientID != "wallet" {
+		t.Errorf("expected a pre_authorized_code_used audit event, got %+v", sink.events)
+	}
+	if rr := exchange(url.Values{"pre-authorized_code": {code}}); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected used code to be rejected, got %d", rr.Code)
+	}
+
+	code = create("1234", 0)
+	if rr := exchange(url.Values{"pre-authorized_code": {code}, "tx_code": {"1234"}, "scope": {"openid"}}); rr.Code != http.StatusOK {
+		t.Errorf("expected code to be exchanged with its PIN, got %d: %s", rr.Code, rr.Body)
+	}
+	code = create("1234", 0)
+	if rr := exchange(url.Values{"pre-authorized_code": {code}, "tx_code": {"0000"}}); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected wrong PIN to be rejected, got %d", rr.Code)
+	}
+	if rr := exchange(url.Values{"pre-authorized_code": {code}, "tx_code": {"1234"}}); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected code to be used up by a wrong PIN, got %d", rr.Code)
+	}
+
+	code = create("", 0)
+	if rr := exchange(url.Values{"pre-authorized_code": {code}, "client_id": {"other"}}); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected code of another client to be rejected, got %d", rr.Code)
+	}
+	code = create("", 0)
+	if rr := exchange(url.Values{"pre-authorized_code": {code}, "scope": {"openid groups"}}); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected scope outside of the code's scopes to be rejected, got %d", rr.Code)
+	}
+	code = create("", 1)
+	s.now = func() time.Time { return time.Now().Add(time.Minute) }
+	if rr := exchange(url.Values{"pre-authorized_code": {code}}); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected expired code to be rejected, got %d", rr.Code)
+	}
+}
+
+func TestPreAuthorizedCodesDisabled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, nil)
+	defer httpServer.Close()
+
+	if contains(s.supportedGrantTypes(), grantTypePreAuthorizedCode) {
+		t.Errorf("expected pre-authorized code grant not to be advertised")
+	}
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, tokenRequest(storage.Client{}, "10.0.0.1:1234", url.Values{
+		"grant_type":          {grantTypePreAuthorizedCode},
+		"pre-authorized_code": {"code"},
+	}))
+	if rr.Code == http.StatusOK {
+		t.Errorf("expected pre-authorized code grant to be rejected while disabled")
+	}
+}
+
+func TestPreAuthorizedCodesAPI(t *testing.T) {
+	ctx := context.Background()
+	s := memory.New(logger)
+	if err := s.CreateClient(ctx, storage.Client{ID: "wallet"}); err != nil {
+		t.Fatal(err)
+	}
+	a := NewAPI(s, logger, nil, nil, nil, 0)
+
+	resp, err := a.CreatePreAuthorizedCode(ctx, &api.CreatePreAuthorizedCodeReq{ClientId: "missing", UserId: "1", Scopes: []string{"openid"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.ClientNotFound {
+		t.Errorf("expected code of unknown client to be rejected")
+	}
+	if _, err := a.CreatePreAuthorizedCode(ctx, &api.CreatePreAuthorizedCodeReq{ClientId: "wallet", UserId: "1"}); err == nil {
+		t.Errorf("expected code without scopes to be rejected")
+	}
+
+	resp, err = a.CreatePreAuthorizedCode(ctx, &api.CreatePreAuthorizedCodeReq{ClientId: "wallet", UserId: "1", Scopes: []string{"openid"}, Pin: "1234"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := s.ConsumePreAuthorizedCode(ctx, resp.Code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored.PINHash) == 0 || string(stored.PINHash) == "1234" || stored.ConnectorID != preAuthCodeConnectorID {
+		t.Errorf("unexpected stored pre-authorized code %+v", stored)
+	}
+	if validFor := stored.Expiry.Sub(stored.CreatedAt); validFor != defaultPreAuthCodeValidFor {
+		t.Errorf("expected code to be valid for %s, got %s", defaultPreAuthCodeValidFor, validFor)
+	}
+}
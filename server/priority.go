@@ -0,0 +1,109 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// priority orders requests by how important it is to serve them while dex
+// is overloaded. Lower priorities are shed first.
+type priority int
+
+// Priority classes, from the first to be shed to the last.
+const (
+	priorityAdmin priority = iota
+	priorityBackground
+	priorityRefresh
+	priorityInteractive
+)
+
+var priorityNames = map[string]priority{
+	"admin":       priorityAdmin,
+	"background":  priorityBackground,
+	"refresh":     priorityRefresh,
+	"interactive": priorityInteractive,
+}
+
+func (p priority) String() string {
+	for name, q := range priorityNames {
+		if p == q {
+			return name
+		}
+	}
+	return fmt.Sprintf("priority(%d)", int(p))
+}
+
+// Endpoint groups whose priority class can be configured. Logins, code
+// exchanges and every other request are always interactive.
+const (
+	// Refresh token grants.
+	endpointGroupRefresh = "refresh"
+	// Grants of automation: passwords, API keys, service accounts, client
+	// credentials and token exchanges.
+	endpointGroupMachine = "machine"
+	// Token introspection by resource servers.
+	endpointGroupIntrospection = "introspection"
+	endpointGroupUserInfo      = "userinfo"
+	// Session listings on the admin listener.
+	endpointGroupAdmin = "admin"
+)
+
+// defaultPriorities are the priority classes of the endpoint groups unless
+// configured otherwise.
+var defaultPriorities = map[string]priority{
+	endpointGroupRefresh:       priorityRefresh,
+	endpointGroupMachine:       priorityRefresh,
+	endpointGroupIntrospection: priorityBackground,
+	endpointGroupUserInfo:      priorityInteractive,
+	endpointGroupAdmin:         priorityAdmin,
+}
+
+// newPriorities returns the priority classes of the endpoint groups,
+// overriding the defaults by the configured class names.
+func newPriorities(configured map[string]string) (map[string]priority, error) {
+	priorities := make(map[string]priority, len(defaultPriorities))
+	for group, p := range defaultPriorities {
+		priorities[group] = p
+	}
+	for group, name := range configured {
+		if _, ok := defaultPriorities[group]; !ok {
+			return nil, fmt.Errorf("unknown endpoint group %q, expected one of %s", group, sortedKeys(defaultPriorities))
+		}
+		p, ok := priorityNames[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown priority class %q of endpoint group %q, expected one of %s", name, group, sortedKeys(priorityNames))
+		}
+		priorities[group] = p
+	}
+	return priorities, nil
+}
+
+func sortedKeys(m map[string]priority) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ", ")
+}
+
+// priority returns the priority class of requests of the endpoint group.
+func (s *Server) priority(group string) priority {
+	if p, ok := s.priorities[group]; ok {
+		return p
+	}
+	return priorityInteractive
+}
+
+// tokenEndpointGroup returns the endpoint group of a token request with the
+// grant type. Code exchanges complete interactive logins and have none.
+func tokenEndpointGroup(grantType string) string {
+	switch grantType {
+	case grantTypeRefreshToken:
+		return endpointGroupRefresh
+	case grantTypePassword, grantTypeAPIKey, grantTypeJWTBearer, grantTypeClientCredentials, grantTypeTokenExchange, grantTypePreAuthorizedCode:
+		return endpointGroupMachine
+	}
+	return ""
+}
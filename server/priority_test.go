@@ -0,0 +1,39 @@
+package server
+
+import "testing"
+
+func TestNewPriorities(t *testing.T) {
+	priorities, err := newPriorities(map[string]string{endpointGroupMachine: "background"})
+	if err != nil {
+		t.Fatalf("new priorities: %v", err)
+	}
+	if p := priorities[endpointGroupMachine]; p != priorityBackground {
+		t.Errorf("expected configured class, got %s", p)
+	}
+	if p := priorities[endpointGroupRefresh]; p != priorityRefresh {
+		t.Errorf("expected default class, got %s", p)
+	}
+
+	for _, configured := range []map[string]string{
+		{"login": "admin"},
+		{endpointGroupRefresh: "urgent"},
+	} {
+		if _, err := newPriorities(configured); err == nil {
+			t.Errorf("expected error for %v", configured)
+		}
+	}
+}
+
+func TestTokenEndpointGroup(t *testing.T) {
+	tests := map[string]string{
+		grantTypeAuthorizationCode: "",
+		grantTypeRefreshToken:      endpointGroupRefresh,
+		grantTypeClientCredentials: endpointGroupMachine,
+		"unknown":                  "",
+	}
+	for grantType, want := range tests {
+		if got := tokenEndpointGroup(grantType); got != want {
+			t.Errorf("%s: expected group %q, got %q", grantType, want, got)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// parseTrustedProxies parses a list of networks in CIDR notation. A bare IP
+// address is a network of that single address.
+func parseTrustedProxies(proxies []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, p := range proxies {
+		if ip := net.ParseIP(p); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", p, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// parseTrustedProxyHeader returns the canonical form of the header trusted
+// proxies report client addresses in.
+func parseTrustedProxyHeader(header string) (string, error) {
+	if header == "" {
+		return "X-Forwarded-For", nil
+	}
+	header = http.CanonicalHeaderKey(header)
+	switch header {
+	case "Forwarded", "X-Forwarded-For", "X-Real-Ip":
+		return header, nil
+	}
+	return "", fmt.Errorf("invalid trusted proxy header %q: must be Forwarded, X-Forwarded-For or X-Real-IP", header)
+}
+
+// resolveRemoteAddr replaces the remote address of requests forwarded by a
+// trusted proxy with the address of the client, so audit events, refresh
+// token fingerprints and client network restrictions see the client rather
+// than the proxy.
+func (s *Server) resolveRemoteAddr(h http.Handler) http.Handler {
+	if len(s.trustedProxies) == 0 {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ip := clientIP(r, s.trustedProxies, s.trustedProxyHeader); ip != remoteIP(r) {
+			r2 := new(http.Request)
+			*r2 = *r
+			r2.RemoteAddr = ip
+			r = r2
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// clientIP returns the address of the client a request originates from.
+//
+// Only a request from a trusted proxy is resolved further. The hops it
+// reports in header, one of Forwarded, X-Forwarded-For or X-Real-IP, are
+// walked from the right, and the first one not in the trusted networks is the
+// client. Hops left of it were added by the client or an untrusted proxy, so
+// they're never believed. Neither are the other headers, which the proxies
+// may pass on from the client unchanged.
+func clientIP(r *http.Request, trusted []*net.IPNet, header string) string {
+	ip := remoteIP(r)
+	if !isTrustedProxy(net.ParseIP(ip), trusted) {
+		return ip
+	}
+
+	var hops []string
+	switch values := r.Header.Values(header); header {
+	case "Forwarded":
+		hops = forwardedFor(values)
+	case "X-Forwarded-For":
+		for _, v := range values {
+			hops = append(hops, strings.Split(v, ",")...)
+		}
+	default:
+		if len(values) > 0 {
+			hops = values[len(values)-1:]
+		}
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := parseHop(hops[i])
+		if hop == nil {
+			// Obfuscated or malformed hop. The last trusted hop is the best
+			// we know.
+			break
+		}
+		ip = hop.String()
+		if !isTrustedProxy(hop, trusted) {
+			break
+		}
+	}
+	return ip
+}
+
+// forwardedFor returns the "for" parameters of RFC 7239 Forwarded headers.
+func forwardedFor(values []string) []string {
+	var hops []string
+	for _, v := range values {
+		for _, element := range strings.Split(v, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+				if len(kv) == 2 && strings.EqualFold(kv[0], "for") {
+					hops = append(hops, kv[1])
+				}
+			}
+		}
+	}
+	return hops
+}
+
+// parseHop parses an address reported by a proxy, which may be quoted and
+// carry a port, such as `"[2001:db8::17]:4711"`.
+func parseHop(hop string) net.IP {
+	hop = strings.Trim(strings.TrimSpace(hop), `"`)
+	if strings.HasPrefix(hop, "[") {
+		end := strings.Index(hop, "]")
+		if end < 0 {
+			return nil
+		}
+		hop = hop[1:end]
+	} else if strings.Count(hop, ":") == 1 {
+		hop = hop[:strings.Index(hop, ":")]
+	}
+	return net.ParseIP(hop)
+}
+
+func isTrustedProxy(ip net.IP, trusted []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted, err := parseTrustedProxies([]string{"10.0.0.0/8", "2001:db8::1"})
+	if err != nil {
+		t.Fatalf("parse trusted proxies: %v", err)
+	}
+	tests := []struct {
+		name        string
+		remoteAddr  string
+		proxyHeader string
+		header      http.Header
+		want        string
+	}{
+		{
+			name:       "untrusted peer",
+			remoteAddr: "192.0.2.1:1234",
+			header:     http.Header{"X-Forwarded-For": {"198.51.100.7"}},
+			want:       "192.0.2.1",
+		},
+		{
+			name:       "no forwarding headers",
+			remoteAddr: "10.0.0.1:1234",
+			want:       "10.0.0.1",
+		},
+		{
+			name:       "x-forwarded-for",
+			remoteAddr: "10.0.0.1:1234",
+			header:     http.Header{"X-Forwarded-For": {"198.51.100.7"}},
+			want:       "198.51.100.7",
+		},
+		{
+			name:       "spoofed x-forwarded-for",
+			remoteAddr: "10.0.0.1:1234",
+			header:     http.Header{"X-Forwarded-For": {"203.0.113.9, 198.51.100.7, 10.0.0.2"}},
+			want:       "198.51.100.7",
+		},
+		{
+			name:       "multiple x-forwarded-for headers",
+			remoteAddr: "10.0.0.1:1234",
+			header:     http.Header{"X-Forwarded-For": {"203.0.113.9", "198.51.100.7:4711"}},
+			want:       "198.51.100.7",
+		},
+		{
+			name:       "only trusted hops",
+			remoteAddr: "10.0.0.1:1234",
+			header:     http.Header{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}},
+			want:       "10.0.0.3",
+		},
+		{
+			// The proxy appends to X-Forwarded-For and passes on the
+			// Forwarded header injected by the client.
+			name:       "forwarded injected behind x-forwarded-for proxy",
+			remoteAddr: "10.0.0.1:1234",
+			header: http.Header{
+				"Forwarded":       {"for=203.0.113.9"},
+				"X-Forwarded-For": {"198.51.100.7"},
+			},
+			want: "198.51.100.7",
+		},
+		{
+			name:        "forwarded",
+			remoteAddr:  "[2001:db8::1]:1234",
+			proxyHeader: "Forwarded",
+			header: http.Header{
+				"Forwarded":       {`for=203.0.113.9, for="[2001:db8:cafe::17]:4711";proto=https`},
+				"X-Forwarded-For": {"198.51.100.7"},
+			},
+			want: "2001:db8:cafe::17",
+		},
+		{
+			name:        "obfuscated forwarded",
+			remoteAddr:  "10.0.0.1:1234",
+			proxyHeader: "Forwarded",
+			header:      http.Header{"Forwarded": {"for=198.51.100.7, for=_hidden, for=10.0.0.2"}},
+			want:        "10.0.0.2",
+		},
+		{
+			name:        "x-real-ip",
+			remoteAddr:  "10.0.0.1:1234",
+			proxyHeader: "X-Real-IP",
+			header:      http.Header{"X-Real-Ip": {"198.51.100.7"}},
+			want:        "198.51.100.7",
+		},
+		{
+			name:        "x-forwarded-for ignored behind x-real-ip proxy",
+			remoteAddr:  "10.0.0.1:1234",
+			proxyHeader: "X-Real-IP",
+			header:      http.Header{"X-Forwarded-For": {"198.51.100.7"}},
+			want:        "10.0.0.1",
+		},
+	}
+	for _, tc := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tc.remoteAddr
+		for k, v := range tc.header {
+			r.Header[k] = v
+		}
+		header, err := parseTrustedProxyHeader(tc.proxyHeader)
+		if err != nil {
+			t.Fatalf("%s: parse trusted proxy header: %v", tc.name, err)
+		}
+		if got := clientIP(r, trusted, header); got != tc.want {
+			t.Errorf("%s: expected client IP %s, got %s", tc.name, tc.want, got)
+		}
+	}
+
+	if _, err := parseTrustedProxies([]string{"10.0.0.0/33"}); err == nil {
+		t.Error("expected invalid network to be rejected")
+	}
+	if _, err := parseTrustedProxyHeader("X-Client-IP"); err == nil {
+		t.Error("expected unknown header to be rejected")
+	}
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strings"
+
+	"github.com/felixge/httpsnoop"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/dexidp/dex/storage"
+)
+
+const requestIDHeader = "X-Request-Id"
+
+// Endpoints used by clients rather than browsers, which get a JSON error
+// instead of the error page.
+var jsonEndpoints = []string{
+	"/token",
+	"/userinfo",
+	"/keys",
+	"/.well-known/openid-configuration",
+	"/version",
+	"/status",
+}
+
+func newPanicCounter(registry *prometheus.Registry) (prometheus.Counter, error) {
+	counter := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "http_panics_total",
+		Help: "Count of HTTP requests whose handler panicked.",
+	})
+	if registry != nil {
+		if err := registry.Register(counter); err != nil {
+			return nil, err
+		}
+	}
+	return counter, nil
+}
+
+// recoverPanics tags each request with an ID and turns panics in h into
+// an internal server error. The stack is logged along with the request ID
+// shown to the user, so reports can be matched to the logs.
+func (s *Server) recoverPanics(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if id == "" {
+			id = storage.NewID()
+		}
+		w.Header().Set(requestIDHeader, id)
+
+		wroteHeader := false
+		w = httpsnoop.Wrap(w, httpsnoop.Hooks{
+			WriteHeader: func(next httpsnoop.WriteHeaderFunc) httpsnoop.WriteHeaderFunc {
+				return func(code int) {
+					wroteHeader = true
+					next(code)
+				}
+			},
+			Write: func(next httpsnoop.WriteFunc) httpsnoop.WriteFunc {
+				return func(b []byte) (int, error) {
+					wroteHeader = true
+					return next(b)
+				}
+			},
+		})
+
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler {
+				// Deliberate abort, let net/http drop the connection.
+				panic(rec)
+			}
+			s.panicCounter.Inc()
+			s.logger.Errorf("panic serving %s %s (request %s): %v\n%s", r.Method, r.URL.Path, id, rec, debug.Stack())
+			if wroteHeader {
+				// Part of the response is already on its way, there's
+				// nothing sensible left to send.
+				return
+			}
+			description := fmt.Sprintf("Internal server error (request ID %s).", id)
+			if s.wantsJSON(r) {
+				s.tokenErrHelper(w, errServerError, description, http.StatusInternalServerError)
+				return
+			}
+			s.renderError(r, w, http.StatusInternalServerError, description)
+		}()
+		h.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) wantsJSON(r *http.Request) bool {
+	for _, p := range jsonEndpoints {
+		if r.URL.Path == s.absPath(p) {
+			return true
+		}
+	}
+	return strings.Contains(r.Header.Get("Accept"), "application/json")
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRecoverPanics(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.Routes = []Route{
+			{Path: "/panic", Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				panic("boom")
+			})},
+		}
+	})
+	defer httpServer.Close()
+
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status %d, got %d", http.StatusInternalServerError, rr.Code)
+	}
+	id := rr.Header().Get(requestIDHeader)
+	if id == "" {
+		t.Fatal("expected a request ID header")
+	}
+	if body := rr.Body.String(); !strings.Contains(body, id) || strings.Contains(body, "boom") {
+		t.Errorf("expected error page with the request ID and no panic value, got %q", body)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/panic", nil)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set(requestIDHeader, "req-123")
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	var resp struct {
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode error response: %v", err)
+	}
+	if resp.Error != errServerError || !strings.Contains(resp.Description, "req-123") {
+		t.Errorf("unexpected error response %+v", resp)
+	}
+
+	if got := testutil.ToFloat64(s.panicCounter); got != 2 {
+		t.Errorf("expected 2 panics to be counted, got %v", got)
+	}
+}
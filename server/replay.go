@@ -0,0 +1,176 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dexidp/dex/pkg/audit"
+	"github.com/dexidp/dex/storage"
+)
+
+// IDTokenReplay configures one-time-use ID tokens. Tokens issued to the
+// listed clients carry a "jti" claim, and resource servers can ask dex
+// whether a token has been presented before. Access tokens are built like ID
+// tokens, so they're tracked too.
+//
+// Issued tokens are tracked in memory until they expire, so only tokens
+// issued by this instance are known. Resource servers should treat tokens
+// reported as untracked like replayed ones.
+type IDTokenReplay struct {
+	// IDs of the clients whose tokens are tracked. Tracking is disabled if
+	// empty.
+	Clients []string
+
+	// Maximum number of tracked tokens. The tokens expiring first are
+	// forgotten when exceeded. Defaults to 100000.
+	MaxTokens int
+}
+
+// Statuses of a token presented to the replay endpoint.
+const (
+	replayFirstUse  = "first_use"
+	replayReplayed  = "replayed"
+	replayUntracked = "untracked"
+)
+
+type issuedToken struct {
+	id       string
+	clientID string
+	expiry   time.Time
+	used     bool
+}
+
+// issuedTokens tracks the IDs of issued tokens. All tokens have the same
+// lifetime, so tokens are queued in the order they expire.
+type issuedTokens struct {
+	clients []string
+	max     int
+
+	mu     sync.Mutex
+	tokens map[string]*issuedToken
+	queue  []*issuedToken
+}
+
+func newIssuedTokens(c IDTokenReplay) *issuedTokens {
+	if len(c.Clients) == 0 {
+		return nil
+	}
+	max := c.MaxTokens
+	if max <= 0 {
+		max = 100000
+	}
+	return &issuedTokens{
+		clients: c.Clients,
+		max:     max,
+		tokens:  make(map[string]*issuedToken),
+	}
+}
+
+// tracks reports whether tokens issued to the client are tracked.
+func (t *issuedTokens) tracks(clientID string) bool {
+	return t != nil && contains(t.clients, clientID)
+}
+
+func (t *issuedTokens) add(now time.Time, id, clientID string, expiry time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for len(t.queue) > 0 && (now.After(t.queue[0].expiry) || len(t.queue) >= t.max) {
+		delete(t.tokens, t.queue[0].id)
+		t.queue = t.queue[1:]
+	}
+	token := &issuedToken{id: id, clientID: clientID, expiry: expiry}
+	t.tokens[id] = token
+	t.queue = append(t.queue, token)
+}
+
+// use marks a token as used and returns its status.
+func (t *issuedTokens) use(now time.Time, id string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	token, ok := t.tokens[id]
+	switch {
+	case !ok || now.After(token.expiry):
+		return replayUntracked
+	case token.used:
+		return replayReplayed
+	}
+	token.used = true
+	return replayFirstUse
+}
+
+// handleReplay lets resource servers check whether an ID token has been
+// presented before. The resource server authenticates as a client in the
+// token's audience. The first check of a token marks it as used.
+func (s *Server) handleReplay(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.tokenErrHelper(w, errInvalidRequest, "method not allowed", http.StatusBadRequest)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		s.tokenErrHelper(w, errInvalidRequest, "Couldn't parse data", http.StatusBadRequest)
+		return
+	}
+	client, ok := s.authenticateClient(w, r)
+	if !ok {
+		return
+	}
+	if s.issuedTokens == nil {
+		s.tokenErrHelper(w, errInvalidRequest, "Replay detection is disabled.", http.StatusBadRequest)
+		return
+	}
+
+	token, err := s.tokenVerifier().Verify(r.Context(), r.PostForm.Get("token"))
+	if err != nil || !contains(token.Audience, client.ID) {
+		s.tokenErrHelper(w, errInvalidRequest, "Invalid token.", http.StatusBadRequest)
+		return
+	}
+	var claims struct {
+		ID string `json:"jti"`
+	}
+	if err := token.Claims(&claims); err != nil {
+		s.tokenErrHelper(w, errInvalidRequest, "Invalid token.", http.StatusBadRequest)
+		return
+	}
+
+	status := replayUntracked
+	if claims.ID != "" {
+		status = s.issuedTokens.use(s.now(), claims.ID)
+	}
+	if status == replayReplayed {
+		s.emitAudit(r.Context(), audit.Event{
+			Type:      audit.EventIDTokenReplay,
+			Severity:  audit.SeverityHigh,
+			ClientID:  client.ID,
+			Subject:   token.Subject,
+			SourceIPs: []string{remoteIP(r)},
+			Message:   "token " + claims.ID + " presented more than once",
+		})
+	}
+
+	data, err := json.Marshal(struct {
+		ID     string `json:"jti,omitempty"`
+		Status string `json:"status"`
+	}{claims.ID, status})
+	if err != nil {
+		s.logger.Errorf("failed to marshal replay response: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
+
+// trackIssuedToken returns the ID of a new token of the client, or an empty
+// string if the client's tokens aren't tracked.
+func (s *Server) trackIssuedToken(clientID string, expiry time.Time) string {
+	if !s.issuedTokens.tracks(clientID) {
+		return ""
+	}
+	id := storage.NewID()
+	s.issuedTokens.add(s.now(), id, clientID, expiry)
+	return id
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/dexidp/dex/pkg/audit"
+	"github.com/dexidp/dex/storage"
+)
+
+func TestIDTokenReplay(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sink := new(recordingSink)
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.AuditSink = sink
+		c.IDTokenReplay = IDTokenReplay{Clients: []string{"app"}, MaxTokens: 2}
+	})
+	defer httpServer.Close()
+
+	client := storage.Client{ID: "app", Secret: "secret"}
+	other := storage.Client{ID: "other", Secret: "secret"}
+	for _, c := range []storage.Client{client, other} {
+		if err := s.storage.CreateClient(ctx, c); err != nil {
+			t.Fatalf("create client: %v", err)
+		}
+	}
+	claims := storage.Claims{UserID: "1", Username: "jane"}
+	newToken := func(clientID string) string {
+		token, _, err := s.newIDToken(ctx, clientID, claims, []string{"openid"}, "", "", "mock")
+		if err != nil {
+			t.Fatalf("create id token: %v", err)
+		}
+		return token
+	}
+	check := func(c storage.Client, token string) (int, string) {
+		req := tokenRequest(c, "10.0.0.1:1234", url.Values{"token": {token}})
+		req.URL.Path = "/token/replay"
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+		var resp struct {
+			ID     string `json:"jti"`
+			Status string `json:"status"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &resp)
+		return rr.Code, resp.Status
+	}
+
+	token := newToken(client.ID)
+	for _, want := range []string{replayFirstUse, replayReplayed} {
+		if code, status := check(client, token); code != http.StatusOK || status != want {
+			t.Errorf("expected %s, got %d %s", want, code, status)
+		}
+	}
+	if len(sink.events) != 1 || sink.events[0].Type != audit.EventIDTokenReplay {
+		t.Errorf("expected replay to be reported, got %v", sink.events)
+	}
+	if code, _ := check(other, token); code != http.StatusBadRequest {
+		t.Errorf("expected token of another audience to be rejected, got %d", code)
+	}
+	if code, status := check(other, newToken(other.ID)); code != http.StatusOK || status != replayUntracked {
+		t.Errorf("expected token of untracked client to be untracked, got %d %s", code, status)
+	}
+
+	// Only the two most recent tokens are tracked.
+	token = newToken(client.ID)
+	newToken(client.ID)
+	newToken(client.ID)
+	if code, status := check(client, token); code != http.StatusOK || status != replayUntracked {
+		t.Errorf("expected forgotten token to be untracked, got %d %s", code, status)
+	}
+}
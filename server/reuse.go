@@ -0,0 +1,175 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dexidp/dex/pkg/audit"
+	"github.com/dexidp/dex/server/internal"
+	"github.com/dexidp/dex/storage"
+)
+
+// redeemedCode remembers who redeemed an auth code so a second redemption of
+// the same code can be reported with both parties.
+type redeemedCode struct {
+	clientID    string
+	userID      string
+	connectorID string
+	remoteIP    string
+	refreshID   string
+	expiry      time.Time
+}
+
+// codeRedemptionsSweepEvery is how many redemptions are recorded between
+// sweeps of the expired ones.
+const codeRedemptionsSweepEvery = 100
+
+// codeRedemptions tracks redeemed auth codes until they would have expired.
+//
+// Storage forgets a code once it's consumed, so this is an in-memory record.
+// Each instance only detects reuse of the codes it redeemed itself, a code
+// replayed against another replica goes unnoticed.
+type codeRedemptions struct {
+	mu    sync.Mutex
+	codes map[string]*redeemedCode
+	// added counts redemptions since the last sweep.
+	added int
+}
+
+func newCodeRedemptions() *codeRedemptions {
+	return &codeRedemptions{codes: make(map[string]*redeemedCode)}
+}
+
+func (c *codeRedemptions) add(now time.Time, code string, r *redeemedCode) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Expired codes are ignored by get, so they're only swept once in a
+	// while to bound memory instead of on every redemption.
+	if c.added++; c.added >= codeRedemptionsSweepEvery {
+		c.added = 0
+		for id, old := range c.codes {
+			if now.After(old.expiry) {
+				delete(c.codes, id)
+			}
+		}
+	}
+	c.codes[code] = r
+}
+
+func (c *codeRedemptions) setRefreshID(code, refreshID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if r, ok := c.codes[code]; ok {
+		r.refreshID = refreshID
+	}
+}
+
+func (c *codeRedemptions) get(now time.Time, code string) (redeemedCode, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r, ok := c.codes[code]
+	if !ok || now.After(r.expiry) {
+		return redeemedCode{}, false
+	}
+	return *r, true
+}
+
+// remoteIP returns the address of the client that issued the request.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// emitAudit delivers an event to the configured audit sink. Failures are
+// logged but never fail the request.
+func (s *Server) emitAudit(ctx context.Context, e audit.Event) {
+	if e.Time.IsZero() {
+		e.Time = s.now()
+	}
+	if err := s.events.Emit(ctx, e); err != nil {
+		s.logger.Errorf("failed to emit audit event %q: %v", e.Type, err)
+	}
+}
+
+func subjectFor(userID, connID string) string {
+	sub, err := internal.Marshal(&internal.IDTokenSubject{UserId: userID, ConnId: connID})
+	if err != nil {
+		return userID
+	}
+	return sub
+}
+
+// revokeRefreshFamily deletes a refresh token and its reference from the
+// user's offline session, leaving a tombstone with the reason. Refresh tokens
+// keep their ID across rotations, so this revokes every token ever derived
+// from the original grant.
+func (s *Server) revokeRefreshFamily(ctx context.Context, userID, connID, clientID, refreshID, reason string) error {
+	err := s.storage.UpdateOfflineSessions(ctx, userID, connID, func(old storage.OfflineSessions) (storage.OfflineSessions, error) {
+		if ref, ok := old.Refresh[clientID]; ok && ref.ID == refreshID {
+			delete(old.Refresh, clientID)
+		}
+		return old, nil
+	})
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+	if err := s.storage.DeleteRefresh(ctx, refreshID); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	s.tombstoneRefresh(ctx, refreshID, clientID, reason)
+	return nil
+}
+
+// reportAuthCodeReuse is called when a code that was already redeemed is
+// presented again.
+func (s *Server) reportAuthCodeReuse(r *http.Request, prev redeemedCode) {
+	e := audit.Event{
+		Type:        audit.EventAuthCodeReuse,
+		Severity:    audit.SeverityHigh,
+		ClientID:    prev.clientID,
+		Subject:     subjectFor(prev.userID, prev.connectorID),
+		ConnectorID: prev.connectorID,
+		SourceIPs:   []string{prev.remoteIP, remoteIP(r)},
+		Message:     "auth code redeemed more than once",
+	}
+	if s.revokeOnTokenReuse && prev.refreshID != "" {
+		if err := s.revokeRefreshFamily(r.Context(), prev.userID, prev.connectorID, prev.clientID, prev.refreshID, revocationReuseDetected); err != nil {
+			s.logger.Errorf("failed to revoke refresh token after auth code reuse: %v", err)
+		} else {
+			e.Revoked = true
+		}
+	}
+	s.emitAudit(r.Context(), e)
+}
+
+// reportRefreshTokenReuse is called when a refresh token that has since been
+// rotated is presented again.
+func (s *Server) reportRefreshTokenReuse(r *http.Request, refresh storage.RefreshToken) {
+	e := audit.Event{
+		Type:        audit.EventRefreshTokenReuse,
+		Severity:    audit.SeverityHigh,
+		ClientID:    refresh.ClientID,
+		Subject:     subjectFor(refresh.Claims.UserID, refresh.ConnectorID),
+		ConnectorID: refresh.ConnectorID,
+		SourceIPs:   []string{refresh.LastUsedIP, remoteIP(r)},
+		Message:     "rotated refresh token presented again",
+	}
+	if s.revokeOnTokenReuse {
+		if err := s.revokeRefreshFamily(r.Context(), refresh.Claims.UserID, refresh.ConnectorID, refresh.ClientID, refresh.ID, revocationReuseDetected); err != nil {
+			s.logger.Errorf("failed to revoke refresh token after reuse: %v", err)
+		} else {
+			e.Revoked = true
+		}
+	}
+	s.emitAudit(r.Context(), e)
+}
@@ -0,0 +1,213 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dexidp/dex/pkg/audit"
+	"github.com/dexidp/dex/server/internal"
+	"github.com/dexidp/dex/storage"
+)
+
+type recordingSink struct {
+	mu     sync.Mutex
+	events []audit.Event
+}
+
+func (r *recordingSink) Emit(ctx context.Context, e audit.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+	return nil
+}
+
+func tokenRequest(client storage.Client, remoteAddr string, form url.Values) *http.Request {
+	req := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(client.ID, client.Secret)
+	req.RemoteAddr = remoteAddr
+	return req
+}
+
+func TestAuthCodeReuseDetection(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sink := new(recordingSink)
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.AuditSink = sink
+	})
+	defer httpServer.Close()
+
+	client := storage.Client{
+		ID:           "testclient",
+		Secret:       "testclientsecret",
+		RedirectURIs: []string{"https://example.com/callback"},
+	}
+	if err := s.storage.CreateClient(ctx, client); err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	code := storage.AuthCode{
+		ID:          storage.NewID(),
+		ClientID:    client.ID,
+		RedirectURI: client.RedirectURIs[0],
+		Scopes:      []string{"openid"},
+		ConnectorID: "mock",
+		Claims:      storage.Claims{UserID: "1", Email: "jane.doe@example.com"},
+		Expiry:      time.Now().Add(time.Minute),
+	}
+	if err := s.storage.CreateAuthCode(ctx, code); err != nil {
+		t.Fatalf("failed to create auth code: %v", err)
+	}
+
+	form := url.Values{
+		"grant_type":   {grantTypeAuthorizationCode},
+		"code":         {code.ID},
+		"redirect_uri": {code.RedirectURI},
+	}
+
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, tokenRequest(client, "10.0.0.1:1234", form))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 redeeming code, got %d: %s", rr.Code, rr.Body)
+	}
+
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, tokenRequest(client, "10.0.0.2:1234", form))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 redeeming code twice, got %d", rr.Code)
+	}
+
+	if len(sink.events) != 1 {
+		t.Fatalf("expected one audit event, got %d", len(sink.events))
+	}
+	e := sink.events[0]
+	if e.Type != audit.EventAuthCodeReuse || e.Severity != audit.SeverityHigh {
+		t.Errorf("unexpected event %q with severity %q", e.Type, e.Severity)
+	}
+	if e.ClientID != client.ID {
+		t.Errorf("expected client %q, got %q", client.ID, e.ClientID)
+	}
+	if len(e.SourceIPs) != 2 || e.SourceIPs[0] != "10.0.0.1" || e.SourceIPs[1] != "10.0.0.2" {
+		t.Errorf("unexpected source IPs %v", e.SourceIPs)
+	}
+}
+
+func TestRefreshTokenReuseDetection(t *testing.T) {
+	tests := []struct {
+		name        string
+		revoke      bool
+		wantRevoked bool
+	}{
+		{"alert only", false, false},
+		{"revoke", true, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			sink := new(recordingSink)
+			httpServer, s := newTestServer(ctx, t, func(c *Config) {
+				c.AuditSink = sink
+				c.RevokeOnTokenReuse = tc.revoke
+			})
+			defer httpServer.Close()
+
+			client := storage.Client{ID: "testclient", Secret: "testclientsecret"}
+			if err := s.storage.CreateClient(ctx, client); err != nil {
+				t.Fatalf("failed to create client: %v", err)
+			}
+			refresh := storage.RefreshToken{
+				ID:          storage.NewID(),
+				Token:       "current",
+				ClientID:    client.ID,
+				ConnectorID: "mock",
+				Scopes:      []string{"openid", "offline_access"},
+				Claims:      storage.Claims{UserID: "1"},
+				CreatedAt:   time.Now(),
+				LastUsed:    time.Now(),
+				LastUsedIP:  "10.0.0.1",
+			}
+			if err := s.storage.CreateRefresh(ctx, refresh); err != nil {
+				t.Fatalf("failed to create refresh token: %v", err)
+			}
+			session := storage.OfflineSessions{
+				UserID: "1",
+				ConnID: "mock",
+				Refresh: map[string]*storage.RefreshTokenRef{
+					client.ID: {ID: refresh.ID, ClientID: client.ID},
+				},
+			}
+			if err := s.storage.CreateOfflineSessions(ctx, session); err != nil {
+				t.Fatalf("failed to create offline session: %v", err)
+			}
+
+			stale, err := internal.Marshal(&internal.RefreshToken{RefreshId: refresh.ID, Token: "rotated"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			form := url.Values{
+				"grant_type":    {grantTypeRefreshToken},
+				"refresh_token": {stale},
+			}
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, tokenRequest(client, "10.0.0.2:1234", form))
+			if rr.Code != http.StatusBadRequest {
+				t.Fatalf("expected 400 for reused refresh token, got %d", rr.Code)
+			}
+
+			if len(sink.events) != 1 {
+				t.Fatalf("expected one audit event, got %d", len(sink.events))
+			}
+			e := sink.events[0]
+			if e.Type != audit.EventRefreshTokenReuse {
+				t.Errorf("unexpected event type %q", e.Type)
+			}
+			if len(e.SourceIPs) != 2 || e.SourceIPs[0] != "10.0.0.1" || e.SourceIPs[1] != "10.0.0.2" {
+				t.Errorf("unexpected source IPs %v", e.SourceIPs)
+			}
+			if e.Revoked != tc.wantRevoked {
+				t.Errorf("expected revoked=%t, got %t", tc.wantRevoked, e.Revoked)
+			}
+
+			_, err = s.storage.GetRefresh(ctx, refresh.ID)
+			if tc.wantRevoked && !errors.Is(err, storage.ErrNotFound) {
+				t.Errorf("expected refresh token to be revoked, got %v", err)
+			}
+			if !tc.wantRevoked && err != nil {
+				t.Errorf("expected refresh token to be kept, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCodeRedemptionsSweep(t *testing.T) {
+	c := newCodeRedemptions()
+	now := time.Now()
+	c.add(now, "old", &redeemedCode{expiry: now.Add(time.Minute)})
+
+	later := now.Add(time.Hour)
+	if _, ok := c.get(later, "old"); ok {
+		t.Errorf("expected expired code to be ignored")
+	}
+	for i := 1; i < codeRedemptionsSweepEvery; i++ {
+		if _, ok := c.codes["old"]; !ok {
+			t.Fatalf("expected expired code to be kept until the next sweep, swept after %d redemptions", i)
+		}
+		c.add(later, storage.NewID(), &redeemedCode{expiry: later.Add(time.Minute)})
+	}
+	if _, ok := c.codes["old"]; ok {
+		t.Errorf("expected expired code to be swept after %d redemptions", codeRedemptionsSweepEvery)
+	}
+	if len(c.codes) != codeRedemptionsSweepEvery-1 {
+		t.Errorf("expected unexpired codes to be kept, got %d", len(c.codes))
+	}
+}
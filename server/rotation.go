@@ -72,8 +72,8 @@ func (s *Server) startKeyRotation(ctx context.Context, strategy rotationStrategy
 	rotater := keyRotater{s.storage, strategy, now, s.logger}
 
 	// Try to rotate immediately so properly configured storages will have keys.
-	if err := rotater.rotate(); err != nil {
-		if err == errAlreadyRotated {
+	if err := rotater.rotate(ctx); err != nil {
+		if errors.Is(err, errAlreadyRotated) {
 			s.logger.Infof("Key rotation not needed: %v", err)
 		} else {
 			s.logger.Errorf("failed to rotate keys: %v", err)
@@ -86,7 +86,7 @@ func (s *Server) startKeyRotation(ctx context.Context, strategy rotationStrategy
 			case <-ctx.Done():
 				return
 			case <-time.After(time.Second * 30):
-				if err := rotater.rotate(); err != nil {
+				if err := rotater.rotate(ctx); err != nil {
 					s.logger.Errorf("failed to rotate keys: %v", err)
 				}
 			}
@@ -94,10 +94,10 @@ func (s *Server) startKeyRotation(ctx context.Context, strategy rotationStrategy
 	}()
 }
 
-func (k keyRotater) rotate() error {
-	keys, err := k.GetKeys()
-	if err != nil && err != storage.ErrNotFound {
-		return fmt.Errorf("get keys: %v", err)
+func (k keyRotater) rotate(ctx context.Context) error {
+	keys, err := k.GetKeys(ctx)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("get keys: %w", err)
 	}
 	if k.now().Before(keys.NextRotation) {
 		return nil
@@ -107,7 +107,7 @@ func (k keyRotater) rotate() error {
 	// Generate the key outside of a storage transaction.
 	key, err := k.strategy.key()
 	if err != nil {
-		return fmt.Errorf("generate key: %v", err)
+		return fmt.Errorf("generate key: %w", err)
 	}
 	b := make([]byte, 20)
 	if _, err := io.ReadFull(rand.Reader, b); err != nil {
@@ -128,7 +128,7 @@ func (k keyRotater) rotate() error {
 	}
 
 	var nextRotation time.Time
-	err = k.Storage.UpdateKeys(func(keys storage.Keys) (storage.Keys, error) {
+	err = k.Storage.UpdateKeys(ctx, func(keys storage.Keys) (storage.Keys, error) {
 		tNow := k.now()
 
 		// if you are running multiple instances of dex, another instance
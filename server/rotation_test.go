@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"os"
 	"sort"
 	"testing"
@@ -13,7 +14,7 @@ import (
 )
 
 func signingKeyID(t *testing.T, s storage.Storage) string {
-	keys, err := s.GetKeys()
+	keys, err := s.GetKeys(context.Background())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -21,7 +22,7 @@ func signingKeyID(t *testing.T, s storage.Storage) string {
 }
 
 func verificationKeyIDs(t *testing.T, s storage.Storage) (ids []string) {
-	keys, err := s.GetKeys()
+	keys, err := s.GetKeys(context.Background())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -84,7 +85,7 @@ func TestKeyRotater(t *testing.T) {
 
 	for i := 0; i < 10; i++ {
 		now = now.Add(rotationFrequency + delta)
-		if err := r.rotate(); err != nil {
+		if err := r.rotate(context.Background()); err != nil {
 			t.Fatal(err)
 		}
 
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Scope is a custom scope clients may request in addition to the ones
+// defined by dex. Dex doesn't add any claims for custom scopes, requesting
+// one only asks the user to consent to it on the approval screen.
+type Scope struct {
+	Name string
+	// Description shown to users on the approval screen.
+	Description string
+	// Audiences added to the ID token when the scope is requested, as if the
+	// client requested "audience:server:client_id:<id>" for each. Like those
+	// scopes, each audience must trust the requesting client.
+	Audiences []string
+}
+
+// standardScopes are the scopes dex understands natively.
+var standardScopes = []string{
+	scopeOpenID,
+	scopeEmail,
+	scopeGroups,
+	scopeProfile,
+	scopeOfflineAccess,
+}
+
+// newScopeRegistry validates the custom scopes and maps their names to their
+// descriptions.
+func newScopeRegistry(scopes []Scope) (map[string]string, error) {
+	registry := make(map[string]string, len(scopes))
+	for _, scope := range scopes {
+		switch {
+		case scope.Name == "":
+			return nil, fmt.Errorf("custom scope with description %q has no name", scope.Description)
+		case strings.ContainsAny(scope.Name, " \t\n\""):
+			return nil, fmt.Errorf("custom scope %q contains invalid characters", scope.Name)
+		case contains(standardScopes, scope.Name) || scope.Name == scopeFederatedID,
+			strings.HasPrefix(scope.Name, scopeCrossClientPrefix):
+			return nil, fmt.Errorf("custom scope %q conflicts with a scope defined by dex", scope.Name)
+		}
+		if _, ok := registry[scope.Name]; ok {
+			return nil, fmt.Errorf("custom scope %q defined more than once", scope.Name)
+		}
+		if contains(scope.Audiences, "") {
+			return nil, fmt.Errorf("custom scope %q has an empty audience", scope.Name)
+		}
+		registry[scope.Name] = scope.Description
+	}
+	return registry, nil
+}
+
+// newScopeAudiences maps the names of custom scopes to their audiences.
+func newScopeAudiences(scopes []Scope) map[string][]string {
+	audiences := make(map[string][]string)
+	for _, scope := range scopes {
+		if len(scope.Audiences) > 0 {
+			audiences[scope.Name] = scope.Audiences
+		}
+	}
+	return audiences
+}
+
+// requestedAudiences returns the peer clients a scope requests as audiences,
+// either through the cross client scope or a custom scope with audiences.
+func (s *Server) requestedAudiences(scope string) (peerIDs []string, ok bool) {
+	if peerID, ok := parseCrossClientScope(scope); ok {
+		return []string{peerID}, true
+	}
+	peerIDs, ok = s.scopeAudiences[scope]
+	return peerIDs, ok
+}
+
+// supportedScopes returns the scopes advertised by the discovery document.
+func (s *Server) supportedScopes() []string {
+	custom := make([]string, 0, len(s.customScopes))
+	for name := range s.customScopes {
+		custom = append(custom, name)
+	}
+	sort.Strings(custom)
+	return append(append([]string{}, standardScopes...), custom...)
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+
+	"github.com/dexidp/dex/storage"
+)
+
+func TestNewScopeRegistry(t *testing.T) {
+	tests := []struct {
+		name    string
+		scopes  []Scope
+		wantErr bool
+	}{
+		{"valid", []Scope{{Name: "billing:read", Description: "View your invoices"}, {Name: "billing:write"}}, false},
+		{"no name", []Scope{{Description: "View your invoices"}}, true},
+		{"whitespace", []Scope{{Name: "billing read"}}, true},
+		{"standard scope", []Scope{{Name: "email"}}, true},
+		{"cross client scope", []Scope{{Name: scopeCrossClientPrefix + "foo"}}, true},
+		{"duplicate", []Scope{{Name: "billing:read"}, {Name: "billing:read"}}, true},
+		{"audiences", []Scope{{Name: "k8s:prod", Audiences: []string{"kube-prod"}}}, false},
+		{"empty audience", []Scope{{Name: "k8s:prod", Audiences: []string{""}}}, true},
+	}
+	for _, tc := range tests {
+		_, err := newScopeRegistry(tc.scopes)
+		if err != nil && !tc.wantErr {
+			t.Errorf("%s: %v", tc.name, err)
+		}
+		if err == nil && tc.wantErr {
+			t.Errorf("%s: expected error", tc.name)
+		}
+	}
+}
+
+func TestCustomScopes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.CustomScopes = []Scope{{Name: "billing:read", Description: "View your invoices"}}
+		c.Storage = storage.WithStaticClients(c.Storage, []storage.Client{{
+			ID:           "bar",
+			Name:         "Bar",
+			RedirectURIs: []string{"https://example.com/bar"},
+		}})
+	})
+	defer httpServer.Close()
+
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil))
+	var d discovery
+	if err := json.Unmarshal(rr.Body.Bytes(), &d); err != nil {
+		t.Fatalf("decode discovery: %v", err)
+	}
+	if !contains(d.Scopes, "billing:read") {
+		t.Errorf("expected custom scope in scopes_supported, got %q", d.Scopes)
+	}
+
+	authRequest := func(scope string) (*storage.AuthRequest, error) {
+		params := url.Values{
+			"client_id":     {"bar"},
+			"redirect_uri":  {"https://example.com/bar"},
+			"response_type": {"code"},
+			"scope":         {scope},
+		}
+		return s.parseAuthorizationRequest(httptest.NewRequest(http.MethodGet, "/auth?"+params.Encode(), nil))
+	}
+	if _, err := authRequest("openid billing:write"); err == nil {
+		t.Errorf("expected unregistered scope to be rejected")
+	}
+	authReq, err := authRequest("openid email billing:read")
+	if err != nil {
+		t.Fatalf("parse auth request with custom scope: %v", err)
+	}
+
+	authReq.LoggedIn = true
+	authReq.ConnectorID = "mock"
+	authReq.Expiry = time.Now().Add(time.Hour)
+	if err := s.storage.CreateAuthRequest(ctx, *authReq); err != nil {
+		t.Fatalf("create auth request: %v", err)
+	}
+	s.skipApproval = false
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/approval?req="+authReq.ID, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected approval page, got %d", rr.Code)
+	}
+	body := rr.Body.String()
+	for _, want := range []string{"View your invoices", "View your email address"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected approval page to contain %q", want)
+		}
+	}
+}
+
+func TestAudienceScopes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.CustomScopes = []Scope{{Name: "k8s:prod", Audiences: []string{"kube-prod"}}}
+		c.Storage = storage.WithStaticClients(c.Storage, []storage.Client{
+			{ID: "bar", RedirectURIs: []string{"https://example.com/bar"}},
+			{ID: "baz", RedirectURIs: []string{"https://example.com/baz"}},
+			{ID: "kube-prod", TrustedPeers: []string{"bar"}},
+		})
+	})
+	defer httpServer.Close()
+
+	authRequest := func(clientID string) (*storage.AuthRequest, error) {
+		params := url.Values{
+			"client_id":     {clientID},
+			"redirect_uri":  {"https://example.com/" + clientID},
+			"response_type": {"code"},
+			"scope":         {"openid k8s:prod"},
+		}
+		return s.parseAuthorizationRequest(httptest.NewRequest(http.MethodGet, "/auth?"+params.Encode(), nil))
+	}
+	if _, err := authRequest("baz"); err == nil {
+		t.Errorf("expected audience scope to be rejected for a client the audience doesn't trust")
+	}
+	if _, err := authRequest("bar"); err != nil {
+		t.Fatalf("parse auth request with audience scope: %v", err)
+	}
+
+	idToken, _, err := s.newIDToken(ctx, "bar", storage.Claims{UserID: "1"}, []string{"openid", "k8s:prod"}, "", "", "mock")
+	if err != nil {
+		t.Fatalf("failed to create id token: %v", err)
+	}
+	jws, err := jose.ParseSigned(idToken)
+	if err != nil {
+		t.Fatalf("failed to parse id token: %v", err)
+	}
+	var claims struct {
+		Aud []string `json:"aud"`
+		Azp string   `json:"azp"`
+	}
+	if err := json.Unmarshal(jws.UnsafePayloadWithoutVerification(), &claims); err != nil {
+		t.Fatal(err)
+	}
+	if !contains(claims.Aud, "kube-prod") || !contains(claims.Aud, "bar") || claims.Azp != "bar" {
+		t.Errorf("expected kube-prod audience with bar as authorized party, got %+v", claims)
+	}
+
+	if _, err := s.serviceAccountScopes([]string{"openid", "k8s:prod"}); err == nil {
+		t.Errorf("expected audience scope to be rejected for service accounts")
+	}
+}
@@ -1,10 +1,12 @@
 package server
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
 	"path"
@@ -18,12 +20,12 @@ import (
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus"
-	"golang.org/x/crypto/bcrypt"
 
 	"github.com/dexidp/dex/connector"
 	"github.com/dexidp/dex/connector/atlassiancrowd"
 	"github.com/dexidp/dex/connector/authproxy"
 	"github.com/dexidp/dex/connector/bitbucketcloud"
+	"github.com/dexidp/dex/connector/email"
 	"github.com/dexidp/dex/connector/gitea"
 	"github.com/dexidp/dex/connector/github"
 	"github.com/dexidp/dex/connector/gitlab"
@@ -36,7 +38,9 @@ import (
 	"github.com/dexidp/dex/connector/oidc"
 	"github.com/dexidp/dex/connector/openshift"
 	"github.com/dexidp/dex/connector/saml"
+	"github.com/dexidp/dex/pkg/audit"
 	"github.com/dexidp/dex/pkg/log"
+	"github.com/dexidp/dex/pkg/secret"
 	"github.com/dexidp/dex/storage"
 )
 
@@ -68,6 +72,15 @@ type Config struct {
 	// domain.
 	AllowedOrigins []string
 
+	// Networks of proxies in front of dex, in CIDR notation. The client
+	// address of requests from these is taken from TrustedProxyHeader.
+	TrustedProxies []string
+	// The header the trusted proxies report client addresses in, one of
+	// Forwarded, X-Forwarded-For or X-Real-IP. Defaults to X-Forwarded-For.
+	// Other headers are ignored, since the proxies may pass them on from
+	// clients unchanged.
+	TrustedProxyHeader string
+
 	// If enabled, the server won't prompt the user to approve authorization requests.
 	// Logging in implies approval.
 	SkipApprovalScreen bool
@@ -78,14 +91,186 @@ type Config struct {
 	RotateKeysAfter      time.Duration // Defaults to 6 hours.
 	IDTokensValidFor     time.Duration // Defaults to 24 hours
 	AuthRequestsValidFor time.Duration // Defaults to 24 hours
+
+	// How long revoked refresh tokens are reported as revoked by the
+	// introspection endpoint, rather than as unknown. Defaults to 24 hours.
+	RevokedTokensValidFor time.Duration
+
+	// How long users stay logged in to dex. Authorization requests from a
+	// browser with a session skip the connector. Sessions are disabled if
+	// zero.
+	SessionsValidFor time.Duration
+
+	// Grace period applied when checking whether auth requests, auth codes
+	// and access tokens presented to the userinfo endpoint have expired.
+	// Covers clients and servers whose clocks drift apart.
+	ClockSkewTolerance time.Duration
+
+	// Periodically compare the local clock against an NTP server.
+	ClockDriftCheck ClockDriftCheck
+
+	// Periodically refresh the identities of users with refresh tokens from
+	// their connectors.
+	GroupSync GroupSync
+
+	// Delay responses to failed password logins and client authentication.
+	FailureDelay FailureDelay
+
+	// Limits the distinct values of client and connector metric labels.
+	MetricLabels MetricLabels
+
 	// If set, the server will use this connector to handle password grants
 	PasswordConnector string
 
+	// If enabled, API keys created through the gRPC API can be exchanged for
+	// tokens at the token endpoint.
+	AllowAPIKeys bool
+
+	// If enabled, pre-authorized codes created through the gRPC API can be
+	// exchanged for tokens at the token endpoint.
+	AllowPreAuthorizedCodes bool
+
+	// If enabled, service accounts can exchange JWT assertions signed with
+	// their keys for tokens at the token endpoint.
+	AllowServiceAccounts bool
+
+	// Trust domains and clients of workloads authenticating with SPIFFE
+	// SVIDs.
+	SPIFFE SPIFFEConfig
+
+	// Fallback connector IDs by connector ID. If a connector fails to log a
+	// user in because of an upstream error, the user is offered its fallback.
+	ConnectorFallbacks map[string]string
+
+	// Group filters by connector ID, applied to the identities returned by
+	// the connectors.
+	ConnectorGroupFilters map[string]GroupFilter
+
+	// Types of verifiable credentials issued by the credential endpoint,
+	// which is only served if any are configured.
+	CredentialTypes []CredentialType
+
 	GCFrequency time.Duration // Defaults to 5 minutes
 
+	// Receives security relevant events such as refresh token reuse. Defaults
+	// to writing the events to Logger.
+	AuditSink audit.Sink
+
+	// Receive every audit event asynchronously, in addition to AuditSink.
+	EventConsumers []EventConsumer
+
+	// If non-zero, audit events are also persisted in the storage, where
+	// they can be queried through the gRPC API, and deleted once they are
+	// older than the retention.
+	AuditRetention time.Duration
+
+	// Notify operators when storage, signing or connectors fail repeatedly.
+	Alerts Alerts
+
+	// Reject low-priority requests while the storage is slow or failing.
+	LoadShedding LoadShedding
+
+	// Priority classes of endpoint groups, overriding the defaults. Requests
+	// of lower classes are shed first. Groups are "refresh", "machine",
+	// "introspection", "userinfo" and "admin", classes "interactive",
+	// "refresh", "background" and "admin".
+	Priorities map[string]string
+
+	// If enabled, detecting the reuse of a refresh token or auth code revokes
+	// the refresh token issued for that grant.
+	RevokeOnTokenReuse bool
+
+	// Restrict when matching clients and users can obtain new tokens.
+	AccessWindows []AccessWindow
+
+	// If set, consulted after the access windows whenever tokens are issued.
+	PolicyEngine PolicyEngine
+
+	// If set, consulted before any tokens are issued. It may deny the request
+	// or reduce the scopes the tokens are issued for.
+	Authorizer Authorizer
+
+	// Disable refresh tokens for matching clients, connectors and grant types.
+	OfflineAccessRules []OfflineAccessRule
+
+	// Decide whether refreshes contact the connector, by client and
+	// connector.
+	RefreshPolicies []RefreshPolicy
+
+	// If set, evaluated alongside the active policies without affecting
+	// responses.
+	ShadowPolicies *ShadowPolicies
+
+	// Track ID tokens of the listed clients, so resource servers can
+	// detect replays.
+	IDTokenReplay IDTokenReplay
+
+	// Transform the identities returned by connectors before tokens are
+	// issued for them.
+	ClaimTransforms []ClaimTransform
+
+	// Additional scopes clients may request. Their descriptions are shown on
+	// the approval screen.
+	CustomScopes []Scope
+
+	// If set, client secrets are verified against hashes made by this hasher.
+	// Plaintext secrets of existing clients are replaced by hashes the next
+	// time the client authenticates.
+	ClientSecretHasher secret.Hasher
+
+	// If set, passwords of the password database are rehashed with this
+	// hasher on login when they were hashed with another algorithm or other
+	// parameters.
+	PasswordHasher secret.Hasher
+
+	// Experimental features to enable for this issuer.
+	Features []Feature
+
+	// Domains of the users the WebFinger endpoint points to this issuer. If
+	// empty, it answers for users of any domain.
+	WebFingerDomains []string
+
+	// Fields added to the discovery document, replacing generated fields of
+	// the same name. Fields describing dex's endpoints can't be overridden.
+	DiscoveryOverrides map[string]interface{}
+
+	// Name of the storage backend, reported by the status endpoint.
+	StorageType string
+
+	// If set, requests bearing this token are allowed to see administrative
+	// views such as the detailed status.
+	AdminToken string
+
+	// If set, tokens are signed by this signer instead of the storage keys,
+	// and its public key is published next to theirs. The storage keys keep
+	// signing every token in the shadow and the signatures are compared,
+	// allowing a zero-risk migration to a new algorithm or a KMS.
+	MigrationSigner Signer
+
+	// If set, users must accept these terms of service after logging in and
+	// before tokens are issued to them.
+	TermsOfService TermsOfService
+
+	// If set, users of the local password connector are asked for a passkey
+	// after their password.
+	WebAuthn WebAuthn
+
+	// If set, users of the local password connector are asked for a code of
+	// their authenticator app after their password. It can't be enabled
+	// along with WebAuthn.
+	TOTP TOTP
+
 	// If specified, the server will use this function for determining time.
 	Now func() time.Time
 
+	// Middleware wraps every request, including requests for Routes. The
+	// first middleware is the outermost one. They run before routing, so
+	// they see requests for unknown paths as well.
+	Middleware []Middleware
+
+	// Additional routes to serve. They may not override dex's own endpoints.
+	Routes []Route
+
 	Web WebConfig
 
 	Logger log.Logger
@@ -108,6 +293,9 @@ type WebConfig struct {
 	//
 	Dir string
 
+	// A filepath to the HTML templates. Defaults to "( Dir )/templates".
+	TemplatesDir string
+
 	// Defaults to "( issuer URL )/theme/logo.png"
 	LogoURL string
 
@@ -152,18 +340,111 @@ type Server struct {
 	// Used for password grant
 	passwordConnector string
 
+	allowAPIKeys         bool
+	allowPreAuthCodes    bool
+	allowServiceAccounts bool
+
+	spiffe *spiffeVerifier
+
+	connectorFallbacks map[string]string
+
+	groupFilters map[string]GroupFilter
+
+	credentialTypes  map[string]CredentialType
+	credentialProofs *credentialProofs
+
 	supportedResponseTypes map[string]bool
 
 	now func() time.Time
 
 	idTokensValidFor     time.Duration
 	authRequestsValidFor time.Duration
+	clockSkewTolerance   time.Duration
+
+	revokedTokensValidFor time.Duration
+	sessionsValidFor      time.Duration
+
+	audit              audit.Sink
+	auditRetention     time.Duration
+	auditStream        *audit.Broadcaster
+	events             *audit.Bus
+	alerts             *failureTracker
+	revokeOnTokenReuse bool
+	redeemedCodes      *codeRedemptions
+	issuedTokens       *issuedTokens
+
+	accessWindows      []AccessWindow
+	policyEngine       PolicyEngine
+	authorizer         Authorizer
+	offlineAccessRules []OfflineAccessRule
+
+	terms TermsOfService
+
+	webAuthn *WebAuthn
+	totp     *TOTP
+
+	// Login links recently sent by link connectors.
+	sentLinks *sentLinks
+
+	// Custom scope names mapped to their descriptions.
+	customScopes map[string]string
+	// Custom scope names mapped to the audiences they request.
+	scopeAudiences map[string][]string
+
+	trustedProxies     []*net.IPNet
+	trustedProxyHeader string
+
+	features map[Feature]bool
+
+	storageType string
+	adminToken  string
+
+	discoveryOverrides map[string]interface{}
+	webFingerDomains   []string
+
+	clientSecretHasher secret.Hasher
+	passwordHasher     secret.Hasher
+	// Client secrets and password hashes which couldn't be replaced.
+	secretUpgrades   *failedUpgrades
+	passwordUpgrades *failedUpgrades
+
+	failureDelay FailureDelay
+
+	signingMigration *signingMigration
+
+	panicCounter prometheus.Counter
+
+	refreshPolicies []RefreshPolicy
+	claimTransforms []claimTransform
+
+	shadowPolicies  *ShadowPolicies
+	shadowDecisions *prometheus.CounterVec
+
+	connectorMetrics *connectorMetrics
+	tokenMetrics     *tokenMetrics
+
+	priorities    map[string]priority
+	storageHealth *storageHealth
+	shedRequests  *prometheus.CounterVec
+
+	// When the key set served by the keys endpoint last changed.
+	keysModified lastModified
 
 	logger log.Logger
 }
 
-// NewServer constructs a server from the provided config.
-func NewServer(ctx context.Context, c Config) (*Server, error) {
+// expired reports whether the expiry has passed, allowing for the configured
+// clock skew tolerance.
+func (s *Server) expired(expiry time.Time) bool {
+	return s.now().After(expiry.Add(s.clockSkewTolerance))
+}
+
+// NewServer constructs a server from the provided config. Options are
+// applied to the config in order before the server is constructed.
+func NewServer(ctx context.Context, c Config, opts ...Option) (*Server, error) {
+	for _, opt := range opts {
+		opt(&c)
+	}
 	return newServer(ctx, c, defaultRotationStrategy(
 		value(c.RotateKeysAfter, 6*time.Hour),
 		value(c.IDTokensValidFor, 24*time.Hour),
@@ -193,18 +474,82 @@ func newServer(ctx context.Context, c Config, rotationStrategy rotationStrategy)
 		supported[respType] = true
 	}
 
+	customScopes, err := newScopeRegistry(c.CustomScopes)
+	if err != nil {
+		return nil, fmt.Errorf("server: %w", err)
+	}
+
+	features, err := newFeatureSet(c.Features)
+	if err != nil {
+		return nil, fmt.Errorf("server: %w", err)
+	}
+
+	claimTransforms, err := newClaimTransforms(c.ClaimTransforms)
+	if err != nil {
+		return nil, fmt.Errorf("server: %w", err)
+	}
+
+	if err := validateGroupFilters(c.ConnectorGroupFilters); err != nil {
+		return nil, fmt.Errorf("server: %w", err)
+	}
+
+	credentialTypes, err := newCredentialTypes(c.CredentialTypes, value(c.IDTokensValidFor, 24*time.Hour))
+	if err != nil {
+		return nil, fmt.Errorf("server: %w", err)
+	}
+
+	priorities, err := newPriorities(c.Priorities)
+	if err != nil {
+		return nil, fmt.Errorf("server: %w", err)
+	}
+
+	webAuthn, err := newWebAuthn(c.WebAuthn, issuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("server: %w", err)
+	}
+
+	totp, err := newTOTP(c.TOTP)
+	if err != nil {
+		return nil, fmt.Errorf("server: %w", err)
+	}
+	if webAuthn != nil && totp != nil {
+		return nil, errors.New("server: WebAuthn and TOTP can't both be enabled")
+	}
+
+	if c.PasswordHasher != nil {
+		// Make sure rehashed passwords are still accepted at login.
+		hash, err := c.PasswordHasher.Hash([]byte("password"))
+		if err != nil {
+			return nil, fmt.Errorf("server: failed to hash password: %w", err)
+		}
+		if err := checkCost([]byte(hash)); err != nil {
+			return nil, fmt.Errorf("server: invalid password hashing parameters: %w", err)
+		}
+	}
+
 	web := webConfig{
-		dir:       c.Web.Dir,
-		logoURL:   c.Web.LogoURL,
-		issuerURL: c.Issuer,
-		issuer:    c.Web.Issuer,
-		theme:     c.Web.Theme,
-		extra:     c.Web.Extra,
+		dir:          c.Web.Dir,
+		templatesDir: c.Web.TemplatesDir,
+		logoURL:      c.Web.LogoURL,
+		issuerURL:    c.Issuer,
+		issuer:       c.Web.Issuer,
+		theme:        c.Web.Theme,
+		extra:        c.Web.Extra,
+		scopes:       customScopes,
 	}
 
 	static, theme, tmpls, err := loadWebConfig(web)
 	if err != nil {
-		return nil, fmt.Errorf("server: failed to load web static: %v", err)
+		return nil, fmt.Errorf("server: failed to load web static: %w", err)
+	}
+
+	trustedProxies, err := parseTrustedProxies(c.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("server: %w", err)
+	}
+	trustedProxyHeader, err := parseTrustedProxyHeader(c.TrustedProxyHeader)
+	if err != nil {
+		return nil, fmt.Errorf("server: %w", err)
 	}
 
 	now := c.Now
@@ -212,26 +557,94 @@ func newServer(ctx context.Context, c Config, rotationStrategy rotationStrategy)
 		now = time.Now
 	}
 
+	storageHealth := newStorageHealth(c.LoadShedding, now)
+	store := c.Storage
+	if storageHealth != nil {
+		store = healthRecorder{Storage: store, health: storageHealth}
+	}
+
 	s := &Server{
 		issuerURL:              *issuerURL,
 		connectors:             make(map[string]Connector),
-		storage:                newKeyCacher(c.Storage, now),
+		storage:                newKeyCacher(store, now),
 		supportedResponseTypes: supported,
 		idTokensValidFor:       value(c.IDTokensValidFor, 24*time.Hour),
 		authRequestsValidFor:   value(c.AuthRequestsValidFor, 24*time.Hour),
+		revokedTokensValidFor:  value(c.RevokedTokensValidFor, 24*time.Hour),
+		sessionsValidFor:       c.SessionsValidFor,
+		clockSkewTolerance:     c.ClockSkewTolerance,
 		skipApproval:           c.SkipApprovalScreen,
 		alwaysShowLogin:        c.AlwaysShowLoginScreen,
 		now:                    now,
 		templates:              tmpls,
 		passwordConnector:      c.PasswordConnector,
+		allowAPIKeys:           c.AllowAPIKeys,
+		allowPreAuthCodes:      c.AllowPreAuthorizedCodes,
+		allowServiceAccounts:   c.AllowServiceAccounts,
+		connectorFallbacks:     c.ConnectorFallbacks,
+		groupFilters:           c.ConnectorGroupFilters,
+		credentialTypes:        credentialTypes,
+		credentialProofs:       newCredentialProofs(),
+		audit:                  c.AuditSink,
+		auditRetention:         c.AuditRetention,
+		alerts:                 newFailureTracker(c.Alerts),
+		priorities:             priorities,
+		storageHealth:          storageHealth,
+		revokeOnTokenReuse:     c.RevokeOnTokenReuse,
+		redeemedCodes:          newCodeRedemptions(),
+		issuedTokens:           newIssuedTokens(c.IDTokenReplay),
+		accessWindows:          c.AccessWindows,
+		policyEngine:           c.PolicyEngine,
+		authorizer:             c.Authorizer,
+		offlineAccessRules:     c.OfflineAccessRules,
+		refreshPolicies:        c.RefreshPolicies,
+		claimTransforms:        claimTransforms,
+		shadowPolicies:         c.ShadowPolicies,
+		terms:                  c.TermsOfService,
+		webAuthn:               webAuthn,
+		totp:                   totp,
+		sentLinks:              newSentLinks(),
+		customScopes:           customScopes,
+		scopeAudiences:         newScopeAudiences(c.CustomScopes),
+		trustedProxies:         trustedProxies,
+		trustedProxyHeader:     trustedProxyHeader,
+		features:               features,
+		storageType:            c.StorageType,
+		adminToken:             c.AdminToken,
+		discoveryOverrides:     c.DiscoveryOverrides,
+		webFingerDomains:       c.WebFingerDomains,
+		clientSecretHasher:     c.ClientSecretHasher,
+		passwordHasher:         c.PasswordHasher,
+		secretUpgrades:         newFailedUpgrades(),
+		passwordUpgrades:       newFailedUpgrades(),
+		failureDelay:           c.FailureDelay,
 		logger:                 c.Logger,
 	}
+	if s.audit == nil {
+		s.audit = audit.NewLoggerSink(c.Logger)
+	}
+	s.auditStream = audit.NewBroadcaster()
+	if s.events, err = s.newEventBus(c.EventConsumers, c.PrometheusRegistry); err != nil {
+		return nil, fmt.Errorf("server: %w", err)
+	}
+	go func() {
+		<-ctx.Done()
+		s.events.Close()
+	}()
+	if s.spiffe, err = newSPIFFEVerifier(c.SPIFFE); err != nil {
+		return nil, fmt.Errorf("server: %w", err)
+	}
+	if c.MigrationSigner != nil {
+		if s.signingMigration, err = newSigningMigration(c.MigrationSigner, c.PrometheusRegistry); err != nil {
+			return nil, fmt.Errorf("server: %w", err)
+		}
+	}
 
 	// Retrieves connector objects in backend storage. This list includes the static connectors
 	// defined in the ConfigMap and dynamic connectors retrieved from the storage.
-	storageConnectors, err := c.Storage.ListConnectors()
+	storageConnectors, err := c.Storage.ListConnectors(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("server: failed to list connector objects from storage: %v", err)
+		return nil, fmt.Errorf("server: failed to list connector objects from storage: %w", err)
 	}
 
 	if len(storageConnectors) == 0 && len(s.connectors) == 0 {
@@ -240,10 +653,26 @@ func newServer(ctx context.Context, c Config, rotationStrategy rotationStrategy)
 
 	for _, conn := range storageConnectors {
 		if _, err := s.OpenConnector(conn); err != nil {
-			return nil, fmt.Errorf("server: Failed to open connector %s: %v", conn.ID, err)
+			return nil, fmt.Errorf("server: Failed to open connector %s: %w", conn.ID, err)
 		}
 	}
 
+	if s.panicCounter, err = newPanicCounter(c.PrometheusRegistry); err != nil {
+		return nil, fmt.Errorf("server: Failed to register Prometheus panic metrics: %w", err)
+	}
+	if s.shadowDecisions, err = newShadowPolicyCounter(c.PrometheusRegistry); err != nil {
+		return nil, fmt.Errorf("server: Failed to register Prometheus shadow policy metrics: %w", err)
+	}
+	if s.connectorMetrics, err = newConnectorMetrics(c.PrometheusRegistry, c.MetricLabels.Connector); err != nil {
+		return nil, fmt.Errorf("server: %w", err)
+	}
+	if s.tokenMetrics, err = newTokenMetrics(c.PrometheusRegistry, c.MetricLabels.ClientID); err != nil {
+		return nil, fmt.Errorf("server: %w", err)
+	}
+	if s.shedRequests, err = newShedCounter(c.PrometheusRegistry); err != nil {
+		return nil, fmt.Errorf("server: %w", err)
+	}
+
 	instrumentHandlerCounter := func(handlerName string, handler http.Handler) http.HandlerFunc {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			handler.ServeHTTP(w, r)
@@ -255,28 +684,40 @@ func newServer(ctx context.Context, c Config, rotationStrategy rotationStrategy)
 			Name: "http_requests_total",
 			Help: "Count of all HTTP requests.",
 		}, []string{"handler", "code", "method"})
-
-		err = c.PrometheusRegistry.Register(requestCounter)
-		if err != nil {
-			return nil, fmt.Errorf("server: Failed to register Prometheus HTTP metrics: %v", err)
+		requestDuration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Duration of all HTTP requests.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"handler", "method"})
+
+		for _, collector := range []prometheus.Collector{requestCounter, requestDuration} {
+			if err := c.PrometheusRegistry.Register(collector); err != nil {
+				return nil, fmt.Errorf("server: Failed to register Prometheus HTTP metrics: %w", err)
+			}
 		}
 
 		instrumentHandlerCounter = func(handlerName string, handler http.Handler) http.HandlerFunc {
 			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				m := httpsnoop.CaptureMetrics(handler, w, r)
 				requestCounter.With(prometheus.Labels{"handler": handlerName, "code": strconv.Itoa(m.Code), "method": r.Method}).Inc()
+				requestDuration.With(prometheus.Labels{"handler": handlerName, "method": r.Method}).Observe(m.Duration.Seconds())
 			})
 		}
 	}
 
 	r := mux.NewRouter()
+	// Paths of the routes registered so far, used to detect conflicts with
+	// Routes from the config.
+	routes := make(map[string]bool)
 	handle := func(p string, h http.Handler) {
+		routes[p] = true
 		r.Handle(path.Join(issuerURL.Path, p), instrumentHandlerCounter(p, h))
 	}
 	handleFunc := func(p string, h http.HandlerFunc) {
 		handle(p, h)
 	}
 	handlePrefix := func(p string, h http.Handler) {
+		routes[p] = true
 		prefix := path.Join(issuerURL.Path, p)
 		r.PathPrefix(prefix).Handler(http.StripPrefix(prefix, h))
 	}
@@ -286,7 +727,7 @@ func newServer(ctx context.Context, c Config, rotationStrategy rotationStrategy)
 			corsOption := handlers.AllowedOrigins(c.AllowedOrigins)
 			handler = handlers.CORS(corsOption)(handler)
 		}
-		r.Handle(path.Join(issuerURL.Path, p), instrumentHandlerCounter(p, handler))
+		handle(p, handler)
 	}
 	r.NotFoundHandler = http.HandlerFunc(http.NotFound)
 
@@ -295,11 +736,23 @@ func newServer(ctx context.Context, c Config, rotationStrategy rotationStrategy)
 		return nil, err
 	}
 	handleWithCORS("/.well-known/openid-configuration", discoveryHandler)
+	handleFunc("/.well-known/webfinger", s.handleWebFinger)
+	if len(s.credentialTypes) > 0 {
+		credentialIssuerHandler, err := s.credentialIssuerHandler()
+		if err != nil {
+			return nil, err
+		}
+		handleWithCORS("/.well-known/openid-credential-issuer", credentialIssuerHandler)
+		handleWithCORS("/credential", s.handleCredential)
+	}
 
 	// TODO(ericchiang): rate limit certain paths based on IP.
 	handleWithCORS("/token", s.handleToken)
 	handleWithCORS("/keys", s.handlePublicKeys)
 	handleWithCORS("/userinfo", s.handleUserInfo)
+	handleFunc("/token/introspect", s.handleIntrospect)
+	handleFunc("/token/replay", s.handleReplay)
+	handleFunc("/logout", s.handleLogout)
 	handleFunc("/auth", s.handleAuthorization)
 	handleFunc("/auth/{connector}", s.handleConnectorLogin)
 	r.HandleFunc(path.Join(issuerURL.Path, "/callback"), func(w http.ResponseWriter, r *http.Request) {
@@ -316,13 +769,36 @@ func newServer(ctx context.Context, c Config, rotationStrategy rotationStrategy)
 	// "authproxy" connector.
 	handleFunc("/callback/{connector}", s.handleConnectorCallback)
 	handleFunc("/approval", s.handleApproval)
+	handleFunc("/terms", s.handleTerms)
+	handleFunc("/webauthn", s.handleWebAuthn)
+	handleFunc("/totp", s.handleTOTP)
+	handleFunc("/link", s.handleLoginLink)
 	handle("/healthz", s.newHealthChecker(ctx))
+	handleFunc("/version", s.handleVersion)
+	handleFunc("/status", s.handleStatus)
 	handlePrefix("/static", static)
 	handlePrefix("/theme", theme)
-	s.mux = r
+	routes["/callback"] = true
+
+	for _, route := range c.Routes {
+		if err := route.validate(); err != nil {
+			return nil, fmt.Errorf("server: %w", err)
+		}
+		if routes[route.Path] {
+			return nil, fmt.Errorf("server: route %q conflicts with an existing route", route.Path)
+		}
+		if route.Prefix {
+			handlePrefix(route.Path, route.Handler)
+		} else {
+			handle(route.Path, route.Handler)
+		}
+	}
+	s.mux = compress(s.recoverPanics(s.resolveRemoteAddr(chain(r, c.Middleware))))
 
 	s.startKeyRotation(ctx, rotationStrategy, now)
 	s.startGarbageCollection(ctx, value(c.GCFrequency, 5*time.Minute), now)
+	s.startClockDriftCheck(ctx, c.ClockDriftCheck)
+	s.startGroupSync(ctx, c.GroupSync)
 
 	return s, nil
 }
@@ -344,22 +820,25 @@ func (s *Server) absURL(pathItems ...string) string {
 	return u.String()
 }
 
-func newPasswordDB(s storage.Storage) interface {
+func newPasswordDB(s storage.Storage, hasher secret.Hasher, upgrades *failedUpgrades, logger log.Logger) interface {
 	connector.Connector
 	connector.PasswordConnector
 } {
-	return passwordDB{s}
+	return passwordDB{s, hasher, upgrades, logger}
 }
 
 type passwordDB struct {
-	s storage.Storage
+	s        storage.Storage
+	hasher   secret.Hasher
+	upgrades *failedUpgrades
+	logger   log.Logger
 }
 
 func (db passwordDB) Login(ctx context.Context, s connector.Scopes, email, password string) (connector.Identity, bool, error) {
-	p, err := db.s.GetPassword(email)
+	p, err := db.s.GetPassword(ctx, email)
 	if err != nil {
-		if err != storage.ErrNotFound {
-			return connector.Identity{}, false, fmt.Errorf("get password: %v", err)
+		if !errors.Is(err, storage.ErrNotFound) {
+			return connector.Identity{}, false, fmt.Errorf("get password: %w", err)
 		}
 		return connector.Identity{}, false, nil
 	}
@@ -368,8 +847,11 @@ func (db passwordDB) Login(ctx context.Context, s connector.Scopes, email, passw
 	if err := checkCost(p.Hash); err != nil {
 		return connector.Identity{}, false, err
 	}
-	if err := bcrypt.CompareHashAndPassword(p.Hash, []byte(password)); err != nil {
-		return connector.Identity{}, false, nil
+	if ok, err := secret.Verify(string(p.Hash), []byte(password)); !ok {
+		return connector.Identity{}, false, err
+	}
+	if db.hasher != nil && db.hasher.NeedsRehash(string(p.Hash)) && !db.upgrades.failed(p.Email, string(p.Hash)) {
+		db.rehash(ctx, p, password)
 	}
 	return connector.Identity{
 		UserID:        p.UserID,
@@ -379,14 +861,36 @@ func (db passwordDB) Login(ctx context.Context, s connector.Scopes, email, passw
 	}, true, nil
 }
 
+// rehash replaces the hash of the password with one made by the configured
+// hasher. Failing to do so doesn't fail the login, static passwords for
+// example can't be updated. Such passwords aren't rehashed on later logins.
+func (db passwordDB) rehash(ctx context.Context, p storage.Password, password string) {
+	hash, err := db.hasher.Hash([]byte(password))
+	if err != nil {
+		db.logger.Errorf("failed to rehash password of %s: %v", log.Email(p.Email), err)
+		return
+	}
+	err = db.s.UpdatePassword(ctx, p.Email, func(old storage.Password) (storage.Password, error) {
+		// Keep passwords changed since they were read.
+		if bytes.Equal(old.Hash, p.Hash) {
+			old.Hash = []byte(hash)
+		}
+		return old, nil
+	})
+	if err != nil {
+		db.logger.Debugf("failed to store rehashed password of %s: %v", log.Email(p.Email), err)
+		db.upgrades.add(p.Email, string(p.Hash))
+	}
+}
+
 func (db passwordDB) Refresh(ctx context.Context, s connector.Scopes, identity connector.Identity) (connector.Identity, error) {
 	// If the user has been deleted, the refresh token will be rejected.
-	p, err := db.s.GetPassword(identity.Email)
+	p, err := db.s.GetPassword(ctx, identity.Email)
 	if err != nil {
-		if err == storage.ErrNotFound {
+		if errors.Is(err, storage.ErrNotFound) {
 			return connector.Identity{}, errors.New("user not found")
 		}
-		return connector.Identity{}, fmt.Errorf("get password: %v", err)
+		return connector.Identity{}, fmt.Errorf("get password: %w", err)
 	}
 
 	// User removed but a new user with the same email exists.
@@ -423,13 +927,13 @@ type keyCacher struct {
 	keys atomic.Value // Always holds nil or type *storage.Keys.
 }
 
-func (k *keyCacher) GetKeys() (storage.Keys, error) {
+func (k *keyCacher) GetKeys(ctx context.Context) (storage.Keys, error) {
 	keys, ok := k.keys.Load().(*storage.Keys)
 	if ok && keys != nil && k.now().Before(keys.NextRotation) {
 		return *keys, nil
 	}
 
-	storageKeys, err := k.Storage.GetKeys()
+	storageKeys, err := k.Storage.GetKeys(ctx)
 	if err != nil {
 		return storageKeys, err
 	}
@@ -447,10 +951,22 @@ func (s *Server) startGarbageCollection(ctx context.Context, frequency time.Dura
 			case <-ctx.Done():
 				return
 			case <-time.After(frequency):
-				if r, err := s.storage.GarbageCollect(now()); err != nil {
+				if r, err := s.storage.GarbageCollect(ctx, now()); err != nil {
 					s.logger.Errorf("garbage collection failed: %v", err)
-				} else if r.AuthRequests > 0 || r.AuthCodes > 0 {
-					s.logger.Infof("garbage collection run, delete auth requests=%d, auth codes=%d", r.AuthRequests, r.AuthCodes)
+				} else if r.AuthRequests > 0 || r.AuthCodes > 0 || r.RevokedTokens > 0 || r.Sessions > 0 {
+					s.logger.Infof("garbage collection run, delete auth requests=%d, auth codes=%d, revoked tokens=%d, sessions=%d", r.AuthRequests, r.AuthCodes, r.RevokedTokens, r.Sessions)
+				}
+				if n, err := s.collectExpiredClients(ctx, now()); err != nil {
+					s.logger.Errorf("deleting expired clients failed: %v", err)
+				} else if n > 0 {
+					s.logger.Infof("deleted %d expired clients", n)
+				}
+				if s.auditRetention > 0 {
+					if n, err := s.storage.PruneAuditEvents(ctx, now().Add(-s.auditRetention)); err != nil {
+						s.logger.Errorf("pruning audit events failed: %v", err)
+					} else if n > 0 {
+						s.logger.Infof("pruned %d audit events", n)
+					}
 				}
 			}
 		}
@@ -481,6 +997,7 @@ var ConnectorsConfig = map[string]func() ConnectorConfig{
 	"bitbucket-cloud": func() ConnectorConfig { return new(bitbucketcloud.Config) },
 	"openshift":       func() ConnectorConfig { return new(openshift.Config) },
 	"atlassian-crowd": func() ConnectorConfig { return new(atlassiancrowd.Config) },
+	"email":           func() ConnectorConfig { return new(email.Config) },
 	// Keep around for backwards compatibility.
 	"samlExperimental": func() ConnectorConfig { return new(saml.Config) },
 }
@@ -498,29 +1015,43 @@ func openConnector(logger log.Logger, conn storage.Connector) (connector.Connect
 	if len(conn.Config) != 0 {
 		data := []byte(string(conn.Config))
 		if err := json.Unmarshal(data, connConfig); err != nil {
-			return c, fmt.Errorf("parse connector config: %v", err)
+			return c, fmt.Errorf("parse connector config: %w", err)
 		}
 	}
 
 	c, err := connConfig.Open(conn.ID, logger)
 	if err != nil {
-		return c, fmt.Errorf("failed to create connector %s: %v", conn.ID, err)
+		return c, fmt.Errorf("failed to create connector %s: %w", conn.ID, err)
 	}
 
 	return c, nil
 }
 
+// ValidateConnectorID returns an error if the ID is reserved for the tokens
+// dex issues without a connector, such as those of API keys.
+func ValidateConnectorID(id string) error {
+	switch id {
+	case spiffeConnectorID, spiffeIDConnectorID, apiKeyConnectorID, serviceAccountConnectorID, preAuthCodeConnectorID:
+		return fmt.Errorf("connector ID %q is reserved", id)
+	}
+	return nil
+}
+
 // OpenConnector updates server connector map with specified connector object.
 func (s *Server) OpenConnector(conn storage.Connector) (Connector, error) {
+	if err := ValidateConnectorID(conn.ID); err != nil {
+		return Connector{}, err
+	}
+
 	var c connector.Connector
 
 	if conn.Type == LocalConnector {
-		c = newPasswordDB(s.storage)
+		c = newPasswordDB(s.storage, s.passwordHasher, s.passwordUpgrades, s.logger)
 	} else {
 		var err error
 		c, err = openConnector(s.logger, conn)
 		if err != nil {
-			return Connector{}, fmt.Errorf("failed to open connector: %v", err)
+			return Connector{}, fmt.Errorf("failed to open connector: %w", err)
 		}
 	}
 
@@ -537,10 +1068,10 @@ func (s *Server) OpenConnector(conn storage.Connector) (Connector, error) {
 
 // getConnector retrieves the connector object with the given id from the storage
 // and updates the connector list for server if necessary.
-func (s *Server) getConnector(id string) (Connector, error) {
-	storageConnector, err := s.storage.GetConnector(id)
+func (s *Server) getConnector(ctx context.Context, id string) (Connector, error) {
+	storageConnector, err := s.storage.GetConnector(ctx, id)
 	if err != nil {
-		return Connector{}, fmt.Errorf("failed to get connector object from storage: %v", err)
+		return Connector{}, fmt.Errorf("failed to get connector object from storage: %w", err)
 	}
 
 	var conn Connector
@@ -554,7 +1085,7 @@ func (s *Server) getConnector(id string) (Connector, error) {
 		// has been updated in the storage. Need to get latest.
 		conn, err := s.OpenConnector(storageConnector)
 		if err != nil {
-			return Connector{}, fmt.Errorf("failed to open connector: %v", err)
+			return Connector{}, fmt.Errorf("failed to open connector: %w", err)
 		}
 		return conn, nil
 	}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"bytes"
 	"context"
 	"crypto/rsa"
 	"crypto/x509"
@@ -29,6 +30,7 @@ import (
 
 	"github.com/dexidp/dex/connector"
 	"github.com/dexidp/dex/connector/mock"
+	"github.com/dexidp/dex/pkg/secret"
 	"github.com/dexidp/dex/storage"
 	"github.com/dexidp/dex/storage/memory"
 )
@@ -105,7 +107,7 @@ func newTestServer(ctx context.Context, t *testing.T, updateConfig func(c *Confi
 		Name:            "Mock",
 		ResourceVersion: "1",
 	}
-	if err := config.Storage.CreateConnector(connector); err != nil {
+	if err := config.Storage.CreateConnector(ctx, connector); err != nil {
 		t.Fatalf("create connector: %v", err)
 	}
 
@@ -149,10 +151,10 @@ func newTestServerMultipleConnectors(ctx context.Context, t *testing.T, updateCo
 		Name:            "Mock",
 		ResourceVersion: "1",
 	}
-	if err := config.Storage.CreateConnector(connector); err != nil {
+	if err := config.Storage.CreateConnector(ctx, connector); err != nil {
 		t.Fatalf("create connector: %v", err)
 	}
-	if err := config.Storage.CreateConnector(connector2); err != nil {
+	if err := config.Storage.CreateConnector(ctx, connector2); err != nil {
 		t.Fatalf("create connector: %v", err)
 	}
 
@@ -563,7 +565,7 @@ func TestOAuth2CodeFlow(t *testing.T) {
 				Secret:       clientSecret,
 				RedirectURIs: []string{redirectURL},
 			}
-			if err := s.storage.CreateClient(client); err != nil {
+			if err := s.storage.CreateClient(ctx, client); err != nil {
 				t.Fatalf("failed to create client: %v", err)
 			}
 
@@ -663,7 +665,7 @@ func TestOAuth2ImplicitFlow(t *testing.T) {
 		Secret:       "testclientsecret",
 		RedirectURIs: []string{redirectURL},
 	}
-	if err := s.storage.CreateClient(client); err != nil {
+	if err := s.storage.CreateClient(ctx, client); err != nil {
 		t.Fatalf("failed to create client: %v", err)
 	}
 
@@ -821,7 +823,7 @@ func TestCrossClientScopes(t *testing.T) {
 		Secret:       "testclientsecret",
 		RedirectURIs: []string{redirectURL},
 	}
-	if err := s.storage.CreateClient(client); err != nil {
+	if err := s.storage.CreateClient(ctx, client); err != nil {
 		t.Fatalf("failed to create client: %v", err)
 	}
 
@@ -831,7 +833,7 @@ func TestCrossClientScopes(t *testing.T) {
 		TrustedPeers: []string{"testclient"},
 	}
 
-	if err := s.storage.CreateClient(peer); err != nil {
+	if err := s.storage.CreateClient(ctx, peer); err != nil {
 		t.Fatalf("failed to create client: %v", err)
 	}
 
@@ -944,7 +946,7 @@ func TestCrossClientScopesWithAzpInAudienceByDefault(t *testing.T) {
 		Secret:       "testclientsecret",
 		RedirectURIs: []string{redirectURL},
 	}
-	if err := s.storage.CreateClient(client); err != nil {
+	if err := s.storage.CreateClient(ctx, client); err != nil {
 		t.Fatalf("failed to create client: %v", err)
 	}
 
@@ -954,7 +956,7 @@ func TestCrossClientScopesWithAzpInAudienceByDefault(t *testing.T) {
 		TrustedPeers: []string{"testclient"},
 	}
 
-	if err := s.storage.CreateClient(peer); err != nil {
+	if err := s.storage.CreateClient(ctx, peer); err != nil {
 		t.Fatalf("failed to create client: %v", err)
 	}
 
@@ -984,8 +986,9 @@ func TestCrossClientScopesWithAzpInAudienceByDefault(t *testing.T) {
 }
 
 func TestPasswordDB(t *testing.T) {
+	ctx := context.Background()
 	s := memory.New(logger)
-	conn := newPasswordDB(s)
+	conn := newPasswordDB(s, nil, newFailedUpgrades(), logger)
 
 	pw := "hi"
 
@@ -994,7 +997,7 @@ func TestPasswordDB(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	s.CreatePassword(storage.Password{
+	s.CreatePassword(ctx, storage.Password{
 		Email:    "jane@example.com",
 		Username: "jane",
 		UserID:   "foobar",
@@ -1066,9 +1069,104 @@ func TestPasswordDB(t *testing.T) {
 	}
 }
 
+func TestPasswordDBRehash(t *testing.T) {
+	ctx := context.Background()
+	s := memory.New(logger)
+	hasher, err := secret.NewArgon2id(secret.Argon2idParams{Memory: minArgon2idMemory, Iterations: 1, Parallelism: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := newPasswordDB(s, hasher, newFailedUpgrades(), logger)
+
+	h, err := bcrypt.GenerateFromPassword([]byte("hi"), bcrypt.DefaultCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.CreatePassword(ctx, storage.Password{
+		Email:    "jane@example.com",
+		Username: "jane",
+		UserID:   "foobar",
+		Hash:     h,
+	})
+
+	if _, valid, err := conn.Login(ctx, connector.Scopes{}, "jane@example.com", "wrong"); err != nil || valid {
+		t.Fatalf("expected invalid password, got %t, %v", valid, err)
+	}
+	p, _ := s.GetPassword(ctx, "jane@example.com")
+	if !bytes.Equal(p.Hash, h) {
+		t.Fatal("expected failed login not to rehash the password")
+	}
+
+	// Log in twice, the second time against the rehashed password.
+	for i := 0; i < 2; i++ {
+		if _, valid, err := conn.Login(ctx, connector.Scopes{}, "jane@example.com", "hi"); err != nil || !valid {
+			t.Fatalf("%d: expected valid password, got %t, %v", i, valid, err)
+		}
+		p, _ = s.GetPassword(ctx, "jane@example.com")
+		if hasher.NeedsRehash(string(p.Hash)) {
+			t.Fatalf("%d: expected password to be rehashed with argon2id, got %q", i, p.Hash)
+		}
+	}
+}
+
+func TestPasswordDBRehashStatic(t *testing.T) {
+	ctx := context.Background()
+	argon2id, err := secret.NewArgon2id(secret.Argon2idParams{Memory: minArgon2idMemory, Iterations: 1, Parallelism: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hasher := &countingHasher{Hasher: argon2id}
+
+	h, err := bcrypt.GenerateFromPassword([]byte("hi"), bcrypt.DefaultCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := storage.WithStaticPasswords(memory.New(logger), []storage.Password{{
+		Email:    "jane@example.com",
+		Username: "jane",
+		UserID:   "foobar",
+		Hash:     h,
+	}}, logger)
+	conn := newPasswordDB(s, hasher, newFailedUpgrades(), logger)
+
+	for i := 0; i < 3; i++ {
+		if _, valid, err := conn.Login(ctx, connector.Scopes{}, "jane@example.com", "hi"); err != nil || !valid {
+			t.Fatalf("%d: expected valid password, got %t, %v", i, valid, err)
+		}
+	}
+	if hasher.hashed != 1 {
+		t.Errorf("expected the static password to be rehashed once, got %d", hasher.hashed)
+	}
+}
+
+func TestPasswordHasherBounds(t *testing.T) {
+	for _, tc := range []struct {
+		hasher  func() (secret.Hasher, error)
+		wantErr bool
+	}{
+		{func() (secret.Hasher, error) { return secret.NewBcrypt(bcrypt.DefaultCost) }, false},
+		{func() (secret.Hasher, error) { return secret.NewBcrypt(bcrypt.MinCost) }, true},
+		{func() (secret.Hasher, error) {
+			return secret.NewArgon2id(secret.Argon2idParams{Memory: 1024, Iterations: 1, Parallelism: 1})
+		}, true},
+	} {
+		hasher, err := tc.hasher()
+		if err != nil {
+			t.Fatal(err)
+		}
+		hash, err := hasher.Hash([]byte("hi"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := checkCost([]byte(hash)); (err != nil) != tc.wantErr {
+			t.Errorf("%s: expected error %t, got %v", hash, tc.wantErr, err)
+		}
+	}
+}
+
 func TestPasswordDBUsernamePrompt(t *testing.T) {
 	s := memory.New(logger)
-	conn := newPasswordDB(s)
+	conn := newPasswordDB(s, nil, newFailedUpgrades(), logger)
 
 	expected := "Email Address"
 	if actual := conn.Prompt(); actual != expected {
@@ -1081,12 +1179,13 @@ type storageWithKeysTrigger struct {
 	f func()
 }
 
-func (s storageWithKeysTrigger) GetKeys() (storage.Keys, error) {
+func (s storageWithKeysTrigger) GetKeys(ctx context.Context) (storage.Keys, error) {
 	s.f()
-	return s.Storage.GetKeys()
+	return s.Storage.GetKeys(ctx)
 }
 
 func TestKeyCacher(t *testing.T) {
+	ctx := context.Background()
 	tNow := time.Now()
 	now := func() time.Time { return tNow }
 
@@ -1102,7 +1201,7 @@ func TestKeyCacher(t *testing.T) {
 		},
 		{
 			before: func() {
-				s.UpdateKeys(func(old storage.Keys) (storage.Keys, error) {
+				s.UpdateKeys(ctx, func(old storage.Keys) (storage.Keys, error) {
 					old.NextRotation = tNow.Add(time.Minute)
 					return old, nil
 				})
@@ -1122,7 +1221,7 @@ func TestKeyCacher(t *testing.T) {
 		{
 			before: func() {
 				tNow = tNow.Add(time.Hour)
-				s.UpdateKeys(func(old storage.Keys) (storage.Keys, error) {
+				s.UpdateKeys(ctx, func(old storage.Keys) (storage.Keys, error) {
 					old.NextRotation = tNow.Add(time.Minute)
 					return old, nil
 				})
@@ -1140,7 +1239,7 @@ func TestKeyCacher(t *testing.T) {
 	for i, tc := range tests {
 		gotCall = false
 		tc.before()
-		s.GetKeys()
+		s.GetKeys(ctx)
 		if gotCall != tc.wantCallToStorage {
 			t.Errorf("case %d: expected call to storage=%t got call to storage=%t", i, tc.wantCallToStorage, gotCall)
 		}
@@ -1218,7 +1317,7 @@ func TestRefreshTokenFlow(t *testing.T) {
 		Secret:       "testclientsecret",
 		RedirectURIs: []string{redirectURL},
 	}
-	if err := s.storage.CreateClient(client); err != nil {
+	if err := s.storage.CreateClient(ctx, client); err != nil {
 		t.Fatalf("failed to create client: %v", err)
 	}
 
@@ -1253,3 +1352,20 @@ func TestRefreshTokenFlow(t *testing.T) {
 		t.Errorf("Token refreshed with invalid refresh token, error expected.")
 	}
 }
+
+func TestOpenConnectorReservedID(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, nil)
+	defer httpServer.Close()
+
+	for _, id := range []string{spiffeConnectorID, spiffeIDConnectorID, apiKeyConnectorID, serviceAccountConnectorID, preAuthCodeConnectorID} {
+		if _, err := s.OpenConnector(storage.Connector{ID: id, Type: "mockCallback", Name: "Mock"}); err == nil {
+			t.Errorf("expected connector ID %q to be rejected", id)
+		}
+	}
+	if _, err := s.OpenConnector(storage.Connector{ID: "other", Type: "mockCallback", Name: "Mock"}); err != nil {
+		t.Errorf("open connector: %v", err)
+	}
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"github.com/dexidp/dex/pkg/audit"
+	"github.com/dexidp/dex/storage"
+)
+
+// serviceAccountConnectorID is the connector ID of tokens issued to service
+// accounts.
+const serviceAccountConnectorID = "service_account"
+
+const (
+	// maxAssertionLifetime bounds how far in the future assertions may
+	// expire, which limits how long a leaked assertion can be replayed.
+	maxAssertionLifetime = time.Hour
+	// assertionLeeway is the clock skew tolerated validating assertions.
+	assertionLeeway = time.Minute
+)
+
+// verifyAssertion checks that the assertion is signed by one of the keys of
+// the service account it was issued by, and that it's meant for dex and
+// hasn't expired. It returns the service account.
+func (s *Server) verifyAssertion(r *http.Request, assertion string) (storage.ServiceAccount, error) {
+	tok, err := jwt.ParseSigned(assertion)
+	if err != nil {
+		return storage.ServiceAccount{}, fmt.Errorf("malformed assertion: %w", err)
+	}
+	var unverified jwt.Claims
+	if err := tok.UnsafeClaimsWithoutVerification(&unverified); err != nil {
+		return storage.ServiceAccount{}, fmt.Errorf("malformed assertion: %w", err)
+	}
+	if unverified.Issuer == "" || unverified.Issuer != unverified.Subject {
+		return storage.ServiceAccount{}, errors.New("assertion issuer and subject must be the service account ID")
+	}
+	account, err := s.storage.GetServiceAccount(r.Context(), unverified.Subject)
+	if err != nil {
+		return storage.ServiceAccount{}, err
+	}
+
+	var kid string
+	if len(tok.Headers) > 0 {
+		kid = tok.Headers[0].KeyID
+	}
+	var claims jwt.Claims
+	verified := false
+	for _, key := range account.PublicKeys {
+		if kid != "" && key.KeyID != "" && key.KeyID != kid {
+			continue
+		}
+		if err := tok.Claims(key.Key, &claims); err == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return storage.ServiceAccount{}, errors.New("assertion isn't signed by a key of the service account")
+	}
+
+	now := s.now()
+	if err := claims.ValidateWithLeeway(jwt.Expected{
+		Issuer:  account.ID,
+		Subject: account.ID,
+		Time:    now,
+	}, assertionLeeway); err != nil {
+		return storage.ServiceAccount{}, fmt.Errorf("invalid assertion: %w", err)
+	}
+	if claims.Expiry == nil {
+		return storage.ServiceAccount{}, errors.New("assertion must expire")
+	}
+	if claims.Expiry.Time().After(now.Add(maxAssertionLifetime + assertionLeeway)) {
+		return storage.ServiceAccount{}, fmt.Errorf("assertion must expire within %s", maxAssertionLifetime)
+	}
+	if !claims.Audience.Contains(s.absURL("/token")) && !claims.Audience.Contains(s.issuerURL.String()) {
+		return storage.ServiceAccount{}, errors.New("assertion audience must be the token endpoint or issuer")
+	}
+	return account, nil
+}
+
+// serviceAccountScopes returns the requested scopes, which default to
+// "openid". Service accounts can't request refresh tokens.
+func (s *Server) serviceAccountScopes(requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		return []string{scopeOpenID}, nil
+	}
+	for _, scope := range requested {
+		switch scope {
+		case scopeOpenID, scopeEmail, scopeProfile, scopeGroups:
+		default:
+			if _, ok := s.customScopes[scope]; !ok || len(s.scopeAudiences[scope]) > 0 {
+				return nil, fmt.Errorf("scope %q can't be requested by service accounts", scope)
+			}
+		}
+	}
+	return requested, nil
+}
+
+// handleServiceAccountGrant exchanges a JWT assertion of a service account
+// for an ID token and access token of one of its clients, following RFC
+// 7523. It returns the ID of the client once the service account has been
+// authenticated.
+func (s *Server) handleServiceAccountGrant(w http.ResponseWriter, r *http.Request) string {
+	ctx := r.Context()
+	account, err := s.verifyAssertion(r, r.PostFormValue("assertion"))
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			s.logger.Debugf("service account assertion rejected: %v", err)
+		}
+		s.delayFailure(ctx)
+		s.tokenErrHelper(w, errInvalidGrant, "Invalid assertion.", http.StatusBadRequest)
+		return ""
+	}
+
+	clientID := r.PostFormValue("client_id")
+	if !contains(account.Clients, clientID) {
+		s.tokenErrHelper(w, errUnauthorizedClient, "Service account can't request tokens for this client.", http.StatusBadRequest)
+		return ""
+	}
+	client, err := s.getClient(ctx, clientID)
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			s.logger.Errorf("failed to get client: %v", err)
+			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		} else {
+			s.tokenErrHelper(w, errInvalidClient, "Invalid client.", http.StatusUnauthorized)
+		}
+		return ""
+	}
+	if !s.checkClientNetwork(w, r, client) {
+		return client.ID
+	}
+
+	scopes, err := s.serviceAccountScopes(strings.Fields(r.PostFormValue("scope")))
+	if err != nil {
+		s.tokenErrHelper(w, errInvalidScope, err.Error(), http.StatusBadRequest)
+		return client.ID
+	}
+	claims := storage.Claims{
+		UserID:   account.ID,
+		Username: account.Name,
+		Groups:   account.Groups,
+	}
+	if msg, ok := s.checkAccessWindows(client.ID, claims); !ok {
+		s.tokenErrHelper(w, errAccessDenied, msg, http.StatusForbidden)
+		return client.ID
+	}
+	scopes, msg, ok := s.authorize(r, grantTypeJWTBearer, client.ID, serviceAccountConnectorID, claims, scopes)
+	if !ok {
+		s.tokenErrHelper(w, errAccessDenied, msg, http.StatusForbidden)
+		return client.ID
+	}
+
+	accessToken, err := s.newAccessToken(ctx, client.ID, claims, scopes, "", serviceAccountConnectorID)
+	if err != nil {
+		s.logger.Errorf("failed to create new access token: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return client.ID
+	}
+	idToken, expiry, err := s.newIDToken(ctx, client.ID, claims, scopes, "", accessToken, serviceAccountConnectorID)
+	if err != nil {
+		s.logger.Errorf("failed to create ID token: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return client.ID
+	}
+
+	s.emitAudit(ctx, audit.Event{
+		Type:        audit.EventServiceAccountToken,
+		Severity:    audit.SeverityInfo,
+		ClientID:    client.ID,
+		Subject:     subjectFor(account.ID, serviceAccountConnectorID),
+		ConnectorID: serviceAccountConnectorID,
+		SourceIPs:   []string{remoteIP(r)},
+	})
+	s.writeAccessToken(w, idToken, accessToken, "", expiry)
+	return client.ID
+}
+
+// parseServiceAccountKeys parses JSON web keys of a service account. Keys
+// must be public keys which can verify signatures.
+func parseServiceAccountKeys(keys []string) ([]jose.JSONWebKey, error) {
+	var parsed []jose.JSONWebKey
+	for i, data := range keys {
+		var key jose.JSONWebKey
+		if err := key.UnmarshalJSON([]byte(data)); err != nil {
+			return nil, fmt.Errorf("public key %d: %w", i, err)
+		}
+		if !key.Valid() || !key.IsPublic() {
+			return nil, fmt.Errorf("public key %d: must be a valid public key", i)
+		}
+		parsed = append(parsed, key)
+	}
+	return parsed, nil
+}
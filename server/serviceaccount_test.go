@@ -0,0 +1,219 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"github.com/dexidp/dex/api/v2"
+	"github.com/dexidp/dex/pkg/audit"
+	"github.com/dexidp/dex/storage"
+	"github.com/dexidp/dex/storage/memory"
+)
+
+func newServiceAccountKey(t *testing.T, kid string) (*ecdsa.PrivateKey, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := jose.JSONWebKey{Key: key.Public(), KeyID: kid, Algorithm: string(jose.ES256), Use: "sig"}
+	data, err := pub.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, string(data)
+}
+
+func signAssertion(t *testing.T, key *ecdsa.PrivateKey, kid string, claims jwt.Claims) string {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: key}, (&jose.SignerOptions{}).WithHeader("kid", kid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertion, err := jwt.Signed(signer).Claims(claims).CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return assertion
+}
+
+func TestServiceAccountGrant(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sink := new(recordingSink)
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.AllowServiceAccounts = true
+		c.AuditSink = sink
+	})
+	defer httpServer.Close()
+
+	for _, id := range []string{"storage-api", "billing"} {
+		if err := s.storage.CreateClient(ctx, storage.Client{ID: id, Secret: id + "-secret"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	key, pub := newServiceAccountKey(t, "k1")
+	otherKey, _ := newServiceAccountKey(t, "k1")
+	a := NewAPI(s.storage, logger, nil, nil, nil, 0)
+	resp, err := a.CreateServiceAccount(ctx, &api.CreateServiceAccountReq{ServiceAccount: &api.ServiceAccount{
+		Id:         "backup",
+		Name:       "Nightly backup",
+		Groups:     []string{"operators"},
+		Clients:    []string{"storage-api"},
+		PublicKeys: []string{pub},
+	}})
+	if err != nil || resp.AlreadyExists {
+		t.Fatalf("create service account: %v %+v", err, resp)
+	}
+
+	now := time.Now()
+	valid := jwt.Claims{
+		Issuer:   "backup",
+		Subject:  "backup",
+		Audience: jwt.Audience{s.absURL("/token")},
+		Expiry:   jwt.NewNumericDate(now.Add(5 * time.Minute)),
+		IssuedAt: jwt.NewNumericDate(now),
+	}
+	exchange := func(assertion, clientID string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, tokenRequest(storage.Client{}, "10.0.0.1:1234", url.Values{
+			"grant_type": {grantTypeJWTBearer},
+			"assertion":  {assertion},
+			"client_id":  {clientID},
+			"scope":      {"openid groups"},
+		}))
+		return rr
+	}
+
+	rr := exchange(signAssertion(t, key, "k1", valid), "storage-api")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected assertion to be exchanged, got %d: %s", rr.Code, rr.Body)
+	}
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &tokens); err != nil {
+		t.Fatal(err)
+	}
+	jws, err := jose.ParseSigned(tokens.IDToken)
+	if err != nil {
+		t.Fatalf("failed to parse id token: %v", err)
+	}
+	var claims struct {
+		Aud    string   `json:"aud"`
+		Groups []string `json:"groups"`
+	}
+	if err := json.Unmarshal(jws.UnsafePayloadWithoutVerification(), &claims); err != nil {
+		t.Fatal(err)
+	}
+	if claims.Aud != "storage-api" || len(claims.Groups) != 1 || claims.Groups[0] != "operators" {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+	if len(sink.events) != 1 || sink.events[0].Type != audit.EventServiceAccountToken {
+		t.Errorf("expected a service_account_token audit event, got %+v", sink.events)
+	}
+
+	wrongAudience := valid
+	wrongAudience.Audience = jwt.Audience{"https://example.com/token"}
+	expired := valid
+	expired.Expiry = jwt.NewNumericDate(now.Add(-5 * time.Minute))
+	longLived := valid
+	longLived.Expiry = jwt.NewNumericDate(now.Add(24 * time.Hour))
+	otherAccount := valid
+	otherAccount.Issuer = "deploy"
+	otherAccount.Subject = "deploy"
+
+	tests := []struct {
+		name      string
+		assertion string
+		clientID  string
+		want      int
+	}{
+		{"issuer audience", signAssertion(t, key, "k1", jwt.Claims{
+			Issuer: "backup", Subject: "backup", Audience: jwt.Audience{s.issuerURL.String()}, Expiry: valid.Expiry,
+		}), "storage-api", http.StatusOK},
+		{"other client", signAssertion(t, key, "k1", valid), "billing", http.StatusBadRequest},
+		{"other key", signAssertion(t, otherKey, "k1", valid), "storage-api", http.StatusBadRequest},
+		{"wrong audience", signAssertion(t, key, "k1", wrongAudience), "storage-api", http.StatusBadRequest},
+		{"expired", signAssertion(t, key, "k1", expired), "storage-api", http.StatusBadRequest},
+		{"long lived", signAssertion(t, key, "k1", longLived), "storage-api", http.StatusBadRequest},
+		{"unknown account", signAssertion(t, key, "k1", otherAccount), "storage-api", http.StatusBadRequest},
+		{"malformed", "not-a-jwt", "storage-api", http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		if rr := exchange(tc.assertion, tc.clientID); rr.Code != tc.want {
+			t.Errorf("%s: expected status %d, got %d: %s", tc.name, tc.want, rr.Code, rr.Body)
+		}
+	}
+}
+
+func TestServiceAccountsAPI(t *testing.T) {
+	ctx := context.Background()
+	s := memory.New(logger)
+	a := NewAPI(s, logger, nil, nil, nil, 0)
+
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	private, err := jose.JSONWebKey{Key: privateKey}.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.CreateServiceAccount(ctx, &api.CreateServiceAccountReq{ServiceAccount: &api.ServiceAccount{
+		Id:         "backup",
+		PublicKeys: []string{string(private)},
+	}}); err == nil {
+		t.Errorf("expected private key to be rejected")
+	}
+
+	_, k1 := newServiceAccountKey(t, "k1")
+	_, k2 := newServiceAccountKey(t, "k2")
+	req := &api.CreateServiceAccountReq{ServiceAccount: &api.ServiceAccount{
+		Id:         "backup",
+		Clients:    []string{"storage-api"},
+		PublicKeys: []string{k1},
+	}}
+	if resp, err := a.CreateServiceAccount(ctx, req); err != nil || resp.AlreadyExists {
+		t.Fatalf("create service account: %v %+v", err, resp)
+	}
+	if resp, err := a.CreateServiceAccount(ctx, req); err != nil || !resp.AlreadyExists {
+		t.Errorf("expected duplicate service account to exist: %v %+v", err, resp)
+	}
+
+	if resp, err := a.UpdateServiceAccount(ctx, &api.UpdateServiceAccountReq{
+		Id:         "backup",
+		Groups:     []string{"operators"},
+		PublicKeys: []string{k1, k2},
+	}); err != nil || resp.NotFound {
+		t.Fatalf("update service account: %v %+v", err, resp)
+	}
+	list, err := a.ListServiceAccounts(ctx, &api.ListServiceAccountsReq{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.ServiceAccounts) != 1 {
+		t.Fatalf("expected one service account, got %+v", list.ServiceAccounts)
+	}
+	got := list.ServiceAccounts[0]
+	if len(got.Groups) != 1 || len(got.Clients) != 1 || len(got.PublicKeys) != 2 {
+		t.Errorf("service account not updated: %+v", got)
+	}
+
+	if resp, err := a.DeleteServiceAccount(ctx, &api.DeleteServiceAccountReq{Id: "backup"}); err != nil || resp.NotFound {
+		t.Fatalf("delete service account: %v %+v", err, resp)
+	}
+	if resp, err := a.UpdateServiceAccount(ctx, &api.UpdateServiceAccountReq{Id: "backup", Name: "x"}); err != nil || !resp.NotFound {
+		t.Errorf("expected deleted service account to be not found: %v %+v", err, resp)
+	}
+}
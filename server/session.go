@@ -0,0 +1,307 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	oidc "github.com/coreos/go-oidc"
+
+	"github.com/dexidp/dex/connector"
+	"github.com/dexidp/dex/pkg/audit"
+	"github.com/dexidp/dex/storage"
+)
+
+// sessionCookieName is the cookie holding the ID of the user's session.
+const sessionCookieName = "dex_session"
+
+// startSession remembers the identity a user logged in with, so later
+// authorization requests from the same browser skip the connector. Failing
+// to start a session doesn't fail the login.
+func (s *Server) startSession(ctx context.Context, w http.ResponseWriter, clientID, connID string, identity connector.Identity) {
+	if s.sessionsValidFor == 0 {
+		return
+	}
+	now := s.now()
+	session := storage.Session{
+		ID:          storage.NewID(),
+		ConnectorID: connID,
+		Claims: storage.Claims{
+			UserID:            identity.UserID,
+			Username:          identity.Username,
+			PreferredUsername: identity.PreferredUsername,
+			Email:             identity.Email,
+			EmailVerified:     identity.EmailVerified,
+			Groups:            identity.Groups,
+		},
+		ConnectorData: identity.ConnectorData,
+		ClientIDs:     []string{clientID},
+		CreatedAt:     now,
+		Expiry:        now.Add(s.sessionsValidFor),
+	}
+	if err := s.storage.CreateSession(ctx, session); err != nil {
+		s.logger.Errorf("failed to create session: %v", err)
+		return
+	}
+	http.SetCookie(w, s.sessionCookie(session.ID, int(s.sessionsValidFor.Seconds())))
+}
+
+// sessionCookie returns the session cookie, scoped to the issuer's path. A
+// negative maxAge deletes the cookie.
+func (s *Server) sessionCookie(value string, maxAge int) *http.Cookie {
+	cookiePath := s.issuerURL.Path
+	if cookiePath == "" {
+		cookiePath = "/"
+	}
+	return &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    value,
+		Path:     cookiePath,
+		MaxAge:   maxAge,
+		Secure:   s.issuerURL.Scheme == "https",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// currentSession returns the unexpired session of the request's cookie.
+func (s *Server) currentSession(r *http.Request) (storage.Session, bool) {
+	if s.sessionsValidFor == 0 {
+		return storage.Session{}, false
+	}
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil || cookie.Value == "" {
+		return storage.Session{}, false
+	}
+	session, err := s.storage.GetSession(r.Context(), cookie.Value)
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			s.logger.Errorf("failed to get session: %v", err)
+		}
+		return storage.Session{}, false
+	}
+	if s.now().After(session.Expiry) {
+		return storage.Session{}, false
+	}
+	return session, true
+}
+
+// resumeSession logs the user in to the auth request with the identity of
+// their session, unless the client asked for a different connector or for
+// the user to log in again. It reports whether the request was handled.
+func (s *Server) resumeSession(w http.ResponseWriter, r *http.Request, authReq storage.AuthRequest) bool {
+	ctx := r.Context()
+	if contains(strings.Fields(r.FormValue("prompt")), "login") {
+		return false
+	}
+	session, ok := s.currentSession(r)
+	if !ok || (authReq.ConnectorID != "" && authReq.ConnectorID != session.ConnectorID) {
+		return false
+	}
+	conn, err := s.getConnector(ctx, session.ConnectorID)
+	if err != nil {
+		// The connector was removed since the user logged in.
+		return false
+	}
+
+	if err := s.storage.UpdateAuthRequest(ctx, authReq.ID, func(a storage.AuthRequest) (storage.AuthRequest, error) {
+		a.ConnectorID = session.ConnectorID
+		return a, nil
+	}); err != nil {
+		s.logger.Errorf("Failed to set connector ID on auth request: %v", err)
+		s.renderError(r, w, http.StatusInternalServerError, "Database error.")
+		return true
+	}
+	authReq.ConnectorID = session.ConnectorID
+
+	// Remember the client so it's notified when the session ends.
+	if !contains(session.ClientIDs, authReq.ClientID) {
+		if err := s.storage.UpdateSession(ctx, session.ID, func(old storage.Session) (storage.Session, error) {
+			if !contains(old.ClientIDs, authReq.ClientID) {
+				old.ClientIDs = append(old.ClientIDs, authReq.ClientID)
+			}
+			return old, nil
+		}); err != nil {
+			s.logger.Errorf("Failed to add client to session: %v", err)
+		}
+	}
+
+	identity := connector.Identity{
+		UserID:            session.Claims.UserID,
+		Username:          session.Claims.Username,
+		PreferredUsername: session.Claims.PreferredUsername,
+		Email:             session.Claims.Email,
+		EmailVerified:     session.Claims.EmailVerified,
+		Groups:            session.Claims.Groups,
+		ConnectorData:     session.ConnectorData,
+	}
+	redirectURL, err := s.finalizeLogin(ctx, identity, authReq, conn.Connector)
+	if err != nil {
+		s.renderLoginError(r, w, err)
+		return true
+	}
+	http.Redirect(w, r, redirectURL, http.StatusFound)
+	return true
+}
+
+// handleLogout ends the user's session. It implements OpenID Connect
+// RP-Initiated Logout: clients may name the user with an ID token and ask to
+// be redirected to one of their redirect URIs afterwards.
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		s.renderError(r, w, http.StatusBadRequest, "Unsupported request method.")
+		return
+	}
+
+	clientID := r.FormValue("client_id")
+	if hint := r.FormValue("id_token_hint"); hint != "" {
+		// The ID token may have expired while the user was logged in.
+		verifier := oidc.NewVerifier(s.issuerURL.String(), s.verificationKeys(), &oidc.Config{
+			SkipClientIDCheck: true,
+			SkipExpiryCheck:   true,
+		})
+		token, err := verifier.Verify(ctx, hint)
+		if err != nil || len(token.Audience) == 0 || (clientID != "" && !contains(token.Audience, clientID)) {
+			s.renderError(r, w, http.StatusBadRequest, "Invalid ID token hint.")
+			return
+		}
+		if clientID == "" {
+			clientID = token.Audience[0]
+		}
+	}
+
+	redirectURI := r.FormValue("post_logout_redirect_uri")
+	if redirectURI != "" {
+		if clientID == "" {
+			s.renderError(r, w, http.StatusBadRequest, "Logout redirect requires a client ID or an ID token hint.")
+			return
+		}
+		client, err := s.getClient(ctx, clientID)
+		if err != nil {
+			if !errors.Is(err, storage.ErrNotFound) {
+				s.logger.Errorf("Failed to get client %q: %v", clientID, err)
+			}
+			s.renderError(r, w, http.StatusBadRequest, "Invalid client.")
+			return
+		}
+		if !contains(client.RedirectURIs, redirectURI) {
+			s.renderError(r, w, http.StatusBadRequest, "Unregistered logout redirect URI.")
+			return
+		}
+	}
+
+	if session, ok := s.currentSession(r); ok {
+		if err := s.storage.DeleteSession(ctx, session.ID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			s.logger.Errorf("Failed to delete session: %v", err)
+			s.renderError(r, w, http.StatusInternalServerError, "Database error.")
+			return
+		}
+		s.emitAudit(ctx, audit.Event{
+			Type:        audit.EventLogout,
+			Severity:    audit.SeverityInfo,
+			ClientID:    clientID,
+			Subject:     subjectFor(session.Claims.UserID, session.ConnectorID),
+			ConnectorID: session.ConnectorID,
+			SourceIPs:   []string{remoteIP(r)},
+		})
+		s.sendBackchannelLogout(session)
+	}
+	http.SetCookie(w, s.sessionCookie("", -1))
+
+	if redirectURI == "" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("You have been logged out.\n"))
+		return
+	}
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		s.renderError(r, w, http.StatusBadRequest, "Invalid logout redirect URI.")
+		return
+	}
+	if state := r.FormValue("state"); state != "" {
+		q := u.Query()
+		q.Set("state", state)
+		u.RawQuery = q.Encode()
+	}
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+// adminSession is a session as listed to administrators. The connector data
+// may hold upstream credentials and is left out.
+type adminSession struct {
+	ID          string    `json:"id"`
+	ConnectorID string    `json:"connectorID"`
+	UserID      string    `json:"userID"`
+	Username    string    `json:"username,omitempty"`
+	Email       string    `json:"email,omitempty"`
+	ClientIDs   []string  `json:"clientIDs,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	Expiry      time.Time `json:"expiry"`
+}
+
+// handleAdminSessions lists the users' sessions on GET /sessions and revokes
+// one on DELETE /sessions/<id>, notifying the clients it was used with.
+func (s *Server) handleAdminSessions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := strings.TrimPrefix(r.URL.Path, "/sessions/")
+	switch {
+	case r.URL.Path == "/sessions" && r.Method == http.MethodGet:
+		if s.shed(w, endpointGroupAdmin) {
+			http.Error(w, "The server is overloaded, retry later.", http.StatusServiceUnavailable)
+			return
+		}
+		sessions, err := s.storage.ListSessions(ctx)
+		if err != nil {
+			s.logger.Errorf("Failed to list sessions: %v", err)
+			http.Error(w, "Database error.", http.StatusInternalServerError)
+			return
+		}
+		list := []adminSession{}
+		for _, session := range sessions {
+			list = append(list, adminSession{
+				ID:          session.ID,
+				ConnectorID: session.ConnectorID,
+				UserID:      session.Claims.UserID,
+				Username:    session.Claims.Username,
+				Email:       session.Claims.Email,
+				ClientIDs:   session.ClientIDs,
+				CreatedAt:   session.CreatedAt,
+				Expiry:      session.Expiry,
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+	case id != r.URL.Path && id != "" && r.Method == http.MethodDelete:
+		session, err := s.storage.GetSession(ctx, id)
+		if err == nil {
+			err = s.storage.DeleteSession(ctx, id)
+		}
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				http.NotFound(w, r)
+				return
+			}
+			s.logger.Errorf("Failed to revoke session: %v", err)
+			http.Error(w, "Database error.", http.StatusInternalServerError)
+			return
+		}
+		s.emitAudit(ctx, audit.Event{
+			Type:        audit.EventLogout,
+			Severity:    audit.SeverityInfo,
+			Subject:     subjectFor(session.Claims.UserID, session.ConnectorID),
+			ConnectorID: session.ConnectorID,
+			SourceIPs:   []string{remoteIP(r)},
+			Message:     "session revoked by an administrator",
+			Revoked:     true,
+		})
+		s.sendBackchannelLogout(session)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Unsupported request.", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dexidp/dex/pkg/audit"
+	"github.com/dexidp/dex/storage"
+)
+
+func TestSessions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sink := new(recordingSink)
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.AuditSink = sink
+		c.SessionsValidFor = time.Hour
+	})
+	defer httpServer.Close()
+
+	client := storage.Client{ID: "app", Secret: "secret", RedirectURIs: []string{"https://app.example.com/callback"}}
+	if err := s.storage.CreateClient(ctx, client); err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+
+	serve := func(target string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+		return rr
+	}
+	authorize := func(extra string, cookies ...*http.Cookie) string {
+		rr := serve("/auth?client_id=app&response_type=code&scope=openid&redirect_uri="+url.QueryEscape(client.RedirectURIs[0])+extra, cookies...)
+		if rr.Code != http.StatusFound {
+			t.Fatalf("expected redirect from /auth, got %d: %s", rr.Code, rr.Body)
+		}
+		return rr.Header().Get("Location")
+	}
+
+	// Log in through the connector.
+	location := authorize("")
+	if !strings.HasPrefix(location, "/auth/mock?") {
+		t.Fatalf("expected redirect to connector, got %q", location)
+	}
+	u, _ := url.Parse(location)
+	authReqID := u.Query().Get("req")
+	serve(location)
+	rr := serve("/callback?state=" + authReqID)
+	var cookie *http.Cookie
+	for _, c := range rr.Result().Cookies() {
+		if c.Name == sessionCookieName {
+			cookie = c
+		}
+	}
+	if cookie == nil {
+		t.Fatalf("expected login to set a session cookie")
+	}
+
+	if location := authorize("", cookie); !strings.HasPrefix(location, "/approval?") {
+		t.Errorf("expected session to skip the connector, got %q", location)
+	}
+	if location := authorize("&prompt=login", cookie); !strings.HasPrefix(location, "/auth/mock?") {
+		t.Errorf("expected prompt=login to send the user to the connector, got %q", location)
+	}
+
+	idToken, _, err := s.newIDToken(ctx, client.ID, storage.Claims{UserID: "0-385-28089-0"}, []string{"openid"}, "", "", "mock")
+	if err != nil {
+		t.Fatalf("create id token: %v", err)
+	}
+	logout := "/logout?id_token_hint=" + idToken + "&state=abc&post_logout_redirect_uri="
+	if rr := serve(logout+url.QueryEscape("https://evil.example.com/"), cookie); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected unregistered logout redirect to be rejected, got %d", rr.Code)
+	}
+	rr = serve(logout+url.QueryEscape(client.RedirectURIs[0]), cookie)
+	if want := client.RedirectURIs[0] + "?state=abc"; rr.Code != http.StatusFound || rr.Header().Get("Location") != want {
+		t.Errorf("expected redirect to %q, got %d %q", want, rr.Code, rr.Header().Get("Location"))
+	}
+	if c := rr.Result().Cookies(); len(c) != 1 || c[0].Name != sessionCookieName || c[0].MaxAge >= 0 {
+		t.Errorf("expected session cookie to be deleted, got %v", c)
+	}
+	if _, err := s.storage.GetSession(ctx, cookie.Value); err != storage.ErrNotFound {
+		t.Errorf("expected session to be deleted, got %v", err)
+	}
+	if last := sink.events[len(sink.events)-1]; last.Type != audit.EventLogout || last.ClientID != client.ID {
+		t.Errorf("expected logout to be reported, got %+v", last)
+	}
+
+	if location := authorize("", cookie); !strings.HasPrefix(location, "/auth/mock?") {
+		t.Errorf("expected logged out user to be sent to the connector, got %q", location)
+	}
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/dexidp/dex/pkg/log"
+	"github.com/dexidp/dex/storage"
+)
+
+// ShadowPolicies are candidate policies evaluated alongside the active ones
+// without affecting any response. Each decision is counted, and requests the
+// shadow policies decide differently are logged, so operators can validate a
+// policy change against production traffic before enforcing it.
+//
+// The shadow policies replace the active ones of the same kind, rather than
+// adding to them. To try an additional access window, list the active ones
+// as well.
+type ShadowPolicies struct {
+	AccessWindows      []AccessWindow
+	PolicyEngine       PolicyEngine
+	OfflineAccessRules []OfflineAccessRule
+}
+
+// Results of comparing a shadow decision with the active one.
+const (
+	shadowAgree      = "agree"
+	shadowWouldAllow = "would_allow"
+	shadowWouldDeny  = "would_deny"
+)
+
+func newShadowPolicyCounter(registry *prometheus.Registry) (*prometheus.CounterVec, error) {
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "shadow_policy_decisions_total",
+		Help: "Count of shadow policy decisions, by policy and whether they agree with the active policy.",
+	}, []string{"policy", "result"})
+	if registry != nil {
+		if err := registry.Register(counter); err != nil {
+			return nil, err
+		}
+	}
+	return counter, nil
+}
+
+func shadowResult(active, shadow bool) string {
+	switch {
+	case active == shadow:
+		return shadowAgree
+	case shadow:
+		return shadowWouldAllow
+	default:
+		return shadowWouldDeny
+	}
+}
+
+// shadowAccess evaluates the shadow access windows and policy engine of a
+// decision the active ones took.
+func (s *Server) shadowAccess(now time.Time, clientID string, claims storage.Claims, active bool) {
+	if s.shadowPolicies == nil || (s.shadowPolicies.AccessWindows == nil && s.shadowPolicies.PolicyEngine == nil) {
+		return
+	}
+	denied, ok := evaluateAccess(s.shadowPolicies.AccessWindows, s.shadowPolicies.PolicyEngine, now, clientID, claims)
+	result := shadowResult(active, ok)
+	s.shadowDecisions.WithLabelValues("access", result).Inc()
+	if result != shadowAgree {
+		s.logger.Infof("shadow policy: access of user %q to client %q %s: %s", log.Subject(claims.UserID), clientID, result, denied)
+	}
+}
+
+// shadowOfflineAccess evaluates the shadow offline access rules of a
+// decision the active ones took.
+func (s *Server) shadowOfflineAccess(clientID, connID, grantType string, active bool) {
+	if s.shadowPolicies == nil || s.shadowPolicies.OfflineAccessRules == nil {
+		return
+	}
+	result := shadowResult(active, evaluateOfflineAccess(s.shadowPolicies.OfflineAccessRules, clientID, connID, grantType))
+	s.shadowDecisions.WithLabelValues("offline_access", result).Inc()
+	if result != shadowAgree {
+		s.logger.Infof("shadow policy: offline access of client %q with connector %q and grant type %q %s", clientID, connID, grantType, result)
+	}
+}
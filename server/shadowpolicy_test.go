@@ -0,0 +1,52 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/dexidp/dex/storage"
+)
+
+func TestShadowPolicies(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.AccessWindows = []AccessWindow{{Clients: []string{"legacy"}, NotAfter: time.Now().Add(-time.Hour)}}
+		c.ShadowPolicies = &ShadowPolicies{
+			AccessWindows:      []AccessWindow{{Clients: []string{"app"}, NotAfter: time.Now().Add(-time.Hour)}},
+			OfflineAccessRules: []OfflineAccessRule{{Clients: []string{"kiosk"}}},
+		}
+	})
+	defer httpServer.Close()
+
+	claims := storage.Claims{UserID: "1", Email: "jane.doe@example.com"}
+	if _, ok := s.checkAccessWindows("app", claims); !ok {
+		t.Errorf("expected shadow access window not to deny access")
+	}
+	if _, ok := s.checkAccessWindows("legacy", claims); ok {
+		t.Errorf("expected active access window to deny access")
+	}
+	s.checkAccessWindows("other", claims)
+	if !s.offlineAccessAllowed("kiosk", "mock", grantTypeAuthorizationCode) {
+		t.Errorf("expected shadow offline access rule not to deny refresh tokens")
+	}
+
+	for _, tc := range []struct {
+		policy, result string
+		want           float64
+	}{
+		{"access", shadowWouldDeny, 1},
+		{"access", shadowWouldAllow, 1},
+		{"access", shadowAgree, 1},
+		{"offline_access", shadowWouldDeny, 1},
+		{"offline_access", shadowAgree, 0},
+	} {
+		if got := testutil.ToFloat64(s.shadowDecisions.WithLabelValues(tc.policy, tc.result)); got != tc.want {
+			t.Errorf("expected %v %s decisions to be %s, got %v", tc.want, tc.policy, tc.result, got)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/square/go-jose.v2"
+)
+
+// Signer signs tokens with a key managed outside of dex's storage, such as a
+// key held by a KMS.
+type Signer interface {
+	// Algorithm is the JWS algorithm used by Sign.
+	Algorithm() jose.SignatureAlgorithm
+	// PublicKey returns the key verifying signatures made by Sign. It's
+	// published by the keys endpoint and must have a key ID.
+	PublicKey() *jose.JSONWebKey
+	// Sign returns the payload as a compact serialized JWS.
+	Sign(ctx context.Context, payload []byte) (string, error)
+}
+
+// NewKeySigner returns a Signer using a private key. The algorithm is
+// derived from the type of the key.
+func NewKeySigner(key *jose.JSONWebKey) (Signer, error) {
+	if key.KeyID == "" {
+		return nil, errors.New("signing key has no key ID")
+	}
+	alg, err := signatureAlgorithm(key)
+	if err != nil {
+		return nil, err
+	}
+	pub := key.Public()
+	if !pub.Valid() {
+		return nil, errors.New("signing key must be a private key")
+	}
+	pub.Algorithm = string(alg)
+	pub.Use = "sig"
+	return &keySigner{key: key, pub: &pub, alg: alg}, nil
+}
+
+type keySigner struct {
+	key *jose.JSONWebKey
+	pub *jose.JSONWebKey
+	alg jose.SignatureAlgorithm
+}
+
+func (k *keySigner) Algorithm() jose.SignatureAlgorithm { return k.alg }
+
+func (k *keySigner) PublicKey() *jose.JSONWebKey { return k.pub }
+
+func (k *keySigner) Sign(ctx context.Context, payload []byte) (string, error) {
+	return signPayload(k.key, k.alg, payload)
+}
+
+// Results of comparing a token with its shadow signature.
+const (
+	shadowMatch    = "match"
+	shadowMismatch = "mismatch"
+	shadowError    = "error"
+)
+
+// signingMigration signs tokens with a new signer while the storage keys
+// keep signing the same payloads in the shadow. Both signatures are verified
+// and compared, so operators can confirm the new signing path works before
+// they commit to it.
+type signingMigration struct {
+	signer  Signer
+	results *prometheus.CounterVec
+}
+
+func newSigningMigration(signer Signer, registry *prometheus.Registry) (*signingMigration, error) {
+	if signer.PublicKey() == nil || signer.PublicKey().KeyID == "" {
+		return nil, errors.New("migration signer must publish a public key with a key ID")
+	}
+	m := &signingMigration{
+		signer: signer,
+		results: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "shadow_signatures_total",
+			Help: "Count of tokens signed by both the migration signer and the storage keys, by whether the signatures agreed.",
+		}, []string{"result"}),
+	}
+	if registry != nil {
+		if err := registry.Register(m.results); err != nil {
+			return nil, fmt.Errorf("register shadow signature metrics: %w", err)
+		}
+	}
+	return m, nil
+}
+
+// compare signs payload with the storage key and checks that both it and
+// token, signed by the migration signer, verify to the same payload.
+func (m *signingMigration) compare(storageKey *jose.JSONWebKey, storageAlg jose.SignatureAlgorithm, payload []byte, token string) (string, error) {
+	shadow, err := signPayload(storageKey, storageAlg, payload)
+	if err != nil {
+		return shadowError, fmt.Errorf("sign shadow token: %w", err)
+	}
+	oldPayload, err := verifyJWS(shadow, storageKey.Public())
+	if err != nil {
+		return shadowError, fmt.Errorf("verify shadow token: %w", err)
+	}
+	newPayload, err := verifyJWS(token, *m.signer.PublicKey())
+	if err != nil {
+		return shadowMismatch, fmt.Errorf("verify migration token: %w", err)
+	}
+	if !bytes.Equal(oldPayload, payload) || !bytes.Equal(newPayload, payload) {
+		return shadowMismatch, errors.New("signed payloads differ")
+	}
+	return shadowMatch, nil
+}
+
+func verifyJWS(token string, key jose.JSONWebKey) ([]byte, error) {
+	jws, err := jose.ParseSigned(token)
+	if err != nil {
+		return nil, err
+	}
+	return jws.Verify(key)
+}
+
+// idTokenAlgs returns the signing algorithms advertised by discovery.
+func (s *Server) idTokenAlgs() []string {
+	algs := []string{string(jose.RS256)}
+	if s.signingMigration != nil {
+		if alg := string(s.signingMigration.signer.Algorithm()); alg != algs[0] {
+			algs = append(algs, alg)
+		}
+	}
+	return algs
+}
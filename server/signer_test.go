@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gopkg.in/square/go-jose.v2"
+
+	"github.com/dexidp/dex/storage"
+)
+
+func TestSigningMigration(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := NewKeySigner(&jose.JSONWebKey{Key: ecKey, KeyID: "migration"})
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.MigrationSigner = signer
+	})
+	defer httpServer.Close()
+
+	idToken, _, err := s.newIDToken(ctx, "client", storage.Claims{UserID: "1"}, []string{"openid"}, "", "", "mock")
+	if err != nil {
+		t.Fatalf("failed to create id token: %v", err)
+	}
+	jws, err := jose.ParseSigned(idToken)
+	if err != nil {
+		t.Fatalf("failed to parse id token: %v", err)
+	}
+	if h := jws.Signatures[0].Header; h.KeyID != "migration" || h.Algorithm != string(jose.ES256) {
+		t.Errorf("expected token signed by the migration key, got kid=%q alg=%q", h.KeyID, h.Algorithm)
+	}
+	if got := testutil.ToFloat64(s.signingMigration.results.WithLabelValues(shadowMatch)); got != 1 {
+		t.Errorf("expected one matching shadow signature, got %v", got)
+	}
+
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/keys", nil))
+	var jwks jose.JSONWebKeySet
+	if err := json.Unmarshal(rr.Body.Bytes(), &jwks); err != nil {
+		t.Fatalf("failed to decode keys: %v", err)
+	}
+	if len(jwks.Key("migration")) != 1 || len(jwks.Keys) < 2 {
+		t.Fatalf("expected the storage and migration keys to be published, got %d keys", len(jwks.Keys))
+	}
+	if _, err := jws.Verify(jwks.Key("migration")[0]); err != nil {
+		t.Errorf("failed to verify token with published key: %v", err)
+	}
+}
+
+func TestNewKeySigner(t *testing.T) {
+	if _, err := NewKeySigner(&jose.JSONWebKey{Key: testKey}); err == nil {
+		t.Error("expected error for key without key ID")
+	}
+	if _, err := NewKeySigner(&jose.JSONWebKey{Key: testKey.Public(), KeyID: "pub"}); err == nil {
+		t.Error("expected error for public key")
+	}
+	signer, err := NewKeySigner(&jose.JSONWebKey{Key: testKey, KeyID: "rsa"})
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+	if signer.Algorithm() != jose.RS256 || signer.PublicKey().KeyID != "rsa" {
+		t.Errorf("unexpected signer algorithm %q and key %q", signer.Algorithm(), signer.PublicKey().KeyID)
+	}
+}
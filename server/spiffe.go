@@ -0,0 +1,320 @@
+package server
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"github.com/dexidp/dex/pkg/audit"
+	"github.com/dexidp/dex/storage"
+)
+
+const (
+	// clientAssertionTypeJWTSPIFFE is the client_assertion_type of requests
+	// authenticating with a JWT-SVID.
+	clientAssertionTypeJWTSPIFFE = "urn:ietf:params:oauth:client-assertion-type:jwt-spiffe"
+
+	// spiffeConnectorID is the connector ID of tokens issued to workloads.
+	// Tokens issued with spiffeIDConnectorID use the SPIFFE ID as their
+	// subject instead of an encoded user and connector ID.
+	spiffeConnectorID   = "spiffe"
+	spiffeIDConnectorID = "spiffe_id"
+)
+
+// SPIFFEConfig configures the authentication of workloads as clients with
+// their SPIFFE verifiable identity documents (SVIDs).
+type SPIFFEConfig struct {
+	TrustDomains []SPIFFETrustDomain
+	Clients      []SPIFFEClient
+}
+
+// SPIFFETrustDomain holds the trust bundle of a SPIFFE trust domain.
+type SPIFFETrustDomain struct {
+	// Name of the trust domain, such as "example.org".
+	Name string
+	// X509Roots verify X.509-SVIDs presented as TLS client certificates.
+	X509Roots []*x509.Certificate
+	// JWTKeys verify JWT-SVIDs presented as client assertions.
+	JWTKeys []jose.JSONWebKey
+}
+
+// SPIFFEClient allows workloads to authenticate as a client.
+type SPIFFEClient struct {
+	ClientID string
+	// SPIFFEIDs are the patterns SPIFFE IDs must match, see path.Match. For
+	// example "spiffe://example.org/ns/billing/sa/*".
+	SPIFFEIDs []string
+	// If set, tokens issued to the workload with the client credentials grant
+	// use its SPIFFE ID as their subject.
+	SPIFFEIDSubject bool
+}
+
+type spiffeTrustDomain struct {
+	roots *x509.CertPool
+	keys  jose.JSONWebKeySet
+}
+
+type spiffeVerifier struct {
+	trustDomains map[string]spiffeTrustDomain
+	clients      map[string]SPIFFEClient
+}
+
+func newSPIFFEVerifier(c SPIFFEConfig) (*spiffeVerifier, error) {
+	if len(c.TrustDomains) == 0 {
+		if len(c.Clients) != 0 {
+			return nil, errors.New("spiffe clients require a trust domain")
+		}
+		return nil, nil
+	}
+	v := &spiffeVerifier{
+		trustDomains: make(map[string]spiffeTrustDomain),
+		clients:      make(map[string]SPIFFEClient),
+	}
+	for _, td := range c.TrustDomains {
+		if td.Name == "" || strings.ContainsAny(td.Name, "/:") {
+			return nil, fmt.Errorf("invalid spiffe trust domain %q", td.Name)
+		}
+		if len(td.X509Roots) == 0 && len(td.JWTKeys) == 0 {
+			return nil, fmt.Errorf("spiffe trust domain %s has no trust bundle", td.Name)
+		}
+		bundle := spiffeTrustDomain{keys: jose.JSONWebKeySet{Keys: td.JWTKeys}}
+		if len(td.X509Roots) > 0 {
+			bundle.roots = x509.NewCertPool()
+			for _, cert := range td.X509Roots {
+				bundle.roots.AddCert(cert)
+			}
+		}
+		v.trustDomains[td.Name] = bundle
+	}
+	for _, client := range c.Clients {
+		if len(client.SPIFFEIDs) == 0 {
+			return nil, fmt.Errorf("spiffe client %s has no spiffe IDs", client.ClientID)
+		}
+		for _, pattern := range client.SPIFFEIDs {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("spiffe client %s: invalid spiffe ID pattern %q", client.ClientID, pattern)
+			}
+		}
+		v.clients[client.ClientID] = client
+	}
+	return v, nil
+}
+
+// presented reports whether the request carries an SVID.
+func (v *spiffeVerifier) presented(r *http.Request) bool {
+	if v == nil {
+		return false
+	}
+	if r.PostFormValue("client_assertion_type") == clientAssertionTypeJWTSPIFFE {
+		return true
+	}
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return false
+	}
+	for _, u := range r.TLS.PeerCertificates[0].URIs {
+		if u.Scheme == "spiffe" {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSPIFFEID returns the trust domain of a SPIFFE ID.
+func parseSPIFFEID(id string) (trustDomain string, err error) {
+	u, err := url.Parse(id)
+	if err != nil {
+		return "", fmt.Errorf("invalid spiffe ID: %w", err)
+	}
+	if u.Scheme != "spiffe" || u.Host == "" || u.User != nil || u.Port() != "" || u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("invalid spiffe ID %q", id)
+	}
+	return u.Host, nil
+}
+
+// verifyX509SVID returns the SPIFFE ID of a TLS client certificate chain
+// issued by the trust bundle of its trust domain.
+func (v *spiffeVerifier) verifyX509SVID(certs []*x509.Certificate, now time.Time) (string, error) {
+	leaf := certs[0]
+	if len(leaf.URIs) != 1 {
+		return "", errors.New("x509-svid must have exactly one URI SAN")
+	}
+	if leaf.IsCA {
+		return "", errors.New("x509-svid must not be a CA certificate")
+	}
+	id := leaf.URIs[0].String()
+	trustDomain, err := parseSPIFFEID(id)
+	if err != nil {
+		return "", err
+	}
+	td, ok := v.trustDomains[trustDomain]
+	if !ok || td.roots == nil {
+		return "", fmt.Errorf("no x509 bundle for trust domain %s", trustDomain)
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         td.roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return "", fmt.Errorf("invalid x509-svid: %w", err)
+	}
+	return id, nil
+}
+
+// verifyJWTSVID returns the SPIFFE ID of a JWT-SVID signed by the trust
+// bundle of its trust domain and meant for one of the audiences.
+func (v *spiffeVerifier) verifyJWTSVID(token string, audiences []string, now time.Time) (string, error) {
+	tok, err := jwt.ParseSigned(token)
+	if err != nil {
+		return "", fmt.Errorf("malformed jwt-svid: %w", err)
+	}
+	var unverified jwt.Claims
+	if err := tok.UnsafeClaimsWithoutVerification(&unverified); err != nil {
+		return "", fmt.Errorf("malformed jwt-svid: %w", err)
+	}
+	trustDomain, err := parseSPIFFEID(unverified.Subject)
+	if err != nil {
+		return "", err
+	}
+	td, ok := v.trustDomains[trustDomain]
+	if !ok || len(td.keys.Keys) == 0 {
+		return "", fmt.Errorf("no jwt bundle for trust domain %s", trustDomain)
+	}
+	if len(tok.Headers) == 0 || tok.Headers[0].KeyID == "" {
+		return "", errors.New("jwt-svid has no key ID")
+	}
+	keys := td.keys.Key(tok.Headers[0].KeyID)
+	if len(keys) == 0 {
+		return "", fmt.Errorf("unknown jwt-svid key %s", tok.Headers[0].KeyID)
+	}
+	var claims jwt.Claims
+	if err := tok.Claims(keys[0].Key, &claims); err != nil {
+		return "", fmt.Errorf("invalid jwt-svid signature: %w", err)
+	}
+	if claims.Expiry == nil {
+		return "", errors.New("jwt-svid must expire")
+	}
+	if err := claims.ValidateWithLeeway(jwt.Expected{Subject: unverified.Subject, Time: now}, assertionLeeway); err != nil {
+		return "", fmt.Errorf("invalid jwt-svid: %w", err)
+	}
+	for _, aud := range audiences {
+		if claims.Audience.Contains(aud) {
+			return claims.Subject, nil
+		}
+	}
+	return "", errors.New("jwt-svid audience must be the token endpoint or issuer")
+}
+
+// authenticateSVID authenticates a workload as the client of the client_id
+// parameter with its SVID. It writes an error response and returns false if
+// the SVID is invalid or not allowed to act as the client.
+func (s *Server) authenticateSVID(w http.ResponseWriter, r *http.Request) (storage.Client, string, SPIFFEClient, bool) {
+	ctx := r.Context()
+	policy, ok := s.spiffe.clients[r.PostFormValue("client_id")]
+	if !ok {
+		s.delayFailure(ctx)
+		s.tokenErrHelper(w, errInvalidClient, "Invalid client credentials.", http.StatusUnauthorized)
+		return storage.Client{}, "", SPIFFEClient{}, false
+	}
+
+	var (
+		spiffeID string
+		err      error
+	)
+	if r.PostFormValue("client_assertion_type") == clientAssertionTypeJWTSPIFFE {
+		spiffeID, err = s.spiffe.verifyJWTSVID(r.PostFormValue("client_assertion"),
+			[]string{s.absURL("/token"), s.issuerURL.String()}, s.now())
+	} else {
+		spiffeID, err = s.spiffe.verifyX509SVID(r.TLS.PeerCertificates, s.now())
+	}
+	if err == nil && !matchSPIFFEID(policy.SPIFFEIDs, spiffeID) {
+		err = fmt.Errorf("spiffe ID %s is not allowed to authenticate as client %s", spiffeID, policy.ClientID)
+	}
+	if err != nil {
+		s.logger.Debugf("svid rejected: %v", err)
+		s.delayFailure(ctx)
+		s.tokenErrHelper(w, errInvalidClient, "Invalid client credentials.", http.StatusUnauthorized)
+		return storage.Client{}, "", SPIFFEClient{}, false
+	}
+
+	client, err := s.getClient(ctx, policy.ClientID)
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			s.logger.Errorf("failed to get client: %v", err)
+			s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		} else {
+			s.tokenErrHelper(w, errInvalidClient, "Invalid client credentials.", http.StatusUnauthorized)
+		}
+		return storage.Client{}, "", SPIFFEClient{}, false
+	}
+	return client, spiffeID, policy, true
+}
+
+func matchSPIFFEID(patterns []string, id string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, id); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// handleClientCredentials issues an ID token and access token to a workload
+// authenticated with an SVID.
+func (s *Server) handleClientCredentials(w http.ResponseWriter, r *http.Request, client storage.Client, spiffeID string, policy SPIFFEClient) {
+	ctx := r.Context()
+	scopes, err := s.serviceAccountScopes(strings.Fields(r.PostFormValue("scope")))
+	if err != nil {
+		s.tokenErrHelper(w, errInvalidScope, err.Error(), http.StatusBadRequest)
+		return
+	}
+	claims := storage.Claims{UserID: spiffeID, Username: spiffeID}
+	connID := spiffeConnectorID
+	if policy.SPIFFEIDSubject {
+		connID = spiffeIDConnectorID
+	}
+	if msg, ok := s.checkAccessWindows(client.ID, claims); !ok {
+		s.tokenErrHelper(w, errAccessDenied, msg, http.StatusForbidden)
+		return
+	}
+	scopes, msg, ok := s.authorize(r, grantTypeClientCredentials, client.ID, connID, claims, scopes)
+	if !ok {
+		s.tokenErrHelper(w, errAccessDenied, msg, http.StatusForbidden)
+		return
+	}
+
+	accessToken, err := s.newAccessToken(ctx, client.ID, claims, scopes, "", connID)
+	if err != nil {
+		s.logger.Errorf("failed to create new access token: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+	idToken, expiry, err := s.newIDToken(ctx, client.ID, claims, scopes, "", accessToken, connID)
+	if err != nil {
+		s.logger.Errorf("failed to create ID token: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+
+	s.emitAudit(ctx, audit.Event{
+		Type:        audit.EventWorkloadToken,
+		Severity:    audit.SeverityInfo,
+		ClientID:    client.ID,
+		Subject:     spiffeID,
+		ConnectorID: connID,
+		SourceIPs:   []string{remoteIP(r)},
+	})
+	s.writeAccessToken(w, idToken, accessToken, "", expiry)
+}
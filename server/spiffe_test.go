@@ -0,0 +1,261 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"github.com/dexidp/dex/storage"
+)
+
+// newTestCA returns a self-signed CA and a function issuing X.509-SVIDs.
+func newTestCA(t *testing.T) (*x509.Certificate, func(spiffeID string) *x509.Certificate) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, caKey.Public(), caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issue := func(spiffeID string) *x509.Certificate {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, err := url.Parse(spiffeID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(2),
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+			URIs:         []*url.URL{u},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, key.Public(), caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+	return ca, issue
+}
+
+func TestSPIFFEClientAuthentication(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ca, issue := newTestCA(t)
+	_, issueUntrusted := newTestCA(t)
+	jwtKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.SPIFFE = SPIFFEConfig{
+			TrustDomains: []SPIFFETrustDomain{{
+				Name:      "example.org",
+				X509Roots: []*x509.Certificate{ca},
+				JWTKeys:   []jose.JSONWebKey{{Key: jwtKey.Public(), KeyID: "j1", Algorithm: string(jose.ES256)}},
+			}},
+			Clients: []SPIFFEClient{
+				{ClientID: "billing", SPIFFEIDs: []string{"spiffe://example.org/ns/billing/sa/*"}, SPIFFEIDSubject: true},
+				{ClientID: "reports", SPIFFEIDs: []string{"spiffe://example.org/ns/reports/sa/*"}},
+			},
+		}
+	})
+	defer httpServer.Close()
+
+	for _, id := range []string{"billing", "reports"} {
+		if err := s.storage.CreateClient(ctx, storage.Client{ID: id, Secret: id + "-secret"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: jwtKey}, (&jose.SignerOptions{}).WithHeader("kid", "j1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwtSVID := func(spiffeID, aud string) string {
+		svid, err := jwt.Signed(signer).Claims(jwt.Claims{
+			Subject:  spiffeID,
+			Audience: jwt.Audience{aud},
+			Expiry:   jwt.NewNumericDate(time.Now().Add(5 * time.Minute)),
+		}).CompactSerialize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return svid
+	}
+	tokenURL := s.absURL("/token")
+
+	tests := []struct {
+		name     string
+		clientID string
+		cert     *x509.Certificate
+		jwtSVID  string
+		wantCode int
+		wantSub  string
+	}{
+		{
+			name:     "x509-svid",
+			clientID: "billing",
+			cert:     issue("spiffe://example.org/ns/billing/sa/api"),
+			wantCode: http.StatusOK,
+			wantSub:  "spiffe://example.org/ns/billing/sa/api",
+		},
+		{
+			name:     "jwt-svid",
+			clientID: "billing",
+			jwtSVID:  jwtSVID("spiffe://example.org/ns/billing/sa/worker", tokenURL),
+			wantCode: http.StatusOK,
+			wantSub:  "spiffe://example.org/ns/billing/sa/worker",
+		},
+		{
+			name:     "encoded subject",
+			clientID: "reports",
+			cert:     issue("spiffe://example.org/ns/reports/sa/cron"),
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "spiffe ID of other client",
+			clientID: "reports",
+			cert:     issue("spiffe://example.org/ns/billing/sa/api"),
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "untrusted x509-svid",
+			clientID: "billing",
+			cert:     issueUntrusted("spiffe://example.org/ns/billing/sa/api"),
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "jwt-svid for other audience",
+			clientID: "billing",
+			jwtSVID:  jwtSVID("spiffe://example.org/ns/billing/sa/worker", "https://example.com"),
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "client without spiffe",
+			clientID: "mock",
+			cert:     issue("spiffe://example.org/ns/billing/sa/api"),
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+	for _, tc := range tests {
+		form := url.Values{
+			"grant_type": {grantTypeClientCredentials},
+			"client_id":  {tc.clientID},
+			"scope":      {"openid"},
+		}
+		if tc.jwtSVID != "" {
+			form.Set("client_assertion_type", clientAssertionTypeJWTSPIFFE)
+			form.Set("client_assertion", tc.jwtSVID)
+		}
+		req := tokenRequest(storage.Client{}, "10.0.0.1:1234", form)
+		req.Header.Del("Authorization")
+		if tc.cert != nil {
+			req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{tc.cert}}
+		}
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+		if rr.Code != tc.wantCode {
+			t.Errorf("%s: expected status %d, got %d: %s", tc.name, tc.wantCode, rr.Code, rr.Body)
+			continue
+		}
+		if rr.Code != http.StatusOK {
+			continue
+		}
+
+		var tokens struct {
+			IDToken string `json:"id_token"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &tokens); err != nil {
+			t.Fatal(err)
+		}
+		jws, err := jose.ParseSigned(tokens.IDToken)
+		if err != nil {
+			t.Fatalf("%s: failed to parse id token: %v", tc.name, err)
+		}
+		var claims struct {
+			Sub string `json:"sub"`
+			Aud string `json:"aud"`
+		}
+		if err := json.Unmarshal(jws.UnsafePayloadWithoutVerification(), &claims); err != nil {
+			t.Fatal(err)
+		}
+		if claims.Aud != tc.clientID {
+			t.Errorf("%s: expected audience %s, got %s", tc.name, tc.clientID, claims.Aud)
+		}
+		if tc.wantSub != "" && claims.Sub != tc.wantSub {
+			t.Errorf("%s: expected subject %s, got %s", tc.name, tc.wantSub, claims.Sub)
+		}
+		if tc.wantSub == "" && claims.Sub == "" {
+			t.Errorf("%s: expected a subject", tc.name)
+		}
+	}
+
+	// Clients authenticated with a secret can't use the client credentials
+	// grant.
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, tokenRequest(storage.Client{ID: "billing", Secret: "billing-secret"}, "10.0.0.1:1234", url.Values{
+		"grant_type": {grantTypeClientCredentials},
+	}))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected client credentials grant without svid to be rejected, got %d", rr.Code)
+	}
+}
+
+func TestNewSPIFFEVerifier(t *testing.T) {
+	if v, err := newSPIFFEVerifier(SPIFFEConfig{}); v != nil || err != nil {
+		t.Errorf("expected no verifier without trust domains, got %v %v", v, err)
+	}
+	bad := []SPIFFEConfig{
+		{Clients: []SPIFFEClient{{ClientID: "a", SPIFFEIDs: []string{"spiffe://example.org/*"}}}},
+		{TrustDomains: []SPIFFETrustDomain{{Name: "example.org"}}},
+		{TrustDomains: []SPIFFETrustDomain{{Name: "spiffe://example.org", JWTKeys: []jose.JSONWebKey{{}}}}},
+		{
+			TrustDomains: []SPIFFETrustDomain{{Name: "example.org", JWTKeys: []jose.JSONWebKey{{}}}},
+			Clients:      []SPIFFEClient{{ClientID: "a", SPIFFEIDs: []string{"spiffe://example.org/["}}},
+		},
+	}
+	for i, c := range bad {
+		if _, err := newSPIFFEVerifier(c); err == nil {
+			t.Errorf("%d: expected invalid config to be rejected", i)
+		}
+	}
+}
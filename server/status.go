@@ -0,0 +1,116 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dexidp/dex/version"
+)
+
+type versionInfo struct {
+	Version   string `json:"version"`
+	GitCommit string `json:"gitCommit,omitempty"`
+	GoVersion string `json:"goVersion"`
+}
+
+func currentVersion() versionInfo {
+	return versionInfo{
+		Version:   version.Version,
+		GitCommit: version.GitCommit,
+		GoVersion: runtime.Version(),
+	}
+}
+
+type keyStatus struct {
+	ID     string     `json:"id"`
+	Expiry *time.Time `json:"expiry,omitempty"`
+}
+
+type statusDetails struct {
+	Features     []Feature   `json:"features"`
+	Storage      string      `json:"storage,omitempty"`
+	SigningKey   *keyStatus  `json:"signingKey,omitempty"`
+	Verification []keyStatus `json:"verificationKeys"`
+	NextRotation time.Time   `json:"nextRotation"`
+}
+
+type status struct {
+	versionInfo
+	Status  string         `json:"status"`
+	Details *statusDetails `json:"details,omitempty"`
+}
+
+// isAdmin reports whether the request carries the configured admin token.
+// It always returns false if no admin token is configured.
+func (s *Server) isAdmin(r *http.Request) bool {
+	if s.adminToken == "" {
+		return false
+	}
+	const prefix = "Bearer "
+	auth := r.Header.Get("Authorization")
+	if len(auth) < len(prefix) || !strings.EqualFold(prefix, auth[:len(prefix)]) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(auth[len(prefix):]), []byte(s.adminToken)) == 1
+}
+
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	s.writeJSON(w, http.StatusOK, currentVersion())
+}
+
+// handleStatus reports the running version. Requests authenticated with the
+// admin token also get the enabled features, the storage backend and the
+// state of the signing keys.
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	st := status{versionInfo: currentVersion(), Status: "ok"}
+	if !s.isAdmin(r) {
+		s.writeJSON(w, http.StatusOK, st)
+		return
+	}
+
+	keys, err := s.storage.GetKeys(r.Context())
+	if err != nil {
+		s.logger.Errorf("failed to get keys: %v", err)
+		s.writeJSON(w, http.StatusInternalServerError, status{versionInfo: st.versionInfo, Status: "error"})
+		return
+	}
+
+	details := &statusDetails{
+		Features:     []Feature{},
+		Storage:      s.storageType,
+		Verification: []keyStatus{},
+		NextRotation: keys.NextRotation,
+	}
+	for f := range s.features {
+		details.Features = append(details.Features, f)
+	}
+	sort.Slice(details.Features, func(i, j int) bool { return details.Features[i] < details.Features[j] })
+	if keys.SigningKeyPub != nil {
+		details.SigningKey = &keyStatus{ID: keys.SigningKeyPub.KeyID}
+	}
+	for _, k := range keys.VerificationKeys {
+		expiry := k.Expiry
+		details.Verification = append(details.Verification, keyStatus{ID: k.PublicKey.KeyID, Expiry: &expiry})
+	}
+	st.Details = details
+	s.writeJSON(w, http.StatusOK, st)
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		s.logger.Errorf("failed to marshal response: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(code)
+	w.Write(data)
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleStatus(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.StorageType = "memory"
+		c.AdminToken = "secret"
+	})
+	defer httpServer.Close()
+
+	tests := []struct {
+		name        string
+		auth        string
+		wantDetails bool
+	}{
+		{name: "anonymous"},
+		{name: "wrong token", auth: "Bearer nope"},
+		{name: "admin", auth: "Bearer secret", wantDetails: true},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/status", nil)
+		if tc.auth != "" {
+			req.Header.Set("Authorization", tc.auth)
+		}
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", tc.name, rr.Code)
+		}
+
+		var got status
+		if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+			t.Fatalf("%s: failed to decode response: %v", tc.name, err)
+		}
+		if got.Status != "ok" || got.Version == "" {
+			t.Errorf("%s: unexpected status %+v", tc.name, got)
+		}
+		if (got.Details != nil) != tc.wantDetails {
+			t.Fatalf("%s: wanted details=%t, got %+v", tc.name, tc.wantDetails, got.Details)
+		}
+		if got.Details != nil {
+			if got.Details.Storage != "memory" {
+				t.Errorf("%s: expected storage type memory, got %q", tc.name, got.Details.Storage)
+			}
+			if got.Details.SigningKey == nil || got.Details.NextRotation.IsZero() {
+				t.Errorf("%s: expected signing key details, got %+v", tc.name, got.Details)
+			}
+		}
+	}
+}
+
+func TestHandleVersion(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, nil)
+	defer httpServer.Close()
+
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/version", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var got versionInfo
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if got.Version == "" || got.GoVersion == "" {
+		t.Errorf("unexpected version %+v", got)
+	}
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"github.com/dexidp/dex/api/v2"
+	"github.com/dexidp/dex/storage"
+)
+
+// sampleClient is the client shown by the sample approval screen.
+var sampleClient = storage.Client{
+	Name:            "Example App",
+	LogoURL:         "https://example.com/logo.png",
+	TOSURI:          "https://example.com/terms",
+	PolicyURI:       "https://example.com/privacy",
+	Contacts:        []string{"support@example.com"},
+	ApplicationType: applicationTypeNative,
+}
+
+// templateSamples render each template with representative data. They're
+// used to validate templates before they're deployed.
+var templateSamples = []struct {
+	name   string
+	path   string
+	render func(tmpls *templates, r *http.Request, w http.ResponseWriter) error
+}{
+	{"login", "/auth", func(tmpls *templates, r *http.Request, w http.ResponseWriter) error {
+		return tmpls.login(r, w, []connectorInfo{
+			{ID: "mock", Name: "Example", URL: "/auth/mock?req=abc123", Type: "mockCallback"},
+			{ID: "github", Name: "GitHub", URL: "/auth/github?req=abc123", Type: "github"},
+		}, "Corporate SSO is unavailable right now.", r.URL.Path)
+	}},
+	{"password", "/auth/local", func(tmpls *templates, r *http.Request, w http.ResponseWriter) error {
+		return tmpls.password(r, w, "/auth/local?req=abc123", "jane@example.com", "Email Address", true, true, r.URL.Path)
+	}},
+	{"approval", "/approval", func(tmpls *templates, r *http.Request, w http.ResponseWriter) error {
+		return tmpls.approval(r, w, "abc123", "Jane Doe", sampleClient, []string{"openid", "email", "groups", "offline_access"}, r.URL.Path)
+	}},
+	{"terms", "/terms", func(tmpls *templates, r *http.Request, w http.ResponseWriter) error {
+		return tmpls.terms(r, w, "abc123", "2020-01", "https://example.com/terms", "Be excellent to each other.")
+	}},
+	{"webauthn", "/webauthn", func(tmpls *templates, r *http.Request, w http.ResponseWriter) error {
+		return tmpls.webAuthn(r, w, webAuthnPage{
+			AuthReqID:     "abc123",
+			Challenge:     "Y2hhbGxlbmdl",
+			RPID:          "dex.example.com",
+			RPName:        "dex",
+			UserID:        "amFuZQ",
+			Username:      "jane@example.com",
+			CredentialIDs: []string{"AQIDBA"},
+		})
+	}},
+	{"emaillink", "/auth/email", func(tmpls *templates, r *http.Request, w http.ResponseWriter) error {
+		return tmpls.emailLink(r, w, emailLinkPage{
+			PostURL:          "/auth/email?req=abc123",
+			BackLink:         true,
+			Email:            "jane@example.com",
+			Sent:             true,
+			Notice:           "A login link was sent recently. Try again in 1 minute.",
+			ExpiresInMinutes: 10,
+		})
+	}},
+	{"totp", "/totp", func(tmpls *templates, r *http.Request, w http.ResponseWriter) error {
+		return tmpls.totp(r, w, totpPage{
+			AuthReqID: "abc123",
+			Enroll:    true,
+			CanSkip:   true,
+			Invalid:   true,
+			QRCode:    `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 29 29"></svg>`,
+			Secret:    "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+		})
+	}},
+	{"oob", "/approval", func(tmpls *templates, r *http.Request, w http.ResponseWriter) error {
+		return tmpls.oob(r, w, "abc123", true, 30*time.Minute, r.URL.Path)
+	}},
+	{"error", "/callback", func(tmpls *templates, r *http.Request, w http.ResponseWriter) error {
+		return tmpls.err(r, w, http.StatusBadRequest, "Invalid <request>.")
+	}},
+}
+
+// ValidateTemplates loads the templates and theme of the web config and
+// renders every template with representative data, so that templates
+// calling unknown functions or referring to missing fields are found before
+// they fail requests. It returns one error per template which fails.
+func ValidateTemplates(c WebConfig, issuerURL string) []error {
+	u, err := url.Parse(issuerURL)
+	if err != nil {
+		return []error{fmt.Errorf("parse issuer URL: %w", err)}
+	}
+	_, _, tmpls, err := loadWebConfig(webConfig{
+		dir:          c.Dir,
+		templatesDir: c.TemplatesDir,
+		logoURL:      c.LogoURL,
+		issuerURL:    issuerURL,
+		issuer:       c.Issuer,
+		theme:        c.Theme,
+		extra:        c.Extra,
+	})
+	if err != nil {
+		return []error{err}
+	}
+
+	var errs []error
+	for _, sample := range templateSamples {
+		r := httptest.NewRequest(http.MethodGet, u.Path+sample.path, nil)
+		if err := sample.render(tmpls, r, httptest.NewRecorder()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func (d dexAPI) ValidateTemplates(ctx context.Context, req *api.ValidateTemplatesReq) (*api.ValidateTemplatesResp, error) {
+	errs := ValidateTemplates(WebConfig{
+		Dir:          req.WebDir,
+		TemplatesDir: req.TemplatesDir,
+		Theme:        req.Theme,
+	}, "")
+	resp := &api.ValidateTemplatesResp{}
+	for _, err := range errs {
+		resp.Errors = append(resp.Errors, err.Error())
+	}
+	return resp, nil
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dexidp/dex/api/v2"
+	"github.com/dexidp/dex/storage/memory"
+)
+
+// copyTemplates copies the bundled templates into a temporary directory,
+// replacing the content of the overrides.
+func copyTemplates(t *testing.T, overrides map[string]string) string {
+	dir, err := ioutil.TempDir("", "dex-templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := ioutil.ReadDir("../web/templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		data, err := ioutil.ReadFile(filepath.Join("../web/templates", f.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if o, ok := overrides[f.Name()]; ok {
+			data = []byte(o)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, f.Name()), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestValidateTemplates(t *testing.T) {
+	if errs := ValidateTemplates(WebConfig{Dir: "../web"}, "https://dex.example.com/dex"); len(errs) != 0 {
+		t.Errorf("expected bundled templates to be valid, got %v", errs)
+	}
+
+	missingField := copyTemplates(t, map[string]string{
+		"approval.html": `{{ template "header.html" . }}{{ .Client }} {{ .ClientLogo }}{{ template "footer.html" . }}`,
+		"oob.html":      `{{ .Code }} {{ .NoSuchField }}`,
+	})
+	defer os.RemoveAll(missingField)
+	errs := ValidateTemplates(WebConfig{Dir: "../web", TemplatesDir: missingField}, "https://dex.example.com/dex")
+	if len(errs) != 2 {
+		t.Fatalf("expected errors for approval and oob templates, got %v", errs)
+	}
+	if !strings.Contains(errs[0].Error(), "ClientLogo") || !strings.Contains(errs[1].Error(), "NoSuchField") {
+		t.Errorf("expected errors to name the missing fields, got %v", errs)
+	}
+
+	missingFunc := copyTemplates(t, map[string]string{
+		"login.html": `{{ translate "Log in" }}`,
+	})
+	defer os.RemoveAll(missingFunc)
+	errs = ValidateTemplates(WebConfig{Dir: "../web", TemplatesDir: missingFunc}, "https://dex.example.com/dex")
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), `"translate" not defined`) {
+		t.Errorf("expected error for undefined function, got %v", errs)
+	}
+}
+
+func TestValidateTemplatesAPI(t *testing.T) {
+	client := newAPI(memory.New(logger), logger, t)
+	defer client.Close()
+
+	ctx := context.Background()
+	resp, err := client.ValidateTemplates(ctx, &api.ValidateTemplatesReq{WebDir: "../web", Theme: "tectonic"})
+	if err != nil {
+		t.Fatalf("validate templates: %v", err)
+	}
+	if len(resp.Errors) != 0 {
+		t.Errorf("expected no errors, got %v", resp.Errors)
+	}
+
+	resp, err = client.ValidateTemplates(ctx, &api.ValidateTemplatesReq{WebDir: "../web", Theme: "missing"})
+	if err != nil {
+		t.Fatalf("validate templates: %v", err)
+	}
+	if len(resp.Errors) != 1 {
+		t.Errorf("expected error for missing theme, got %v", resp.Errors)
+	}
+}
@@ -12,14 +12,21 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
+
+	"github.com/dexidp/dex/storage"
 )
 
 const (
-	tmplApproval = "approval.html"
-	tmplLogin    = "login.html"
-	tmplPassword = "password.html"
-	tmplOOB      = "oob.html"
-	tmplError    = "error.html"
+	tmplApproval  = "approval.html"
+	tmplLogin     = "login.html"
+	tmplPassword  = "password.html"
+	tmplOOB       = "oob.html"
+	tmplError     = "error.html"
+	tmplTerms     = "terms.html"
+	tmplWebAuthn  = "webauthn.html"
+	tmplEmailLink = "emaillink.html"
+	tmplTOTP      = "totp.html"
 )
 
 var requiredTmpls = []string{
@@ -28,23 +35,37 @@ var requiredTmpls = []string{
 	tmplPassword,
 	tmplOOB,
 	tmplError,
+	tmplTerms,
+	tmplWebAuthn,
+	tmplEmailLink,
+	tmplTOTP,
 }
 
 type templates struct {
-	loginTmpl    *template.Template
-	approvalTmpl *template.Template
-	passwordTmpl *template.Template
-	oobTmpl      *template.Template
-	errorTmpl    *template.Template
+	loginTmpl     *template.Template
+	approvalTmpl  *template.Template
+	passwordTmpl  *template.Template
+	oobTmpl       *template.Template
+	errorTmpl     *template.Template
+	termsTmpl     *template.Template
+	webAuthnTmpl  *template.Template
+	emailLinkTmpl *template.Template
+	totpTmpl      *template.Template
+
+	// Descriptions of the custom scopes, shown in addition to the ones in
+	// scopeDescriptions.
+	scopeDescriptions map[string]string
 }
 
 type webConfig struct {
-	dir       string
-	logoURL   string
-	issuer    string
-	theme     string
-	issuerURL string
-	extra     map[string]string
+	dir          string
+	templatesDir string
+	logoURL      string
+	issuer       string
+	theme        string
+	issuerURL    string
+	extra        map[string]string
+	scopes       map[string]string
 }
 
 func dirExists(dir string) error {
@@ -53,7 +74,7 @@ func dirExists(dir string) error {
 		if os.IsNotExist(err) {
 			return fmt.Errorf("directory %q does not exist", dir)
 		}
-		return fmt.Errorf("stat directory %q: %v", dir, err)
+		return fmt.Errorf("stat directory %q: %w", dir, err)
 	}
 	if !stat.IsDir() {
 		return fmt.Errorf("path %q is a file not a directory", dir)
@@ -87,31 +108,42 @@ func loadWebConfig(c webConfig) (static, theme http.Handler, templates *template
 	}
 
 	if err := dirExists(c.dir); err != nil {
-		return nil, nil, nil, fmt.Errorf("load web dir: %v", err)
+		return nil, nil, nil, fmt.Errorf("load web dir: %w", err)
 	}
 
 	staticDir := filepath.Join(c.dir, "static")
-	templatesDir := filepath.Join(c.dir, "templates")
+	templatesDir := c.templatesDir
+	if templatesDir == "" {
+		templatesDir = filepath.Join(c.dir, "templates")
+	}
 	themeDir := filepath.Join(c.dir, "themes", c.theme)
 
 	for _, dir := range []string{staticDir, templatesDir, themeDir} {
 		if err := dirExists(dir); err != nil {
-			return nil, nil, nil, fmt.Errorf("load dir: %v", err)
+			return nil, nil, nil, fmt.Errorf("load dir: %w", err)
 		}
 	}
 
-	static = http.FileServer(http.Dir(staticDir))
-	theme = http.FileServer(http.Dir(themeDir))
+	hashes := make(assetHashes)
+	if err := hashes.hashAssets("static", staticDir); err != nil {
+		return nil, nil, nil, fmt.Errorf("hash static assets: %w", err)
+	}
+	if err := hashes.hashAssets("theme", themeDir); err != nil {
+		return nil, nil, nil, fmt.Errorf("hash theme assets: %w", err)
+	}
+
+	static = hashes.cacheAssets("static", http.FileServer(http.Dir(staticDir)))
+	theme = hashes.cacheAssets("theme", http.FileServer(http.Dir(themeDir)))
 
-	templates, err = loadTemplates(c, templatesDir)
+	templates, err = loadTemplates(c, templatesDir, hashes)
 	return
 }
 
 // loadTemplates parses the expected templates from the provided directory.
-func loadTemplates(c webConfig, templatesDir string) (*templates, error) {
+func loadTemplates(c webConfig, templatesDir string, hashes assetHashes) (*templates, error) {
 	files, err := ioutil.ReadDir(templatesDir)
 	if err != nil {
-		return nil, fmt.Errorf("read dir: %v", err)
+		return nil, fmt.Errorf("read dir: %w", err)
 	}
 
 	filenames := []string{}
@@ -127,20 +159,22 @@ func loadTemplates(c webConfig, templatesDir string) (*templates, error) {
 
 	issuerURL, err := url.Parse(c.issuerURL)
 	if err != nil {
-		return nil, fmt.Errorf("error parsing issuerURL: %v", err)
+		return nil, fmt.Errorf("error parsing issuerURL: %w", err)
 	}
 
 	funcs := map[string]interface{}{
 		"issuer": func() string { return c.issuer },
 		"logo":   func() string { return c.logoURL },
-		"url":    func(reqPath, assetPath string) string { return relativeURL(issuerURL.Path, reqPath, assetPath) },
-		"lower":  strings.ToLower,
-		"extra":  func(k string) string { return c.extra[k] },
+		"url": func(reqPath, assetPath string) string {
+			return hashes.versionedURL(assetPath, relativeURL(issuerURL.Path, reqPath, assetPath))
+		},
+		"lower": strings.ToLower,
+		"extra": func(k string) string { return c.extra[k] },
 	}
 
 	tmpls, err := template.New("").Funcs(funcs).ParseFiles(filenames...)
 	if err != nil {
-		return nil, fmt.Errorf("parse files: %v", err)
+		return nil, fmt.Errorf("parse files: %w", err)
 	}
 	missingTmpls := []string{}
 	for _, tmplName := range requiredTmpls {
@@ -152,11 +186,17 @@ func loadTemplates(c webConfig, templatesDir string) (*templates, error) {
 		return nil, fmt.Errorf("missing template(s): %s", missingTmpls)
 	}
 	return &templates{
-		loginTmpl:    tmpls.Lookup(tmplLogin),
-		approvalTmpl: tmpls.Lookup(tmplApproval),
-		passwordTmpl: tmpls.Lookup(tmplPassword),
-		oobTmpl:      tmpls.Lookup(tmplOOB),
-		errorTmpl:    tmpls.Lookup(tmplError),
+		loginTmpl:     tmpls.Lookup(tmplLogin),
+		approvalTmpl:  tmpls.Lookup(tmplApproval),
+		passwordTmpl:  tmpls.Lookup(tmplPassword),
+		oobTmpl:       tmpls.Lookup(tmplOOB),
+		errorTmpl:     tmpls.Lookup(tmplError),
+		termsTmpl:     tmpls.Lookup(tmplTerms),
+		webAuthnTmpl:  tmpls.Lookup(tmplWebAuthn),
+		emailLinkTmpl: tmpls.Lookup(tmplEmailLink),
+		totpTmpl:      tmpls.Lookup(tmplTOTP),
+
+		scopeDescriptions: c.scopes,
 	}, nil
 }
 
@@ -242,12 +282,13 @@ func (n byName) Len() int           { return len(n) }
 func (n byName) Less(i, j int) bool { return n[i].Name < n[j].Name }
 func (n byName) Swap(i, j int)      { n[i], n[j] = n[j], n[i] }
 
-func (t *templates) login(r *http.Request, w http.ResponseWriter, connectors []connectorInfo, reqPath string) error {
+func (t *templates) login(r *http.Request, w http.ResponseWriter, connectors []connectorInfo, notice, reqPath string) error {
 	sort.Sort(byName(connectors))
 	data := struct {
 		Connectors []connectorInfo
+		Notice     string
 		ReqPath    string
-	}{connectors, r.URL.Path}
+	}{connectors, notice, r.URL.Path}
 	return renderTemplate(w, t.loginTmpl, data)
 }
 
@@ -263,10 +304,16 @@ func (t *templates) password(r *http.Request, w http.ResponseWriter, postURL, la
 	return renderTemplate(w, t.passwordTmpl, data)
 }
 
-func (t *templates) approval(r *http.Request, w http.ResponseWriter, authReqID, username, clientName string, scopes []string, reqPath string) error {
+func (t *templates) approval(r *http.Request, w http.ResponseWriter, authReqID, username string, client storage.Client, scopes []string, reqPath string) error {
 	accesses := []string{}
 	for _, scope := range scopes {
 		access, ok := scopeDescriptions[scope]
+		if !ok {
+			access, ok = t.scopeDescriptions[scope]
+			if ok && access == "" {
+				access = scope
+			}
+		}
 		if ok {
 			accesses = append(accesses, access)
 		}
@@ -278,15 +325,59 @@ func (t *templates) approval(r *http.Request, w http.ResponseWriter, authReqID,
 		AuthReqID string
 		Scopes    []string
 		ReqPath   string
-	}{username, clientName, authReqID, accesses, r.URL.Path}
+		// Metadata the client registered.
+		LogoURL   string
+		TOSURI    string
+		PolicyURI string
+		Contacts  []string
+		Native    bool
+	}{username, client.Name, authReqID, accesses, r.URL.Path,
+		client.LogoURL, client.TOSURI, client.PolicyURI, client.Contacts, client.ApplicationType == applicationTypeNative}
 	return renderTemplate(w, t.approvalTmpl, data)
 }
 
-func (t *templates) oob(r *http.Request, w http.ResponseWriter, code string, reqPath string) error {
+func (t *templates) terms(r *http.Request, w http.ResponseWriter, authReqID, version, docURL, text string) error {
+	data := struct {
+		AuthReqID string
+		Version   string
+		URL       string
+		Text      string
+		ReqPath   string
+	}{authReqID, version, docURL, text, r.URL.Path}
+	return renderTemplate(w, t.termsTmpl, data)
+}
+
+func (t *templates) webAuthn(r *http.Request, w http.ResponseWriter, page webAuthnPage) error {
 	data := struct {
-		Code    string
+		webAuthnPage
 		ReqPath string
-	}{code, r.URL.Path}
+	}{page, r.URL.Path}
+	return renderTemplate(w, t.webAuthnTmpl, data)
+}
+
+func (t *templates) emailLink(r *http.Request, w http.ResponseWriter, page emailLinkPage) error {
+	data := struct {
+		emailLinkPage
+		ReqPath string
+	}{page, r.URL.Path}
+	return renderTemplate(w, t.emailLinkTmpl, data)
+}
+
+func (t *templates) totp(r *http.Request, w http.ResponseWriter, page totpPage) error {
+	data := struct {
+		totpPage
+		ReqPath string
+	}{page, r.URL.Path}
+	return renderTemplate(w, t.totpTmpl, data)
+}
+
+func (t *templates) oob(r *http.Request, w http.ResponseWriter, code string, auto bool, validFor time.Duration, reqPath string) error {
+	data := struct {
+		Code             string
+		Auto             bool
+		ExpiresInMinutes int
+		ReqPath          string
+	}{code, auto, int(validFor.Round(time.Minute).Minutes()), r.URL.Path}
 	return renderTemplate(w, t.oobTmpl, data)
 }
 
@@ -1,6 +1,19 @@
 package server
 
-import "testing"
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files of template tests")
 
 func TestRelativeURL(t *testing.T) {
 	tests := []struct {
@@ -42,3 +55,68 @@ func TestRelativeURL(t *testing.T) {
 		})
 	}
 }
+
+func TestOOBTemplate(t *testing.T) {
+	_, _, tmpls, err := loadWebConfig(webConfig{dir: "../web"})
+	if err != nil {
+		t.Fatalf("load web config: %v", err)
+	}
+
+	for _, auto := range []bool{false, true} {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/approval", nil)
+		if err := tmpls.oob(req, rr, "abc123", auto, 30*time.Minute, req.URL.Path); err != nil {
+			t.Fatalf("render oob template: %v", err)
+		}
+		body := rr.Body.String()
+		if !strings.Contains(body, `value="abc123"`) || !strings.Contains(body, "expires in 30 minutes") {
+			t.Errorf("auto=%t: expected code and expiry in %q", auto, body)
+		}
+		if got := strings.Contains(body, "document.title"); got != auto {
+			t.Errorf("auto=%t: expected code in title %t, got %t", auto, auto, got)
+		}
+	}
+}
+
+// TestTemplatesGolden renders every template of every theme and compares the
+// output to the golden files in testdata/templates. Run the test with
+// -update to regenerate them after changing templates or theme assets.
+func TestTemplatesGolden(t *testing.T) {
+	for _, theme := range []string{"coreos", "tectonic"} {
+		_, _, tmpls, err := loadWebConfig(webConfig{
+			dir:       "../web",
+			issuer:    "dex",
+			issuerURL: "https://dex.example.com/dex",
+			theme:     theme,
+		})
+		if err != nil {
+			t.Fatalf("load web config for theme %s: %v", theme, err)
+		}
+		for _, tc := range templateSamples {
+			t.Run(theme+"/"+tc.name, func(t *testing.T) {
+				rr := httptest.NewRecorder()
+				req := httptest.NewRequest(http.MethodGet, "https://dex.example.com/dex"+tc.path, nil)
+				if err := tc.render(tmpls, req, rr); err != nil {
+					t.Fatalf("render: %v", err)
+				}
+				golden := filepath.Join("testdata", "templates", theme, tc.name+".html")
+				if *updateGolden {
+					if err := os.MkdirAll(filepath.Dir(golden), 0755); err != nil {
+						t.Fatal(err)
+					}
+					if err := ioutil.WriteFile(golden, rr.Body.Bytes(), 0644); err != nil {
+						t.Fatal(err)
+					}
+					return
+				}
+				want, err := ioutil.ReadFile(golden)
+				if err != nil {
+					t.Fatalf("read golden file (run with -update to create it): %v", err)
+				}
+				if !bytes.Equal(rr.Body.Bytes(), want) {
+					t.Errorf("output differs from %s (run with -update to accept it):\n%s", golden, rr.Body.String())
+				}
+			})
+		}
+	}
+}
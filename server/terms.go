@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path"
+
+	"github.com/dexidp/dex/pkg/log"
+	"github.com/dexidp/dex/storage"
+)
+
+// TermsOfService is a document users must accept before tokens are issued to
+// them. Users are prompted again whenever Version changes.
+type TermsOfService struct {
+	// Version of the document. An empty version disables the prompt.
+	Version string
+	// URL the document can be read at.
+	URL string
+	// Optional summary shown on the prompt.
+	Text string
+}
+
+// termsAccepted reports whether the user has accepted the current version of
+// the terms of service. It always returns true if none are configured.
+func (s *Server) termsAccepted(ctx context.Context, userID, connID string) (bool, error) {
+	if s.terms.Version == "" {
+		return true, nil
+	}
+	a, err := s.storage.GetTermsAcceptance(ctx, userID, connID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return a.Version == s.terms.Version, nil
+}
+
+func (s *Server) handleTerms(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	authReq, err := s.storage.GetAuthRequest(ctx, r.FormValue("req"))
+	if err != nil {
+		s.logger.Errorf("Failed to get auth request: %v", err)
+		s.renderError(r, w, http.StatusInternalServerError, "Database error.")
+		return
+	}
+	if !authReq.LoggedIn {
+		s.logger.Errorf("Auth request does not have an identity for terms of service")
+		s.renderError(r, w, http.StatusInternalServerError, "Login process not yet finalized.")
+		return
+	}
+	approvalURL := path.Join(s.issuerURL.Path, "/approval") + "?req=" + authReq.ID
+
+	switch r.Method {
+	case http.MethodGet:
+		if s.terms.Version == "" {
+			http.Redirect(w, r, approvalURL, http.StatusSeeOther)
+			return
+		}
+		if err := s.templates.terms(r, w, authReq.ID, s.terms.Version, s.terms.URL, s.terms.Text); err != nil {
+			s.logger.Errorf("Server template error: %v", err)
+		}
+	case http.MethodPost:
+		if r.FormValue("terms") != "accept" {
+			s.renderError(r, w, http.StatusForbidden, "The terms of service must be accepted to continue.")
+			return
+		}
+		// Only record acceptance of the version the user was shown.
+		if r.FormValue("version") != s.terms.Version {
+			http.Redirect(w, r, path.Join(s.issuerURL.Path, "/terms")+"?req="+authReq.ID, http.StatusSeeOther)
+			return
+		}
+
+		acceptance := storage.TermsAcceptance{
+			UserID:     authReq.Claims.UserID,
+			ConnID:     authReq.ConnectorID,
+			Version:    s.terms.Version,
+			AcceptedAt: s.now(),
+		}
+		err := s.storage.CreateTermsAcceptance(ctx, acceptance)
+		if errors.Is(err, storage.ErrAlreadyExists) {
+			err = s.storage.UpdateTermsAcceptance(ctx, acceptance.UserID, acceptance.ConnID, func(old storage.TermsAcceptance) (storage.TermsAcceptance, error) {
+				old.Version = acceptance.Version
+				old.AcceptedAt = acceptance.AcceptedAt
+				return old, nil
+			})
+		}
+		if err != nil {
+			s.logger.Errorf("Failed to store terms of service acceptance: %v", err)
+			s.renderError(r, w, http.StatusInternalServerError, "Database error.")
+			return
+		}
+		s.logger.Infof("user %q accepted terms of service version %q", log.Email(authReq.Claims.Email), s.terms.Version)
+		http.Redirect(w, r, approvalURL, http.StatusSeeOther)
+	default:
+		s.renderError(r, w, http.StatusBadRequest, "Unsupported request method.")
+	}
+}
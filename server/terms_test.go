@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dexidp/dex/storage"
+)
+
+func TestTermsOfService(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.TermsOfService = TermsOfService{Version: "v1", URL: "https://example.com/terms"}
+	})
+	defer httpServer.Close()
+
+	newAuthRequest := func() storage.AuthRequest {
+		authReq := storage.AuthRequest{
+			ID:            storage.NewID(),
+			ClientID:      "test",
+			ResponseTypes: []string{responseTypeCode},
+			RedirectURI:   "https://example.com/callback",
+			ConnectorID:   "mock",
+			LoggedIn:      true,
+			Claims:        storage.Claims{UserID: "0-385-28089-0", Email: "kilgore@kilgore.trout"},
+			Expiry:        time.Now().Add(time.Hour),
+		}
+		if err := s.storage.CreateAuthRequest(ctx, authReq); err != nil {
+			t.Fatalf("create auth request: %v", err)
+		}
+		return authReq
+	}
+	do := func(method, target string, form url.Values) *httptest.ResponseRecorder {
+		var req *http.Request
+		if form != nil {
+			req = httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		} else {
+			req = httptest.NewRequest(method, target, nil)
+		}
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+		return rr
+	}
+
+	authReq := newAuthRequest()
+	rr := do(http.MethodGet, "/approval?req="+authReq.ID, nil)
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/terms?req="+authReq.ID {
+		t.Fatalf("expected redirect to terms of service, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	rr = do(http.MethodGet, "/terms?req="+authReq.ID, nil)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "https://example.com/terms") {
+		t.Fatalf("expected terms of service page, got %d", rr.Code)
+	}
+
+	rr = do(http.MethodPost, "/terms", url.Values{"req": {authReq.ID}, "terms": {"decline"}})
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected declining to be forbidden, got %d", rr.Code)
+	}
+
+	rr = do(http.MethodPost, "/terms", url.Values{"req": {authReq.ID}, "terms": {"accept"}, "version": {"v1"}})
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/approval?req="+authReq.ID {
+		t.Fatalf("expected redirect to approval, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	acceptance, err := s.storage.GetTermsAcceptance(ctx, authReq.Claims.UserID, authReq.ConnectorID)
+	if err != nil {
+		t.Fatalf("get terms acceptance: %v", err)
+	}
+	if acceptance.Version != "v1" {
+		t.Errorf("expected accepted version v1, got %q", acceptance.Version)
+	}
+
+	rr = do(http.MethodGet, "/approval?req="+authReq.ID, nil)
+	if loc := rr.Header().Get("Location"); !strings.HasPrefix(loc, authReq.RedirectURI) {
+		t.Fatalf("expected redirect to client after accepting, got %d %q", rr.Code, loc)
+	}
+
+	// Publishing a new version prompts the user again.
+	s.terms.Version = "v2"
+	authReq = newAuthRequest()
+	rr = do(http.MethodGet, "/approval?req="+authReq.ID, nil)
+	if rr.Header().Get("Location") != "/terms?req="+authReq.ID {
+		t.Fatalf("expected redirect to terms of service after version change, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	rr = do(http.MethodPost, "/terms", url.Values{"req": {authReq.ID}, "terms": {"accept"}, "version": {"v2"}})
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected redirect after accepting new version, got %d", rr.Code)
+	}
+	if acceptance, _ := s.storage.GetTermsAcceptance(ctx, authReq.Claims.UserID, authReq.ConnectorID); acceptance.Version != "v2" {
+		t.Errorf("expected accepted version v2, got %q", acceptance.Version)
+	}
+}
@@ -0,0 +1,237 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dexidp/dex/connector"
+	"github.com/dexidp/dex/pkg/audit"
+	"github.com/dexidp/dex/storage"
+)
+
+// tokenExchangeScopes returns the scopes of an exchanged token, which
+// default to "openid". Requested audiences are added as cross-client scopes,
+// so each of them must trust the client. Exchanged tokens are never
+// refreshed.
+func (s *Server) tokenExchangeScopes(r *http.Request, clientID string, requested, audiences []string) (scopes []string, errType, msg string) {
+	if len(requested) == 0 {
+		requested = []string{scopeOpenID}
+	}
+	for _, scope := range requested {
+		switch scope {
+		case scopeOpenID, scopeEmail, scopeProfile, scopeGroups, scopeFederatedID:
+		default:
+			peerIDs, ok := s.requestedAudiences(scope)
+			if _, custom := s.customScopes[scope]; !ok && !custom {
+				return nil, errInvalidScope, fmt.Sprintf("Scope %q can't be requested for an exchanged token.", scope)
+			}
+			audiences = append(audiences, peerIDs...)
+		}
+		scopes = append(scopes, scope)
+	}
+	for _, peerID := range audiences {
+		if peerID == clientID {
+			continue
+		}
+		trusted, err := s.validateCrossClientTrust(r.Context(), clientID, peerID)
+		if err != nil {
+			s.logger.Errorf("failed to validate cross client trust: %v", err)
+			return nil, errServerError, ""
+		}
+		if !trusted {
+			return nil, errInvalidTarget, fmt.Sprintf("Audience %q doesn't trust the client.", peerID)
+		}
+		if scope := scopeCrossClientPrefix + peerID; !contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, "", ""
+}
+
+// handleTokenExchange exchanges a token issued by the upstream provider of a
+// connector for a token issued by dex, following RFC 8693. The connector is
+// chosen with the "connector_id" parameter and must be able to validate
+// tokens of its provider.
+func (s *Server) handleTokenExchange(w http.ResponseWriter, r *http.Request, client storage.Client) {
+	ctx := r.Context()
+	if err := r.ParseForm(); err != nil {
+		s.tokenErrHelper(w, errInvalidRequest, "Couldn't parse data", http.StatusBadRequest)
+		return
+	}
+	q := r.Form
+
+	subjectToken := q.Get("subject_token")
+	subjectTokenType := q.Get("subject_token_type")
+	if subjectToken == "" || subjectTokenType == "" {
+		s.tokenErrHelper(w, errInvalidRequest, "Missing subject_token or subject_token_type.", http.StatusBadRequest)
+		return
+	}
+	actorToken := q.Get("actor_token")
+	actorTokenType := q.Get("actor_token_type")
+	if (actorToken == "") != (actorTokenType == "") {
+		s.tokenErrHelper(w, errInvalidRequest, "actor_token and actor_token_type must be passed together.", http.StatusBadRequest)
+		return
+	}
+	requestedTokenType := q.Get("requested_token_type")
+	switch requestedTokenType {
+	case "":
+		requestedTokenType = connector.TokenTypeAccessToken
+	case connector.TokenTypeAccessToken, connector.TokenTypeIDToken:
+	default:
+		s.tokenErrHelper(w, errInvalidRequest, fmt.Sprintf("Unsupported requested_token_type %q.", requestedTokenType), http.StatusBadRequest)
+		return
+	}
+	if len(q["resource"]) > 0 {
+		s.tokenErrHelper(w, errInvalidTarget, "Resource indicators aren't supported, use audience.", http.StatusBadRequest)
+		return
+	}
+
+	scopes, errType, msg := s.tokenExchangeScopes(r, client.ID, strings.Fields(q.Get("scope")), q["audience"])
+	if errType != "" {
+		status := http.StatusBadRequest
+		if errType == errServerError {
+			status = http.StatusInternalServerError
+		}
+		s.tokenErrHelper(w, errType, msg, status)
+		return
+	}
+
+	connID := q.Get("connector_id")
+	conn, err := s.getConnector(ctx, connID)
+	if err != nil {
+		s.tokenErrHelper(w, errInvalidRequest, "Requested connector does not exist.", http.StatusBadRequest)
+		return
+	}
+	tokenConn, ok := conn.Connector.(connector.TokenIdentityConnector)
+	if !ok {
+		s.tokenErrHelper(w, errInvalidRequest, "Requested connector can't exchange tokens.", http.StatusBadRequest)
+		return
+	}
+
+	identity, ok := s.tokenIdentity(w, r, tokenConn, connID, subjectTokenType, subjectToken, "subject")
+	if !ok {
+		return
+	}
+	var actor *actorClaims
+	if actorToken != "" {
+		actorIdentity, ok := s.tokenIdentity(w, r, tokenConn, connID, actorTokenType, actorToken, "actor")
+		if !ok {
+			return
+		}
+		if identity.MayAct != "" && identity.MayAct != actorIdentity.UserID {
+			s.tokenErrHelper(w, errInvalidRequest, "The actor may not act on behalf of the subject.", http.StatusBadRequest)
+			return
+		}
+		actor = &actorClaims{Subject: subjectFor(actorIdentity.UserID, connID)}
+	}
+
+	claims := storage.Claims{
+		UserID:            identity.UserID,
+		Username:          identity.Username,
+		PreferredUsername: identity.PreferredUsername,
+		Email:             identity.Email,
+		EmailVerified:     identity.EmailVerified,
+		Groups:            identity.Groups,
+	}
+	if msg, ok := s.checkAccessWindows(client.ID, claims); !ok {
+		s.tokenErrHelper(w, errAccessDenied, msg, http.StatusForbidden)
+		return
+	}
+	scopes, msg, ok = s.authorize(r, grantTypeTokenExchange, client.ID, connID, claims, scopes)
+	if !ok {
+		s.tokenErrHelper(w, errAccessDenied, msg, http.StatusForbidden)
+		return
+	}
+	// Like the password grant, token exchange can't prompt the user for the
+	// terms of service.
+	accepted, err := s.termsAccepted(ctx, claims.UserID, connID)
+	if err != nil {
+		s.logger.Errorf("failed to get terms of service acceptance: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+	if !accepted {
+		s.tokenErrHelper(w, errAccessDenied, "The current terms of service have not been accepted.", http.StatusForbidden)
+		return
+	}
+
+	// Access tokens are built like by newAccessToken, ID tokens have no
+	// access token to hash.
+	accessToken := ""
+	if requestedTokenType == connector.TokenTypeAccessToken {
+		accessToken = storage.NewID()
+	}
+	token, expiry, err := s.newActorIDToken(ctx, client.ID, claims, scopes, "", accessToken, connID, actor)
+	if err != nil {
+		s.logger.Errorf("failed to create exchanged token: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+
+	e := audit.Event{
+		Type:        audit.EventTokenExchange,
+		Severity:    audit.SeverityInfo,
+		ClientID:    client.ID,
+		Subject:     subjectFor(claims.UserID, connID),
+		ConnectorID: connID,
+		SourceIPs:   []string{remoteIP(r)},
+	}
+	if actor != nil {
+		e.Message = "on behalf of the subject, by " + actor.Subject
+	}
+	s.emitAudit(ctx, e)
+	s.writeExchangedToken(w, token, requestedTokenType, expiry)
+}
+
+// tokenIdentity validates a subject or actor token with the connector. It
+// writes an error response and returns false if the token is invalid.
+func (s *Server) tokenIdentity(w http.ResponseWriter, r *http.Request, conn connector.TokenIdentityConnector, connID, tokenType, token, role string) (connector.Identity, bool) {
+	start := time.Now()
+	identity, err := conn.TokenIdentity(r.Context(), tokenType, token)
+	s.connectorMetrics.observe(connID, connectorOpTokenIdentity, connectorOutcome(true, err), start)
+	if err != nil {
+		s.logger.Debugf("%s token rejected by connector %q: %v", role, connID, err)
+		s.delayFailure(r.Context())
+		s.tokenErrHelper(w, errInvalidRequest, fmt.Sprintf("Invalid %s token.", role), http.StatusBadRequest)
+		return identity, false
+	}
+	identity, err = s.filterGroups(connID, s.transformIdentity(connID, identity))
+	if err != nil {
+		s.logger.Infof("%s token rejected: %v", role, err)
+		s.tokenErrHelper(w, errAccessDenied, "User is not a member of the groups allowed to log in.", http.StatusForbidden)
+		return identity, false
+	}
+	return identity, true
+}
+
+// writeExchangedToken writes the response of RFC 8693 section 2.2.1. Tokens
+// other than access tokens can't be used as bearer tokens.
+func (s *Server) writeExchangedToken(w http.ResponseWriter, token, tokenType string, expiry time.Time) {
+	resp := struct {
+		AccessToken     string `json:"access_token"`
+		IssuedTokenType string `json:"issued_token_type"`
+		TokenType       string `json:"token_type"`
+		ExpiresIn       int    `json:"expires_in"`
+	}{
+		token,
+		tokenType,
+		"bearer",
+		int(expiry.Sub(s.now()).Seconds()),
+	}
+	if tokenType != connector.TokenTypeAccessToken {
+		resp.TokenType = "N_A"
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		s.logger.Errorf("failed to marshal token exchange response: %v", err)
+		s.tokenErrHelper(w, errServerError, "", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
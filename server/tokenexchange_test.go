@@ -0,0 +1,130 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	jose "gopkg.in/square/go-jose.v2"
+
+	"github.com/dexidp/dex/connector"
+	"github.com/dexidp/dex/storage"
+)
+
+func TestTokenExchange(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.Features = []Feature{FeatureTokenExchange}
+	})
+	defer httpServer.Close()
+
+	client := storage.Client{ID: "workload", Secret: "secret"}
+	peer := storage.Client{ID: "backend", Secret: "secret", TrustedPeers: []string{client.ID}}
+	other := storage.Client{ID: "other", Secret: "secret"}
+	for _, c := range []storage.Client{client, peer, other} {
+		if err := s.storage.CreateClient(ctx, c); err != nil {
+			t.Fatalf("create client: %v", err)
+		}
+	}
+
+	exchange := func(params url.Values) (*httptest.ResponseRecorder, map[string]interface{}) {
+		params.Set("grant_type", grantTypeTokenExchange)
+		params.Set("connector_id", "mock")
+		params.Set("subject_token_type", connector.TokenTypeIDToken)
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, tokenRequest(client, "10.0.0.1:1234", params))
+		var resp struct {
+			AccessToken     string `json:"access_token"`
+			IssuedTokenType string `json:"issued_token_type"`
+		}
+		if rr.Code != http.StatusOK {
+			return rr, nil
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode token response: %v", err)
+		}
+		if resp.IssuedTokenType != connector.TokenTypeAccessToken {
+			t.Errorf("expected an access token to be issued, got %q", resp.IssuedTokenType)
+		}
+		jws, err := jose.ParseSigned(resp.AccessToken)
+		if err != nil {
+			t.Fatalf("parse access token: %v", err)
+		}
+		var claims map[string]interface{}
+		if err := json.Unmarshal(jws.UnsafePayloadWithoutVerification(), &claims); err != nil {
+			t.Fatalf("decode access token claims: %v", err)
+		}
+		return rr, claims
+	}
+
+	rr, claims := exchange(url.Values{"subject_token": {"upstream"}, "audience": {peer.ID}, "scope": {"openid email"}})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected exchange to succeed, got %d: %s", rr.Code, rr.Body)
+	}
+	aud, _ := claims["aud"].([]interface{})
+	if len(aud) != 2 || aud[0] != peer.ID || aud[1] != client.ID || claims["azp"] != client.ID {
+		t.Errorf("expected audience of %s and %s, got %v", peer.ID, client.ID, claims)
+	}
+	if claims["email"] != "kilgore@kilgore.trout" {
+		t.Errorf("expected email claim, got %v", claims)
+	}
+	if _, ok := claims["act"]; ok {
+		t.Errorf("expected no act claim without an actor token, got %v", claims)
+	}
+
+	rr, claims = exchange(url.Values{"subject_token": {"upstream"}, "actor_token": {"actor"}, "actor_token_type": {connector.TokenTypeIDToken}})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected exchange with actor to succeed, got %d: %s", rr.Code, rr.Body)
+	}
+	if act, _ := claims["act"].(map[string]interface{}); act["sub"] != subjectFor("0-385-28089-0", "mock") {
+		t.Errorf("expected act claim naming the actor, got %v", claims)
+	}
+
+	for name, tc := range map[string]struct {
+		params  url.Values
+		errType string
+	}{
+		"invalid subject token": {url.Values{"subject_token": {"invalid"}}, errInvalidRequest},
+		"untrusted audience":    {url.Values{"subject_token": {"upstream"}, "audience": {other.ID}}, errInvalidTarget},
+		"refresh token":         {url.Values{"subject_token": {"upstream"}, "scope": {"openid offline_access"}}, errInvalidScope},
+		"actor token type":      {url.Values{"subject_token": {"upstream"}, "actor_token": {"actor"}}, errInvalidRequest},
+	} {
+		rr, _ := exchange(tc.params)
+		var resp struct {
+			Error string `json:"error"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &resp)
+		if rr.Code != http.StatusBadRequest || resp.Error != tc.errType {
+			t.Errorf("%s: expected %s, got %d: %s", name, tc.errType, rr.Code, rr.Body)
+		}
+	}
+
+	// Users must have accepted the terms of service through a browser login.
+	s.terms = TermsOfService{Version: "v2"}
+	rr, _ = exchange(url.Values{"subject_token": {"upstream"}})
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected exchange to be refused without accepted terms, got %d: %s", rr.Code, rr.Body)
+	}
+	if err := s.storage.CreateTermsAcceptance(ctx, storage.TermsAcceptance{UserID: "0-385-28089-0", ConnID: "mock", Version: "v2"}); err != nil {
+		t.Fatal(err)
+	}
+	if rr, _ := exchange(url.Values{"subject_token": {"upstream"}}); rr.Code != http.StatusOK {
+		t.Errorf("expected exchange to succeed once the terms are accepted, got %d: %s", rr.Code, rr.Body)
+	}
+	s.terms = TermsOfService{}
+
+	s.features = nil
+	rr, _ = exchange(url.Values{"subject_token": {"upstream"}})
+	var resp struct {
+		Error string `json:"error"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if rr.Code != http.StatusBadRequest || resp.Error != errUnsupportedGrantType {
+		t.Errorf("expected exchange to be rejected while the feature is disabled, got %d: %s", rr.Code, rr.Body)
+	}
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// tokenMetrics counts token requests per client, so operators can see which
+// clients request tokens and which of them fail.
+type tokenMetrics struct {
+	requests   *prometheus.CounterVec
+	clients    *labelGuard
+	grantTypes *labelGuard
+}
+
+func newTokenMetrics(registry *prometheus.Registry, clients MetricLabelPolicy) (*tokenMetrics, error) {
+	m := &tokenMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "token_requests_total",
+			Help: "Count of token requests by authenticated clients, by client, grant type and status code.",
+		}, []string{"client_id", "grant_type", "code"}),
+		clients: newLabelGuard(clients),
+		// The grant type is user input, only report the ones dex knows.
+		grantTypes: newLabelGuard(MetricLabelPolicy{
+			Allow:     []string{grantTypeAuthorizationCode, grantTypeRefreshToken, grantTypePassword, grantTypeAPIKey, grantTypeJWTBearer, grantTypeClientCredentials, grantTypePreAuthorizedCode},
+			MaxValues: -1,
+		}),
+	}
+	if registry != nil {
+		if err := registry.Register(m.requests); err != nil {
+			return nil, fmt.Errorf("register token metrics: %w", err)
+		}
+	}
+	return m, nil
+}
+
+func (m *tokenMetrics) observe(clientID, grantType string, code int) {
+	m.requests.WithLabelValues(m.clients.value(clientID), m.grantTypes.value(grantType), strconv.Itoa(code)).Inc()
+}
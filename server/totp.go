@@ -0,0 +1,391 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/dexidp/dex/pkg/audit"
+	"github.com/dexidp/dex/pkg/log"
+	"github.com/dexidp/dex/pkg/qrcode"
+	"github.com/dexidp/dex/pkg/totp"
+	"github.com/dexidp/dex/storage"
+)
+
+// TOTP modes.
+const (
+	// TOTPOptional asks users who enrolled an authenticator app for a code
+	// after logging in with their password, and offers to enroll one to
+	// users who didn't.
+	TOTPOptional = "optional"
+	// TOTPRequired requires every user to enroll an authenticator app and
+	// enter its codes.
+	TOTPRequired = "required"
+)
+
+// secondFactorTOTP is the second factor of auth requests which wait for a
+// code of an authenticator app.
+const secondFactorTOTP = "totp"
+
+// backupCodeAlphabet leaves out characters which are easily confused.
+const backupCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// TOTP configures authenticator app codes as a second factor of the local
+// password connector.
+type TOTP struct {
+	// Mode is TOTPOptional or TOTPRequired. An empty mode disables TOTP.
+	Mode string
+	// Issuer is the name authenticator apps show next to the account.
+	// Defaults to "dex".
+	Issuer string
+	// BackupCodes is the number of single use codes users are given when
+	// enrolling, to log in without their app. Defaults to 10.
+	BackupCodes int
+}
+
+// newTOTP validates the TOTP config and sets its defaults. It returns nil if
+// TOTP is disabled.
+func newTOTP(c TOTP) (*TOTP, error) {
+	switch c.Mode {
+	case "":
+		return nil, nil
+	case TOTPOptional, TOTPRequired:
+	default:
+		return nil, fmt.Errorf("unknown TOTP mode %q", c.Mode)
+	}
+	if c.Issuer == "" {
+		c.Issuer = "dex"
+	}
+	if c.BackupCodes < 0 {
+		return nil, fmt.Errorf("invalid number of TOTP backup codes %d", c.BackupCodes)
+	}
+	if c.BackupCodes == 0 {
+		c.BackupCodes = 10
+	}
+	return &c, nil
+}
+
+// totpRequired reports whether the user can't log in without a TOTP code,
+// because they enrolled an app or they're required to enroll one. Grants
+// which can't prompt the user must be refused to such users.
+func (s *Server) totpRequired(ctx context.Context, userID, connID string) (bool, error) {
+	if s.secondFactor(connID) != secondFactorTOTP {
+		return false, nil
+	}
+	if s.totp.Mode == TOTPRequired {
+		return true, nil
+	}
+	secret, err := s.storage.GetTOTPSecret(ctx, userID, connID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return secret.Confirmed, nil
+}
+
+// totpPage is the data the TOTP template is rendered with.
+type totpPage struct {
+	AuthReqID string
+	// Enroll is set if the user has no confirmed secret yet and is asked to
+	// add it to their app.
+	Enroll  bool
+	CanSkip bool
+	Invalid bool
+
+	// The secret to enroll, as a QR code and for typing in.
+	QRCode template.HTML
+	Secret string
+
+	// BackupCodes are shown once, after enrolling, with a link to continue
+	// the login.
+	BackupCodes []string
+	ApprovalURL string
+}
+
+func (s *Server) handleTOTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	authReq, err := s.storage.GetAuthRequest(ctx, r.FormValue("req"))
+	if err != nil {
+		s.logger.Errorf("Failed to get auth request: %v", err)
+		s.renderError(r, w, http.StatusInternalServerError, "Database error.")
+		return
+	}
+	if !authReq.LoggedIn {
+		s.logger.Errorf("Auth request does not have an identity for TOTP")
+		s.renderError(r, w, http.StatusInternalServerError, "Login process not yet finalized.")
+		return
+	}
+	approvalURL := path.Join(s.issuerURL.Path, "/approval") + "?req=" + authReq.ID
+	if authReq.SecondFactor != secondFactorTOTP || s.totp == nil {
+		http.Redirect(w, r, approvalURL, http.StatusSeeOther)
+		return
+	}
+	userID, connID := authReq.Claims.UserID, authReq.ConnectorID
+	secret, err := s.storage.GetTOTPSecret(ctx, userID, connID)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		s.logger.Errorf("Failed to get TOTP secret: %v", err)
+		s.renderError(r, w, http.StatusInternalServerError, "Database error.")
+		return
+	}
+	pending := errors.Is(err, storage.ErrNotFound) || !secret.Confirmed
+	page := totpPage{
+		AuthReqID: authReq.ID,
+		Enroll:    pending,
+		CanSkip:   pending && s.totp.Mode == TOTPOptional,
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if errors.Is(err, storage.ErrNotFound) {
+			// The secret is kept until it's confirmed, so that reloading
+			// the page doesn't invalidate an app which already scanned it.
+			if secret, err = s.newTOTPSecret(ctx, userID, connID); err != nil {
+				s.logger.Errorf("Failed to create TOTP secret: %v", err)
+				s.renderError(r, w, http.StatusInternalServerError, "Database error.")
+				return
+			}
+		}
+		s.renderTOTP(r, w, page, secret, authReq.Claims.Email)
+	case http.MethodPost:
+		if r.FormValue("action") == "skip" {
+			if !page.CanSkip {
+				s.renderError(r, w, http.StatusForbidden, "A code is required to continue.")
+				return
+			}
+			if err := s.passSecondFactor(ctx, authReq.ID); err != nil {
+				s.logger.Errorf("Failed to update auth request: %v", err)
+				s.renderError(r, w, http.StatusInternalServerError, "Database error.")
+				return
+			}
+			http.Redirect(w, r, approvalURL, http.StatusSeeOther)
+			return
+		}
+		if errors.Is(err, storage.ErrNotFound) {
+			// The secret was reset since the page was shown.
+			http.Redirect(w, r, r.URL.Path+"?req="+authReq.ID, http.StatusSeeOther)
+			return
+		}
+
+		var backupCodes []string
+		if pending {
+			backupCodes, err = s.confirmTOTPSecret(r, authReq)
+		} else {
+			err = s.verifyTOTPCode(r, authReq)
+		}
+		if err != nil {
+			if !errors.Is(err, errInvalidTOTPCode) {
+				s.logger.Errorf("TOTP failed: %v", err)
+				s.renderError(r, w, http.StatusInternalServerError, "Database error.")
+				return
+			}
+			s.logger.Infof("TOTP failed for user %q: %v", log.Email(authReq.Claims.Email), err)
+			s.emitAudit(ctx, audit.Event{
+				Type:        audit.EventTOTPFailed,
+				Severity:    audit.SeverityWarning,
+				ClientID:    authReq.ClientID,
+				Subject:     subjectFor(userID, connID),
+				ConnectorID: connID,
+				SourceIPs:   []string{remoteIP(r)},
+				Message:     err.Error(),
+			})
+			s.delayFailure(ctx)
+			page.Invalid = true
+			w.WriteHeader(http.StatusUnauthorized)
+			s.renderTOTP(r, w, page, secret, authReq.Claims.Email)
+			return
+		}
+		if err := s.passSecondFactor(ctx, authReq.ID); err != nil {
+			s.logger.Errorf("Failed to update auth request: %v", err)
+			s.renderError(r, w, http.StatusInternalServerError, "Database error.")
+			return
+		}
+		if len(backupCodes) == 0 {
+			http.Redirect(w, r, approvalURL, http.StatusSeeOther)
+			return
+		}
+		page = totpPage{AuthReqID: authReq.ID, BackupCodes: backupCodes, ApprovalURL: approvalURL}
+		if err := s.templates.totp(r, w, page); err != nil {
+			s.logger.Errorf("Server template error: %v", err)
+		}
+	default:
+		s.renderError(r, w, http.StatusBadRequest, "Unsupported request method.")
+	}
+}
+
+// renderTOTP renders the code form, along with the secret if it's being
+// enrolled.
+func (s *Server) renderTOTP(r *http.Request, w http.ResponseWriter, page totpPage, secret storage.TOTPSecret, account string) {
+	if page.Enroll {
+		code, err := qrcode.Encode([]byte(totp.URI(secret.Secret, s.totp.Issuer, account)))
+		if err != nil {
+			s.logger.Errorf("Failed to encode TOTP QR code: %v", err)
+			s.renderError(r, w, http.StatusInternalServerError, "Internal server error.")
+			return
+		}
+		// The SVG is drawn from the modules alone, no part of the URI ends
+		// up in its markup.
+		page.QRCode = template.HTML(code.SVG()) // nolint: gosec
+		page.Secret = totp.Encode(secret.Secret)
+	}
+	if err := s.templates.totp(r, w, page); err != nil {
+		s.logger.Errorf("Server template error: %v", err)
+	}
+}
+
+func (s *Server) newTOTPSecret(ctx context.Context, userID, connID string) (storage.TOTPSecret, error) {
+	key, err := totp.NewSecret()
+	if err != nil {
+		return storage.TOTPSecret{}, err
+	}
+	secret := storage.TOTPSecret{
+		UserID:    userID,
+		ConnID:    connID,
+		Secret:    key,
+		CreatedAt: s.now(),
+	}
+	if err := s.storage.CreateTOTPSecret(ctx, secret); err != nil {
+		if !errors.Is(err, storage.ErrAlreadyExists) {
+			return storage.TOTPSecret{}, err
+		}
+		// Created by a concurrent request.
+		return s.storage.GetTOTPSecret(ctx, userID, connID)
+	}
+	return secret, nil
+}
+
+// errInvalidTOTPCode is returned for codes which fail verification, as
+// opposed to storage errors.
+var errInvalidTOTPCode = errors.New("invalid code")
+
+// confirmTOTPSecret completes the enrollment of a secret with the first code
+// of the app. It returns the backup codes of the user.
+func (s *Server) confirmTOTPSecret(r *http.Request, authReq storage.AuthRequest) ([]string, error) {
+	ctx := r.Context()
+	code := normalizeTOTPCode(r.PostFormValue("code"))
+	backupCodes := make([]string, s.totp.BackupCodes)
+	hashes := make([][]byte, len(backupCodes))
+	for i := range backupCodes {
+		c, err := newBackupCode()
+		if err != nil {
+			return nil, err
+		}
+		backupCodes[i] = c
+		hashes[i] = hashBackupCode(c)
+	}
+
+	err := s.storage.UpdateTOTPSecret(ctx, authReq.Claims.UserID, authReq.ConnectorID, func(old storage.TOTPSecret) (storage.TOTPSecret, error) {
+		if old.Confirmed {
+			// Confirmed by a concurrent request.
+			return old, errInvalidTOTPCode
+		}
+		step, ok := totp.Validate(old.Secret, code, s.now(), old.LastStep)
+		if !ok {
+			return old, errInvalidTOTPCode
+		}
+		old.Confirmed = true
+		old.LastStep = step
+		old.BackupCodes = hashes
+		return old, nil
+	})
+	if err != nil {
+		if errors.Is(err, errInvalidTOTPCode) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("update TOTP secret: %w", err)
+	}
+	s.logger.Infof("user %q enrolled an authenticator app", log.Email(authReq.Claims.Email))
+	s.emitAudit(ctx, audit.Event{
+		Type:        audit.EventTOTPEnrolled,
+		Severity:    audit.SeverityInfo,
+		ClientID:    authReq.ClientID,
+		Subject:     subjectFor(authReq.Claims.UserID, authReq.ConnectorID),
+		ConnectorID: authReq.ConnectorID,
+		SourceIPs:   []string{remoteIP(r)},
+	})
+	return backupCodes, nil
+}
+
+// verifyTOTPCode checks a code of the app or one of the backup codes. Both
+// are single use.
+func (s *Server) verifyTOTPCode(r *http.Request, authReq storage.AuthRequest) error {
+	ctx := r.Context()
+	code := normalizeTOTPCode(r.PostFormValue("code"))
+	remaining := -1
+	err := s.storage.UpdateTOTPSecret(ctx, authReq.Claims.UserID, authReq.ConnectorID, func(old storage.TOTPSecret) (storage.TOTPSecret, error) {
+		if step, ok := totp.Validate(old.Secret, code, s.now(), old.LastStep); ok {
+			old.LastStep = step
+			return old, nil
+		}
+		hash := hashBackupCode(code)
+		for i, h := range old.BackupCodes {
+			if subtle.ConstantTimeCompare(h, hash) == 1 {
+				codes := make([][]byte, 0, len(old.BackupCodes)-1)
+				codes = append(codes, old.BackupCodes[:i]...)
+				old.BackupCodes = append(codes, old.BackupCodes[i+1:]...)
+				remaining = len(old.BackupCodes)
+				return old, nil
+			}
+		}
+		return old, errInvalidTOTPCode
+	})
+	if err != nil {
+		if errors.Is(err, errInvalidTOTPCode) {
+			return err
+		}
+		return fmt.Errorf("update TOTP secret: %w", err)
+	}
+	if remaining >= 0 {
+		s.logger.Infof("user %q used a backup code, %d left", log.Email(authReq.Claims.Email), remaining)
+		s.emitAudit(ctx, audit.Event{
+			Type:        audit.EventTOTPBackupCodeUsed,
+			Severity:    audit.SeverityInfo,
+			ClientID:    authReq.ClientID,
+			Subject:     subjectFor(authReq.Claims.UserID, authReq.ConnectorID),
+			ConnectorID: authReq.ConnectorID,
+			SourceIPs:   []string{remoteIP(r)},
+			Message:     fmt.Sprintf("%d backup codes left", remaining),
+		})
+	}
+	return nil
+}
+
+// normalizeTOTPCode removes the separators users may type in codes.
+func normalizeTOTPCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer(" ", "", "-", "").Replace(code)
+}
+
+// newBackupCode returns a random backup code, formatted as two groups of
+// five characters.
+func newBackupCode() (string, error) {
+	// Bytes past the largest multiple of the alphabet size are skipped, so
+	// that every character is as likely.
+	limit := 256 - 256%len(backupCodeAlphabet)
+	code := make([]byte, 0, 10)
+	b := make([]byte, 1)
+	for len(code) < cap(code) {
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		if int(b[0]) < limit {
+			code = append(code, backupCodeAlphabet[int(b[0])%len(backupCodeAlphabet)])
+		}
+	}
+	return string(code[:5]) + "-" + string(code[5:]), nil
+}
+
+// hashBackupCode returns the hash backup codes are stored as. Codes are
+// random, so they don't need a slow hash.
+func hashBackupCode(code string) []byte {
+	sum := sha256.Sum256([]byte(normalizeTOTPCode(code)))
+	return sum[:]
+}
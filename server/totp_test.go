@@ -0,0 +1,215 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dexidp/dex/api/v2"
+	"github.com/dexidp/dex/connector"
+	"github.com/dexidp/dex/pkg/audit"
+	"github.com/dexidp/dex/pkg/totp"
+	"github.com/dexidp/dex/storage"
+)
+
+func TestNewTOTP(t *testing.T) {
+	if c, err := newTOTP(TOTP{}); err != nil || c != nil {
+		t.Errorf("expected TOTP to be disabled without a mode, got %+v, %v", c, err)
+	}
+	if _, err := newTOTP(TOTP{Mode: "always"}); err == nil {
+		t.Errorf("expected error for unknown mode")
+	}
+	if _, err := newTOTP(TOTP{Mode: TOTPRequired, BackupCodes: -1}); err == nil {
+		t.Errorf("expected error for negative number of backup codes")
+	}
+	c, err := newTOTP(TOTP{Mode: TOTPOptional})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Issuer != "dex" || c.BackupCodes != 10 {
+		t.Errorf("unexpected defaults %+v", c)
+	}
+}
+
+func TestTOTP(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sink := new(recordingSink)
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.TOTP = TOTP{Mode: TOTPOptional, BackupCodes: 2}
+		c.AuditSink = sink
+	})
+	defer httpServer.Close()
+
+	claims := storage.Claims{UserID: "jane", Email: "jane@example.com"}
+	newAuthRequest := func() storage.AuthRequest {
+		authReq := storage.AuthRequest{
+			ID:            storage.NewID(),
+			ClientID:      "test",
+			ResponseTypes: []string{responseTypeCode},
+			RedirectURI:   "https://example.com/callback",
+			ConnectorID:   LocalConnector,
+			Expiry:        time.Now().Add(time.Hour),
+		}
+		if err := s.storage.CreateAuthRequest(ctx, authReq); err != nil {
+			t.Fatalf("create auth request: %v", err)
+		}
+		ident := connector.Identity{UserID: claims.UserID, Email: claims.Email}
+		if _, err := s.finalizeLogin(ctx, ident, authReq, nil); err != nil {
+			t.Fatalf("finalize login: %v", err)
+		}
+		return authReq
+	}
+	do := func(method, target string, form url.Values) *httptest.ResponseRecorder {
+		var req *http.Request
+		if form != nil {
+			req = httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		} else {
+			req = httptest.NewRequest(method, target, nil)
+		}
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+		return rr
+	}
+	// code returns the code of a step relative to the last one used, or to
+	// the current one before any was used.
+	code := func(offset int64) string {
+		secret, err := s.storage.GetTOTPSecret(ctx, claims.UserID, LocalConnector)
+		if err != nil {
+			t.Fatal(err)
+		}
+		step := secret.LastStep
+		if step == 0 {
+			step = totp.Step(s.now())
+		}
+		return totp.Code(secret.Secret, step+offset)
+	}
+
+	// Without an app, the user is offered to enroll one.
+	authReq := newAuthRequest()
+	rr := do(http.MethodGet, "/approval?req="+authReq.ID, nil)
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/totp?req="+authReq.ID {
+		t.Fatalf("expected redirect to TOTP, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	rr = do(http.MethodGet, "/totp?req="+authReq.ID, nil)
+	page := rr.Body.String()
+	if rr.Code != http.StatusOK || !strings.Contains(page, "<svg") || !strings.Contains(page, "Not now") {
+		t.Fatalf("expected optional enrollment page, got %d: %s", rr.Code, page)
+	}
+	secret, err := s.storage.GetTOTPSecret(ctx, claims.UserID, LocalConnector)
+	if err != nil {
+		t.Fatalf("expected a pending secret: %v", err)
+	}
+	if secret.Confirmed || !strings.Contains(page, totp.Encode(secret.Secret)) {
+		t.Errorf("expected the pending secret to be shown, got %+v", secret)
+	}
+	// Reloading keeps the secret.
+	do(http.MethodGet, "/totp?req="+authReq.ID, nil)
+	if again, _ := s.storage.GetTOTPSecret(ctx, claims.UserID, LocalConnector); string(again.Secret) != string(secret.Secret) {
+		t.Errorf("expected the pending secret to be kept")
+	}
+
+	rr = do(http.MethodPost, "/totp", url.Values{"req": {authReq.ID}, "code": {"000000"}})
+	if rr.Code != http.StatusUnauthorized || !strings.Contains(rr.Body.String(), "Invalid code") {
+		t.Errorf("expected invalid code to be refused, got %d", rr.Code)
+	}
+	if last := sink.events[len(sink.events)-1]; last.Type != audit.EventTOTPFailed {
+		t.Errorf("expected a totp_failed event, got %+v", last)
+	}
+
+	rr = do(http.MethodPost, "/totp", url.Values{"req": {authReq.ID}, "code": {code(0)}})
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Save Your Backup Codes") {
+		t.Fatalf("expected backup codes after enrolling, got %d: %s", rr.Code, rr.Body)
+	}
+	backupCodes := regexp.MustCompile(`<code>([a-z0-9]{5}-[a-z0-9]{5})</code>`).FindAllStringSubmatch(rr.Body.String(), -1)
+	if len(backupCodes) != 2 {
+		t.Fatalf("expected two backup codes, got %q", backupCodes)
+	}
+	if last := sink.events[len(sink.events)-1]; last.Type != audit.EventTOTPEnrolled {
+		t.Errorf("expected a totp_enrolled event, got %+v", last)
+	}
+	rr = do(http.MethodGet, "/approval?req="+authReq.ID, nil)
+	if loc := rr.Header().Get("Location"); !strings.HasPrefix(loc, authReq.RedirectURI) {
+		t.Fatalf("expected redirect to client after enrolling, got %d %q", rr.Code, loc)
+	}
+
+	// Once enrolled, the user must enter a code and can't skip.
+	authReq = newAuthRequest()
+	rr = do(http.MethodGet, "/totp?req="+authReq.ID, nil)
+	if page := rr.Body.String(); strings.Contains(page, "<svg") || strings.Contains(page, "Not now") {
+		t.Errorf("expected code page without enrollment, got %s", page)
+	}
+	if rr := do(http.MethodPost, "/totp", url.Values{"req": {authReq.ID}, "action": {"skip"}}); rr.Code != http.StatusForbidden {
+		t.Errorf("expected skipping to be refused, got %d", rr.Code)
+	}
+	// The code used to enroll can't be replayed.
+	if rr := do(http.MethodPost, "/totp", url.Values{"req": {authReq.ID}, "code": {code(0)}}); rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected used code to be refused, got %d", rr.Code)
+	}
+	rr = do(http.MethodPost, "/totp", url.Values{"req": {authReq.ID}, "code": {code(1)}})
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/approval?req="+authReq.ID {
+		t.Fatalf("expected redirect to approval, got %d: %s", rr.Code, rr.Body)
+	}
+
+	// Backup codes work once, in any case and without the dash.
+	backupCode := strings.ToUpper(strings.Replace(backupCodes[0][1], "-", "", 1))
+	authReq = newAuthRequest()
+	if rr := do(http.MethodPost, "/totp", url.Values{"req": {authReq.ID}, "code": {backupCode}}); rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected backup code to be accepted, got %d", rr.Code)
+	}
+	if last := sink.events[len(sink.events)-1]; last.Type != audit.EventTOTPBackupCodeUsed || last.Message != "1 backup codes left" {
+		t.Errorf("expected a totp_backup_code_used event, got %+v", last)
+	}
+	authReq = newAuthRequest()
+	if rr := do(http.MethodPost, "/totp", url.Values{"req": {authReq.ID}, "code": {backupCode}}); rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected used backup code to be refused, got %d", rr.Code)
+	}
+
+	// Enrolled users can't use the password grant.
+	if required, err := s.totpRequired(ctx, claims.UserID, LocalConnector); err != nil || !required {
+		t.Errorf("expected TOTP to be required once enrolled, got %v, %v", required, err)
+	}
+	if required, err := s.totpRequired(ctx, "john", LocalConnector); err != nil || required {
+		t.Errorf("expected TOTP to be optional for users without an app, got %v, %v", required, err)
+	}
+}
+
+func TestTOTPAPI(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, nil)
+	defer httpServer.Close()
+
+	if err := s.storage.CreatePassword(ctx, storage.Password{Email: "jane@example.com", UserID: "jane", Hash: []byte("$2a$10$")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.storage.CreateTOTPSecret(ctx, storage.TOTPSecret{UserID: "jane", ConnID: LocalConnector, Secret: []byte("secret"), Confirmed: true}); err != nil {
+		t.Fatal(err)
+	}
+	a := NewAPI(s.storage, logger, nil, nil, nil, 0)
+
+	resp, err := a.ResetTOTP(ctx, &api.ResetTOTPReq{Email: "jane@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.NotFound {
+		t.Errorf("expected the user's secret to be found")
+	}
+	if _, err := s.storage.GetTOTPSecret(ctx, "jane", LocalConnector); err != storage.ErrNotFound {
+		t.Errorf("expected the secret to be deleted, got %v", err)
+	}
+	if resp, _ := a.ResetTOTP(ctx, &api.ResetTOTPReq{Email: "jane@example.com"}); !resp.NotFound {
+		t.Errorf("expected reset without a secret not to find it")
+	}
+	if resp, _ := a.ResetTOTP(ctx, &api.ResetTOTPReq{Email: "john@example.com"}); !resp.NotFound {
+		t.Errorf("expected unknown user not to be found")
+	}
+}
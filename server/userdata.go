@@ -0,0 +1,359 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dexidp/dex/api/v2"
+	"github.com/dexidp/dex/pkg/audit"
+	"github.com/dexidp/dex/server/internal"
+	"github.com/dexidp/dex/storage"
+)
+
+// userData is the JSON document returned by ExportUserData. It deliberately
+// leaves out credentials: password hashes, refresh tokens, session cookies
+// and the data connectors keep to refresh upstream sessions.
+type userData struct {
+	Subject     string `json:"subject"`
+	UserID      string `json:"userID"`
+	ConnectorID string `json:"connectorID"`
+
+	Password        *userDataPassword        `json:"password,omitempty"`
+	OfflineSession  *userDataOfflineSession  `json:"offlineSession,omitempty"`
+	RefreshTokens   []userDataRefreshToken   `json:"refreshTokens"`
+	Sessions        []userDataSession        `json:"sessions"`
+	TermsAcceptance *storage.TermsAcceptance `json:"termsAcceptance,omitempty"`
+	AuditEvents     []storage.AuditEvent     `json:"auditEvents"`
+}
+
+type userDataPassword struct {
+	Email    string `json:"email"`
+	Username string `json:"username"`
+}
+
+type userDataOfflineSession struct {
+	Clients []string `json:"clients"`
+}
+
+type userDataRefreshToken struct {
+	ID         string         `json:"id"`
+	ClientID   string         `json:"clientID"`
+	Scopes     []string       `json:"scopes"`
+	Claims     storage.Claims `json:"claims"`
+	CreatedAt  time.Time      `json:"createdAt"`
+	LastUsed   time.Time      `json:"lastUsed"`
+	LastUsedIP string         `json:"lastUsedIP,omitempty"`
+}
+
+type userDataSession struct {
+	Claims    storage.Claims `json:"claims"`
+	ClientIDs []string       `json:"clientIDs"`
+	CreatedAt time.Time      `json:"createdAt"`
+	Expiry    time.Time      `json:"expiry"`
+}
+
+func (d dexAPI) ExportUserData(ctx context.Context, req *api.ExportUserDataReq) (*api.ExportUserDataResp, error) {
+	id := new(internal.IDTokenSubject)
+	if err := internal.Unmarshal(req.Subject, id); err != nil {
+		return nil, fmt.Errorf("invalid subject: %w", err)
+	}
+
+	data := userData{
+		Subject:       req.Subject,
+		UserID:        id.UserId,
+		ConnectorID:   id.ConnId,
+		RefreshTokens: []userDataRefreshToken{},
+		Sessions:      []userDataSession{},
+	}
+	found := false
+
+	p, err := d.localPassword(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if p != nil {
+		data.Password = &userDataPassword{Email: p.Email, Username: p.Username}
+		found = true
+	}
+
+	session, err := d.s.GetOfflineSessions(ctx, id.UserId, id.ConnId)
+	switch {
+	case err == nil:
+		data.OfflineSession = &userDataOfflineSession{Clients: []string{}}
+		for clientID := range session.Refresh {
+			data.OfflineSession.Clients = append(data.OfflineSession.Clients, clientID)
+		}
+		found = true
+	case !errors.Is(err, storage.ErrNotFound):
+		d.logger.Errorf("api: failed to get offline session: %v", err)
+		return nil, fmt.Errorf("get offline session: %w", err)
+	}
+
+	tokens, err := d.userRefreshTokens(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tokens {
+		data.RefreshTokens = append(data.RefreshTokens, userDataRefreshToken{
+			ID:         t.ID,
+			ClientID:   t.ClientID,
+			Scopes:     t.Scopes,
+			Claims:     t.Claims,
+			CreatedAt:  t.CreatedAt,
+			LastUsed:   t.LastUsed,
+			LastUsedIP: t.LastUsedIP,
+		})
+		found = true
+	}
+
+	sessions, err := d.userSessions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range sessions {
+		data.Sessions = append(data.Sessions, userDataSession{
+			Claims:    s.Claims,
+			ClientIDs: s.ClientIDs,
+			CreatedAt: s.CreatedAt,
+			Expiry:    s.Expiry,
+		})
+		found = true
+	}
+
+	terms, err := d.s.GetTermsAcceptance(ctx, id.UserId, id.ConnId)
+	switch {
+	case err == nil:
+		data.TermsAcceptance = &terms
+		found = true
+	case !errors.Is(err, storage.ErrNotFound):
+		d.logger.Errorf("api: failed to get terms acceptance: %v", err)
+		return nil, fmt.Errorf("get terms acceptance: %w", err)
+	}
+
+	data.AuditEvents, err = d.userAuditEvents(ctx, req.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if len(data.AuditEvents) > 0 {
+		found = true
+	} else {
+		data.AuditEvents = []storage.AuditEvent{}
+	}
+
+	if !found {
+		return &api.ExportUserDataResp{NotFound: true}, nil
+	}
+	b, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshal user data: %w", err)
+	}
+	return &api.ExportUserDataResp{Data: b}, nil
+}
+
+func (d dexAPI) EraseUserData(ctx context.Context, req *api.EraseUserDataReq) (*api.EraseUserDataResp, error) {
+	id := new(internal.IDTokenSubject)
+	if err := internal.Unmarshal(req.Subject, id); err != nil {
+		return nil, fmt.Errorf("invalid subject: %w", err)
+	}
+
+	var erased []string
+
+	tokens, err := d.userRefreshTokens(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tokens {
+		if err := d.s.DeleteRefresh(ctx, t.ID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			d.logger.Errorf("api: failed to delete refresh token: %v", err)
+			return nil, fmt.Errorf("delete refresh token: %w", err)
+		}
+	}
+	if len(tokens) > 0 {
+		erased = append(erased, fmt.Sprintf("%d refresh tokens", len(tokens)))
+	}
+
+	sessions, err := d.userSessions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range sessions {
+		if err := d.s.DeleteSession(ctx, s.ID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			d.logger.Errorf("api: failed to delete session: %v", err)
+			return nil, fmt.Errorf("delete session: %w", err)
+		}
+	}
+	if len(sessions) > 0 {
+		erased = append(erased, fmt.Sprintf("%d sessions", len(sessions)))
+	}
+
+	switch err := d.s.DeleteOfflineSessions(ctx, id.UserId, id.ConnId); {
+	case err == nil:
+		erased = append(erased, "offline session")
+	case !errors.Is(err, storage.ErrNotFound):
+		d.logger.Errorf("api: failed to delete offline session: %v", err)
+		return nil, fmt.Errorf("delete offline session: %w", err)
+	}
+
+	switch err := d.s.DeleteTermsAcceptance(ctx, id.UserId, id.ConnId); {
+	case err == nil:
+		erased = append(erased, "terms acceptance")
+	case !errors.Is(err, storage.ErrNotFound):
+		d.logger.Errorf("api: failed to delete terms acceptance: %v", err)
+		return nil, fmt.Errorf("delete terms acceptance: %w", err)
+	}
+
+	creds, err := d.s.ListWebAuthnCredentials(ctx, id.UserId, id.ConnId)
+	if err != nil {
+		d.logger.Errorf("api: failed to list webauthn credentials: %v", err)
+		return nil, fmt.Errorf("list webauthn credentials: %w", err)
+	}
+	for _, c := range creds {
+		if err := d.s.DeleteWebAuthnCredential(ctx, c.ID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			d.logger.Errorf("api: failed to delete webauthn credential: %v", err)
+			return nil, fmt.Errorf("delete webauthn credential: %w", err)
+		}
+	}
+	if len(creds) > 0 {
+		erased = append(erased, fmt.Sprintf("%d passkeys", len(creds)))
+	}
+
+	switch err := d.s.DeleteTOTPSecret(ctx, id.UserId, id.ConnId); {
+	case err == nil:
+		erased = append(erased, "TOTP secret")
+	case !errors.Is(err, storage.ErrNotFound):
+		d.logger.Errorf("api: failed to delete TOTP secret: %v", err)
+		return nil, fmt.Errorf("delete TOTP secret: %w", err)
+	}
+
+	p, err := d.localPassword(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if p != nil {
+		if err := d.s.DeletePassword(ctx, p.Email); err != nil {
+			d.logger.Errorf("api: failed to delete password: %v", err)
+			return nil, fmt.Errorf("delete password: %w", err)
+		}
+		erased = append(erased, "password")
+	}
+
+	// Audit events stay, so security investigations keep working, but are
+	// detached from the user. The erasure ID links them to the event
+	// recording the erasure.
+	erasureID := "erased-" + storage.NewID()
+	events, err := d.userAuditEvents(ctx, req.Subject)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range events {
+		err := d.s.UpdateAuditEvent(ctx, e.ID, func(old storage.AuditEvent) (storage.AuditEvent, error) {
+			old.Subject = erasureID
+			old.SourceIPs = nil
+			return old, nil
+		})
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			d.logger.Errorf("api: failed to anonymize audit event: %v", err)
+			return nil, fmt.Errorf("anonymize audit event: %w", err)
+		}
+	}
+	if len(events) > 0 {
+		erased = append(erased, fmt.Sprintf("%d audit events anonymized", len(events)))
+	}
+
+	if len(erased) == 0 {
+		return &api.EraseUserDataResp{NotFound: true}, nil
+	}
+
+	e := audit.Event{
+		Type:        audit.EventUserDataErased,
+		Severity:    audit.SeverityInfo,
+		Time:        time.Now(),
+		Subject:     erasureID,
+		ConnectorID: id.ConnId,
+		Message:     "erased user data: " + strings.Join(erased, ", "),
+	}
+	if err := d.audit.Emit(ctx, e); err != nil {
+		d.logger.Errorf("api: failed to emit audit event %q: %v", e.Type, err)
+	}
+	// The erasure is always recorded in the storage, even if audit events
+	// aren't persisted otherwise.
+	if err := (storageSink{d.s}).Emit(ctx, e); err != nil {
+		d.logger.Errorf("api: failed to store erasure record: %v", err)
+		return nil, fmt.Errorf("store erasure record: %w", err)
+	}
+	return &api.EraseUserDataResp{ErasureId: erasureID}, nil
+}
+
+// localPassword returns the password entry of a user of the local password
+// database, or nil if the user logs in through another connector.
+func (d dexAPI) localPassword(ctx context.Context, id *internal.IDTokenSubject) (*storage.Password, error) {
+	conn, err := d.s.GetConnector(ctx, id.ConnId)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, nil
+		}
+		d.logger.Errorf("api: failed to get connector: %v", err)
+		return nil, fmt.Errorf("get connector: %w", err)
+	}
+	if conn.Type != LocalConnector {
+		return nil, nil
+	}
+	passwords, err := d.s.ListPasswords(ctx)
+	if err != nil {
+		d.logger.Errorf("api: failed to list passwords: %v", err)
+		return nil, fmt.Errorf("list passwords: %w", err)
+	}
+	for _, p := range passwords {
+		if p.UserID == id.UserId {
+			return &p, nil
+		}
+	}
+	return nil, nil
+}
+
+// userRefreshTokens returns all refresh tokens issued to the user, including
+// ones no longer referenced by the user's offline session.
+func (d dexAPI) userRefreshTokens(ctx context.Context, id *internal.IDTokenSubject) ([]storage.RefreshToken, error) {
+	all, err := d.s.ListRefreshTokens(ctx)
+	if err != nil {
+		d.logger.Errorf("api: failed to list refresh tokens: %v", err)
+		return nil, fmt.Errorf("list refresh tokens: %w", err)
+	}
+	var tokens []storage.RefreshToken
+	for _, t := range all {
+		if t.Claims.UserID == id.UserId && t.ConnectorID == id.ConnId {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens, nil
+}
+
+// userSessions returns the user's logins to dex.
+func (d dexAPI) userSessions(ctx context.Context, id *internal.IDTokenSubject) ([]storage.Session, error) {
+	all, err := d.s.ListSessions(ctx)
+	if err != nil {
+		d.logger.Errorf("api: failed to list sessions: %v", err)
+		return nil, fmt.Errorf("list sessions: %w", err)
+	}
+	var sessions []storage.Session
+	for _, s := range all {
+		if s.Claims.UserID == id.UserId && s.ConnectorID == id.ConnId {
+			sessions = append(sessions, s)
+		}
+	}
+	return sessions, nil
+}
+
+// userAuditEvents returns the persisted audit events of the user.
+func (d dexAPI) userAuditEvents(ctx context.Context, subject string) ([]storage.AuditEvent, error) {
+	events, err := d.s.ListAuditEvents(ctx, storage.AuditEventFilter{Subject: subject})
+	if err != nil {
+		d.logger.Errorf("api: failed to list audit events: %v", err)
+		return nil, fmt.Errorf("list audit events: %w", err)
+	}
+	return events, nil
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/dexidp/dex/api/v2"
+	"github.com/dexidp/dex/pkg/audit"
+	"github.com/dexidp/dex/server/internal"
+	"github.com/dexidp/dex/storage"
+	"github.com/dexidp/dex/storage/memory"
+)
+
+func TestUserDataExportAndErasure(t *testing.T) {
+	ctx := context.Background()
+	s := memory.New(logger)
+	client := newAPI(s, logger, t)
+	defer client.Close()
+
+	subject, err := internal.Marshal(&internal.IDTokenSubject{UserId: "user1", ConnId: "local"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+
+	mustCreate := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	mustCreate(s.CreateConnector(ctx, storage.Connector{ID: "local", Type: LocalConnector, Name: "Email"}))
+	mustCreate(s.CreatePassword(ctx, storage.Password{Email: "jane@example.com", Hash: hash, Username: "jane", UserID: "user1"}))
+	mustCreate(s.CreatePassword(ctx, storage.Password{Email: "joe@example.com", Hash: hash, Username: "joe", UserID: "user2"}))
+	mustCreate(s.CreateRefresh(ctx, storage.RefreshToken{
+		ID: "refresh1", Token: "refresh-secret", ClientID: "app", ConnectorID: "local",
+		Claims: storage.Claims{UserID: "user1", Email: "jane@example.com"}, CreatedAt: now, LastUsed: now,
+	}))
+	mustCreate(s.CreateRefresh(ctx, storage.RefreshToken{
+		ID: "refresh2", Token: "other-secret", ClientID: "app", ConnectorID: "local",
+		Claims: storage.Claims{UserID: "user2"}, CreatedAt: now, LastUsed: now,
+	}))
+	mustCreate(s.CreateOfflineSessions(ctx, storage.OfflineSessions{
+		UserID: "user1", ConnID: "local",
+		Refresh: map[string]*storage.RefreshTokenRef{"app": {ID: "refresh1", ClientID: "app"}},
+	}))
+	mustCreate(s.CreateSession(ctx, storage.Session{
+		ID: "session-secret", ConnectorID: "local", Claims: storage.Claims{UserID: "user1"},
+		ClientIDs: []string{"app"}, CreatedAt: now, Expiry: now.Add(time.Hour),
+	}))
+	mustCreate(s.CreateSession(ctx, storage.Session{
+		ID: "session2", ConnectorID: "local", Claims: storage.Claims{UserID: "user2"},
+		CreatedAt: now, Expiry: now.Add(time.Hour),
+	}))
+	mustCreate(s.CreateTermsAcceptance(ctx, storage.TermsAcceptance{UserID: "user1", ConnID: "local", Version: "v1", AcceptedAt: now}))
+	mustCreate(s.CreateAuditEvent(ctx, storage.AuditEvent{
+		ID: storage.NewID(), Type: audit.EventLogin, Time: now, Subject: subject, SourceIPs: []string{"192.0.2.1"},
+	}))
+
+	exported, err := client.ExportUserData(ctx, &api.ExportUserDataReq{Subject: subject})
+	if err != nil {
+		t.Fatalf("export user data: %v", err)
+	}
+	if exported.NotFound {
+		t.Fatal("expected user data to be found")
+	}
+	for _, secret := range []string{"refresh-secret", string(hash), "joe@example.com", "refresh2", "session-secret"} {
+		if strings.Contains(string(exported.Data), secret) {
+			t.Errorf("export contains %q: %s", secret, exported.Data)
+		}
+	}
+	var data userData
+	if err := json.Unmarshal(exported.Data, &data); err != nil {
+		t.Fatalf("unmarshal export: %v", err)
+	}
+	if data.Password == nil || data.Password.Email != "jane@example.com" {
+		t.Errorf("expected the local password entry, got %+v", data.Password)
+	}
+	if len(data.RefreshTokens) != 1 || data.RefreshTokens[0].ID != "refresh1" {
+		t.Errorf("expected the user's refresh token, got %+v", data.RefreshTokens)
+	}
+	if len(data.Sessions) != 1 || data.Sessions[0].ClientIDs[0] != "app" {
+		t.Errorf("expected the user's session, got %+v", data.Sessions)
+	}
+	if data.OfflineSession == nil || data.TermsAcceptance == nil || len(data.AuditEvents) != 1 {
+		t.Errorf("incomplete export: %s", exported.Data)
+	}
+
+	erased, err := client.EraseUserData(ctx, &api.EraseUserDataReq{Subject: subject})
+	if err != nil {
+		t.Fatalf("erase user data: %v", err)
+	}
+	if erased.NotFound || erased.ErasureId == "" {
+		t.Fatalf("unexpected erasure response %+v", erased)
+	}
+
+	if _, err := s.GetRefresh(ctx, "refresh1"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected refresh token to be deleted, got %v", err)
+	}
+	if _, err := s.GetRefresh(ctx, "refresh2"); err != nil {
+		t.Errorf("refresh token of another user was deleted: %v", err)
+	}
+	if _, err := s.GetSession(ctx, "session-secret"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected session to be deleted, got %v", err)
+	}
+	if _, err := s.GetSession(ctx, "session2"); err != nil {
+		t.Errorf("session of another user was deleted: %v", err)
+	}
+	if _, err := s.GetOfflineSessions(ctx, "user1", "local"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected offline session to be deleted, got %v", err)
+	}
+	if _, err := s.GetTermsAcceptance(ctx, "user1", "local"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected terms acceptance to be deleted, got %v", err)
+	}
+	if _, err := s.GetPassword(ctx, "jane@example.com"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected password to be deleted, got %v", err)
+	}
+	if _, err := s.GetPassword(ctx, "joe@example.com"); err != nil {
+		t.Errorf("password of another user was deleted: %v", err)
+	}
+
+	events, err := s.ListAuditEvents(ctx, storage.AuditEventFilter{Subject: erased.ErasureId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected the anonymized login and the erasure record, got %+v", events)
+	}
+	if events[0].Type != audit.EventUserDataErased {
+		t.Errorf("expected the erasure to be recorded last, got %q", events[0].Type)
+	}
+	if events[1].Type != audit.EventLogin || len(events[1].SourceIPs) != 0 {
+		t.Errorf("expected the login event to be anonymized, got %+v", events[1])
+	}
+
+	exported, err = client.ExportUserData(ctx, &api.ExportUserDataReq{Subject: subject})
+	if err != nil {
+		t.Fatalf("export user data: %v", err)
+	}
+	if !exported.NotFound {
+		t.Errorf("expected no data to be left, got %s", exported.Data)
+	}
+	erased, err = client.EraseUserData(ctx, &api.EraseUserDataReq{Subject: subject})
+	if err != nil {
+		t.Fatalf("erase user data: %v", err)
+	}
+	if !erased.NotFound {
+		t.Errorf("expected a second erasure to find nothing, got %+v", erased)
+	}
+}
@@ -0,0 +1,367 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+
+	"github.com/dexidp/dex/pkg/audit"
+	"github.com/dexidp/dex/pkg/log"
+	"github.com/dexidp/dex/pkg/webauthn"
+	"github.com/dexidp/dex/storage"
+)
+
+// WebAuthn modes.
+const (
+	// WebAuthnOptional asks users who registered a passkey to use it after
+	// logging in with their password, and offers to register one to users
+	// who didn't.
+	WebAuthnOptional = "optional"
+	// WebAuthnRequired requires every user to register and use a passkey.
+	WebAuthnRequired = "required"
+)
+
+// secondFactorWebAuthn is the second factor of auth requests which wait for
+// a WebAuthn assertion or registration.
+const secondFactorWebAuthn = "webauthn"
+
+// WebAuthn configures passkeys and security keys as a second factor of the
+// local password connector.
+type WebAuthn struct {
+	// Mode is WebAuthnOptional or WebAuthnRequired. An empty mode disables
+	// WebAuthn.
+	Mode string
+	// RPID is the relying party ID passkeys are bound to. Defaults to the
+	// host name of the issuer.
+	RPID string
+	// RPName is the name authenticators show when registering. Defaults to
+	// "dex".
+	RPName string
+	// Origins the browser may report for the login page. Defaults to the
+	// origin of the issuer.
+	Origins []string
+	// If set, authenticators must verify the user, for example with a PIN
+	// or biometrics, and not just their presence.
+	RequireUserVerification bool
+}
+
+// newWebAuthn validates the WebAuthn config and sets its defaults. It returns
+// nil if WebAuthn is disabled.
+func newWebAuthn(c WebAuthn, issuerURL *url.URL) (*WebAuthn, error) {
+	switch c.Mode {
+	case "":
+		return nil, nil
+	case WebAuthnOptional, WebAuthnRequired:
+	default:
+		return nil, fmt.Errorf("unknown WebAuthn mode %q", c.Mode)
+	}
+	if c.RPID == "" {
+		c.RPID = issuerURL.Hostname()
+	}
+	if c.RPName == "" {
+		c.RPName = "dex"
+	}
+	if len(c.Origins) == 0 {
+		c.Origins = []string{issuerURL.Scheme + "://" + issuerURL.Host}
+	}
+	return &c, nil
+}
+
+func (c *WebAuthn) relyingParty() webauthn.RelyingParty {
+	return webauthn.RelyingParty{
+		ID:                      c.RPID,
+		Origins:                 c.Origins,
+		RequireUserVerification: c.RequireUserVerification,
+	}
+}
+
+// secondFactor returns the second factor users of the connector are prompted
+// for after logging in, or an empty string if there is none.
+func (s *Server) secondFactor(connID string) string {
+	if connID != LocalConnector {
+		return ""
+	}
+	switch {
+	case s.webAuthn != nil:
+		return secondFactorWebAuthn
+	case s.totp != nil:
+		return secondFactorTOTP
+	}
+	return ""
+}
+
+// webAuthnRequired reports whether the user can't log in without a passkey,
+// because one is registered or they're required to register one. Grants
+// which can't prompt the user must be refused to such users.
+func (s *Server) webAuthnRequired(ctx context.Context, userID, connID string) (bool, error) {
+	if s.secondFactor(connID) != secondFactorWebAuthn {
+		return false, nil
+	}
+	if s.webAuthn.Mode == WebAuthnRequired {
+		return true, nil
+	}
+	creds, err := s.storage.ListWebAuthnCredentials(ctx, userID, connID)
+	return len(creds) > 0, err
+}
+
+func (s *Server) handleWebAuthn(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	authReq, err := s.storage.GetAuthRequest(ctx, r.FormValue("req"))
+	if err != nil {
+		s.logger.Errorf("Failed to get auth request: %v", err)
+		s.renderError(r, w, http.StatusInternalServerError, "Database error.")
+		return
+	}
+	if !authReq.LoggedIn {
+		s.logger.Errorf("Auth request does not have an identity for WebAuthn")
+		s.renderError(r, w, http.StatusInternalServerError, "Login process not yet finalized.")
+		return
+	}
+	approvalURL := path.Join(s.issuerURL.Path, "/approval") + "?req=" + authReq.ID
+	if authReq.SecondFactor != secondFactorWebAuthn || s.webAuthn == nil {
+		http.Redirect(w, r, approvalURL, http.StatusSeeOther)
+		return
+	}
+	creds, err := s.storage.ListWebAuthnCredentials(ctx, authReq.Claims.UserID, authReq.ConnectorID)
+	if err != nil {
+		s.logger.Errorf("Failed to list WebAuthn credentials: %v", err)
+		s.renderError(r, w, http.StatusInternalServerError, "Database error.")
+		return
+	}
+	// Users can only register their first passkey at login, others must be
+	// registered after using an existing one.
+	register := len(creds) == 0
+	canSkip := register && s.webAuthn.Mode == WebAuthnOptional
+
+	switch r.Method {
+	case http.MethodGet:
+		challenge := make([]byte, 32)
+		if _, err := rand.Read(challenge); err != nil {
+			s.logger.Errorf("Failed to generate WebAuthn challenge: %v", err)
+			s.renderError(r, w, http.StatusInternalServerError, "Internal server error.")
+			return
+		}
+		if err := s.storage.UpdateAuthRequest(ctx, authReq.ID, func(a storage.AuthRequest) (storage.AuthRequest, error) {
+			a.SecondFactorChallenge = challenge
+			return a, nil
+		}); err != nil {
+			s.logger.Errorf("Failed to store WebAuthn challenge: %v", err)
+			s.renderError(r, w, http.StatusInternalServerError, "Database error.")
+			return
+		}
+		credIDs := make([]string, len(creds))
+		for i, c := range creds {
+			credIDs[i] = c.ID
+		}
+		data := webAuthnPage{
+			AuthReqID:     authReq.ID,
+			Register:      register,
+			CanSkip:       canSkip,
+			Challenge:     base64.RawURLEncoding.EncodeToString(challenge),
+			RPID:          s.webAuthn.RPID,
+			RPName:        s.webAuthn.RPName,
+			UserID:        base64.RawURLEncoding.EncodeToString([]byte(authReq.Claims.UserID)),
+			Username:      authReq.Claims.Email,
+			CredentialIDs: credIDs,
+		}
+		if err := s.templates.webAuthn(r, w, data); err != nil {
+			s.logger.Errorf("Server template error: %v", err)
+		}
+	case http.MethodPost:
+		if r.FormValue("action") == "skip" {
+			if !canSkip {
+				s.renderError(r, w, http.StatusForbidden, "A passkey is required to continue.")
+				return
+			}
+			if err := s.passSecondFactor(ctx, authReq.ID); err != nil {
+				s.logger.Errorf("Failed to update auth request: %v", err)
+				s.renderError(r, w, http.StatusInternalServerError, "Database error.")
+				return
+			}
+			http.Redirect(w, r, approvalURL, http.StatusSeeOther)
+			return
+		}
+
+		// Challenges are single use: take it off the auth request before
+		// verifying the response, so it can't be replayed.
+		var challenge []byte
+		if err := s.storage.UpdateAuthRequest(ctx, authReq.ID, func(a storage.AuthRequest) (storage.AuthRequest, error) {
+			challenge = a.SecondFactorChallenge
+			a.SecondFactorChallenge = nil
+			return a, nil
+		}); err != nil {
+			s.logger.Errorf("Failed to update auth request: %v", err)
+			s.renderError(r, w, http.StatusInternalServerError, "Database error.")
+			return
+		}
+
+		if register {
+			err = s.registerWebAuthnCredential(r, authReq, challenge)
+		} else {
+			err = s.verifyWebAuthnAssertion(r, authReq, challenge)
+		}
+		if err != nil {
+			var vErr *webAuthnError
+			if !errors.As(err, &vErr) {
+				s.logger.Errorf("WebAuthn failed: %v", err)
+				s.renderError(r, w, http.StatusInternalServerError, "Database error.")
+				return
+			}
+			s.logger.Infof("WebAuthn failed for user %q: %v", log.Email(authReq.Claims.Email), vErr.err)
+			s.emitAudit(ctx, audit.Event{
+				Type:        audit.EventWebAuthnFailed,
+				Severity:    vErr.severity,
+				ClientID:    authReq.ClientID,
+				Subject:     subjectFor(authReq.Claims.UserID, authReq.ConnectorID),
+				ConnectorID: authReq.ConnectorID,
+				SourceIPs:   []string{remoteIP(r)},
+				Message:     vErr.err.Error(),
+			})
+			s.delayFailure(ctx)
+			s.renderError(r, w, http.StatusUnauthorized, "Passkey verification failed.")
+			return
+		}
+		if err := s.passSecondFactor(ctx, authReq.ID); err != nil {
+			s.logger.Errorf("Failed to update auth request: %v", err)
+			s.renderError(r, w, http.StatusInternalServerError, "Database error.")
+			return
+		}
+		http.Redirect(w, r, approvalURL, http.StatusSeeOther)
+	default:
+		s.renderError(r, w, http.StatusBadRequest, "Unsupported request method.")
+	}
+}
+
+// webAuthnPage is the data the WebAuthn template is rendered with.
+type webAuthnPage struct {
+	AuthReqID string
+	// Register is set if the user has no passkey yet and is asked to
+	// register one instead of using it.
+	Register bool
+	CanSkip  bool
+
+	// Parameters of the ceremony, base64url encoded where binary.
+	Challenge     string
+	RPID          string
+	RPName        string
+	UserID        string
+	Username      string
+	CredentialIDs []string
+}
+
+// webAuthnError is returned for responses which fail verification, as
+// opposed to storage errors.
+type webAuthnError struct {
+	err      error
+	severity audit.Severity
+}
+
+func (e *webAuthnError) Error() string {
+	return e.err.Error()
+}
+
+func invalidWebAuthn(err error) error {
+	return &webAuthnError{err, audit.SeverityWarning}
+}
+
+// passSecondFactor lets the auth request continue to the approval screen.
+func (s *Server) passSecondFactor(ctx context.Context, authReqID string) error {
+	return s.storage.UpdateAuthRequest(ctx, authReqID, func(a storage.AuthRequest) (storage.AuthRequest, error) {
+		a.SecondFactor = ""
+		a.SecondFactorChallenge = nil
+		return a, nil
+	})
+}
+
+func (s *Server) registerWebAuthnCredential(r *http.Request, authReq storage.AuthRequest, challenge []byte) error {
+	ctx := r.Context()
+	clientData, err1 := base64.RawURLEncoding.DecodeString(r.PostFormValue("client_data"))
+	attestation, err2 := base64.RawURLEncoding.DecodeString(r.PostFormValue("attestation_object"))
+	if err1 != nil || err2 != nil {
+		return invalidWebAuthn(errors.New("malformed registration"))
+	}
+	cred, err := s.webAuthn.relyingParty().VerifyRegistration(challenge, clientData, attestation)
+	if err != nil {
+		return invalidWebAuthn(err)
+	}
+
+	now := s.now()
+	c := storage.WebAuthnCredential{
+		ID:          base64.RawURLEncoding.EncodeToString(cred.ID),
+		UserID:      authReq.Claims.UserID,
+		ConnectorID: authReq.ConnectorID,
+		PublicKey:   cred.PublicKey,
+		SignCount:   cred.SignCount,
+		CreatedAt:   now,
+		LastUsed:    now,
+	}
+	if err := s.storage.CreateWebAuthnCredential(ctx, c); err != nil {
+		if errors.Is(err, storage.ErrAlreadyExists) {
+			return invalidWebAuthn(errors.New("credential is already registered"))
+		}
+		return fmt.Errorf("create WebAuthn credential: %w", err)
+	}
+	s.logger.Infof("user %q registered a passkey", log.Email(authReq.Claims.Email))
+	s.emitAudit(ctx, audit.Event{
+		Type:        audit.EventWebAuthnRegistered,
+		Severity:    audit.SeverityInfo,
+		ClientID:    authReq.ClientID,
+		Subject:     subjectFor(authReq.Claims.UserID, authReq.ConnectorID),
+		ConnectorID: authReq.ConnectorID,
+		SourceIPs:   []string{remoteIP(r)},
+	})
+	return nil
+}
+
+func (s *Server) verifyWebAuthnAssertion(r *http.Request, authReq storage.AuthRequest, challenge []byte) error {
+	ctx := r.Context()
+	credID, err1 := base64.RawURLEncoding.DecodeString(r.PostFormValue("credential_id"))
+	clientData, err2 := base64.RawURLEncoding.DecodeString(r.PostFormValue("client_data"))
+	authData, err3 := base64.RawURLEncoding.DecodeString(r.PostFormValue("authenticator_data"))
+	sig, err4 := base64.RawURLEncoding.DecodeString(r.PostFormValue("signature"))
+	if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
+		return invalidWebAuthn(errors.New("malformed assertion"))
+	}
+	id := base64.RawURLEncoding.EncodeToString(credID)
+	cred, err := s.storage.GetWebAuthnCredential(ctx, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return invalidWebAuthn(errors.New("unknown credential"))
+		}
+		return fmt.Errorf("get WebAuthn credential: %w", err)
+	}
+	if cred.UserID != authReq.Claims.UserID || cred.ConnectorID != authReq.ConnectorID {
+		return invalidWebAuthn(errors.New("credential belongs to another user"))
+	}
+	signCount, err := s.webAuthn.relyingParty().VerifyAssertion(challenge, cred.PublicKey, clientData, authData, sig)
+	if err != nil {
+		return invalidWebAuthn(err)
+	}
+
+	// A counter which doesn't increase indicates the authenticator was
+	// cloned. Authenticators which don't count always report zero.
+	err = s.storage.UpdateWebAuthnCredential(ctx, id, func(old storage.WebAuthnCredential) (storage.WebAuthnCredential, error) {
+		if (signCount != 0 || old.SignCount != 0) && signCount <= old.SignCount {
+			return old, &webAuthnError{
+				fmt.Errorf("signature counter %d did not exceed %d, the authenticator may have been cloned", signCount, old.SignCount),
+				audit.SeverityHigh,
+			}
+		}
+		old.SignCount = signCount
+		old.LastUsed = s.now()
+		return old, nil
+	})
+	if err != nil {
+		var vErr *webAuthnError
+		if errors.As(err, &vErr) {
+			return err
+		}
+		return fmt.Errorf("update WebAuthn credential: %w", err)
+	}
+	return nil
+}
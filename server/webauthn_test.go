@@ -0,0 +1,262 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dexidp/dex/api/v2"
+	"github.com/dexidp/dex/connector"
+	"github.com/dexidp/dex/pkg/audit"
+	"github.com/dexidp/dex/pkg/webauthn/webauthntest"
+	"github.com/dexidp/dex/storage"
+)
+
+func TestNewWebAuthn(t *testing.T) {
+	issuerURL, err := url.Parse("https://dex.example.com:8443/dex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, err := newWebAuthn(WebAuthn{}, issuerURL); err != nil || c != nil {
+		t.Errorf("expected WebAuthn to be disabled without a mode, got %+v, %v", c, err)
+	}
+	if _, err := newWebAuthn(WebAuthn{Mode: "always"}, issuerURL); err == nil {
+		t.Errorf("expected error for unknown mode")
+	}
+	c, err := newWebAuthn(WebAuthn{Mode: WebAuthnOptional}, issuerURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.RPID != "dex.example.com" || len(c.Origins) != 1 || c.Origins[0] != "https://dex.example.com:8443" {
+		t.Errorf("unexpected defaults %+v", c)
+	}
+}
+
+func TestWebAuthn(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sink := new(recordingSink)
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.WebAuthn = WebAuthn{Mode: WebAuthnOptional}
+		c.AuditSink = sink
+	})
+	defer httpServer.Close()
+
+	origin := s.webAuthn.Origins[0]
+	claims := storage.Claims{UserID: "jane", Email: "jane@example.com"}
+	newAuthRequest := func() storage.AuthRequest {
+		authReq := storage.AuthRequest{
+			ID:            storage.NewID(),
+			ClientID:      "test",
+			ResponseTypes: []string{responseTypeCode},
+			RedirectURI:   "https://example.com/callback",
+			ConnectorID:   LocalConnector,
+			Expiry:        time.Now().Add(time.Hour),
+		}
+		if err := s.storage.CreateAuthRequest(ctx, authReq); err != nil {
+			t.Fatalf("create auth request: %v", err)
+		}
+		// Logging in to the local connector asks for the second factor.
+		ident := connector.Identity{UserID: claims.UserID, Email: claims.Email}
+		if _, err := s.finalizeLogin(ctx, ident, authReq, nil); err != nil {
+			t.Fatalf("finalize login: %v", err)
+		}
+		return authReq
+	}
+	do := func(method, target string, form url.Values) *httptest.ResponseRecorder {
+		var req *http.Request
+		if form != nil {
+			req = httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		} else {
+			req = httptest.NewRequest(method, target, nil)
+		}
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+		return rr
+	}
+	// challenge renders the WebAuthn page and returns the challenge it asks
+	// the authenticator to sign.
+	challenge := func(authReqID string) ([]byte, string) {
+		rr := do(http.MethodGet, "/webauthn?req="+authReqID, nil)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected WebAuthn page, got %d: %s", rr.Code, rr.Body)
+		}
+		authReq, err := s.storage.GetAuthRequest(ctx, authReqID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return authReq.SecondFactorChallenge, rr.Body.String()
+	}
+	encode := base64.RawURLEncoding.EncodeToString
+
+	authenticator, err := webauthntest.New(s.webAuthn.RPID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a passkey, the user is offered to register one.
+	authReq := newAuthRequest()
+	rr := do(http.MethodGet, "/approval?req="+authReq.ID, nil)
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/webauthn?req="+authReq.ID {
+		t.Fatalf("expected redirect to WebAuthn, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	c, page := challenge(authReq.ID)
+	if !strings.Contains(page, "Register a Passkey") || !strings.Contains(page, "Not now") {
+		t.Errorf("expected optional registration page, got %s", page)
+	}
+	clientData, attestation := authenticator.Register(c, origin)
+	rr = do(http.MethodPost, "/webauthn", url.Values{
+		"req":                {authReq.ID},
+		"action":             {"register"},
+		"client_data":        {encode(clientData)},
+		"attestation_object": {encode(attestation)},
+	})
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/approval?req="+authReq.ID {
+		t.Fatalf("expected redirect to approval after registering, got %d: %s", rr.Code, rr.Body)
+	}
+	cred, err := s.storage.GetWebAuthnCredential(ctx, encode(authenticator.ID))
+	if err != nil {
+		t.Fatalf("get registered credential: %v", err)
+	}
+	if cred.UserID != claims.UserID || cred.ConnectorID != LocalConnector {
+		t.Errorf("unexpected credential %+v", cred)
+	}
+	rr = do(http.MethodGet, "/approval?req="+authReq.ID, nil)
+	if loc := rr.Header().Get("Location"); !strings.HasPrefix(loc, authReq.RedirectURI) {
+		t.Fatalf("expected redirect to client after registering, got %d %q", rr.Code, loc)
+	}
+
+	// With a passkey, the user must use it and can't skip.
+	authReq = newAuthRequest()
+	c, page = challenge(authReq.ID)
+	if !strings.Contains(page, encode(authenticator.ID)) || strings.Contains(page, "Not now") {
+		t.Errorf("expected assertion page for the registered passkey, got %s", page)
+	}
+	if rr := do(http.MethodPost, "/webauthn", url.Values{"req": {authReq.ID}, "action": {"skip"}}); rr.Code != http.StatusForbidden {
+		t.Errorf("expected skipping to be forbidden, got %d", rr.Code)
+	}
+	clientData, authData, sig := authenticator.Assert(c, origin)
+	assertion := url.Values{
+		"req":                {authReq.ID},
+		"action":             {"assert"},
+		"credential_id":      {encode(authenticator.ID)},
+		"client_data":        {encode(clientData)},
+		"authenticator_data": {encode(authData)},
+		"signature":          {encode(sig)},
+	}
+	if rr := do(http.MethodPost, "/webauthn", assertion); rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected assertion to pass, got %d: %s", rr.Code, rr.Body)
+	}
+	if authReq, _ := s.storage.GetAuthRequest(ctx, authReq.ID); authReq.SecondFactor != "" {
+		t.Errorf("expected second factor to be passed, got %q", authReq.SecondFactor)
+	}
+
+	// Challenges can't be replayed.
+	authReq = newAuthRequest()
+	challenge(authReq.ID)
+	assertion.Set("req", authReq.ID)
+	if rr := do(http.MethodPost, "/webauthn", assertion); rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected replayed assertion to be rejected, got %d", rr.Code)
+	}
+
+	// A signature counter which goes backwards indicates a cloned key.
+	c, _ = challenge(authReq.ID)
+	authenticator.SignCount = 0
+	clientData, authData, sig = authenticator.Assert(c, origin)
+	rr = do(http.MethodPost, "/webauthn", url.Values{
+		"req":                {authReq.ID},
+		"credential_id":      {encode(authenticator.ID)},
+		"client_data":        {encode(clientData)},
+		"authenticator_data": {encode(authData)},
+		"signature":          {encode(sig)},
+	})
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected assertion of a cloned authenticator to be rejected, got %d", rr.Code)
+	}
+	last := sink.events[len(sink.events)-1]
+	if last.Type != audit.EventWebAuthnFailed || last.Severity != audit.SeverityHigh {
+		t.Errorf("expected a high severity webauthn_failed event, got %+v", last)
+	}
+
+	// Grants which can't ask for the passkey are refused.
+	required, err := s.webAuthnRequired(ctx, claims.UserID, LocalConnector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !required {
+		t.Errorf("expected a passkey to be required for the password grant")
+	}
+}
+
+func TestWebAuthnDisabled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, nil)
+	defer httpServer.Close()
+
+	if s.secondFactor(LocalConnector) != "" {
+		t.Errorf("expected no second factor while WebAuthn is disabled")
+	}
+	required, err := s.webAuthnRequired(ctx, "jane", LocalConnector)
+	if err != nil || required {
+		t.Errorf("expected no passkey to be required, got %v, %v", required, err)
+	}
+}
+
+func TestWebAuthnAPI(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, nil)
+	defer httpServer.Close()
+
+	if err := s.storage.CreatePassword(ctx, storage.Password{Email: "jane@example.com", UserID: "jane", Hash: []byte("$2a$10$")}); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []storage.WebAuthnCredential{
+		{ID: "a", UserID: "jane", ConnectorID: LocalConnector},
+		{ID: "b", UserID: "jane", ConnectorID: LocalConnector},
+		{ID: "c", UserID: "john", ConnectorID: LocalConnector},
+	} {
+		if err := s.storage.CreateWebAuthnCredential(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a := NewAPI(s.storage, logger, nil, nil, nil, 0)
+
+	list, err := a.ListWebAuthnCredentials(ctx, &api.ListWebAuthnCredentialsReq{Email: "jane@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Credentials) != 2 {
+		t.Errorf("expected the user's two passkeys, got %v", list.Credentials)
+	}
+	if list, _ := a.ListWebAuthnCredentials(ctx, &api.ListWebAuthnCredentialsReq{Email: "john@example.com"}); !list.NotFound {
+		t.Errorf("expected unknown user not to be found")
+	}
+
+	resp, err := a.DeleteWebAuthnCredentials(ctx, &api.DeleteWebAuthnCredentialsReq{Email: "jane@example.com", Id: "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.NotFound {
+		t.Errorf("expected passkey of another user not to be found")
+	}
+	if _, err := a.DeleteWebAuthnCredentials(ctx, &api.DeleteWebAuthnCredentialsReq{Email: "jane@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if creds, err := s.storage.ListWebAuthnCredentials(ctx, "jane", LocalConnector); err != nil || len(creds) != 0 {
+		t.Errorf("expected the user's passkeys to be deleted, got %+v, %v", creds, err)
+	}
+	if _, err := s.storage.GetWebAuthnCredential(ctx, "c"); err != nil {
+		t.Errorf("expected the other user's passkey to remain: %v", err)
+	}
+}
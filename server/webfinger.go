@@ -0,0 +1,109 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var errInvalidWebFingerResource = errors.New("resource has no host")
+
+// The link relation of the OpenID Connect issuer.
+const webFingerIssuerRel = "http://openid.net/specs/connect/1.0/issuer"
+
+type webFingerLink struct {
+	Rel  string `json:"rel"`
+	Href string `json:"href"`
+}
+
+type webFingerResponse struct {
+	Subject string          `json:"subject"`
+	Links   []webFingerLink `json:"links"`
+}
+
+// handleWebFinger implements WebFinger (RFC 7033) for OpenID Connect issuer
+// discovery, pointing users of the configured domains to this issuer.
+func (s *Server) handleWebFinger(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "Method not allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+	// WebFinger resources are public, any origin may query them.
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	q := r.URL.Query()
+	resource := q.Get("resource")
+	if resource == "" {
+		http.Error(w, "Missing resource parameter.", http.StatusBadRequest)
+		return
+	}
+	subject, host, err := normalizeWebFingerResource(resource)
+	if err != nil {
+		http.Error(w, "Invalid resource parameter.", http.StatusBadRequest)
+		return
+	}
+	if !s.webFingerDomain(host) {
+		http.NotFound(w, r)
+		return
+	}
+
+	resp := webFingerResponse{Subject: subject, Links: []webFingerLink{}}
+	if rels, ok := q["rel"]; !ok || contains(rels, webFingerIssuerRel) {
+		resp.Links = append(resp.Links, webFingerLink{Rel: webFingerIssuerRel, Href: s.issuerURL.String()})
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		s.logger.Errorf("failed to marshal webfinger response: %v", err)
+		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/jrd+json")
+	w.Write(data)
+}
+
+// webFingerDomain reports whether the server answers for users of the host.
+// Without configured domains it answers for any host.
+func (s *Server) webFingerDomain(host string) bool {
+	if len(s.webFingerDomains) == 0 {
+		return true
+	}
+	for _, domain := range s.webFingerDomains {
+		if strings.EqualFold(domain, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeWebFingerResource normalizes user input as described by OpenID
+// Connect Discovery section 2.1: input without a scheme containing an @ is
+// an acct: URI, other input without a scheme is an https URL. It returns the
+// normalized resource and the host of the user.
+func normalizeWebFingerResource(resource string) (subject, host string, err error) {
+	switch {
+	case strings.HasPrefix(resource, "acct:"), strings.Contains(resource, "://"):
+	case strings.Contains(resource, "@"):
+		resource = "acct:" + resource
+	default:
+		resource = "https://" + resource
+	}
+	u, err := url.Parse(resource)
+	if err != nil {
+		return "", "", err
+	}
+	if u.Scheme == "acct" {
+		i := strings.LastIndex(u.Opaque, "@")
+		if i <= 0 || i == len(u.Opaque)-1 {
+			return "", "", errInvalidWebFingerResource
+		}
+		return resource, u.Opaque[i+1:], nil
+	}
+	if u.Host == "" {
+		return "", "", errInvalidWebFingerResource
+	}
+	u.Fragment = ""
+	return u.String(), u.Hostname(), nil
+}
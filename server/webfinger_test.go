@@ -0,0 +1,90 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestNormalizeWebFingerResource(t *testing.T) {
+	tests := []struct {
+		resource string
+		subject  string
+		host     string
+		wantErr  bool
+	}{
+		{"joe@example.com", "acct:joe@example.com", "example.com", false},
+		{"acct:joe@example.com", "acct:joe@example.com", "example.com", false},
+		{"example.com", "https://example.com", "example.com", false},
+		{"example.com:8080/joe", "https://example.com:8080/joe", "example.com", false},
+		{"https://example.com/joe#frag", "https://example.com/joe", "example.com", false},
+		{"acct:joe", "", "", true},
+		{"acct:joe@", "", "", true},
+	}
+	for _, tc := range tests {
+		subject, host, err := normalizeWebFingerResource(tc.resource)
+		if err != nil {
+			if !tc.wantErr {
+				t.Errorf("%s: unexpected error: %v", tc.resource, err)
+			}
+			continue
+		}
+		if tc.wantErr {
+			t.Errorf("%s: expected error", tc.resource)
+			continue
+		}
+		if subject != tc.subject || host != tc.host {
+			t.Errorf("%s: expected (%q, %q), got (%q, %q)", tc.resource, tc.subject, tc.host, subject, host)
+		}
+	}
+}
+
+func TestHandleWebFinger(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, s := newTestServer(ctx, t, func(c *Config) {
+		c.WebFingerDomains = []string{"example.com"}
+	})
+	defer httpServer.Close()
+
+	get := func(query url.Values) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/.well-known/webfinger?"+query.Encode(), nil))
+		return rr
+	}
+
+	rr := get(url.Values{"resource": {"acct:joe@example.com"}, "rel": {webFingerIssuerRel}})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/jrd+json" {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	var resp webFingerResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	want := []webFingerLink{{Rel: webFingerIssuerRel, Href: s.issuerURL.String()}}
+	if resp.Subject != "acct:joe@example.com" || len(resp.Links) != 1 || resp.Links[0] != want[0] {
+		t.Errorf("unexpected response %+v", resp)
+	}
+
+	// Other relations are filtered out.
+	if err := json.Unmarshal(get(url.Values{"resource": {"joe@example.com"}, "rel": {"other"}}).Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Links) != 0 {
+		t.Errorf("expected no links for other relations, got %+v", resp.Links)
+	}
+
+	if rr := get(url.Values{"resource": {"joe@other.com"}}); rr.Code != http.StatusNotFound {
+		t.Errorf("expected users of other domains not to be found, got %d", rr.Code)
+	}
+	if rr := get(url.Values{}); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected missing resource to be rejected, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,266 @@
+// Package cache provides a storage wrapper caching reads of objects which
+// are read on most requests but rarely change.
+package cache
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dexidp/dex/storage"
+)
+
+// Config sets how long objects of each type are cached. Types with a zero
+// duration aren't cached.
+type Config struct {
+	Clients    time.Duration
+	Keys       time.Duration
+	Connectors time.Duration
+	Passwords  time.Duration
+}
+
+// New returns a storage caching reads of s as configured.
+//
+// Writes through the returned storage invalidate the cached objects they
+// change. Writes by other dex instances sharing the storage are only seen
+// once the cached objects expire.
+func New(s storage.Storage, c Config) storage.Storage {
+	if c == (Config{}) {
+		return s
+	}
+	now := time.Now
+	return &cache{
+		Storage:        s,
+		clients:        newTable(c.Clients, now),
+		clientLists:    newTable(c.Clients, now),
+		keys:           newTable(c.Keys, now),
+		connectors:     newTable(c.Connectors, now),
+		connectorLists: newTable(c.Connectors, now),
+		passwords:      newTable(c.Passwords, now),
+	}
+}
+
+type cache struct {
+	storage.Storage
+
+	clients        *table
+	clientLists    *table
+	keys           *table
+	connectors     *table
+	connectorLists *table
+	passwords      *table
+}
+
+type entry struct {
+	value  interface{}
+	expiry time.Time
+}
+
+// table caches objects of one type by key.
+type table struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]entry
+	// generation is incremented on every invalidation. Values read from the
+	// storage before an invalidation may be stale and aren't cached.
+	generation uint64
+}
+
+func newTable(ttl time.Duration, now func() time.Time) *table {
+	return &table{ttl: ttl, now: now, entries: make(map[string]entry)}
+}
+
+// get returns the cached value of the key, or the current generation to
+// pass to set after reading the value from the storage.
+func (t *table) get(key string) (value interface{}, generation uint64, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e, ok := t.entries[key]
+	if ok && t.now().Before(e.expiry) {
+		return e.value, t.generation, true
+	}
+	delete(t.entries, key)
+	return nil, t.generation, false
+}
+
+func (t *table) set(key string, value interface{}, generation uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if generation == t.generation {
+		t.entries[key] = entry{value: value, expiry: t.now().Add(t.ttl)}
+	}
+}
+
+// invalidate removes the key from the table, or every key if the key is
+// empty.
+func (t *table) invalidate(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.generation++
+	if key == "" {
+		t.entries = make(map[string]entry)
+	} else {
+		delete(t.entries, key)
+	}
+}
+
+func (t *table) enabled() bool {
+	return t.ttl > 0
+}
+
+func (c *cache) GetClient(ctx context.Context, id string) (storage.Client, error) {
+	if !c.clients.enabled() {
+		return c.Storage.GetClient(ctx, id)
+	}
+	v, gen, ok := c.clients.get(id)
+	if ok {
+		return v.(storage.Client), nil
+	}
+	client, err := c.Storage.GetClient(ctx, id)
+	if err == nil {
+		c.clients.set(id, client, gen)
+	}
+	return client, err
+}
+
+func (c *cache) ListClients(ctx context.Context) ([]storage.Client, error) {
+	if !c.clients.enabled() {
+		return c.Storage.ListClients(ctx)
+	}
+	// Lists are copied, since callers may reorder or filter them in place.
+	v, gen, ok := c.clientLists.get("")
+	if ok {
+		return append([]storage.Client(nil), v.([]storage.Client)...), nil
+	}
+	clients, err := c.Storage.ListClients(ctx)
+	if err == nil {
+		c.clientLists.set("", append([]storage.Client(nil), clients...), gen)
+	}
+	return clients, err
+}
+
+func (c *cache) invalidateClient(id string) {
+	c.clients.invalidate(id)
+	c.clientLists.invalidate("")
+}
+
+func (c *cache) CreateClient(ctx context.Context, client storage.Client) error {
+	defer c.invalidateClient(client.ID)
+	return c.Storage.CreateClient(ctx, client)
+}
+
+func (c *cache) UpdateClient(ctx context.Context, id string, updater func(old storage.Client) (storage.Client, error)) error {
+	defer c.invalidateClient(id)
+	return c.Storage.UpdateClient(ctx, id, updater)
+}
+
+func (c *cache) DeleteClient(ctx context.Context, id string) error {
+	defer c.invalidateClient(id)
+	return c.Storage.DeleteClient(ctx, id)
+}
+
+func (c *cache) GetKeys(ctx context.Context) (storage.Keys, error) {
+	if !c.keys.enabled() {
+		return c.Storage.GetKeys(ctx)
+	}
+	v, gen, ok := c.keys.get("")
+	if ok {
+		return v.(storage.Keys), nil
+	}
+	keys, err := c.Storage.GetKeys(ctx)
+	if err == nil {
+		c.keys.set("", keys, gen)
+	}
+	return keys, err
+}
+
+func (c *cache) UpdateKeys(ctx context.Context, updater func(old storage.Keys) (storage.Keys, error)) error {
+	defer c.keys.invalidate("")
+	return c.Storage.UpdateKeys(ctx, updater)
+}
+
+func (c *cache) GetConnector(ctx context.Context, id string) (storage.Connector, error) {
+	if !c.connectors.enabled() {
+		return c.Storage.GetConnector(ctx, id)
+	}
+	v, gen, ok := c.connectors.get(id)
+	if ok {
+		return v.(storage.Connector), nil
+	}
+	conn, err := c.Storage.GetConnector(ctx, id)
+	if err == nil {
+		c.connectors.set(id, conn, gen)
+	}
+	return conn, err
+}
+
+func (c *cache) ListConnectors(ctx context.Context) ([]storage.Connector, error) {
+	if !c.connectors.enabled() {
+		return c.Storage.ListConnectors(ctx)
+	}
+	v, gen, ok := c.connectorLists.get("")
+	if ok {
+		return append([]storage.Connector(nil), v.([]storage.Connector)...), nil
+	}
+	connectors, err := c.Storage.ListConnectors(ctx)
+	if err == nil {
+		c.connectorLists.set("", append([]storage.Connector(nil), connectors...), gen)
+	}
+	return connectors, err
+}
+
+func (c *cache) invalidateConnector(id string) {
+	c.connectors.invalidate(id)
+	c.connectorLists.invalidate("")
+}
+
+func (c *cache) CreateConnector(ctx context.Context, conn storage.Connector) error {
+	defer c.invalidateConnector(conn.ID)
+	return c.Storage.CreateConnector(ctx, conn)
+}
+
+func (c *cache) UpdateConnector(ctx context.Context, id string, updater func(old storage.Connector) (storage.Connector, error)) error {
+	defer c.invalidateConnector(id)
+	return c.Storage.UpdateConnector(ctx, id, updater)
+}
+
+func (c *cache) DeleteConnector(ctx context.Context, id string) error {
+	defer c.invalidateConnector(id)
+	return c.Storage.DeleteConnector(ctx, id)
+}
+
+// GetPassword caches passwords by lowercased email, since storages look them
+// up case insensitively.
+func (c *cache) GetPassword(ctx context.Context, email string) (storage.Password, error) {
+	if !c.passwords.enabled() {
+		return c.Storage.GetPassword(ctx, email)
+	}
+	key := strings.ToLower(email)
+	v, gen, ok := c.passwords.get(key)
+	if ok {
+		return v.(storage.Password), nil
+	}
+	p, err := c.Storage.GetPassword(ctx, email)
+	if err == nil {
+		c.passwords.set(key, p, gen)
+	}
+	return p, err
+}
+
+func (c *cache) CreatePassword(ctx context.Context, p storage.Password) error {
+	defer c.passwords.invalidate(strings.ToLower(p.Email))
+	return c.Storage.CreatePassword(ctx, p)
+}
+
+func (c *cache) UpdatePassword(ctx context.Context, email string, updater func(p storage.Password) (storage.Password, error)) error {
+	defer c.passwords.invalidate(strings.ToLower(email))
+	return c.Storage.UpdatePassword(ctx, email, updater)
+}
+
+func (c *cache) DeletePassword(ctx context.Context, email string) error {
+	defer c.passwords.invalidate(strings.ToLower(email))
+	return c.Storage.DeletePassword(ctx, email)
+}
@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/dexidp/dex/storage"
+	"github.com/dexidp/dex/storage/conformance"
+	"github.com/dexidp/dex/storage/memory"
+)
+
+var logger = &logrus.Logger{
+	Out:       os.Stderr,
+	Formatter: &logrus.TextFormatter{DisableColors: true},
+	Level:     logrus.DebugLevel,
+}
+
+var allCached = Config{Clients: time.Minute, Keys: time.Minute, Connectors: time.Minute, Passwords: time.Minute}
+
+func TestStorage(t *testing.T) {
+	newStorage := func() storage.Storage {
+		return New(memory.New(logger), allCached)
+	}
+	conformance.RunTests(t, newStorage)
+}
+
+// countingStorage counts the reads of clients from the underlying storage.
+type countingStorage struct {
+	storage.Storage
+	reads int
+}
+
+func (s *countingStorage) GetClient(ctx context.Context, id string) (storage.Client, error) {
+	s.reads++
+	return s.Storage.GetClient(ctx, id)
+}
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	backing := &countingStorage{Storage: memory.New(logger)}
+	s := New(backing, Config{Clients: time.Minute}).(*cache)
+	now := time.Now()
+	s.clients.now = func() time.Time { return now }
+
+	if err := backing.CreateClient(ctx, storage.Client{ID: "foo", Name: "Foo"}); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(wantName string, wantReads int) {
+		t.Helper()
+		c, err := s.GetClient(ctx, "foo")
+		if err != nil {
+			t.Fatalf("get client: %v", err)
+		}
+		if c.Name != wantName {
+			t.Errorf("expected client %q, got %q", wantName, c.Name)
+		}
+		if backing.reads != wantReads {
+			t.Errorf("expected %d reads of the storage, got %d", wantReads, backing.reads)
+		}
+	}
+
+	get("Foo", 1)
+	get("Foo", 1)
+
+	// Writes by another instance are seen once the client expires.
+	backing.UpdateClient(ctx, "foo", func(old storage.Client) (storage.Client, error) {
+		old.Name = "Bar"
+		return old, nil
+	})
+	get("Foo", 1)
+	now = now.Add(time.Minute)
+	get("Bar", 2)
+
+	// Writes through the cache are seen right away.
+	s.UpdateClient(ctx, "foo", func(old storage.Client) (storage.Client, error) {
+		old.Name = "Baz"
+		return old, nil
+	})
+	get("Baz", 3)
+	get("Baz", 3)
+
+	if err := s.DeleteClient(ctx, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetClient(ctx, "foo"); err != storage.ErrNotFound {
+		t.Errorf("expected deleted client not to be found, got %v", err)
+	}
+}
+
+func TestCacheDisabled(t *testing.T) {
+	backing := memory.New(logger)
+	if s := New(backing, Config{}); s != backing {
+		t.Errorf("expected storage to be returned unchanged without any cached types")
+	}
+}
+
+func TestStaleReadNotCached(t *testing.T) {
+	tbl := newTable(time.Minute, time.Now)
+	_, gen, _ := tbl.get("foo")
+	// The object changes while it's being read from the storage.
+	tbl.invalidate("foo")
+	tbl.set("foo", "stale", gen)
+	if _, _, ok := tbl.get("foo"); ok {
+		t.Errorf("expected value read before an invalidation not to be cached")
+	}
+}
@@ -2,8 +2,11 @@
 package conformance
 
 import (
+	"context"
+	"errors"
 	"reflect"
 	"sort"
+	"sync"
 	"testing"
 	"time"
 
@@ -40,13 +43,24 @@ func runTests(t *testing.T, newStorage func() storage.Storage, tests []subTest)
 func RunTests(t *testing.T, newStorage func() storage.Storage) {
 	runTests(t, newStorage, []subTest{
 		{"AuthCodeCRUD", testAuthCodeCRUD},
+		{"AuthCodeConcurrentConsume", testAuthCodeConcurrentConsume},
 		{"AuthRequestCRUD", testAuthRequestCRUD},
 		{"ClientCRUD", testClientCRUD},
 		{"RefreshTokenCRUD", testRefreshTokenCRUD},
 		{"PasswordCRUD", testPasswordCRUD},
 		{"KeysCRUD", testKeysCRUD},
 		{"OfflineSessionCRUD", testOfflineSessionCRUD},
+		{"TermsAcceptanceCRUD", testTermsAcceptanceCRUD},
+		{"TOTPSecretCRUD", testTOTPSecretCRUD},
 		{"ConnectorCRUD", testConnectorCRUD},
+		{"AuditEvents", testAuditEvents},
+		{"APIKeyCRUD", testAPIKeyCRUD},
+		{"RevokedTokenCRUD", testRevokedTokenCRUD},
+		{"SessionCRUD", testSessionCRUD},
+		{"PreAuthorizedCodeConsume", testPreAuthorizedCodeConsume},
+		{"ServiceAccountCRUD", testServiceAccountCRUD},
+		{"WebAuthnCredentialCRUD", testWebAuthnCredentialCRUD},
+		{"LoginLinkConsume", testLoginLinkConsume},
 		{"GarbageCollection", testGC},
 		{"TimezoneSupport", testTimezones},
 	})
@@ -64,7 +78,7 @@ func mustBeErrNotFound(t *testing.T, kind string, err error) {
 	switch {
 	case err == nil:
 		t.Errorf("deleting non-existent %s should return an error", kind)
-	case err != storage.ErrNotFound:
+	case !errors.Is(err, storage.ErrNotFound):
 		t.Errorf("deleting %s expected storage.ErrNotFound, got %v", kind, err)
 	}
 }
@@ -73,12 +87,13 @@ func mustBeErrAlreadyExists(t *testing.T, kind string, err error) {
 	switch {
 	case err == nil:
 		t.Errorf("attempting to create an existing %s should return an error", kind)
-	case err != storage.ErrAlreadyExists:
+	case !errors.Is(err, storage.ErrAlreadyExists):
 		t.Errorf("creating an existing %s expected storage.ErrAlreadyExists, got %v", kind, err)
 	}
 }
 
 func testAuthRequestCRUD(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
 	a1 := storage.AuthRequest{
 		ID:                  storage.NewID(),
 		ClientID:            "client1",
@@ -103,12 +118,12 @@ func testAuthRequestCRUD(t *testing.T, s storage.Storage) {
 
 	identity := storage.Claims{Email: "foobar"}
 
-	if err := s.CreateAuthRequest(a1); err != nil {
+	if err := s.CreateAuthRequest(ctx, a1); err != nil {
 		t.Fatalf("failed creating auth request: %v", err)
 	}
 
 	// Attempt to create same AuthRequest twice.
-	err := s.CreateAuthRequest(a1)
+	err := s.CreateAuthRequest(ctx, a1)
 	mustBeErrAlreadyExists(t, "auth request", err)
 
 	a2 := storage.AuthRequest{
@@ -131,41 +146,63 @@ func testAuthRequestCRUD(t *testing.T, s storage.Storage) {
 			EmailVerified: true,
 			Groups:        []string{"a"},
 		},
+		PKCE: storage.PKCE{
+			CodeChallenge:       "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM",
+			CodeChallengeMethod: "S256",
+		},
 	}
 
-	if err := s.CreateAuthRequest(a2); err != nil {
+	if err := s.CreateAuthRequest(ctx, a2); err != nil {
 		t.Fatalf("failed creating auth request: %v", err)
 	}
 
-	if err := s.UpdateAuthRequest(a1.ID, func(old storage.AuthRequest) (storage.AuthRequest, error) {
+	if err := s.UpdateAuthRequest(ctx, a1.ID, func(old storage.AuthRequest) (storage.AuthRequest, error) {
 		old.Claims = identity
 		old.ConnectorID = "connID"
+		old.FallbackFrom = "saml"
+		old.SecondFactor = "webauthn"
+		old.SecondFactorChallenge = []byte("challenge")
 		return old, nil
 	}); err != nil {
 		t.Fatalf("failed to update auth request: %v", err)
 	}
 
-	got, err := s.GetAuthRequest(a1.ID)
+	got, err := s.GetAuthRequest(ctx, a1.ID)
 	if err != nil {
 		t.Fatalf("failed to get auth req: %v", err)
 	}
 	if !reflect.DeepEqual(got.Claims, identity) {
 		t.Fatalf("update failed, wanted identity=%#v got %#v", identity, got.Claims)
 	}
+	if got.FallbackFrom != "saml" {
+		t.Fatalf("update failed, wanted fallback connector %q got %q", "saml", got.FallbackFrom)
+	}
+	if got.SecondFactor != "webauthn" || string(got.SecondFactorChallenge) != "challenge" {
+		t.Fatalf("update failed, wanted second factor %q got %q", "webauthn", got.SecondFactor)
+	}
+
+	got, err = s.GetAuthRequest(ctx, a2.ID)
+	if err != nil {
+		t.Fatalf("failed to get auth req: %v", err)
+	}
+	if got.PKCE != a2.PKCE {
+		t.Fatalf("wanted code challenge %#v got %#v", a2.PKCE, got.PKCE)
+	}
 
-	if err := s.DeleteAuthRequest(a1.ID); err != nil {
+	if err := s.DeleteAuthRequest(ctx, a1.ID); err != nil {
 		t.Fatalf("failed to delete auth request: %v", err)
 	}
 
-	if err := s.DeleteAuthRequest(a2.ID); err != nil {
+	if err := s.DeleteAuthRequest(ctx, a2.ID); err != nil {
 		t.Fatalf("failed to delete auth request: %v", err)
 	}
 
-	_, err = s.GetAuthRequest(a1.ID)
+	_, err = s.GetAuthRequest(ctx, a1.ID)
 	mustBeErrNotFound(t, "auth request", err)
 }
 
 func testAuthCodeCRUD(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
 	a1 := storage.AuthCode{
 		ID:            storage.NewID(),
 		ClientID:      "client1",
@@ -184,7 +221,7 @@ func testAuthCodeCRUD(t *testing.T, s storage.Storage) {
 		},
 	}
 
-	if err := s.CreateAuthCode(a1); err != nil {
+	if err := s.CreateAuthCode(ctx, a1); err != nil {
 		t.Fatalf("failed creating auth code: %v", err)
 	}
 
@@ -204,17 +241,21 @@ func testAuthCodeCRUD(t *testing.T, s storage.Storage) {
 			EmailVerified: true,
 			Groups:        []string{"a"},
 		},
+		PKCE: storage.PKCE{
+			CodeChallenge:       "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk",
+			CodeChallengeMethod: "plain",
+		},
 	}
 
 	// Attempt to create same AuthCode twice.
-	err := s.CreateAuthCode(a1)
+	err := s.CreateAuthCode(ctx, a1)
 	mustBeErrAlreadyExists(t, "auth code", err)
 
-	if err := s.CreateAuthCode(a2); err != nil {
+	if err := s.CreateAuthCode(ctx, a2); err != nil {
 		t.Fatalf("failed creating auth code: %v", err)
 	}
 
-	got, err := s.GetAuthCode(a1.ID)
+	got, err := s.GetAuthCode(ctx, a1.ID)
 	if err != nil {
 		t.Fatalf("failed to get auth code: %v", err)
 	}
@@ -226,19 +267,82 @@ func testAuthCodeCRUD(t *testing.T, s storage.Storage) {
 		t.Errorf("auth code retrieved from storage did not match: %s", diff)
 	}
 
-	if err := s.DeleteAuthCode(a1.ID); err != nil {
+	if err := s.DeleteAuthCode(ctx, a1.ID); err != nil {
 		t.Fatalf("delete auth code: %v", err)
 	}
 
-	if err := s.DeleteAuthCode(a2.ID); err != nil {
-		t.Fatalf("delete auth code: %v", err)
+	consumed, err := s.ConsumeAuthCode(ctx, a2.ID)
+	if err != nil {
+		t.Fatalf("consume auth code: %v", err)
+	}
+	consumed.Expiry = a2.Expiry
+	if diff := pretty.Compare(a2, consumed); diff != "" {
+		t.Errorf("consumed auth code did not match: %s", diff)
 	}
 
-	_, err = s.GetAuthCode(a1.ID)
+	_, err = s.ConsumeAuthCode(ctx, a2.ID)
+	mustBeErrNotFound(t, "auth code", err)
+
+	_, err = s.GetAuthCode(ctx, a1.ID)
+	mustBeErrNotFound(t, "auth code", err)
+
+	_, err = s.GetAuthCode(ctx, a2.ID)
 	mustBeErrNotFound(t, "auth code", err)
 }
 
+func testAuthCodeConcurrentConsume(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	a := storage.AuthCode{
+		ID:          storage.NewID(),
+		ClientID:    "client1",
+		RedirectURI: "https://localhost:80/callback",
+		Nonce:       "foobar",
+		Scopes:      []string{"openid", "email"},
+		Expiry:      neverExpire,
+		ConnectorID: "ldap",
+		Claims: storage.Claims{
+			UserID:        "1",
+			Username:      "jane",
+			Email:         "jane.doe@example.com",
+			EmailVerified: true,
+			Groups:        []string{"a", "b"},
+		},
+	}
+	if err := s.CreateAuthCode(ctx, a); err != nil {
+		t.Fatalf("failed creating auth code: %v", err)
+	}
+
+	const n = 10
+	errs := make(chan error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.ConsumeAuthCode(ctx, a.ID)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	var consumed int
+	for err := range errs {
+		switch {
+		case err == nil:
+			consumed++
+		case errors.Is(err, storage.ErrNotFound):
+		default:
+			t.Errorf("consume auth code: %v", err)
+		}
+	}
+	if consumed != 1 {
+		t.Errorf("expected auth code to be consumed exactly once, got %d", consumed)
+	}
+}
+
 func testClientCRUD(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
 	id1 := storage.NewID()
 	c1 := storage.Client{
 		ID:           id1,
@@ -246,16 +350,26 @@ func testClientCRUD(t *testing.T, s storage.Storage) {
 		RedirectURIs: []string{"foo://bar.com/", "https://auth.example.com"},
 		Name:         "dex client",
 		LogoURL:      "https://goo.gl/JIyzIC",
+		AllowedCIDRs: []string{"10.0.0.0/8"},
+		Claims:       map[string]interface{}{"tenant": "acme", "roles": []interface{}{"admin"}},
+
+		TOSURI:          "https://example.com/tos",
+		PolicyURI:       "https://example.com/privacy",
+		Contacts:        []string{"admin@example.com"},
+		ApplicationType: "web",
+		Expiry:          neverExpire,
+
+		BackchannelLogoutURI: "https://example.com/logout",
 	}
-	err := s.DeleteClient(id1)
+	err := s.DeleteClient(ctx, id1)
 	mustBeErrNotFound(t, "client", err)
 
-	if err := s.CreateClient(c1); err != nil {
+	if err := s.CreateClient(ctx, c1); err != nil {
 		t.Fatalf("create client: %v", err)
 	}
 
 	// Attempt to create same Client twice.
-	err = s.CreateClient(c1)
+	err = s.CreateClient(ctx, c1)
 	mustBeErrAlreadyExists(t, "client", err)
 
 	id2 := storage.NewID()
@@ -267,16 +381,20 @@ func testClientCRUD(t *testing.T, s storage.Storage) {
 		LogoURL:      "https://goo.gl/JIyzIC",
 	}
 
-	if err := s.CreateClient(c2); err != nil {
+	if err := s.CreateClient(ctx, c2); err != nil {
 		t.Fatalf("create client: %v", err)
 	}
 
 	getAndCompare := func(id string, want storage.Client) {
-		gc, err := s.GetClient(id1)
+		gc, err := s.GetClient(ctx, id1)
 		if err != nil {
 			t.Errorf("get client: %v", err)
 			return
 		}
+		if want.Expiry.Unix() != gc.Expiry.Unix() {
+			t.Errorf("client expiry did not match want=%s vs got=%s", want.Expiry, gc.Expiry)
+		}
+		gc.Expiry = want.Expiry // time fields do not compare well
 		if diff := pretty.Compare(want, gc); diff != "" {
 			t.Errorf("client retrieved from storage did not match: %s", diff)
 		}
@@ -285,7 +403,7 @@ func testClientCRUD(t *testing.T, s storage.Storage) {
 	getAndCompare(id1, c1)
 
 	newSecret := "barfoo"
-	err = s.UpdateClient(id1, func(old storage.Client) (storage.Client, error) {
+	err = s.UpdateClient(ctx, id1, func(old storage.Client) (storage.Client, error) {
 		old.Secret = newSecret
 		return old, nil
 	})
@@ -295,19 +413,20 @@ func testClientCRUD(t *testing.T, s storage.Storage) {
 	c1.Secret = newSecret
 	getAndCompare(id1, c1)
 
-	if err := s.DeleteClient(id1); err != nil {
+	if err := s.DeleteClient(ctx, id1); err != nil {
 		t.Fatalf("delete client: %v", err)
 	}
 
-	if err := s.DeleteClient(id2); err != nil {
+	if err := s.DeleteClient(ctx, id2); err != nil {
 		t.Fatalf("delete client: %v", err)
 	}
 
-	_, err = s.GetClient(id1)
+	_, err = s.GetClient(ctx, id1)
 	mustBeErrNotFound(t, "client", err)
 }
 
 func testRefreshTokenCRUD(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
 	id := storage.NewID()
 	refresh := storage.RefreshToken{
 		ID:          id,
@@ -318,6 +437,9 @@ func testRefreshTokenCRUD(t *testing.T, s storage.Storage) {
 		Scopes:      []string{"openid", "email", "profile"},
 		CreatedAt:   time.Now().UTC().Round(time.Millisecond),
 		LastUsed:    time.Now().UTC().Round(time.Millisecond),
+		LastUsedIP:  "10.0.0.1",
+
+		DeviceFingerprint: "f3b0c44298fc1c149afbf4c8996fb924",
 		Claims: storage.Claims{
 			UserID:        "1",
 			Username:      "jane",
@@ -327,16 +449,16 @@ func testRefreshTokenCRUD(t *testing.T, s storage.Storage) {
 		},
 		ConnectorData: []byte(`{"some":"data"}`),
 	}
-	if err := s.CreateRefresh(refresh); err != nil {
+	if err := s.CreateRefresh(ctx, refresh); err != nil {
 		t.Fatalf("create refresh token: %v", err)
 	}
 
 	// Attempt to create same Refresh Token twice.
-	err := s.CreateRefresh(refresh)
+	err := s.CreateRefresh(ctx, refresh)
 	mustBeErrAlreadyExists(t, "refresh token", err)
 
 	getAndCompare := func(id string, want storage.RefreshToken) {
-		gr, err := s.GetRefresh(id)
+		gr, err := s.GetRefresh(ctx, id)
 		if err != nil {
 			t.Errorf("get refresh: %v", err)
 			return
@@ -382,7 +504,7 @@ func testRefreshTokenCRUD(t *testing.T, s storage.Storage) {
 		ConnectorData: []byte(`{"some":"data"}`),
 	}
 
-	if err := s.CreateRefresh(refresh2); err != nil {
+	if err := s.CreateRefresh(ctx, refresh2); err != nil {
 		t.Fatalf("create second refresh token: %v", err)
 	}
 
@@ -393,27 +515,29 @@ func testRefreshTokenCRUD(t *testing.T, s storage.Storage) {
 	updater := func(r storage.RefreshToken) (storage.RefreshToken, error) {
 		r.Token = "spam"
 		r.LastUsed = updatedAt
+		r.LastUsedIP = "10.0.0.2"
 		return r, nil
 	}
-	if err := s.UpdateRefreshToken(id, updater); err != nil {
+	if err := s.UpdateRefreshToken(ctx, id, updater); err != nil {
 		t.Errorf("failed to udpate refresh token: %v", err)
 	}
 	refresh.Token = "spam"
 	refresh.LastUsed = updatedAt
+	refresh.LastUsedIP = "10.0.0.2"
 	getAndCompare(id, refresh)
 
 	// Ensure that updating the first token doesn't impact the second. Issue #847.
 	getAndCompare(id2, refresh2)
 
-	if err := s.DeleteRefresh(id); err != nil {
+	if err := s.DeleteRefresh(ctx, id); err != nil {
 		t.Fatalf("failed to delete refresh request: %v", err)
 	}
 
-	if err := s.DeleteRefresh(id2); err != nil {
+	if err := s.DeleteRefresh(ctx, id2); err != nil {
 		t.Fatalf("failed to delete refresh request: %v", err)
 	}
 
-	_, err = s.GetRefresh(id)
+	_, err = s.GetRefresh(ctx, id)
 	mustBeErrNotFound(t, "refresh token", err)
 }
 
@@ -424,6 +548,7 @@ func (n byEmail) Less(i, j int) bool { return n[i].Email < n[j].Email }
 func (n byEmail) Swap(i, j int)      { n[i], n[j] = n[j], n[i] }
 
 func testPasswordCRUD(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
 	// Use bcrypt.MinCost to keep the tests short.
 	passwordHash1, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
 	if err != nil {
@@ -436,12 +561,12 @@ func testPasswordCRUD(t *testing.T, s storage.Storage) {
 		Username: "jane",
 		UserID:   "foobar",
 	}
-	if err := s.CreatePassword(password1); err != nil {
+	if err := s.CreatePassword(ctx, password1); err != nil {
 		t.Fatalf("create password token: %v", err)
 	}
 
 	// Attempt to create same Password twice.
-	err = s.CreatePassword(password1)
+	err = s.CreatePassword(ctx, password1)
 	mustBeErrAlreadyExists(t, "password", err)
 
 	passwordHash2, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
@@ -455,12 +580,12 @@ func testPasswordCRUD(t *testing.T, s storage.Storage) {
 		Username: "john",
 		UserID:   "barfoo",
 	}
-	if err := s.CreatePassword(password2); err != nil {
+	if err := s.CreatePassword(ctx, password2); err != nil {
 		t.Fatalf("create password token: %v", err)
 	}
 
 	getAndCompare := func(id string, want storage.Password) {
-		gr, err := s.GetPassword(id)
+		gr, err := s.GetPassword(ctx, id)
 		if err != nil {
 			t.Errorf("get password %q: %v", id, err)
 			return
@@ -473,7 +598,7 @@ func testPasswordCRUD(t *testing.T, s storage.Storage) {
 	getAndCompare("jane@example.com", password1)
 	getAndCompare("JANE@example.com", password1) // Emails should be case insensitive
 
-	if err := s.UpdatePassword(password1.Email, func(old storage.Password) (storage.Password, error) {
+	if err := s.UpdatePassword(ctx, password1.Email, func(old storage.Password) (storage.Password, error) {
 		old.Username = "jane doe"
 		return old, nil
 	}); err != nil {
@@ -487,7 +612,7 @@ func testPasswordCRUD(t *testing.T, s storage.Storage) {
 	passwordList = append(passwordList, password1, password2)
 
 	listAndCompare := func(want []storage.Password) {
-		passwords, err := s.ListPasswords()
+		passwords, err := s.ListPasswords(ctx)
 		if err != nil {
 			t.Errorf("list password: %v", err)
 			return
@@ -501,19 +626,20 @@ func testPasswordCRUD(t *testing.T, s storage.Storage) {
 
 	listAndCompare(passwordList)
 
-	if err := s.DeletePassword(password1.Email); err != nil {
+	if err := s.DeletePassword(ctx, password1.Email); err != nil {
 		t.Fatalf("failed to delete password: %v", err)
 	}
 
-	if err := s.DeletePassword(password2.Email); err != nil {
+	if err := s.DeletePassword(ctx, password2.Email); err != nil {
 		t.Fatalf("failed to delete password: %v", err)
 	}
 
-	_, err = s.GetPassword(password1.Email)
+	_, err = s.GetPassword(ctx, password1.Email)
 	mustBeErrNotFound(t, "password", err)
 }
 
 func testOfflineSessionCRUD(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
 	userID1 := storage.NewID()
 	session1 := storage.OfflineSessions{
 		UserID:        userID1,
@@ -524,12 +650,12 @@ func testOfflineSessionCRUD(t *testing.T, s storage.Storage) {
 
 	// Creating an OfflineSession with an empty Refresh list to ensure that
 	// an empty map is translated as expected by the storage.
-	if err := s.CreateOfflineSessions(session1); err != nil {
+	if err := s.CreateOfflineSessions(ctx, session1); err != nil {
 		t.Fatalf("create offline session with UserID = %s: %v", session1.UserID, err)
 	}
 
 	// Attempt to create same OfflineSession twice.
-	err := s.CreateOfflineSessions(session1)
+	err := s.CreateOfflineSessions(ctx, session1)
 	mustBeErrAlreadyExists(t, "offline session", err)
 
 	userID2 := storage.NewID()
@@ -540,12 +666,12 @@ func testOfflineSessionCRUD(t *testing.T, s storage.Storage) {
 		ConnectorData: []byte(`{"some":"data"}`),
 	}
 
-	if err := s.CreateOfflineSessions(session2); err != nil {
+	if err := s.CreateOfflineSessions(ctx, session2); err != nil {
 		t.Fatalf("create offline session with UserID = %s: %v", session2.UserID, err)
 	}
 
 	getAndCompare := func(userID string, connID string, want storage.OfflineSessions) {
-		gr, err := s.GetOfflineSessions(userID, connID)
+		gr, err := s.GetOfflineSessions(ctx, userID, connID)
 		if err != nil {
 			t.Errorf("get offline session: %v", err)
 			return
@@ -566,7 +692,7 @@ func testOfflineSessionCRUD(t *testing.T, s storage.Storage) {
 	}
 	session1.Refresh[tokenRef.ClientID] = &tokenRef
 
-	if err := s.UpdateOfflineSessions(session1.UserID, session1.ConnID, func(old storage.OfflineSessions) (storage.OfflineSessions, error) {
+	if err := s.UpdateOfflineSessions(ctx, session1.UserID, session1.ConnID, func(old storage.OfflineSessions) (storage.OfflineSessions, error) {
 		old.Refresh[tokenRef.ClientID] = &tokenRef
 		return old, nil
 	}); err != nil {
@@ -575,19 +701,118 @@ func testOfflineSessionCRUD(t *testing.T, s storage.Storage) {
 
 	getAndCompare(userID1, "Conn1", session1)
 
-	if err := s.DeleteOfflineSessions(session1.UserID, session1.ConnID); err != nil {
+	if err := s.DeleteOfflineSessions(ctx, session1.UserID, session1.ConnID); err != nil {
 		t.Fatalf("failed to delete offline session: %v", err)
 	}
 
-	if err := s.DeleteOfflineSessions(session2.UserID, session2.ConnID); err != nil {
+	if err := s.DeleteOfflineSessions(ctx, session2.UserID, session2.ConnID); err != nil {
 		t.Fatalf("failed to delete offline session: %v", err)
 	}
 
-	_, err = s.GetOfflineSessions(session1.UserID, session1.ConnID)
+	_, err = s.GetOfflineSessions(ctx, session1.UserID, session1.ConnID)
 	mustBeErrNotFound(t, "offline session", err)
 }
 
+func testTermsAcceptanceCRUD(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	a1 := storage.TermsAcceptance{
+		UserID:     storage.NewID(),
+		ConnID:     "Conn1",
+		Version:    "2020-01",
+		AcceptedAt: time.Now().UTC().Round(time.Millisecond),
+	}
+	if err := s.CreateTermsAcceptance(ctx, a1); err != nil {
+		t.Fatalf("create terms acceptance: %v", err)
+	}
+
+	err := s.CreateTermsAcceptance(ctx, a1)
+	mustBeErrAlreadyExists(t, "terms acceptance", err)
+
+	getAndCompare := func(want storage.TermsAcceptance) {
+		got, err := s.GetTermsAcceptance(ctx, want.UserID, want.ConnID)
+		if err != nil {
+			t.Errorf("get terms acceptance: %v", err)
+			return
+		}
+		if diff := pretty.Compare(want, got); diff != "" {
+			t.Errorf("terms acceptance retrieved from storage did not match: %s", diff)
+		}
+	}
+
+	getAndCompare(a1)
+
+	a1.Version = "2020-06"
+	a1.AcceptedAt = a1.AcceptedAt.Add(time.Hour)
+	if err := s.UpdateTermsAcceptance(ctx, a1.UserID, a1.ConnID, func(old storage.TermsAcceptance) (storage.TermsAcceptance, error) {
+		old.Version = a1.Version
+		old.AcceptedAt = a1.AcceptedAt
+		return old, nil
+	}); err != nil {
+		t.Fatalf("update terms acceptance: %v", err)
+	}
+
+	getAndCompare(a1)
+
+	if err := s.DeleteTermsAcceptance(ctx, a1.UserID, a1.ConnID); err != nil {
+		t.Fatalf("delete terms acceptance: %v", err)
+	}
+
+	_, err = s.GetTermsAcceptance(ctx, a1.UserID, a1.ConnID)
+	mustBeErrNotFound(t, "terms acceptance", err)
+}
+
+func testTOTPSecretCRUD(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	t1 := storage.TOTPSecret{
+		UserID:    storage.NewID(),
+		ConnID:    "Conn1",
+		Secret:    []byte("12345678901234567890"),
+		CreatedAt: time.Now().UTC().Round(time.Millisecond),
+	}
+	if err := s.CreateTOTPSecret(ctx, t1); err != nil {
+		t.Fatalf("create totp secret: %v", err)
+	}
+
+	err := s.CreateTOTPSecret(ctx, t1)
+	mustBeErrAlreadyExists(t, "totp secret", err)
+
+	getAndCompare := func(want storage.TOTPSecret) {
+		got, err := s.GetTOTPSecret(ctx, want.UserID, want.ConnID)
+		if err != nil {
+			t.Errorf("get totp secret: %v", err)
+			return
+		}
+		if diff := pretty.Compare(want, got); diff != "" {
+			t.Errorf("totp secret retrieved from storage did not match: %s", diff)
+		}
+	}
+
+	getAndCompare(t1)
+
+	t1.Confirmed = true
+	t1.LastStep = 53165472
+	t1.BackupCodes = [][]byte{[]byte("hash1"), []byte("hash2")}
+	if err := s.UpdateTOTPSecret(ctx, t1.UserID, t1.ConnID, func(old storage.TOTPSecret) (storage.TOTPSecret, error) {
+		old.Confirmed = t1.Confirmed
+		old.LastStep = t1.LastStep
+		old.BackupCodes = t1.BackupCodes
+		return old, nil
+	}); err != nil {
+		t.Fatalf("update totp secret: %v", err)
+	}
+
+	getAndCompare(t1)
+
+	if err := s.DeleteTOTPSecret(ctx, t1.UserID, t1.ConnID); err != nil {
+		t.Fatalf("delete totp secret: %v", err)
+	}
+
+	_, err = s.GetTOTPSecret(ctx, t1.UserID, t1.ConnID)
+	mustBeErrNotFound(t, "totp secret", err)
+}
+
 func testConnectorCRUD(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
 	id1 := storage.NewID()
 	config1 := []byte(`{"issuer": "https://accounts.google.com"}`)
 	c1 := storage.Connector{
@@ -597,12 +822,12 @@ func testConnectorCRUD(t *testing.T, s storage.Storage) {
 		Config: config1,
 	}
 
-	if err := s.CreateConnector(c1); err != nil {
+	if err := s.CreateConnector(ctx, c1); err != nil {
 		t.Fatalf("create connector with ID = %s: %v", c1.ID, err)
 	}
 
 	// Attempt to create same Connector twice.
-	err := s.CreateConnector(c1)
+	err := s.CreateConnector(ctx, c1)
 	mustBeErrAlreadyExists(t, "connector", err)
 
 	id2 := storage.NewID()
@@ -614,12 +839,12 @@ func testConnectorCRUD(t *testing.T, s storage.Storage) {
 		Config: config2,
 	}
 
-	if err := s.CreateConnector(c2); err != nil {
+	if err := s.CreateConnector(ctx, c2); err != nil {
 		t.Fatalf("create connector with ID = %s: %v", c2.ID, err)
 	}
 
 	getAndCompare := func(id string, want storage.Connector) {
-		gr, err := s.GetConnector(id)
+		gr, err := s.GetConnector(ctx, id)
 		if err != nil {
 			t.Errorf("get connector: %v", err)
 			return
@@ -633,7 +858,7 @@ func testConnectorCRUD(t *testing.T, s storage.Storage) {
 
 	getAndCompare(id1, c1)
 
-	if err := s.UpdateConnector(c1.ID, func(old storage.Connector) (storage.Connector, error) {
+	if err := s.UpdateConnector(ctx, c1.ID, func(old storage.Connector) (storage.Connector, error) {
 		old.Type = "oidc"
 		return old, nil
 	}); err != nil {
@@ -645,7 +870,7 @@ func testConnectorCRUD(t *testing.T, s storage.Storage) {
 
 	connectorList := []storage.Connector{c1, c2}
 	listAndCompare := func(want []storage.Connector) {
-		connectors, err := s.ListConnectors()
+		connectors, err := s.ListConnectors(ctx)
 		if err != nil {
 			t.Errorf("list connectors: %v", err)
 			return
@@ -663,21 +888,22 @@ func testConnectorCRUD(t *testing.T, s storage.Storage) {
 	}
 	listAndCompare(connectorList)
 
-	if err := s.DeleteConnector(c1.ID); err != nil {
+	if err := s.DeleteConnector(ctx, c1.ID); err != nil {
 		t.Fatalf("failed to delete connector: %v", err)
 	}
 
-	if err := s.DeleteConnector(c2.ID); err != nil {
+	if err := s.DeleteConnector(ctx, c2.ID); err != nil {
 		t.Fatalf("failed to delete connector: %v", err)
 	}
 
-	_, err = s.GetConnector(c1.ID)
+	_, err = s.GetConnector(ctx, c1.ID)
 	mustBeErrNotFound(t, "connector", err)
 }
 
 func testKeysCRUD(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
 	updateAndCompare := func(k storage.Keys) {
-		err := s.UpdateKeys(func(oldKeys storage.Keys) (storage.Keys, error) {
+		err := s.UpdateKeys(ctx, func(oldKeys storage.Keys) (storage.Keys, error) {
 			return k, nil
 		})
 		if err != nil {
@@ -685,7 +911,7 @@ func testKeysCRUD(t *testing.T, s storage.Storage) {
 			return
 		}
 
-		if got, err := s.GetKeys(); err != nil {
+		if got, err := s.GetKeys(ctx); err != nil {
 			t.Errorf("failed to get keys: %v", err)
 		} else {
 			got.NextRotation = got.NextRotation.UTC()
@@ -724,7 +950,484 @@ func testKeysCRUD(t *testing.T, s storage.Storage) {
 	updateAndCompare(keys2)
 }
 
+func testAPIKeyCRUD(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	now := time.Now().UTC().Round(time.Millisecond)
+
+	k1 := storage.APIKey{
+		ID:       storage.NewID(),
+		Hash:     "3a6eb0790f39ac87c94f3856b2dd2c5d110e6811602261a9a923d3bb23adc8b7",
+		ClientID: "client1",
+		Name:     "nightly backup",
+		Scopes:   []string{"openid", "groups"},
+		Claims: storage.Claims{
+			UserID:        "1",
+			Username:      "backup",
+			Email:         "backup@example.com",
+			EmailVerified: true,
+			Groups:        []string{"a", "b"},
+		},
+		ConnectorID: "api_key",
+		CreatedAt:   now,
+		Expiry:      now.Add(24 * time.Hour),
+	}
+	k2 := storage.APIKey{
+		ID:       storage.NewID(),
+		Hash:     "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		ClientID: "client2",
+		Scopes:   []string{"openid"},
+		Claims: storage.Claims{
+			UserID:   "2",
+			Username: "deploy",
+		},
+		ConnectorID: "api_key",
+		CreatedAt:   now,
+	}
+	for _, k := range []storage.APIKey{k1, k2} {
+		if err := s.CreateAPIKey(ctx, k); err != nil {
+			t.Fatalf("create api key: %v", err)
+		}
+	}
+	err := s.CreateAPIKey(ctx, k1)
+	mustBeErrAlreadyExists(t, "api key", err)
+
+	got, err := s.GetAPIKey(ctx, k1.ID)
+	if err != nil {
+		t.Fatalf("get api key: %v", err)
+	}
+	got.CreatedAt = got.CreatedAt.UTC()
+	got.Expiry = got.Expiry.UTC()
+	if diff := pretty.Compare(k1, got); diff != "" {
+		t.Errorf("api key retrieved from storage did not match: %s", diff)
+	}
+
+	got, err = s.GetAPIKey(ctx, k2.ID)
+	if err != nil {
+		t.Fatalf("get api key: %v", err)
+	}
+	if !got.Expiry.IsZero() {
+		t.Errorf("expected api key without expiry, got %v", got.Expiry)
+	}
+
+	keys, err := s.ListAPIKeys(ctx)
+	if err != nil {
+		t.Fatalf("list api keys: %v", err)
+	}
+	if len(keys) != 2 {
+		t.Errorf("expected 2 api keys, got %d", len(keys))
+	}
+
+	if err := s.DeleteAPIKey(ctx, k1.ID); err != nil {
+		t.Fatalf("delete api key: %v", err)
+	}
+	_, err = s.GetAPIKey(ctx, k1.ID)
+	mustBeErrNotFound(t, "api key", err)
+	err = s.DeleteAPIKey(ctx, k1.ID)
+	mustBeErrNotFound(t, "api key", err)
+}
+
+func testServiceAccountCRUD(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	a1 := storage.ServiceAccount{
+		ID:         "backup",
+		Name:       "Nightly backup",
+		Groups:     []string{"operators"},
+		Clients:    []string{"storage-api"},
+		PublicKeys: []jose.JSONWebKey{*jsonWebKeys[0].Public},
+		CreatedAt:  time.Now().UTC().Round(time.Millisecond),
+	}
+	if err := s.CreateServiceAccount(ctx, a1); err != nil {
+		t.Fatalf("create service account: %v", err)
+	}
+	err := s.CreateServiceAccount(ctx, a1)
+	mustBeErrAlreadyExists(t, "service account", err)
+
+	getAndCompare := func(want storage.ServiceAccount) {
+		got, err := s.GetServiceAccount(ctx, want.ID)
+		if err != nil {
+			t.Errorf("get service account: %v", err)
+			return
+		}
+		got.CreatedAt = got.CreatedAt.UTC()
+		if diff := pretty.Compare(want, got); diff != "" {
+			t.Errorf("service account retrieved from storage did not match: %s", diff)
+		}
+	}
+	getAndCompare(a1)
+
+	if err := s.UpdateServiceAccount(ctx, a1.ID, func(old storage.ServiceAccount) (storage.ServiceAccount, error) {
+		old.Groups = append(old.Groups, "auditors")
+		old.PublicKeys = append(old.PublicKeys, *jsonWebKeys[1].Public)
+		return old, nil
+	}); err != nil {
+		t.Fatalf("update service account: %v", err)
+	}
+	a1.Groups = []string{"operators", "auditors"}
+	a1.PublicKeys = []jose.JSONWebKey{*jsonWebKeys[0].Public, *jsonWebKeys[1].Public}
+	getAndCompare(a1)
+
+	accounts, err := s.ListServiceAccounts(ctx)
+	if err != nil {
+		t.Fatalf("list service accounts: %v", err)
+	}
+	if len(accounts) != 1 || accounts[0].ID != a1.ID {
+		t.Errorf("unexpected service accounts %+v", accounts)
+	}
+
+	if err := s.DeleteServiceAccount(ctx, a1.ID); err != nil {
+		t.Fatalf("delete service account: %v", err)
+	}
+	_, err = s.GetServiceAccount(ctx, a1.ID)
+	mustBeErrNotFound(t, "service account", err)
+}
+
+func testWebAuthnCredentialCRUD(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	now := time.Now().UTC().Round(time.Millisecond)
+
+	c1 := storage.WebAuthnCredential{
+		ID:          "AQIDBAUGBwg-_w",
+		UserID:      "jane",
+		ConnectorID: "local",
+		PublicKey:   []byte{0xa5, 0x01, 0x02},
+		SignCount:   1,
+		CreatedAt:   now,
+		LastUsed:    now,
+	}
+	if err := s.CreateWebAuthnCredential(ctx, c1); err != nil {
+		t.Fatalf("create webauthn credential: %v", err)
+	}
+	err := s.CreateWebAuthnCredential(ctx, c1)
+	mustBeErrAlreadyExists(t, "webauthn credential", err)
+
+	getAndCompare := func(want storage.WebAuthnCredential) {
+		got, err := s.GetWebAuthnCredential(ctx, want.ID)
+		if err != nil {
+			t.Errorf("get webauthn credential: %v", err)
+			return
+		}
+		got.CreatedAt = got.CreatedAt.UTC()
+		got.LastUsed = got.LastUsed.UTC()
+		if diff := pretty.Compare(want, got); diff != "" {
+			t.Errorf("webauthn credential retrieved from storage did not match: %s", diff)
+		}
+	}
+	getAndCompare(c1)
+
+	if err := s.UpdateWebAuthnCredential(ctx, c1.ID, func(old storage.WebAuthnCredential) (storage.WebAuthnCredential, error) {
+		old.SignCount = 5
+		old.LastUsed = now.Add(time.Minute)
+		return old, nil
+	}); err != nil {
+		t.Fatalf("update webauthn credential: %v", err)
+	}
+	c1.SignCount = 5
+	c1.LastUsed = now.Add(time.Minute)
+	getAndCompare(c1)
+
+	c2 := c1
+	c2.ID = "CQoLDA0ODxA"
+	c2.UserID = "john"
+	if err := s.CreateWebAuthnCredential(ctx, c2); err != nil {
+		t.Fatalf("create webauthn credential: %v", err)
+	}
+	creds, err := s.ListWebAuthnCredentials(ctx, c1.UserID, c1.ConnectorID)
+	if err != nil {
+		t.Fatalf("list webauthn credentials: %v", err)
+	}
+	if len(creds) != 1 || creds[0].ID != c1.ID {
+		t.Errorf("unexpected webauthn credentials %+v", creds)
+	}
+	if creds, err := s.ListWebAuthnCredentials(ctx, c1.UserID, "ldap"); err != nil || len(creds) != 0 {
+		t.Errorf("expected no webauthn credentials for another connector, got %+v, %v", creds, err)
+	}
+	if err := s.DeleteWebAuthnCredential(ctx, c2.ID); err != nil {
+		t.Fatalf("delete webauthn credential: %v", err)
+	}
+
+	if err := s.DeleteWebAuthnCredential(ctx, c1.ID); err != nil {
+		t.Fatalf("delete webauthn credential: %v", err)
+	}
+	_, err = s.GetWebAuthnCredential(ctx, c1.ID)
+	mustBeErrNotFound(t, "webauthn credential", err)
+}
+
+func testRevokedTokenCRUD(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	now := time.Now().UTC().Round(time.Millisecond)
+
+	rt := storage.RevokedToken{
+		ID:        storage.NewID(),
+		ClientID:  "client1",
+		Reason:    "reuse_detected",
+		RevokedAt: now,
+		Expiry:    now.Add(24 * time.Hour),
+	}
+	if err := s.CreateRevokedToken(ctx, rt); err != nil {
+		t.Fatalf("create revoked token: %v", err)
+	}
+	err := s.CreateRevokedToken(ctx, rt)
+	mustBeErrAlreadyExists(t, "revoked token", err)
+
+	got, err := s.GetRevokedToken(ctx, rt.ID)
+	if err != nil {
+		t.Fatalf("get revoked token: %v", err)
+	}
+	got.RevokedAt = got.RevokedAt.UTC()
+	got.Expiry = got.Expiry.UTC()
+	if diff := pretty.Compare(rt, got); diff != "" {
+		t.Errorf("revoked token retrieved from storage did not match: %s", diff)
+	}
+
+	_, err = s.GetRevokedToken(ctx, storage.NewID())
+	mustBeErrNotFound(t, "revoked token", err)
+}
+
+func testSessionCRUD(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	now := time.Now().UTC().Round(time.Millisecond)
+
+	session := storage.Session{
+		ID:          storage.NewID(),
+		ConnectorID: "ldap",
+		Claims: storage.Claims{
+			UserID:        "1",
+			Username:      "jane",
+			Email:         "jane.doe@example.com",
+			EmailVerified: true,
+			Groups:        []string{"a", "b"},
+		},
+		ConnectorData: []byte(`{"some":"data"}`),
+		ClientIDs:     []string{"client1"},
+		CreatedAt:     now,
+		Expiry:        now.Add(24 * time.Hour),
+	}
+	if err := s.CreateSession(ctx, session); err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	err := s.CreateSession(ctx, session)
+	mustBeErrAlreadyExists(t, "session", err)
+
+	got, err := s.GetSession(ctx, session.ID)
+	if err != nil {
+		t.Fatalf("get session: %v", err)
+	}
+	got.CreatedAt = got.CreatedAt.UTC()
+	got.Expiry = got.Expiry.UTC()
+	if diff := pretty.Compare(session, got); diff != "" {
+		t.Errorf("session retrieved from storage did not match: %s", diff)
+	}
+
+	if err := s.UpdateSession(ctx, session.ID, func(old storage.Session) (storage.Session, error) {
+		old.ClientIDs = append(old.ClientIDs, "client2")
+		return old, nil
+	}); err != nil {
+		t.Fatalf("update session: %v", err)
+	}
+	sessions, err := s.ListSessions(ctx)
+	if err != nil {
+		t.Fatalf("list sessions: %v", err)
+	}
+	if len(sessions) != 1 || len(sessions[0].ClientIDs) != 2 || sessions[0].ClientIDs[1] != "client2" {
+		t.Errorf("expected updated session to be listed, got %v", sessions)
+	}
+
+	if err := s.DeleteSession(ctx, session.ID); err != nil {
+		t.Fatalf("delete session: %v", err)
+	}
+	_, err = s.GetSession(ctx, session.ID)
+	mustBeErrNotFound(t, "session", err)
+}
+
+func testPreAuthorizedCodeConsume(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	now := time.Now().UTC().Round(time.Millisecond)
+
+	code := storage.PreAuthorizedCode{
+		ID:       storage.NewID(),
+		ClientID: "wallet",
+		Scopes:   []string{"openid", "email"},
+		Claims: storage.Claims{
+			UserID:        "1",
+			Username:      "jane",
+			Email:         "jane.doe@example.com",
+			EmailVerified: true,
+			Groups:        []string{"a", "b"},
+		},
+		ConnectorID: "ldap",
+		PINHash:     []byte("$2a$10$33EMT0cVYVlPy6WAMCLsceLYjWhuHpbz5yuZxu/GAFj03J9Lytjuy"),
+		CreatedAt:   now,
+		Expiry:      now.Add(time.Hour),
+	}
+	if err := s.CreatePreAuthorizedCode(ctx, code); err != nil {
+		t.Fatalf("create pre-authorized code: %v", err)
+	}
+	err := s.CreatePreAuthorizedCode(ctx, code)
+	mustBeErrAlreadyExists(t, "pre-authorized code", err)
+
+	got, err := s.ConsumePreAuthorizedCode(ctx, code.ID)
+	if err != nil {
+		t.Fatalf("consume pre-authorized code: %v", err)
+	}
+	got.CreatedAt = got.CreatedAt.UTC()
+	got.Expiry = got.Expiry.UTC()
+	if diff := pretty.Compare(code, got); diff != "" {
+		t.Errorf("pre-authorized code retrieved from storage did not match: %s", diff)
+	}
+
+	_, err = s.ConsumePreAuthorizedCode(ctx, code.ID)
+	mustBeErrNotFound(t, "pre-authorized code", err)
+}
+
+func testLoginLinkConsume(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	now := time.Now().UTC().Round(time.Millisecond)
+
+	link := storage.LoginLink{
+		ID:            storage.NewID(),
+		AuthRequestID: storage.NewID(),
+		ConnectorID:   "email",
+		Email:         "jane.doe@example.com",
+		CreatedAt:     now,
+		Expiry:        now.Add(10 * time.Minute),
+	}
+	if err := s.CreateLoginLink(ctx, link); err != nil {
+		t.Fatalf("create login link: %v", err)
+	}
+	err := s.CreateLoginLink(ctx, link)
+	mustBeErrAlreadyExists(t, "login link", err)
+
+	got, err := s.ConsumeLoginLink(ctx, link.ID)
+	if err != nil {
+		t.Fatalf("consume login link: %v", err)
+	}
+	got.CreatedAt = got.CreatedAt.UTC()
+	got.Expiry = got.Expiry.UTC()
+	if diff := pretty.Compare(link, got); diff != "" {
+		t.Errorf("login link retrieved from storage did not match: %s", diff)
+	}
+
+	_, err = s.ConsumeLoginLink(ctx, link.ID)
+	mustBeErrNotFound(t, "login link", err)
+}
+
+func testAuditEvents(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	now := time.Now().UTC().Round(time.Millisecond)
+
+	e1 := storage.AuditEvent{
+		ID:          storage.NewID(),
+		Type:        "login",
+		Severity:    "info",
+		Time:        now.Add(-2 * time.Hour),
+		ClientID:    "client1",
+		Subject:     "subject1",
+		ConnectorID: "conn1",
+		SourceIPs:   []string{"192.0.2.1"},
+	}
+	e2 := storage.AuditEvent{
+		ID:        storage.NewID(),
+		Type:      "refresh_token_reuse",
+		Severity:  "high",
+		Time:      now.Add(-time.Hour),
+		ClientID:  "client2",
+		Subject:   "subject1",
+		SourceIPs: []string{"192.0.2.1", "198.51.100.7"},
+		Message:   "refresh token presented twice",
+		Revoked:   true,
+	}
+	e3 := storage.AuditEvent{
+		ID:       storage.NewID(),
+		Type:     "login",
+		Severity: "info",
+		Time:     now,
+		ClientID: "client1",
+		Subject:  "subject2",
+	}
+	for _, e := range []storage.AuditEvent{e1, e2, e3} {
+		if err := s.CreateAuditEvent(ctx, e); err != nil {
+			t.Fatalf("create audit event: %v", err)
+		}
+	}
+	err := s.CreateAuditEvent(ctx, e1)
+	mustBeErrAlreadyExists(t, "audit event", err)
+
+	ids := func(events []storage.AuditEvent) []string {
+		var ids []string
+		for _, e := range events {
+			ids = append(ids, e.ID)
+		}
+		return ids
+	}
+
+	tests := []struct {
+		name   string
+		filter storage.AuditEventFilter
+		want   []string
+	}{
+		{"all", storage.AuditEventFilter{}, []string{e3.ID, e2.ID, e1.ID}},
+		{"subject", storage.AuditEventFilter{Subject: "subject1"}, []string{e2.ID, e1.ID}},
+		{"client", storage.AuditEventFilter{ClientID: "client1"}, []string{e3.ID, e1.ID}},
+		{"type", storage.AuditEventFilter{Type: "refresh_token_reuse"}, []string{e2.ID}},
+		{"time range", storage.AuditEventFilter{Since: e2.Time, Until: e3.Time}, []string{e2.ID}},
+		{"limit", storage.AuditEventFilter{Limit: 2}, []string{e3.ID, e2.ID}},
+		{"combined", storage.AuditEventFilter{ClientID: "client1", Type: "login", Since: e1.Time.Add(time.Minute)}, []string{e3.ID}},
+	}
+	for _, tc := range tests {
+		events, err := s.ListAuditEvents(ctx, tc.filter)
+		if err != nil {
+			t.Fatalf("%s: list audit events: %v", tc.name, err)
+		}
+		if diff := pretty.Compare(tc.want, ids(events)); diff != "" {
+			t.Errorf("%s: unexpected audit events: %s", tc.name, diff)
+		}
+	}
+
+	events, err := s.ListAuditEvents(ctx, storage.AuditEventFilter{Type: "refresh_token_reuse"})
+	if err != nil {
+		t.Fatalf("list audit events: %v", err)
+	}
+	if len(events) == 1 {
+		got := events[0]
+		got.Time = got.Time.UTC()
+		if diff := pretty.Compare(e2, got); diff != "" {
+			t.Errorf("audit event retrieved from storage did not match: %s", diff)
+		}
+	}
+
+	if err := s.UpdateAuditEvent(ctx, e2.ID, func(old storage.AuditEvent) (storage.AuditEvent, error) {
+		old.Subject = "anonymous"
+		old.SourceIPs = nil
+		return old, nil
+	}); err != nil {
+		t.Fatalf("update audit event: %v", err)
+	}
+	events, err = s.ListAuditEvents(ctx, storage.AuditEventFilter{Subject: "anonymous"})
+	if err != nil {
+		t.Fatalf("list audit events: %v", err)
+	}
+	if len(events) != 1 || events[0].ID != e2.ID || len(events[0].SourceIPs) != 0 || events[0].Type != e2.Type {
+		t.Errorf("audit event not updated: %+v", events)
+	}
+
+	n, err := s.PruneAuditEvents(ctx, e2.Time.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("prune audit events: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 pruned audit events, got %d", n)
+	}
+	events, err = s.ListAuditEvents(ctx, storage.AuditEventFilter{})
+	if err != nil {
+		t.Fatalf("list audit events: %v", err)
+	}
+	if diff := pretty.Compare([]string{e3.ID}, ids(events)); diff != "" {
+		t.Errorf("unexpected audit events after pruning: %s", diff)
+	}
+}
+
 func testGC(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
 	est, err := time.LoadLocation("America/New_York")
 	if err != nil {
 		t.Fatal(err)
@@ -753,12 +1456,12 @@ func testGC(t *testing.T, s storage.Storage) {
 		},
 	}
 
-	if err := s.CreateAuthCode(c); err != nil {
+	if err := s.CreateAuthCode(ctx, c); err != nil {
 		t.Fatalf("failed creating auth code: %v", err)
 	}
 
 	for _, tz := range []*time.Location{time.UTC, est, pst} {
-		result, err := s.GarbageCollect(expiry.Add(-time.Hour).In(tz))
+		result, err := s.GarbageCollect(ctx, expiry.Add(-time.Hour).In(tz))
 		if err != nil {
 			t.Errorf("garbage collection failed: %v", err)
 		} else {
@@ -766,20 +1469,20 @@ func testGC(t *testing.T, s storage.Storage) {
 				t.Errorf("expected no garbage collection results, got %#v", result)
 			}
 		}
-		if _, err := s.GetAuthCode(c.ID); err != nil {
+		if _, err := s.GetAuthCode(ctx, c.ID); err != nil {
 			t.Errorf("expected to be able to get auth code after GC: %v", err)
 		}
 	}
 
-	if r, err := s.GarbageCollect(expiry.Add(time.Hour)); err != nil {
+	if r, err := s.GarbageCollect(ctx, expiry.Add(time.Hour)); err != nil {
 		t.Errorf("garbage collection failed: %v", err)
 	} else if r.AuthCodes != 1 {
 		t.Errorf("expected to garbage collect 1 objects, got %d", r.AuthCodes)
 	}
 
-	if _, err := s.GetAuthCode(c.ID); err == nil {
+	if _, err := s.GetAuthCode(ctx, c.ID); err == nil {
 		t.Errorf("expected auth code to be GC'd")
-	} else if err != storage.ErrNotFound {
+	} else if !errors.Is(err, storage.ErrNotFound) {
 		t.Errorf("expected storage.ErrNotFound, got %v", err)
 	}
 
@@ -805,12 +1508,12 @@ func testGC(t *testing.T, s storage.Storage) {
 		},
 	}
 
-	if err := s.CreateAuthRequest(a); err != nil {
+	if err := s.CreateAuthRequest(ctx, a); err != nil {
 		t.Fatalf("failed creating auth request: %v", err)
 	}
 
 	for _, tz := range []*time.Location{time.UTC, est, pst} {
-		result, err := s.GarbageCollect(expiry.Add(-time.Hour).In(tz))
+		result, err := s.GarbageCollect(ctx, expiry.Add(-time.Hour).In(tz))
 		if err != nil {
 			t.Errorf("garbage collection failed: %v", err)
 		} else {
@@ -818,20 +1521,160 @@ func testGC(t *testing.T, s storage.Storage) {
 				t.Errorf("expected no garbage collection results, got %#v", result)
 			}
 		}
-		if _, err := s.GetAuthRequest(a.ID); err != nil {
+		if _, err := s.GetAuthRequest(ctx, a.ID); err != nil {
 			t.Errorf("expected to be able to get auth request after GC: %v", err)
 		}
 	}
 
-	if r, err := s.GarbageCollect(expiry.Add(time.Hour)); err != nil {
+	if r, err := s.GarbageCollect(ctx, expiry.Add(time.Hour)); err != nil {
 		t.Errorf("garbage collection failed: %v", err)
 	} else if r.AuthRequests != 1 {
 		t.Errorf("expected to garbage collect 1 objects, got %d", r.AuthRequests)
 	}
 
-	if _, err := s.GetAuthRequest(a.ID); err == nil {
+	if _, err := s.GetAuthRequest(ctx, a.ID); err == nil {
 		t.Errorf("expected auth request to be GC'd")
-	} else if err != storage.ErrNotFound {
+	} else if !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected storage.ErrNotFound, got %v", err)
+	}
+
+	rt := storage.RevokedToken{
+		ID:        storage.NewID(),
+		ClientID:  "foobar",
+		Reason:    "revoked",
+		RevokedAt: expiry.Add(-24 * time.Hour),
+		Expiry:    expiry,
+	}
+
+	if err := s.CreateRevokedToken(ctx, rt); err != nil {
+		t.Fatalf("failed creating revoked token: %v", err)
+	}
+
+	for _, tz := range []*time.Location{time.UTC, est, pst} {
+		result, err := s.GarbageCollect(ctx, expiry.Add(-time.Hour).In(tz))
+		if err != nil {
+			t.Errorf("garbage collection failed: %v", err)
+		} else if result.RevokedTokens != 0 {
+			t.Errorf("expected no garbage collection results, got %#v", result)
+		}
+		if _, err := s.GetRevokedToken(ctx, rt.ID); err != nil {
+			t.Errorf("expected to be able to get revoked token after GC: %v", err)
+		}
+	}
+
+	if r, err := s.GarbageCollect(ctx, expiry.Add(time.Hour)); err != nil {
+		t.Errorf("garbage collection failed: %v", err)
+	} else if r.RevokedTokens != 1 {
+		t.Errorf("expected to garbage collect 1 objects, got %d", r.RevokedTokens)
+	}
+
+	if _, err := s.GetRevokedToken(ctx, rt.ID); err == nil {
+		t.Errorf("expected revoked token to be GC'd")
+	} else if !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected storage.ErrNotFound, got %v", err)
+	}
+
+	session := storage.Session{
+		ID:          storage.NewID(),
+		ConnectorID: "ldap",
+		Claims:      storage.Claims{UserID: "1", Groups: []string{}},
+		CreatedAt:   expiry.Add(-24 * time.Hour),
+		Expiry:      expiry,
+	}
+
+	if err := s.CreateSession(ctx, session); err != nil {
+		t.Fatalf("failed creating session: %v", err)
+	}
+
+	for _, tz := range []*time.Location{time.UTC, est, pst} {
+		result, err := s.GarbageCollect(ctx, expiry.Add(-time.Hour).In(tz))
+		if err != nil {
+			t.Errorf("garbage collection failed: %v", err)
+		} else if result.Sessions != 0 {
+			t.Errorf("expected no garbage collection results, got %#v", result)
+		}
+		if _, err := s.GetSession(ctx, session.ID); err != nil {
+			t.Errorf("expected to be able to get session after GC: %v", err)
+		}
+	}
+
+	if r, err := s.GarbageCollect(ctx, expiry.Add(time.Hour)); err != nil {
+		t.Errorf("garbage collection failed: %v", err)
+	} else if r.Sessions != 1 {
+		t.Errorf("expected to garbage collect 1 objects, got %d", r.Sessions)
+	}
+
+	if _, err := s.GetSession(ctx, session.ID); err == nil {
+		t.Errorf("expected session to be GC'd")
+	} else if !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected storage.ErrNotFound, got %v", err)
+	}
+
+	code := storage.PreAuthorizedCode{
+		ID:          storage.NewID(),
+		ClientID:    "wallet",
+		ConnectorID: "ldap",
+		Claims:      storage.Claims{UserID: "1", Groups: []string{}},
+		CreatedAt:   expiry.Add(-time.Hour),
+		Expiry:      expiry,
+	}
+
+	if err := s.CreatePreAuthorizedCode(ctx, code); err != nil {
+		t.Fatalf("failed creating pre-authorized code: %v", err)
+	}
+
+	for _, tz := range []*time.Location{time.UTC, est, pst} {
+		result, err := s.GarbageCollect(ctx, expiry.Add(-time.Hour).In(tz))
+		if err != nil {
+			t.Errorf("garbage collection failed: %v", err)
+		} else if result.PreAuthorizedCodes != 0 {
+			t.Errorf("expected no garbage collection results, got %#v", result)
+		}
+	}
+
+	if r, err := s.GarbageCollect(ctx, expiry.Add(time.Hour)); err != nil {
+		t.Errorf("garbage collection failed: %v", err)
+	} else if r.PreAuthorizedCodes != 1 {
+		t.Errorf("expected to garbage collect 1 objects, got %d", r.PreAuthorizedCodes)
+	}
+
+	if _, err := s.ConsumePreAuthorizedCode(ctx, code.ID); err == nil {
+		t.Errorf("expected pre-authorized code to be GC'd")
+	} else if !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected storage.ErrNotFound, got %v", err)
+	}
+
+	link := storage.LoginLink{
+		ID:            storage.NewID(),
+		AuthRequestID: storage.NewID(),
+		ConnectorID:   "email",
+		Email:         "jane@example.com",
+		CreatedAt:     expiry.Add(-time.Hour),
+		Expiry:        expiry,
+	}
+
+	if err := s.CreateLoginLink(ctx, link); err != nil {
+		t.Fatalf("failed creating login link: %v", err)
+	}
+
+	for _, tz := range []*time.Location{time.UTC, est, pst} {
+		result, err := s.GarbageCollect(ctx, expiry.Add(-time.Hour).In(tz))
+		if err != nil {
+			t.Errorf("garbage collection failed: %v", err)
+		} else if result.LoginLinks != 0 {
+			t.Errorf("expected no garbage collection results, got %#v", result)
+		}
+	}
+
+	if r, err := s.GarbageCollect(ctx, expiry.Add(time.Hour)); err != nil {
+		t.Errorf("garbage collection failed: %v", err)
+	} else if r.LoginLinks != 1 {
+		t.Errorf("expected to garbage collect 1 objects, got %d", r.LoginLinks)
+	}
+
+	if _, err := s.ConsumeLoginLink(ctx, link.ID); err == nil {
+		t.Errorf("expected login link to be GC'd")
+	} else if !errors.Is(err, storage.ErrNotFound) {
 		t.Errorf("expected storage.ErrNotFound, got %v", err)
 	}
 }
@@ -839,6 +1682,7 @@ func testGC(t *testing.T, s storage.Storage) {
 // testTimezones tests that backends either fully support timezones or
 // do the correct standardization.
 func testTimezones(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
 	est, err := time.LoadLocation("America/New_York")
 	if err != nil {
 		t.Fatal(err)
@@ -864,10 +1708,10 @@ func testTimezones(t *testing.T, s storage.Storage) {
 			Groups:        []string{"a", "b"},
 		},
 	}
-	if err := s.CreateAuthCode(c); err != nil {
+	if err := s.CreateAuthCode(ctx, c); err != nil {
 		t.Fatalf("failed creating auth code: %v", err)
 	}
-	got, err := s.GetAuthCode(c.ID)
+	got, err := s.GetAuthCode(ctx, c.ID)
 	if err != nil {
 		t.Fatalf("failed to get auth code: %v", err)
 	}
@@ -0,0 +1,211 @@
+package dynamodb
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// errConditionFailed is returned when the condition of a conditional write
+// doesn't hold.
+var errConditionFailed = errors.New("dynamodb: condition failed")
+
+type credentials struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+}
+
+// attributeValue is a DynamoDB attribute value of one of the types dex
+// stores: strings and numbers.
+type attributeValue struct {
+	S string `json:"S,omitempty"`
+	N string `json:"N,omitempty"`
+}
+
+type item map[string]attributeValue
+
+// client calls the operations of the DynamoDB JSON API dex needs.
+//
+// https://docs.aws.amazon.com/amazondynamodb/latest/APIReference/Welcome.html
+type client struct {
+	endpoint   string
+	region     string
+	creds      credentials
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+type getItemInput struct {
+	TableName      string
+	Key            item
+	ConsistentRead bool
+}
+
+type getItemOutput struct {
+	Item item
+}
+
+type putItemInput struct {
+	TableName                 string
+	Item                      item
+	ConditionExpression       string            `json:",omitempty"`
+	ExpressionAttributeNames  map[string]string `json:",omitempty"`
+	ExpressionAttributeValues item              `json:",omitempty"`
+}
+
+type deleteItemInput struct {
+	TableName                string
+	Key                      item
+	ConditionExpression      string            `json:",omitempty"`
+	ExpressionAttributeNames map[string]string `json:",omitempty"`
+	ReturnValues             string            `json:",omitempty"`
+}
+
+type deleteItemOutput struct {
+	Attributes item
+}
+
+type queryInput struct {
+	TableName                 string
+	KeyConditionExpression    string
+	ExpressionAttributeNames  map[string]string
+	ExpressionAttributeValues item
+	ConsistentRead            bool
+	ExclusiveStartKey         item `json:",omitempty"`
+}
+
+type queryOutput struct {
+	Items            []item
+	LastEvaluatedKey item
+}
+
+// do calls the operation with the input and decodes its output into out.
+func (c *client) do(ctx context.Context, operation string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, c.endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.0")
+	req.Header.Set("X-Amz-Target", "DynamoDB_20120810."+operation)
+	sign(req, body, c.creds, c.region, "dynamodb", c.now())
+
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("dynamodb: %s: %w", operation, err)
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("dynamodb: %s: read response: %w", operation, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Type         string `json:"__type"`
+			Message      string `json:"message"`
+			MessageUpper string `json:"Message"`
+		}
+		json.Unmarshal(respBody, &apiErr)
+		typ := apiErr.Type[strings.LastIndex(apiErr.Type, "#")+1:]
+		if typ == "ConditionalCheckFailedException" {
+			return errConditionFailed
+		}
+		msg := apiErr.Message
+		if msg == "" {
+			msg = apiErr.MessageUpper
+		}
+		return fmt.Errorf("dynamodb: %s: %s %s: %s", operation, resp.Status, typ, msg)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("dynamodb: %s: decode response: %w", operation, err)
+	}
+	return nil
+}
+
+// sign adds an AWS Signature Version 4 to the request, signing all of its
+// headers.
+//
+// https://docs.aws.amazon.com/general/latest/gr/sigv4_signing.html
+func sign(r *http.Request, body []byte, creds credentials, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	r.Header.Set("X-Amz-Date", amzDate)
+	if creds.sessionToken != "" {
+		r.Header.Set("X-Amz-Security-Token", creds.sessionToken)
+	}
+
+	host := r.Host
+	if host == "" {
+		host = r.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range r.Header {
+		trimmed := make([]string, len(values))
+		for i, v := range values {
+			trimmed[i] = strings.Join(strings.Fields(v), " ")
+		}
+		headers[strings.ToLower(name)] = strings.Join(trimmed, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := r.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		path,
+		strings.Replace(r.URL.Query().Encode(), "+", "%20", -1),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hashHex(body),
+	}, "\n")
+
+	scope := strings.Join([]string{amzDate[:8], region, service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hashHex([]byte(canonicalRequest))}, "\n")
+
+	key := []byte("AWS4" + creds.secretAccessKey)
+	for _, part := range []string{amzDate[:8], region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	r.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.accessKeyID, scope, signedHeaders, signature))
+}
+
+func hashHex(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,85 @@
+package dynamodb
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/dexidp/dex/pkg/log"
+	"github.com/dexidp/dex/storage"
+)
+
+// DynamoDB options for storing dex's state in an Amazon DynamoDB table.
+//
+// The table must have a string partition key named "kind" and a string sort
+// key named "id". Enable time to live on the "expiry" attribute to let
+// DynamoDB delete expired auth requests, auth codes, revoked tokens and
+// sessions in between garbage collections.
+type DynamoDB struct {
+	Table string `json:"table" yaml:"table"`
+
+	// Region of the table. Defaults to the AWS_REGION environment variable.
+	Region string `json:"region" yaml:"region"`
+	// Endpoint overrides the regional DynamoDB endpoint, for example to use
+	// DynamoDB Local.
+	Endpoint string `json:"endpoint" yaml:"endpoint"`
+
+	// Static credentials. Default to the AWS_ACCESS_KEY_ID,
+	// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables,
+	// which AWS Lambda and ECS provide.
+	AccessKeyID     string `json:"accessKeyID" yaml:"accessKeyID"`
+	SecretAccessKey string `json:"secretAccessKey" yaml:"secretAccessKey"`
+	SessionToken    string `json:"sessionToken" yaml:"sessionToken"`
+}
+
+// Open creates a new storage implementation backed by DynamoDB.
+func (d *DynamoDB) Open(logger log.Logger) (storage.Storage, error) {
+	return d.open(logger)
+}
+
+func (d *DynamoDB) open(logger log.Logger) (*conn, error) {
+	if d.Table == "" {
+		return nil, errors.New("dynamodb: no table specified")
+	}
+	region := d.Region
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		return nil, errors.New("dynamodb: no region specified")
+	}
+	endpoint := d.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://dynamodb.%s.amazonaws.com", region)
+	}
+
+	creds := credentials{
+		accessKeyID:     d.AccessKeyID,
+		secretAccessKey: d.SecretAccessKey,
+		sessionToken:    d.SessionToken,
+	}
+	if creds.accessKeyID == "" {
+		creds = credentials{
+			accessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			secretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			sessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}
+	}
+	if creds.accessKeyID == "" || creds.secretAccessKey == "" {
+		return nil, errors.New("dynamodb: no credentials specified")
+	}
+
+	return &conn{
+		db: &client{
+			endpoint:   endpoint,
+			region:     region,
+			creds:      creds,
+			httpClient: &http.Client{Timeout: defaultStorageTimeout},
+			now:        time.Now,
+		},
+		table:  d.Table,
+		logger: logger,
+	}, nil
+}
@@ -0,0 +1,979 @@
+// Package dynamodb provides a storage implementation backed by a single
+// Amazon DynamoDB table.
+package dynamodb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dexidp/dex/pkg/log"
+	"github.com/dexidp/dex/storage"
+)
+
+// Every object is stored as an item of its kind and ID, holding the JSON
+// encoded object. Writes are conditional on the version of the item, so
+// concurrent updates fail instead of overwriting each other.
+const (
+	attrKind    = "kind"
+	attrID      = "id"
+	attrData    = "data"
+	attrVersion = "version"
+	// attrExpiry is the time to live attribute, in Unix seconds.
+	attrExpiry = "expiry"
+)
+
+const (
+	kindClient             = "client"
+	kindAuthCode           = "auth_code"
+	kindRefreshToken       = "refresh_token"
+	kindAuthRequest        = "auth_req"
+	kindPassword           = "password"
+	kindOfflineSession     = "offline_session"
+	kindConnector          = "connector"
+	kindTerms              = "terms_acceptance"
+	kindAuditEvent         = "audit_event"
+	kindAPIKey             = "api_key"
+	kindServiceAccount     = "service_account"
+	kindRevokedToken       = "revoked_token"
+	kindSession            = "session"
+	kindPreAuthCode        = "pre_authorized_code"
+	kindWebAuthnCredential = "webauthn_credential"
+	kindLoginLink          = "login_link"
+	kindTOTPSecret         = "totp_secret"
+	kindKeys               = "keys"
+	keysID                 = "openid-connect-keys"
+
+	// defaultStorageTimeout will be applied to all storage's operations.
+	defaultStorageTimeout = 5 * time.Second
+)
+
+type conn struct {
+	db     *client
+	table  string
+	logger log.Logger
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) GarbageCollect(ctx context.Context, now time.Time) (result storage.GCResult, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+
+	var authRequests []storage.AuthRequest
+	if err := c.list(ctx, kindAuthRequest, func(data []byte) error {
+		var a storage.AuthRequest
+		err := json.Unmarshal(data, &a)
+		authRequests = append(authRequests, a)
+		return err
+	}); err != nil {
+		return result, err
+	}
+	for _, a := range authRequests {
+		if now.After(a.Expiry) {
+			if err := c.delete(ctx, kindAuthRequest, a.ID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+				return result, fmt.Errorf("failed to delete auth request: %w", err)
+			}
+			result.AuthRequests++
+		}
+	}
+
+	var authCodes []storage.AuthCode
+	if err := c.list(ctx, kindAuthCode, func(data []byte) error {
+		var a storage.AuthCode
+		err := json.Unmarshal(data, &a)
+		authCodes = append(authCodes, a)
+		return err
+	}); err != nil {
+		return result, err
+	}
+	for _, a := range authCodes {
+		if now.After(a.Expiry) {
+			if err := c.delete(ctx, kindAuthCode, a.ID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+				return result, fmt.Errorf("failed to delete auth code: %w", err)
+			}
+			result.AuthCodes++
+		}
+	}
+
+	var revokedTokens []storage.RevokedToken
+	if err := c.list(ctx, kindRevokedToken, func(data []byte) error {
+		var t storage.RevokedToken
+		err := json.Unmarshal(data, &t)
+		revokedTokens = append(revokedTokens, t)
+		return err
+	}); err != nil {
+		return result, err
+	}
+	for _, t := range revokedTokens {
+		if now.After(t.Expiry) {
+			if err := c.delete(ctx, kindRevokedToken, t.ID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+				return result, fmt.Errorf("failed to delete revoked token: %w", err)
+			}
+			result.RevokedTokens++
+		}
+	}
+
+	sessions, err := c.ListSessions(ctx)
+	if err != nil {
+		return result, err
+	}
+	for _, s := range sessions {
+		if now.After(s.Expiry) {
+			if err := c.delete(ctx, kindSession, s.ID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+				return result, fmt.Errorf("failed to delete session: %w", err)
+			}
+			result.Sessions++
+		}
+	}
+
+	var codes []storage.PreAuthorizedCode
+	if err := c.list(ctx, kindPreAuthCode, func(data []byte) error {
+		var p storage.PreAuthorizedCode
+		err := json.Unmarshal(data, &p)
+		codes = append(codes, p)
+		return err
+	}); err != nil {
+		return result, err
+	}
+	for _, p := range codes {
+		if now.After(p.Expiry) {
+			if err := c.delete(ctx, kindPreAuthCode, p.ID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+				return result, fmt.Errorf("failed to delete pre-authorized code: %w", err)
+			}
+			result.PreAuthorizedCodes++
+		}
+	}
+
+	var links []storage.LoginLink
+	if err := c.list(ctx, kindLoginLink, func(data []byte) error {
+		var l storage.LoginLink
+		err := json.Unmarshal(data, &l)
+		links = append(links, l)
+		return err
+	}); err != nil {
+		return result, err
+	}
+	for _, l := range links {
+		if now.After(l.Expiry) {
+			if err := c.delete(ctx, kindLoginLink, l.ID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+				return result, fmt.Errorf("failed to delete login link: %w", err)
+			}
+			result.LoginLinks++
+		}
+	}
+	return result, nil
+}
+
+func (c *conn) CreateAuthRequest(ctx context.Context, a storage.AuthRequest) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.create(ctx, kindAuthRequest, a.ID, a)
+}
+
+func (c *conn) GetAuthRequest(ctx context.Context, id string) (a storage.AuthRequest, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	err = c.get(ctx, kindAuthRequest, id, &a)
+	return a, err
+}
+
+func (c *conn) UpdateAuthRequest(ctx context.Context, id string, updater func(a storage.AuthRequest) (storage.AuthRequest, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.update(ctx, kindAuthRequest, id, false, func(current []byte) (interface{}, error) {
+		var a storage.AuthRequest
+		if err := json.Unmarshal(current, &a); err != nil {
+			return nil, err
+		}
+		return updater(a)
+	})
+}
+
+func (c *conn) DeleteAuthRequest(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.delete(ctx, kindAuthRequest, id)
+}
+
+func (c *conn) CreateAuthCode(ctx context.Context, a storage.AuthCode) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.create(ctx, kindAuthCode, a.ID, a)
+}
+
+func (c *conn) GetAuthCode(ctx context.Context, id string) (a storage.AuthCode, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	err = c.get(ctx, kindAuthCode, id, &a)
+	return a, err
+}
+
+func (c *conn) DeleteAuthCode(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.delete(ctx, kindAuthCode, id)
+}
+
+func (c *conn) ConsumeAuthCode(ctx context.Context, id string) (a storage.AuthCode, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	out, err := c.deleteItem(ctx, kindAuthCode, id, true)
+	if err != nil {
+		return a, err
+	}
+	err = json.Unmarshal([]byte(out[attrData].S), &a)
+	return a, err
+}
+
+func (c *conn) CreateRefresh(ctx context.Context, r storage.RefreshToken) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.create(ctx, kindRefreshToken, r.ID, r)
+}
+
+func (c *conn) GetRefresh(ctx context.Context, id string) (r storage.RefreshToken, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	err = c.get(ctx, kindRefreshToken, id, &r)
+	return r, err
+}
+
+func (c *conn) UpdateRefreshToken(ctx context.Context, id string, updater func(old storage.RefreshToken) (storage.RefreshToken, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.update(ctx, kindRefreshToken, id, false, func(current []byte) (interface{}, error) {
+		var r storage.RefreshToken
+		if err := json.Unmarshal(current, &r); err != nil {
+			return nil, err
+		}
+		return updater(r)
+	})
+}
+
+func (c *conn) DeleteRefresh(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.delete(ctx, kindRefreshToken, id)
+}
+
+func (c *conn) ListRefreshTokens(ctx context.Context) (tokens []storage.RefreshToken, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	err = c.list(ctx, kindRefreshToken, func(data []byte) error {
+		var r storage.RefreshToken
+		err := json.Unmarshal(data, &r)
+		tokens = append(tokens, r)
+		return err
+	})
+	return tokens, err
+}
+
+func (c *conn) CreateClient(ctx context.Context, cli storage.Client) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.create(ctx, kindClient, cli.ID, cli)
+}
+
+func (c *conn) GetClient(ctx context.Context, id string) (cli storage.Client, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	err = c.get(ctx, kindClient, id, &cli)
+	return cli, err
+}
+
+func (c *conn) UpdateClient(ctx context.Context, id string, updater func(old storage.Client) (storage.Client, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.update(ctx, kindClient, id, false, func(current []byte) (interface{}, error) {
+		var cli storage.Client
+		if err := json.Unmarshal(current, &cli); err != nil {
+			return nil, err
+		}
+		return updater(cli)
+	})
+}
+
+func (c *conn) DeleteClient(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.delete(ctx, kindClient, id)
+}
+
+func (c *conn) ListClients(ctx context.Context) (clients []storage.Client, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	err = c.list(ctx, kindClient, func(data []byte) error {
+		var cli storage.Client
+		err := json.Unmarshal(data, &cli)
+		clients = append(clients, cli)
+		return err
+	})
+	return clients, err
+}
+
+func (c *conn) CreatePassword(ctx context.Context, p storage.Password) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.create(ctx, kindPassword, strings.ToLower(p.Email), p)
+}
+
+func (c *conn) GetPassword(ctx context.Context, email string) (p storage.Password, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	err = c.get(ctx, kindPassword, strings.ToLower(email), &p)
+	return p, err
+}
+
+func (c *conn) UpdatePassword(ctx context.Context, email string, updater func(p storage.Password) (storage.Password, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.update(ctx, kindPassword, strings.ToLower(email), false, func(current []byte) (interface{}, error) {
+		var p storage.Password
+		if err := json.Unmarshal(current, &p); err != nil {
+			return nil, err
+		}
+		return updater(p)
+	})
+}
+
+func (c *conn) DeletePassword(ctx context.Context, email string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.delete(ctx, kindPassword, strings.ToLower(email))
+}
+
+func (c *conn) ListPasswords(ctx context.Context) (passwords []storage.Password, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	err = c.list(ctx, kindPassword, func(data []byte) error {
+		var p storage.Password
+		err := json.Unmarshal(data, &p)
+		passwords = append(passwords, p)
+		return err
+	})
+	return passwords, err
+}
+
+func (c *conn) CreateOfflineSessions(ctx context.Context, s storage.OfflineSessions) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.create(ctx, kindOfflineSession, userKey(s.UserID, s.ConnID), s)
+}
+
+func (c *conn) UpdateOfflineSessions(ctx context.Context, userID string, connID string, updater func(s storage.OfflineSessions) (storage.OfflineSessions, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.update(ctx, kindOfflineSession, userKey(userID, connID), false, func(current []byte) (interface{}, error) {
+		var s storage.OfflineSessions
+		if err := json.Unmarshal(current, &s); err != nil {
+			return nil, err
+		}
+		return updater(s)
+	})
+}
+
+func (c *conn) GetOfflineSessions(ctx context.Context, userID string, connID string) (s storage.OfflineSessions, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	err = c.get(ctx, kindOfflineSession, userKey(userID, connID), &s)
+	return s, err
+}
+
+func (c *conn) DeleteOfflineSessions(ctx context.Context, userID string, connID string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.delete(ctx, kindOfflineSession, userKey(userID, connID))
+}
+
+func (c *conn) CreateTermsAcceptance(ctx context.Context, a storage.TermsAcceptance) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.create(ctx, kindTerms, userKey(a.UserID, a.ConnID), a)
+}
+
+func (c *conn) UpdateTermsAcceptance(ctx context.Context, userID string, connID string, updater func(a storage.TermsAcceptance) (storage.TermsAcceptance, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.update(ctx, kindTerms, userKey(userID, connID), false, func(current []byte) (interface{}, error) {
+		var a storage.TermsAcceptance
+		if err := json.Unmarshal(current, &a); err != nil {
+			return nil, err
+		}
+		return updater(a)
+	})
+}
+
+func (c *conn) GetTermsAcceptance(ctx context.Context, userID string, connID string) (a storage.TermsAcceptance, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	err = c.get(ctx, kindTerms, userKey(userID, connID), &a)
+	return a, err
+}
+
+func (c *conn) DeleteTermsAcceptance(ctx context.Context, userID string, connID string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.delete(ctx, kindTerms, userKey(userID, connID))
+}
+
+func (c *conn) CreateTOTPSecret(ctx context.Context, t storage.TOTPSecret) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.create(ctx, kindTOTPSecret, userKey(t.UserID, t.ConnID), t)
+}
+
+func (c *conn) UpdateTOTPSecret(ctx context.Context, userID string, connID string, updater func(t storage.TOTPSecret) (storage.TOTPSecret, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.update(ctx, kindTOTPSecret, userKey(userID, connID), false, func(current []byte) (interface{}, error) {
+		var t storage.TOTPSecret
+		if err := json.Unmarshal(current, &t); err != nil {
+			return nil, err
+		}
+		return updater(t)
+	})
+}
+
+func (c *conn) GetTOTPSecret(ctx context.Context, userID string, connID string) (t storage.TOTPSecret, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	err = c.get(ctx, kindTOTPSecret, userKey(userID, connID), &t)
+	return t, err
+}
+
+func (c *conn) DeleteTOTPSecret(ctx context.Context, userID string, connID string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.delete(ctx, kindTOTPSecret, userKey(userID, connID))
+}
+
+func (c *conn) CreateAuditEvent(ctx context.Context, e storage.AuditEvent) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.create(ctx, kindAuditEvent, e.ID, e)
+}
+
+func (c *conn) ListAuditEvents(ctx context.Context, filter storage.AuditEventFilter) ([]storage.AuditEvent, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	all, err := c.listAuditEvents(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var events []storage.AuditEvent
+	for _, e := range all {
+		if filter.Matches(e) {
+			events = append(events, e)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Time.After(events[j].Time) })
+	if filter.Limit > 0 && len(events) > filter.Limit {
+		events = events[:filter.Limit]
+	}
+	return events, nil
+}
+
+func (c *conn) UpdateAuditEvent(ctx context.Context, id string, updater func(e storage.AuditEvent) (storage.AuditEvent, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.update(ctx, kindAuditEvent, id, false, func(current []byte) (interface{}, error) {
+		var e storage.AuditEvent
+		if err := json.Unmarshal(current, &e); err != nil {
+			return nil, err
+		}
+		return updater(e)
+	})
+}
+
+func (c *conn) PruneAuditEvents(ctx context.Context, before time.Time) (n int64, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	events, err := c.listAuditEvents(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range events {
+		if !e.Time.Before(before) {
+			continue
+		}
+		if err := c.delete(ctx, kindAuditEvent, e.ID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return n, fmt.Errorf("failed to delete audit event: %w", err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+func (c *conn) listAuditEvents(ctx context.Context) (events []storage.AuditEvent, err error) {
+	err = c.list(ctx, kindAuditEvent, func(data []byte) error {
+		var e storage.AuditEvent
+		err := json.Unmarshal(data, &e)
+		events = append(events, e)
+		return err
+	})
+	return events, err
+}
+
+func (c *conn) CreateAPIKey(ctx context.Context, k storage.APIKey) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.create(ctx, kindAPIKey, k.ID, k)
+}
+
+func (c *conn) GetAPIKey(ctx context.Context, id string) (k storage.APIKey, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	err = c.get(ctx, kindAPIKey, id, &k)
+	return k, err
+}
+
+func (c *conn) ListAPIKeys(ctx context.Context) (keys []storage.APIKey, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	err = c.list(ctx, kindAPIKey, func(data []byte) error {
+		var k storage.APIKey
+		err := json.Unmarshal(data, &k)
+		keys = append(keys, k)
+		return err
+	})
+	return keys, err
+}
+
+func (c *conn) DeleteAPIKey(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.delete(ctx, kindAPIKey, id)
+}
+
+func (c *conn) CreateConnector(ctx context.Context, connector storage.Connector) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.create(ctx, kindConnector, connector.ID, connector)
+}
+
+func (c *conn) GetConnector(ctx context.Context, id string) (conn storage.Connector, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	err = c.get(ctx, kindConnector, id, &conn)
+	return conn, err
+}
+
+func (c *conn) UpdateConnector(ctx context.Context, id string, updater func(s storage.Connector) (storage.Connector, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.update(ctx, kindConnector, id, false, func(current []byte) (interface{}, error) {
+		var conn storage.Connector
+		if err := json.Unmarshal(current, &conn); err != nil {
+			return nil, err
+		}
+		return updater(conn)
+	})
+}
+
+func (c *conn) DeleteConnector(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.delete(ctx, kindConnector, id)
+}
+
+func (c *conn) ListConnectors(ctx context.Context) (connectors []storage.Connector, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	err = c.list(ctx, kindConnector, func(data []byte) error {
+		var conn storage.Connector
+		err := json.Unmarshal(data, &conn)
+		connectors = append(connectors, conn)
+		return err
+	})
+	return connectors, err
+}
+
+func (c *conn) GetKeys(ctx context.Context) (keys storage.Keys, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	err = c.get(ctx, kindKeys, keysID, &keys)
+	if errors.Is(err, storage.ErrNotFound) {
+		return keys, nil
+	}
+	return keys, err
+}
+
+func (c *conn) UpdateKeys(ctx context.Context, updater func(old storage.Keys) (storage.Keys, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.update(ctx, kindKeys, keysID, true, func(current []byte) (interface{}, error) {
+		var keys storage.Keys
+		if len(current) > 0 {
+			if err := json.Unmarshal(current, &keys); err != nil {
+				return nil, err
+			}
+		}
+		return updater(keys)
+	})
+}
+
+func (c *conn) CreateServiceAccount(ctx context.Context, a storage.ServiceAccount) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.create(ctx, kindServiceAccount, a.ID, a)
+}
+
+func (c *conn) GetServiceAccount(ctx context.Context, id string) (a storage.ServiceAccount, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	err = c.get(ctx, kindServiceAccount, id, &a)
+	return a, err
+}
+
+func (c *conn) ListServiceAccounts(ctx context.Context) (accounts []storage.ServiceAccount, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	err = c.list(ctx, kindServiceAccount, func(data []byte) error {
+		var a storage.ServiceAccount
+		err := json.Unmarshal(data, &a)
+		accounts = append(accounts, a)
+		return err
+	})
+	return accounts, err
+}
+
+func (c *conn) UpdateServiceAccount(ctx context.Context, id string, updater func(a storage.ServiceAccount) (storage.ServiceAccount, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.update(ctx, kindServiceAccount, id, false, func(current []byte) (interface{}, error) {
+		var a storage.ServiceAccount
+		if err := json.Unmarshal(current, &a); err != nil {
+			return nil, err
+		}
+		return updater(a)
+	})
+}
+
+func (c *conn) DeleteServiceAccount(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.delete(ctx, kindServiceAccount, id)
+}
+
+func (c *conn) CreateRevokedToken(ctx context.Context, t storage.RevokedToken) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.create(ctx, kindRevokedToken, t.ID, t)
+}
+
+func (c *conn) GetRevokedToken(ctx context.Context, id string) (t storage.RevokedToken, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	err = c.get(ctx, kindRevokedToken, id, &t)
+	return t, err
+}
+
+func (c *conn) CreateSession(ctx context.Context, s storage.Session) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.create(ctx, kindSession, s.ID, s)
+}
+
+func (c *conn) GetSession(ctx context.Context, id string) (s storage.Session, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	err = c.get(ctx, kindSession, id, &s)
+	return s, err
+}
+
+func (c *conn) ListSessions(ctx context.Context) (sessions []storage.Session, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	err = c.list(ctx, kindSession, func(data []byte) error {
+		var s storage.Session
+		err := json.Unmarshal(data, &s)
+		sessions = append(sessions, s)
+		return err
+	})
+	return sessions, err
+}
+
+func (c *conn) UpdateSession(ctx context.Context, id string, updater func(s storage.Session) (storage.Session, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.update(ctx, kindSession, id, false, func(current []byte) (interface{}, error) {
+		var s storage.Session
+		if err := json.Unmarshal(current, &s); err != nil {
+			return nil, err
+		}
+		return updater(s)
+	})
+}
+
+func (c *conn) DeleteSession(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.delete(ctx, kindSession, id)
+}
+
+func userKey(userID, connID string) string {
+	return strings.ToLower(userID + "|" + connID)
+}
+
+func itemKey(kind, id string) item {
+	return item{attrKind: {S: kind}, attrID: {S: id}}
+}
+
+func (c *conn) CreatePreAuthorizedCode(ctx context.Context, p storage.PreAuthorizedCode) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.create(ctx, kindPreAuthCode, p.ID, p)
+}
+
+func (c *conn) ConsumePreAuthorizedCode(ctx context.Context, id string) (p storage.PreAuthorizedCode, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	out, err := c.deleteItem(ctx, kindPreAuthCode, id, true)
+	if err != nil {
+		return p, err
+	}
+	err = json.Unmarshal([]byte(out[attrData].S), &p)
+	return p, err
+}
+
+func (c *conn) CreateWebAuthnCredential(ctx context.Context, w storage.WebAuthnCredential) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.create(ctx, kindWebAuthnCredential, w.ID, w)
+}
+
+func (c *conn) GetWebAuthnCredential(ctx context.Context, id string) (w storage.WebAuthnCredential, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	err = c.get(ctx, kindWebAuthnCredential, id, &w)
+	return w, err
+}
+
+func (c *conn) ListWebAuthnCredentials(ctx context.Context, userID string, connID string) (creds []storage.WebAuthnCredential, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	err = c.list(ctx, kindWebAuthnCredential, func(data []byte) error {
+		var w storage.WebAuthnCredential
+		if err := json.Unmarshal(data, &w); err != nil {
+			return err
+		}
+		if w.UserID == userID && w.ConnectorID == connID {
+			creds = append(creds, w)
+		}
+		return nil
+	})
+	return creds, err
+}
+
+func (c *conn) UpdateWebAuthnCredential(ctx context.Context, id string, updater func(w storage.WebAuthnCredential) (storage.WebAuthnCredential, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.update(ctx, kindWebAuthnCredential, id, false, func(current []byte) (interface{}, error) {
+		var w storage.WebAuthnCredential
+		if err := json.Unmarshal(current, &w); err != nil {
+			return nil, err
+		}
+		return updater(w)
+	})
+}
+
+func (c *conn) DeleteWebAuthnCredential(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.delete(ctx, kindWebAuthnCredential, id)
+}
+
+func (c *conn) CreateLoginLink(ctx context.Context, l storage.LoginLink) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.create(ctx, kindLoginLink, l.ID, l)
+}
+
+func (c *conn) ConsumeLoginLink(ctx context.Context, id string) (l storage.LoginLink, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	out, err := c.deleteItem(ctx, kindLoginLink, id, true)
+	if err != nil {
+		return l, err
+	}
+	err = json.Unmarshal([]byte(out[attrData].S), &l)
+	return l, err
+}
+
+// expiry returns the time after which the object can be deleted, or the zero
+// time if it's kept until deleted.
+func expiry(value interface{}) time.Time {
+	switch v := value.(type) {
+	case storage.AuthRequest:
+		return v.Expiry
+	case storage.AuthCode:
+		return v.Expiry
+	case storage.RevokedToken:
+		return v.Expiry
+	case storage.Session:
+		return v.Expiry
+	case storage.PreAuthorizedCode:
+		return v.Expiry
+	case storage.LoginLink:
+		return v.Expiry
+	}
+	return time.Time{}
+}
+
+// newItem returns the item storing the object, setting its expiry as the
+// item's time to live.
+func newItem(kind, id string, value interface{}, version int64) (item, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	it := itemKey(kind, id)
+	it[attrData] = attributeValue{S: string(data)}
+	it[attrVersion] = attributeValue{N: strconv.FormatInt(version, 10)}
+	if t := expiry(value); !t.IsZero() {
+		it[attrExpiry] = attributeValue{N: strconv.FormatInt(t.Unix(), 10)}
+	}
+	return it, nil
+}
+
+// create stores a new object.
+func (c *conn) create(ctx context.Context, kind, id string, value interface{}) error {
+	it, err := newItem(kind, id, value, 1)
+	if err != nil {
+		return err
+	}
+	err = c.db.do(ctx, "PutItem", putItemInput{
+		TableName:                c.table,
+		Item:                     it,
+		ConditionExpression:      "attribute_not_exists(#id)",
+		ExpressionAttributeNames: map[string]string{"#id": attrID},
+	}, nil)
+	if errors.Is(err, errConditionFailed) {
+		return storage.ErrAlreadyExists
+	}
+	return err
+}
+
+func (c *conn) getItem(ctx context.Context, kind, id string) (item, error) {
+	var out getItemOutput
+	if err := c.db.do(ctx, "GetItem", getItemInput{
+		TableName:      c.table,
+		Key:            itemKey(kind, id),
+		ConsistentRead: true,
+	}, &out); err != nil {
+		return nil, err
+	}
+	if out.Item == nil {
+		return nil, storage.ErrNotFound
+	}
+	return out.Item, nil
+}
+
+func (c *conn) get(ctx context.Context, kind, id string, value interface{}) error {
+	it, err := c.getItem(ctx, kind, id)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(it[attrData].S), value)
+}
+
+// update replaces an object, unless it was changed since it was read. With
+// upsert, a missing object is created and update is passed nil.
+func (c *conn) update(ctx context.Context, kind, id string, upsert bool, update func(current []byte) (interface{}, error)) error {
+	var (
+		current []byte
+		version int64
+	)
+	it, err := c.getItem(ctx, kind, id)
+	switch {
+	case err == nil:
+		current = []byte(it[attrData].S)
+		if version, err = strconv.ParseInt(it[attrVersion].N, 10, 64); err != nil {
+			return fmt.Errorf("invalid version of %s %q: %w", kind, id, err)
+		}
+	case !upsert || !errors.Is(err, storage.ErrNotFound):
+		return err
+	}
+
+	updated, err := update(current)
+	if err != nil {
+		return err
+	}
+	if it, err = newItem(kind, id, updated, version+1); err != nil {
+		return err
+	}
+
+	in := putItemInput{TableName: c.table, Item: it}
+	if version == 0 {
+		in.ConditionExpression = "attribute_not_exists(#id)"
+		in.ExpressionAttributeNames = map[string]string{"#id": attrID}
+	} else {
+		in.ConditionExpression = "#version = :version"
+		in.ExpressionAttributeNames = map[string]string{"#version": attrVersion}
+		in.ExpressionAttributeValues = item{":version": {N: strconv.FormatInt(version, 10)}}
+	}
+	err = c.db.do(ctx, "PutItem", in, nil)
+	if errors.Is(err, errConditionFailed) {
+		return fmt.Errorf("failed to update %s %q: concurrent conflicting update happened", kind, id)
+	}
+	return err
+}
+
+// deleteItem deletes an existing item, returning its attributes if old is
+// set.
+func (c *conn) deleteItem(ctx context.Context, kind, id string, old bool) (item, error) {
+	in := deleteItemInput{
+		TableName:                c.table,
+		Key:                      itemKey(kind, id),
+		ConditionExpression:      "attribute_exists(#id)",
+		ExpressionAttributeNames: map[string]string{"#id": attrID},
+	}
+	if old {
+		in.ReturnValues = "ALL_OLD"
+	}
+	var out deleteItemOutput
+	err := c.db.do(ctx, "DeleteItem", in, &out)
+	if errors.Is(err, errConditionFailed) {
+		return nil, storage.ErrNotFound
+	}
+	return out.Attributes, err
+}
+
+func (c *conn) delete(ctx context.Context, kind, id string) error {
+	_, err := c.deleteItem(ctx, kind, id, false)
+	return err
+}
+
+// list calls fn with the data of every item of the kind.
+func (c *conn) list(ctx context.Context, kind string, fn func(data []byte) error) error {
+	in := queryInput{
+		TableName:                 c.table,
+		KeyConditionExpression:    "#kind = :kind",
+		ExpressionAttributeNames:  map[string]string{"#kind": attrKind},
+		ExpressionAttributeValues: item{":kind": {S: kind}},
+		ConsistentRead:            true,
+	}
+	for {
+		var out queryOutput
+		if err := c.db.do(ctx, "Query", in, &out); err != nil {
+			return err
+		}
+		for _, it := range out.Items {
+			if err := fn([]byte(it[attrData].S)); err != nil {
+				return err
+			}
+		}
+		if len(out.LastEvaluatedKey) == 0 {
+			return nil
+		}
+		in.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
@@ -0,0 +1,183 @@
+package dynamodb
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/dexidp/dex/storage"
+	"github.com/dexidp/dex/storage/conformance"
+)
+
+var logger = &logrus.Logger{
+	Out:       os.Stderr,
+	Formatter: &logrus.TextFormatter{DisableColors: true},
+	Level:     logrus.DebugLevel,
+}
+
+// TestSign checks the signature against the get-vanilla example of the AWS
+// Signature Version 4 test suite.
+func TestSign(t *testing.T) {
+	r, err := http.NewRequest(http.MethodGet, "http://example.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds := credentials{accessKeyID: "AKIDEXAMPLE", secretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	sign(r, nil, creds, "us-east-1", "service", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, " +
+		"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := r.Header.Get("Authorization"); got != want {
+		t.Errorf("expected authorization %q, got %q", want, got)
+	}
+}
+
+// fakeDynamoDB serves the operations and condition expressions dex uses
+// from memory.
+type fakeDynamoDB struct {
+	mu    sync.Mutex
+	items map[[2]string]item
+}
+
+func (f *fakeDynamoDB) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Key                       item
+		Item                      item
+		ConditionExpression       string
+		ExpressionAttributeValues item
+		ReturnValues              string
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+		http.Error(w, "unsigned request", http.StatusForbidden)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := func(it item) [2]string { return [2]string{it[attrKind].S, it[attrID].S} }
+	check := func(existing item, ok bool) bool {
+		switch in.ConditionExpression {
+		case "":
+			return true
+		case "attribute_not_exists(#id)":
+			return !ok
+		case "attribute_exists(#id)":
+			return ok
+		case "#version = :version":
+			return ok && existing[attrVersion].N == in.ExpressionAttributeValues[":version"].N
+		}
+		panic("unexpected condition " + in.ConditionExpression)
+	}
+	conditionFailed := func() {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"__type":"com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException"}`))
+	}
+
+	var out interface{} = struct{}{}
+	switch op := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "DynamoDB_20120810."); op {
+	case "GetItem":
+		out = getItemOutput{Item: f.items[key(in.Key)]}
+	case "PutItem":
+		existing, ok := f.items[key(in.Item)]
+		if !check(existing, ok) {
+			conditionFailed()
+			return
+		}
+		f.items[key(in.Item)] = in.Item
+	case "DeleteItem":
+		existing, ok := f.items[key(in.Key)]
+		if !check(existing, ok) {
+			conditionFailed()
+			return
+		}
+		delete(f.items, key(in.Key))
+		if in.ReturnValues == "ALL_OLD" {
+			out = deleteItemOutput{Attributes: existing}
+		}
+	case "Query":
+		var items []item
+		for k, it := range f.items {
+			if k[0] == in.ExpressionAttributeValues[":kind"].S {
+				items = append(items, it)
+			}
+		}
+		sort.Slice(items, func(i, j int) bool { return items[i][attrID].S < items[j][attrID].S })
+		out = queryOutput{Items: items}
+	default:
+		http.Error(w, "unknown operation "+op, http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(out)
+}
+
+func TestDynamoDB(t *testing.T) {
+	newStorage := func() storage.Storage {
+		srv := httptest.NewServer(&fakeDynamoDB{items: make(map[[2]string]item)})
+		t.Cleanup(srv.Close)
+		d := &DynamoDB{
+			Table:           "dex",
+			Region:          "us-east-1",
+			Endpoint:        srv.URL,
+			AccessKeyID:     "AKIDEXAMPLE",
+			SecretAccessKey: "secret",
+		}
+		s, err := d.Open(logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	conformance.RunTests(t, newStorage)
+	conformance.RunTransactionTests(t, newStorage)
+}
+
+func TestExpiryAttribute(t *testing.T) {
+	f := &fakeDynamoDB{items: make(map[[2]string]item)}
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+	d := &DynamoDB{Table: "dex", Region: "us-east-1", Endpoint: srv.URL, AccessKeyID: "a", SecretAccessKey: "b"}
+	s, err := d.Open(logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	expiry := time.Unix(1600000000, 0)
+	if err := s.CreateSession(ctx, storage.Session{ID: "s1", Expiry: expiry}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CreateClient(ctx, storage.Client{ID: "c1"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.items[[2]string{kindSession, "s1"}][attrExpiry].N; got != "1600000000" {
+		t.Errorf("expected session expiry 1600000000, got %q", got)
+	}
+	if _, ok := f.items[[2]string{kindClient, "c1"}][attrExpiry]; ok {
+		t.Errorf("expected clients to have no expiry")
+	}
+
+	if err := s.UpdateSession(ctx, "s1", func(old storage.Session) (storage.Session, error) {
+		return old, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.items[[2]string{kindSession, "s1"}][attrExpiry].N; got != "1600000000" {
+		t.Errorf("expected update to keep expiry, got %q", got)
+	}
+}
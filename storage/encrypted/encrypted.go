@@ -0,0 +1,580 @@
+// Package encrypted provides a storage wrapper encrypting sensitive fields
+// at rest: client secrets, refresh tokens, the upstream connector data of
+// refresh tokens, offline sessions and sessions, connector configs and TOTP
+// secrets.
+//
+// Every value is encrypted with a new AES-256-GCM data key, which is in turn
+// encrypted with a configured key encryption key. Encrypted values are
+// encoded as "dexenc1:<key ID>:<encrypted data key>:<ciphertext>", with both
+// binary parts base64 encoded. Values without the prefix are read as they
+// are, so existing data can be encrypted by writing it again.
+package encrypted
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/dexidp/dex/storage"
+)
+
+const prefix = "dexenc1:"
+
+// localConnector is the ID of the password database connector, the only one
+// users enroll TOTP secrets for.
+const localConnector = "local"
+
+// Key is a key encryption key.
+type Key struct {
+	// ID is stored with every value encrypted with the key, to find the key
+	// to decrypt it with.
+	ID string
+	// Key must be 32 bytes long.
+	Key []byte
+}
+
+type crypter struct {
+	// ID of the key new values are encrypted with.
+	current string
+	keys    map[string]cipher.AEAD
+}
+
+func newCrypter(keys []Key) (*crypter, error) {
+	c := &crypter{current: keys[0].ID, keys: make(map[string]cipher.AEAD, len(keys))}
+	for _, k := range keys {
+		if k.ID == "" || strings.Contains(k.ID, ":") {
+			return nil, fmt.Errorf("encrypted: invalid key ID %q", k.ID)
+		}
+		if _, ok := c.keys[k.ID]; ok {
+			return nil, fmt.Errorf("encrypted: duplicate key ID %q", k.ID)
+		}
+		if len(k.Key) != 32 {
+			return nil, fmt.Errorf("encrypted: key %q must be 32 bytes long, got %d", k.ID, len(k.Key))
+		}
+		aead, err := newAEAD(k.Key)
+		if err != nil {
+			return nil, err
+		}
+		c.keys[k.ID] = aead
+	}
+	return c, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts the plaintext with the AEAD, prepending a random nonce.
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(aead cipher.AEAD, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	n := aead.NonceSize()
+	return aead.Open(nil, ciphertext[:n], ciphertext[n:], additionalData)
+}
+
+// encrypt encrypts a value with a new data key. Empty values are left empty.
+func (c *crypter) encrypt(plaintext []byte) ([]byte, error) {
+	if len(plaintext) == 0 {
+		return plaintext, nil
+	}
+	dataKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, err
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := seal(dataAEAD, plaintext, nil)
+	if err != nil {
+		return nil, err
+	}
+	// The key ID is authenticated, so a data key can't be passed off as
+	// encrypted with another key.
+	encryptedKey, err := seal(c.keys[c.current], dataKey, []byte(c.current))
+	if err != nil {
+		return nil, err
+	}
+	enc := base64.RawURLEncoding
+	return []byte(prefix + c.current + ":" + enc.EncodeToString(encryptedKey) + ":" + enc.EncodeToString(ciphertext)), nil
+}
+
+// decrypt decrypts a value encrypted with any of the keys. Values that
+// aren't encrypted are returned as they are.
+func (c *crypter) decrypt(value []byte) ([]byte, error) {
+	if !strings.HasPrefix(string(value), prefix) {
+		return value, nil
+	}
+	parts := strings.Split(strings.TrimPrefix(string(value), prefix), ":")
+	if len(parts) != 3 {
+		return nil, errors.New("encrypted: malformed value")
+	}
+	keyID := parts[0]
+	kek, ok := c.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("encrypted: value is encrypted with unknown key %q", keyID)
+	}
+	enc := base64.RawURLEncoding
+	encryptedKey, err := enc.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("encrypted: malformed data key: %w", err)
+	}
+	ciphertext, err := enc.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("encrypted: malformed ciphertext: %w", err)
+	}
+	dataKey, err := open(kek, encryptedKey, []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("encrypted: decrypt data key with key %q: %w", keyID, err)
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := open(dataAEAD, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("encrypted: decrypt value: %w", err)
+	}
+	return plaintext, nil
+}
+
+// isCurrent reports whether the value is empty or encrypted with the
+// current key.
+func (c *crypter) isCurrent(value []byte) bool {
+	return len(value) == 0 || strings.HasPrefix(string(value), prefix+c.current+":")
+}
+
+func (c *crypter) encryptString(s *string) error {
+	v, err := c.encrypt([]byte(*s))
+	*s = string(v)
+	return err
+}
+
+func (c *crypter) decryptString(s *string) error {
+	v, err := c.decrypt([]byte(*s))
+	*s = string(v)
+	return err
+}
+
+func (c *crypter) encryptBytes(b *[]byte) (err error) {
+	*b, err = c.encrypt(*b)
+	return err
+}
+
+func (c *crypter) decryptBytes(b *[]byte) (err error) {
+	*b, err = c.decrypt(*b)
+	return err
+}
+
+// New returns a storage encrypting sensitive fields of s with the first key,
+// and decrypting them with any of the keys. Without keys s is returned
+// unchanged.
+func New(s storage.Storage, keys []Key) (storage.Storage, error) {
+	if len(keys) == 0 {
+		return s, nil
+	}
+	c, err := newCrypter(keys)
+	if err != nil {
+		return nil, err
+	}
+	return &encryptedStorage{Storage: s, c: c}, nil
+}
+
+type encryptedStorage struct {
+	storage.Storage
+	c *crypter
+}
+
+func (s *encryptedStorage) encryptClient(c storage.Client) (storage.Client, error) {
+	return c, s.c.encryptString(&c.Secret)
+}
+
+func (s *encryptedStorage) decryptClient(c storage.Client) (storage.Client, error) {
+	return c, s.c.decryptString(&c.Secret)
+}
+
+func (s *encryptedStorage) CreateClient(ctx context.Context, c storage.Client) error {
+	c, err := s.encryptClient(c)
+	if err != nil {
+		return err
+	}
+	return s.Storage.CreateClient(ctx, c)
+}
+
+func (s *encryptedStorage) GetClient(ctx context.Context, id string) (storage.Client, error) {
+	c, err := s.Storage.GetClient(ctx, id)
+	if err != nil {
+		return c, err
+	}
+	return s.decryptClient(c)
+}
+
+func (s *encryptedStorage) ListClients(ctx context.Context) ([]storage.Client, error) {
+	clients, err := s.Storage.ListClients(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range clients {
+		if clients[i], err = s.decryptClient(clients[i]); err != nil {
+			return nil, err
+		}
+	}
+	return clients, nil
+}
+
+func (s *encryptedStorage) UpdateClient(ctx context.Context, id string, updater func(old storage.Client) (storage.Client, error)) error {
+	return s.Storage.UpdateClient(ctx, id, func(old storage.Client) (storage.Client, error) {
+		old, err := s.decryptClient(old)
+		if err != nil {
+			return old, err
+		}
+		updated, err := updater(old)
+		if err != nil {
+			return updated, err
+		}
+		return s.encryptClient(updated)
+	})
+}
+
+func (s *encryptedStorage) encryptRefresh(r storage.RefreshToken) (storage.RefreshToken, error) {
+	if err := s.c.encryptString(&r.Token); err != nil {
+		return r, err
+	}
+	return r, s.c.encryptBytes(&r.ConnectorData)
+}
+
+func (s *encryptedStorage) decryptRefresh(r storage.RefreshToken) (storage.RefreshToken, error) {
+	if err := s.c.decryptString(&r.Token); err != nil {
+		return r, err
+	}
+	return r, s.c.decryptBytes(&r.ConnectorData)
+}
+
+func (s *encryptedStorage) CreateRefresh(ctx context.Context, r storage.RefreshToken) error {
+	r, err := s.encryptRefresh(r)
+	if err != nil {
+		return err
+	}
+	return s.Storage.CreateRefresh(ctx, r)
+}
+
+func (s *encryptedStorage) GetRefresh(ctx context.Context, id string) (storage.RefreshToken, error) {
+	r, err := s.Storage.GetRefresh(ctx, id)
+	if err != nil {
+		return r, err
+	}
+	return s.decryptRefresh(r)
+}
+
+func (s *encryptedStorage) ListRefreshTokens(ctx context.Context) ([]storage.RefreshToken, error) {
+	tokens, err := s.Storage.ListRefreshTokens(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range tokens {
+		if tokens[i], err = s.decryptRefresh(tokens[i]); err != nil {
+			return nil, err
+		}
+	}
+	return tokens, nil
+}
+
+func (s *encryptedStorage) UpdateRefreshToken(ctx context.Context, id string, updater func(old storage.RefreshToken) (storage.RefreshToken, error)) error {
+	return s.Storage.UpdateRefreshToken(ctx, id, func(old storage.RefreshToken) (storage.RefreshToken, error) {
+		old, err := s.decryptRefresh(old)
+		if err != nil {
+			return old, err
+		}
+		updated, err := updater(old)
+		if err != nil {
+			return updated, err
+		}
+		return s.encryptRefresh(updated)
+	})
+}
+
+func (s *encryptedStorage) CreateOfflineSessions(ctx context.Context, o storage.OfflineSessions) error {
+	if err := s.c.encryptBytes(&o.ConnectorData); err != nil {
+		return err
+	}
+	return s.Storage.CreateOfflineSessions(ctx, o)
+}
+
+func (s *encryptedStorage) GetOfflineSessions(ctx context.Context, userID string, connID string) (storage.OfflineSessions, error) {
+	o, err := s.Storage.GetOfflineSessions(ctx, userID, connID)
+	if err != nil {
+		return o, err
+	}
+	return o, s.c.decryptBytes(&o.ConnectorData)
+}
+
+func (s *encryptedStorage) UpdateOfflineSessions(ctx context.Context, userID string, connID string, updater func(o storage.OfflineSessions) (storage.OfflineSessions, error)) error {
+	return s.Storage.UpdateOfflineSessions(ctx, userID, connID, func(old storage.OfflineSessions) (storage.OfflineSessions, error) {
+		if err := s.c.decryptBytes(&old.ConnectorData); err != nil {
+			return old, err
+		}
+		updated, err := updater(old)
+		if err != nil {
+			return updated, err
+		}
+		return updated, s.c.encryptBytes(&updated.ConnectorData)
+	})
+}
+
+func (s *encryptedStorage) CreateSession(ctx context.Context, l storage.Session) error {
+	if err := s.c.encryptBytes(&l.ConnectorData); err != nil {
+		return err
+	}
+	return s.Storage.CreateSession(ctx, l)
+}
+
+func (s *encryptedStorage) GetSession(ctx context.Context, id string) (storage.Session, error) {
+	l, err := s.Storage.GetSession(ctx, id)
+	if err != nil {
+		return l, err
+	}
+	return l, s.c.decryptBytes(&l.ConnectorData)
+}
+
+func (s *encryptedStorage) ListSessions(ctx context.Context) ([]storage.Session, error) {
+	sessions, err := s.Storage.ListSessions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		if err := s.c.decryptBytes(&sessions[i].ConnectorData); err != nil {
+			return nil, err
+		}
+	}
+	return sessions, nil
+}
+
+func (s *encryptedStorage) UpdateSession(ctx context.Context, id string, updater func(l storage.Session) (storage.Session, error)) error {
+	return s.Storage.UpdateSession(ctx, id, func(old storage.Session) (storage.Session, error) {
+		if err := s.c.decryptBytes(&old.ConnectorData); err != nil {
+			return old, err
+		}
+		updated, err := updater(old)
+		if err != nil {
+			return updated, err
+		}
+		return updated, s.c.encryptBytes(&updated.ConnectorData)
+	})
+}
+
+func (s *encryptedStorage) CreateConnector(ctx context.Context, c storage.Connector) error {
+	if err := s.c.encryptBytes(&c.Config); err != nil {
+		return err
+	}
+	return s.Storage.CreateConnector(ctx, c)
+}
+
+func (s *encryptedStorage) GetConnector(ctx context.Context, id string) (storage.Connector, error) {
+	c, err := s.Storage.GetConnector(ctx, id)
+	if err != nil {
+		return c, err
+	}
+	return c, s.c.decryptBytes(&c.Config)
+}
+
+func (s *encryptedStorage) ListConnectors(ctx context.Context) ([]storage.Connector, error) {
+	connectors, err := s.Storage.ListConnectors(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range connectors {
+		if err := s.c.decryptBytes(&connectors[i].Config); err != nil {
+			return nil, err
+		}
+	}
+	return connectors, nil
+}
+
+func (s *encryptedStorage) UpdateConnector(ctx context.Context, id string, updater func(c storage.Connector) (storage.Connector, error)) error {
+	return s.Storage.UpdateConnector(ctx, id, func(old storage.Connector) (storage.Connector, error) {
+		if err := s.c.decryptBytes(&old.Config); err != nil {
+			return old, err
+		}
+		updated, err := updater(old)
+		if err != nil {
+			return updated, err
+		}
+		return updated, s.c.encryptBytes(&updated.Config)
+	})
+}
+
+func (s *encryptedStorage) CreateTOTPSecret(ctx context.Context, t storage.TOTPSecret) error {
+	if err := s.c.encryptBytes(&t.Secret); err != nil {
+		return err
+	}
+	return s.Storage.CreateTOTPSecret(ctx, t)
+}
+
+func (s *encryptedStorage) GetTOTPSecret(ctx context.Context, userID string, connID string) (storage.TOTPSecret, error) {
+	t, err := s.Storage.GetTOTPSecret(ctx, userID, connID)
+	if err != nil {
+		return t, err
+	}
+	return t, s.c.decryptBytes(&t.Secret)
+}
+
+func (s *encryptedStorage) UpdateTOTPSecret(ctx context.Context, userID string, connID string, updater func(t storage.TOTPSecret) (storage.TOTPSecret, error)) error {
+	return s.Storage.UpdateTOTPSecret(ctx, userID, connID, func(old storage.TOTPSecret) (storage.TOTPSecret, error) {
+		if err := s.c.decryptBytes(&old.Secret); err != nil {
+			return old, err
+		}
+		updated, err := updater(old)
+		if err != nil {
+			return updated, err
+		}
+		return updated, s.c.encryptBytes(&updated.Secret)
+	})
+}
+
+// Reencrypt encrypts every sensitive field of a storage returned by New
+// with its first key, if it isn't already. Run it after rotating keys,
+// before removing the previous keys from the configuration. It returns the
+// number of objects written.
+func Reencrypt(ctx context.Context, s storage.Storage) (n int, err error) {
+	e, ok := s.(*encryptedStorage)
+	if !ok {
+		return 0, errors.New("encrypted: storage doesn't encrypt values")
+	}
+	isCurrent := e.c.isCurrent
+
+	clients, err := e.Storage.ListClients(ctx)
+	if err != nil {
+		return n, fmt.Errorf("list clients: %w", err)
+	}
+	for _, c := range clients {
+		if isCurrent([]byte(c.Secret)) {
+			continue
+		}
+		if err := e.UpdateClient(ctx, c.ID, identityClient); err != nil {
+			return n, fmt.Errorf("reencrypt client %q: %w", c.ID, err)
+		}
+		n++
+	}
+
+	connectors, err := e.Storage.ListConnectors(ctx)
+	if err != nil {
+		return n, fmt.Errorf("list connectors: %w", err)
+	}
+	for _, c := range connectors {
+		if isCurrent(c.Config) {
+			continue
+		}
+		if err := e.UpdateConnector(ctx, c.ID, identityConnector); err != nil {
+			return n, fmt.Errorf("reencrypt connector %q: %w", c.ID, err)
+		}
+		n++
+	}
+
+	loginSessions, err := e.Storage.ListSessions(ctx)
+	if err != nil {
+		return n, fmt.Errorf("list sessions: %w", err)
+	}
+	for _, l := range loginSessions {
+		if isCurrent(l.ConnectorData) {
+			continue
+		}
+		err := e.UpdateSession(ctx, l.ID, identitySession)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return n, fmt.Errorf("reencrypt session %q: %w", l.ID, err)
+		}
+		n++
+	}
+
+	// Offline sessions can't be listed, but every one has refresh tokens.
+	tokens, err := e.Storage.ListRefreshTokens(ctx)
+	if err != nil {
+		return n, fmt.Errorf("list refresh tokens: %w", err)
+	}
+	sessions := make(map[[2]string]bool)
+	for _, r := range tokens {
+		sessions[[2]string{r.Claims.UserID, r.ConnectorID}] = true
+		if isCurrent([]byte(r.Token)) && isCurrent(r.ConnectorData) {
+			continue
+		}
+		err := e.UpdateRefreshToken(ctx, r.ID, identityRefresh)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return n, fmt.Errorf("reencrypt refresh token %q: %w", r.ID, err)
+		}
+		n++
+	}
+	for key := range sessions {
+		o, err := e.Storage.GetOfflineSessions(ctx, key[0], key[1])
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return n, fmt.Errorf("get offline sessions: %w", err)
+		}
+		if isCurrent(o.ConnectorData) {
+			continue
+		}
+		err = e.UpdateOfflineSessions(ctx, key[0], key[1], identityOfflineSessions)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return n, fmt.Errorf("reencrypt offline sessions: %w", err)
+		}
+		n++
+	}
+
+	// TOTP secrets can't be listed either, but only users of the password
+	// database enroll them.
+	passwords, err := e.Storage.ListPasswords(ctx)
+	if err != nil {
+		return n, fmt.Errorf("list passwords: %w", err)
+	}
+	for _, p := range passwords {
+		t, err := e.Storage.GetTOTPSecret(ctx, p.UserID, localConnector)
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return n, fmt.Errorf("get TOTP secret: %w", err)
+		}
+		if isCurrent(t.Secret) {
+			continue
+		}
+		err = e.UpdateTOTPSecret(ctx, p.UserID, localConnector, identityTOTPSecret)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return n, fmt.Errorf("reencrypt TOTP secret: %w", err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+func identityClient(c storage.Client) (storage.Client, error) { return c, nil }
+
+func identityConnector(c storage.Connector) (storage.Connector, error) { return c, nil }
+
+func identityRefresh(r storage.RefreshToken) (storage.RefreshToken, error) { return r, nil }
+
+func identityOfflineSessions(o storage.OfflineSessions) (storage.OfflineSessions, error) {
+	return o, nil
+}
+
+func identitySession(l storage.Session) (storage.Session, error) { return l, nil }
+
+func identityTOTPSecret(t storage.TOTPSecret) (storage.TOTPSecret, error) { return t, nil }
@@ -0,0 +1,187 @@
+package encrypted
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/dexidp/dex/storage"
+	"github.com/dexidp/dex/storage/conformance"
+	"github.com/dexidp/dex/storage/memory"
+)
+
+var logger = &logrus.Logger{
+	Out:       os.Stderr,
+	Formatter: &logrus.TextFormatter{DisableColors: true},
+	Level:     logrus.DebugLevel,
+}
+
+var (
+	oldKey = Key{ID: "2019", Key: bytes.Repeat([]byte{1}, 32)}
+	newKey = Key{ID: "2020", Key: bytes.Repeat([]byte{2}, 32)}
+)
+
+func mustNew(t *testing.T, s storage.Storage, keys ...Key) storage.Storage {
+	t.Helper()
+	e, err := New(s, keys)
+	if err != nil {
+		t.Fatalf("failed to create encrypted storage: %v", err)
+	}
+	return e
+}
+
+func TestStorage(t *testing.T) {
+	newStorage := func() storage.Storage {
+		return mustNew(t, memory.New(logger), newKey, oldKey)
+	}
+	conformance.RunTests(t, newStorage)
+}
+
+func TestEncryptsAtRest(t *testing.T) {
+	ctx := context.Background()
+	backing := memory.New(logger)
+	s := mustNew(t, backing, newKey)
+
+	if err := s.CreateClient(ctx, storage.Client{ID: "foo", Secret: "bar"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CreateConnector(ctx, storage.Connector{ID: "ldap", Type: "ldap", Config: []byte(`{"bindPW":"secret"}`)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CreateRefresh(ctx, storage.RefreshToken{ID: "r1", Token: "token", ConnectorData: []byte("upstream")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CreateSession(ctx, storage.Session{ID: "s1", ConnectorData: []byte("upstream")}); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := backing.GetClient(ctx, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(raw.Secret, "dexenc1:2020:") {
+		t.Errorf("expected client secret encrypted with the first key, got %q", raw.Secret)
+	}
+	rawConn, err := backing.GetConnector(ctx, "ldap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(rawConn.Config, []byte("secret")) {
+		t.Errorf("expected connector config to be encrypted, got %q", rawConn.Config)
+	}
+	rawRefresh, err := backing.GetRefresh(ctx, "r1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rawRefresh.Token == "token" || string(rawRefresh.ConnectorData) == "upstream" {
+		t.Errorf("expected refresh token to be encrypted, got %+v", rawRefresh)
+	}
+	rawSession, err := backing.GetSession(ctx, "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(rawSession.ConnectorData) == "upstream" {
+		t.Errorf("expected session connector data to be encrypted, got %q", rawSession.ConnectorData)
+	}
+	if l, err := s.GetSession(ctx, "s1"); err != nil || string(l.ConnectorData) != "upstream" {
+		t.Errorf("expected decrypted session connector data, got %q, %v", l.ConnectorData, err)
+	}
+
+	c, err := s.GetClient(ctx, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Secret != "bar" {
+		t.Errorf("expected decrypted secret %q, got %q", "bar", c.Secret)
+	}
+
+	// Values written before encryption was enabled are read as they are.
+	if err := backing.CreateClient(ctx, storage.Client{ID: "plain", Secret: "plain-secret"}); err != nil {
+		t.Fatal(err)
+	}
+	if c, err := s.GetClient(ctx, "plain"); err != nil || c.Secret != "plain-secret" {
+		t.Errorf("expected plaintext secret to be read as it is, got %q, %v", c.Secret, err)
+	}
+
+	// A key that wasn't used to encrypt the value can't decrypt it.
+	wrong := mustNew(t, backing, Key{ID: "2020", Key: bytes.Repeat([]byte{3}, 32)})
+	if _, err := wrong.GetClient(ctx, "foo"); err == nil {
+		t.Errorf("expected decrypting with the wrong key to fail")
+	}
+}
+
+func TestRotation(t *testing.T) {
+	ctx := context.Background()
+	backing := memory.New(logger)
+
+	old := mustNew(t, backing, oldKey)
+	if err := old.CreateClient(ctx, storage.Client{ID: "foo", Secret: "bar"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := old.CreateRefresh(ctx, storage.RefreshToken{ID: "r1", Token: "token", ConnectorID: "ldap", Claims: storage.Claims{UserID: "jane"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := old.CreateOfflineSessions(ctx, storage.OfflineSessions{UserID: "jane", ConnID: "ldap", ConnectorData: []byte("upstream")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := old.CreatePassword(ctx, storage.Password{Email: "jane@example.com", Hash: []byte("hash"), UserID: "jane"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := old.CreateTOTPSecret(ctx, storage.TOTPSecret{UserID: "jane", ConnID: "local", Secret: []byte("totp")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := old.CreateSession(ctx, storage.Session{ID: "s1", ConnectorData: []byte("upstream")}); err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := mustNew(t, backing, newKey, oldKey)
+	if c, err := rotated.GetClient(ctx, "foo"); err != nil || c.Secret != "bar" {
+		t.Fatalf("expected value encrypted with the previous key to be decrypted, got %q, %v", c.Secret, err)
+	}
+
+	n, err := Reencrypt(ctx, rotated)
+	if err != nil {
+		t.Fatalf("reencrypt: %v", err)
+	}
+	if n != 5 {
+		t.Errorf("expected 5 objects to be reencrypted, got %d", n)
+	}
+	if n, err := Reencrypt(ctx, rotated); err != nil || n != 0 {
+		t.Errorf("expected nothing left to reencrypt, got %d, %v", n, err)
+	}
+
+	current := mustNew(t, backing, newKey)
+	if c, err := current.GetClient(ctx, "foo"); err != nil || c.Secret != "bar" {
+		t.Errorf("expected client to be readable with the new key alone, got %q, %v", c.Secret, err)
+	}
+	if r, err := current.GetRefresh(ctx, "r1"); err != nil || r.Token != "token" {
+		t.Errorf("expected refresh token to be readable with the new key alone, got %q, %v", r.Token, err)
+	}
+	if o, err := current.GetOfflineSessions(ctx, "jane", "ldap"); err != nil || string(o.ConnectorData) != "upstream" {
+		t.Errorf("expected offline sessions to be readable with the new key alone, got %q, %v", o.ConnectorData, err)
+	}
+	if s, err := current.GetTOTPSecret(ctx, "jane", "local"); err != nil || string(s.Secret) != "totp" {
+		t.Errorf("expected TOTP secret to be readable with the new key alone, got %q, %v", s.Secret, err)
+	}
+	if l, err := current.GetSession(ctx, "s1"); err != nil || string(l.ConnectorData) != "upstream" {
+		t.Errorf("expected session to be readable with the new key alone, got %q, %v", l.ConnectorData, err)
+	}
+}
+
+func TestInvalidKeys(t *testing.T) {
+	invalid := [][]Key{
+		{{ID: "", Key: newKey.Key}},
+		{{ID: "a:b", Key: newKey.Key}},
+		{{ID: "short", Key: []byte("too short")}},
+		{newKey, newKey},
+	}
+	for _, keys := range invalid {
+		if _, err := New(memory.New(logger), keys); err == nil {
+			t.Errorf("expected error creating encrypted storage with keys %v", keys)
+		}
+	}
+}
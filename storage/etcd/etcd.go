@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -21,6 +22,16 @@ const (
 	passwordPrefix       = "password/"
 	offlineSessionPrefix = "offline_session/"
 	connectorPrefix      = "connector/"
+	termsPrefix          = "terms_acceptance/"
+	auditEventPrefix     = "audit_event/"
+	apiKeyPrefix         = "api_key/"
+	serviceAccountPrefix = "service_account/"
+	revokedTokenPrefix   = "revoked_token/"
+	sessionPrefix        = "session/"
+	preAuthCodePrefix    = "pre_authorized_code/"
+	webAuthnPrefix       = "webauthn_credential/"
+	loginLinkPrefix      = "login_link/"
+	totpSecretPrefix     = "totp_secret/"
 	keysName             = "openid-connect-keys"
 
 	// defaultStorageTimeout will be applied to all storage's operations.
@@ -36,8 +47,8 @@ func (c *conn) Close() error {
 	return c.db.Close()
 }
 
-func (c *conn) GarbageCollect(now time.Time) (result storage.GCResult, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+func (c *conn) GarbageCollect(ctx context.Context, now time.Time) (result storage.GCResult, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
 	defer cancel()
 	authRequests, err := c.listAuthRequests(ctx)
 	if err != nil {
@@ -49,7 +60,7 @@ func (c *conn) GarbageCollect(now time.Time) (result storage.GCResult, err error
 		if now.After(authRequest.Expiry) {
 			if err := c.deleteKey(ctx, keyID(authRequestPrefix, authRequest.ID)); err != nil {
 				c.logger.Errorf("failed to delete auth request: %v", err)
-				delErr = fmt.Errorf("failed to delete auth request: %v", err)
+				delErr = fmt.Errorf("failed to delete auth request: %w", err)
 			}
 			result.AuthRequests++
 		}
@@ -67,22 +78,94 @@ func (c *conn) GarbageCollect(now time.Time) (result storage.GCResult, err error
 		if now.After(authCode.Expiry) {
 			if err := c.deleteKey(ctx, keyID(authCodePrefix, authCode.ID)); err != nil {
 				c.logger.Errorf("failed to delete auth code %v", err)
-				delErr = fmt.Errorf("failed to delete auth code: %v", err)
+				delErr = fmt.Errorf("failed to delete auth code: %w", err)
 			}
 			result.AuthCodes++
 		}
 	}
+	if delErr != nil {
+		return result, delErr
+	}
+
+	revokedTokens, err := c.listRevokedTokens(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	for _, t := range revokedTokens {
+		if now.After(t.Expiry) {
+			if err := c.deleteKey(ctx, keyID(revokedTokenPrefix, t.ID)); err != nil {
+				c.logger.Errorf("failed to delete revoked token %v", err)
+				delErr = fmt.Errorf("failed to delete revoked token: %w", err)
+			}
+			result.RevokedTokens++
+		}
+	}
+	if delErr != nil {
+		return result, delErr
+	}
+
+	sessions, err := c.listSessions(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	for _, s := range sessions {
+		if now.After(s.Expiry) {
+			if err := c.deleteKey(ctx, keyID(sessionPrefix, s.ID)); err != nil {
+				c.logger.Errorf("failed to delete session %v", err)
+				delErr = fmt.Errorf("failed to delete session: %w", err)
+			}
+			result.Sessions++
+		}
+	}
+	if delErr != nil {
+		return result, delErr
+	}
+
+	codes, err := c.listPreAuthorizedCodes(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	for _, p := range codes {
+		if now.After(p.Expiry) {
+			if err := c.deleteKey(ctx, keyID(preAuthCodePrefix, p.ID)); err != nil {
+				c.logger.Errorf("failed to delete pre-authorized code %v", err)
+				delErr = fmt.Errorf("failed to delete pre-authorized code: %w", err)
+			}
+			result.PreAuthorizedCodes++
+		}
+	}
+	if delErr != nil {
+		return result, delErr
+	}
+
+	links, err := c.listLoginLinks(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	for _, l := range links {
+		if now.After(l.Expiry) {
+			if err := c.deleteKey(ctx, keyID(loginLinkPrefix, l.ID)); err != nil {
+				c.logger.Errorf("failed to delete login link %v", err)
+				delErr = fmt.Errorf("failed to delete login link: %w", err)
+			}
+			result.LoginLinks++
+		}
+	}
 	return result, delErr
 }
 
-func (c *conn) CreateAuthRequest(a storage.AuthRequest) error {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+func (c *conn) CreateAuthRequest(ctx context.Context, a storage.AuthRequest) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
 	defer cancel()
 	return c.txnCreate(ctx, keyID(authRequestPrefix, a.ID), fromStorageAuthRequest(a))
 }
 
-func (c *conn) GetAuthRequest(id string) (a storage.AuthRequest, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+func (c *conn) GetAuthRequest(ctx context.Context, id string) (a storage.AuthRequest, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
 	defer cancel()
 	var req AuthRequest
 	if err = c.getKey(ctx, keyID(authRequestPrefix, id), &req); err != nil {
@@ -91,8 +174,8 @@ func (c *conn) GetAuthRequest(id string) (a storage.AuthRequest, err error) {
 	return toStorageAuthRequest(req), nil
 }
 
-func (c *conn) UpdateAuthRequest(id string, updater func(a storage.AuthRequest) (storage.AuthRequest, error)) error {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+func (c *conn) UpdateAuthRequest(ctx context.Context, id string, updater func(a storage.AuthRequest) (storage.AuthRequest, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
 	defer cancel()
 	return c.txnUpdate(ctx, keyID(authRequestPrefix, id), func(currentValue []byte) ([]byte, error) {
 		var current AuthRequest
@@ -109,39 +192,59 @@ func (c *conn) UpdateAuthRequest(id string, updater func(a storage.AuthRequest)
 	})
 }
 
-func (c *conn) DeleteAuthRequest(id string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+func (c *conn) DeleteAuthRequest(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
 	defer cancel()
 	return c.deleteKey(ctx, keyID(authRequestPrefix, id))
 }
 
-func (c *conn) CreateAuthCode(a storage.AuthCode) error {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+func (c *conn) CreateAuthCode(ctx context.Context, a storage.AuthCode) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
 	defer cancel()
 	return c.txnCreate(ctx, keyID(authCodePrefix, a.ID), fromStorageAuthCode(a))
 }
 
-func (c *conn) GetAuthCode(id string) (a storage.AuthCode, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+func (c *conn) GetAuthCode(ctx context.Context, id string) (a storage.AuthCode, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
 	defer cancel()
-	err = c.getKey(ctx, keyID(authCodePrefix, id), &a)
-	return a, err
+	var ac AuthCode
+	if err = c.getKey(ctx, keyID(authCodePrefix, id), &ac); err != nil {
+		return a, err
+	}
+	return toStorageAuthCode(ac), nil
 }
 
-func (c *conn) DeleteAuthCode(id string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+func (c *conn) DeleteAuthCode(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
 	defer cancel()
 	return c.deleteKey(ctx, keyID(authCodePrefix, id))
 }
 
-func (c *conn) CreateRefresh(r storage.RefreshToken) error {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+func (c *conn) ConsumeAuthCode(ctx context.Context, id string) (a storage.AuthCode, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	res, err := c.db.Delete(ctx, keyID(authCodePrefix, id), clientv3.WithPrevKV())
+	if err != nil {
+		return a, err
+	}
+	if res.Deleted == 0 || len(res.PrevKvs) == 0 {
+		return a, storage.ErrNotFound
+	}
+	var ac AuthCode
+	if err = json.Unmarshal(res.PrevKvs[0].Value, &ac); err != nil {
+		return a, err
+	}
+	return toStorageAuthCode(ac), nil
+}
+
+func (c *conn) CreateRefresh(ctx context.Context, r storage.RefreshToken) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
 	defer cancel()
 	return c.txnCreate(ctx, keyID(refreshTokenPrefix, r.ID), fromStorageRefreshToken(r))
 }
 
-func (c *conn) GetRefresh(id string) (r storage.RefreshToken, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+func (c *conn) GetRefresh(ctx context.Context, id string) (r storage.RefreshToken, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
 	defer cancel()
 	var token RefreshToken
 	if err = c.getKey(ctx, keyID(refreshTokenPrefix, id), &token); err != nil {
@@ -150,8 +253,8 @@ func (c *conn) GetRefresh(id string) (r storage.RefreshToken, err error) {
 	return toStorageRefreshToken(token), nil
 }
 
-func (c *conn) UpdateRefreshToken(id string, updater func(old storage.RefreshToken) (storage.RefreshToken, error)) error {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+func (c *conn) UpdateRefreshToken(ctx context.Context, id string, updater func(old storage.RefreshToken) (storage.RefreshToken, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
 	defer cancel()
 	return c.txnUpdate(ctx, keyID(refreshTokenPrefix, id), func(currentValue []byte) ([]byte, error) {
 		var current RefreshToken
@@ -168,14 +271,14 @@ func (c *conn) UpdateRefreshToken(id string, updater func(old storage.RefreshTok
 	})
 }
 
-func (c *conn) DeleteRefresh(id string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+func (c *conn) DeleteRefresh(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
 	defer cancel()
 	return c.deleteKey(ctx, keyID(refreshTokenPrefix, id))
 }
 
-func (c *conn) ListRefreshTokens() (tokens []storage.RefreshToken, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+func (c *conn) ListRefreshTokens(ctx context.Context) (tokens []storage.RefreshToken, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
 	defer cancel()
 	res, err := c.db.Get(ctx, refreshTokenPrefix, clientv3.WithPrefix())
 	if err != nil {
@@ -191,21 +294,21 @@ func (c *conn) ListRefreshTokens() (tokens []storage.RefreshToken, err error) {
 	return tokens, nil
 }
 
-func (c *conn) CreateClient(cli storage.Client) error {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+func (c *conn) CreateClient(ctx context.Context, cli storage.Client) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
 	defer cancel()
 	return c.txnCreate(ctx, keyID(clientPrefix, cli.ID), cli)
 }
 
-func (c *conn) GetClient(id string) (cli storage.Client, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+func (c *conn) GetClient(ctx context.Context, id string) (cli storage.Client, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
 	defer cancel()
 	err = c.getKey(ctx, keyID(clientPrefix, id), &cli)
 	return cli, err
 }
 
-func (c *conn) UpdateClient(id string, updater func(old storage.Client) (storage.Client, error)) error {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+func (c *conn) UpdateClient(ctx context.Context, id string, updater func(old storage.Client) (storage.Client, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
 	defer cancel()
 	return c.txnUpdate(ctx, keyID(clientPrefix, id), func(currentValue []byte) ([]byte, error) {
 		var current storage.Client
@@ -222,14 +325,14 @@ func (c *conn) UpdateClient(id string, updater func(old storage.Client) (storage
 	})
 }
 
-func (c *conn) DeleteClient(id string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+func (c *conn) DeleteClient(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
 	defer cancel()
 	return c.deleteKey(ctx, keyID(clientPrefix, id))
 }
 
-func (c *conn) ListClients() (clients []storage.Client, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+func (c *conn) ListClients(ctx context.Context) (clients []storage.Client, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
 	defer cancel()
 	res, err := c.db.Get(ctx, clientPrefix, clientv3.WithPrefix())
 	if err != nil {
@@ -245,21 +348,21 @@ func (c *conn) ListClients() (clients []storage.Client, err error) {
 	return clients, nil
 }
 
-func (c *conn) CreatePassword(p storage.Password) error {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+func (c *conn) CreatePassword(ctx context.Context, p storage.Password) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
 	defer cancel()
 	return c.txnCreate(ctx, passwordPrefix+strings.ToLower(p.Email), p)
 }
 
-func (c *conn) GetPassword(email string) (p storage.Password, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+func (c *conn) GetPassword(ctx context.Context, email string) (p storage.Password, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
 	defer cancel()
 	err = c.getKey(ctx, keyEmail(passwordPrefix, email), &p)
 	return p, err
 }
 
-func (c *conn) UpdatePassword(email string, updater func(p storage.Password) (storage.Password, error)) error {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+func (c *conn) UpdatePassword(ctx context.Context, email string, updater func(p storage.Password) (storage.Password, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
 	defer cancel()
 	return c.txnUpdate(ctx, keyEmail(passwordPrefix, email), func(currentValue []byte) ([]byte, error) {
 		var current storage.Password
@@ -276,14 +379,14 @@ func (c *conn) UpdatePassword(email string, updater func(p storage.Password) (st
 	})
 }
 
-func (c *conn) DeletePassword(email string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+func (c *conn) DeletePassword(ctx context.Context, email string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
 	defer cancel()
 	return c.deleteKey(ctx, keyEmail(passwordPrefix, email))
 }
 
-func (c *conn) ListPasswords() (passwords []storage.Password, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+func (c *conn) ListPasswords(ctx context.Context) (passwords []storage.Password, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
 	defer cancel()
 	res, err := c.db.Get(ctx, passwordPrefix, clientv3.WithPrefix())
 	if err != nil {
@@ -299,14 +402,14 @@ func (c *conn) ListPasswords() (passwords []storage.Password, err error) {
 	return passwords, nil
 }
 
-func (c *conn) CreateOfflineSessions(s storage.OfflineSessions) error {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+func (c *conn) CreateOfflineSessions(ctx context.Context, s storage.OfflineSessions) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
 	defer cancel()
 	return c.txnCreate(ctx, keySession(offlineSessionPrefix, s.UserID, s.ConnID), fromStorageOfflineSessions(s))
 }
 
-func (c *conn) UpdateOfflineSessions(userID string, connID string, updater func(s storage.OfflineSessions) (storage.OfflineSessions, error)) error {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+func (c *conn) UpdateOfflineSessions(ctx context.Context, userID string, connID string, updater func(s storage.OfflineSessions) (storage.OfflineSessions, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
 	defer cancel()
 	return c.txnUpdate(ctx, keySession(offlineSessionPrefix, userID, connID), func(currentValue []byte) ([]byte, error) {
 		var current OfflineSessions
@@ -323,8 +426,8 @@ func (c *conn) UpdateOfflineSessions(userID string, connID string, updater func(
 	})
 }
 
-func (c *conn) GetOfflineSessions(userID string, connID string) (s storage.OfflineSessions, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+func (c *conn) GetOfflineSessions(ctx context.Context, userID string, connID string) (s storage.OfflineSessions, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
 	defer cancel()
 	var os OfflineSessions
 	if err = c.getKey(ctx, keySession(offlineSessionPrefix, userID, connID), &os); err != nil {
@@ -333,27 +436,224 @@ func (c *conn) GetOfflineSessions(userID string, connID string) (s storage.Offli
 	return toStorageOfflineSessions(os), nil
 }
 
-func (c *conn) DeleteOfflineSessions(userID string, connID string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+func (c *conn) DeleteOfflineSessions(ctx context.Context, userID string, connID string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
 	defer cancel()
 	return c.deleteKey(ctx, keySession(offlineSessionPrefix, userID, connID))
 }
 
-func (c *conn) CreateConnector(connector storage.Connector) error {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+func (c *conn) CreateTermsAcceptance(ctx context.Context, a storage.TermsAcceptance) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.txnCreate(ctx, keySession(termsPrefix, a.UserID, a.ConnID), fromStorageTermsAcceptance(a))
+}
+
+func (c *conn) UpdateTermsAcceptance(ctx context.Context, userID string, connID string, updater func(a storage.TermsAcceptance) (storage.TermsAcceptance, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.txnUpdate(ctx, keySession(termsPrefix, userID, connID), func(currentValue []byte) ([]byte, error) {
+		var current TermsAcceptance
+		if len(currentValue) > 0 {
+			if err := json.Unmarshal(currentValue, &current); err != nil {
+				return nil, err
+			}
+		}
+		updated, err := updater(toStorageTermsAcceptance(current))
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(fromStorageTermsAcceptance(updated))
+	})
+}
+
+func (c *conn) GetTermsAcceptance(ctx context.Context, userID string, connID string) (a storage.TermsAcceptance, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	var ta TermsAcceptance
+	if err = c.getKey(ctx, keySession(termsPrefix, userID, connID), &ta); err != nil {
+		return
+	}
+	return toStorageTermsAcceptance(ta), nil
+}
+
+func (c *conn) DeleteTermsAcceptance(ctx context.Context, userID string, connID string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.deleteKey(ctx, keySession(termsPrefix, userID, connID))
+}
+
+func (c *conn) CreateTOTPSecret(ctx context.Context, t storage.TOTPSecret) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.txnCreate(ctx, keySession(totpSecretPrefix, t.UserID, t.ConnID), fromStorageTOTPSecret(t))
+}
+
+func (c *conn) UpdateTOTPSecret(ctx context.Context, userID string, connID string, updater func(t storage.TOTPSecret) (storage.TOTPSecret, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.txnUpdate(ctx, keySession(totpSecretPrefix, userID, connID), func(currentValue []byte) ([]byte, error) {
+		var current TOTPSecret
+		if len(currentValue) > 0 {
+			if err := json.Unmarshal(currentValue, &current); err != nil {
+				return nil, err
+			}
+		}
+		updated, err := updater(toStorageTOTPSecret(current))
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(fromStorageTOTPSecret(updated))
+	})
+}
+
+func (c *conn) GetTOTPSecret(ctx context.Context, userID string, connID string) (t storage.TOTPSecret, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	var ts TOTPSecret
+	if err = c.getKey(ctx, keySession(totpSecretPrefix, userID, connID), &ts); err != nil {
+		return
+	}
+	return toStorageTOTPSecret(ts), nil
+}
+
+func (c *conn) DeleteTOTPSecret(ctx context.Context, userID string, connID string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.deleteKey(ctx, keySession(totpSecretPrefix, userID, connID))
+}
+
+func (c *conn) CreateAuditEvent(ctx context.Context, e storage.AuditEvent) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.txnCreate(ctx, keyID(auditEventPrefix, e.ID), fromStorageAuditEvent(e))
+}
+
+func (c *conn) ListAuditEvents(ctx context.Context, filter storage.AuditEventFilter) ([]storage.AuditEvent, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	all, err := c.listAuditEvents(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var events []storage.AuditEvent
+	for _, e := range all {
+		if filter.Matches(e) {
+			events = append(events, e)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Time.After(events[j].Time) })
+	if filter.Limit > 0 && len(events) > filter.Limit {
+		events = events[:filter.Limit]
+	}
+	return events, nil
+}
+
+func (c *conn) UpdateAuditEvent(ctx context.Context, id string, updater func(e storage.AuditEvent) (storage.AuditEvent, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.txnUpdate(ctx, keyID(auditEventPrefix, id), func(currentValue []byte) ([]byte, error) {
+		var current AuditEvent
+		if len(currentValue) > 0 {
+			if err := json.Unmarshal(currentValue, &current); err != nil {
+				return nil, err
+			}
+		}
+		updated, err := updater(toStorageAuditEvent(current))
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(fromStorageAuditEvent(updated))
+	})
+}
+
+func (c *conn) PruneAuditEvents(ctx context.Context, before time.Time) (n int64, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	events, err := c.listAuditEvents(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range events {
+		if !e.Time.Before(before) {
+			continue
+		}
+		if err := c.deleteKey(ctx, keyID(auditEventPrefix, e.ID)); err != nil {
+			return n, fmt.Errorf("failed to delete audit event: %w", err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+func (c *conn) listAuditEvents(ctx context.Context) (events []storage.AuditEvent, err error) {
+	res, err := c.db.Get(ctx, auditEventPrefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range res.Kvs {
+		var e AuditEvent
+		if err = json.Unmarshal(v.Value, &e); err != nil {
+			return nil, err
+		}
+		events = append(events, toStorageAuditEvent(e))
+	}
+	return events, nil
+}
+
+func (c *conn) CreateAPIKey(ctx context.Context, k storage.APIKey) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.txnCreate(ctx, keyID(apiKeyPrefix, k.ID), fromStorageAPIKey(k))
+}
+
+func (c *conn) GetAPIKey(ctx context.Context, id string) (storage.APIKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	var k APIKey
+	if err := c.getKey(ctx, keyID(apiKeyPrefix, id), &k); err != nil {
+		return storage.APIKey{}, err
+	}
+	return toStorageAPIKey(k), nil
+}
+
+func (c *conn) ListAPIKeys(ctx context.Context) (keys []storage.APIKey, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	res, err := c.db.Get(ctx, apiKeyPrefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range res.Kvs {
+		var k APIKey
+		if err = json.Unmarshal(v.Value, &k); err != nil {
+			return nil, err
+		}
+		keys = append(keys, toStorageAPIKey(k))
+	}
+	return keys, nil
+}
+
+func (c *conn) DeleteAPIKey(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.deleteKey(ctx, keyID(apiKeyPrefix, id))
+}
+
+func (c *conn) CreateConnector(ctx context.Context, connector storage.Connector) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
 	defer cancel()
 	return c.txnCreate(ctx, keyID(connectorPrefix, connector.ID), connector)
 }
 
-func (c *conn) GetConnector(id string) (conn storage.Connector, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+func (c *conn) GetConnector(ctx context.Context, id string) (conn storage.Connector, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
 	defer cancel()
 	err = c.getKey(ctx, keyID(connectorPrefix, id), &conn)
 	return conn, err
 }
 
-func (c *conn) UpdateConnector(id string, updater func(s storage.Connector) (storage.Connector, error)) error {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+func (c *conn) UpdateConnector(ctx context.Context, id string, updater func(s storage.Connector) (storage.Connector, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
 	defer cancel()
 	return c.txnUpdate(ctx, keyID(connectorPrefix, id), func(currentValue []byte) ([]byte, error) {
 		var current storage.Connector
@@ -370,14 +670,14 @@ func (c *conn) UpdateConnector(id string, updater func(s storage.Connector) (sto
 	})
 }
 
-func (c *conn) DeleteConnector(id string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+func (c *conn) DeleteConnector(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
 	defer cancel()
 	return c.deleteKey(ctx, keyID(connectorPrefix, id))
 }
 
-func (c *conn) ListConnectors() (connectors []storage.Connector, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+func (c *conn) ListConnectors(ctx context.Context) (connectors []storage.Connector, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
 	defer cancel()
 	res, err := c.db.Get(ctx, connectorPrefix, clientv3.WithPrefix())
 	if err != nil {
@@ -393,8 +693,8 @@ func (c *conn) ListConnectors() (connectors []storage.Connector, err error) {
 	return connectors, nil
 }
 
-func (c *conn) GetKeys() (keys storage.Keys, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+func (c *conn) GetKeys(ctx context.Context) (keys storage.Keys, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
 	defer cancel()
 	res, err := c.db.Get(ctx, keysName)
 	if err != nil {
@@ -406,8 +706,8 @@ func (c *conn) GetKeys() (keys storage.Keys, err error) {
 	return keys, err
 }
 
-func (c *conn) UpdateKeys(updater func(old storage.Keys) (storage.Keys, error)) error {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+func (c *conn) UpdateKeys(ctx context.Context, updater func(old storage.Keys) (storage.Keys, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
 	defer cancel()
 	return c.txnUpdate(ctx, keysName, func(currentValue []byte) ([]byte, error) {
 		var current storage.Keys
@@ -476,6 +776,66 @@ func (c *conn) listAuthCodes(ctx context.Context) (codes []AuthCode, err error)
 	return codes, nil
 }
 
+func (c *conn) listRevokedTokens(ctx context.Context) (tokens []RevokedToken, err error) {
+	res, err := c.db.Get(ctx, revokedTokenPrefix, clientv3.WithPrefix())
+	if err != nil {
+		return tokens, err
+	}
+	for _, v := range res.Kvs {
+		var t RevokedToken
+		if err = json.Unmarshal(v.Value, &t); err != nil {
+			return tokens, err
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, nil
+}
+
+func (c *conn) listSessions(ctx context.Context) (sessions []Session, err error) {
+	res, err := c.db.Get(ctx, sessionPrefix, clientv3.WithPrefix())
+	if err != nil {
+		return sessions, err
+	}
+	for _, v := range res.Kvs {
+		var s Session
+		if err = json.Unmarshal(v.Value, &s); err != nil {
+			return sessions, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, nil
+}
+
+func (c *conn) listPreAuthorizedCodes(ctx context.Context) (codes []PreAuthorizedCode, err error) {
+	res, err := c.db.Get(ctx, preAuthCodePrefix, clientv3.WithPrefix())
+	if err != nil {
+		return codes, err
+	}
+	for _, v := range res.Kvs {
+		var p PreAuthorizedCode
+		if err = json.Unmarshal(v.Value, &p); err != nil {
+			return codes, err
+		}
+		codes = append(codes, p)
+	}
+	return codes, nil
+}
+
+func (c *conn) listLoginLinks(ctx context.Context) (links []LoginLink, err error) {
+	res, err := c.db.Get(ctx, loginLinkPrefix, clientv3.WithPrefix())
+	if err != nil {
+		return links, err
+	}
+	for _, v := range res.Kvs {
+		var l LoginLink
+		if err = json.Unmarshal(v.Value, &l); err != nil {
+			return links, err
+		}
+		links = append(links, l)
+	}
+	return links, nil
+}
+
 func (c *conn) txnCreate(ctx context.Context, key string, value interface{}) error {
 	b, err := json.Marshal(value)
 	if err != nil {
@@ -531,3 +891,235 @@ func keyEmail(prefix, email string) string { return prefix + strings.ToLower(ema
 func keySession(prefix, userID, connID string) string {
 	return prefix + strings.ToLower(userID+"|"+connID)
 }
+
+func (c *conn) CreateServiceAccount(ctx context.Context, a storage.ServiceAccount) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.txnCreate(ctx, keyID(serviceAccountPrefix, a.ID), fromStorageServiceAccount(a))
+}
+
+func (c *conn) GetServiceAccount(ctx context.Context, id string) (storage.ServiceAccount, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	var a ServiceAccount
+	if err := c.getKey(ctx, keyID(serviceAccountPrefix, id), &a); err != nil {
+		return storage.ServiceAccount{}, err
+	}
+	return toStorageServiceAccount(a), nil
+}
+
+func (c *conn) ListServiceAccounts(ctx context.Context) (accounts []storage.ServiceAccount, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	res, err := c.db.Get(ctx, serviceAccountPrefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range res.Kvs {
+		var a ServiceAccount
+		if err = json.Unmarshal(v.Value, &a); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, toStorageServiceAccount(a))
+	}
+	return accounts, nil
+}
+
+func (c *conn) UpdateServiceAccount(ctx context.Context, id string, updater func(a storage.ServiceAccount) (storage.ServiceAccount, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.txnUpdate(ctx, keyID(serviceAccountPrefix, id), func(currentValue []byte) ([]byte, error) {
+		var current ServiceAccount
+		if len(currentValue) > 0 {
+			if err := json.Unmarshal(currentValue, &current); err != nil {
+				return nil, err
+			}
+		}
+		updated, err := updater(toStorageServiceAccount(current))
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(fromStorageServiceAccount(updated))
+	})
+}
+
+func (c *conn) DeleteServiceAccount(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.deleteKey(ctx, keyID(serviceAccountPrefix, id))
+}
+
+func (c *conn) CreateRevokedToken(ctx context.Context, t storage.RevokedToken) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.txnCreate(ctx, keyID(revokedTokenPrefix, t.ID), fromStorageRevokedToken(t))
+}
+
+func (c *conn) GetRevokedToken(ctx context.Context, id string) (storage.RevokedToken, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	var t RevokedToken
+	if err := c.getKey(ctx, keyID(revokedTokenPrefix, id), &t); err != nil {
+		return storage.RevokedToken{}, err
+	}
+	return toStorageRevokedToken(t), nil
+}
+
+func (c *conn) CreateSession(ctx context.Context, s storage.Session) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.txnCreate(ctx, keyID(sessionPrefix, s.ID), fromStorageSession(s))
+}
+
+func (c *conn) GetSession(ctx context.Context, id string) (storage.Session, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	var s Session
+	if err := c.getKey(ctx, keyID(sessionPrefix, id), &s); err != nil {
+		return storage.Session{}, err
+	}
+	return toStorageSession(s), nil
+}
+
+func (c *conn) ListSessions(ctx context.Context) ([]storage.Session, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	sessions, err := c.listSessions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]storage.Session, len(sessions))
+	for i, s := range sessions {
+		result[i] = toStorageSession(s)
+	}
+	return result, nil
+}
+
+func (c *conn) UpdateSession(ctx context.Context, id string, updater func(s storage.Session) (storage.Session, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.txnUpdate(ctx, keyID(sessionPrefix, id), func(currentValue []byte) ([]byte, error) {
+		var current Session
+		if len(currentValue) > 0 {
+			if err := json.Unmarshal(currentValue, &current); err != nil {
+				return nil, err
+			}
+		}
+		updated, err := updater(toStorageSession(current))
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(fromStorageSession(updated))
+	})
+}
+
+func (c *conn) DeleteSession(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.deleteKey(ctx, keyID(sessionPrefix, id))
+}
+
+func (c *conn) CreatePreAuthorizedCode(ctx context.Context, p storage.PreAuthorizedCode) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.txnCreate(ctx, keyID(preAuthCodePrefix, p.ID), fromStoragePreAuthorizedCode(p))
+}
+
+func (c *conn) ConsumePreAuthorizedCode(ctx context.Context, id string) (p storage.PreAuthorizedCode, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	res, err := c.db.Delete(ctx, keyID(preAuthCodePrefix, id), clientv3.WithPrevKV())
+	if err != nil {
+		return p, err
+	}
+	if res.Deleted == 0 || len(res.PrevKvs) == 0 {
+		return p, storage.ErrNotFound
+	}
+	var code PreAuthorizedCode
+	if err = json.Unmarshal(res.PrevKvs[0].Value, &code); err != nil {
+		return p, err
+	}
+	return toStoragePreAuthorizedCode(code), nil
+}
+
+func (c *conn) CreateWebAuthnCredential(ctx context.Context, w storage.WebAuthnCredential) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.txnCreate(ctx, keyID(webAuthnPrefix, w.ID), fromStorageWebAuthnCredential(w))
+}
+
+func (c *conn) GetWebAuthnCredential(ctx context.Context, id string) (storage.WebAuthnCredential, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	var w WebAuthnCredential
+	if err := c.getKey(ctx, keyID(webAuthnPrefix, id), &w); err != nil {
+		return storage.WebAuthnCredential{}, err
+	}
+	return toStorageWebAuthnCredential(w), nil
+}
+
+func (c *conn) ListWebAuthnCredentials(ctx context.Context, userID string, connID string) (creds []storage.WebAuthnCredential, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	res, err := c.db.Get(ctx, webAuthnPrefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range res.Kvs {
+		var w WebAuthnCredential
+		if err = json.Unmarshal(v.Value, &w); err != nil {
+			return nil, err
+		}
+		if w.UserID == userID && w.ConnectorID == connID {
+			creds = append(creds, toStorageWebAuthnCredential(w))
+		}
+	}
+	return creds, nil
+}
+
+func (c *conn) UpdateWebAuthnCredential(ctx context.Context, id string, updater func(w storage.WebAuthnCredential) (storage.WebAuthnCredential, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.txnUpdate(ctx, keyID(webAuthnPrefix, id), func(currentValue []byte) ([]byte, error) {
+		var current WebAuthnCredential
+		if len(currentValue) > 0 {
+			if err := json.Unmarshal(currentValue, &current); err != nil {
+				return nil, err
+			}
+		}
+		updated, err := updater(toStorageWebAuthnCredential(current))
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(fromStorageWebAuthnCredential(updated))
+	})
+}
+
+func (c *conn) DeleteWebAuthnCredential(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.deleteKey(ctx, keyID(webAuthnPrefix, id))
+}
+
+func (c *conn) CreateLoginLink(ctx context.Context, l storage.LoginLink) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	return c.txnCreate(ctx, keyID(loginLinkPrefix, l.ID), fromStorageLoginLink(l))
+}
+
+func (c *conn) ConsumeLoginLink(ctx context.Context, id string) (l storage.LoginLink, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultStorageTimeout)
+	defer cancel()
+	res, err := c.db.Delete(ctx, keyID(loginLinkPrefix, id), clientv3.WithPrevKV())
+	if err != nil {
+		return l, err
+	}
+	if res.Deleted == 0 || len(res.PrevKvs) == 0 {
+		return l, storage.ErrNotFound
+	}
+	var link LoginLink
+	if err = json.Unmarshal(res.PrevKvs[0].Value, &link); err != nil {
+		return l, err
+	}
+	return toStorageLoginLink(link), nil
+}
@@ -21,10 +21,29 @@ type AuthCode struct {
 	Claims        Claims `json:"claims,omitempty"`
 
 	Expiry time.Time `json:"expiry"`
+
+	CodeChallenge       string `json:"code_challenge,omitempty"`
+	CodeChallengeMethod string `json:"code_challenge_method,omitempty"`
 }
 
 func fromStorageAuthCode(a storage.AuthCode) AuthCode {
 	return AuthCode{
+		ID:                  a.ID,
+		ClientID:            a.ClientID,
+		RedirectURI:         a.RedirectURI,
+		ConnectorID:         a.ConnectorID,
+		ConnectorData:       a.ConnectorData,
+		Nonce:               a.Nonce,
+		Scopes:              a.Scopes,
+		Claims:              fromStorageClaims(a.Claims),
+		Expiry:              a.Expiry,
+		CodeChallenge:       a.PKCE.CodeChallenge,
+		CodeChallengeMethod: a.PKCE.CodeChallengeMethod,
+	}
+}
+
+func toStorageAuthCode(a AuthCode) storage.AuthCode {
+	return storage.AuthCode{
 		ID:            a.ID,
 		ClientID:      a.ClientID,
 		RedirectURI:   a.RedirectURI,
@@ -32,8 +51,12 @@ func fromStorageAuthCode(a storage.AuthCode) AuthCode {
 		ConnectorData: a.ConnectorData,
 		Nonce:         a.Nonce,
 		Scopes:        a.Scopes,
-		Claims:        fromStorageClaims(a.Claims),
+		Claims:        toStorageClaims(a.Claims),
 		Expiry:        a.Expiry,
+		PKCE: storage.PKCE{
+			CodeChallenge:       a.CodeChallenge,
+			CodeChallengeMethod: a.CodeChallengeMethod,
+		},
 	}
 }
 
@@ -58,6 +81,13 @@ type AuthRequest struct {
 
 	ConnectorID   string `json:"connector_id"`
 	ConnectorData []byte `json:"connector_data"`
+	FallbackFrom  string `json:"fallback_from,omitempty"`
+
+	CodeChallenge       string `json:"code_challenge,omitempty"`
+	CodeChallengeMethod string `json:"code_challenge_method,omitempty"`
+
+	SecondFactor          string `json:"second_factor,omitempty"`
+	SecondFactorChallenge []byte `json:"second_factor_challenge,omitempty"`
 }
 
 func fromStorageAuthRequest(a storage.AuthRequest) AuthRequest {
@@ -75,6 +105,12 @@ func fromStorageAuthRequest(a storage.AuthRequest) AuthRequest {
 		Claims:              fromStorageClaims(a.Claims),
 		ConnectorID:         a.ConnectorID,
 		ConnectorData:       a.ConnectorData,
+		FallbackFrom:        a.FallbackFrom,
+		CodeChallenge:       a.PKCE.CodeChallenge,
+		CodeChallengeMethod: a.PKCE.CodeChallengeMethod,
+
+		SecondFactor:          a.SecondFactor,
+		SecondFactorChallenge: a.SecondFactorChallenge,
 	}
 }
 
@@ -91,8 +127,15 @@ func toStorageAuthRequest(a AuthRequest) storage.AuthRequest {
 		LoggedIn:            a.LoggedIn,
 		ConnectorID:         a.ConnectorID,
 		ConnectorData:       a.ConnectorData,
+		FallbackFrom:        a.FallbackFrom,
 		Expiry:              a.Expiry,
 		Claims:              toStorageClaims(a.Claims),
+		PKCE: storage.PKCE{
+			CodeChallenge:       a.CodeChallenge,
+			CodeChallengeMethod: a.CodeChallengeMethod,
+		},
+		SecondFactor:          a.SecondFactor,
+		SecondFactorChallenge: a.SecondFactorChallenge,
 	}
 }
 
@@ -102,8 +145,11 @@ type RefreshToken struct {
 
 	Token string `json:"token"`
 
-	CreatedAt time.Time `json:"created_at"`
-	LastUsed  time.Time `json:"last_used"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsed   time.Time `json:"last_used"`
+	LastUsedIP string    `json:"last_used_ip,omitempty"`
+
+	DeviceFingerprint string `json:"device_fingerprint,omitempty"`
 
 	ClientID string `json:"client_id"`
 
@@ -118,31 +164,35 @@ type RefreshToken struct {
 
 func toStorageRefreshToken(r RefreshToken) storage.RefreshToken {
 	return storage.RefreshToken{
-		ID:            r.ID,
-		Token:         r.Token,
-		CreatedAt:     r.CreatedAt,
-		LastUsed:      r.LastUsed,
-		ClientID:      r.ClientID,
-		ConnectorID:   r.ConnectorID,
-		ConnectorData: r.ConnectorData,
-		Scopes:        r.Scopes,
-		Nonce:         r.Nonce,
-		Claims:        toStorageClaims(r.Claims),
+		ID:                r.ID,
+		Token:             r.Token,
+		CreatedAt:         r.CreatedAt,
+		LastUsed:          r.LastUsed,
+		LastUsedIP:        r.LastUsedIP,
+		DeviceFingerprint: r.DeviceFingerprint,
+		ClientID:          r.ClientID,
+		ConnectorID:       r.ConnectorID,
+		ConnectorData:     r.ConnectorData,
+		Scopes:            r.Scopes,
+		Nonce:             r.Nonce,
+		Claims:            toStorageClaims(r.Claims),
 	}
 }
 
 func fromStorageRefreshToken(r storage.RefreshToken) RefreshToken {
 	return RefreshToken{
-		ID:            r.ID,
-		Token:         r.Token,
-		CreatedAt:     r.CreatedAt,
-		LastUsed:      r.LastUsed,
-		ClientID:      r.ClientID,
-		ConnectorID:   r.ConnectorID,
-		ConnectorData: r.ConnectorData,
-		Scopes:        r.Scopes,
-		Nonce:         r.Nonce,
-		Claims:        fromStorageClaims(r.Claims),
+		ID:                r.ID,
+		Token:             r.Token,
+		CreatedAt:         r.CreatedAt,
+		LastUsed:          r.LastUsed,
+		LastUsedIP:        r.LastUsedIP,
+		DeviceFingerprint: r.DeviceFingerprint,
+		ClientID:          r.ClientID,
+		ConnectorID:       r.ConnectorID,
+		ConnectorData:     r.ConnectorData,
+		Scopes:            r.Scopes,
+		Nonce:             r.Nonce,
+		Claims:            fromStorageClaims(r.Claims),
 	}
 }
 
@@ -216,3 +266,350 @@ func toStorageOfflineSessions(o OfflineSessions) storage.OfflineSessions {
 	}
 	return s
 }
+
+// TermsAcceptance is a mirrored struct from storage with JSON struct tags
+type TermsAcceptance struct {
+	UserID     string    `json:"user_id,omitempty"`
+	ConnID     string    `json:"conn_id,omitempty"`
+	Version    string    `json:"version"`
+	AcceptedAt time.Time `json:"accepted_at"`
+}
+
+func fromStorageTermsAcceptance(a storage.TermsAcceptance) TermsAcceptance {
+	return TermsAcceptance{
+		UserID:     a.UserID,
+		ConnID:     a.ConnID,
+		Version:    a.Version,
+		AcceptedAt: a.AcceptedAt,
+	}
+}
+
+func toStorageTermsAcceptance(a TermsAcceptance) storage.TermsAcceptance {
+	return storage.TermsAcceptance{
+		UserID:     a.UserID,
+		ConnID:     a.ConnID,
+		Version:    a.Version,
+		AcceptedAt: a.AcceptedAt,
+	}
+}
+
+// TOTPSecret is a mirrored struct from storage with JSON struct tags
+type TOTPSecret struct {
+	UserID      string    `json:"user_id,omitempty"`
+	ConnID      string    `json:"conn_id,omitempty"`
+	Secret      []byte    `json:"secret"`
+	Confirmed   bool      `json:"confirmed,omitempty"`
+	LastStep    int64     `json:"last_step,omitempty"`
+	BackupCodes [][]byte  `json:"backup_codes,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func fromStorageTOTPSecret(t storage.TOTPSecret) TOTPSecret {
+	return TOTPSecret{
+		UserID:      t.UserID,
+		ConnID:      t.ConnID,
+		Secret:      t.Secret,
+		Confirmed:   t.Confirmed,
+		LastStep:    t.LastStep,
+		BackupCodes: t.BackupCodes,
+		CreatedAt:   t.CreatedAt,
+	}
+}
+
+func toStorageTOTPSecret(t TOTPSecret) storage.TOTPSecret {
+	return storage.TOTPSecret{
+		UserID:      t.UserID,
+		ConnID:      t.ConnID,
+		Secret:      t.Secret,
+		Confirmed:   t.Confirmed,
+		LastStep:    t.LastStep,
+		BackupCodes: t.BackupCodes,
+		CreatedAt:   t.CreatedAt,
+	}
+}
+
+// AuditEvent is a mirrored struct from storage with JSON struct tags
+type AuditEvent struct {
+	ID          string    `json:"id"`
+	Type        string    `json:"type"`
+	Severity    string    `json:"severity,omitempty"`
+	Time        time.Time `json:"time"`
+	ClientID    string    `json:"client_id,omitempty"`
+	Subject     string    `json:"subject,omitempty"`
+	ConnectorID string    `json:"connector_id,omitempty"`
+	SourceIPs   []string  `json:"source_ips,omitempty"`
+	Message     string    `json:"message,omitempty"`
+	Revoked     bool      `json:"revoked,omitempty"`
+}
+
+func fromStorageAuditEvent(e storage.AuditEvent) AuditEvent {
+	return AuditEvent{
+		ID:          e.ID,
+		Type:        e.Type,
+		Severity:    e.Severity,
+		Time:        e.Time,
+		ClientID:    e.ClientID,
+		Subject:     e.Subject,
+		ConnectorID: e.ConnectorID,
+		SourceIPs:   e.SourceIPs,
+		Message:     e.Message,
+		Revoked:     e.Revoked,
+	}
+}
+
+func toStorageAuditEvent(e AuditEvent) storage.AuditEvent {
+	return storage.AuditEvent{
+		ID:          e.ID,
+		Type:        e.Type,
+		Severity:    e.Severity,
+		Time:        e.Time,
+		ClientID:    e.ClientID,
+		Subject:     e.Subject,
+		ConnectorID: e.ConnectorID,
+		SourceIPs:   e.SourceIPs,
+		Message:     e.Message,
+		Revoked:     e.Revoked,
+	}
+}
+
+// APIKey is a mirrored struct from storage with JSON struct tags
+type APIKey struct {
+	ID          string    `json:"id"`
+	Hash        string    `json:"hash"`
+	ClientID    string    `json:"client_id"`
+	Name        string    `json:"name,omitempty"`
+	Scopes      []string  `json:"scopes,omitempty"`
+	Claims      Claims    `json:"claims"`
+	ConnectorID string    `json:"connector_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	Expiry      time.Time `json:"expiry"`
+}
+
+func fromStorageAPIKey(k storage.APIKey) APIKey {
+	return APIKey{
+		ID:          k.ID,
+		Hash:        k.Hash,
+		ClientID:    k.ClientID,
+		Name:        k.Name,
+		Scopes:      k.Scopes,
+		Claims:      fromStorageClaims(k.Claims),
+		ConnectorID: k.ConnectorID,
+		CreatedAt:   k.CreatedAt,
+		Expiry:      k.Expiry,
+	}
+}
+
+func toStorageAPIKey(k APIKey) storage.APIKey {
+	return storage.APIKey{
+		ID:          k.ID,
+		Hash:        k.Hash,
+		ClientID:    k.ClientID,
+		Name:        k.Name,
+		Scopes:      k.Scopes,
+		Claims:      toStorageClaims(k.Claims),
+		ConnectorID: k.ConnectorID,
+		CreatedAt:   k.CreatedAt,
+		Expiry:      k.Expiry,
+	}
+}
+
+// ServiceAccount is a mirrored struct from storage with JSON struct tags
+type ServiceAccount struct {
+	ID         string            `json:"id"`
+	Name       string            `json:"name,omitempty"`
+	Groups     []string          `json:"groups,omitempty"`
+	Clients    []string          `json:"clients,omitempty"`
+	PublicKeys []jose.JSONWebKey `json:"public_keys,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+}
+
+func fromStorageServiceAccount(a storage.ServiceAccount) ServiceAccount {
+	return ServiceAccount{
+		ID:         a.ID,
+		Name:       a.Name,
+		Groups:     a.Groups,
+		Clients:    a.Clients,
+		PublicKeys: a.PublicKeys,
+		CreatedAt:  a.CreatedAt,
+	}
+}
+
+func toStorageServiceAccount(a ServiceAccount) storage.ServiceAccount {
+	return storage.ServiceAccount{
+		ID:         a.ID,
+		Name:       a.Name,
+		Groups:     a.Groups,
+		Clients:    a.Clients,
+		PublicKeys: a.PublicKeys,
+		CreatedAt:  a.CreatedAt,
+	}
+}
+
+// RevokedToken is a mirrored struct from storage with JSON struct tags
+type RevokedToken struct {
+	ID        string    `json:"id"`
+	ClientID  string    `json:"client_id"`
+	Reason    string    `json:"reason,omitempty"`
+	RevokedAt time.Time `json:"revoked_at"`
+	Expiry    time.Time `json:"expiry"`
+}
+
+func fromStorageRevokedToken(t storage.RevokedToken) RevokedToken {
+	return RevokedToken{
+		ID:        t.ID,
+		ClientID:  t.ClientID,
+		Reason:    t.Reason,
+		RevokedAt: t.RevokedAt,
+		Expiry:    t.Expiry,
+	}
+}
+
+func toStorageRevokedToken(t RevokedToken) storage.RevokedToken {
+	return storage.RevokedToken{
+		ID:        t.ID,
+		ClientID:  t.ClientID,
+		Reason:    t.Reason,
+		RevokedAt: t.RevokedAt,
+		Expiry:    t.Expiry,
+	}
+}
+
+// Session is a mirrored struct from storage with JSON struct tags
+type Session struct {
+	ID            string    `json:"id"`
+	ConnectorID   string    `json:"connector_id"`
+	Claims        Claims    `json:"claims"`
+	ConnectorData []byte    `json:"connector_data,omitempty"`
+	ClientIDs     []string  `json:"client_ids,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	Expiry        time.Time `json:"expiry"`
+}
+
+func fromStorageSession(s storage.Session) Session {
+	return Session{
+		ID:            s.ID,
+		ConnectorID:   s.ConnectorID,
+		Claims:        fromStorageClaims(s.Claims),
+		ConnectorData: s.ConnectorData,
+		ClientIDs:     s.ClientIDs,
+		CreatedAt:     s.CreatedAt,
+		Expiry:        s.Expiry,
+	}
+}
+
+func toStorageSession(s Session) storage.Session {
+	return storage.Session{
+		ID:            s.ID,
+		ConnectorID:   s.ConnectorID,
+		Claims:        toStorageClaims(s.Claims),
+		ConnectorData: s.ConnectorData,
+		ClientIDs:     s.ClientIDs,
+		CreatedAt:     s.CreatedAt,
+		Expiry:        s.Expiry,
+	}
+}
+
+// PreAuthorizedCode is a mirrored struct from storage with JSON struct tags
+type PreAuthorizedCode struct {
+	ID          string    `json:"id"`
+	ClientID    string    `json:"client_id"`
+	Scopes      []string  `json:"scopes,omitempty"`
+	Claims      Claims    `json:"claims"`
+	ConnectorID string    `json:"connector_id"`
+	PINHash     []byte    `json:"pin_hash,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	Expiry      time.Time `json:"expiry"`
+}
+
+func fromStoragePreAuthorizedCode(p storage.PreAuthorizedCode) PreAuthorizedCode {
+	return PreAuthorizedCode{
+		ID:          p.ID,
+		ClientID:    p.ClientID,
+		Scopes:      p.Scopes,
+		Claims:      fromStorageClaims(p.Claims),
+		ConnectorID: p.ConnectorID,
+		PINHash:     p.PINHash,
+		CreatedAt:   p.CreatedAt,
+		Expiry:      p.Expiry,
+	}
+}
+
+func toStoragePreAuthorizedCode(p PreAuthorizedCode) storage.PreAuthorizedCode {
+	return storage.PreAuthorizedCode{
+		ID:          p.ID,
+		ClientID:    p.ClientID,
+		Scopes:      p.Scopes,
+		Claims:      toStorageClaims(p.Claims),
+		ConnectorID: p.ConnectorID,
+		PINHash:     p.PINHash,
+		CreatedAt:   p.CreatedAt,
+		Expiry:      p.Expiry,
+	}
+}
+
+// WebAuthnCredential is a mirrored struct from storage with JSON struct tags
+type WebAuthnCredential struct {
+	ID          string    `json:"id"`
+	UserID      string    `json:"user_id"`
+	ConnectorID string    `json:"connector_id"`
+	PublicKey   []byte    `json:"public_key"`
+	SignCount   uint32    `json:"sign_count"`
+	CreatedAt   time.Time `json:"created_at"`
+	LastUsed    time.Time `json:"last_used"`
+}
+
+func fromStorageWebAuthnCredential(c storage.WebAuthnCredential) WebAuthnCredential {
+	return WebAuthnCredential{
+		ID:          c.ID,
+		UserID:      c.UserID,
+		ConnectorID: c.ConnectorID,
+		PublicKey:   c.PublicKey,
+		SignCount:   c.SignCount,
+		CreatedAt:   c.CreatedAt,
+		LastUsed:    c.LastUsed,
+	}
+}
+
+func toStorageWebAuthnCredential(c WebAuthnCredential) storage.WebAuthnCredential {
+	return storage.WebAuthnCredential{
+		ID:          c.ID,
+		UserID:      c.UserID,
+		ConnectorID: c.ConnectorID,
+		PublicKey:   c.PublicKey,
+		SignCount:   c.SignCount,
+		CreatedAt:   c.CreatedAt,
+		LastUsed:    c.LastUsed,
+	}
+}
+
+// LoginLink is a mirrored struct from storage with JSON struct tags
+type LoginLink struct {
+	ID            string    `json:"id"`
+	AuthRequestID string    `json:"auth_request_id"`
+	ConnectorID   string    `json:"connector_id"`
+	Email         string    `json:"email"`
+	CreatedAt     time.Time `json:"created_at"`
+	Expiry        time.Time `json:"expiry"`
+}
+
+func fromStorageLoginLink(l storage.LoginLink) LoginLink {
+	return LoginLink{
+		ID:            l.ID,
+		AuthRequestID: l.AuthRequestID,
+		ConnectorID:   l.ConnectorID,
+		Email:         l.Email,
+		CreatedAt:     l.CreatedAt,
+		Expiry:        l.Expiry,
+	}
+}
+
+func toStorageLoginLink(l LoginLink) storage.LoginLink {
+	return storage.LoginLink{
+		ID:            l.ID,
+		AuthRequestID: l.AuthRequestID,
+		ConnectorID:   l.ConnectorID,
+		Email:         l.Email,
+		CreatedAt:     l.CreatedAt,
+		Expiry:        l.Expiry,
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -13,25 +14,45 @@ import (
 )
 
 const (
-	kindAuthCode        = "AuthCode"
-	kindAuthRequest     = "AuthRequest"
-	kindClient          = "OAuth2Client"
-	kindRefreshToken    = "RefreshToken"
-	kindKeys            = "SigningKey"
-	kindPassword        = "Password"
-	kindOfflineSessions = "OfflineSessions"
-	kindConnector       = "Connector"
+	kindAuthCode           = "AuthCode"
+	kindAuthRequest        = "AuthRequest"
+	kindClient             = "OAuth2Client"
+	kindRefreshToken       = "RefreshToken"
+	kindKeys               = "SigningKey"
+	kindPassword           = "Password"
+	kindOfflineSessions    = "OfflineSessions"
+	kindConnector          = "Connector"
+	kindTermsAcceptance    = "TermsAcceptance"
+	kindAuditEvent         = "AuditEvent"
+	kindAPIKey             = "APIKey"
+	kindServiceAccount     = "ServiceAccount"
+	kindRevokedToken       = "RevokedToken"
+	kindSession            = "Session"
+	kindPreAuthCode        = "PreAuthorizedCode"
+	kindWebAuthnCredential = "WebAuthnCredential"
+	kindLoginLink          = "LoginLink"
+	kindTOTPSecret         = "TOTPSecret"
 )
 
 const (
-	resourceAuthCode        = "authcodes"
-	resourceAuthRequest     = "authrequests"
-	resourceClient          = "oauth2clients"
-	resourceRefreshToken    = "refreshtokens"
-	resourceKeys            = "signingkeies" // Kubernetes attempts to pluralize.
-	resourcePassword        = "passwords"
-	resourceOfflineSessions = "offlinesessionses" // Again attempts to pluralize.
-	resourceConnector       = "connectors"
+	resourceAuthCode           = "authcodes"
+	resourceAuthRequest        = "authrequests"
+	resourceClient             = "oauth2clients"
+	resourceRefreshToken       = "refreshtokens"
+	resourceKeys               = "signingkeies" // Kubernetes attempts to pluralize.
+	resourcePassword           = "passwords"
+	resourceOfflineSessions    = "offlinesessionses" // Again attempts to pluralize.
+	resourceConnector          = "connectors"
+	resourceTermsAcceptance    = "termsacceptances"
+	resourceAuditEvent         = "auditevents"
+	resourceAPIKey             = "apikeys"
+	resourceServiceAccount     = "serviceaccounts"
+	resourceRevokedToken       = "revokedtokens"
+	resourceSession            = "sessions"
+	resourcePreAuthCode        = "preauthorizedcodes"
+	resourceWebAuthnCredential = "webauthncredentials"
+	resourceLoginLink          = "loginlinks"
+	resourceTOTPSecret         = "totpsecrets"
 )
 
 // Config values for the Kubernetes storage type.
@@ -79,7 +100,7 @@ func (c *Config) open(logger log.Logger, waitForResources bool) (*client, error)
 
 	cli, err := newClient(cluster, user, namespace, logger)
 	if err != nil {
-		return nil, fmt.Errorf("create client: %v", err)
+		return nil, fmt.Errorf("create client: %w", err)
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -129,6 +150,7 @@ func (c *Config) open(logger log.Logger, waitForResources bool) (*client, error)
 //
 // Creating a custom resource does not mean that they'll be immediately available.
 func (cli *client) registerCustomResources() (ok bool) {
+	ctx := context.Background()
 	ok = true
 	length := len(customResourceDefinitions)
 	for i := 0; i < length; i++ {
@@ -138,20 +160,20 @@ func (cli *client) registerCustomResources() (ok bool) {
 		r := customResourceDefinitions[i]
 		var i interface{}
 		cli.logger.Infof("checking if custom resource %s has been created already...", r.ObjectMeta.Name)
-		if err := cli.list(r.Spec.Names.Plural, &i); err == nil {
+		if err := cli.list(ctx, r.Spec.Names.Plural, &i); err == nil {
 			cli.logger.Infof("The custom resource %s already available, skipping create", r.ObjectMeta.Name)
 			continue
 		} else {
 			cli.logger.Infof("failed to list custom resource %s, attempting to create: %v", r.ObjectMeta.Name, err)
 		}
-		err = cli.postResource("apiextensions.k8s.io/v1beta1", "", "customresourcedefinitions", r)
+		err = cli.postResource(ctx, "apiextensions.k8s.io/v1beta1", "", "customresourcedefinitions", r)
 		resourceName = r.ObjectMeta.Name
 
 		if err != nil {
-			switch err {
-			case storage.ErrAlreadyExists:
+			switch {
+			case errors.Is(err, storage.ErrAlreadyExists):
 				cli.logger.Infof("custom resource already created %s", resourceName)
-			case storage.ErrNotFound:
+			case errors.Is(err, storage.ErrNotFound):
 				cli.logger.Errorf("custom resources not found, please enable the respective API group")
 				ok = false
 			default:
@@ -192,10 +214,11 @@ func (cli *client) waitForCRDs(ctx context.Context) error {
 
 // isCRDReady determines if a CRD is ready by inspecting its conditions.
 func (cli *client) isCRDReady(name string) error {
+	ctx := context.Background()
 	var r k8sapi.CustomResourceDefinition
-	err := cli.getResource("apiextensions.k8s.io/v1beta1", "", "customresourcedefinitions", name, &r)
+	err := cli.getResource(ctx, "apiextensions.k8s.io/v1beta1", "", "customresourcedefinitions", name, &r)
 	if err != nil {
-		return fmt.Errorf("get crd %s: %v", name, err)
+		return fmt.Errorf("get crd %s: %w", name, err)
 	}
 
 	conds := make(map[string]string) // For debugging, keep the conditions around.
@@ -215,62 +238,62 @@ func (cli *client) Close() error {
 	return nil
 }
 
-func (cli *client) CreateAuthRequest(a storage.AuthRequest) error {
-	return cli.post(resourceAuthRequest, cli.fromStorageAuthRequest(a))
+func (cli *client) CreateAuthRequest(ctx context.Context, a storage.AuthRequest) error {
+	return cli.post(ctx, resourceAuthRequest, cli.fromStorageAuthRequest(a))
 }
 
-func (cli *client) CreateClient(c storage.Client) error {
-	return cli.post(resourceClient, cli.fromStorageClient(c))
+func (cli *client) CreateClient(ctx context.Context, c storage.Client) error {
+	return cli.post(ctx, resourceClient, cli.fromStorageClient(c))
 }
 
-func (cli *client) CreateAuthCode(c storage.AuthCode) error {
-	return cli.post(resourceAuthCode, cli.fromStorageAuthCode(c))
+func (cli *client) CreateAuthCode(ctx context.Context, c storage.AuthCode) error {
+	return cli.post(ctx, resourceAuthCode, cli.fromStorageAuthCode(c))
 }
 
-func (cli *client) CreatePassword(p storage.Password) error {
-	return cli.post(resourcePassword, cli.fromStoragePassword(p))
+func (cli *client) CreatePassword(ctx context.Context, p storage.Password) error {
+	return cli.post(ctx, resourcePassword, cli.fromStoragePassword(p))
 }
 
-func (cli *client) CreateRefresh(r storage.RefreshToken) error {
-	return cli.post(resourceRefreshToken, cli.fromStorageRefreshToken(r))
+func (cli *client) CreateRefresh(ctx context.Context, r storage.RefreshToken) error {
+	return cli.post(ctx, resourceRefreshToken, cli.fromStorageRefreshToken(r))
 }
 
-func (cli *client) CreateOfflineSessions(o storage.OfflineSessions) error {
-	return cli.post(resourceOfflineSessions, cli.fromStorageOfflineSessions(o))
+func (cli *client) CreateOfflineSessions(ctx context.Context, o storage.OfflineSessions) error {
+	return cli.post(ctx, resourceOfflineSessions, cli.fromStorageOfflineSessions(o))
 }
 
-func (cli *client) CreateConnector(c storage.Connector) error {
-	return cli.post(resourceConnector, cli.fromStorageConnector(c))
+func (cli *client) CreateConnector(ctx context.Context, c storage.Connector) error {
+	return cli.post(ctx, resourceConnector, cli.fromStorageConnector(c))
 }
 
-func (cli *client) GetAuthRequest(id string) (storage.AuthRequest, error) {
+func (cli *client) GetAuthRequest(ctx context.Context, id string) (storage.AuthRequest, error) {
 	var req AuthRequest
-	if err := cli.get(resourceAuthRequest, id, &req); err != nil {
+	if err := cli.get(ctx, resourceAuthRequest, id, &req); err != nil {
 		return storage.AuthRequest{}, err
 	}
 	return toStorageAuthRequest(req), nil
 }
 
-func (cli *client) GetAuthCode(id string) (storage.AuthCode, error) {
+func (cli *client) GetAuthCode(ctx context.Context, id string) (storage.AuthCode, error) {
 	var code AuthCode
-	if err := cli.get(resourceAuthCode, id, &code); err != nil {
+	if err := cli.get(ctx, resourceAuthCode, id, &code); err != nil {
 		return storage.AuthCode{}, err
 	}
 	return toStorageAuthCode(code), nil
 }
 
-func (cli *client) GetClient(id string) (storage.Client, error) {
-	c, err := cli.getClient(id)
+func (cli *client) GetClient(ctx context.Context, id string) (storage.Client, error) {
+	c, err := cli.getClient(ctx, id)
 	if err != nil {
 		return storage.Client{}, err
 	}
 	return toStorageClient(c), nil
 }
 
-func (cli *client) getClient(id string) (Client, error) {
+func (cli *client) getClient(ctx context.Context, id string) (Client, error) {
 	var c Client
 	name := cli.idToName(id)
-	if err := cli.get(resourceClient, name, &c); err != nil {
+	if err := cli.get(ctx, resourceClient, name, &c); err != nil {
 		return Client{}, err
 	}
 	if c.ID != id {
@@ -279,20 +302,20 @@ func (cli *client) getClient(id string) (Client, error) {
 	return c, nil
 }
 
-func (cli *client) GetPassword(email string) (storage.Password, error) {
-	p, err := cli.getPassword(email)
+func (cli *client) GetPassword(ctx context.Context, email string) (storage.Password, error) {
+	p, err := cli.getPassword(ctx, email)
 	if err != nil {
 		return storage.Password{}, err
 	}
 	return toStoragePassword(p), nil
 }
 
-func (cli *client) getPassword(email string) (Password, error) {
+func (cli *client) getPassword(ctx context.Context, email string) (Password, error) {
 	// TODO(ericchiang): Figure out whose job it is to lowercase emails.
 	email = strings.ToLower(email)
 	var p Password
 	name := cli.idToName(email)
-	if err := cli.get(resourcePassword, name, &p); err != nil {
+	if err := cli.get(ctx, resourcePassword, name, &p); err != nil {
 		return Password{}, err
 	}
 	if email != p.Email {
@@ -301,38 +324,38 @@ func (cli *client) getPassword(email string) (Password, error) {
 	return p, nil
 }
 
-func (cli *client) GetKeys() (storage.Keys, error) {
+func (cli *client) GetKeys(ctx context.Context) (storage.Keys, error) {
 	var keys Keys
-	if err := cli.get(resourceKeys, keysName, &keys); err != nil {
+	if err := cli.get(ctx, resourceKeys, keysName, &keys); err != nil {
 		return storage.Keys{}, err
 	}
 	return toStorageKeys(keys), nil
 }
 
-func (cli *client) GetRefresh(id string) (storage.RefreshToken, error) {
-	r, err := cli.getRefreshToken(id)
+func (cli *client) GetRefresh(ctx context.Context, id string) (storage.RefreshToken, error) {
+	r, err := cli.getRefreshToken(ctx, id)
 	if err != nil {
 		return storage.RefreshToken{}, err
 	}
 	return toStorageRefreshToken(r), nil
 }
 
-func (cli *client) getRefreshToken(id string) (r RefreshToken, err error) {
-	err = cli.get(resourceRefreshToken, id, &r)
+func (cli *client) getRefreshToken(ctx context.Context, id string) (r RefreshToken, err error) {
+	err = cli.get(ctx, resourceRefreshToken, id, &r)
 	return
 }
 
-func (cli *client) GetOfflineSessions(userID string, connID string) (storage.OfflineSessions, error) {
-	o, err := cli.getOfflineSessions(userID, connID)
+func (cli *client) GetOfflineSessions(ctx context.Context, userID string, connID string) (storage.OfflineSessions, error) {
+	o, err := cli.getOfflineSessions(ctx, userID, connID)
 	if err != nil {
 		return storage.OfflineSessions{}, err
 	}
 	return toStorageOfflineSessions(o), nil
 }
 
-func (cli *client) getOfflineSessions(userID string, connID string) (o OfflineSessions, err error) {
+func (cli *client) getOfflineSessions(ctx context.Context, userID string, connID string) (o OfflineSessions, err error) {
 	name := cli.offlineTokenName(userID, connID)
-	if err = cli.get(resourceOfflineSessions, name, &o); err != nil {
+	if err = cli.get(ctx, resourceOfflineSessions, name, &o); err != nil {
 		return OfflineSessions{}, err
 	}
 	if userID != o.UserID || connID != o.ConnID {
@@ -341,26 +364,26 @@ func (cli *client) getOfflineSessions(userID string, connID string) (o OfflineSe
 	return o, nil
 }
 
-func (cli *client) GetConnector(id string) (storage.Connector, error) {
+func (cli *client) GetConnector(ctx context.Context, id string) (storage.Connector, error) {
 	var c Connector
-	if err := cli.get(resourceConnector, id, &c); err != nil {
+	if err := cli.get(ctx, resourceConnector, id, &c); err != nil {
 		return storage.Connector{}, err
 	}
 	return toStorageConnector(c), nil
 }
 
-func (cli *client) ListClients() ([]storage.Client, error) {
+func (cli *client) ListClients(ctx context.Context) ([]storage.Client, error) {
 	return nil, errors.New("not implemented")
 }
 
-func (cli *client) ListRefreshTokens() ([]storage.RefreshToken, error) {
+func (cli *client) ListRefreshTokens(ctx context.Context) ([]storage.RefreshToken, error) {
 	return nil, errors.New("not implemented")
 }
 
-func (cli *client) ListPasswords() (passwords []storage.Password, err error) {
+func (cli *client) ListPasswords(ctx context.Context) (passwords []storage.Password, err error) {
 	var passwordList PasswordList
-	if err = cli.list(resourcePassword, &passwordList); err != nil {
-		return passwords, fmt.Errorf("failed to list passwords: %v", err)
+	if err = cli.list(ctx, resourcePassword, &passwordList); err != nil {
+		return passwords, fmt.Errorf("failed to list passwords: %w", err)
 	}
 
 	for _, password := range passwordList.Passwords {
@@ -376,10 +399,10 @@ func (cli *client) ListPasswords() (passwords []storage.Password, err error) {
 	return
 }
 
-func (cli *client) ListConnectors() (connectors []storage.Connector, err error) {
+func (cli *client) ListConnectors(ctx context.Context) (connectors []storage.Connector, err error) {
 	var connectorList ConnectorList
-	if err = cli.list(resourceConnector, &connectorList); err != nil {
-		return connectors, fmt.Errorf("failed to list connectors: %v", err)
+	if err = cli.list(ctx, resourceConnector, &connectorList); err != nil {
+		return connectors, fmt.Errorf("failed to list connectors: %w", err)
 	}
 
 	connectors = make([]storage.Connector, len(connectorList.Connectors))
@@ -390,51 +413,64 @@ func (cli *client) ListConnectors() (connectors []storage.Connector, err error)
 	return
 }
 
-func (cli *client) DeleteAuthRequest(id string) error {
-	return cli.delete(resourceAuthRequest, id)
+func (cli *client) DeleteAuthRequest(ctx context.Context, id string) error {
+	return cli.delete(ctx, resourceAuthRequest, id)
 }
 
-func (cli *client) DeleteAuthCode(code string) error {
-	return cli.delete(resourceAuthCode, code)
+func (cli *client) DeleteAuthCode(ctx context.Context, code string) error {
+	return cli.delete(ctx, resourceAuthCode, code)
 }
 
-func (cli *client) DeleteClient(id string) error {
+func (cli *client) ConsumeAuthCode(ctx context.Context, id string) (storage.AuthCode, error) {
+	var code AuthCode
+	if err := cli.get(ctx, resourceAuthCode, id, &code); err != nil {
+		return storage.AuthCode{}, err
+	}
+	// Only one concurrent delete of the resource succeeds, the others
+	// observe a 404 and report ErrNotFound.
+	if err := cli.delete(ctx, resourceAuthCode, code.ObjectMeta.Name); err != nil {
+		return storage.AuthCode{}, err
+	}
+	return toStorageAuthCode(code), nil
+}
+
+func (cli *client) DeleteClient(ctx context.Context, id string) error {
 	// Check for hash collition.
-	c, err := cli.getClient(id)
+	c, err := cli.getClient(ctx, id)
 	if err != nil {
 		return err
 	}
-	return cli.delete(resourceClient, c.ObjectMeta.Name)
+	return cli.delete(ctx, resourceClient, c.ObjectMeta.Name)
 }
 
-func (cli *client) DeleteRefresh(id string) error {
-	return cli.delete(resourceRefreshToken, id)
+func (cli *client) DeleteRefresh(ctx context.Context, id string) error {
+	return cli.delete(ctx, resourceRefreshToken, id)
 }
 
-func (cli *client) DeletePassword(email string) error {
+func (cli *client) DeletePassword(ctx context.Context, email string) error {
 	// Check for hash collision.
-	p, err := cli.getPassword(email)
+	p, err := cli.getPassword(ctx, email)
 	if err != nil {
 		return err
 	}
-	return cli.delete(resourcePassword, p.ObjectMeta.Name)
+	return cli.delete(ctx, resourcePassword, p.ObjectMeta.Name)
 }
 
-func (cli *client) DeleteOfflineSessions(userID string, connID string) error {
+func (cli *client) DeleteOfflineSessions(ctx context.Context, userID string, connID string) error {
 	// Check for hash collision.
-	o, err := cli.getOfflineSessions(userID, connID)
+	o, err := cli.getOfflineSessions(ctx, userID, connID)
 	if err != nil {
 		return err
 	}
-	return cli.delete(resourceOfflineSessions, o.ObjectMeta.Name)
+	return cli.delete(ctx, resourceOfflineSessions, o.ObjectMeta.Name)
 }
 
-func (cli *client) DeleteConnector(id string) error {
-	return cli.delete(resourceConnector, id)
+func (cli *client) DeleteConnector(ctx context.Context, id string) error {
+	return cli.delete(ctx, resourceConnector, id)
 }
 
-func (cli *client) UpdateRefreshToken(id string, updater func(old storage.RefreshToken) (storage.RefreshToken, error)) error {
-	r, err := cli.getRefreshToken(id)
+func (cli *client) UpdateRefreshToken(ctx context.Context, id string, updater func(old storage.RefreshToken) (storage.RefreshToken, error)) error {
+	r, err := cli.getRefreshToken(ctx, id)
 	if err != nil {
 		return err
 	}
@@ -446,11 +482,11 @@ func (cli *client) UpdateRefreshToken(id string, updater func(old storage.Refres
 
 	newToken := cli.fromStorageRefreshToken(updated)
 	newToken.ObjectMeta = r.ObjectMeta
-	return cli.put(resourceRefreshToken, r.ObjectMeta.Name, newToken)
+	return cli.put(ctx, resourceRefreshToken, r.ObjectMeta.Name, newToken)
 }
 
-func (cli *client) UpdateClient(id string, updater func(old storage.Client) (storage.Client, error)) error {
-	c, err := cli.getClient(id)
+func (cli *client) UpdateClient(ctx context.Context, id string, updater func(old storage.Client) (storage.Client, error)) error {
+	c, err := cli.getClient(ctx, id)
 	if err != nil {
 		return err
 	}
@@ -463,11 +499,11 @@ func (cli *client) UpdateClient(id string, updater func(old storage.Client) (sto
 
 	newClient := cli.fromStorageClient(updated)
 	newClient.ObjectMeta = c.ObjectMeta
-	return cli.put(resourceClient, c.ObjectMeta.Name, newClient)
+	return cli.put(ctx, resourceClient, c.ObjectMeta.Name, newClient)
 }
 
-func (cli *client) UpdatePassword(email string, updater func(old storage.Password) (storage.Password, error)) error {
-	p, err := cli.getPassword(email)
+func (cli *client) UpdatePassword(ctx context.Context, email string, updater func(old storage.Password) (storage.Password, error)) error {
+	p, err := cli.getPassword(ctx, email)
 	if err != nil {
 		return err
 	}
@@ -480,11 +516,11 @@ func (cli *client) UpdatePassword(email string, updater func(old storage.Passwor
 
 	newPassword := cli.fromStoragePassword(updated)
 	newPassword.ObjectMeta = p.ObjectMeta
-	return cli.put(resourcePassword, p.ObjectMeta.Name, newPassword)
+	return cli.put(ctx, resourcePassword, p.ObjectMeta.Name, newPassword)
 }
 
-func (cli *client) UpdateOfflineSessions(userID string, connID string, updater func(old storage.OfflineSessions) (storage.OfflineSessions, error)) error {
-	o, err := cli.getOfflineSessions(userID, connID)
+func (cli *client) UpdateOfflineSessions(ctx context.Context, userID string, connID string, updater func(old storage.OfflineSessions) (storage.OfflineSessions, error)) error {
+	o, err := cli.getOfflineSessions(ctx, userID, connID)
 	if err != nil {
 		return err
 	}
@@ -496,14 +532,14 @@ func (cli *client) UpdateOfflineSessions(userID string, connID string, updater f
 
 	newOfflineSessions := cli.fromStorageOfflineSessions(updated)
 	newOfflineSessions.ObjectMeta = o.ObjectMeta
-	return cli.put(resourceOfflineSessions, o.ObjectMeta.Name, newOfflineSessions)
+	return cli.put(ctx, resourceOfflineSessions, o.ObjectMeta.Name, newOfflineSessions)
 }
 
-func (cli *client) UpdateKeys(updater func(old storage.Keys) (storage.Keys, error)) error {
+func (cli *client) UpdateKeys(ctx context.Context, updater func(old storage.Keys) (storage.Keys, error)) error {
 	firstUpdate := false
 	var keys Keys
-	if err := cli.get(resourceKeys, keysName, &keys); err != nil {
-		if err != storage.ErrNotFound {
+	if err := cli.get(ctx, resourceKeys, keysName, &keys); err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
 			return err
 		}
 		firstUpdate = true
@@ -519,15 +555,15 @@ func (cli *client) UpdateKeys(updater func(old storage.Keys) (storage.Keys, erro
 	}
 	newKeys := cli.fromStorageKeys(updated)
 	if firstUpdate {
-		return cli.post(resourceKeys, newKeys)
+		return cli.post(ctx, resourceKeys, newKeys)
 	}
 	newKeys.ObjectMeta = keys.ObjectMeta
-	return cli.put(resourceKeys, keysName, newKeys)
+	return cli.put(ctx, resourceKeys, keysName, newKeys)
 }
 
-func (cli *client) UpdateAuthRequest(id string, updater func(a storage.AuthRequest) (storage.AuthRequest, error)) error {
+func (cli *client) UpdateAuthRequest(ctx context.Context, id string, updater func(a storage.AuthRequest) (storage.AuthRequest, error)) error {
 	var req AuthRequest
-	err := cli.get(resourceAuthRequest, id, &req)
+	err := cli.get(ctx, resourceAuthRequest, id, &req)
 	if err != nil {
 		return err
 	}
@@ -539,12 +575,12 @@ func (cli *client) UpdateAuthRequest(id string, updater func(a storage.AuthReque
 
 	newReq := cli.fromStorageAuthRequest(updated)
 	newReq.ObjectMeta = req.ObjectMeta
-	return cli.put(resourceAuthRequest, id, newReq)
+	return cli.put(ctx, resourceAuthRequest, id, newReq)
 }
 
-func (cli *client) UpdateConnector(id string, updater func(a storage.Connector) (storage.Connector, error)) error {
+func (cli *client) UpdateConnector(ctx context.Context, id string, updater func(a storage.Connector) (storage.Connector, error)) error {
 	var c Connector
-	err := cli.get(resourceConnector, id, &c)
+	err := cli.get(ctx, resourceConnector, id, &c)
 	if err != nil {
 		return err
 	}
@@ -556,21 +592,21 @@ func (cli *client) UpdateConnector(id string, updater func(a storage.Connector)
 
 	newConn := cli.fromStorageConnector(updated)
 	newConn.ObjectMeta = c.ObjectMeta
-	return cli.put(resourceConnector, id, newConn)
+	return cli.put(ctx, resourceConnector, id, newConn)
 }
 
-func (cli *client) GarbageCollect(now time.Time) (result storage.GCResult, err error) {
+func (cli *client) GarbageCollect(ctx context.Context, now time.Time) (result storage.GCResult, err error) {
 	var authRequests AuthRequestList
-	if err := cli.list(resourceAuthRequest, &authRequests); err != nil {
-		return result, fmt.Errorf("failed to list auth requests: %v", err)
+	if err := cli.list(ctx, resourceAuthRequest, &authRequests); err != nil {
+		return result, fmt.Errorf("failed to list auth requests: %w", err)
 	}
 
 	var delErr error
 	for _, authRequest := range authRequests.AuthRequests {
 		if now.After(authRequest.Expiry) {
-			if err := cli.delete(resourceAuthRequest, authRequest.ObjectMeta.Name); err != nil {
+			if err := cli.delete(ctx, resourceAuthRequest, authRequest.ObjectMeta.Name); err != nil {
 				cli.logger.Errorf("failed to delete auth request: %v", err)
-				delErr = fmt.Errorf("failed to delete auth request: %v", err)
+				delErr = fmt.Errorf("failed to delete auth request: %w", err)
 			}
 			result.AuthRequests++
 		}
@@ -580,18 +616,470 @@ func (cli *client) GarbageCollect(now time.Time) (result storage.GCResult, err e
 	}
 
 	var authCodes AuthCodeList
-	if err := cli.list(resourceAuthCode, &authCodes); err != nil {
-		return result, fmt.Errorf("failed to list auth codes: %v", err)
+	if err := cli.list(ctx, resourceAuthCode, &authCodes); err != nil {
+		return result, fmt.Errorf("failed to list auth codes: %w", err)
 	}
 
 	for _, authCode := range authCodes.AuthCodes {
 		if now.After(authCode.Expiry) {
-			if err := cli.delete(resourceAuthCode, authCode.ObjectMeta.Name); err != nil {
+			if err := cli.delete(ctx, resourceAuthCode, authCode.ObjectMeta.Name); err != nil {
 				cli.logger.Errorf("failed to delete auth code %v", err)
-				delErr = fmt.Errorf("failed to delete auth code: %v", err)
+				delErr = fmt.Errorf("failed to delete auth code: %w", err)
 			}
 			result.AuthCodes++
 		}
 	}
+	if delErr != nil {
+		return result, delErr
+	}
+
+	var revokedTokens RevokedTokenList
+	if err := cli.list(ctx, resourceRevokedToken, &revokedTokens); err != nil {
+		return result, fmt.Errorf("failed to list revoked tokens: %w", err)
+	}
+
+	for _, t := range revokedTokens.RevokedTokens {
+		if now.After(t.Expiry) {
+			if err := cli.delete(ctx, resourceRevokedToken, t.ObjectMeta.Name); err != nil {
+				cli.logger.Errorf("failed to delete revoked token %v", err)
+				delErr = fmt.Errorf("failed to delete revoked token: %w", err)
+			}
+			result.RevokedTokens++
+		}
+	}
+	if delErr != nil {
+		return result, delErr
+	}
+
+	var sessions SessionList
+	if err := cli.list(ctx, resourceSession, &sessions); err != nil {
+		return result, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	for _, s := range sessions.Sessions {
+		if now.After(s.Expiry) {
+			if err := cli.delete(ctx, resourceSession, s.ObjectMeta.Name); err != nil {
+				cli.logger.Errorf("failed to delete session %v", err)
+				delErr = fmt.Errorf("failed to delete session: %w", err)
+			}
+			result.Sessions++
+		}
+	}
+	if delErr != nil {
+		return result, delErr
+	}
+
+	var codes PreAuthorizedCodeList
+	if err := cli.list(ctx, resourcePreAuthCode, &codes); err != nil {
+		return result, fmt.Errorf("failed to list pre-authorized codes: %w", err)
+	}
+
+	for _, p := range codes.PreAuthorizedCodes {
+		if now.After(p.Expiry) {
+			if err := cli.delete(ctx, resourcePreAuthCode, p.ObjectMeta.Name); err != nil {
+				cli.logger.Errorf("failed to delete pre-authorized code %v", err)
+				delErr = fmt.Errorf("failed to delete pre-authorized code: %w", err)
+			}
+			result.PreAuthorizedCodes++
+		}
+	}
+	if delErr != nil {
+		return result, delErr
+	}
+
+	var links LoginLinkList
+	if err := cli.list(ctx, resourceLoginLink, &links); err != nil {
+		return result, fmt.Errorf("failed to list login links: %w", err)
+	}
+
+	for _, l := range links.LoginLinks {
+		if now.After(l.Expiry) {
+			if err := cli.delete(ctx, resourceLoginLink, l.ObjectMeta.Name); err != nil {
+				cli.logger.Errorf("failed to delete login link %v", err)
+				delErr = fmt.Errorf("failed to delete login link: %w", err)
+			}
+			result.LoginLinks++
+		}
+	}
 	return result, delErr
 }
+
+func (cli *client) CreateTermsAcceptance(ctx context.Context, a storage.TermsAcceptance) error {
+	return cli.post(ctx, resourceTermsAcceptance, cli.fromStorageTermsAcceptance(a))
+}
+
+func (cli *client) GetTermsAcceptance(ctx context.Context, userID string, connID string) (storage.TermsAcceptance, error) {
+	a, err := cli.getTermsAcceptance(ctx, userID, connID)
+	if err != nil {
+		return storage.TermsAcceptance{}, err
+	}
+	return toStorageTermsAcceptance(a), nil
+}
+
+func (cli *client) getTermsAcceptance(ctx context.Context, userID string, connID string) (a TermsAcceptance, err error) {
+	name := cli.offlineTokenName(userID, connID)
+	if err = cli.get(ctx, resourceTermsAcceptance, name, &a); err != nil {
+		return TermsAcceptance{}, err
+	}
+	if userID != a.UserID || connID != a.ConnID {
+		return TermsAcceptance{}, fmt.Errorf("get terms acceptance: wrong acceptance retrieved")
+	}
+	return a, nil
+}
+
+func (cli *client) DeleteTermsAcceptance(ctx context.Context, userID string, connID string) error {
+	// Check for hash collision.
+	a, err := cli.getTermsAcceptance(ctx, userID, connID)
+	if err != nil {
+		return err
+	}
+	return cli.delete(ctx, resourceTermsAcceptance, a.ObjectMeta.Name)
+}
+
+func (cli *client) UpdateTermsAcceptance(ctx context.Context, userID string, connID string, updater func(old storage.TermsAcceptance) (storage.TermsAcceptance, error)) error {
+	a, err := cli.getTermsAcceptance(ctx, userID, connID)
+	if err != nil {
+		return err
+	}
+
+	updated, err := updater(toStorageTermsAcceptance(a))
+	if err != nil {
+		return err
+	}
+
+	newAcceptance := cli.fromStorageTermsAcceptance(updated)
+	newAcceptance.ObjectMeta = a.ObjectMeta
+	return cli.put(ctx, resourceTermsAcceptance, a.ObjectMeta.Name, newAcceptance)
+}
+
+func (cli *client) CreateTOTPSecret(ctx context.Context, t storage.TOTPSecret) error {
+	return cli.post(ctx, resourceTOTPSecret, cli.fromStorageTOTPSecret(t))
+}
+
+func (cli *client) GetTOTPSecret(ctx context.Context, userID string, connID string) (storage.TOTPSecret, error) {
+	t, err := cli.getTOTPSecret(ctx, userID, connID)
+	if err != nil {
+		return storage.TOTPSecret{}, err
+	}
+	return toStorageTOTPSecret(t), nil
+}
+
+func (cli *client) getTOTPSecret(ctx context.Context, userID string, connID string) (t TOTPSecret, err error) {
+	name := cli.offlineTokenName(userID, connID)
+	if err = cli.get(ctx, resourceTOTPSecret, name, &t); err != nil {
+		return TOTPSecret{}, err
+	}
+	if userID != t.UserID || connID != t.ConnID {
+		return TOTPSecret{}, fmt.Errorf("get totp secret: wrong secret retrieved")
+	}
+	return t, nil
+}
+
+func (cli *client) DeleteTOTPSecret(ctx context.Context, userID string, connID string) error {
+	// Check for hash collision.
+	t, err := cli.getTOTPSecret(ctx, userID, connID)
+	if err != nil {
+		return err
+	}
+	return cli.delete(ctx, resourceTOTPSecret, t.ObjectMeta.Name)
+}
+
+func (cli *client) UpdateTOTPSecret(ctx context.Context, userID string, connID string, updater func(old storage.TOTPSecret) (storage.TOTPSecret, error)) error {
+	t, err := cli.getTOTPSecret(ctx, userID, connID)
+	if err != nil {
+		return err
+	}
+
+	updated, err := updater(toStorageTOTPSecret(t))
+	if err != nil {
+		return err
+	}
+
+	newSecret := cli.fromStorageTOTPSecret(updated)
+	newSecret.ObjectMeta = t.ObjectMeta
+	return cli.put(ctx, resourceTOTPSecret, t.ObjectMeta.Name, newSecret)
+}
+
+func (cli *client) CreateAuditEvent(ctx context.Context, e storage.AuditEvent) error {
+	return cli.post(ctx, resourceAuditEvent, cli.fromStorageAuditEvent(e))
+}
+
+func (cli *client) ListAuditEvents(ctx context.Context, filter storage.AuditEventFilter) ([]storage.AuditEvent, error) {
+	var auditEvents AuditEventList
+	if err := cli.list(ctx, resourceAuditEvent, &auditEvents); err != nil {
+		return nil, fmt.Errorf("failed to list audit events: %w", err)
+	}
+
+	var events []storage.AuditEvent
+	for _, auditEvent := range auditEvents.AuditEvents {
+		if e := toStorageAuditEvent(auditEvent); filter.Matches(e) {
+			events = append(events, e)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Time.After(events[j].Time) })
+	if filter.Limit > 0 && len(events) > filter.Limit {
+		events = events[:filter.Limit]
+	}
+	return events, nil
+}
+
+func (cli *client) UpdateAuditEvent(ctx context.Context, id string, updater func(e storage.AuditEvent) (storage.AuditEvent, error)) error {
+	var e AuditEvent
+	if err := cli.get(ctx, resourceAuditEvent, id, &e); err != nil {
+		return err
+	}
+
+	updated, err := updater(toStorageAuditEvent(e))
+	if err != nil {
+		return err
+	}
+
+	newEvent := cli.fromStorageAuditEvent(updated)
+	newEvent.ObjectMeta = e.ObjectMeta
+	return cli.put(ctx, resourceAuditEvent, e.ObjectMeta.Name, newEvent)
+}
+
+func (cli *client) PruneAuditEvents(ctx context.Context, before time.Time) (n int64, err error) {
+	var auditEvents AuditEventList
+	if err := cli.list(ctx, resourceAuditEvent, &auditEvents); err != nil {
+		return 0, fmt.Errorf("failed to list audit events: %w", err)
+	}
+
+	for _, auditEvent := range auditEvents.AuditEvents {
+		if !auditEvent.Time.Before(before) {
+			continue
+		}
+		if err := cli.delete(ctx, resourceAuditEvent, auditEvent.ObjectMeta.Name); err != nil {
+			return n, fmt.Errorf("failed to delete audit event: %w", err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+func (cli *client) CreateAPIKey(ctx context.Context, k storage.APIKey) error {
+	return cli.post(ctx, resourceAPIKey, cli.fromStorageAPIKey(k))
+}
+
+func (cli *client) GetAPIKey(ctx context.Context, id string) (storage.APIKey, error) {
+	var k APIKey
+	if err := cli.get(ctx, resourceAPIKey, id, &k); err != nil {
+		return storage.APIKey{}, err
+	}
+	return toStorageAPIKey(k), nil
+}
+
+func (cli *client) ListAPIKeys(ctx context.Context) ([]storage.APIKey, error) {
+	var apiKeys APIKeyList
+	if err := cli.list(ctx, resourceAPIKey, &apiKeys); err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+
+	keys := make([]storage.APIKey, len(apiKeys.APIKeys))
+	for i, k := range apiKeys.APIKeys {
+		keys[i] = toStorageAPIKey(k)
+	}
+	return keys, nil
+}
+
+func (cli *client) DeleteAPIKey(ctx context.Context, id string) error {
+	return cli.delete(ctx, resourceAPIKey, id)
+}
+
+func (cli *client) CreateServiceAccount(ctx context.Context, a storage.ServiceAccount) error {
+	return cli.post(ctx, resourceServiceAccount, cli.fromStorageServiceAccount(a))
+}
+
+func (cli *client) GetServiceAccount(ctx context.Context, id string) (storage.ServiceAccount, error) {
+	var a ServiceAccount
+	if err := cli.get(ctx, resourceServiceAccount, id, &a); err != nil {
+		return storage.ServiceAccount{}, err
+	}
+	return toStorageServiceAccount(a), nil
+}
+
+func (cli *client) ListServiceAccounts(ctx context.Context) ([]storage.ServiceAccount, error) {
+	var serviceAccounts ServiceAccountList
+	if err := cli.list(ctx, resourceServiceAccount, &serviceAccounts); err != nil {
+		return nil, fmt.Errorf("failed to list service accounts: %w", err)
+	}
+
+	accounts := make([]storage.ServiceAccount, len(serviceAccounts.ServiceAccounts))
+	for i, a := range serviceAccounts.ServiceAccounts {
+		accounts[i] = toStorageServiceAccount(a)
+	}
+	return accounts, nil
+}
+
+func (cli *client) UpdateServiceAccount(ctx context.Context, id string, updater func(a storage.ServiceAccount) (storage.ServiceAccount, error)) error {
+	var a ServiceAccount
+	if err := cli.get(ctx, resourceServiceAccount, id, &a); err != nil {
+		return err
+	}
+
+	updated, err := updater(toStorageServiceAccount(a))
+	if err != nil {
+		return err
+	}
+
+	newAccount := cli.fromStorageServiceAccount(updated)
+	newAccount.ObjectMeta = a.ObjectMeta
+	return cli.put(ctx, resourceServiceAccount, id, newAccount)
+}
+
+func (cli *client) DeleteServiceAccount(ctx context.Context, id string) error {
+	return cli.delete(ctx, resourceServiceAccount, id)
+}
+
+func (cli *client) CreateRevokedToken(ctx context.Context, t storage.RevokedToken) error {
+	return cli.post(ctx, resourceRevokedToken, cli.fromStorageRevokedToken(t))
+}
+
+func (cli *client) GetRevokedToken(ctx context.Context, id string) (storage.RevokedToken, error) {
+	var t RevokedToken
+	if err := cli.get(ctx, resourceRevokedToken, id, &t); err != nil {
+		return storage.RevokedToken{}, err
+	}
+	return toStorageRevokedToken(t), nil
+}
+
+func (cli *client) CreateSession(ctx context.Context, s storage.Session) error {
+	return cli.post(ctx, resourceSession, cli.fromStorageSession(s))
+}
+
+func (cli *client) GetSession(ctx context.Context, id string) (storage.Session, error) {
+	var s Session
+	if err := cli.get(ctx, resourceSession, id, &s); err != nil {
+		return storage.Session{}, err
+	}
+	return toStorageSession(s), nil
+}
+
+func (cli *client) ListSessions(ctx context.Context) ([]storage.Session, error) {
+	var sessionList SessionList
+	if err := cli.list(ctx, resourceSession, &sessionList); err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	sessions := make([]storage.Session, len(sessionList.Sessions))
+	for i, s := range sessionList.Sessions {
+		sessions[i] = toStorageSession(s)
+	}
+	return sessions, nil
+}
+
+func (cli *client) UpdateSession(ctx context.Context, id string, updater func(s storage.Session) (storage.Session, error)) error {
+	var s Session
+	if err := cli.get(ctx, resourceSession, id, &s); err != nil {
+		return err
+	}
+
+	updated, err := updater(toStorageSession(s))
+	if err != nil {
+		return err
+	}
+
+	newSession := cli.fromStorageSession(updated)
+	newSession.ObjectMeta = s.ObjectMeta
+	return cli.put(ctx, resourceSession, id, newSession)
+}
+
+func (cli *client) DeleteSession(ctx context.Context, id string) error {
+	return cli.delete(ctx, resourceSession, id)
+}
+
+func (cli *client) CreatePreAuthorizedCode(ctx context.Context, p storage.PreAuthorizedCode) error {
+	return cli.post(ctx, resourcePreAuthCode, cli.fromStoragePreAuthorizedCode(p))
+}
+
+func (cli *client) ConsumePreAuthorizedCode(ctx context.Context, id string) (storage.PreAuthorizedCode, error) {
+	var p PreAuthorizedCode
+	if err := cli.get(ctx, resourcePreAuthCode, id, &p); err != nil {
+		return storage.PreAuthorizedCode{}, err
+	}
+	// Only one concurrent delete of the resource succeeds, the others
+	// observe a 404 and report ErrNotFound.
+	if err := cli.delete(ctx, resourcePreAuthCode, p.ObjectMeta.Name); err != nil {
+		return storage.PreAuthorizedCode{}, err
+	}
+	return toStoragePreAuthorizedCode(p), nil
+}
+
+func (cli *client) CreateWebAuthnCredential(ctx context.Context, c storage.WebAuthnCredential) error {
+	return cli.post(ctx, resourceWebAuthnCredential, cli.fromStorageWebAuthnCredential(c))
+}
+
+func (cli *client) GetWebAuthnCredential(ctx context.Context, id string) (storage.WebAuthnCredential, error) {
+	c, err := cli.getWebAuthnCredential(ctx, id)
+	if err != nil {
+		return storage.WebAuthnCredential{}, err
+	}
+	return toStorageWebAuthnCredential(c), nil
+}
+
+func (cli *client) getWebAuthnCredential(ctx context.Context, id string) (WebAuthnCredential, error) {
+	var c WebAuthnCredential
+	if err := cli.get(ctx, resourceWebAuthnCredential, cli.idToName(id), &c); err != nil {
+		return WebAuthnCredential{}, err
+	}
+	if id != c.CredentialID {
+		return WebAuthnCredential{}, fmt.Errorf("get webauthn credential: ID %q mapped to credential with ID %q", id, c.CredentialID)
+	}
+	return c, nil
+}
+
+func (cli *client) ListWebAuthnCredentials(ctx context.Context, userID string, connID string) ([]storage.WebAuthnCredential, error) {
+	var list WebAuthnCredentialList
+	if err := cli.list(ctx, resourceWebAuthnCredential, &list); err != nil {
+		return nil, fmt.Errorf("failed to list webauthn credentials: %w", err)
+	}
+	var creds []storage.WebAuthnCredential
+	for _, c := range list.WebAuthnCredentials {
+		if c.UserID == userID && c.ConnectorID == connID {
+			creds = append(creds, toStorageWebAuthnCredential(c))
+		}
+	}
+	return creds, nil
+}
+
+func (cli *client) UpdateWebAuthnCredential(ctx context.Context, id string, updater func(c storage.WebAuthnCredential) (storage.WebAuthnCredential, error)) error {
+	c, err := cli.getWebAuthnCredential(ctx, id)
+	if err != nil {
+		return err
+	}
+	updated, err := updater(toStorageWebAuthnCredential(c))
+	if err != nil {
+		return err
+	}
+	updated.ID = c.CredentialID
+
+	newCredential := cli.fromStorageWebAuthnCredential(updated)
+	newCredential.ObjectMeta = c.ObjectMeta
+	return cli.put(ctx, resourceWebAuthnCredential, c.ObjectMeta.Name, newCredential)
+}
+
+func (cli *client) DeleteWebAuthnCredential(ctx context.Context, id string) error {
+	// Check for hash collision.
+	c, err := cli.getWebAuthnCredential(ctx, id)
+	if err != nil {
+		return err
+	}
+	return cli.delete(ctx, resourceWebAuthnCredential, c.ObjectMeta.Name)
+}
+
+func (cli *client) CreateLoginLink(ctx context.Context, l storage.LoginLink) error {
+	return cli.post(ctx, resourceLoginLink, cli.fromStorageLoginLink(l))
+}
+
+func (cli *client) ConsumeLoginLink(ctx context.Context, id string) (storage.LoginLink, error) {
+	var l LoginLink
+	if err := cli.get(ctx, resourceLoginLink, id, &l); err != nil {
+		return storage.LoginLink{}, err
+	}
+	// Only one concurrent delete of the resource succeeds, the others
+	// observe a 404 and report ErrNotFound.
+	if err := cli.delete(ctx, resourceLoginLink, l.ObjectMeta.Name); err != nil {
+		return storage.LoginLink{}, err
+	}
+	return toStorageLoginLink(l), nil
+}
@@ -1,6 +1,7 @@
 package kubernetes
 
 import (
+	"context"
 	"io/ioutil"
 	"os"
 	"strings"
@@ -81,6 +82,7 @@ func (s *StorageTestSuite) SetupTest() {
 }
 
 func (s *StorageTestSuite) TestStorage() {
+	ctx := context.Background()
 	newStorage := func() storage.Storage {
 		for _, resource := range []string{
 			resourceAuthCode,
@@ -90,7 +92,7 @@ func (s *StorageTestSuite) TestStorage() {
 			resourceKeys,
 			resourcePassword,
 		} {
-			if err := s.client.deleteAll(resource); err != nil {
+			if err := s.client.deleteAll(ctx, resource); err != nil {
 				s.T().Fatalf("delete all %q failed: %v", resource, err)
 			}
 		}
@@ -143,6 +143,156 @@ var customResourceDefinitions = []k8sapi.CustomResourceDefinition{
 			},
 		},
 	},
+	{
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name: "termsacceptances.dex.coreos.com",
+		},
+		TypeMeta: crdMeta,
+		Spec: k8sapi.CustomResourceDefinitionSpec{
+			Group:   apiGroup,
+			Version: "v1",
+			Names: k8sapi.CustomResourceDefinitionNames{
+				Plural:   "termsacceptances",
+				Singular: "termsacceptance",
+				Kind:     "TermsAcceptance",
+			},
+		},
+	},
+	{
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name: "auditevents.dex.coreos.com",
+		},
+		TypeMeta: crdMeta,
+		Spec: k8sapi.CustomResourceDefinitionSpec{
+			Group:   apiGroup,
+			Version: "v1",
+			Names: k8sapi.CustomResourceDefinitionNames{
+				Plural:   "auditevents",
+				Singular: "auditevent",
+				Kind:     "AuditEvent",
+			},
+		},
+	},
+	{
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name: "apikeys.dex.coreos.com",
+		},
+		TypeMeta: crdMeta,
+		Spec: k8sapi.CustomResourceDefinitionSpec{
+			Group:   apiGroup,
+			Version: "v1",
+			Names: k8sapi.CustomResourceDefinitionNames{
+				Plural:   "apikeys",
+				Singular: "apikey",
+				Kind:     "APIKey",
+			},
+		},
+	},
+	{
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name: "serviceaccounts.dex.coreos.com",
+		},
+		TypeMeta: crdMeta,
+		Spec: k8sapi.CustomResourceDefinitionSpec{
+			Group:   apiGroup,
+			Version: "v1",
+			Names: k8sapi.CustomResourceDefinitionNames{
+				Plural:   "serviceaccounts",
+				Singular: "serviceaccount",
+				Kind:     "ServiceAccount",
+			},
+		},
+	},
+	{
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name: "revokedtokens.dex.coreos.com",
+		},
+		TypeMeta: crdMeta,
+		Spec: k8sapi.CustomResourceDefinitionSpec{
+			Group:   apiGroup,
+			Version: "v1",
+			Names: k8sapi.CustomResourceDefinitionNames{
+				Plural:   "revokedtokens",
+				Singular: "revokedtoken",
+				Kind:     "RevokedToken",
+			},
+		},
+	},
+	{
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name: "sessions.dex.coreos.com",
+		},
+		TypeMeta: crdMeta,
+		Spec: k8sapi.CustomResourceDefinitionSpec{
+			Group:   apiGroup,
+			Version: "v1",
+			Names: k8sapi.CustomResourceDefinitionNames{
+				Plural:   "sessions",
+				Singular: "session",
+				Kind:     "Session",
+			},
+		},
+	},
+	{
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name: "preauthorizedcodes.dex.coreos.com",
+		},
+		TypeMeta: crdMeta,
+		Spec: k8sapi.CustomResourceDefinitionSpec{
+			Group:   apiGroup,
+			Version: "v1",
+			Names: k8sapi.CustomResourceDefinitionNames{
+				Plural:   "preauthorizedcodes",
+				Singular: "preauthorizedcode",
+				Kind:     "PreAuthorizedCode",
+			},
+		},
+	},
+	{
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name: "webauthncredentials.dex.coreos.com",
+		},
+		TypeMeta: crdMeta,
+		Spec: k8sapi.CustomResourceDefinitionSpec{
+			Group:   apiGroup,
+			Version: "v1",
+			Names: k8sapi.CustomResourceDefinitionNames{
+				Plural:   "webauthncredentials",
+				Singular: "webauthncredential",
+				Kind:     "WebAuthnCredential",
+			},
+		},
+	},
+	{
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name: "loginlinks.dex.coreos.com",
+		},
+		TypeMeta: crdMeta,
+		Spec: k8sapi.CustomResourceDefinitionSpec{
+			Group:   apiGroup,
+			Version: "v1",
+			Names: k8sapi.CustomResourceDefinitionNames{
+				Plural:   "loginlinks",
+				Singular: "loginlink",
+				Kind:     "LoginLink",
+			},
+		},
+	},
+	{
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name: "totpsecrets.dex.coreos.com",
+		},
+		TypeMeta: crdMeta,
+		Spec: k8sapi.CustomResourceDefinitionSpec{
+			Group:   apiGroup,
+			Version: "v1",
+			Names: k8sapi.CustomResourceDefinitionNames{
+				Plural:   "totpsecrets",
+				Singular: "totpsecret",
+				Kind:     "TOTPSecret",
+			},
+		},
+	},
 }
 
 // There will only ever be a single keys resource. Maintain this by setting a
@@ -167,6 +317,19 @@ type Client struct {
 
 	Name    string `json:"name,omitempty"`
 	LogoURL string `json:"logoURL,omitempty"`
+
+	AllowedCIDRs []string `json:"allowedCIDRs,omitempty"`
+
+	Claims map[string]interface{} `json:"claims,omitempty"`
+
+	TOSURI          string   `json:"tosURI,omitempty"`
+	PolicyURI       string   `json:"policyURI,omitempty"`
+	Contacts        []string `json:"contacts,omitempty"`
+	ApplicationType string   `json:"applicationType,omitempty"`
+
+	Expiry time.Time `json:"expiry,omitempty"`
+
+	BackchannelLogoutURI string `json:"backchannelLogoutURI,omitempty"`
 }
 
 // ClientList is a list of Clients.
@@ -186,25 +349,43 @@ func (cli *client) fromStorageClient(c storage.Client) Client {
 			Name:      cli.idToName(c.ID),
 			Namespace: cli.namespace,
 		},
-		ID:           c.ID,
-		Secret:       c.Secret,
-		RedirectURIs: c.RedirectURIs,
-		TrustedPeers: c.TrustedPeers,
-		Public:       c.Public,
-		Name:         c.Name,
-		LogoURL:      c.LogoURL,
+		ID:              c.ID,
+		Secret:          c.Secret,
+		RedirectURIs:    c.RedirectURIs,
+		TrustedPeers:    c.TrustedPeers,
+		Public:          c.Public,
+		Name:            c.Name,
+		LogoURL:         c.LogoURL,
+		AllowedCIDRs:    c.AllowedCIDRs,
+		Claims:          c.Claims,
+		TOSURI:          c.TOSURI,
+		PolicyURI:       c.PolicyURI,
+		Contacts:        c.Contacts,
+		ApplicationType: c.ApplicationType,
+		Expiry:          c.Expiry,
+
+		BackchannelLogoutURI: c.BackchannelLogoutURI,
 	}
 }
 
 func toStorageClient(c Client) storage.Client {
 	return storage.Client{
-		ID:           c.ID,
-		Secret:       c.Secret,
-		RedirectURIs: c.RedirectURIs,
-		TrustedPeers: c.TrustedPeers,
-		Public:       c.Public,
-		Name:         c.Name,
-		LogoURL:      c.LogoURL,
+		ID:              c.ID,
+		Secret:          c.Secret,
+		RedirectURIs:    c.RedirectURIs,
+		TrustedPeers:    c.TrustedPeers,
+		Public:          c.Public,
+		Name:            c.Name,
+		LogoURL:         c.LogoURL,
+		AllowedCIDRs:    c.AllowedCIDRs,
+		Claims:          c.Claims,
+		TOSURI:          c.TOSURI,
+		PolicyURI:       c.PolicyURI,
+		Contacts:        c.Contacts,
+		ApplicationType: c.ApplicationType,
+		Expiry:          c.Expiry,
+
+		BackchannelLogoutURI: c.BackchannelLogoutURI,
 	}
 }
 
@@ -267,8 +448,17 @@ type AuthRequest struct {
 	// The connector used to login the user. Set when the user authenticates.
 	ConnectorID   string `json:"connectorID,omitempty"`
 	ConnectorData []byte `json:"connectorData,omitempty"`
+	// The connector the user was offered ConnectorID as a fallback for.
+	FallbackFrom string `json:"fallbackFrom,omitempty"`
 
 	Expiry time.Time `json:"expiry"`
+
+	CodeChallenge       string `json:"codeChallenge,omitempty"`
+	CodeChallengeMethod string `json:"codeChallengeMethod,omitempty"`
+
+	// The second factor the user must still pass.
+	SecondFactor          string `json:"secondFactor,omitempty"`
+	SecondFactorChallenge []byte `json:"secondFactorChallenge,omitempty"`
 }
 
 // AuthRequestList is a list of AuthRequests.
@@ -291,8 +481,15 @@ func toStorageAuthRequest(req AuthRequest) storage.AuthRequest {
 		LoggedIn:            req.LoggedIn,
 		ConnectorID:         req.ConnectorID,
 		ConnectorData:       req.ConnectorData,
+		FallbackFrom:        req.FallbackFrom,
 		Expiry:              req.Expiry,
 		Claims:              toStorageClaims(req.Claims),
+		PKCE: storage.PKCE{
+			CodeChallenge:       req.CodeChallenge,
+			CodeChallengeMethod: req.CodeChallengeMethod,
+		},
+		SecondFactor:          req.SecondFactor,
+		SecondFactorChallenge: req.SecondFactorChallenge,
 	}
 	return a
 }
@@ -317,8 +514,14 @@ func (cli *client) fromStorageAuthRequest(a storage.AuthRequest) AuthRequest {
 		ForceApprovalPrompt: a.ForceApprovalPrompt,
 		ConnectorID:         a.ConnectorID,
 		ConnectorData:       a.ConnectorData,
+		FallbackFrom:        a.FallbackFrom,
 		Expiry:              a.Expiry,
 		Claims:              fromStorageClaims(a.Claims),
+		CodeChallenge:       a.PKCE.CodeChallenge,
+		CodeChallengeMethod: a.PKCE.CodeChallengeMethod,
+
+		SecondFactor:          a.SecondFactor,
+		SecondFactorChallenge: a.SecondFactorChallenge,
 	}
 	return req
 }
@@ -392,6 +595,9 @@ type AuthCode struct {
 	ConnectorData []byte `json:"connectorData,omitempty"`
 
 	Expiry time.Time `json:"expiry"`
+
+	CodeChallenge       string `json:"codeChallenge,omitempty"`
+	CodeChallengeMethod string `json:"codeChallengeMethod,omitempty"`
 }
 
 // AuthCodeList is a list of AuthCodes.
@@ -411,14 +617,16 @@ func (cli *client) fromStorageAuthCode(a storage.AuthCode) AuthCode {
 			Name:      a.ID,
 			Namespace: cli.namespace,
 		},
-		ClientID:      a.ClientID,
-		RedirectURI:   a.RedirectURI,
-		ConnectorID:   a.ConnectorID,
-		ConnectorData: a.ConnectorData,
-		Nonce:         a.Nonce,
-		Scopes:        a.Scopes,
-		Claims:        fromStorageClaims(a.Claims),
-		Expiry:        a.Expiry,
+		ClientID:            a.ClientID,
+		RedirectURI:         a.RedirectURI,
+		ConnectorID:         a.ConnectorID,
+		ConnectorData:       a.ConnectorData,
+		Nonce:               a.Nonce,
+		Scopes:              a.Scopes,
+		Claims:              fromStorageClaims(a.Claims),
+		Expiry:              a.Expiry,
+		CodeChallenge:       a.PKCE.CodeChallenge,
+		CodeChallengeMethod: a.PKCE.CodeChallengeMethod,
 	}
 }
 
@@ -433,6 +641,10 @@ func toStorageAuthCode(a AuthCode) storage.AuthCode {
 		Scopes:        a.Scopes,
 		Claims:        toStorageClaims(a.Claims),
 		Expiry:        a.Expiry,
+		PKCE: storage.PKCE{
+			CodeChallenge:       a.CodeChallenge,
+			CodeChallengeMethod: a.CodeChallengeMethod,
+		},
 	}
 }
 
@@ -442,8 +654,11 @@ type RefreshToken struct {
 	k8sapi.TypeMeta   `json:",inline"`
 	k8sapi.ObjectMeta `json:"metadata,omitempty"`
 
-	CreatedAt time.Time
-	LastUsed  time.Time
+	CreatedAt  time.Time
+	LastUsed   time.Time
+	LastUsedIP string `json:"lastUsedIP,omitempty"`
+
+	DeviceFingerprint string `json:"deviceFingerprint,omitempty"`
 
 	ClientID string   `json:"clientID"`
 	Scopes   []string `json:"scopes,omitempty"`
@@ -466,16 +681,18 @@ type RefreshList struct {
 
 func toStorageRefreshToken(r RefreshToken) storage.RefreshToken {
 	return storage.RefreshToken{
-		ID:            r.ObjectMeta.Name,
-		Token:         r.Token,
-		CreatedAt:     r.CreatedAt,
-		LastUsed:      r.LastUsed,
-		ClientID:      r.ClientID,
-		ConnectorID:   r.ConnectorID,
-		ConnectorData: r.ConnectorData,
-		Scopes:        r.Scopes,
-		Nonce:         r.Nonce,
-		Claims:        toStorageClaims(r.Claims),
+		ID:                r.ObjectMeta.Name,
+		Token:             r.Token,
+		CreatedAt:         r.CreatedAt,
+		LastUsed:          r.LastUsed,
+		LastUsedIP:        r.LastUsedIP,
+		DeviceFingerprint: r.DeviceFingerprint,
+		ClientID:          r.ClientID,
+		ConnectorID:       r.ConnectorID,
+		ConnectorData:     r.ConnectorData,
+		Scopes:            r.Scopes,
+		Nonce:             r.Nonce,
+		Claims:            toStorageClaims(r.Claims),
 	}
 }
 
@@ -489,15 +706,17 @@ func (cli *client) fromStorageRefreshToken(r storage.RefreshToken) RefreshToken
 			Name:      r.ID,
 			Namespace: cli.namespace,
 		},
-		Token:         r.Token,
-		CreatedAt:     r.CreatedAt,
-		LastUsed:      r.LastUsed,
-		ClientID:      r.ClientID,
-		ConnectorID:   r.ConnectorID,
-		ConnectorData: r.ConnectorData,
-		Scopes:        r.Scopes,
-		Nonce:         r.Nonce,
-		Claims:        fromStorageClaims(r.Claims),
+		Token:             r.Token,
+		CreatedAt:         r.CreatedAt,
+		LastUsed:          r.LastUsed,
+		LastUsedIP:        r.LastUsedIP,
+		DeviceFingerprint: r.DeviceFingerprint,
+		ClientID:          r.ClientID,
+		ConnectorID:       r.ConnectorID,
+		ConnectorData:     r.ConnectorData,
+		Scopes:            r.Scopes,
+		Nonce:             r.Nonce,
+		Claims:            fromStorageClaims(r.Claims),
 	}
 }
 
@@ -635,3 +854,517 @@ type ConnectorList struct {
 	k8sapi.ListMeta `json:"metadata,omitempty"`
 	Connectors      []Connector `json:"items"`
 }
+
+// TermsAcceptance is a mirrored struct from storage with JSON struct tags and
+// Kubernetes type metadata.
+type TermsAcceptance struct {
+	k8sapi.TypeMeta   `json:",inline"`
+	k8sapi.ObjectMeta `json:"metadata,omitempty"`
+
+	UserID     string    `json:"userID,omitempty"`
+	ConnID     string    `json:"connID,omitempty"`
+	Version    string    `json:"version,omitempty"`
+	AcceptedAt time.Time `json:"acceptedAt"`
+}
+
+func (cli *client) fromStorageTermsAcceptance(a storage.TermsAcceptance) TermsAcceptance {
+	return TermsAcceptance{
+		TypeMeta: k8sapi.TypeMeta{
+			Kind:       kindTermsAcceptance,
+			APIVersion: cli.apiVersion,
+		},
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name:      cli.offlineTokenName(a.UserID, a.ConnID),
+			Namespace: cli.namespace,
+		},
+		UserID:     a.UserID,
+		ConnID:     a.ConnID,
+		Version:    a.Version,
+		AcceptedAt: a.AcceptedAt,
+	}
+}
+
+func toStorageTermsAcceptance(a TermsAcceptance) storage.TermsAcceptance {
+	return storage.TermsAcceptance{
+		UserID:     a.UserID,
+		ConnID:     a.ConnID,
+		Version:    a.Version,
+		AcceptedAt: a.AcceptedAt,
+	}
+}
+
+// TOTPSecret is a mirrored struct from storage with JSON struct tags and
+// Kubernetes type metadata.
+type TOTPSecret struct {
+	k8sapi.TypeMeta   `json:",inline"`
+	k8sapi.ObjectMeta `json:"metadata,omitempty"`
+
+	UserID      string    `json:"userID,omitempty"`
+	ConnID      string    `json:"connID,omitempty"`
+	Secret      []byte    `json:"secret,omitempty"`
+	Confirmed   bool      `json:"confirmed,omitempty"`
+	LastStep    int64     `json:"lastStep,omitempty"`
+	BackupCodes [][]byte  `json:"backupCodes,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+func (cli *client) fromStorageTOTPSecret(t storage.TOTPSecret) TOTPSecret {
+	return TOTPSecret{
+		TypeMeta: k8sapi.TypeMeta{
+			Kind:       kindTOTPSecret,
+			APIVersion: cli.apiVersion,
+		},
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name:      cli.offlineTokenName(t.UserID, t.ConnID),
+			Namespace: cli.namespace,
+		},
+		UserID:      t.UserID,
+		ConnID:      t.ConnID,
+		Secret:      t.Secret,
+		Confirmed:   t.Confirmed,
+		LastStep:    t.LastStep,
+		BackupCodes: t.BackupCodes,
+		CreatedAt:   t.CreatedAt,
+	}
+}
+
+func toStorageTOTPSecret(t TOTPSecret) storage.TOTPSecret {
+	return storage.TOTPSecret{
+		UserID:      t.UserID,
+		ConnID:      t.ConnID,
+		Secret:      t.Secret,
+		Confirmed:   t.Confirmed,
+		LastStep:    t.LastStep,
+		BackupCodes: t.BackupCodes,
+		CreatedAt:   t.CreatedAt,
+	}
+}
+
+// AuditEvent is a mirrored struct from storage with JSON struct tags and
+// Kubernetes type metadata.
+type AuditEvent struct {
+	k8sapi.TypeMeta   `json:",inline"`
+	k8sapi.ObjectMeta `json:"metadata,omitempty"`
+
+	Type        string    `json:"type,omitempty"`
+	Severity    string    `json:"severity,omitempty"`
+	Time        time.Time `json:"time"`
+	ClientID    string    `json:"clientID,omitempty"`
+	Subject     string    `json:"subject,omitempty"`
+	ConnectorID string    `json:"connectorID,omitempty"`
+	SourceIPs   []string  `json:"sourceIPs,omitempty"`
+	Message     string    `json:"message,omitempty"`
+	Revoked     bool      `json:"revoked,omitempty"`
+}
+
+// AuditEventList is a list of AuditEvents.
+type AuditEventList struct {
+	k8sapi.TypeMeta `json:",inline"`
+	k8sapi.ListMeta `json:"metadata,omitempty"`
+	AuditEvents     []AuditEvent `json:"items"`
+}
+
+func (cli *client) fromStorageAuditEvent(e storage.AuditEvent) AuditEvent {
+	return AuditEvent{
+		TypeMeta: k8sapi.TypeMeta{
+			Kind:       kindAuditEvent,
+			APIVersion: cli.apiVersion,
+		},
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name:      e.ID,
+			Namespace: cli.namespace,
+		},
+		Type:        e.Type,
+		Severity:    e.Severity,
+		Time:        e.Time,
+		ClientID:    e.ClientID,
+		Subject:     e.Subject,
+		ConnectorID: e.ConnectorID,
+		SourceIPs:   e.SourceIPs,
+		Message:     e.Message,
+		Revoked:     e.Revoked,
+	}
+}
+
+func toStorageAuditEvent(e AuditEvent) storage.AuditEvent {
+	return storage.AuditEvent{
+		ID:          e.ObjectMeta.Name,
+		Type:        e.Type,
+		Severity:    e.Severity,
+		Time:        e.Time,
+		ClientID:    e.ClientID,
+		Subject:     e.Subject,
+		ConnectorID: e.ConnectorID,
+		SourceIPs:   e.SourceIPs,
+		Message:     e.Message,
+		Revoked:     e.Revoked,
+	}
+}
+
+// APIKey is a mirrored struct from storage with JSON struct tags and
+// Kubernetes type metadata.
+type APIKey struct {
+	k8sapi.TypeMeta   `json:",inline"`
+	k8sapi.ObjectMeta `json:"metadata,omitempty"`
+
+	Hash        string    `json:"hash,omitempty"`
+	ClientID    string    `json:"clientID,omitempty"`
+	Name        string    `json:"name,omitempty"`
+	Scopes      []string  `json:"scopes,omitempty"`
+	Claims      Claims    `json:"claims,omitempty"`
+	ConnectorID string    `json:"connectorID,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	Expiry      time.Time `json:"expiry"`
+}
+
+// APIKeyList is a list of APIKeys.
+type APIKeyList struct {
+	k8sapi.TypeMeta `json:",inline"`
+	k8sapi.ListMeta `json:"metadata,omitempty"`
+	APIKeys         []APIKey `json:"items"`
+}
+
+func (cli *client) fromStorageAPIKey(k storage.APIKey) APIKey {
+	return APIKey{
+		TypeMeta: k8sapi.TypeMeta{
+			Kind:       kindAPIKey,
+			APIVersion: cli.apiVersion,
+		},
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name:      k.ID,
+			Namespace: cli.namespace,
+		},
+		Hash:        k.Hash,
+		ClientID:    k.ClientID,
+		Name:        k.Name,
+		Scopes:      k.Scopes,
+		Claims:      fromStorageClaims(k.Claims),
+		ConnectorID: k.ConnectorID,
+		CreatedAt:   k.CreatedAt,
+		Expiry:      k.Expiry,
+	}
+}
+
+func toStorageAPIKey(k APIKey) storage.APIKey {
+	return storage.APIKey{
+		ID:          k.ObjectMeta.Name,
+		Hash:        k.Hash,
+		ClientID:    k.ClientID,
+		Name:        k.Name,
+		Scopes:      k.Scopes,
+		Claims:      toStorageClaims(k.Claims),
+		ConnectorID: k.ConnectorID,
+		CreatedAt:   k.CreatedAt,
+		Expiry:      k.Expiry,
+	}
+}
+
+// ServiceAccount is a mirrored struct from storage with JSON struct tags and
+// Kubernetes type metadata.
+type ServiceAccount struct {
+	k8sapi.TypeMeta   `json:",inline"`
+	k8sapi.ObjectMeta `json:"metadata,omitempty"`
+
+	Name       string            `json:"name,omitempty"`
+	Groups     []string          `json:"groups,omitempty"`
+	Clients    []string          `json:"clients,omitempty"`
+	PublicKeys []jose.JSONWebKey `json:"publicKeys,omitempty"`
+	CreatedAt  time.Time         `json:"createdAt"`
+}
+
+// ServiceAccountList is a list of ServiceAccounts.
+type ServiceAccountList struct {
+	k8sapi.TypeMeta `json:",inline"`
+	k8sapi.ListMeta `json:"metadata,omitempty"`
+	ServiceAccounts []ServiceAccount `json:"items"`
+}
+
+func (cli *client) fromStorageServiceAccount(a storage.ServiceAccount) ServiceAccount {
+	return ServiceAccount{
+		TypeMeta: k8sapi.TypeMeta{
+			Kind:       kindServiceAccount,
+			APIVersion: cli.apiVersion,
+		},
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name:      a.ID,
+			Namespace: cli.namespace,
+		},
+		Name:       a.Name,
+		Groups:     a.Groups,
+		Clients:    a.Clients,
+		PublicKeys: a.PublicKeys,
+		CreatedAt:  a.CreatedAt,
+	}
+}
+
+func toStorageServiceAccount(a ServiceAccount) storage.ServiceAccount {
+	return storage.ServiceAccount{
+		ID:         a.ObjectMeta.Name,
+		Name:       a.Name,
+		Groups:     a.Groups,
+		Clients:    a.Clients,
+		PublicKeys: a.PublicKeys,
+		CreatedAt:  a.CreatedAt,
+	}
+}
+
+// RevokedToken is a mirrored struct from storage with JSON struct tags and
+// Kubernetes type metadata.
+type RevokedToken struct {
+	k8sapi.TypeMeta   `json:",inline"`
+	k8sapi.ObjectMeta `json:"metadata,omitempty"`
+
+	ClientID  string    `json:"clientID,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	RevokedAt time.Time `json:"revokedAt"`
+	Expiry    time.Time `json:"expiry"`
+}
+
+// RevokedTokenList is a list of RevokedTokens.
+type RevokedTokenList struct {
+	k8sapi.TypeMeta `json:",inline"`
+	k8sapi.ListMeta `json:"metadata,omitempty"`
+	RevokedTokens   []RevokedToken `json:"items"`
+}
+
+func (cli *client) fromStorageRevokedToken(t storage.RevokedToken) RevokedToken {
+	return RevokedToken{
+		TypeMeta: k8sapi.TypeMeta{
+			Kind:       kindRevokedToken,
+			APIVersion: cli.apiVersion,
+		},
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name:      t.ID,
+			Namespace: cli.namespace,
+		},
+		ClientID:  t.ClientID,
+		Reason:    t.Reason,
+		RevokedAt: t.RevokedAt,
+		Expiry:    t.Expiry,
+	}
+}
+
+func toStorageRevokedToken(t RevokedToken) storage.RevokedToken {
+	return storage.RevokedToken{
+		ID:        t.ObjectMeta.Name,
+		ClientID:  t.ClientID,
+		Reason:    t.Reason,
+		RevokedAt: t.RevokedAt,
+		Expiry:    t.Expiry,
+	}
+}
+
+// Session is a mirrored struct from storage with JSON struct tags and
+// Kubernetes type metadata.
+type Session struct {
+	k8sapi.TypeMeta   `json:",inline"`
+	k8sapi.ObjectMeta `json:"metadata,omitempty"`
+
+	ConnectorID   string    `json:"connectorID,omitempty"`
+	Claims        Claims    `json:"claims,omitempty"`
+	ConnectorData []byte    `json:"connectorData,omitempty"`
+	ClientIDs     []string  `json:"clientIDs,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+	Expiry        time.Time `json:"expiry"`
+}
+
+// SessionList is a list of Sessions.
+type SessionList struct {
+	k8sapi.TypeMeta `json:",inline"`
+	k8sapi.ListMeta `json:"metadata,omitempty"`
+	Sessions        []Session `json:"items"`
+}
+
+func (cli *client) fromStorageSession(s storage.Session) Session {
+	return Session{
+		TypeMeta: k8sapi.TypeMeta{
+			Kind:       kindSession,
+			APIVersion: cli.apiVersion,
+		},
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name:      s.ID,
+			Namespace: cli.namespace,
+		},
+		ConnectorID:   s.ConnectorID,
+		Claims:        fromStorageClaims(s.Claims),
+		ConnectorData: s.ConnectorData,
+		ClientIDs:     s.ClientIDs,
+		CreatedAt:     s.CreatedAt,
+		Expiry:        s.Expiry,
+	}
+}
+
+func toStorageSession(s Session) storage.Session {
+	return storage.Session{
+		ID:            s.ObjectMeta.Name,
+		ConnectorID:   s.ConnectorID,
+		Claims:        toStorageClaims(s.Claims),
+		ConnectorData: s.ConnectorData,
+		ClientIDs:     s.ClientIDs,
+		CreatedAt:     s.CreatedAt,
+		Expiry:        s.Expiry,
+	}
+}
+
+// PreAuthorizedCode is a mirrored struct from storage with JSON struct tags
+// and Kubernetes type metadata.
+type PreAuthorizedCode struct {
+	k8sapi.TypeMeta   `json:",inline"`
+	k8sapi.ObjectMeta `json:"metadata,omitempty"`
+
+	ClientID    string    `json:"clientID"`
+	Scopes      []string  `json:"scopes,omitempty"`
+	Claims      Claims    `json:"claims,omitempty"`
+	ConnectorID string    `json:"connectorID,omitempty"`
+	PINHash     []byte    `json:"pinHash,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	Expiry      time.Time `json:"expiry"`
+}
+
+// PreAuthorizedCodeList is a list of PreAuthorizedCodes.
+type PreAuthorizedCodeList struct {
+	k8sapi.TypeMeta    `json:",inline"`
+	k8sapi.ListMeta    `json:"metadata,omitempty"`
+	PreAuthorizedCodes []PreAuthorizedCode `json:"items"`
+}
+
+func (cli *client) fromStoragePreAuthorizedCode(p storage.PreAuthorizedCode) PreAuthorizedCode {
+	return PreAuthorizedCode{
+		TypeMeta: k8sapi.TypeMeta{
+			Kind:       kindPreAuthCode,
+			APIVersion: cli.apiVersion,
+		},
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name:      p.ID,
+			Namespace: cli.namespace,
+		},
+		ClientID:    p.ClientID,
+		Scopes:      p.Scopes,
+		Claims:      fromStorageClaims(p.Claims),
+		ConnectorID: p.ConnectorID,
+		PINHash:     p.PINHash,
+		CreatedAt:   p.CreatedAt,
+		Expiry:      p.Expiry,
+	}
+}
+
+func toStoragePreAuthorizedCode(p PreAuthorizedCode) storage.PreAuthorizedCode {
+	return storage.PreAuthorizedCode{
+		ID:          p.ObjectMeta.Name,
+		ClientID:    p.ClientID,
+		Scopes:      p.Scopes,
+		Claims:      toStorageClaims(p.Claims),
+		ConnectorID: p.ConnectorID,
+		PINHash:     p.PINHash,
+		CreatedAt:   p.CreatedAt,
+		Expiry:      p.Expiry,
+	}
+}
+
+// WebAuthnCredential is a mirrored struct from storage with JSON struct tags
+// and Kubernetes type metadata.
+type WebAuthnCredential struct {
+	k8sapi.TypeMeta   `json:",inline"`
+	k8sapi.ObjectMeta `json:"metadata,omitempty"`
+
+	// The Kubernetes name is an encoded version of this value, since
+	// credential IDs aren't valid names.
+	//
+	// This field is IMMUTABLE. Do not change.
+	CredentialID string `json:"credentialID,omitempty"`
+
+	UserID      string    `json:"userID,omitempty"`
+	ConnectorID string    `json:"connectorID,omitempty"`
+	PublicKey   []byte    `json:"publicKey,omitempty"`
+	SignCount   uint32    `json:"signCount,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	LastUsed    time.Time `json:"lastUsed"`
+}
+
+// WebAuthnCredentialList is a list of WebAuthnCredentials.
+type WebAuthnCredentialList struct {
+	k8sapi.TypeMeta     `json:",inline"`
+	k8sapi.ListMeta     `json:"metadata,omitempty"`
+	WebAuthnCredentials []WebAuthnCredential `json:"items"`
+}
+
+func (cli *client) fromStorageWebAuthnCredential(c storage.WebAuthnCredential) WebAuthnCredential {
+	return WebAuthnCredential{
+		TypeMeta: k8sapi.TypeMeta{
+			Kind:       kindWebAuthnCredential,
+			APIVersion: cli.apiVersion,
+		},
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name:      cli.idToName(c.ID),
+			Namespace: cli.namespace,
+		},
+		CredentialID: c.ID,
+		UserID:       c.UserID,
+		ConnectorID:  c.ConnectorID,
+		PublicKey:    c.PublicKey,
+		SignCount:    c.SignCount,
+		CreatedAt:    c.CreatedAt,
+		LastUsed:     c.LastUsed,
+	}
+}
+
+func toStorageWebAuthnCredential(c WebAuthnCredential) storage.WebAuthnCredential {
+	return storage.WebAuthnCredential{
+		ID:          c.CredentialID,
+		UserID:      c.UserID,
+		ConnectorID: c.ConnectorID,
+		PublicKey:   c.PublicKey,
+		SignCount:   c.SignCount,
+		CreatedAt:   c.CreatedAt,
+		LastUsed:    c.LastUsed,
+	}
+}
+
+// LoginLink is a mirrored struct from storage with JSON struct tags and
+// Kubernetes type metadata.
+type LoginLink struct {
+	k8sapi.TypeMeta   `json:",inline"`
+	k8sapi.ObjectMeta `json:"metadata,omitempty"`
+
+	AuthRequestID string    `json:"authRequestID"`
+	ConnectorID   string    `json:"connectorID"`
+	Email         string    `json:"email"`
+	CreatedAt     time.Time `json:"createdAt"`
+	Expiry        time.Time `json:"expiry"`
+}
+
+// LoginLinkList is a list of LoginLinks.
+type LoginLinkList struct {
+	k8sapi.TypeMeta `json:",inline"`
+	k8sapi.ListMeta `json:"metadata,omitempty"`
+	LoginLinks      []LoginLink `json:"items"`
+}
+
+func (cli *client) fromStorageLoginLink(l storage.LoginLink) LoginLink {
+	return LoginLink{
+		TypeMeta: k8sapi.TypeMeta{
+			Kind:       kindLoginLink,
+			APIVersion: cli.apiVersion,
+		},
+		ObjectMeta: k8sapi.ObjectMeta{
+			Name:      l.ID,
+			Namespace: cli.namespace,
+		},
+		AuthRequestID: l.AuthRequestID,
+		ConnectorID:   l.ConnectorID,
+		Email:         l.Email,
+		CreatedAt:     l.CreatedAt,
+		Expiry:        l.Expiry,
+	}
+}
+
+func toStorageLoginLink(l LoginLink) storage.LoginLink {
+	return storage.LoginLink{
+		ID:            l.ObjectMeta.Name,
+		AuthRequestID: l.AuthRequestID,
+		ConnectorID:   l.ConnectorID,
+		Email:         l.Email,
+		CreatedAt:     l.CreatedAt,
+		Expiry:        l.Expiry,
+	}
+}
@@ -0,0 +1,377 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// LegacyStorage is the storage interface from before methods took a context.
+//
+// Deprecated: implement Storage instead. LegacyStorage will be removed in a
+// future release.
+type LegacyStorage interface {
+	Close() error
+
+	CreateAuthRequest(a AuthRequest) error
+	CreateClient(c Client) error
+	CreateAuthCode(c AuthCode) error
+	CreateRefresh(r RefreshToken) error
+	CreatePassword(p Password) error
+	CreateOfflineSessions(s OfflineSessions) error
+	CreateConnector(c Connector) error
+
+	GetAuthRequest(id string) (AuthRequest, error)
+	GetAuthCode(id string) (AuthCode, error)
+	GetClient(id string) (Client, error)
+	GetKeys() (Keys, error)
+	GetRefresh(id string) (RefreshToken, error)
+	GetPassword(email string) (Password, error)
+	GetOfflineSessions(userID string, connID string) (OfflineSessions, error)
+	GetConnector(id string) (Connector, error)
+
+	ListClients() ([]Client, error)
+	ListRefreshTokens() ([]RefreshToken, error)
+	ListPasswords() ([]Password, error)
+	ListConnectors() ([]Connector, error)
+
+	DeleteAuthRequest(id string) error
+	DeleteAuthCode(code string) error
+	DeleteClient(id string) error
+	DeleteRefresh(id string) error
+	DeletePassword(email string) error
+	DeleteOfflineSessions(userID string, connID string) error
+	DeleteConnector(id string) error
+
+	UpdateClient(id string, updater func(old Client) (Client, error)) error
+	UpdateKeys(updater func(old Keys) (Keys, error)) error
+	UpdateAuthRequest(id string, updater func(a AuthRequest) (AuthRequest, error)) error
+	UpdateRefreshToken(id string, updater func(r RefreshToken) (RefreshToken, error)) error
+	UpdatePassword(email string, updater func(p Password) (Password, error)) error
+	UpdateOfflineSessions(userID string, connID string, updater func(s OfflineSessions) (OfflineSessions, error)) error
+	UpdateConnector(id string, updater func(c Connector) (Connector, error)) error
+
+	GarbageCollect(now time.Time) (GCResult, error)
+}
+
+// FromLegacy adapts a LegacyStorage to the Storage interface. The contexts
+// passed to the returned storage are ignored.
+//
+// Deprecated: implement Storage instead.
+func FromLegacy(s LegacyStorage) Storage {
+	return legacyStorage{s}
+}
+
+// legacyStorage implements Storage with a LegacyStorage.
+//
+// Everything added to Storage after LegacyStorage was deprecated can't be
+// persisted. Lookups of those objects never find anything, so features using
+// them behave as if nothing was configured, and creating them fails.
+type legacyStorage struct {
+	s LegacyStorage
+}
+
+var (
+	errLegacyUnsupported = errors.New("not supported by legacy storages")
+	errLegacyNotFound    = fmt.Errorf("%w: %v", ErrNotFound, errLegacyUnsupported)
+)
+
+func (l legacyStorage) Close() error { return l.s.Close() }
+
+func (l legacyStorage) CreateAuthRequest(ctx context.Context, a AuthRequest) error {
+	return l.s.CreateAuthRequest(a)
+}
+
+func (l legacyStorage) CreateClient(ctx context.Context, c Client) error {
+	return l.s.CreateClient(c)
+}
+
+func (l legacyStorage) CreateAuthCode(ctx context.Context, c AuthCode) error {
+	return l.s.CreateAuthCode(c)
+}
+
+func (l legacyStorage) CreateRefresh(ctx context.Context, r RefreshToken) error {
+	return l.s.CreateRefresh(r)
+}
+
+func (l legacyStorage) CreatePassword(ctx context.Context, p Password) error {
+	return l.s.CreatePassword(p)
+}
+
+func (l legacyStorage) CreateOfflineSessions(ctx context.Context, s OfflineSessions) error {
+	return l.s.CreateOfflineSessions(s)
+}
+
+func (l legacyStorage) CreateConnector(ctx context.Context, c Connector) error {
+	return l.s.CreateConnector(c)
+}
+
+func (l legacyStorage) GetAuthRequest(ctx context.Context, id string) (AuthRequest, error) {
+	return l.s.GetAuthRequest(id)
+}
+
+func (l legacyStorage) GetAuthCode(ctx context.Context, id string) (AuthCode, error) {
+	return l.s.GetAuthCode(id)
+}
+
+func (l legacyStorage) GetClient(ctx context.Context, id string) (Client, error) {
+	return l.s.GetClient(id)
+}
+
+func (l legacyStorage) GetKeys(ctx context.Context) (Keys, error) {
+	return l.s.GetKeys()
+}
+
+func (l legacyStorage) GetRefresh(ctx context.Context, id string) (RefreshToken, error) {
+	return l.s.GetRefresh(id)
+}
+
+func (l legacyStorage) GetPassword(ctx context.Context, email string) (Password, error) {
+	return l.s.GetPassword(email)
+}
+
+func (l legacyStorage) GetOfflineSessions(ctx context.Context, userID string, connID string) (OfflineSessions, error) {
+	return l.s.GetOfflineSessions(userID, connID)
+}
+
+func (l legacyStorage) GetConnector(ctx context.Context, id string) (Connector, error) {
+	return l.s.GetConnector(id)
+}
+
+func (l legacyStorage) ListClients(ctx context.Context) ([]Client, error) {
+	return l.s.ListClients()
+}
+
+func (l legacyStorage) ListRefreshTokens(ctx context.Context) ([]RefreshToken, error) {
+	return l.s.ListRefreshTokens()
+}
+
+func (l legacyStorage) ListPasswords(ctx context.Context) ([]Password, error) {
+	return l.s.ListPasswords()
+}
+
+func (l legacyStorage) ListConnectors(ctx context.Context) ([]Connector, error) {
+	return l.s.ListConnectors()
+}
+
+func (l legacyStorage) DeleteAuthRequest(ctx context.Context, id string) error {
+	return l.s.DeleteAuthRequest(id)
+}
+
+func (l legacyStorage) DeleteAuthCode(ctx context.Context, code string) error {
+	return l.s.DeleteAuthCode(code)
+}
+
+func (l legacyStorage) DeleteClient(ctx context.Context, id string) error {
+	return l.s.DeleteClient(id)
+}
+
+func (l legacyStorage) DeleteRefresh(ctx context.Context, id string) error {
+	return l.s.DeleteRefresh(id)
+}
+
+func (l legacyStorage) DeletePassword(ctx context.Context, email string) error {
+	return l.s.DeletePassword(email)
+}
+
+func (l legacyStorage) DeleteOfflineSessions(ctx context.Context, userID string, connID string) error {
+	return l.s.DeleteOfflineSessions(userID, connID)
+}
+
+func (l legacyStorage) DeleteConnector(ctx context.Context, id string) error {
+	return l.s.DeleteConnector(id)
+}
+
+// ConsumeAuthCode is emulated with a get and a delete. Deletes are atomic, so
+// only one caller can delete the code and all others receive ErrNotFound.
+func (l legacyStorage) ConsumeAuthCode(ctx context.Context, id string) (AuthCode, error) {
+	c, err := l.s.GetAuthCode(id)
+	if err != nil {
+		return AuthCode{}, err
+	}
+	if err := l.s.DeleteAuthCode(id); err != nil {
+		return AuthCode{}, err
+	}
+	return c, nil
+}
+
+func (l legacyStorage) UpdateClient(ctx context.Context, id string, updater func(old Client) (Client, error)) error {
+	return l.s.UpdateClient(id, updater)
+}
+
+func (l legacyStorage) UpdateKeys(ctx context.Context, updater func(old Keys) (Keys, error)) error {
+	return l.s.UpdateKeys(updater)
+}
+
+func (l legacyStorage) UpdateAuthRequest(ctx context.Context, id string, updater func(a AuthRequest) (AuthRequest, error)) error {
+	return l.s.UpdateAuthRequest(id, updater)
+}
+
+func (l legacyStorage) UpdateRefreshToken(ctx context.Context, id string, updater func(r RefreshToken) (RefreshToken, error)) error {
+	return l.s.UpdateRefreshToken(id, updater)
+}
+
+func (l legacyStorage) UpdatePassword(ctx context.Context, email string, updater func(p Password) (Password, error)) error {
+	return l.s.UpdatePassword(email, updater)
+}
+
+func (l legacyStorage) UpdateOfflineSessions(ctx context.Context, userID string, connID string, updater func(s OfflineSessions) (OfflineSessions, error)) error {
+	return l.s.UpdateOfflineSessions(userID, connID, updater)
+}
+
+func (l legacyStorage) UpdateConnector(ctx context.Context, id string, updater func(c Connector) (Connector, error)) error {
+	return l.s.UpdateConnector(id, updater)
+}
+
+func (l legacyStorage) GarbageCollect(ctx context.Context, now time.Time) (GCResult, error) {
+	return l.s.GarbageCollect(now)
+}
+
+func (l legacyStorage) CreateTermsAcceptance(ctx context.Context, a TermsAcceptance) error {
+	return errLegacyUnsupported
+}
+
+func (l legacyStorage) GetTermsAcceptance(ctx context.Context, userID string, connID string) (TermsAcceptance, error) {
+	return TermsAcceptance{}, errLegacyNotFound
+}
+
+func (l legacyStorage) UpdateTermsAcceptance(ctx context.Context, userID string, connID string, updater func(a TermsAcceptance) (TermsAcceptance, error)) error {
+	return errLegacyNotFound
+}
+
+func (l legacyStorage) DeleteTermsAcceptance(ctx context.Context, userID string, connID string) error {
+	return errLegacyNotFound
+}
+
+func (l legacyStorage) CreateAuditEvent(ctx context.Context, e AuditEvent) error {
+	return errLegacyUnsupported
+}
+
+func (l legacyStorage) ListAuditEvents(ctx context.Context, filter AuditEventFilter) ([]AuditEvent, error) {
+	return nil, errLegacyUnsupported
+}
+
+func (l legacyStorage) UpdateAuditEvent(ctx context.Context, id string, updater func(e AuditEvent) (AuditEvent, error)) error {
+	return errLegacyNotFound
+}
+
+func (l legacyStorage) PruneAuditEvents(ctx context.Context, before time.Time) (int64, error) {
+	return 0, errLegacyUnsupported
+}
+
+func (l legacyStorage) CreateAPIKey(ctx context.Context, k APIKey) error {
+	return errLegacyUnsupported
+}
+
+func (l legacyStorage) GetAPIKey(ctx context.Context, id string) (APIKey, error) {
+	return APIKey{}, errLegacyNotFound
+}
+
+func (l legacyStorage) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	return nil, errLegacyUnsupported
+}
+
+func (l legacyStorage) DeleteAPIKey(ctx context.Context, id string) error {
+	return errLegacyNotFound
+}
+
+func (l legacyStorage) CreateServiceAccount(ctx context.Context, a ServiceAccount) error {
+	return errLegacyUnsupported
+}
+
+func (l legacyStorage) GetServiceAccount(ctx context.Context, id string) (ServiceAccount, error) {
+	return ServiceAccount{}, errLegacyNotFound
+}
+
+func (l legacyStorage) ListServiceAccounts(ctx context.Context) ([]ServiceAccount, error) {
+	return nil, errLegacyUnsupported
+}
+
+func (l legacyStorage) UpdateServiceAccount(ctx context.Context, id string, updater func(a ServiceAccount) (ServiceAccount, error)) error {
+	return errLegacyNotFound
+}
+
+func (l legacyStorage) DeleteServiceAccount(ctx context.Context, id string) error {
+	return errLegacyNotFound
+}
+
+func (l legacyStorage) CreateRevokedToken(ctx context.Context, t RevokedToken) error {
+	return errLegacyUnsupported
+}
+
+func (l legacyStorage) GetRevokedToken(ctx context.Context, id string) (RevokedToken, error) {
+	return RevokedToken{}, errLegacyNotFound
+}
+
+func (l legacyStorage) CreateSession(ctx context.Context, s Session) error {
+	return errLegacyUnsupported
+}
+
+func (l legacyStorage) GetSession(ctx context.Context, id string) (Session, error) {
+	return Session{}, errLegacyNotFound
+}
+
+func (l legacyStorage) ListSessions(ctx context.Context) ([]Session, error) {
+	return nil, errLegacyUnsupported
+}
+
+func (l legacyStorage) UpdateSession(ctx context.Context, id string, updater func(s Session) (Session, error)) error {
+	return errLegacyNotFound
+}
+
+func (l legacyStorage) DeleteSession(ctx context.Context, id string) error {
+	return errLegacyNotFound
+}
+
+func (l legacyStorage) CreatePreAuthorizedCode(ctx context.Context, c PreAuthorizedCode) error {
+	return errLegacyUnsupported
+}
+
+func (l legacyStorage) ConsumePreAuthorizedCode(ctx context.Context, id string) (PreAuthorizedCode, error) {
+	return PreAuthorizedCode{}, errLegacyNotFound
+}
+
+func (l legacyStorage) CreateWebAuthnCredential(ctx context.Context, c WebAuthnCredential) error {
+	return errLegacyUnsupported
+}
+
+func (l legacyStorage) GetWebAuthnCredential(ctx context.Context, id string) (WebAuthnCredential, error) {
+	return WebAuthnCredential{}, errLegacyNotFound
+}
+
+func (l legacyStorage) ListWebAuthnCredentials(ctx context.Context, userID string, connID string) ([]WebAuthnCredential, error) {
+	return nil, errLegacyUnsupported
+}
+
+func (l legacyStorage) UpdateWebAuthnCredential(ctx context.Context, id string, updater func(c WebAuthnCredential) (WebAuthnCredential, error)) error {
+	return errLegacyNotFound
+}
+
+func (l legacyStorage) DeleteWebAuthnCredential(ctx context.Context, id string) error {
+	return errLegacyNotFound
+}
+
+func (l legacyStorage) CreateLoginLink(ctx context.Context, link LoginLink) error {
+	return errLegacyUnsupported
+}
+
+func (l legacyStorage) ConsumeLoginLink(ctx context.Context, id string) (LoginLink, error) {
+	return LoginLink{}, errLegacyNotFound
+}
+
+func (l legacyStorage) CreateTOTPSecret(ctx context.Context, t TOTPSecret) error {
+	return errLegacyUnsupported
+}
+
+func (l legacyStorage) GetTOTPSecret(ctx context.Context, userID string, connID string) (TOTPSecret, error) {
+	return TOTPSecret{}, errLegacyNotFound
+}
+
+func (l legacyStorage) UpdateTOTPSecret(ctx context.Context, userID string, connID string, updater func(t TOTPSecret) (TOTPSecret, error)) error {
+	return errLegacyNotFound
+}
+
+func (l legacyStorage) DeleteTOTPSecret(ctx context.Context, userID string, connID string) error {
+	return errLegacyNotFound
+}
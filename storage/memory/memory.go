@@ -2,6 +2,8 @@
 package memory
 
 import (
+	"context"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -19,6 +21,16 @@ func New(logger log.Logger) storage.Storage {
 		authReqs:        make(map[string]storage.AuthRequest),
 		passwords:       make(map[string]storage.Password),
 		offlineSessions: make(map[offlineSessionID]storage.OfflineSessions),
+		termsAcceptance: make(map[offlineSessionID]storage.TermsAcceptance),
+		auditEvents:     make(map[string]storage.AuditEvent),
+		apiKeys:         make(map[string]storage.APIKey),
+		serviceAccounts: make(map[string]storage.ServiceAccount),
+		revokedTokens:   make(map[string]storage.RevokedToken),
+		sessions:        make(map[string]storage.Session),
+		preAuthCodes:    make(map[string]storage.PreAuthorizedCode),
+		webAuthnCreds:   make(map[string]storage.WebAuthnCredential),
+		loginLinks:      make(map[string]storage.LoginLink),
+		totpSecrets:     make(map[offlineSessionID]storage.TOTPSecret),
 		connectors:      make(map[string]storage.Connector),
 		logger:          logger,
 	}
@@ -45,6 +57,16 @@ type memStorage struct {
 	authReqs        map[string]storage.AuthRequest
 	passwords       map[string]storage.Password
 	offlineSessions map[offlineSessionID]storage.OfflineSessions
+	termsAcceptance map[offlineSessionID]storage.TermsAcceptance
+	auditEvents     map[string]storage.AuditEvent
+	apiKeys         map[string]storage.APIKey
+	serviceAccounts map[string]storage.ServiceAccount
+	revokedTokens   map[string]storage.RevokedToken
+	sessions        map[string]storage.Session
+	preAuthCodes    map[string]storage.PreAuthorizedCode
+	webAuthnCreds   map[string]storage.WebAuthnCredential
+	loginLinks      map[string]storage.LoginLink
+	totpSecrets     map[offlineSessionID]storage.TOTPSecret
 	connectors      map[string]storage.Connector
 
 	keys storage.Keys
@@ -65,7 +87,7 @@ func (s *memStorage) tx(f func()) {
 
 func (s *memStorage) Close() error { return nil }
 
-func (s *memStorage) GarbageCollect(now time.Time) (result storage.GCResult, err error) {
+func (s *memStorage) GarbageCollect(ctx context.Context, now time.Time) (result storage.GCResult, err error) {
 	s.tx(func() {
 		for id, a := range s.authCodes {
 			if now.After(a.Expiry) {
@@ -79,11 +101,35 @@ func (s *memStorage) GarbageCollect(now time.Time) (result storage.GCResult, err
 				result.AuthRequests++
 			}
 		}
+		for id, t := range s.revokedTokens {
+			if now.After(t.Expiry) {
+				delete(s.revokedTokens, id)
+				result.RevokedTokens++
+			}
+		}
+		for id, session := range s.sessions {
+			if now.After(session.Expiry) {
+				delete(s.sessions, id)
+				result.Sessions++
+			}
+		}
+		for id, c := range s.preAuthCodes {
+			if now.After(c.Expiry) {
+				delete(s.preAuthCodes, id)
+				result.PreAuthorizedCodes++
+			}
+		}
+		for id, l := range s.loginLinks {
+			if now.After(l.Expiry) {
+				delete(s.loginLinks, id)
+				result.LoginLinks++
+			}
+		}
 	})
 	return result, nil
 }
 
-func (s *memStorage) CreateClient(c storage.Client) (err error) {
+func (s *memStorage) CreateClient(ctx context.Context, c storage.Client) (err error) {
 	s.tx(func() {
 		if _, ok := s.clients[c.ID]; ok {
 			err = storage.ErrAlreadyExists
@@ -94,7 +140,7 @@ func (s *memStorage) CreateClient(c storage.Client) (err error) {
 	return
 }
 
-func (s *memStorage) CreateAuthCode(c storage.AuthCode) (err error) {
+func (s *memStorage) CreateAuthCode(ctx context.Context, c storage.AuthCode) (err error) {
 	s.tx(func() {
 		if _, ok := s.authCodes[c.ID]; ok {
 			err = storage.ErrAlreadyExists
@@ -105,7 +151,7 @@ func (s *memStorage) CreateAuthCode(c storage.AuthCode) (err error) {
 	return
 }
 
-func (s *memStorage) CreateRefresh(r storage.RefreshToken) (err error) {
+func (s *memStorage) CreateRefresh(ctx context.Context, r storage.RefreshToken) (err error) {
 	s.tx(func() {
 		if _, ok := s.refreshTokens[r.ID]; ok {
 			err = storage.ErrAlreadyExists
@@ -116,7 +162,7 @@ func (s *memStorage) CreateRefresh(r storage.RefreshToken) (err error) {
 	return
 }
 
-func (s *memStorage) CreateAuthRequest(a storage.AuthRequest) (err error) {
+func (s *memStorage) CreateAuthRequest(ctx context.Context, a storage.AuthRequest) (err error) {
 	s.tx(func() {
 		if _, ok := s.authReqs[a.ID]; ok {
 			err = storage.ErrAlreadyExists
@@ -127,7 +173,7 @@ func (s *memStorage) CreateAuthRequest(a storage.AuthRequest) (err error) {
 	return
 }
 
-func (s *memStorage) CreatePassword(p storage.Password) (err error) {
+func (s *memStorage) CreatePassword(ctx context.Context, p storage.Password) (err error) {
 	lowerEmail := strings.ToLower(p.Email)
 	s.tx(func() {
 		if _, ok := s.passwords[lowerEmail]; ok {
@@ -139,7 +185,7 @@ func (s *memStorage) CreatePassword(p storage.Password) (err error) {
 	return
 }
 
-func (s *memStorage) CreateOfflineSessions(o storage.OfflineSessions) (err error) {
+func (s *memStorage) CreateOfflineSessions(ctx context.Context, o storage.OfflineSessions) (err error) {
 	id := offlineSessionID{
 		userID: o.UserID,
 		connID: o.ConnID,
@@ -154,7 +200,7 @@ func (s *memStorage) CreateOfflineSessions(o storage.OfflineSessions) (err error
 	return
 }
 
-func (s *memStorage) CreateConnector(connector storage.Connector) (err error) {
+func (s *memStorage) CreateConnector(ctx context.Context, connector storage.Connector) (err error) {
 	s.tx(func() {
 		if _, ok := s.connectors[connector.ID]; ok {
 			err = storage.ErrAlreadyExists
@@ -165,7 +211,7 @@ func (s *memStorage) CreateConnector(connector storage.Connector) (err error) {
 	return
 }
 
-func (s *memStorage) GetAuthCode(id string) (c storage.AuthCode, err error) {
+func (s *memStorage) GetAuthCode(ctx context.Context, id string) (c storage.AuthCode, err error) {
 	s.tx(func() {
 		var ok bool
 		if c, ok = s.authCodes[id]; !ok {
@@ -176,7 +222,7 @@ func (s *memStorage) GetAuthCode(id string) (c storage.AuthCode, err error) {
 	return
 }
 
-func (s *memStorage) GetPassword(email string) (p storage.Password, err error) {
+func (s *memStorage) GetPassword(ctx context.Context, email string) (p storage.Password, err error) {
 	email = strings.ToLower(email)
 	s.tx(func() {
 		var ok bool
@@ -187,7 +233,7 @@ func (s *memStorage) GetPassword(email string) (p storage.Password, err error) {
 	return
 }
 
-func (s *memStorage) GetClient(id string) (client storage.Client, err error) {
+func (s *memStorage) GetClient(ctx context.Context, id string) (client storage.Client, err error) {
 	s.tx(func() {
 		var ok bool
 		if client, ok = s.clients[id]; !ok {
@@ -197,12 +243,12 @@ func (s *memStorage) GetClient(id string) (client storage.Client, err error) {
 	return
 }
 
-func (s *memStorage) GetKeys() (keys storage.Keys, err error) {
+func (s *memStorage) GetKeys(ctx context.Context) (keys storage.Keys, err error) {
 	s.tx(func() { keys = s.keys })
 	return
 }
 
-func (s *memStorage) GetRefresh(id string) (tok storage.RefreshToken, err error) {
+func (s *memStorage) GetRefresh(ctx context.Context, id string) (tok storage.RefreshToken, err error) {
 	s.tx(func() {
 		var ok bool
 		if tok, ok = s.refreshTokens[id]; !ok {
@@ -213,7 +259,7 @@ func (s *memStorage) GetRefresh(id string) (tok storage.RefreshToken, err error)
 	return
 }
 
-func (s *memStorage) GetAuthRequest(id string) (req storage.AuthRequest, err error) {
+func (s *memStorage) GetAuthRequest(ctx context.Context, id string) (req storage.AuthRequest, err error) {
 	s.tx(func() {
 		var ok bool
 		if req, ok = s.authReqs[id]; !ok {
@@ -224,7 +270,7 @@ func (s *memStorage) GetAuthRequest(id string) (req storage.AuthRequest, err err
 	return
 }
 
-func (s *memStorage) GetOfflineSessions(userID string, connID string) (o storage.OfflineSessions, err error) {
+func (s *memStorage) GetOfflineSessions(ctx context.Context, userID string, connID string) (o storage.OfflineSessions, err error) {
 	id := offlineSessionID{
 		userID: userID,
 		connID: connID,
@@ -239,7 +285,7 @@ func (s *memStorage) GetOfflineSessions(userID string, connID string) (o storage
 	return
 }
 
-func (s *memStorage) GetConnector(id string) (connector storage.Connector, err error) {
+func (s *memStorage) GetConnector(ctx context.Context, id string) (connector storage.Connector, err error) {
 	s.tx(func() {
 		var ok bool
 		if connector, ok = s.connectors[id]; !ok {
@@ -249,7 +295,7 @@ func (s *memStorage) GetConnector(id string) (connector storage.Connector, err e
 	return
 }
 
-func (s *memStorage) ListClients() (clients []storage.Client, err error) {
+func (s *memStorage) ListClients(ctx context.Context) (clients []storage.Client, err error) {
 	s.tx(func() {
 		for _, client := range s.clients {
 			clients = append(clients, client)
@@ -258,7 +304,7 @@ func (s *memStorage) ListClients() (clients []storage.Client, err error) {
 	return
 }
 
-func (s *memStorage) ListRefreshTokens() (tokens []storage.RefreshToken, err error) {
+func (s *memStorage) ListRefreshTokens(ctx context.Context) (tokens []storage.RefreshToken, err error) {
 	s.tx(func() {
 		for _, refresh := range s.refreshTokens {
 			tokens = append(tokens, refresh)
@@ -267,7 +313,7 @@ func (s *memStorage) ListRefreshTokens() (tokens []storage.RefreshToken, err err
 	return
 }
 
-func (s *memStorage) ListPasswords() (passwords []storage.Password, err error) {
+func (s *memStorage) ListPasswords(ctx context.Context) (passwords []storage.Password, err error) {
 	s.tx(func() {
 		for _, password := range s.passwords {
 			passwords = append(passwords, password)
@@ -276,7 +322,7 @@ func (s *memStorage) ListPasswords() (passwords []storage.Password, err error) {
 	return
 }
 
-func (s *memStorage) ListConnectors() (conns []storage.Connector, err error) {
+func (s *memStorage) ListConnectors(ctx context.Context) (conns []storage.Connector, err error) {
 	s.tx(func() {
 		for _, c := range s.connectors {
 			conns = append(conns, c)
@@ -285,7 +331,7 @@ func (s *memStorage) ListConnectors() (conns []storage.Connector, err error) {
 	return
 }
 
-func (s *memStorage) DeletePassword(email string) (err error) {
+func (s *memStorage) DeletePassword(ctx context.Context, email string) (err error) {
 	email = strings.ToLower(email)
 	s.tx(func() {
 		if _, ok := s.passwords[email]; !ok {
@@ -297,7 +343,7 @@ func (s *memStorage) DeletePassword(email string) (err error) {
 	return
 }
 
-func (s *memStorage) DeleteClient(id string) (err error) {
+func (s *memStorage) DeleteClient(ctx context.Context, id string) (err error) {
 	s.tx(func() {
 		if _, ok := s.clients[id]; !ok {
 			err = storage.ErrNotFound
@@ -308,7 +354,7 @@ func (s *memStorage) DeleteClient(id string) (err error) {
 	return
 }
 
-func (s *memStorage) DeleteRefresh(id string) (err error) {
+func (s *memStorage) DeleteRefresh(ctx context.Context, id string) (err error) {
 	s.tx(func() {
 		if _, ok := s.refreshTokens[id]; !ok {
 			err = storage.ErrNotFound
@@ -319,7 +365,7 @@ func (s *memStorage) DeleteRefresh(id string) (err error) {
 	return
 }
 
-func (s *memStorage) DeleteAuthCode(id string) (err error) {
+func (s *memStorage) DeleteAuthCode(ctx context.Context, id string) (err error) {
 	s.tx(func() {
 		if _, ok := s.authCodes[id]; !ok {
 			err = storage.ErrNotFound
@@ -330,7 +376,19 @@ func (s *memStorage) DeleteAuthCode(id string) (err error) {
 	return
 }
 
-func (s *memStorage) DeleteAuthRequest(id string) (err error) {
+func (s *memStorage) ConsumeAuthCode(ctx context.Context, id string) (c storage.AuthCode, err error) {
+	s.tx(func() {
+		var ok bool
+		if c, ok = s.authCodes[id]; !ok {
+			err = storage.ErrNotFound
+			return
+		}
+		delete(s.authCodes, id)
+	})
+	return
+}
+
+func (s *memStorage) DeleteAuthRequest(ctx context.Context, id string) (err error) {
 	s.tx(func() {
 		if _, ok := s.authReqs[id]; !ok {
 			err = storage.ErrNotFound
@@ -341,7 +399,7 @@ func (s *memStorage) DeleteAuthRequest(id string) (err error) {
 	return
 }
 
-func (s *memStorage) DeleteOfflineSessions(userID string, connID string) (err error) {
+func (s *memStorage) DeleteOfflineSessions(ctx context.Context, userID string, connID string) (err error) {
 	id := offlineSessionID{
 		userID: userID,
 		connID: connID,
@@ -356,7 +414,7 @@ func (s *memStorage) DeleteOfflineSessions(userID string, connID string) (err er
 	return
 }
 
-func (s *memStorage) DeleteConnector(id string) (err error) {
+func (s *memStorage) DeleteConnector(ctx context.Context, id string) (err error) {
 	s.tx(func() {
 		if _, ok := s.connectors[id]; !ok {
 			err = storage.ErrNotFound
@@ -367,7 +425,7 @@ func (s *memStorage) DeleteConnector(id string) (err error) {
 	return
 }
 
-func (s *memStorage) UpdateClient(id string, updater func(old storage.Client) (storage.Client, error)) (err error) {
+func (s *memStorage) UpdateClient(ctx context.Context, id string, updater func(old storage.Client) (storage.Client, error)) (err error) {
 	s.tx(func() {
 		client, ok := s.clients[id]
 		if !ok {
@@ -381,7 +439,7 @@ func (s *memStorage) UpdateClient(id string, updater func(old storage.Client) (s
 	return
 }
 
-func (s *memStorage) UpdateKeys(updater func(old storage.Keys) (storage.Keys, error)) (err error) {
+func (s *memStorage) UpdateKeys(ctx context.Context, updater func(old storage.Keys) (storage.Keys, error)) (err error) {
 	s.tx(func() {
 		var keys storage.Keys
 		if keys, err = updater(s.keys); err == nil {
@@ -391,7 +449,7 @@ func (s *memStorage) UpdateKeys(updater func(old storage.Keys) (storage.Keys, er
 	return
 }
 
-func (s *memStorage) UpdateAuthRequest(id string, updater func(old storage.AuthRequest) (storage.AuthRequest, error)) (err error) {
+func (s *memStorage) UpdateAuthRequest(ctx context.Context, id string, updater func(old storage.AuthRequest) (storage.AuthRequest, error)) (err error) {
 	s.tx(func() {
 		req, ok := s.authReqs[id]
 		if !ok {
@@ -405,7 +463,7 @@ func (s *memStorage) UpdateAuthRequest(id string, updater func(old storage.AuthR
 	return
 }
 
-func (s *memStorage) UpdatePassword(email string, updater func(p storage.Password) (storage.Password, error)) (err error) {
+func (s *memStorage) UpdatePassword(ctx context.Context, email string, updater func(p storage.Password) (storage.Password, error)) (err error) {
 	email = strings.ToLower(email)
 	s.tx(func() {
 		req, ok := s.passwords[email]
@@ -420,7 +478,7 @@ func (s *memStorage) UpdatePassword(email string, updater func(p storage.Passwor
 	return
 }
 
-func (s *memStorage) UpdateRefreshToken(id string, updater func(p storage.RefreshToken) (storage.RefreshToken, error)) (err error) {
+func (s *memStorage) UpdateRefreshToken(ctx context.Context, id string, updater func(p storage.RefreshToken) (storage.RefreshToken, error)) (err error) {
 	s.tx(func() {
 		r, ok := s.refreshTokens[id]
 		if !ok {
@@ -434,7 +492,7 @@ func (s *memStorage) UpdateRefreshToken(id string, updater func(p storage.Refres
 	return
 }
 
-func (s *memStorage) UpdateOfflineSessions(userID string, connID string, updater func(o storage.OfflineSessions) (storage.OfflineSessions, error)) (err error) {
+func (s *memStorage) UpdateOfflineSessions(ctx context.Context, userID string, connID string, updater func(o storage.OfflineSessions) (storage.OfflineSessions, error)) (err error) {
 	id := offlineSessionID{
 		userID: userID,
 		connID: connID,
@@ -452,7 +510,7 @@ func (s *memStorage) UpdateOfflineSessions(userID string, connID string, updater
 	return
 }
 
-func (s *memStorage) UpdateConnector(id string, updater func(c storage.Connector) (storage.Connector, error)) (err error) {
+func (s *memStorage) UpdateConnector(ctx context.Context, id string, updater func(c storage.Connector) (storage.Connector, error)) (err error) {
 	s.tx(func() {
 		r, ok := s.connectors[id]
 		if !ok {
@@ -465,3 +523,454 @@ func (s *memStorage) UpdateConnector(id string, updater func(c storage.Connector
 	})
 	return
 }
+
+func (s *memStorage) CreateTermsAcceptance(ctx context.Context, a storage.TermsAcceptance) (err error) {
+	id := offlineSessionID{
+		userID: a.UserID,
+		connID: a.ConnID,
+	}
+	s.tx(func() {
+		if _, ok := s.termsAcceptance[id]; ok {
+			err = storage.ErrAlreadyExists
+		} else {
+			s.termsAcceptance[id] = a
+		}
+	})
+	return
+}
+
+func (s *memStorage) GetTermsAcceptance(ctx context.Context, userID string, connID string) (a storage.TermsAcceptance, err error) {
+	id := offlineSessionID{
+		userID: userID,
+		connID: connID,
+	}
+	s.tx(func() {
+		var ok bool
+		if a, ok = s.termsAcceptance[id]; !ok {
+			err = storage.ErrNotFound
+		}
+	})
+	return
+}
+
+func (s *memStorage) DeleteTermsAcceptance(ctx context.Context, userID string, connID string) (err error) {
+	id := offlineSessionID{
+		userID: userID,
+		connID: connID,
+	}
+	s.tx(func() {
+		if _, ok := s.termsAcceptance[id]; !ok {
+			err = storage.ErrNotFound
+			return
+		}
+		delete(s.termsAcceptance, id)
+	})
+	return
+}
+
+func (s *memStorage) UpdateTermsAcceptance(ctx context.Context, userID string, connID string, updater func(a storage.TermsAcceptance) (storage.TermsAcceptance, error)) (err error) {
+	id := offlineSessionID{
+		userID: userID,
+		connID: connID,
+	}
+	s.tx(func() {
+		a, ok := s.termsAcceptance[id]
+		if !ok {
+			err = storage.ErrNotFound
+			return
+		}
+		if a, err = updater(a); err == nil {
+			s.termsAcceptance[id] = a
+		}
+	})
+	return
+}
+
+func (s *memStorage) CreateAuditEvent(ctx context.Context, e storage.AuditEvent) (err error) {
+	s.tx(func() {
+		if _, ok := s.auditEvents[e.ID]; ok {
+			err = storage.ErrAlreadyExists
+		} else {
+			s.auditEvents[e.ID] = e
+		}
+	})
+	return
+}
+
+func (s *memStorage) ListAuditEvents(ctx context.Context, filter storage.AuditEventFilter) (events []storage.AuditEvent, err error) {
+	s.tx(func() {
+		for _, e := range s.auditEvents {
+			if filter.Matches(e) {
+				events = append(events, e)
+			}
+		}
+	})
+	sort.Slice(events, func(i, j int) bool { return events[i].Time.After(events[j].Time) })
+	if filter.Limit > 0 && len(events) > filter.Limit {
+		events = events[:filter.Limit]
+	}
+	return events, nil
+}
+
+func (s *memStorage) UpdateAuditEvent(ctx context.Context, id string, updater func(e storage.AuditEvent) (storage.AuditEvent, error)) (err error) {
+	s.tx(func() {
+		e, ok := s.auditEvents[id]
+		if !ok {
+			err = storage.ErrNotFound
+			return
+		}
+		if e, err = updater(e); err == nil {
+			s.auditEvents[id] = e
+		}
+	})
+	return
+}
+
+func (s *memStorage) PruneAuditEvents(ctx context.Context, before time.Time) (n int64, err error) {
+	s.tx(func() {
+		for id, e := range s.auditEvents {
+			if e.Time.Before(before) {
+				delete(s.auditEvents, id)
+				n++
+			}
+		}
+	})
+	return n, nil
+}
+
+func (s *memStorage) CreateAPIKey(ctx context.Context, k storage.APIKey) (err error) {
+	s.tx(func() {
+		if _, ok := s.apiKeys[k.ID]; ok {
+			err = storage.ErrAlreadyExists
+		} else {
+			s.apiKeys[k.ID] = k
+		}
+	})
+	return
+}
+
+func (s *memStorage) GetAPIKey(ctx context.Context, id string) (k storage.APIKey, err error) {
+	s.tx(func() {
+		var ok bool
+		if k, ok = s.apiKeys[id]; !ok {
+			err = storage.ErrNotFound
+		}
+	})
+	return
+}
+
+func (s *memStorage) ListAPIKeys(ctx context.Context) (keys []storage.APIKey, err error) {
+	s.tx(func() {
+		for _, k := range s.apiKeys {
+			keys = append(keys, k)
+		}
+	})
+	return
+}
+
+func (s *memStorage) DeleteAPIKey(ctx context.Context, id string) (err error) {
+	s.tx(func() {
+		if _, ok := s.apiKeys[id]; !ok {
+			err = storage.ErrNotFound
+			return
+		}
+		delete(s.apiKeys, id)
+	})
+	return
+}
+
+func (s *memStorage) CreateServiceAccount(ctx context.Context, a storage.ServiceAccount) (err error) {
+	s.tx(func() {
+		if _, ok := s.serviceAccounts[a.ID]; ok {
+			err = storage.ErrAlreadyExists
+		} else {
+			s.serviceAccounts[a.ID] = a
+		}
+	})
+	return
+}
+
+func (s *memStorage) GetServiceAccount(ctx context.Context, id string) (a storage.ServiceAccount, err error) {
+	s.tx(func() {
+		var ok bool
+		if a, ok = s.serviceAccounts[id]; !ok {
+			err = storage.ErrNotFound
+		}
+	})
+	return
+}
+
+func (s *memStorage) ListServiceAccounts(ctx context.Context) (accounts []storage.ServiceAccount, err error) {
+	s.tx(func() {
+		for _, a := range s.serviceAccounts {
+			accounts = append(accounts, a)
+		}
+	})
+	return
+}
+
+func (s *memStorage) UpdateServiceAccount(ctx context.Context, id string, updater func(a storage.ServiceAccount) (storage.ServiceAccount, error)) (err error) {
+	s.tx(func() {
+		a, ok := s.serviceAccounts[id]
+		if !ok {
+			err = storage.ErrNotFound
+			return
+		}
+		if a, err = updater(a); err == nil {
+			s.serviceAccounts[id] = a
+		}
+	})
+	return
+}
+
+func (s *memStorage) DeleteServiceAccount(ctx context.Context, id string) (err error) {
+	s.tx(func() {
+		if _, ok := s.serviceAccounts[id]; !ok {
+			err = storage.ErrNotFound
+			return
+		}
+		delete(s.serviceAccounts, id)
+	})
+	return
+}
+
+func (s *memStorage) CreateRevokedToken(ctx context.Context, t storage.RevokedToken) (err error) {
+	s.tx(func() {
+		if _, ok := s.revokedTokens[t.ID]; ok {
+			err = storage.ErrAlreadyExists
+		} else {
+			s.revokedTokens[t.ID] = t
+		}
+	})
+	return
+}
+
+func (s *memStorage) GetRevokedToken(ctx context.Context, id string) (t storage.RevokedToken, err error) {
+	s.tx(func() {
+		var ok bool
+		if t, ok = s.revokedTokens[id]; !ok {
+			err = storage.ErrNotFound
+		}
+	})
+	return
+}
+
+func (s *memStorage) CreateSession(ctx context.Context, session storage.Session) (err error) {
+	s.tx(func() {
+		if _, ok := s.sessions[session.ID]; ok {
+			err = storage.ErrAlreadyExists
+		} else {
+			s.sessions[session.ID] = session
+		}
+	})
+	return
+}
+
+func (s *memStorage) GetSession(ctx context.Context, id string) (session storage.Session, err error) {
+	s.tx(func() {
+		var ok bool
+		if session, ok = s.sessions[id]; !ok {
+			err = storage.ErrNotFound
+		}
+	})
+	return
+}
+
+func (s *memStorage) ListSessions(ctx context.Context) (sessions []storage.Session, err error) {
+	s.tx(func() {
+		for _, session := range s.sessions {
+			sessions = append(sessions, session)
+		}
+	})
+	return
+}
+
+func (s *memStorage) UpdateSession(ctx context.Context, id string, updater func(s storage.Session) (storage.Session, error)) (err error) {
+	s.tx(func() {
+		session, ok := s.sessions[id]
+		if !ok {
+			err = storage.ErrNotFound
+			return
+		}
+		if session, err = updater(session); err == nil {
+			s.sessions[id] = session
+		}
+	})
+	return
+}
+
+func (s *memStorage) DeleteSession(ctx context.Context, id string) (err error) {
+	s.tx(func() {
+		if _, ok := s.sessions[id]; !ok {
+			err = storage.ErrNotFound
+			return
+		}
+		delete(s.sessions, id)
+	})
+	return
+}
+
+func (s *memStorage) CreatePreAuthorizedCode(ctx context.Context, c storage.PreAuthorizedCode) (err error) {
+	s.tx(func() {
+		if _, ok := s.preAuthCodes[c.ID]; ok {
+			err = storage.ErrAlreadyExists
+		} else {
+			s.preAuthCodes[c.ID] = c
+		}
+	})
+	return
+}
+
+func (s *memStorage) ConsumePreAuthorizedCode(ctx context.Context, id string) (c storage.PreAuthorizedCode, err error) {
+	s.tx(func() {
+		var ok bool
+		if c, ok = s.preAuthCodes[id]; !ok {
+			err = storage.ErrNotFound
+			return
+		}
+		delete(s.preAuthCodes, id)
+	})
+	return
+}
+
+func (s *memStorage) CreateWebAuthnCredential(ctx context.Context, c storage.WebAuthnCredential) (err error) {
+	s.tx(func() {
+		if _, ok := s.webAuthnCreds[c.ID]; ok {
+			err = storage.ErrAlreadyExists
+		} else {
+			s.webAuthnCreds[c.ID] = c
+		}
+	})
+	return
+}
+
+func (s *memStorage) GetWebAuthnCredential(ctx context.Context, id string) (c storage.WebAuthnCredential, err error) {
+	s.tx(func() {
+		var ok bool
+		if c, ok = s.webAuthnCreds[id]; !ok {
+			err = storage.ErrNotFound
+		}
+	})
+	return
+}
+
+func (s *memStorage) ListWebAuthnCredentials(ctx context.Context, userID string, connID string) (creds []storage.WebAuthnCredential, err error) {
+	s.tx(func() {
+		for _, c := range s.webAuthnCreds {
+			if c.UserID == userID && c.ConnectorID == connID {
+				creds = append(creds, c)
+			}
+		}
+	})
+	return
+}
+
+func (s *memStorage) UpdateWebAuthnCredential(ctx context.Context, id string, updater func(c storage.WebAuthnCredential) (storage.WebAuthnCredential, error)) (err error) {
+	s.tx(func() {
+		c, ok := s.webAuthnCreds[id]
+		if !ok {
+			err = storage.ErrNotFound
+			return
+		}
+		if c, err = updater(c); err == nil {
+			s.webAuthnCreds[id] = c
+		}
+	})
+	return
+}
+
+func (s *memStorage) DeleteWebAuthnCredential(ctx context.Context, id string) (err error) {
+	s.tx(func() {
+		if _, ok := s.webAuthnCreds[id]; !ok {
+			err = storage.ErrNotFound
+			return
+		}
+		delete(s.webAuthnCreds, id)
+	})
+	return
+}
+
+func (s *memStorage) CreateTOTPSecret(ctx context.Context, t storage.TOTPSecret) (err error) {
+	id := offlineSessionID{
+		userID: t.UserID,
+		connID: t.ConnID,
+	}
+	s.tx(func() {
+		if _, ok := s.totpSecrets[id]; ok {
+			err = storage.ErrAlreadyExists
+		} else {
+			s.totpSecrets[id] = t
+		}
+	})
+	return
+}
+
+func (s *memStorage) GetTOTPSecret(ctx context.Context, userID string, connID string) (t storage.TOTPSecret, err error) {
+	id := offlineSessionID{
+		userID: userID,
+		connID: connID,
+	}
+	s.tx(func() {
+		var ok bool
+		if t, ok = s.totpSecrets[id]; !ok {
+			err = storage.ErrNotFound
+		}
+	})
+	return
+}
+
+func (s *memStorage) DeleteTOTPSecret(ctx context.Context, userID string, connID string) (err error) {
+	id := offlineSessionID{
+		userID: userID,
+		connID: connID,
+	}
+	s.tx(func() {
+		if _, ok := s.totpSecrets[id]; !ok {
+			err = storage.ErrNotFound
+			return
+		}
+		delete(s.totpSecrets, id)
+	})
+	return
+}
+
+func (s *memStorage) UpdateTOTPSecret(ctx context.Context, userID string, connID string, updater func(t storage.TOTPSecret) (storage.TOTPSecret, error)) (err error) {
+	id := offlineSessionID{
+		userID: userID,
+		connID: connID,
+	}
+	s.tx(func() {
+		t, ok := s.totpSecrets[id]
+		if !ok {
+			err = storage.ErrNotFound
+			return
+		}
+		if t, err = updater(t); err == nil {
+			s.totpSecrets[id] = t
+		}
+	})
+	return
+}
+
+func (s *memStorage) CreateLoginLink(ctx context.Context, l storage.LoginLink) (err error) {
+	s.tx(func() {
+		if _, ok := s.loginLinks[l.ID]; ok {
+			err = storage.ErrAlreadyExists
+		} else {
+			s.loginLinks[l.ID] = l
+		}
+	})
+	return
+}
+
+func (s *memStorage) ConsumeLoginLink(ctx context.Context, id string) (l storage.LoginLink, err error) {
+	s.tx(func() {
+		var ok bool
+		if l, ok = s.loginLinks[id]; !ok {
+			err = storage.ErrNotFound
+			return
+		}
+		delete(s.loginLinks, id)
+	})
+	return
+}
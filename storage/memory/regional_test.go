@@ -0,0 +1,103 @@
+package memory
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/dexidp/dex/storage"
+)
+
+func TestRegions(t *testing.T) {
+	ctx := context.Background()
+	logger := &logrus.Logger{
+		Out:       os.Stderr,
+		Formatter: &logrus.TextFormatter{DisableColors: true},
+		Level:     logrus.DebugLevel,
+	}
+
+	// The local replica lags behind the primary: it has an old version of
+	// the client and refresh token, and misses the connector and passkey.
+	newStorages := func() (primary, local storage.Storage) {
+		primary, local = New(logger), New(logger)
+		primary.CreateClient(ctx, storage.Client{ID: "client", Name: "new"})
+		local.CreateClient(ctx, storage.Client{ID: "client", Name: "old"})
+		primary.CreateRefresh(ctx, storage.RefreshToken{ID: "refresh", Token: "new"})
+		local.CreateRefresh(ctx, storage.RefreshToken{ID: "refresh", Token: "old"})
+		primary.CreateConnector(ctx, storage.Connector{ID: "connector", Name: "new"})
+		primary.CreateWebAuthnCredential(ctx, storage.WebAuthnCredential{ID: "passkey", UserID: "jane", ConnectorID: "local"})
+		return primary, local
+	}
+
+	tests := []struct {
+		consistency storage.Consistency
+		wantClient  string
+		wantRefresh string
+	}{
+		{storage.ConsistencyStrong, "new", "new"},
+		{storage.ConsistencyBounded, "old", "new"},
+		{storage.ConsistencyLocal, "old", "old"},
+	}
+	for _, tc := range tests {
+		primary, local := newStorages()
+		s := storage.WithRegions(primary, local, tc.consistency, logger)
+
+		c, err := s.GetClient(ctx, "client")
+		if err != nil {
+			t.Fatalf("%s: get client: %v", tc.consistency, err)
+		}
+		if c.Name != tc.wantClient {
+			t.Errorf("%s: expected %s client, got %s", tc.consistency, tc.wantClient, c.Name)
+		}
+		r, err := s.GetRefresh(ctx, "refresh")
+		if err != nil {
+			t.Fatalf("%s: get refresh token: %v", tc.consistency, err)
+		}
+		if r.Token != tc.wantRefresh {
+			t.Errorf("%s: expected %s refresh token, got %s", tc.consistency, tc.wantRefresh, r.Token)
+		}
+		if _, err := s.GetConnector(ctx, "connector"); err != nil {
+			t.Errorf("%s: expected connector missing from the replica to be read from the primary: %v", tc.consistency, err)
+		}
+		// Passkeys decide whether grants need a second factor, so they're
+		// always listed from the primary.
+		if creds, err := s.ListWebAuthnCredentials(ctx, "jane", "local"); err != nil || len(creds) != 1 {
+			t.Errorf("%s: expected passkey missing from the replica to be listed from the primary, got %+v, %v", tc.consistency, creds, err)
+		}
+
+		if err := s.CreatePassword(ctx, storage.Password{Email: "jane@example.com", UserID: "jane"}); err != nil {
+			t.Fatalf("%s: create password: %v", tc.consistency, err)
+		}
+		if _, err := primary.GetPassword(ctx, "jane@example.com"); err != nil {
+			t.Errorf("%s: expected password to be written to the primary: %v", tc.consistency, err)
+		}
+		if _, err := local.GetPassword(ctx, "jane@example.com"); err != storage.ErrNotFound {
+			t.Errorf("%s: expected password not to be written to the replica, got %v", tc.consistency, err)
+		}
+
+		if err := s.CreateAuditEvent(ctx, storage.AuditEvent{ID: "event", Time: time.Now()}); err != nil {
+			t.Fatalf("%s: create audit event: %v", tc.consistency, err)
+		}
+		if err := s.Close(); err != nil {
+			t.Fatalf("%s: close: %v", tc.consistency, err)
+		}
+		events, err := primary.ListAuditEvents(ctx, storage.AuditEventFilter{})
+		if err != nil {
+			t.Fatalf("%s: list audit events: %v", tc.consistency, err)
+		}
+		if len(events) != 1 {
+			t.Errorf("%s: expected queued audit event to be written to the primary on close, got %d events", tc.consistency, len(events))
+		}
+		if err := s.CreateAuditEvent(ctx, storage.AuditEvent{ID: "late", Time: time.Now()}); err == nil {
+			t.Errorf("%s: expected audit events to be refused once closed", tc.consistency)
+		}
+	}
+
+	primary := New(logger)
+	if s := storage.WithRegions(primary, nil, storage.ConsistencyLocal, logger); s != primary {
+		t.Errorf("expected the primary to be returned unchanged without a local replica")
+	}
+}
@@ -0,0 +1,278 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dexidp/dex/pkg/log"
+)
+
+// Tests for this code are in the "memory" package, since this package doesn't
+// define a concrete storage implementation.
+
+// Consistency chooses which reads a region serves from its local replica
+// rather than the primary storage, which takes all writes.
+type Consistency string
+
+const (
+	// ConsistencyStrong reads everything from the primary. Every region
+	// sees every write as soon as it happened.
+	ConsistencyStrong Consistency = "strong"
+
+	// ConsistencyBounded reads clients, connectors and listings from the
+	// local replica, and everything else from the primary. Objects that are
+	// consumed, rotated or revoked during logins and token requests are
+	// always current, but a client or connector that was changed or deleted
+	// is served as it was for up to the replication lag.
+	ConsistencyBounded Consistency = "bounded"
+
+	// ConsistencyLocal reads everything but signing keys from the local
+	// replica, falling back to the primary for objects it doesn't have yet,
+	// so new objects and revocations are seen right away. Deleted objects,
+	// such as refresh tokens, API keys and sessions, remain usable in other
+	// regions for up to the replication lag. Writes that depend on the
+	// current state, such as consuming auth codes and rotating refresh
+	// tokens, still happen on the primary and can't be replayed.
+	ConsistencyLocal Consistency = "local"
+)
+
+// ParseConsistency parses a consistency mode, defaulting to strong.
+func ParseConsistency(s string) (Consistency, error) {
+	switch c := Consistency(s); c {
+	case "":
+		return ConsistencyStrong, nil
+	case ConsistencyStrong, ConsistencyBounded, ConsistencyLocal:
+		return c, nil
+	}
+	return "", fmt.Errorf("unknown consistency %q, expected %q, %q or %q", s, ConsistencyStrong, ConsistencyBounded, ConsistencyLocal)
+}
+
+const (
+	// auditQueueSize bounds the audit events waiting to be written to a
+	// remote primary. Beyond it events are written synchronously.
+	auditQueueSize = 1000
+
+	auditWriteTimeout = 10 * time.Second
+)
+
+// regionalStorage routes reads to the replica of the local region, and all
+// writes to the primary storage in another region.
+type regionalStorage struct {
+	// The primary storage.
+	Storage
+
+	local       Storage
+	consistency Consistency
+	logger      log.Logger
+
+	// Audit events to write to the primary in the background. mu guards
+	// sending to audit against closing it.
+	mu     sync.RWMutex
+	closed bool
+	audit  chan AuditEvent
+	done   chan struct{}
+}
+
+// WithRegions routes the reads of a region to its local replica according to
+// the consistency mode. All writes go to the primary, and signing keys are
+// always read from it, so tokens signed with a newly rotated key verify with
+// the keys every region publishes. A user's passkeys and TOTP secret are
+// always read from it too, since they decide whether a grant needs a second
+// factor, and one missing from the replica would let the grant skip it. Since
+// writing to the primary takes a cross-region round trip, audit events,
+// which nothing reads back when issuing tokens, are written in the
+// background.
+//
+// A nil local replica means the primary is local, and returns it unchanged.
+// Closing the returned storage closes both storages.
+func WithRegions(primary, local Storage, consistency Consistency, logger log.Logger) Storage {
+	if local == nil {
+		return primary
+	}
+	s := &regionalStorage{
+		Storage:     primary,
+		local:       local,
+		consistency: consistency,
+		logger:      logger,
+		audit:       make(chan AuditEvent, auditQueueSize),
+		done:        make(chan struct{}),
+	}
+	go s.writeAuditEvents()
+	return s
+}
+
+func (s *regionalStorage) writeAuditEvents() {
+	defer close(s.done)
+	for e := range s.audit {
+		ctx, cancel := context.WithTimeout(context.Background(), auditWriteTimeout)
+		if err := s.Storage.CreateAuditEvent(ctx, e); err != nil {
+			s.logger.Errorf("failed to write audit event %s to the primary storage: %v", e.ID, err)
+		}
+		cancel()
+	}
+}
+
+// CreateAuditEvent queues the event to be written to the primary. If the
+// queue is full it's written right away, rather than dropped. Once the
+// storage is closed, events can't be written anymore.
+func (s *regionalStorage) CreateAuditEvent(ctx context.Context, e AuditEvent) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return errRegionalClosed
+	}
+	select {
+	case s.audit <- e:
+		return nil
+	default:
+		return s.Storage.CreateAuditEvent(ctx, e)
+	}
+}
+
+var errRegionalClosed = errors.New("storage is closed")
+
+// Close writes the queued audit events and closes both storages.
+func (s *regionalStorage) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return errRegionalClosed
+	}
+	s.closed = true
+	close(s.audit)
+	s.mu.Unlock()
+	<-s.done
+	err := s.Storage.Close()
+	if lerr := s.local.Close(); err == nil {
+		err = lerr
+	}
+	return err
+}
+
+// read reads from the local replica if allowed, falling back to the
+// primary if that fails, for example because an object wasn't replicated
+// yet or the replica is unavailable.
+func (s *regionalStorage) read(local bool, read func(st Storage) error) error {
+	if local {
+		if err := read(s.local); err == nil {
+			return nil
+		}
+	}
+	return read(s.Storage)
+}
+
+func (s *regionalStorage) readsConfig() bool {
+	return s.consistency == ConsistencyBounded || s.consistency == ConsistencyLocal
+}
+
+func (s *regionalStorage) readsAll() bool {
+	return s.consistency == ConsistencyLocal
+}
+
+func (s *regionalStorage) GetClient(ctx context.Context, id string) (c Client, err error) {
+	err = s.read(s.readsConfig(), func(st Storage) (err error) { c, err = st.GetClient(ctx, id); return err })
+	return c, err
+}
+
+func (s *regionalStorage) ListClients(ctx context.Context) (c []Client, err error) {
+	err = s.read(s.readsConfig(), func(st Storage) (err error) { c, err = st.ListClients(ctx); return err })
+	return c, err
+}
+
+func (s *regionalStorage) GetConnector(ctx context.Context, id string) (c Connector, err error) {
+	err = s.read(s.readsConfig(), func(st Storage) (err error) { c, err = st.GetConnector(ctx, id); return err })
+	return c, err
+}
+
+func (s *regionalStorage) ListConnectors(ctx context.Context) (c []Connector, err error) {
+	err = s.read(s.readsConfig(), func(st Storage) (err error) { c, err = st.ListConnectors(ctx); return err })
+	return c, err
+}
+
+func (s *regionalStorage) ListRefreshTokens(ctx context.Context) (r []RefreshToken, err error) {
+	err = s.read(s.readsConfig(), func(st Storage) (err error) { r, err = st.ListRefreshTokens(ctx); return err })
+	return r, err
+}
+
+func (s *regionalStorage) ListPasswords(ctx context.Context) (p []Password, err error) {
+	err = s.read(s.readsConfig(), func(st Storage) (err error) { p, err = st.ListPasswords(ctx); return err })
+	return p, err
+}
+
+func (s *regionalStorage) ListAuditEvents(ctx context.Context, filter AuditEventFilter) (e []AuditEvent, err error) {
+	err = s.read(s.readsConfig(), func(st Storage) (err error) { e, err = st.ListAuditEvents(ctx, filter); return err })
+	return e, err
+}
+
+func (s *regionalStorage) ListAPIKeys(ctx context.Context) (k []APIKey, err error) {
+	err = s.read(s.readsConfig(), func(st Storage) (err error) { k, err = st.ListAPIKeys(ctx); return err })
+	return k, err
+}
+
+func (s *regionalStorage) ListServiceAccounts(ctx context.Context) (a []ServiceAccount, err error) {
+	err = s.read(s.readsConfig(), func(st Storage) (err error) { a, err = st.ListServiceAccounts(ctx); return err })
+	return a, err
+}
+
+func (s *regionalStorage) ListSessions(ctx context.Context) (l []Session, err error) {
+	err = s.read(s.readsConfig(), func(st Storage) (err error) { l, err = st.ListSessions(ctx); return err })
+	return l, err
+}
+
+func (s *regionalStorage) GetAuthRequest(ctx context.Context, id string) (a AuthRequest, err error) {
+	err = s.read(s.readsAll(), func(st Storage) (err error) { a, err = st.GetAuthRequest(ctx, id); return err })
+	return a, err
+}
+
+func (s *regionalStorage) GetAuthCode(ctx context.Context, id string) (c AuthCode, err error) {
+	err = s.read(s.readsAll(), func(st Storage) (err error) { c, err = st.GetAuthCode(ctx, id); return err })
+	return c, err
+}
+
+func (s *regionalStorage) GetRefresh(ctx context.Context, id string) (r RefreshToken, err error) {
+	err = s.read(s.readsAll(), func(st Storage) (err error) { r, err = st.GetRefresh(ctx, id); return err })
+	return r, err
+}
+
+func (s *regionalStorage) GetPassword(ctx context.Context, email string) (p Password, err error) {
+	err = s.read(s.readsAll(), func(st Storage) (err error) { p, err = st.GetPassword(ctx, email); return err })
+	return p, err
+}
+
+func (s *regionalStorage) GetOfflineSessions(ctx context.Context, userID, connID string) (o OfflineSessions, err error) {
+	err = s.read(s.readsAll(), func(st Storage) (err error) { o, err = st.GetOfflineSessions(ctx, userID, connID); return err })
+	return o, err
+}
+
+func (s *regionalStorage) GetTermsAcceptance(ctx context.Context, userID, connID string) (a TermsAcceptance, err error) {
+	err = s.read(s.readsAll(), func(st Storage) (err error) { a, err = st.GetTermsAcceptance(ctx, userID, connID); return err })
+	return a, err
+}
+
+func (s *regionalStorage) GetAPIKey(ctx context.Context, id string) (k APIKey, err error) {
+	err = s.read(s.readsAll(), func(st Storage) (err error) { k, err = st.GetAPIKey(ctx, id); return err })
+	return k, err
+}
+
+func (s *regionalStorage) GetServiceAccount(ctx context.Context, id string) (a ServiceAccount, err error) {
+	err = s.read(s.readsAll(), func(st Storage) (err error) { a, err = st.GetServiceAccount(ctx, id); return err })
+	return a, err
+}
+
+func (s *regionalStorage) GetRevokedToken(ctx context.Context, id string) (t RevokedToken, err error) {
+	err = s.read(s.readsAll(), func(st Storage) (err error) { t, err = st.GetRevokedToken(ctx, id); return err })
+	return t, err
+}
+
+func (s *regionalStorage) GetSession(ctx context.Context, id string) (l Session, err error) {
+	err = s.read(s.readsAll(), func(st Storage) (err error) { l, err = st.GetSession(ctx, id); return err })
+	return l, err
+}
+
+func (s *regionalStorage) GetWebAuthnCredential(ctx context.Context, id string) (c WebAuthnCredential, err error) {
+	err = s.read(s.readsAll(), func(st Storage) (err error) { c, err = st.GetWebAuthnCredential(ctx, id); return err })
+	return c, err
+}
@@ -68,7 +68,7 @@ func (s *SQLite3) open(logger log.Logger) (*conn, error) {
 
 	c := &conn{db, &flavorSQLite3, logger, errCheck}
 	if _, err := c.migrate(); err != nil {
-		return nil, fmt.Errorf("failed to perform migrations: %v", err)
+		return nil, fmt.Errorf("failed to perform migrations: %w", err)
 	}
 	return c, nil
 }
@@ -241,7 +241,7 @@ func (p *Postgres) open(logger log.Logger) (*conn, error) {
 
 	c := &conn{db, &flavorPostgres, logger, errCheck}
 	if _, err := c.migrate(); err != nil {
-		return nil, fmt.Errorf("failed to perform migrations: %v", err)
+		return nil, fmt.Errorf("failed to perform migrations: %w", err)
 	}
 	return c, nil
 }
@@ -291,7 +291,7 @@ func (s *MySQL) open(logger log.Logger) (*conn, error) {
 	}
 	if s.SSL.CAFile != "" || s.SSL.CertFile != "" || s.SSL.KeyFile != "" {
 		if err := s.makeTLSConfig(); err != nil {
-			return nil, fmt.Errorf("failed to make TLS config: %v", err)
+			return nil, fmt.Errorf("failed to make TLS config: %w", err)
 		}
 		cfg.TLSConfig = mysqlSSLCustom
 	} else if s.SSL.Mode == "" {
@@ -346,7 +346,7 @@ func (s *MySQL) open(logger log.Logger) (*conn, error) {
 
 	c := &conn{db, &flavorMySQL, logger, errCheck}
 	if _, err := c.migrate(); err != nil {
-		return nil, fmt.Errorf("failed to perform migrations: %v", err)
+		return nil, fmt.Errorf("failed to perform migrations: %w", err)
 	}
 	return c, nil
 }
@@ -1,6 +1,7 @@
 package sql
 
 import (
+	"context"
 	"database/sql"
 	"database/sql/driver"
 	"encoding/json"
@@ -51,7 +52,7 @@ type jsonEncoder struct {
 func (j jsonEncoder) Value() (driver.Value, error) {
 	b, err := json.Marshal(j.i)
 	if err != nil {
-		return nil, fmt.Errorf("marshal: %v", err)
+		return nil, fmt.Errorf("marshal: %w", err)
 	}
 	return b, nil
 }
@@ -69,14 +70,14 @@ func (j jsonDecoder) Scan(dest interface{}) error {
 		return fmt.Errorf("expected []byte got %T", dest)
 	}
 	if err := json.Unmarshal(b, &j.i); err != nil {
-		return fmt.Errorf("unmarshal: %v", err)
+		return fmt.Errorf("unmarshal: %w", err)
 	}
 	return nil
 }
 
 // Abstract conn vs trans.
 type querier interface {
-	QueryRow(query string, args ...interface{}) *sql.Row
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
 }
 
 // Abstract row vs rows.
@@ -84,58 +85,94 @@ type scanner interface {
 	Scan(dest ...interface{}) error
 }
 
-func (c *conn) GarbageCollect(now time.Time) (result storage.GCResult, err error) {
-	r, err := c.Exec(`delete from auth_request where expiry < $1`, now)
+func (c *conn) GarbageCollect(ctx context.Context, now time.Time) (result storage.GCResult, err error) {
+	r, err := c.ExecContext(ctx, `delete from auth_request where expiry < $1`, now)
 	if err != nil {
-		return result, fmt.Errorf("gc auth_request: %v", err)
+		return result, fmt.Errorf("gc auth_request: %w", err)
 	}
 	if n, err := r.RowsAffected(); err == nil {
 		result.AuthRequests = n
 	}
 
-	r, err = c.Exec(`delete from auth_code where expiry < $1`, now)
+	r, err = c.ExecContext(ctx, `delete from auth_code where expiry < $1`, now)
 	if err != nil {
-		return result, fmt.Errorf("gc auth_code: %v", err)
+		return result, fmt.Errorf("gc auth_code: %w", err)
 	}
 	if n, err := r.RowsAffected(); err == nil {
 		result.AuthCodes = n
 	}
+
+	r, err = c.ExecContext(ctx, `delete from revoked_token where expiry < $1`, now)
+	if err != nil {
+		return result, fmt.Errorf("gc revoked_token: %w", err)
+	}
+	if n, err := r.RowsAffected(); err == nil {
+		result.RevokedTokens = n
+	}
+
+	r, err = c.ExecContext(ctx, `delete from session where expiry < $1`, now)
+	if err != nil {
+		return result, fmt.Errorf("gc session: %w", err)
+	}
+	if n, err := r.RowsAffected(); err == nil {
+		result.Sessions = n
+	}
+
+	r, err = c.ExecContext(ctx, `delete from pre_authorized_code where expiry < $1`, now)
+	if err != nil {
+		return result, fmt.Errorf("gc pre_authorized_code: %w", err)
+	}
+	if n, err := r.RowsAffected(); err == nil {
+		result.PreAuthorizedCodes = n
+	}
+
+	r, err = c.ExecContext(ctx, `delete from login_link where expiry < $1`, now)
+	if err != nil {
+		return result, fmt.Errorf("gc login_link: %w", err)
+	}
+	if n, err := r.RowsAffected(); err == nil {
+		result.LoginLinks = n
+	}
 	return
 }
 
-func (c *conn) CreateAuthRequest(a storage.AuthRequest) error {
-	_, err := c.Exec(`
+func (c *conn) CreateAuthRequest(ctx context.Context, a storage.AuthRequest) error {
+	_, err := c.ExecContext(ctx, `
 		insert into auth_request (
 			id, client_id, response_types, scopes, redirect_uri, nonce, state,
 			force_approval_prompt, logged_in,
 			claims_user_id, claims_username, claims_preferred_username,
 			claims_email, claims_email_verified, claims_groups,
-			connector_id, connector_data,
-			expiry
+			connector_id, connector_data, fallback_from,
+			expiry,
+			code_challenge, code_challenge_method,
+			second_factor, second_factor_challenge
 		)
 		values (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23
 		);
 	`,
 		a.ID, a.ClientID, encoder(a.ResponseTypes), encoder(a.Scopes), a.RedirectURI, a.Nonce, a.State,
 		a.ForceApprovalPrompt, a.LoggedIn,
 		a.Claims.UserID, a.Claims.Username, a.Claims.PreferredUsername,
 		a.Claims.Email, a.Claims.EmailVerified, encoder(a.Claims.Groups),
-		a.ConnectorID, a.ConnectorData,
+		a.ConnectorID, a.ConnectorData, a.FallbackFrom,
 		a.Expiry,
+		a.PKCE.CodeChallenge, a.PKCE.CodeChallengeMethod,
+		a.SecondFactor, a.SecondFactorChallenge,
 	)
 	if err != nil {
 		if c.alreadyExistsCheck(err) {
 			return storage.ErrAlreadyExists
 		}
-		return fmt.Errorf("insert auth request: %v", err)
+		return fmt.Errorf("insert auth request: %w", err)
 	}
 	return nil
 }
 
-func (c *conn) UpdateAuthRequest(id string, updater func(a storage.AuthRequest) (storage.AuthRequest, error)) error {
-	return c.ExecTx(func(tx *trans) error {
-		r, err := getAuthRequest(tx, id)
+func (c *conn) UpdateAuthRequest(ctx context.Context, id string, updater func(a storage.AuthRequest) (storage.AuthRequest, error)) error {
+	return c.ExecTx(ctx, func(tx *trans) error {
+		r, err := getAuthRequest(ctx, tx, id)
 		if err != nil {
 			return err
 		}
@@ -144,7 +181,7 @@ func (c *conn) UpdateAuthRequest(id string, updater func(a storage.AuthRequest)
 		if err != nil {
 			return err
 		}
-		_, err = tx.Exec(`
+		_, err = tx.ExecContext(ctx, `
 			update auth_request
 			set
 				client_id = $1, response_types = $2, scopes = $3, redirect_uri = $4,
@@ -152,37 +189,44 @@ func (c *conn) UpdateAuthRequest(id string, updater func(a storage.AuthRequest)
 				claims_user_id = $9, claims_username = $10, claims_preferred_username = $11,
 				claims_email = $12, claims_email_verified = $13,
 				claims_groups = $14,
-				connector_id = $15, connector_data = $16,
-				expiry = $17
-			where id = $18;
+				connector_id = $15, connector_data = $16, fallback_from = $17,
+				expiry = $18,
+				code_challenge = $19, code_challenge_method = $20,
+				second_factor = $21, second_factor_challenge = $22
+			where id = $23;
 		`,
 			a.ClientID, encoder(a.ResponseTypes), encoder(a.Scopes), a.RedirectURI, a.Nonce, a.State,
 			a.ForceApprovalPrompt, a.LoggedIn,
 			a.Claims.UserID, a.Claims.Username, a.Claims.PreferredUsername,
 			a.Claims.Email, a.Claims.EmailVerified,
 			encoder(a.Claims.Groups),
-			a.ConnectorID, a.ConnectorData,
-			a.Expiry, r.ID,
+			a.ConnectorID, a.ConnectorData, a.FallbackFrom,
+			a.Expiry,
+			a.PKCE.CodeChallenge, a.PKCE.CodeChallengeMethod,
+			a.SecondFactor, a.SecondFactorChallenge,
+			r.ID,
 		)
 		if err != nil {
-			return fmt.Errorf("update auth request: %v", err)
+			return fmt.Errorf("update auth request: %w", err)
 		}
 		return nil
 	})
 }
 
-func (c *conn) GetAuthRequest(id string) (storage.AuthRequest, error) {
-	return getAuthRequest(c, id)
+func (c *conn) GetAuthRequest(ctx context.Context, id string) (storage.AuthRequest, error) {
+	return getAuthRequest(ctx, c, id)
 }
 
-func getAuthRequest(q querier, id string) (a storage.AuthRequest, err error) {
-	err = q.QueryRow(`
+func getAuthRequest(ctx context.Context, q querier, id string) (a storage.AuthRequest, err error) {
+	err = q.QueryRowContext(ctx, `
 		select
 			id, client_id, response_types, scopes, redirect_uri, nonce, state,
 			force_approval_prompt, logged_in,
 			claims_user_id, claims_username, claims_preferred_username,
 			claims_email, claims_email_verified, claims_groups,
-			connector_id, connector_data, expiry
+			connector_id, connector_data, fallback_from, expiry,
+			code_challenge, code_challenge_method,
+			second_factor, second_factor_challenge
 		from auth_request where id = $1;
 	`, id).Scan(
 		&a.ID, &a.ClientID, decoder(&a.ResponseTypes), decoder(&a.Scopes), &a.RedirectURI, &a.Nonce, &a.State,
@@ -190,102 +234,133 @@ func getAuthRequest(q querier, id string) (a storage.AuthRequest, err error) {
 		&a.Claims.UserID, &a.Claims.Username, &a.Claims.PreferredUsername,
 		&a.Claims.Email, &a.Claims.EmailVerified,
 		decoder(&a.Claims.Groups),
-		&a.ConnectorID, &a.ConnectorData, &a.Expiry,
+		&a.ConnectorID, &a.ConnectorData, &a.FallbackFrom, &a.Expiry,
+		&a.PKCE.CodeChallenge, &a.PKCE.CodeChallengeMethod,
+		&a.SecondFactor, &a.SecondFactorChallenge,
 	)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, sql.ErrNoRows) {
 			return a, storage.ErrNotFound
 		}
-		return a, fmt.Errorf("select auth request: %v", err)
+		return a, fmt.Errorf("select auth request: %w", err)
 	}
 	return a, nil
 }
 
-func (c *conn) CreateAuthCode(a storage.AuthCode) error {
-	_, err := c.Exec(`
+func (c *conn) CreateAuthCode(ctx context.Context, a storage.AuthCode) error {
+	_, err := c.ExecContext(ctx, `
 		insert into auth_code (
 			id, client_id, scopes, nonce, redirect_uri,
 			claims_user_id, claims_username, claims_preferred_username,
 			claims_email, claims_email_verified, claims_groups,
 			connector_id, connector_data,
-			expiry
+			expiry,
+			code_challenge, code_challenge_method
 		)
-		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14);
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16);
 	`,
 		a.ID, a.ClientID, encoder(a.Scopes), a.Nonce, a.RedirectURI, a.Claims.UserID,
 		a.Claims.Username, a.Claims.PreferredUsername, a.Claims.Email, a.Claims.EmailVerified,
 		encoder(a.Claims.Groups), a.ConnectorID, a.ConnectorData, a.Expiry,
+		a.PKCE.CodeChallenge, a.PKCE.CodeChallengeMethod,
 	)
 
 	if err != nil {
 		if c.alreadyExistsCheck(err) {
 			return storage.ErrAlreadyExists
 		}
-		return fmt.Errorf("insert auth code: %v", err)
+		return fmt.Errorf("insert auth code: %w", err)
 	}
 	return nil
 }
 
-func (c *conn) GetAuthCode(id string) (a storage.AuthCode, err error) {
-	err = c.QueryRow(`
+func (c *conn) GetAuthCode(ctx context.Context, id string) (storage.AuthCode, error) {
+	return getAuthCode(ctx, c, id)
+}
+
+func getAuthCode(ctx context.Context, q querier, id string) (storage.AuthCode, error) {
+	return scanAuthCode(q.QueryRowContext(ctx, `
 		select
 			id, client_id, scopes, nonce, redirect_uri,
 			claims_user_id, claims_username, claims_preferred_username,
 			claims_email, claims_email_verified, claims_groups,
 			connector_id, connector_data,
-			expiry
+			expiry,
+			code_challenge, code_challenge_method
 		from auth_code where id = $1;
-	`, id).Scan(
+	`, id))
+}
+
+func (c *conn) ConsumeAuthCode(ctx context.Context, id string) (a storage.AuthCode, err error) {
+	err = c.consume(ctx, "auth_code", `
+		id, client_id, scopes, nonce, redirect_uri,
+		claims_user_id, claims_username, claims_preferred_username,
+		claims_email, claims_email_verified, claims_groups,
+		connector_id, connector_data,
+		expiry,
+		code_challenge, code_challenge_method
+	`, id, func(s scanner) (err error) {
+		a, err = scanAuthCode(s)
+		return err
+	})
+	return a, err
+}
+
+func scanAuthCode(s scanner) (a storage.AuthCode, err error) {
+	err = s.Scan(
 		&a.ID, &a.ClientID, decoder(&a.Scopes), &a.Nonce, &a.RedirectURI, &a.Claims.UserID,
 		&a.Claims.Username, &a.Claims.PreferredUsername, &a.Claims.Email, &a.Claims.EmailVerified,
 		decoder(&a.Claims.Groups), &a.ConnectorID, &a.ConnectorData, &a.Expiry,
+		&a.PKCE.CodeChallenge, &a.PKCE.CodeChallengeMethod,
 	)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, sql.ErrNoRows) {
 			return a, storage.ErrNotFound
 		}
-		return a, fmt.Errorf("select auth code: %v", err)
+		return a, fmt.Errorf("scan auth code: %w", err)
 	}
 	return a, nil
 }
 
-func (c *conn) CreateRefresh(r storage.RefreshToken) error {
-	_, err := c.Exec(`
+func (c *conn) CreateRefresh(ctx context.Context, r storage.RefreshToken) error {
+	_, err := c.ExecContext(ctx, `
 		insert into refresh_token (
 			id, client_id, scopes, nonce,
 			claims_user_id, claims_username, claims_preferred_username,
 			claims_email, claims_email_verified, claims_groups,
 			connector_id, connector_data,
-			token, created_at, last_used
+			token, created_at, last_used, last_used_ip,
+			device_fingerprint
 		)
-		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15);
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17);
 	`,
 		r.ID, r.ClientID, encoder(r.Scopes), r.Nonce,
 		r.Claims.UserID, r.Claims.Username, r.Claims.PreferredUsername,
 		r.Claims.Email, r.Claims.EmailVerified,
 		encoder(r.Claims.Groups),
 		r.ConnectorID, r.ConnectorData,
-		r.Token, r.CreatedAt, r.LastUsed,
+		r.Token, r.CreatedAt, r.LastUsed, r.LastUsedIP,
+		r.DeviceFingerprint,
 	)
 	if err != nil {
 		if c.alreadyExistsCheck(err) {
 			return storage.ErrAlreadyExists
 		}
-		return fmt.Errorf("insert refresh_token: %v", err)
+		return fmt.Errorf("insert refresh_token: %w", err)
 	}
 	return nil
 }
 
-func (c *conn) UpdateRefreshToken(id string, updater func(old storage.RefreshToken) (storage.RefreshToken, error)) error {
-	return c.ExecTx(func(tx *trans) error {
-		r, err := getRefresh(tx, id)
+func (c *conn) UpdateRefreshToken(ctx context.Context, id string, updater func(old storage.RefreshToken) (storage.RefreshToken, error)) error {
+	return c.ExecTx(ctx, func(tx *trans) error {
+		r, err := getRefresh(ctx, tx, id)
 		if err != nil {
 			return err
 		}
 		if r, err = updater(r); err != nil {
 			return err
 		}
-		_, err = tx.Exec(`
+		_, err = tx.ExecContext(ctx, `
 			update refresh_token
 			set
 				client_id = $1,
@@ -301,53 +376,58 @@ func (c *conn) UpdateRefreshToken(id string, updater func(old storage.RefreshTok
 								connector_data = $11,
 				token = $12,
 				created_at = $13,
-				last_used = $14
+				last_used = $14,
+				last_used_ip = $15,
+				device_fingerprint = $16
 			where
-				id = $15
+				id = $17
 		`,
 			r.ClientID, encoder(r.Scopes), r.Nonce,
 			r.Claims.UserID, r.Claims.Username, r.Claims.PreferredUsername,
 			r.Claims.Email, r.Claims.EmailVerified,
 			encoder(r.Claims.Groups),
 			r.ConnectorID, r.ConnectorData,
-			r.Token, r.CreatedAt, r.LastUsed, id,
+			r.Token, r.CreatedAt, r.LastUsed, r.LastUsedIP,
+			r.DeviceFingerprint, id,
 		)
 		if err != nil {
-			return fmt.Errorf("update refresh token: %v", err)
+			return fmt.Errorf("update refresh token: %w", err)
 		}
 		return nil
 	})
 }
 
-func (c *conn) GetRefresh(id string) (storage.RefreshToken, error) {
-	return getRefresh(c, id)
+func (c *conn) GetRefresh(ctx context.Context, id string) (storage.RefreshToken, error) {
+	return getRefresh(ctx, c, id)
 }
 
-func getRefresh(q querier, id string) (storage.RefreshToken, error) {
-	return scanRefresh(q.QueryRow(`
+func getRefresh(ctx context.Context, q querier, id string) (storage.RefreshToken, error) {
+	return scanRefresh(q.QueryRowContext(ctx, `
 		select
 			id, client_id, scopes, nonce,
 			claims_user_id, claims_username, claims_preferred_username,
 			claims_email, claims_email_verified,
 			claims_groups,
 			connector_id, connector_data,
-			token, created_at, last_used
+			token, created_at, last_used, last_used_ip,
+			device_fingerprint
 		from refresh_token where id = $1;
 	`, id))
 }
 
-func (c *conn) ListRefreshTokens() ([]storage.RefreshToken, error) {
-	rows, err := c.Query(`
+func (c *conn) ListRefreshTokens(ctx context.Context) ([]storage.RefreshToken, error) {
+	rows, err := c.QueryContext(ctx, `
 		select
 			id, client_id, scopes, nonce,
 			claims_user_id, claims_username, claims_preferred_username,
 			claims_email, claims_email_verified, claims_groups,
 			connector_id, connector_data,
-			token, created_at, last_used
+			token, created_at, last_used, last_used_ip,
+			device_fingerprint
 		from refresh_token;
 	`)
 	if err != nil {
-		return nil, fmt.Errorf("query: %v", err)
+		return nil, fmt.Errorf("query: %w", err)
 	}
 	var tokens []storage.RefreshToken
 	for rows.Next() {
@@ -358,7 +438,7 @@ func (c *conn) ListRefreshTokens() ([]storage.RefreshToken, error) {
 		tokens = append(tokens, r)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("scan: %v", err)
+		return nil, fmt.Errorf("scan: %w", err)
 	}
 	return tokens, nil
 }
@@ -370,26 +450,27 @@ func scanRefresh(s scanner) (r storage.RefreshToken, err error) {
 		&r.Claims.Email, &r.Claims.EmailVerified,
 		decoder(&r.Claims.Groups),
 		&r.ConnectorID, &r.ConnectorData,
-		&r.Token, &r.CreatedAt, &r.LastUsed,
+		&r.Token, &r.CreatedAt, &r.LastUsed, &r.LastUsedIP,
+		&r.DeviceFingerprint,
 	)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, sql.ErrNoRows) {
 			return r, storage.ErrNotFound
 		}
-		return r, fmt.Errorf("scan refresh_token: %v", err)
+		return r, fmt.Errorf("scan refresh_token: %w", err)
 	}
 	return r, nil
 }
 
-func (c *conn) UpdateKeys(updater func(old storage.Keys) (storage.Keys, error)) error {
-	return c.ExecTx(func(tx *trans) error {
+func (c *conn) UpdateKeys(ctx context.Context, updater func(old storage.Keys) (storage.Keys, error)) error {
+	return c.ExecTx(ctx, func(tx *trans) error {
 		firstUpdate := false
 		// TODO(ericchiang): errors may cause a transaction be rolled back by the SQL
 		// server. Test this, and consider adding a COUNT() command beforehand.
-		old, err := getKeys(tx)
+		old, err := getKeys(ctx, tx)
 		if err != nil {
-			if err != storage.ErrNotFound {
-				return fmt.Errorf("get keys: %v", err)
+			if !errors.Is(err, storage.ErrNotFound) {
+				return fmt.Errorf("get keys: %w", err)
 			}
 			firstUpdate = true
 			old = storage.Keys{}
@@ -401,7 +482,7 @@ func (c *conn) UpdateKeys(updater func(old storage.Keys) (storage.Keys, error))
 		}
 
 		if firstUpdate {
-			_, err = tx.Exec(`
+			_, err = tx.ExecContext(ctx, `
 				insert into keys (
 					id, verification_keys, signing_key, signing_key_pub, next_rotation
 				)
@@ -411,10 +492,10 @@ func (c *conn) UpdateKeys(updater func(old storage.Keys) (storage.Keys, error))
 				encoder(nk.SigningKeyPub), nk.NextRotation,
 			)
 			if err != nil {
-				return fmt.Errorf("insert: %v", err)
+				return fmt.Errorf("insert: %w", err)
 			}
 		} else {
-			_, err = tx.Exec(`
+			_, err = tx.ExecContext(ctx, `
 				update keys
 				set
 				    verification_keys = $1,
@@ -427,19 +508,19 @@ func (c *conn) UpdateKeys(updater func(old storage.Keys) (storage.Keys, error))
 				encoder(nk.SigningKeyPub), nk.NextRotation, keysRowID,
 			)
 			if err != nil {
-				return fmt.Errorf("update: %v", err)
+				return fmt.Errorf("update: %w", err)
 			}
 		}
 		return nil
 	})
 }
 
-func (c *conn) GetKeys() (keys storage.Keys, err error) {
-	return getKeys(c)
+func (c *conn) GetKeys(ctx context.Context) (keys storage.Keys, err error) {
+	return getKeys(ctx, c)
 }
 
-func getKeys(q querier) (keys storage.Keys, err error) {
-	err = q.QueryRow(`
+func getKeys(ctx context.Context, q querier) (keys storage.Keys, err error) {
+	err = q.QueryRowContext(ctx, `
 		select
 			verification_keys, signing_key, signing_key_pub, next_rotation
 		from keys
@@ -449,17 +530,17 @@ func getKeys(q querier) (keys storage.Keys, err error) {
 		decoder(&keys.SigningKeyPub), &keys.NextRotation,
 	)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, sql.ErrNoRows) {
 			return keys, storage.ErrNotFound
 		}
-		return keys, fmt.Errorf("query keys: %v", err)
+		return keys, fmt.Errorf("query keys: %w", err)
 	}
 	return keys, nil
 }
 
-func (c *conn) UpdateClient(id string, updater func(old storage.Client) (storage.Client, error)) error {
-	return c.ExecTx(func(tx *trans) error {
-		cli, err := getClient(tx, id)
+func (c *conn) UpdateClient(ctx context.Context, id string, updater func(old storage.Client) (storage.Client, error)) error {
+	return c.ExecTx(ctx, func(tx *trans) error {
+		cli, err := getClient(ctx, tx, id)
 		if err != nil {
 			return err
 		}
@@ -468,7 +549,7 @@ func (c *conn) UpdateClient(id string, updater func(old storage.Client) (storage
 			return err
 		}
 
-		_, err = tx.Exec(`
+		_, err = tx.ExecContext(ctx, `
 			update client
 			set
 				secret = $1,
@@ -476,52 +557,70 @@ func (c *conn) UpdateClient(id string, updater func(old storage.Client) (storage
 				trusted_peers = $3,
 				public = $4,
 				name = $5,
-				logo_url = $6
-			where id = $7;
-		`, nc.Secret, encoder(nc.RedirectURIs), encoder(nc.TrustedPeers), nc.Public, nc.Name, nc.LogoURL, id,
+				logo_url = $6,
+				allowed_cidrs = $7,
+				claims = $8,
+				tos_uri = $9,
+				policy_uri = $10,
+				contacts = $11,
+				application_type = $12,
+				expiry = $13,
+				backchannel_logout_uri = $14
+			where id = $15;
+		`, nc.Secret, encoder(nc.RedirectURIs), encoder(nc.TrustedPeers), nc.Public, nc.Name, nc.LogoURL,
+			encoder(nc.AllowedCIDRs), encoder(nc.Claims), nc.TOSURI, nc.PolicyURI, encoder(nc.Contacts),
+			nc.ApplicationType, nc.Expiry, nc.BackchannelLogoutURI, id,
 		)
 		if err != nil {
-			return fmt.Errorf("update client: %v", err)
+			return fmt.Errorf("update client: %w", err)
 		}
 		return nil
 	})
 }
 
-func (c *conn) CreateClient(cli storage.Client) error {
-	_, err := c.Exec(`
+func (c *conn) CreateClient(ctx context.Context, cli storage.Client) error {
+	_, err := c.ExecContext(ctx, `
 		insert into client (
-			id, secret, redirect_uris, trusted_peers, public, name, logo_url
+			id, secret, redirect_uris, trusted_peers, public, name, logo_url,
+			allowed_cidrs, claims, tos_uri, policy_uri, contacts, application_type,
+			expiry, backchannel_logout_uri
 		)
-		values ($1, $2, $3, $4, $5, $6, $7);
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15);
 	`,
 		cli.ID, cli.Secret, encoder(cli.RedirectURIs), encoder(cli.TrustedPeers),
-		cli.Public, cli.Name, cli.LogoURL,
+		cli.Public, cli.Name, cli.LogoURL, encoder(cli.AllowedCIDRs), encoder(cli.Claims),
+		cli.TOSURI, cli.PolicyURI, encoder(cli.Contacts), cli.ApplicationType,
+		cli.Expiry, cli.BackchannelLogoutURI,
 	)
 	if err != nil {
 		if c.alreadyExistsCheck(err) {
 			return storage.ErrAlreadyExists
 		}
-		return fmt.Errorf("insert client: %v", err)
+		return fmt.Errorf("insert client: %w", err)
 	}
 	return nil
 }
 
-func getClient(q querier, id string) (storage.Client, error) {
-	return scanClient(q.QueryRow(`
+func getClient(ctx context.Context, q querier, id string) (storage.Client, error) {
+	return scanClient(q.QueryRowContext(ctx, `
 		select
-			id, secret, redirect_uris, trusted_peers, public, name, logo_url
+			id, secret, redirect_uris, trusted_peers, public, name, logo_url,
+			allowed_cidrs, claims, tos_uri, policy_uri, contacts, application_type,
+			expiry, backchannel_logout_uri
 	    from client where id = $1;
 	`, id))
 }
 
-func (c *conn) GetClient(id string) (storage.Client, error) {
-	return getClient(c, id)
+func (c *conn) GetClient(ctx context.Context, id string) (storage.Client, error) {
+	return getClient(ctx, c, id)
 }
 
-func (c *conn) ListClients() ([]storage.Client, error) {
-	rows, err := c.Query(`
+func (c *conn) ListClients(ctx context.Context) ([]storage.Client, error) {
+	rows, err := c.QueryContext(ctx, `
 		select
-			id, secret, redirect_uris, trusted_peers, public, name, logo_url
+			id, secret, redirect_uris, trusted_peers, public, name, logo_url,
+			allowed_cidrs, claims, tos_uri, policy_uri, contacts, application_type,
+			expiry, backchannel_logout_uri
 		from client;
 	`)
 	if err != nil {
@@ -544,20 +643,22 @@ func (c *conn) ListClients() ([]storage.Client, error) {
 func scanClient(s scanner) (cli storage.Client, err error) {
 	err = s.Scan(
 		&cli.ID, &cli.Secret, decoder(&cli.RedirectURIs), decoder(&cli.TrustedPeers),
-		&cli.Public, &cli.Name, &cli.LogoURL,
+		&cli.Public, &cli.Name, &cli.LogoURL, decoder(&cli.AllowedCIDRs), decoder(&cli.Claims),
+		&cli.TOSURI, &cli.PolicyURI, decoder(&cli.Contacts), &cli.ApplicationType,
+		&cli.Expiry, &cli.BackchannelLogoutURI,
 	)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, sql.ErrNoRows) {
 			return cli, storage.ErrNotFound
 		}
-		return cli, fmt.Errorf("get client: %v", err)
+		return cli, fmt.Errorf("get client: %w", err)
 	}
 	return cli, nil
 }
 
-func (c *conn) CreatePassword(p storage.Password) error {
+func (c *conn) CreatePassword(ctx context.Context, p storage.Password) error {
 	p.Email = strings.ToLower(p.Email)
-	_, err := c.Exec(`
+	_, err := c.ExecContext(ctx, `
 		insert into password (
 			email, hash, username, user_id
 		)
@@ -571,14 +672,14 @@ func (c *conn) CreatePassword(p storage.Password) error {
 		if c.alreadyExistsCheck(err) {
 			return storage.ErrAlreadyExists
 		}
-		return fmt.Errorf("insert password: %v", err)
+		return fmt.Errorf("insert password: %w", err)
 	}
 	return nil
 }
 
-func (c *conn) UpdatePassword(email string, updater func(p storage.Password) (storage.Password, error)) error {
-	return c.ExecTx(func(tx *trans) error {
-		p, err := getPassword(tx, email)
+func (c *conn) UpdatePassword(ctx context.Context, email string, updater func(p storage.Password) (storage.Password, error)) error {
+	return c.ExecTx(ctx, func(tx *trans) error {
+		p, err := getPassword(ctx, tx, email)
 		if err != nil {
 			return err
 		}
@@ -587,7 +688,7 @@ func (c *conn) UpdatePassword(email string, updater func(p storage.Password) (st
 		if err != nil {
 			return err
 		}
-		_, err = tx.Exec(`
+		_, err = tx.ExecContext(ctx, `
 			update password
 			set
 				hash = $1, username = $2, user_id = $3
@@ -596,26 +697,26 @@ func (c *conn) UpdatePassword(email string, updater func(p storage.Password) (st
 			np.Hash, np.Username, np.UserID, p.Email,
 		)
 		if err != nil {
-			return fmt.Errorf("update password: %v", err)
+			return fmt.Errorf("update password: %w", err)
 		}
 		return nil
 	})
 }
 
-func (c *conn) GetPassword(email string) (storage.Password, error) {
-	return getPassword(c, email)
+func (c *conn) GetPassword(ctx context.Context, email string) (storage.Password, error) {
+	return getPassword(ctx, c, email)
 }
 
-func getPassword(q querier, email string) (p storage.Password, err error) {
-	return scanPassword(q.QueryRow(`
+func getPassword(ctx context.Context, q querier, email string) (p storage.Password, err error) {
+	return scanPassword(q.QueryRowContext(ctx, `
 		select
 			email, hash, username, user_id
 		from password where email = $1;
 	`, strings.ToLower(email)))
 }
 
-func (c *conn) ListPasswords() ([]storage.Password, error) {
-	rows, err := c.Query(`
+func (c *conn) ListPasswords(ctx context.Context) ([]storage.Password, error) {
+	rows, err := c.QueryContext(ctx, `
 		select
 			email, hash, username, user_id
 		from password;
@@ -643,16 +744,16 @@ func scanPassword(s scanner) (p storage.Password, err error) {
 		&p.Email, &p.Hash, &p.Username, &p.UserID,
 	)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, sql.ErrNoRows) {
 			return p, storage.ErrNotFound
 		}
-		return p, fmt.Errorf("select password: %v", err)
+		return p, fmt.Errorf("select password: %w", err)
 	}
 	return p, nil
 }
 
-func (c *conn) CreateOfflineSessions(s storage.OfflineSessions) error {
-	_, err := c.Exec(`
+func (c *conn) CreateOfflineSessions(ctx context.Context, s storage.OfflineSessions) error {
+	_, err := c.ExecContext(ctx, `
 		insert into offline_session (
 			user_id, conn_id, refresh, connector_data
 		)
@@ -666,14 +767,14 @@ func (c *conn) CreateOfflineSessions(s storage.OfflineSessions) error {
 		if c.alreadyExistsCheck(err) {
 			return storage.ErrAlreadyExists
 		}
-		return fmt.Errorf("insert offline session: %v", err)
+		return fmt.Errorf("insert offline session: %w", err)
 	}
 	return nil
 }
 
-func (c *conn) UpdateOfflineSessions(userID string, connID string, updater func(s storage.OfflineSessions) (storage.OfflineSessions, error)) error {
-	return c.ExecTx(func(tx *trans) error {
-		s, err := getOfflineSessions(tx, userID, connID)
+func (c *conn) UpdateOfflineSessions(ctx context.Context, userID string, connID string, updater func(s storage.OfflineSessions) (storage.OfflineSessions, error)) error {
+	return c.ExecTx(ctx, func(tx *trans) error {
+		s, err := getOfflineSessions(ctx, tx, userID, connID)
 		if err != nil {
 			return err
 		}
@@ -682,7 +783,7 @@ func (c *conn) UpdateOfflineSessions(userID string, connID string, updater func(
 		if err != nil {
 			return err
 		}
-		_, err = tx.Exec(`
+		_, err = tx.ExecContext(ctx, `
 			update offline_session
 			set
 				refresh = $1,
@@ -692,18 +793,18 @@ func (c *conn) UpdateOfflineSessions(userID string, connID string, updater func(
 			encoder(newSession.Refresh), newSession.ConnectorData, s.UserID, s.ConnID,
 		)
 		if err != nil {
-			return fmt.Errorf("update offline session: %v", err)
+			return fmt.Errorf("update offline session: %w", err)
 		}
 		return nil
 	})
 }
 
-func (c *conn) GetOfflineSessions(userID string, connID string) (storage.OfflineSessions, error) {
-	return getOfflineSessions(c, userID, connID)
+func (c *conn) GetOfflineSessions(ctx context.Context, userID string, connID string) (storage.OfflineSessions, error) {
+	return getOfflineSessions(ctx, c, userID, connID)
 }
 
-func getOfflineSessions(q querier, userID string, connID string) (storage.OfflineSessions, error) {
-	return scanOfflineSessions(q.QueryRow(`
+func getOfflineSessions(ctx context.Context, q querier, userID string, connID string) (storage.OfflineSessions, error) {
+	return scanOfflineSessions(q.QueryRowContext(ctx, `
 		select
 			user_id, conn_id, refresh, connector_data
 		from offline_session
@@ -716,16 +817,16 @@ func scanOfflineSessions(s scanner) (o storage.OfflineSessions, err error) {
 		&o.UserID, &o.ConnID, decoder(&o.Refresh), &o.ConnectorData,
 	)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, sql.ErrNoRows) {
 			return o, storage.ErrNotFound
 		}
-		return o, fmt.Errorf("select offline session: %v", err)
+		return o, fmt.Errorf("select offline session: %w", err)
 	}
 	return o, nil
 }
 
-func (c *conn) CreateConnector(connector storage.Connector) error {
-	_, err := c.Exec(`
+func (c *conn) CreateConnector(ctx context.Context, connector storage.Connector) error {
+	_, err := c.ExecContext(ctx, `
 		insert into connector (
 			id, type, name, resource_version, config
 		)
@@ -739,14 +840,14 @@ func (c *conn) CreateConnector(connector storage.Connector) error {
 		if c.alreadyExistsCheck(err) {
 			return storage.ErrAlreadyExists
 		}
-		return fmt.Errorf("insert connector: %v", err)
+		return fmt.Errorf("insert connector: %w", err)
 	}
 	return nil
 }
 
-func (c *conn) UpdateConnector(id string, updater func(s storage.Connector) (storage.Connector, error)) error {
-	return c.ExecTx(func(tx *trans) error {
-		connector, err := getConnector(tx, id)
+func (c *conn) UpdateConnector(ctx context.Context, id string, updater func(s storage.Connector) (storage.Connector, error)) error {
+	return c.ExecTx(ctx, func(tx *trans) error {
+		connector, err := getConnector(ctx, tx, id)
 		if err != nil {
 			return err
 		}
@@ -755,7 +856,7 @@ func (c *conn) UpdateConnector(id string, updater func(s storage.Connector) (sto
 		if err != nil {
 			return err
 		}
-		_, err = tx.Exec(`
+		_, err = tx.ExecContext(ctx, `
 			update connector
 			set
 			    type = $1,
@@ -767,18 +868,18 @@ func (c *conn) UpdateConnector(id string, updater func(s storage.Connector) (sto
 			newConn.Type, newConn.Name, newConn.ResourceVersion, newConn.Config, connector.ID,
 		)
 		if err != nil {
-			return fmt.Errorf("update connector: %v", err)
+			return fmt.Errorf("update connector: %w", err)
 		}
 		return nil
 	})
 }
 
-func (c *conn) GetConnector(id string) (storage.Connector, error) {
-	return getConnector(c, id)
+func (c *conn) GetConnector(ctx context.Context, id string) (storage.Connector, error) {
+	return getConnector(ctx, c, id)
 }
 
-func getConnector(q querier, id string) (storage.Connector, error) {
-	return scanConnector(q.QueryRow(`
+func getConnector(ctx context.Context, q querier, id string) (storage.Connector, error) {
+	return scanConnector(q.QueryRowContext(ctx, `
 		select
 			id, type, name, resource_version, config
 		from connector
@@ -791,16 +892,16 @@ func scanConnector(s scanner) (c storage.Connector, err error) {
 		&c.ID, &c.Type, &c.Name, &c.ResourceVersion, &c.Config,
 	)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, sql.ErrNoRows) {
 			return c, storage.ErrNotFound
 		}
-		return c, fmt.Errorf("select connector: %v", err)
+		return c, fmt.Errorf("select connector: %w", err)
 	}
 	return c, nil
 }
 
-func (c *conn) ListConnectors() ([]storage.Connector, error) {
-	rows, err := c.Query(`
+func (c *conn) ListConnectors(ctx context.Context) ([]storage.Connector, error) {
+	rows, err := c.QueryContext(ctx, `
 		select
 			id, type, name, resource_version, config
 		from connector;
@@ -822,17 +923,17 @@ func (c *conn) ListConnectors() ([]storage.Connector, error) {
 	return connectors, nil
 }
 
-func (c *conn) DeleteAuthRequest(id string) error { return c.delete("auth_request", "id", id) }
-func (c *conn) DeleteAuthCode(id string) error    { return c.delete("auth_code", "id", id) }
-func (c *conn) DeleteClient(id string) error      { return c.delete("client", "id", id) }
-func (c *conn) DeleteRefresh(id string) error     { return c.delete("refresh_token", "id", id) }
-func (c *conn) DeletePassword(email string) error {
-	return c.delete("password", "email", strings.ToLower(email))
+func (c *conn) DeleteAuthRequest(ctx context.Context, id string) error { return c.delete(ctx, "auth_request", "id", id) }
+func (c *conn) DeleteAuthCode(ctx context.Context, id string) error    { return c.delete(ctx, "auth_code", "id", id) }
+func (c *conn) DeleteClient(ctx context.Context, id string) error      { return c.delete(ctx, "client", "id", id) }
+func (c *conn) DeleteRefresh(ctx context.Context, id string) error     { return c.delete(ctx, "refresh_token", "id", id) }
+func (c *conn) DeletePassword(ctx context.Context, email string) error {
+	return c.delete(ctx, "password", "email", strings.ToLower(email))
 }
-func (c *conn) DeleteConnector(id string) error { return c.delete("connector", "id", id) }
+func (c *conn) DeleteConnector(ctx context.Context, id string) error { return c.delete(ctx, "connector", "id", id) }
 
-func (c *conn) DeleteOfflineSessions(userID string, connID string) error {
-	result, err := c.Exec(`delete from offline_session where user_id = $1 AND conn_id = $2`, userID, connID)
+func (c *conn) DeleteOfflineSessions(ctx context.Context, userID string, connID string) error {
+	result, err := c.ExecContext(ctx, `delete from offline_session where user_id = $1 AND conn_id = $2`, userID, connID)
 	if err != nil {
 		return fmt.Errorf("delete offline_session: user_id = %s, conn_id = %s", userID, connID)
 	}
@@ -841,7 +942,7 @@ func (c *conn) DeleteOfflineSessions(userID string, connID string) error {
 	// a driver that doesn't implement this, we can run this in a transaction with a get beforehand.
 	n, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("rows affected: %v", err)
+		return fmt.Errorf("rows affected: %w", err)
 	}
 	if n < 1 {
 		return storage.ErrNotFound
@@ -850,8 +951,764 @@ func (c *conn) DeleteOfflineSessions(userID string, connID string) error {
 }
 
 // Do NOT call directly. Does not escape table.
-func (c *conn) delete(table, field, id string) error {
-	result, err := c.Exec(`delete from `+table+` where `+field+` = $1`, id)
+func (c *conn) CreateTermsAcceptance(ctx context.Context, a storage.TermsAcceptance) error {
+	_, err := c.ExecContext(ctx, `
+		insert into terms_acceptance (
+			user_id, conn_id, version, accepted_at
+		)
+		values (
+			$1, $2, $3, $4
+		);
+	`,
+		a.UserID, a.ConnID, a.Version, a.AcceptedAt,
+	)
+	if err != nil {
+		if c.alreadyExistsCheck(err) {
+			return storage.ErrAlreadyExists
+		}
+		return fmt.Errorf("insert terms acceptance: %w", err)
+	}
+	return nil
+}
+
+func (c *conn) UpdateTermsAcceptance(ctx context.Context, userID string, connID string, updater func(a storage.TermsAcceptance) (storage.TermsAcceptance, error)) error {
+	return c.ExecTx(ctx, func(tx *trans) error {
+		a, err := getTermsAcceptance(ctx, tx, userID, connID)
+		if err != nil {
+			return err
+		}
+
+		newAcceptance, err := updater(a)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+			update terms_acceptance
+			set
+				version = $1,
+				accepted_at = $2
+			where user_id = $3 AND conn_id = $4;
+		`,
+			newAcceptance.Version, newAcceptance.AcceptedAt, a.UserID, a.ConnID,
+		)
+		if err != nil {
+			return fmt.Errorf("update terms acceptance: %w", err)
+		}
+		return nil
+	})
+}
+
+func (c *conn) GetTermsAcceptance(ctx context.Context, userID string, connID string) (storage.TermsAcceptance, error) {
+	return getTermsAcceptance(ctx, c, userID, connID)
+}
+
+func getTermsAcceptance(ctx context.Context, q querier, userID string, connID string) (a storage.TermsAcceptance, err error) {
+	err = q.QueryRowContext(ctx, `
+		select
+			user_id, conn_id, version, accepted_at
+		from terms_acceptance
+		where user_id = $1 AND conn_id = $2;
+		`, userID, connID).Scan(
+		&a.UserID, &a.ConnID, &a.Version, &a.AcceptedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return a, storage.ErrNotFound
+		}
+		return a, fmt.Errorf("select terms acceptance: %w", err)
+	}
+	return a, nil
+}
+
+func (c *conn) DeleteTermsAcceptance(ctx context.Context, userID string, connID string) error {
+	result, err := c.ExecContext(ctx, `delete from terms_acceptance where user_id = $1 AND conn_id = $2`, userID, connID)
+	if err != nil {
+		return fmt.Errorf("delete terms_acceptance: user_id = %s, conn_id = %s", userID, connID)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if n < 1 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+func (c *conn) CreateTOTPSecret(ctx context.Context, t storage.TOTPSecret) error {
+	_, err := c.ExecContext(ctx, `
+		insert into totp_secret (
+			user_id, conn_id, secret, confirmed, last_step, backup_codes, created_at
+		)
+		values (
+			$1, $2, $3, $4, $5, $6, $7
+		);
+	`,
+		t.UserID, t.ConnID, t.Secret, t.Confirmed, t.LastStep, encoder(t.BackupCodes), t.CreatedAt,
+	)
+	if err != nil {
+		if c.alreadyExistsCheck(err) {
+			return storage.ErrAlreadyExists
+		}
+		return fmt.Errorf("insert totp secret: %w", err)
+	}
+	return nil
+}
+
+func (c *conn) UpdateTOTPSecret(ctx context.Context, userID string, connID string, updater func(t storage.TOTPSecret) (storage.TOTPSecret, error)) error {
+	return c.ExecTx(ctx, func(tx *trans) error {
+		t, err := getTOTPSecret(ctx, tx, userID, connID)
+		if err != nil {
+			return err
+		}
+
+		newSecret, err := updater(t)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+			update totp_secret
+			set
+				secret = $1,
+				confirmed = $2,
+				last_step = $3,
+				backup_codes = $4,
+				created_at = $5
+			where user_id = $6 AND conn_id = $7;
+		`,
+			newSecret.Secret, newSecret.Confirmed, newSecret.LastStep, encoder(newSecret.BackupCodes), newSecret.CreatedAt,
+			t.UserID, t.ConnID,
+		)
+		if err != nil {
+			return fmt.Errorf("update totp secret: %w", err)
+		}
+		return nil
+	})
+}
+
+func (c *conn) GetTOTPSecret(ctx context.Context, userID string, connID string) (storage.TOTPSecret, error) {
+	return getTOTPSecret(ctx, c, userID, connID)
+}
+
+func getTOTPSecret(ctx context.Context, q querier, userID string, connID string) (t storage.TOTPSecret, err error) {
+	err = q.QueryRowContext(ctx, `
+		select
+			user_id, conn_id, secret, confirmed, last_step, backup_codes, created_at
+		from totp_secret
+		where user_id = $1 AND conn_id = $2;
+		`, userID, connID).Scan(
+		&t.UserID, &t.ConnID, &t.Secret, &t.Confirmed, &t.LastStep, decoder(&t.BackupCodes), &t.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return t, storage.ErrNotFound
+		}
+		return t, fmt.Errorf("select totp secret: %w", err)
+	}
+	return t, nil
+}
+
+func (c *conn) DeleteTOTPSecret(ctx context.Context, userID string, connID string) error {
+	result, err := c.ExecContext(ctx, `delete from totp_secret where user_id = $1 AND conn_id = $2`, userID, connID)
+	if err != nil {
+		return fmt.Errorf("delete totp_secret: user_id = %s, conn_id = %s", userID, connID)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if n < 1 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+func (c *conn) CreateAuditEvent(ctx context.Context, e storage.AuditEvent) error {
+	_, err := c.ExecContext(ctx, `
+		insert into audit_event (
+			id, type, severity, emitted_at, client_id, subject, connector_id,
+			source_ips, message, revoked
+		)
+		values (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+		);
+	`,
+		e.ID, e.Type, e.Severity, e.Time, e.ClientID, e.Subject, e.ConnectorID,
+		encoder(e.SourceIPs), e.Message, e.Revoked,
+	)
+	if err != nil {
+		if c.alreadyExistsCheck(err) {
+			return storage.ErrAlreadyExists
+		}
+		return fmt.Errorf("insert audit event: %w", err)
+	}
+	return nil
+}
+
+func (c *conn) ListAuditEvents(ctx context.Context, filter storage.AuditEventFilter) ([]storage.AuditEvent, error) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+	if filter.Subject != "" {
+		add("subject = $%d", filter.Subject)
+	}
+	if filter.ClientID != "" {
+		add("client_id = $%d", filter.ClientID)
+	}
+	if filter.Type != "" {
+		add("type = $%d", filter.Type)
+	}
+	if !filter.Since.IsZero() {
+		add("emitted_at >= $%d", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		add("emitted_at < $%d", filter.Until)
+	}
+
+	query := `
+		select
+			id, type, severity, emitted_at, client_id, subject, connector_id,
+			source_ips, message, revoked
+		from audit_event`
+	if len(where) > 0 {
+		query += " where " + strings.Join(where, " and ")
+	}
+	query += " order by emitted_at desc"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" limit $%d", len(args))
+	}
+
+	rows, err := c.QueryContext(ctx, query+";", args...)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+	defer rows.Close()
+
+	var events []storage.AuditEvent
+	for rows.Next() {
+		e, err := scanAuditEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("scan: %w", err)
+	}
+	return events, nil
+}
+
+func (c *conn) UpdateAuditEvent(ctx context.Context, id string, updater func(e storage.AuditEvent) (storage.AuditEvent, error)) error {
+	return c.ExecTx(ctx, func(tx *trans) error {
+		e, err := getAuditEvent(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		nu, err := updater(e)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+			update audit_event
+			set
+				type = $1,
+				severity = $2,
+				emitted_at = $3,
+				client_id = $4,
+				subject = $5,
+				connector_id = $6,
+				source_ips = $7,
+				message = $8,
+				revoked = $9
+			where id = $10;
+		`,
+			nu.Type, nu.Severity, nu.Time, nu.ClientID, nu.Subject, nu.ConnectorID,
+			encoder(nu.SourceIPs), nu.Message, nu.Revoked, id,
+		)
+		if err != nil {
+			return fmt.Errorf("update audit event: %w", err)
+		}
+		return nil
+	})
+}
+
+func getAuditEvent(ctx context.Context, q querier, id string) (storage.AuditEvent, error) {
+	return scanAuditEvent(q.QueryRowContext(ctx, `
+		select
+			id, type, severity, emitted_at, client_id, subject, connector_id,
+			source_ips, message, revoked
+		from audit_event where id = $1;
+	`, id))
+}
+
+func scanAuditEvent(s scanner) (e storage.AuditEvent, err error) {
+	err = s.Scan(
+		&e.ID, &e.Type, &e.Severity, &e.Time, &e.ClientID, &e.Subject, &e.ConnectorID,
+		decoder(&e.SourceIPs), &e.Message, &e.Revoked,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return e, storage.ErrNotFound
+		}
+		return e, fmt.Errorf("scan audit event: %w", err)
+	}
+	return e, nil
+}
+
+func (c *conn) PruneAuditEvents(ctx context.Context, before time.Time) (int64, error) {
+	r, err := c.ExecContext(ctx, `delete from audit_event where emitted_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("prune audit events: %w", err)
+	}
+	return r.RowsAffected()
+}
+
+func (c *conn) CreateAPIKey(ctx context.Context, k storage.APIKey) error {
+	_, err := c.ExecContext(ctx, `
+		insert into api_key (
+			id, hash, client_id, name, scopes,
+			claims_user_id, claims_username, claims_preferred_username,
+			claims_email, claims_email_verified, claims_groups,
+			connector_id, created_at, expiry
+		)
+		values (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14
+		);
+	`,
+		k.ID, k.Hash, k.ClientID, k.Name, encoder(k.Scopes),
+		k.Claims.UserID, k.Claims.Username, k.Claims.PreferredUsername,
+		k.Claims.Email, k.Claims.EmailVerified, encoder(k.Claims.Groups),
+		k.ConnectorID, k.CreatedAt, k.Expiry,
+	)
+	if err != nil {
+		if c.alreadyExistsCheck(err) {
+			return storage.ErrAlreadyExists
+		}
+		return fmt.Errorf("insert api key: %w", err)
+	}
+	return nil
+}
+
+func (c *conn) GetAPIKey(ctx context.Context, id string) (storage.APIKey, error) {
+	return scanAPIKey(c.QueryRowContext(ctx, `
+		select
+			id, hash, client_id, name, scopes,
+			claims_user_id, claims_username, claims_preferred_username,
+			claims_email, claims_email_verified, claims_groups,
+			connector_id, created_at, expiry
+		from api_key where id = $1;
+	`, id))
+}
+
+func (c *conn) ListAPIKeys(ctx context.Context) ([]storage.APIKey, error) {
+	rows, err := c.QueryContext(ctx, `
+		select
+			id, hash, client_id, name, scopes,
+			claims_user_id, claims_username, claims_preferred_username,
+			claims_email, claims_email_verified, claims_groups,
+			connector_id, created_at, expiry
+		from api_key;
+	`)
+	if err != nil {
+		return nil, err
+	}
+	var keys []storage.APIKey
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func scanAPIKey(s scanner) (k storage.APIKey, err error) {
+	err = s.Scan(
+		&k.ID, &k.Hash, &k.ClientID, &k.Name, decoder(&k.Scopes),
+		&k.Claims.UserID, &k.Claims.Username, &k.Claims.PreferredUsername,
+		&k.Claims.Email, &k.Claims.EmailVerified, decoder(&k.Claims.Groups),
+		&k.ConnectorID, &k.CreatedAt, &k.Expiry,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return k, storage.ErrNotFound
+		}
+		return k, fmt.Errorf("select api key: %w", err)
+	}
+	return k, nil
+}
+
+func (c *conn) DeleteAPIKey(ctx context.Context, id string) error { return c.delete(ctx, "api_key", "id", id) }
+
+func (c *conn) CreateServiceAccount(ctx context.Context, a storage.ServiceAccount) error {
+	_, err := c.ExecContext(ctx, `
+		insert into service_account (
+			id, name, group_names, clients, public_keys, created_at
+		)
+		values (
+			$1, $2, $3, $4, $5, $6
+		);
+	`,
+		a.ID, a.Name, encoder(a.Groups), encoder(a.Clients), encoder(a.PublicKeys), a.CreatedAt,
+	)
+	if err != nil {
+		if c.alreadyExistsCheck(err) {
+			return storage.ErrAlreadyExists
+		}
+		return fmt.Errorf("insert service account: %w", err)
+	}
+	return nil
+}
+
+func (c *conn) UpdateServiceAccount(ctx context.Context, id string, updater func(a storage.ServiceAccount) (storage.ServiceAccount, error)) error {
+	return c.ExecTx(ctx, func(tx *trans) error {
+		a, err := getServiceAccount(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		nu, err := updater(a)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+			update service_account
+			set
+				name = $1,
+				group_names = $2,
+				clients = $3,
+				public_keys = $4
+			where id = $5;
+		`,
+			nu.Name, encoder(nu.Groups), encoder(nu.Clients), encoder(nu.PublicKeys), a.ID,
+		)
+		if err != nil {
+			return fmt.Errorf("update service account: %w", err)
+		}
+		return nil
+	})
+}
+
+func (c *conn) GetServiceAccount(ctx context.Context, id string) (storage.ServiceAccount, error) {
+	return getServiceAccount(ctx, c, id)
+}
+
+func getServiceAccount(ctx context.Context, q querier, id string) (storage.ServiceAccount, error) {
+	return scanServiceAccount(q.QueryRowContext(ctx, `
+		select
+			id, name, group_names, clients, public_keys, created_at
+		from service_account where id = $1;
+	`, id))
+}
+
+func (c *conn) ListServiceAccounts(ctx context.Context) ([]storage.ServiceAccount, error) {
+	rows, err := c.QueryContext(ctx, `
+		select
+			id, name, group_names, clients, public_keys, created_at
+		from service_account;
+	`)
+	if err != nil {
+		return nil, err
+	}
+	var accounts []storage.ServiceAccount
+	for rows.Next() {
+		a, err := scanServiceAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+func scanServiceAccount(s scanner) (a storage.ServiceAccount, err error) {
+	err = s.Scan(
+		&a.ID, &a.Name, decoder(&a.Groups), decoder(&a.Clients), decoder(&a.PublicKeys), &a.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return a, storage.ErrNotFound
+		}
+		return a, fmt.Errorf("select service account: %w", err)
+	}
+	return a, nil
+}
+
+func (c *conn) DeleteServiceAccount(ctx context.Context, id string) error {
+	return c.delete(ctx, "service_account", "id", id)
+}
+
+func (c *conn) CreateRevokedToken(ctx context.Context, t storage.RevokedToken) error {
+	_, err := c.ExecContext(ctx, `
+		insert into revoked_token (id, client_id, reason, revoked_at, expiry)
+		values ($1, $2, $3, $4, $5);
+	`, t.ID, t.ClientID, t.Reason, t.RevokedAt, t.Expiry)
+	if err != nil {
+		if c.alreadyExistsCheck(err) {
+			return storage.ErrAlreadyExists
+		}
+		return fmt.Errorf("insert revoked token: %w", err)
+	}
+	return nil
+}
+
+func (c *conn) GetRevokedToken(ctx context.Context, id string) (t storage.RevokedToken, err error) {
+	err = c.QueryRowContext(ctx, `
+		select id, client_id, reason, revoked_at, expiry
+		from revoked_token where id = $1;
+	`, id).Scan(&t.ID, &t.ClientID, &t.Reason, &t.RevokedAt, &t.Expiry)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return t, storage.ErrNotFound
+		}
+		return t, fmt.Errorf("select revoked token: %w", err)
+	}
+	return t, nil
+}
+
+func (c *conn) CreateSession(ctx context.Context, s storage.Session) error {
+	_, err := c.ExecContext(ctx, `
+		insert into session (
+			id, connector_id,
+			claims_user_id, claims_username, claims_preferred_username,
+			claims_email, claims_email_verified, claims_groups,
+			connector_data, client_ids, created_at, expiry
+		)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12);
+	`,
+		s.ID, s.ConnectorID,
+		s.Claims.UserID, s.Claims.Username, s.Claims.PreferredUsername,
+		s.Claims.Email, s.Claims.EmailVerified, encoder(s.Claims.Groups),
+		s.ConnectorData, encoder(s.ClientIDs), s.CreatedAt, s.Expiry,
+	)
+	if err != nil {
+		if c.alreadyExistsCheck(err) {
+			return storage.ErrAlreadyExists
+		}
+		return fmt.Errorf("insert session: %w", err)
+	}
+	return nil
+}
+
+func (c *conn) UpdateSession(ctx context.Context, id string, updater func(s storage.Session) (storage.Session, error)) error {
+	return c.ExecTx(ctx, func(tx *trans) error {
+		s, err := getSession(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		ns, err := updater(s)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+			update session
+			set
+				client_ids = $1,
+				expiry = $2
+			where id = $3;
+		`, encoder(ns.ClientIDs), ns.Expiry, s.ID)
+		if err != nil {
+			return fmt.Errorf("update session: %w", err)
+		}
+		return nil
+	})
+}
+
+func (c *conn) GetSession(ctx context.Context, id string) (storage.Session, error) {
+	return getSession(ctx, c, id)
+}
+
+func getSession(ctx context.Context, q querier, id string) (storage.Session, error) {
+	return scanSession(q.QueryRowContext(ctx, `
+		select
+			id, connector_id,
+			claims_user_id, claims_username, claims_preferred_username,
+			claims_email, claims_email_verified, claims_groups,
+			connector_data, client_ids, created_at, expiry
+		from session where id = $1;
+	`, id))
+}
+
+func (c *conn) ListSessions(ctx context.Context) ([]storage.Session, error) {
+	rows, err := c.QueryContext(ctx, `
+		select
+			id, connector_id,
+			claims_user_id, claims_username, claims_preferred_username,
+			claims_email, claims_email_verified, claims_groups,
+			connector_data, client_ids, created_at, expiry
+		from session;
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+	var sessions []storage.Session
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("scan: %w", err)
+	}
+	return sessions, nil
+}
+
+func scanSession(sc scanner) (s storage.Session, err error) {
+	err = sc.Scan(
+		&s.ID, &s.ConnectorID,
+		&s.Claims.UserID, &s.Claims.Username, &s.Claims.PreferredUsername,
+		&s.Claims.Email, &s.Claims.EmailVerified, decoder(&s.Claims.Groups),
+		&s.ConnectorData, decoder(&s.ClientIDs), &s.CreatedAt, &s.Expiry,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return s, storage.ErrNotFound
+		}
+		return s, fmt.Errorf("scan session: %w", err)
+	}
+	return s, nil
+}
+
+func (c *conn) DeleteSession(ctx context.Context, id string) error {
+	return c.delete(ctx, "session", "id", id)
+}
+
+func (c *conn) CreatePreAuthorizedCode(ctx context.Context, p storage.PreAuthorizedCode) error {
+	_, err := c.ExecContext(ctx, `
+		insert into pre_authorized_code (
+			id, client_id, scopes,
+			claims_user_id, claims_username, claims_preferred_username,
+			claims_email, claims_email_verified, claims_groups,
+			connector_id, pin_hash, created_at, expiry
+		)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13);
+	`,
+		p.ID, p.ClientID, encoder(p.Scopes),
+		p.Claims.UserID, p.Claims.Username, p.Claims.PreferredUsername,
+		p.Claims.Email, p.Claims.EmailVerified, encoder(p.Claims.Groups),
+		p.ConnectorID, p.PINHash, p.CreatedAt, p.Expiry,
+	)
+	if err != nil {
+		if c.alreadyExistsCheck(err) {
+			return storage.ErrAlreadyExists
+		}
+		return fmt.Errorf("insert pre_authorized_code: %w", err)
+	}
+	return nil
+}
+
+func (c *conn) ConsumePreAuthorizedCode(ctx context.Context, id string) (p storage.PreAuthorizedCode, err error) {
+	err = c.consume(ctx, "pre_authorized_code", `
+		id, client_id, scopes,
+		claims_user_id, claims_username, claims_preferred_username,
+		claims_email, claims_email_verified, claims_groups,
+		connector_id, pin_hash, created_at, expiry
+	`, id, func(s scanner) error {
+		err := s.Scan(
+			&p.ID, &p.ClientID, decoder(&p.Scopes),
+			&p.Claims.UserID, &p.Claims.Username, &p.Claims.PreferredUsername,
+			&p.Claims.Email, &p.Claims.EmailVerified, decoder(&p.Claims.Groups),
+			&p.ConnectorID, &p.PINHash, &p.CreatedAt, &p.Expiry,
+		)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return storage.ErrNotFound
+			}
+			return fmt.Errorf("scan pre_authorized_code: %w", err)
+		}
+		return nil
+	})
+	return p, err
+}
+
+func (c *conn) CreateLoginLink(ctx context.Context, l storage.LoginLink) error {
+	_, err := c.ExecContext(ctx, `
+		insert into login_link (
+			id, auth_request_id, connector_id, email, created_at, expiry
+		)
+		values ($1, $2, $3, $4, $5, $6);
+	`,
+		l.ID, l.AuthRequestID, l.ConnectorID, l.Email, l.CreatedAt, l.Expiry,
+	)
+	if err != nil {
+		if c.alreadyExistsCheck(err) {
+			return storage.ErrAlreadyExists
+		}
+		return fmt.Errorf("insert login_link: %w", err)
+	}
+	return nil
+}
+
+func (c *conn) ConsumeLoginLink(ctx context.Context, id string) (l storage.LoginLink, err error) {
+	err = c.consume(ctx, "login_link", `
+		id, auth_request_id, connector_id, email, created_at, expiry
+	`, id, func(s scanner) error {
+		err := s.Scan(
+			&l.ID, &l.AuthRequestID, &l.ConnectorID, &l.Email, &l.CreatedAt, &l.Expiry,
+		)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return storage.ErrNotFound
+			}
+			return fmt.Errorf("scan login_link: %w", err)
+		}
+		return nil
+	})
+	return l, err
+}
+
+// consume atomically deletes the row of table with the given id, passing the
+// listed columns of the deleted row to scan. Only one caller can consume a
+// row, all others receive storage.ErrNotFound.
+func (c *conn) consume(ctx context.Context, table, columns, id string, scan func(s scanner) error) error {
+	if c.flavor.supportsDeleteReturning {
+		// A single statement needs no transaction. Under a serializable
+		// one, concurrent consumers would fail with serialization errors
+		// instead of finding no row.
+		return scan(c.QueryRowContext(ctx, `delete from `+table+` where id = $1 returning `+columns+`;`, id))
+	}
+	return c.ExecTx(ctx, func(tx *trans) error {
+		if err := scan(tx.QueryRowContext(ctx, `select `+columns+` from `+table+` where id = $1;`, id)); err != nil {
+			return err
+		}
+		result, err := tx.ExecContext(ctx, `delete from `+table+` where id = $1`, id)
+		if err != nil {
+			return fmt.Errorf("delete %s: %w", table, err)
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("rows affected: %w", err)
+		}
+		// A concurrent transaction consumed the row between the select
+		// and the delete.
+		if n < 1 {
+			return storage.ErrNotFound
+		}
+		return nil
+	})
+}
+
+func (c *conn) delete(ctx context.Context, table, field, id string) error {
+	result, err := c.ExecContext(ctx, `delete from `+table+` where `+field+` = $1`, id)
 	if err != nil {
 		return fmt.Errorf("delete %s: %v", table, id)
 	}
@@ -860,10 +1717,110 @@ func (c *conn) delete(table, field, id string) error {
 	// a driver that doesn't implement this, we can run this in a transaction with a get beforehand.
 	n, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("rows affected: %v", err)
+		return fmt.Errorf("rows affected: %w", err)
 	}
 	if n < 1 {
 		return storage.ErrNotFound
 	}
 	return nil
 }
+
+func (c *conn) CreateWebAuthnCredential(ctx context.Context, w storage.WebAuthnCredential) error {
+	_, err := c.ExecContext(ctx, `
+		insert into webauthn_credential (
+			id, user_id, connector_id, public_key, sign_count, created_at, last_used
+		)
+		values (
+			$1, $2, $3, $4, $5, $6, $7
+		);
+	`,
+		w.ID, w.UserID, w.ConnectorID, w.PublicKey, w.SignCount, w.CreatedAt, w.LastUsed,
+	)
+	if err != nil {
+		if c.alreadyExistsCheck(err) {
+			return storage.ErrAlreadyExists
+		}
+		return fmt.Errorf("insert webauthn credential: %w", err)
+	}
+	return nil
+}
+
+func (c *conn) UpdateWebAuthnCredential(ctx context.Context, id string, updater func(w storage.WebAuthnCredential) (storage.WebAuthnCredential, error)) error {
+	return c.ExecTx(ctx, func(tx *trans) error {
+		w, err := getWebAuthnCredential(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		nw, err := updater(w)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+			update webauthn_credential
+			set
+				public_key = $1,
+				sign_count = $2,
+				last_used = $3
+			where id = $4;
+		`,
+			nw.PublicKey, nw.SignCount, nw.LastUsed, w.ID,
+		)
+		if err != nil {
+			return fmt.Errorf("update webauthn credential: %w", err)
+		}
+		return nil
+	})
+}
+
+func (c *conn) GetWebAuthnCredential(ctx context.Context, id string) (storage.WebAuthnCredential, error) {
+	return getWebAuthnCredential(ctx, c, id)
+}
+
+func getWebAuthnCredential(ctx context.Context, q querier, id string) (storage.WebAuthnCredential, error) {
+	return scanWebAuthnCredential(q.QueryRowContext(ctx, `
+		select
+			id, user_id, connector_id, public_key, sign_count, created_at, last_used
+		from webauthn_credential where id = $1;
+	`, id))
+}
+
+func (c *conn) ListWebAuthnCredentials(ctx context.Context, userID string, connID string) ([]storage.WebAuthnCredential, error) {
+	rows, err := c.QueryContext(ctx, `
+		select
+			id, user_id, connector_id, public_key, sign_count, created_at, last_used
+		from webauthn_credential where user_id = $1 and connector_id = $2;
+	`, userID, connID)
+	if err != nil {
+		return nil, err
+	}
+	var creds []storage.WebAuthnCredential
+	for rows.Next() {
+		w, err := scanWebAuthnCredential(rows)
+		if err != nil {
+			return nil, err
+		}
+		creds = append(creds, w)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return creds, nil
+}
+
+func scanWebAuthnCredential(s scanner) (w storage.WebAuthnCredential, err error) {
+	err = s.Scan(
+		&w.ID, &w.UserID, &w.ConnectorID, &w.PublicKey, &w.SignCount, &w.CreatedAt, &w.LastUsed,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return w, storage.ErrNotFound
+		}
+		return w, fmt.Errorf("select webauthn credential: %w", err)
+	}
+	return w, nil
+}
+
+func (c *conn) DeleteWebAuthnCredential(ctx context.Context, id string) error {
+	return c.delete(ctx, "webauthn_credential", "id", id)
+}
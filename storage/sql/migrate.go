@@ -1,6 +1,7 @@
 package sql
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 )
@@ -13,7 +14,7 @@ func (c *conn) migrate() (int, error) {
 		);
 	`)
 	if err != nil {
-		return 0, fmt.Errorf("creating migration table: %v", err)
+		return 0, fmt.Errorf("creating migration table: %w", err)
 	}
 
 	i := 0
@@ -27,14 +28,14 @@ func (c *conn) migrate() (int, error) {
 	}
 
 	for {
-		err := c.ExecTx(func(tx *trans) error {
+		err := c.ExecTx(context.Background(), func(tx *trans) error {
 			// Within a transaction, perform a single migration.
 			var (
 				num sql.NullInt64
 				n   int
 			)
 			if err := tx.QueryRow(`select max(num) from migrations;`).Scan(&num); err != nil {
-				return fmt.Errorf("select max migration: %v", err)
+				return fmt.Errorf("select max migration: %w", err)
 			}
 			if num.Valid {
 				n = int(num.Int64)
@@ -48,13 +49,13 @@ func (c *conn) migrate() (int, error) {
 			m := flavorMigrations[n]
 			for i := range m.stmts {
 				if _, err := tx.Exec(m.stmts[i]); err != nil {
-					return fmt.Errorf("migration %d statement %d failed: %v", migrationNum, i+1, err)
+					return fmt.Errorf("migration %d statement %d failed: %w", migrationNum, i+1, err)
 				}
 			}
 
 			q := `insert into migrations (num, at) values ($1, now());`
 			if _, err := tx.Exec(q, migrationNum); err != nil {
-				return fmt.Errorf("update migration table: %v", err)
+				return fmt.Errorf("update migration table: %w", err)
 			}
 			return nil
 		})
@@ -229,4 +230,249 @@ var migrations = []migration{
 		},
 		flavor: &flavorMySQL,
 	},
+	{
+		stmts: []string{`
+			alter table refresh_token
+				add column last_used_ip text not null default '';`,
+		},
+	},
+	{
+		stmts: []string{`
+			alter table client
+				add column allowed_cidrs bytea;`,
+			`
+			update client set allowed_cidrs = 'null';`,
+		},
+	},
+	{
+		stmts: []string{`
+			create table terms_acceptance (
+				user_id text not null,
+				conn_id text not null,
+				version text not null,
+				accepted_at timestamptz not null,
+				PRIMARY KEY (user_id, conn_id)
+			);`,
+		},
+	},
+	{
+		stmts: []string{`
+			alter table refresh_token
+				add column device_fingerprint text not null default '';`,
+		},
+	},
+	{
+		stmts: []string{`
+			create table audit_event (
+				id text not null primary key,
+				type text not null,
+				severity text not null,
+				emitted_at timestamptz not null,
+				client_id text not null,
+				subject text not null,
+				connector_id text not null,
+				source_ips bytea not null,
+				message text not null,
+				revoked boolean not null
+			);`,
+			`
+			create index audit_event_emitted_at on audit_event (emitted_at);`,
+			`
+			create index audit_event_subject on audit_event (subject, emitted_at);`,
+			`
+			create index audit_event_client_id on audit_event (client_id, emitted_at);`,
+		},
+	},
+	{
+		stmts: []string{`
+			alter table client
+				add column claims bytea;`,
+			`
+			update client set claims = 'null';`,
+		},
+	},
+	{
+		stmts: []string{`
+			alter table auth_request
+				add column fallback_from text not null default '';`,
+		},
+	},
+	{
+		stmts: []string{`
+			create table api_key (
+				id text not null primary key,
+				hash text not null,
+				client_id text not null,
+				name text not null,
+				scopes bytea not null, -- JSON array of strings
+				claims_user_id text not null,
+				claims_username text not null,
+				claims_preferred_username text not null default '',
+				claims_email text not null,
+				claims_email_verified boolean not null,
+				claims_groups bytea not null, -- JSON array of strings
+				connector_id text not null,
+				created_at timestamptz not null,
+				expiry timestamptz not null
+			);`,
+		},
+	},
+	{
+		stmts: []string{`
+			create table service_account (
+				id text not null primary key,
+				name text not null,
+				group_names bytea not null, -- JSON array of strings
+				clients bytea not null, -- JSON array of strings
+				public_keys bytea not null, -- JSON array of JSON web keys
+				created_at timestamptz not null
+			);`,
+		},
+	},
+	{
+		stmts: []string{`
+			alter table auth_request
+				add column code_challenge text not null default '';`,
+			`
+			alter table auth_request
+				add column code_challenge_method text not null default '';`,
+			`
+			alter table auth_code
+				add column code_challenge text not null default '';`,
+			`
+			alter table auth_code
+				add column code_challenge_method text not null default '';`,
+		},
+	},
+	{
+		stmts: []string{`
+			alter table client
+				add column tos_uri text not null default '';`,
+			`
+			alter table client
+				add column policy_uri text not null default '';`,
+			`
+			alter table client
+				add column contacts bytea;`,
+			`
+			update client set contacts = 'null';`,
+			`
+			alter table client
+				add column application_type text not null default '';`,
+		},
+	},
+	{
+		stmts: []string{`
+			alter table client
+				add column expiry timestamptz not null default '0001-01-01 00:00:00 UTC';`,
+		},
+	},
+	{
+		stmts: []string{`
+			create table revoked_token (
+				id text not null primary key,
+				client_id text not null,
+				reason text not null,
+				revoked_at timestamptz not null,
+				expiry timestamptz not null
+			);`,
+		},
+	},
+	{
+		stmts: []string{`
+			create table session (
+				id text not null primary key,
+				connector_id text not null,
+				claims_user_id text not null,
+				claims_username text not null,
+				claims_preferred_username text not null,
+				claims_email text not null,
+				claims_email_verified boolean not null,
+				claims_groups bytea not null, -- JSON array of strings
+				connector_data bytea,
+				created_at timestamptz not null,
+				expiry timestamptz not null
+			);`,
+		},
+	},
+	{
+		stmts: []string{`
+			alter table client
+				add column backchannel_logout_uri text not null default '';`,
+			`
+			alter table session
+				add column client_ids bytea;`,
+			`
+			update session set client_ids = 'null';`,
+		},
+	},
+	{
+		stmts: []string{`
+			create table pre_authorized_code (
+				id text not null primary key,
+				client_id text not null,
+				scopes bytea not null, -- JSON array of strings
+				claims_user_id text not null,
+				claims_username text not null,
+				claims_preferred_username text not null,
+				claims_email text not null,
+				claims_email_verified boolean not null,
+				claims_groups bytea not null, -- JSON array of strings
+				connector_id text not null,
+				pin_hash bytea,
+				created_at timestamptz not null,
+				expiry timestamptz not null
+			);`,
+		},
+	},
+	{
+		stmts: []string{`
+			create table webauthn_credential (
+				id text not null primary key,
+				user_id text not null,
+				connector_id text not null,
+				public_key bytea not null,
+				sign_count bigint not null,
+				created_at timestamptz not null,
+				last_used timestamptz not null
+			);`,
+			`
+			alter table auth_request
+				add column second_factor text not null default '';`,
+			`
+			alter table auth_request
+				add column second_factor_challenge bytea;`,
+		},
+	},
+	{
+		stmts: []string{`
+			create table login_link (
+				id text not null primary key,
+				auth_request_id text not null,
+				connector_id text not null,
+				email text not null,
+				created_at timestamptz not null,
+				expiry timestamptz not null
+			);`,
+		},
+	},
+	{
+		stmts: []string{`
+			create table totp_secret (
+				user_id text not null,
+				conn_id text not null,
+				secret bytea not null,
+				confirmed boolean not null,
+				last_step bigint not null,
+				backup_codes bytea not null, -- JSON array of hashes
+				created_at timestamptz not null,
+				PRIMARY KEY (user_id, conn_id)
+			);`,
+		},
+	},
+	{
+		stmts: []string{`
+			create index webauthn_credential_user on webauthn_credential (user_id, connector_id);`,
+		},
+	},
 }
@@ -2,6 +2,7 @@
 package sql
 
 import (
+	"context"
 	"database/sql"
 	"regexp"
 	"time"
@@ -20,10 +21,13 @@ type flavor struct {
 	queryReplacers []replacer
 
 	// Optional function to create and finish a transaction.
-	executeTx func(db *sql.DB, fn func(*sql.Tx) error) error
+	executeTx func(ctx context.Context, db *sql.DB, fn func(*sql.Tx) error) error
 
 	// Does the flavor support timezones?
 	supportsTimezones bool
+
+	// Does the flavor support "delete ... returning"?
+	supportsDeleteReturning bool
 }
 
 // A regexp with a replacement string.
@@ -50,14 +54,14 @@ var (
 		//
 		// NOTE(ericchiang): For some reason using `SET SESSION CHARACTERISTICS AS TRANSACTION` at a
 		// session level didn't work for some edge cases. Might be something worth exploring.
-		executeTx: func(db *sql.DB, fn func(sqlTx *sql.Tx) error) error {
-			tx, err := db.Begin()
+		executeTx: func(ctx context.Context, db *sql.DB, fn func(sqlTx *sql.Tx) error) error {
+			tx, err := db.BeginTx(ctx, nil)
 			if err != nil {
 				return err
 			}
 			defer tx.Rollback()
 
-			if _, err := tx.Exec(`SET TRANSACTION ISOLATION LEVEL SERIALIZABLE;`); err != nil {
+			if _, err := tx.ExecContext(ctx, `SET TRANSACTION ISOLATION LEVEL SERIALIZABLE;`); err != nil {
 				return err
 			}
 			if err := fn(tx); err != nil {
@@ -66,7 +70,8 @@ var (
 			return tx.Commit()
 		},
 
-		supportsTimezones: true,
+		supportsTimezones:       true,
+		supportsDeleteReturning: true,
 	}
 
 	flavorSQLite3 = flavor{
@@ -156,15 +161,31 @@ func (c *conn) QueryRow(query string, args ...interface{}) *sql.Row {
 	return c.db.QueryRow(query, c.translateArgs(args)...)
 }
 
-// ExecTx runs a method which operates on a transaction.
-func (c *conn) ExecTx(fn func(tx *trans) error) error {
+func (c *conn) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	query = c.flavor.translate(query)
+	return c.db.ExecContext(ctx, query, c.translateArgs(args)...)
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	query = c.flavor.translate(query)
+	return c.db.QueryContext(ctx, query, c.translateArgs(args)...)
+}
+
+func (c *conn) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	query = c.flavor.translate(query)
+	return c.db.QueryRowContext(ctx, query, c.translateArgs(args)...)
+}
+
+// ExecTx runs a method which operates on a transaction. The transaction is
+// rolled back if ctx is done before it's committed.
+func (c *conn) ExecTx(ctx context.Context, fn func(tx *trans) error) error {
 	if c.flavor.executeTx != nil {
-		return c.flavor.executeTx(c.db, func(sqlTx *sql.Tx) error {
+		return c.flavor.executeTx(ctx, c.db, func(sqlTx *sql.Tx) error {
 			return fn(&trans{sqlTx, c})
 		})
 	}
 
-	sqlTx, err := c.db.Begin()
+	sqlTx, err := c.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -196,3 +217,13 @@ func (t *trans) QueryRow(query string, args ...interface{}) *sql.Row {
 	query = t.c.flavor.translate(query)
 	return t.tx.QueryRow(query, t.c.translateArgs(args)...)
 }
+
+func (t *trans) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	query = t.c.flavor.translate(query)
+	return t.tx.ExecContext(ctx, query, t.c.translateArgs(args)...)
+}
+
+func (t *trans) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	query = t.c.flavor.translate(query)
+	return t.tx.QueryRowContext(ctx, query, t.c.translateArgs(args)...)
+}
@@ -1,8 +1,13 @@
 package storage
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/dexidp/dex/pkg/log"
 )
@@ -31,11 +36,11 @@ func WithStaticClients(s Storage, staticClients []Client) Storage {
 	return staticClientsStorage{s, staticClients, clientsByID}
 }
 
-func (s staticClientsStorage) GetClient(id string) (Client, error) {
+func (s staticClientsStorage) GetClient(ctx context.Context, id string) (Client, error) {
 	if client, ok := s.clientsByID[id]; ok {
 		return client, nil
 	}
-	return s.Storage.GetClient(id)
+	return s.Storage.GetClient(ctx, id)
 }
 
 func (s staticClientsStorage) isStatic(id string) bool {
@@ -43,8 +48,8 @@ func (s staticClientsStorage) isStatic(id string) bool {
 	return ok
 }
 
-func (s staticClientsStorage) ListClients() ([]Client, error) {
-	clients, err := s.Storage.ListClients()
+func (s staticClientsStorage) ListClients(ctx context.Context) ([]Client, error) {
+	clients, err := s.Storage.ListClients(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -60,25 +65,25 @@ func (s staticClientsStorage) ListClients() ([]Client, error) {
 	return append(clients[:n], s.clients...), nil
 }
 
-func (s staticClientsStorage) CreateClient(c Client) error {
+func (s staticClientsStorage) CreateClient(ctx context.Context, c Client) error {
 	if s.isStatic(c.ID) {
 		return errors.New("static clients: read-only cannot create client")
 	}
-	return s.Storage.CreateClient(c)
+	return s.Storage.CreateClient(ctx, c)
 }
 
-func (s staticClientsStorage) DeleteClient(id string) error {
+func (s staticClientsStorage) DeleteClient(ctx context.Context, id string) error {
 	if s.isStatic(id) {
 		return errors.New("static clients: read-only cannot delete client")
 	}
-	return s.Storage.DeleteClient(id)
+	return s.Storage.DeleteClient(ctx, id)
 }
 
-func (s staticClientsStorage) UpdateClient(id string, updater func(old Client) (Client, error)) error {
+func (s staticClientsStorage) UpdateClient(ctx context.Context, id string, updater func(old Client) (Client, error)) error {
 	if s.isStatic(id) {
 		return errors.New("static clients: read-only cannot update client")
 	}
-	return s.Storage.UpdateClient(id, updater)
+	return s.Storage.UpdateClient(ctx, id, updater)
 }
 
 type staticPasswordsStorage struct {
@@ -99,7 +104,7 @@ func WithStaticPasswords(s Storage, staticPasswords []Password, logger log.Logge
 		//Enable case insensitive email comparison.
 		lowerEmail := strings.ToLower(p.Email)
 		if _, ok := passwordsByEmail[lowerEmail]; ok {
-			logger.Errorf("Attempting to create StaticPasswords with the same email id: %s", p.Email)
+			logger.Errorf("Attempting to create StaticPasswords with the same email id: %s", log.Email(p.Email))
 		}
 		passwordsByEmail[lowerEmail] = p
 	}
@@ -112,18 +117,18 @@ func (s staticPasswordsStorage) isStatic(email string) bool {
 	return ok
 }
 
-func (s staticPasswordsStorage) GetPassword(email string) (Password, error) {
+func (s staticPasswordsStorage) GetPassword(ctx context.Context, email string) (Password, error) {
 	// TODO(ericchiang): BLAH. We really need to figure out how to handle
 	// lower cased emails better.
 	email = strings.ToLower(email)
 	if password, ok := s.passwordsByEmail[email]; ok {
 		return password, nil
 	}
-	return s.Storage.GetPassword(email)
+	return s.Storage.GetPassword(ctx, email)
 }
 
-func (s staticPasswordsStorage) ListPasswords() ([]Password, error) {
-	passwords, err := s.Storage.ListPasswords()
+func (s staticPasswordsStorage) ListPasswords(ctx context.Context) ([]Password, error) {
+	passwords, err := s.Storage.ListPasswords(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -140,25 +145,25 @@ func (s staticPasswordsStorage) ListPasswords() ([]Password, error) {
 	return append(passwords[:n], s.passwords...), nil
 }
 
-func (s staticPasswordsStorage) CreatePassword(p Password) error {
+func (s staticPasswordsStorage) CreatePassword(ctx context.Context, p Password) error {
 	if s.isStatic(p.Email) {
 		return errors.New("static passwords: read-only cannot create password")
 	}
-	return s.Storage.CreatePassword(p)
+	return s.Storage.CreatePassword(ctx, p)
 }
 
-func (s staticPasswordsStorage) DeletePassword(email string) error {
+func (s staticPasswordsStorage) DeletePassword(ctx context.Context, email string) error {
 	if s.isStatic(email) {
 		return errors.New("static passwords: read-only cannot delete password")
 	}
-	return s.Storage.DeletePassword(email)
+	return s.Storage.DeletePassword(ctx, email)
 }
 
-func (s staticPasswordsStorage) UpdatePassword(email string, updater func(old Password) (Password, error)) error {
+func (s staticPasswordsStorage) UpdatePassword(ctx context.Context, email string, updater func(old Password) (Password, error)) error {
 	if s.isStatic(email) {
 		return errors.New("static passwords: read-only cannot update password")
 	}
-	return s.Storage.UpdatePassword(email, updater)
+	return s.Storage.UpdatePassword(ctx, email, updater)
 }
 
 // staticConnectorsStorage represents a storage with read-only set of connectors.
@@ -185,15 +190,15 @@ func (s staticConnectorsStorage) isStatic(id string) bool {
 	return ok
 }
 
-func (s staticConnectorsStorage) GetConnector(id string) (Connector, error) {
+func (s staticConnectorsStorage) GetConnector(ctx context.Context, id string) (Connector, error) {
 	if connector, ok := s.connectorsByID[id]; ok {
 		return connector, nil
 	}
-	return s.Storage.GetConnector(id)
+	return s.Storage.GetConnector(ctx, id)
 }
 
-func (s staticConnectorsStorage) ListConnectors() ([]Connector, error) {
-	connectors, err := s.Storage.ListConnectors()
+func (s staticConnectorsStorage) ListConnectors(ctx context.Context) ([]Connector, error) {
+	connectors, err := s.Storage.ListConnectors(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -210,23 +215,146 @@ func (s staticConnectorsStorage) ListConnectors() ([]Connector, error) {
 	return append(connectors[:n], s.connectors...), nil
 }
 
-func (s staticConnectorsStorage) CreateConnector(c Connector) error {
+func (s staticConnectorsStorage) CreateConnector(ctx context.Context, c Connector) error {
 	if s.isStatic(c.ID) {
 		return errors.New("static connectors: read-only cannot create connector")
 	}
-	return s.Storage.CreateConnector(c)
+	return s.Storage.CreateConnector(ctx, c)
 }
 
-func (s staticConnectorsStorage) DeleteConnector(id string) error {
+func (s staticConnectorsStorage) DeleteConnector(ctx context.Context, id string) error {
 	if s.isStatic(id) {
 		return errors.New("static connectors: read-only cannot delete connector")
 	}
-	return s.Storage.DeleteConnector(id)
+	return s.Storage.DeleteConnector(ctx, id)
 }
 
-func (s staticConnectorsStorage) UpdateConnector(id string, updater func(old Connector) (Connector, error)) error {
+func (s staticConnectorsStorage) UpdateConnector(ctx context.Context, id string, updater func(old Connector) (Connector, error)) error {
 	if s.isStatic(id) {
 		return errors.New("static connectors: read-only cannot update connector")
 	}
-	return s.Storage.UpdateConnector(id, updater)
+	return s.Storage.UpdateConnector(ctx, id, updater)
+}
+
+// StaticObjects are the read-only clients, passwords and connectors added to
+// a storage.
+type StaticObjects struct {
+	Clients    []Client
+	Passwords  []Password
+	Connectors []Connector
+}
+
+// ReloadableStatic is a storage with read-only sets of clients, passwords and
+// connectors which can be replaced while the storage is in use.
+type ReloadableStatic struct {
+	Storage
+
+	logger log.Logger
+
+	mu     sync.RWMutex
+	static Storage
+}
+
+// WithReloadableStatic returns a storage with the static objects, as added by
+// WithStaticClients, WithStaticPasswords and WithStaticConnectors.
+func WithReloadableStatic(s Storage, objects StaticObjects, logger log.Logger) *ReloadableStatic {
+	r := &ReloadableStatic{Storage: s, logger: logger}
+	r.Reload(objects)
+	return r
+}
+
+// Reload replaces the static objects.
+//
+// Connectors without a resource version get one derived from their
+// contents, so servers reopen static connectors whose config changed.
+func (r *ReloadableStatic) Reload(objects StaticObjects) {
+	static := r.Storage
+	if len(objects.Clients) > 0 {
+		static = WithStaticClients(static, objects.Clients)
+	}
+	if len(objects.Passwords) > 0 {
+		static = WithStaticPasswords(static, objects.Passwords, r.logger)
+	}
+	connectors := make([]Connector, len(objects.Connectors))
+	for i, c := range objects.Connectors {
+		if c.ResourceVersion == "" {
+			h := sha256.New()
+			for _, field := range [][]byte{[]byte(c.Type), []byte(c.Name), c.Config} {
+				fmt.Fprintf(h, "%d:%s", len(field), field)
+			}
+			c.ResourceVersion = "static-" + hex.EncodeToString(h.Sum(nil))[:16]
+		}
+		connectors[i] = c
+	}
+	static = WithStaticConnectors(static, connectors)
+
+	r.mu.Lock()
+	r.static = static
+	r.mu.Unlock()
+}
+
+func (r *ReloadableStatic) current() Storage {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.static
+}
+
+func (r *ReloadableStatic) GetClient(ctx context.Context, id string) (Client, error) {
+	return r.current().GetClient(ctx, id)
+}
+
+func (r *ReloadableStatic) ListClients(ctx context.Context) ([]Client, error) {
+	return r.current().ListClients(ctx)
+}
+
+func (r *ReloadableStatic) CreateClient(ctx context.Context, c Client) error {
+	return r.current().CreateClient(ctx, c)
+}
+
+func (r *ReloadableStatic) UpdateClient(ctx context.Context, id string, updater func(old Client) (Client, error)) error {
+	return r.current().UpdateClient(ctx, id, updater)
+}
+
+func (r *ReloadableStatic) DeleteClient(ctx context.Context, id string) error {
+	return r.current().DeleteClient(ctx, id)
+}
+
+func (r *ReloadableStatic) GetPassword(ctx context.Context, email string) (Password, error) {
+	return r.current().GetPassword(ctx, email)
+}
+
+func (r *ReloadableStatic) ListPasswords(ctx context.Context) ([]Password, error) {
+	return r.current().ListPasswords(ctx)
+}
+
+func (r *ReloadableStatic) CreatePassword(ctx context.Context, p Password) error {
+	return r.current().CreatePassword(ctx, p)
+}
+
+func (r *ReloadableStatic) UpdatePassword(ctx context.Context, email string, updater func(old Password) (Password, error)) error {
+	return r.current().UpdatePassword(ctx, email, updater)
+}
+
+func (r *ReloadableStatic) DeletePassword(ctx context.Context, email string) error {
+	return r.current().DeletePassword(ctx, email)
+}
+
+func (r *ReloadableStatic) GetConnector(ctx context.Context, id string) (Connector, error) {
+	return r.current().GetConnector(ctx, id)
+}
+
+func (r *ReloadableStatic) ListConnectors(ctx context.Context) ([]Connector, error) {
+	return r.current().ListConnectors(ctx)
+}
+
+func (r *ReloadableStatic) CreateConnector(ctx context.Context, c Connector) error {
+	return r.current().CreateConnector(ctx, c)
+}
+
+func (r *ReloadableStatic) UpdateConnector(ctx context.Context, id string, updater func(old Connector) (Connector, error)) error {
+	return r.current().UpdateConnector(ctx, id, updater)
+}
+
+func (r *ReloadableStatic) DeleteConnector(ctx context.Context, id string) error {
+	return r.current().DeleteConnector(ctx, id)
 }
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/base32"
 	"errors"
@@ -13,9 +14,11 @@ import (
 
 var (
 	// ErrNotFound is the error returned by storages if a resource cannot be found.
+	// It may be wrapped with additional context, test for it with errors.Is.
 	ErrNotFound = errors.New("not found")
 
 	// ErrAlreadyExists is the error returned by storages if a resource ID is taken during a create.
+	// It may be wrapped with additional context, test for it with errors.Is.
 	ErrAlreadyExists = errors.New("ID already exists")
 )
 
@@ -36,49 +39,102 @@ func NewID() string {
 
 // GCResult returns the number of objects deleted by garbage collection.
 type GCResult struct {
-	AuthRequests int64
-	AuthCodes    int64
+	AuthRequests       int64
+	AuthCodes          int64
+	RevokedTokens      int64
+	Sessions           int64
+	PreAuthorizedCodes int64
+	LoginLinks         int64
 }
 
 // Storage is the storage interface used by the server. Implementations are
 // required to be able to perform atomic compare-and-swap updates and either
 // support timezones or standardize on UTC.
+//
+// Every method except Close takes the context of the request it's performed
+// for. Implementations should abandon the operation once the context is done.
 type Storage interface {
 	Close() error
 
 	// TODO(ericchiang): Let the storages set the IDs of these objects.
-	CreateAuthRequest(a AuthRequest) error
-	CreateClient(c Client) error
-	CreateAuthCode(c AuthCode) error
-	CreateRefresh(r RefreshToken) error
-	CreatePassword(p Password) error
-	CreateOfflineSessions(s OfflineSessions) error
-	CreateConnector(c Connector) error
+	CreateAuthRequest(ctx context.Context, a AuthRequest) error
+	CreateClient(ctx context.Context, c Client) error
+	CreateAuthCode(ctx context.Context, c AuthCode) error
+	CreateRefresh(ctx context.Context, r RefreshToken) error
+	CreatePassword(ctx context.Context, p Password) error
+	CreateOfflineSessions(ctx context.Context, s OfflineSessions) error
+	CreateConnector(ctx context.Context, c Connector) error
+	CreateTermsAcceptance(ctx context.Context, a TermsAcceptance) error
+	CreateAuditEvent(ctx context.Context, e AuditEvent) error
+	CreateAPIKey(ctx context.Context, k APIKey) error
+	CreateServiceAccount(ctx context.Context, a ServiceAccount) error
+	CreateRevokedToken(ctx context.Context, t RevokedToken) error
+	CreateSession(ctx context.Context, s Session) error
+	CreatePreAuthorizedCode(ctx context.Context, c PreAuthorizedCode) error
+	CreateLoginLink(ctx context.Context, l LoginLink) error
+	CreateWebAuthnCredential(ctx context.Context, c WebAuthnCredential) error
+	CreateTOTPSecret(ctx context.Context, t TOTPSecret) error
 
 	// TODO(ericchiang): return (T, bool, error) so we can indicate not found
 	// requests that way instead of using ErrNotFound.
-	GetAuthRequest(id string) (AuthRequest, error)
-	GetAuthCode(id string) (AuthCode, error)
-	GetClient(id string) (Client, error)
-	GetKeys() (Keys, error)
-	GetRefresh(id string) (RefreshToken, error)
-	GetPassword(email string) (Password, error)
-	GetOfflineSessions(userID string, connID string) (OfflineSessions, error)
-	GetConnector(id string) (Connector, error)
-
-	ListClients() ([]Client, error)
-	ListRefreshTokens() ([]RefreshToken, error)
-	ListPasswords() ([]Password, error)
-	ListConnectors() ([]Connector, error)
+	GetAuthRequest(ctx context.Context, id string) (AuthRequest, error)
+	GetAuthCode(ctx context.Context, id string) (AuthCode, error)
+	GetClient(ctx context.Context, id string) (Client, error)
+	GetKeys(ctx context.Context) (Keys, error)
+	GetRefresh(ctx context.Context, id string) (RefreshToken, error)
+	GetPassword(ctx context.Context, email string) (Password, error)
+	GetOfflineSessions(ctx context.Context, userID string, connID string) (OfflineSessions, error)
+	GetConnector(ctx context.Context, id string) (Connector, error)
+	GetTermsAcceptance(ctx context.Context, userID string, connID string) (TermsAcceptance, error)
+	GetAPIKey(ctx context.Context, id string) (APIKey, error)
+	GetServiceAccount(ctx context.Context, id string) (ServiceAccount, error)
+	GetRevokedToken(ctx context.Context, id string) (RevokedToken, error)
+	GetSession(ctx context.Context, id string) (Session, error)
+	GetWebAuthnCredential(ctx context.Context, id string) (WebAuthnCredential, error)
+	GetTOTPSecret(ctx context.Context, userID string, connID string) (TOTPSecret, error)
+
+	ListClients(ctx context.Context) ([]Client, error)
+	ListRefreshTokens(ctx context.Context) ([]RefreshToken, error)
+	ListPasswords(ctx context.Context) ([]Password, error)
+	ListConnectors(ctx context.Context) ([]Connector, error)
+	ListAPIKeys(ctx context.Context) ([]APIKey, error)
+	ListServiceAccounts(ctx context.Context) ([]ServiceAccount, error)
+	ListSessions(ctx context.Context) ([]Session, error)
+
+	// ListWebAuthnCredentials returns the passkeys registered by a user.
+	ListWebAuthnCredentials(ctx context.Context, userID string, connID string) ([]WebAuthnCredential, error)
+
+	// ListAuditEvents returns the audit events matching the filter, newest
+	// first.
+	ListAuditEvents(ctx context.Context, filter AuditEventFilter) ([]AuditEvent, error)
 
 	// Delete methods MUST be atomic.
-	DeleteAuthRequest(id string) error
-	DeleteAuthCode(code string) error
-	DeleteClient(id string) error
-	DeleteRefresh(id string) error
-	DeletePassword(email string) error
-	DeleteOfflineSessions(userID string, connID string) error
-	DeleteConnector(id string) error
+	DeleteAuthRequest(ctx context.Context, id string) error
+	DeleteAuthCode(ctx context.Context, code string) error
+	DeleteClient(ctx context.Context, id string) error
+	DeleteRefresh(ctx context.Context, id string) error
+	DeletePassword(ctx context.Context, email string) error
+	DeleteOfflineSessions(ctx context.Context, userID string, connID string) error
+	DeleteConnector(ctx context.Context, id string) error
+	DeleteTermsAcceptance(ctx context.Context, userID string, connID string) error
+	DeleteAPIKey(ctx context.Context, id string) error
+	DeleteServiceAccount(ctx context.Context, id string) error
+	DeleteSession(ctx context.Context, id string) error
+	DeleteWebAuthnCredential(ctx context.Context, id string) error
+	DeleteTOTPSecret(ctx context.Context, userID string, connID string) error
+
+	// ConsumeAuthCode atomically deletes an auth code and returns the deleted
+	// value. Only one caller can consume a given code, all others receive
+	// ErrNotFound.
+	ConsumeAuthCode(ctx context.Context, id string) (AuthCode, error)
+
+	// ConsumePreAuthorizedCode atomically deletes a pre-authorized code and
+	// returns the deleted value, like ConsumeAuthCode.
+	ConsumePreAuthorizedCode(ctx context.Context, id string) (PreAuthorizedCode, error)
+
+	// ConsumeLoginLink atomically deletes a login link and returns the
+	// deleted value, like ConsumeAuthCode.
+	ConsumeLoginLink(ctx context.Context, id string) (LoginLink, error)
 
 	// Update methods take a function for updating an object then performs that update within
 	// a transaction. "updater" functions may be called multiple times by a single update call.
@@ -90,20 +146,31 @@ type Storage interface {
 	//			old.Secret = newSecret
 	//			return old, nil
 	//		}
-	//		if err := s.UpdateClient(clientID, updater); err != nil {
+	//		if err := s.UpdateClient(ctx, clientID, updater); err != nil {
 	//			// update failed, handle error
 	//		}
 	//
-	UpdateClient(id string, updater func(old Client) (Client, error)) error
-	UpdateKeys(updater func(old Keys) (Keys, error)) error
-	UpdateAuthRequest(id string, updater func(a AuthRequest) (AuthRequest, error)) error
-	UpdateRefreshToken(id string, updater func(r RefreshToken) (RefreshToken, error)) error
-	UpdatePassword(email string, updater func(p Password) (Password, error)) error
-	UpdateOfflineSessions(userID string, connID string, updater func(s OfflineSessions) (OfflineSessions, error)) error
-	UpdateConnector(id string, updater func(c Connector) (Connector, error)) error
-
-	// GarbageCollect deletes all expired AuthCodes and AuthRequests.
-	GarbageCollect(now time.Time) (GCResult, error)
+	UpdateClient(ctx context.Context, id string, updater func(old Client) (Client, error)) error
+	UpdateKeys(ctx context.Context, updater func(old Keys) (Keys, error)) error
+	UpdateAuthRequest(ctx context.Context, id string, updater func(a AuthRequest) (AuthRequest, error)) error
+	UpdateRefreshToken(ctx context.Context, id string, updater func(r RefreshToken) (RefreshToken, error)) error
+	UpdatePassword(ctx context.Context, email string, updater func(p Password) (Password, error)) error
+	UpdateOfflineSessions(ctx context.Context, userID string, connID string, updater func(s OfflineSessions) (OfflineSessions, error)) error
+	UpdateConnector(ctx context.Context, id string, updater func(c Connector) (Connector, error)) error
+	UpdateTermsAcceptance(ctx context.Context, userID string, connID string, updater func(a TermsAcceptance) (TermsAcceptance, error)) error
+	UpdateAuditEvent(ctx context.Context, id string, updater func(e AuditEvent) (AuditEvent, error)) error
+	UpdateServiceAccount(ctx context.Context, id string, updater func(a ServiceAccount) (ServiceAccount, error)) error
+	UpdateSession(ctx context.Context, id string, updater func(s Session) (Session, error)) error
+	UpdateWebAuthnCredential(ctx context.Context, id string, updater func(c WebAuthnCredential) (WebAuthnCredential, error)) error
+	UpdateTOTPSecret(ctx context.Context, userID string, connID string, updater func(t TOTPSecret) (TOTPSecret, error)) error
+
+	// GarbageCollect deletes all expired AuthCodes, AuthRequests,
+	// RevokedTokens, Sessions, PreAuthorizedCodes and LoginLinks.
+	GarbageCollect(ctx context.Context, now time.Time) (GCResult, error)
+
+	// PruneAuditEvents deletes all audit events older than before and returns
+	// the number of deleted events.
+	PruneAuditEvents(ctx context.Context, before time.Time) (int64, error)
 }
 
 // Client represents an OAuth2 client.
@@ -135,6 +202,32 @@ type Client struct {
 	// Name and LogoURL used when displaying this client to the end user.
 	Name    string `json:"name" yaml:"name"`
 	LogoURL string `json:"logoURL" yaml:"logoURL"`
+
+	// AllowedCIDRs restricts the networks tokens may be requested from for this
+	// client, for example "10.0.0.0/8". If empty, requests from any address are
+	// allowed.
+	AllowedCIDRs []string `json:"allowedCIDRs,omitempty" yaml:"allowedCIDRs,omitempty"`
+
+	// Claims are added to every token issued to this client, for example
+	// a tenant ID. They can't replace the claims set by dex.
+	Claims map[string]interface{} `json:"claims,omitempty" yaml:"claims,omitempty"`
+
+	// Client metadata defined by OAuth 2.0 Dynamic Client Registration,
+	// shown to the end user alongside Name and LogoURL. ApplicationType is
+	// either "web" or "native".
+	TOSURI          string   `json:"tosURI,omitempty" yaml:"tosURI,omitempty"`
+	PolicyURI       string   `json:"policyURI,omitempty" yaml:"policyURI,omitempty"`
+	Contacts        []string `json:"contacts,omitempty" yaml:"contacts,omitempty"`
+	ApplicationType string   `json:"applicationType,omitempty" yaml:"applicationType,omitempty"`
+
+	// Expiry after which the client can no longer authenticate and is
+	// deleted along with its tokens. Zero for clients that don't expire.
+	Expiry time.Time `json:"expiry,omitempty" yaml:"expiry,omitempty"`
+
+	// BackchannelLogoutURI receives logout tokens when a session of a user
+	// logged in to the client ends, as defined by OpenID Connect
+	// Back-Channel Logout.
+	BackchannelLogoutURI string `json:"backchannelLogoutURI,omitempty" yaml:"backchannelLogoutURI,omitempty"`
 }
 
 // Claims represents the ID Token claims supported by the server.
@@ -185,6 +278,29 @@ type AuthRequest struct {
 	// Set when the user authenticates.
 	ConnectorID   string
 	ConnectorData []byte
+
+	// The connector the user was offered ConnectorID as a fallback for,
+	// because it failed to reach its upstream identity provider.
+	FallbackFrom string
+
+	// The code challenge sent by the client, if any.
+	PKCE PKCE
+
+	// The second factor, such as "webauthn", the user must pass before the
+	// login is approved. Set when the user authenticates and cleared once
+	// the second factor is passed.
+	SecondFactor string
+	// The challenge of the user's current attempt at the second factor.
+	SecondFactorChallenge []byte
+}
+
+// PKCE is a code challenge of a client, which the client must answer with
+// the matching code verifier when exchanging the auth code.
+//
+// https://tools.ietf.org/html/rfc7636
+type PKCE struct {
+	CodeChallenge       string
+	CodeChallengeMethod string
 }
 
 // AuthCode represents a code which can be exchanged for an OAuth2 token response.
@@ -220,6 +336,9 @@ type AuthCode struct {
 	Claims        Claims
 
 	Expiry time.Time
+
+	// The code challenge sent by the client, if any.
+	PKCE PKCE
 }
 
 // RefreshToken is an OAuth2 refresh token which allows a client to request new
@@ -235,6 +354,14 @@ type RefreshToken struct {
 	CreatedAt time.Time
 	LastUsed  time.Time
 
+	// Address of the client that last obtained or rotated this token. Used to
+	// report both parties when a rotated token is presented again.
+	LastUsedIP string
+
+	// Hash of the device fingerprint supplied by the client when the token
+	// was issued. If set, refresh requests must present the same fingerprint.
+	DeviceFingerprint string
+
 	// Client this refresh token is valid for.
 	ClientID string
 
@@ -262,6 +389,10 @@ type RefreshTokenRef struct {
 
 	CreatedAt time.Time
 	LastUsed  time.Time
+
+	// IdentityRefreshedAt is when the identity was last refreshed from the
+	// connector. Zero if it hasn't been since the token was created.
+	IdentityRefreshedAt time.Time
 }
 
 // OfflineSessions objects are sessions pertaining to users with refresh tokens.
@@ -280,6 +411,237 @@ type OfflineSessions struct {
 	ConnectorData []byte
 }
 
+// TermsAcceptance records the version of the terms of service a user last
+// accepted.
+type TermsAcceptance struct {
+	// UserID and ConnID identify the user, like in OfflineSessions.
+	UserID string
+	ConnID string
+
+	// Version of the accepted document.
+	Version string
+
+	AcceptedAt time.Time
+}
+
+// AuditEvent is a persisted audit record.
+type AuditEvent struct {
+	// ID is a unique identifier of the event, see NewID.
+	ID string
+
+	Type     string
+	Severity string
+	Time     time.Time
+
+	ClientID    string
+	Subject     string
+	ConnectorID string
+	SourceIPs   []string
+
+	Message string
+	Revoked bool
+}
+
+// AuditEventFilter selects audit events. Zero fields match any event.
+type AuditEventFilter struct {
+	Subject  string
+	ClientID string
+	Type     string
+
+	// Since and Until bound the time of the events, Since inclusive and
+	// Until exclusive.
+	Since time.Time
+	Until time.Time
+
+	// Limit is the maximum number of events returned.
+	Limit int
+}
+
+// Matches reports whether the event is selected by the filter, ignoring
+// Limit.
+func (f AuditEventFilter) Matches(e AuditEvent) bool {
+	switch {
+	case f.Subject != "" && e.Subject != f.Subject,
+		f.ClientID != "" && e.ClientID != f.ClientID,
+		f.Type != "" && e.Type != f.Type,
+		!f.Since.IsZero() && e.Time.Before(f.Since),
+		!f.Until.IsZero() && !e.Time.Before(f.Until):
+		return false
+	}
+	return true
+}
+
+// APIKey is a long-lived credential which can be exchanged at the token
+// endpoint for short-lived tokens of a client, restricted to a set of
+// scopes.
+type APIKey struct {
+	// ID is the public part of the key, used to look it up, see NewID.
+	ID string
+
+	// Hash is the hex encoded SHA-256 hash of the secret part of the key.
+	// Secrets are random, so they don't need a slow password hash.
+	Hash string
+
+	ClientID string
+
+	// Name describes what the key is used for.
+	Name string
+
+	// Scopes tokens may be requested with.
+	Scopes []string
+
+	// The identity tokens are issued for, and the connector it belongs to.
+	Claims      Claims
+	ConnectorID string
+
+	CreatedAt time.Time
+
+	// Expiry is zero for keys that don't expire.
+	Expiry time.Time
+}
+
+// RevokedToken is a tombstone of a revoked refresh token, kept for a while
+// after the token is deleted so revoked tokens can be told apart from
+// tokens that never existed.
+type RevokedToken struct {
+	// ID of the refresh token, also its "jti" when introspected.
+	ID string
+
+	ClientID string
+
+	// Reason the token was revoked, such as "reuse_detected".
+	Reason string
+
+	RevokedAt time.Time
+
+	// Expiry is when the tombstone is garbage collected.
+	Expiry time.Time
+}
+
+// Session is a user's login to dex, kept in a browser cookie so the user
+// isn't sent to a connector again for every authorization request.
+type Session struct {
+	ID string
+
+	// The connector the user logged in with and the identity it returned,
+	// before claim transforms.
+	ConnectorID   string
+	Claims        Claims
+	ConnectorData []byte
+
+	// Clients the user logged in to during the session.
+	ClientIDs []string
+
+	CreatedAt time.Time
+	Expiry    time.Time
+}
+
+// PreAuthorizedCode is a code generated by an admin which can be exchanged
+// for tokens without an interactive login, like the pre-authorized code of
+// OpenID for Verifiable Credential Issuance.
+type PreAuthorizedCode struct {
+	// Actual string exchanged for tokens.
+	ID string
+
+	// The client tokens are issued for.
+	ClientID string
+
+	// Scopes tokens are issued with.
+	Scopes []string
+
+	// The identity tokens are issued for, and the connector it belongs to.
+	Claims      Claims
+	ConnectorID string
+
+	// PINHash is the bcrypt hash of the PIN the code must be exchanged with,
+	// or empty if it needs none.
+	PINHash []byte
+
+	CreatedAt time.Time
+	Expiry    time.Time
+}
+
+// LoginLink is a single-use link emailed by a passwordless connector. Opening
+// it logs the owner of the email address in to the auth request it was sent
+// for.
+type LoginLink struct {
+	// Actual string sent in the link.
+	ID string
+
+	// The auth request the link completes.
+	AuthRequestID string
+
+	// The connector which sent the link, and the address it was sent to.
+	ConnectorID string
+	Email       string
+
+	CreatedAt time.Time
+	Expiry    time.Time
+}
+
+// ServiceAccount is the identity of a workload, kept apart from clients.
+// Service accounts authenticate with JWT assertions signed by one of their
+// keys and are issued tokens for one of their clients.
+type ServiceAccount struct {
+	// ID of the service account, also the subject of its tokens.
+	ID   string
+	Name string
+
+	Groups []string
+
+	// IDs of the clients tokens may be issued for.
+	Clients []string
+
+	// PublicKeys verify the assertions of the service account.
+	PublicKeys []jose.JSONWebKey
+
+	CreatedAt time.Time
+}
+
+// WebAuthnCredential is a passkey or security key a user registered as a
+// second factor.
+type WebAuthnCredential struct {
+	// ID is the credential ID chosen by the authenticator, base64url encoded.
+	ID string
+
+	// The user the credential belongs to.
+	UserID      string
+	ConnectorID string
+
+	// PublicKey is the COSE encoded public key of the credential.
+	PublicKey []byte
+
+	// SignCount is the signature counter the authenticator last reported.
+	SignCount uint32
+
+	CreatedAt time.Time
+	LastUsed  time.Time
+}
+
+// TOTPSecret is the shared secret of the authenticator app a user enrolled as
+// a second factor.
+type TOTPSecret struct {
+	// UserID and ConnID identify the user, like in OfflineSessions.
+	UserID string
+	ConnID string
+
+	// Secret the codes are derived from.
+	Secret []byte
+
+	// Confirmed is set once the user entered a code from their app. Until
+	// then the secret is only a pending enrollment.
+	Confirmed bool
+
+	// LastStep is the time step of the last accepted code, codes of this and
+	// earlier steps are refused so that they can't be replayed.
+	LastStep int64
+
+	// BackupCodes are the SHA-256 hashes of the unused backup codes.
+	BackupCodes [][]byte
+
+	CreatedAt time.Time
+}
+
 // Password is an email to password mapping managed by the storage.
 type Password struct {
 	// Email and identifying name of the password. Emails are assumed to be valid and
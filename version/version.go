@@ -3,3 +3,7 @@ package version
 
 // Version is set by the build scripts.
 var Version = "was not built properly"
+
+// GitCommit is the commit the binary was built from. It's set by the build
+// scripts.
+var GitCommit = ""